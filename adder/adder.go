@@ -5,6 +5,7 @@ package adder
 import (
 	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"strings"
 
@@ -40,6 +41,7 @@ type Adder struct {
 	dgs ClusterDAGService
 
 	params *api.AddParams
+	limits Limits
 
 	// AddedOutput updates are placed on this channel
 	// whenever a block is processed. They contain information
@@ -70,6 +72,11 @@ func New(ds ClusterDAGService, p *api.AddParams, out chan *api.AddedOutput) *Add
 	}
 }
 
+// SetLimits sets the limits on the content accepted by FromMultipart.
+func (a *Adder) SetLimits(limits Limits) {
+	a.limits = limits
+}
+
 func (a *Adder) setContext(ctx context.Context) {
 	if a.ctx == nil { // only allows first context
 		ctxc, cancel := context.WithCancel(ctx)
@@ -83,12 +90,22 @@ func (a *Adder) setContext(ctx context.Context) {
 func (a *Adder) FromMultipart(ctx context.Context, r *multipart.Reader) (cid.Cid, error) {
 	logger.Debugf("adding from multipart with params: %+v", a.params)
 
+	var meta *partsMetadata
+	if a.params.PreserveMode || a.params.PreserveMtime {
+		var closer io.Closer
+		r, meta, closer = readPartsMetadata(r)
+		defer closer.Close()
+	}
+
 	f, err := files.NewFileFromPartReader(r, "multipart/form-data")
 	if err != nil {
 		return cid.Undef, err
 	}
 	defer f.Close()
-	return a.FromFiles(ctx, f)
+	if meta != nil {
+		f = withMetadata(f, meta)
+	}
+	return a.FromFiles(ctx, limitDirectory(f, a.limits))
 }
 
 // FromFiles adds content from a files.Directory. The adder will no longer
@@ -116,6 +133,10 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (cid.Cid, erro
 	ipfsAdder.Out = a.output
 	ipfsAdder.Progress = a.params.Progress
 	ipfsAdder.NoCopy = a.params.NoCopy
+	ipfsAdder.FileMode = a.params.Mode
+	ipfsAdder.FileMtime = a.params.Mtime
+	ipfsAdder.PreserveMode = a.params.PreserveMode
+	ipfsAdder.PreserveMtime = a.params.PreserveMtime
 
 	// Set up prefix
 	prefix, err := merkledag.PrefixForCidVersion(a.params.CidVersion)
@@ -131,6 +152,14 @@ func (a *Adder) FromFiles(ctx context.Context, f files.Directory) (cid.Cid, erro
 	prefix.MhLength = -1
 	ipfsAdder.CidBuilder = &prefix
 
+	// Hidden files are only skipped in recursive adds, as with ipfs.
+	if a.params.Recursive && !a.params.Hidden {
+		f = skipHidden(f)
+	}
+	if a.params.RootName != "" {
+		f = renameRoot(f, a.params.RootName)
+	}
+
 	// setup wrapping
 	if a.params.Wrap {
 		f = files.NewSliceDirectory(
@@ -1,8 +1,11 @@
 package adder
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"mime/multipart"
+	"net/textproto"
 	"sync"
 	"testing"
 	"time"
@@ -13,6 +16,10 @@ import (
 	cid "github.com/ipfs/go-cid"
 	files "github.com/ipfs/go-ipfs-files"
 	ipld "github.com/ipfs/go-ipld-format"
+	merkledag "github.com/ipfs/go-merkledag"
+	pb "github.com/ipfs/go-unixfs/pb"
+
+	proto "github.com/gogo/protobuf/proto"
 )
 
 type mockCDAGServ struct {
@@ -76,6 +83,35 @@ func TestAdder(t *testing.T) {
 	}
 }
 
+func TestAdder_Limits(t *testing.T) {
+	testcases := []struct {
+		limits Limits
+		err    error
+	}{
+		{Limits{MaxFiles: 2}, ErrTooManyFiles},
+		{Limits{MaxPathDepth: 1}, ErrPathTooDeep},
+		{Limits{MaxFiles: 1000, MaxPathDepth: 10}, nil},
+	}
+
+	for _, tc := range testcases {
+		sth := test.NewShardingTestHelper()
+		mr, closer := sth.GetTreeMultiReader(t)
+		r := multipart.NewReader(mr, mr.Boundary())
+		dags := &mockCDAGServ{
+			resultCids: make(map[string]struct{}),
+		}
+
+		adder := New(dags, api.DefaultAddParams(), nil)
+		adder.SetLimits(tc.limits)
+		_, err := adder.FromMultipart(context.Background(), r)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%+v: expected error %v, got %v", tc.limits, tc.err, err)
+		}
+		closer.Close()
+		sth.Clean(t)
+	}
+}
+
 func TestAdder_DoubleStart(t *testing.T) {
 	sth := test.NewShardingTestHelper()
 	defer sth.Clean(t)
@@ -142,3 +178,157 @@ func TestAdder_ContextCancelled(t *testing.T) {
 	cancel()
 	wg.Wait()
 }
+
+func TestAdder_Names(t *testing.T) {
+	newDir := func() files.Directory {
+		return files.NewMapDirectory(map[string]files.Node{
+			"dir": files.NewMapDirectory(map[string]files.Node{
+				"a":       files.NewBytesFile([]byte("a")),
+				".hidden": files.NewBytesFile([]byte("hidden")),
+			}),
+		})
+	}
+
+	addNames := func(p *api.AddParams, dir files.Directory) (map[string]bool, error) {
+		out := make(chan *api.AddedOutput, 100)
+		dags := &mockCDAGServ{
+			resultCids: make(map[string]struct{}),
+		}
+		_, err := New(dags, p, out).FromFiles(context.Background(), dir)
+		names := make(map[string]bool)
+		for o := range out {
+			names[o.Name] = true
+		}
+		return names, err
+	}
+
+	p := api.DefaultAddParams()
+	p.Recursive = true
+	p.Wrap = true
+	p.RootName = "renamed"
+	names, err := addNames(p, newDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !names["renamed/a"] || names["dir/a"] {
+		t.Errorf("expected the root to be renamed: %v", names)
+	}
+	if names["renamed/.hidden"] {
+		t.Error("hidden files should be skipped")
+	}
+
+	p = api.DefaultAddParams()
+	p.Recursive = true
+	p.Hidden = true
+	names, err = addNames(p, newDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !names["dir/.hidden"] {
+		t.Errorf("hidden files should be added: %v", names)
+	}
+
+	p = api.DefaultAddParams()
+	p.RootName = "renamed"
+	two := files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewBytesFile([]byte("a")),
+		"b": files.NewBytesFile([]byte("b")),
+	})
+	_, err = addNames(p, two)
+	if err != ErrRootNameMultiple {
+		t.Errorf("expected ErrRootNameMultiple, got %v", err)
+	}
+}
+
+type nodesDAGServ struct {
+	mockCDAGServ
+	nodes map[cid.Cid]ipld.Node
+}
+
+func (dag *nodesDAGServ) Add(ctx context.Context, node ipld.Node) error {
+	dag.nodes[node.Cid()] = node
+	return nil
+}
+
+func TestAdder_Metadata(t *testing.T) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="file"; filename="file.txt"`)
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set(api.AddModeHeader, "640")
+	header.Set(api.AddMtimeHeader, "1600000000")
+	w, err := mw.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("hello"))
+	mw.Close()
+
+	add := func(p *api.AddParams) (ipld.Node, error) {
+		dags := &nodesDAGServ{nodes: make(map[cid.Cid]ipld.Node)}
+		r := multipart.NewReader(bytes.NewReader(body.Bytes()), mw.Boundary())
+		root, err := New(dags, p, nil).FromMultipart(context.Background(), r)
+		if err != nil {
+			return nil, err
+		}
+		return dags.nodes[root], nil
+	}
+
+	plain, err := add(api.DefaultAddParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := api.DefaultAddParams()
+	p.PreserveMode = true
+	p.PreserveMtime = true
+	p.RawLeaves = true
+	nd, err := add(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd.Cid().Equals(plain.Cid()) {
+		t.Fatal("the metadata should have been stored")
+	}
+	pn, ok := nd.(*merkledag.ProtoNode)
+	if !ok {
+		t.Fatal("a single chunk file with metadata should be a file node")
+	}
+	if len(pn.Links()) != 0 {
+		t.Error("the data should be held by the file node, not a raw leaf")
+	}
+	pbdata := new(pb.Data)
+	if err := proto.Unmarshal(pn.Data(), pbdata); err != nil {
+		t.Fatal(err)
+	}
+	// mode (field 7) 0640, then mtime (field 8) with 1600000000 seconds
+	expected := []byte{0x38, 0xa0, 0x03, 0x42, 0x06, 0x08, 0x80, 0xa0, 0xf8, 0xfa, 0x05}
+	if !bytes.Equal(pbdata.XXX_unrecognized, expected) {
+		t.Errorf("unexpected metadata fields: %x", pbdata.XXX_unrecognized)
+	}
+	if pbdata.GetFilesize() != 5 || string(pbdata.GetData()) != "hello" {
+		t.Error("the file size and data should be kept")
+	}
+
+	// Files of several chunks keep their raw leaves.
+	p.Chunker = "size-2"
+	nd, err = add(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pn, ok = nd.(*merkledag.ProtoNode)
+	if !ok || len(pn.Links()) != 3 {
+		t.Fatal("expected a file node with three leaves")
+	}
+	if pn.Links()[0].Cid.Prefix().Codec != cid.Raw {
+		t.Error("the leaves should be raw")
+	}
+	pbdata = new(pb.Data)
+	if err := proto.Unmarshal(pn.Data(), pbdata); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pbdata.XXX_unrecognized, expected) {
+		t.Errorf("unexpected metadata fields: %x", pbdata.XXX_unrecognized)
+	}
+}
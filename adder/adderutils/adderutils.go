@@ -4,6 +4,7 @@ package adderutils
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"mime/multipart"
 	"net/http"
 	"sync"
@@ -23,7 +24,9 @@ var logger = logging.Logger("adder")
 // AddMultipartHTTPHandler is a helper function to add content
 // uploaded using a multipart request. The outputTransform parameter
 // allows to customize the http response output format to something
-// else than api.AddedOutput objects.
+// else than api.AddedOutput objects. Requests exceeding the given limits
+// fail with a 413 (Request Entity Too Large) status when the response
+// is not streamed.
 func AddMultipartHTTPHandler(
 	ctx context.Context,
 	rpc *rpc.Client,
@@ -31,6 +34,7 @@ func AddMultipartHTTPHandler(
 	reader *multipart.Reader,
 	w http.ResponseWriter,
 	outputTransform func(*api.AddedOutput) interface{},
+	limits adder.Limits,
 ) (cid.Cid, error) {
 	var dags adder.ClusterDAGService
 	output := make(chan *api.AddedOutput, 200)
@@ -68,12 +72,17 @@ func AddMultipartHTTPHandler(
 
 		enc := json.NewEncoder(w)
 		add := adder.New(dags, params, output)
+		add.SetLimits(limits)
 		root, err := add.FromMultipart(ctx, reader)
 		if err != nil { // Send an error
 			logger.Error(err)
-			w.WriteHeader(http.StatusInternalServerError)
+			code := http.StatusInternalServerError
+			if errors.Is(err, adder.ErrTooManyFiles) || errors.Is(err, adder.ErrPathTooDeep) {
+				code = http.StatusRequestEntityTooLarge
+			}
+			w.WriteHeader(code)
 			errorResp := api.Error{
-				Code:    http.StatusInternalServerError,
+				Code:    code,
 				Message: err.Error(),
 			}
 
@@ -102,6 +111,7 @@ func AddMultipartHTTPHandler(
 		streamOutput(w, output, outputTransform)
 	}()
 	add := adder.New(dags, params, output)
+	add.SetLimits(limits)
 	root, err := add.FromMultipart(ctx, reader)
 	if err != nil {
 		logger.Error(err)
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	gopath "path"
 	"path/filepath"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 
@@ -65,6 +67,15 @@ type Adder struct {
 	// filename in the case of single files here and emit those events
 	// correctly from the beginning).
 	OutputPrefix string
+	// Cluster: the mode and modification time stored in the UnixFS
+	// nodes of the files added (UnixFS 1.5), when not zero. With
+	// PreserveMode and PreserveMtime, those of the files are stored
+	// when known (see files.FileInfo). Directories and symlinks are
+	// stored without them.
+	FileMode      os.FileMode
+	FileMtime     time.Time
+	PreserveMode  bool
+	PreserveMtime bool
 }
 
 func (adder *Adder) mfsRoot() (*mfs.Root, error) {
@@ -87,17 +98,27 @@ func (adder *Adder) SetMfsRoot(r *mfs.Root) {
 }
 
 // Constructs a node from reader's data, and adds it. Doesn't pin.
-func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
+func (adder *Adder) add(reader io.Reader, withMetadata bool) (ipld.Node, error) {
 	chnk, err := chunker.FromString(reader, adder.Chunker)
 	if err != nil {
 		return nil, err
 	}
 
+	// Cluster: the metadata of a file is stored in its root, which
+	// cannot be a raw leaf. Files which fit in a single chunk are added
+	// as a file node holding the data instead.
+	rawLeaves := adder.RawLeaves
+	if withMetadata && rawLeaves && !adder.Trickle {
+		ps := newPeekSplitter(chnk)
+		rawLeaves = !ps.single()
+		chnk = ps
+	}
+
 	// Cluster: we don't do batching/use BufferedDS.
 
 	params := ihelper.DagBuilderParams{
 		Dagserv:    adder.dagService,
-		RawLeaves:  adder.RawLeaves,
+		RawLeaves:  rawLeaves,
 		Maxlinks:   ihelper.DefaultLinksPerBlock,
 		NoCopy:     adder.NoCopy,
 		CidBuilder: adder.CidBuilder,
@@ -381,7 +402,12 @@ func (adder *Adder) addFile(path string, file files.File) error {
 		}
 	}
 
-	dagnode, err := adder.add(reader)
+	dagnode, err := adder.add(reader, adder.hasMetadata(file))
+	if err != nil {
+		return err
+	}
+
+	dagnode, err = adder.setMetadata(dagnode, file)
 	if err != nil {
 		return err
 	}
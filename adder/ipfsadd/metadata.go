@@ -0,0 +1,173 @@
+package ipfsadd
+
+import (
+	"io"
+	"os"
+	"time"
+
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	files "github.com/ipfs/go-ipfs-files"
+	posinfo "github.com/ipfs/go-ipfs-posinfo"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	pb "github.com/ipfs/go-unixfs/pb"
+
+	proto "github.com/gogo/protobuf/proto"
+)
+
+// Cluster: the Data message of go-unixfs v0.2.2 predates the mode and mtime
+// fields of UnixFS 1.5. unixfsData is the same message with them
+// (see https://github.com/ipfs/specs/blob/master/UNIXFS.md).
+type unixfsData struct {
+	Type             *pb.Data_DataType `protobuf:"varint,1,req,name=Type,enum=unixfs.pb.Data_DataType"`
+	Data             []byte            `protobuf:"bytes,2,opt,name=Data"`
+	Filesize         *uint64           `protobuf:"varint,3,opt,name=filesize"`
+	Blocksizes       []uint64          `protobuf:"varint,4,rep,name=blocksizes"`
+	HashType         *uint64           `protobuf:"varint,5,opt,name=hashType"`
+	Fanout           *uint64           `protobuf:"varint,6,opt,name=fanout"`
+	Mode             *uint32           `protobuf:"varint,7,opt,name=mode"`
+	Mtime            *unixfsTime       `protobuf:"bytes,8,opt,name=mtime"`
+	XXX_unrecognized []byte
+}
+
+func (m *unixfsData) Reset()         { *m = unixfsData{} }
+func (m *unixfsData) String() string { return proto.CompactTextString(m) }
+func (*unixfsData) ProtoMessage()    {}
+
+// unixfsTime is the UnixTime message of UnixFS 1.5.
+type unixfsTime struct {
+	Seconds               *int64  `protobuf:"varint,1,req,name=Seconds"`
+	FractionalNanoseconds *uint32 `protobuf:"fixed32,2,opt,name=FractionalNanoseconds"`
+}
+
+func (m *unixfsTime) Reset()         { *m = unixfsTime{} }
+func (m *unixfsTime) String() string { return proto.CompactTextString(m) }
+func (*unixfsTime) ProtoMessage()    {}
+
+// fileMetadata returns the mode and modification time to store for the
+// given file: the ones set in the adder or, when preserving them, the ones
+// of the file, if known.
+func (adder *Adder) fileMetadata(file files.File) (os.FileMode, time.Time) {
+	mode := adder.FileMode
+	mtime := adder.FileMtime
+	fi, ok := file.(files.FileInfo)
+	if !ok || fi.Stat() == nil {
+		return mode, mtime
+	}
+	if adder.PreserveMode && mode == 0 {
+		mode = fi.Stat().Mode()
+	}
+	if adder.PreserveMtime && mtime.IsZero() {
+		mtime = fi.Stat().ModTime()
+	}
+	return mode, mtime
+}
+
+// hasMetadata returns whether a mode or a modification time are stored for
+// the given file.
+func (adder *Adder) hasMetadata(file files.File) bool {
+	mode, mtime := adder.fileMetadata(file)
+	return mode != 0 || !mtime.IsZero()
+}
+
+// setMetadata returns the root node of a file with the mode and the
+// modification time of the file set, when there are any to store. The
+// returned node is added to the DAG service. The root must be a file node:
+// files with metadata are not added as a single raw leaf (see add).
+func (adder *Adder) setMetadata(nd ipld.Node, file files.File) (ipld.Node, error) {
+	mode, mtime := adder.fileMetadata(file)
+	if mode == 0 && mtime.IsZero() {
+		return nd, nil
+	}
+
+	if fn, ok := nd.(*posinfo.FilestoreNode); ok {
+		nd = fn.Node
+	}
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return nd, nil
+	}
+	pn = pn.Copy().(*dag.ProtoNode)
+
+	pbdata := new(unixfsData)
+	if err := proto.Unmarshal(pn.Data(), pbdata); err != nil {
+		return nil, err
+	}
+	if mode != 0 {
+		pbdata.Mode = proto.Uint32(unixfsMode(mode))
+	}
+	if !mtime.IsZero() {
+		pbdata.Mtime = &unixfsTime{Seconds: proto.Int64(mtime.Unix())}
+		if nsecs := mtime.Nanosecond(); nsecs != 0 {
+			pbdata.Mtime.FractionalNanoseconds = proto.Uint32(uint32(nsecs))
+		}
+	}
+	data, err := proto.Marshal(pbdata)
+	if err != nil {
+		return nil, err
+	}
+	pn.SetData(data)
+	pn.SetCidBuilder(adder.CidBuilder)
+
+	if err := adder.dagService.Add(adder.ctx, pn); err != nil {
+		return nil, err
+	}
+	return pn, nil
+}
+
+// unixfsMode returns the POSIX permission and special bits of a mode, as
+// stored in UnixFS.
+func unixfsMode(mode os.FileMode) uint32 {
+	m := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+// peekSplitter is a chunker.Splitter which reads ahead the first chunks of
+// another one, to learn whether there is more than one.
+type peekSplitter struct {
+	chunker.Splitter
+	peeked [][]byte
+	err    error
+}
+
+// newPeekSplitter reads up to two chunks from the given splitter. They are
+// returned again by NextBytes.
+func newPeekSplitter(spl chunker.Splitter) *peekSplitter {
+	ps := &peekSplitter{Splitter: spl}
+	for i := 0; i < 2 && ps.err == nil; i++ {
+		b, err := spl.NextBytes()
+		if err != nil {
+			ps.err = err
+			break
+		}
+		ps.peeked = append(ps.peeked, b)
+	}
+	return ps
+}
+
+// single returns whether the data fits in a single chunk.
+func (ps *peekSplitter) single() bool {
+	return ps.err == io.EOF && len(ps.peeked) <= 1
+}
+
+// NextBytes returns the peeked chunks first.
+func (ps *peekSplitter) NextBytes() ([]byte, error) {
+	if len(ps.peeked) > 0 {
+		b := ps.peeked[0]
+		ps.peeked = ps.peeked[1:]
+		return b, nil
+	}
+	if ps.err != nil {
+		return nil, ps.err
+	}
+	return ps.Splitter.NextBytes()
+}
@@ -0,0 +1,99 @@
+package adder
+
+import (
+	"errors"
+
+	files "github.com/ipfs/go-ipfs-files"
+)
+
+// Errors returned when the content given to FromMultipart exceeds the
+// Limits of the Adder.
+var (
+	ErrTooManyFiles = errors.New("too many files in request")
+	ErrPathTooDeep  = errors.New("file path too deep in request")
+)
+
+// Limits bound the content that an Adder accepts from a multipart request,
+// so that requests from untrusted users cannot exhaust the resources of the
+// peer. Zero values mean no limit.
+type Limits struct {
+	// MaxFiles is the maximum number of files and directories.
+	MaxFiles int
+	// MaxPathDepth is the maximum number of components in the path
+	// of a file.
+	MaxPathDepth int
+}
+
+// limitedDirectory wraps a files.Directory so that iterating it fails
+// once the limits are exceeded.
+type limitedDirectory struct {
+	files.Directory
+
+	limits Limits
+	depth  int
+	count  *int // shared by all the directories in the tree
+}
+
+func limitDirectory(dir files.Directory, limits Limits) files.Directory {
+	if limits.MaxFiles <= 0 && limits.MaxPathDepth <= 0 {
+		return dir
+	}
+	return &limitedDirectory{
+		Directory: dir,
+		limits:    limits,
+		count:     new(int),
+	}
+}
+
+func (dir *limitedDirectory) Entries() files.DirIterator {
+	return &limitedIterator{
+		DirIterator: dir.Directory.Entries(),
+		dir:         dir,
+	}
+}
+
+type limitedIterator struct {
+	files.DirIterator
+
+	dir *limitedDirectory
+	err error
+}
+
+func (it *limitedIterator) Next() bool {
+	if it.err != nil || !it.DirIterator.Next() {
+		return false
+	}
+
+	limits := it.dir.limits
+	*it.dir.count++
+	if limits.MaxFiles > 0 && *it.dir.count > limits.MaxFiles {
+		it.err = ErrTooManyFiles
+		return false
+	}
+	if limits.MaxPathDepth > 0 && it.dir.depth+1 > limits.MaxPathDepth {
+		it.err = ErrPathTooDeep
+		return false
+	}
+	return true
+}
+
+func (it *limitedIterator) Node() files.Node {
+	n := it.DirIterator.Node()
+	subdir, ok := n.(files.Directory)
+	if !ok {
+		return n
+	}
+	return &limitedDirectory{
+		Directory: subdir,
+		limits:    it.dir.limits,
+		depth:     it.dir.depth + 1,
+		count:     it.dir.count,
+	}
+}
+
+func (it *limitedIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.DirIterator.Err()
+}
@@ -0,0 +1,186 @@
+package adder
+
+import (
+	"io"
+	"mime/multipart"
+	"net/url"
+	"os"
+	gopath "path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	files "github.com/ipfs/go-ipfs-files"
+)
+
+// fileMetadata is the mode and the modification time of a file, as sent
+// in the headers of its part of a multipart request.
+type fileMetadata struct {
+	name  string
+	mode  os.FileMode
+	mtime time.Time
+}
+
+// partsMetadata records the metadata of the files in a multipart request
+// by their path, as it is read.
+type partsMetadata struct {
+	mu    sync.Mutex
+	files map[string]*fileMetadata
+}
+
+// readPartsMetadata returns a multipart.Reader with the same parts as the
+// given one and the metadata of their files, which is recorded as parts
+// are read from the returned reader. The returned closer must be called
+// once done.
+func readPartsMetadata(r *multipart.Reader) (*multipart.Reader, *partsMetadata, io.Closer) {
+	meta := &partsMetadata{files: make(map[string]*fileMetadata)}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	go func() {
+		for {
+			part, err := r.NextPart()
+			if err == io.EOF {
+				pw.CloseWithError(mw.Close())
+				return
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			meta.record(part)
+			w, err := mw.CreatePart(part.Header)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(w, part); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	return multipart.NewReader(pr, mw.Boundary()), meta, pr
+}
+
+// record records the metadata in the headers of the given part, if any.
+func (meta *partsMetadata) record(part *multipart.Part) {
+	md := &fileMetadata{}
+	if v := part.Header.Get(api.AddModeHeader); v != "" {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err == nil {
+			md.mode = os.FileMode(mode).Perm()
+		}
+	}
+	if v := part.Header.Get(api.AddMtimeHeader); v != "" {
+		secs, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			nsecs, _ := strconv.ParseInt(part.Header.Get(api.AddMtimeNsecsHeader), 10, 64)
+			md.mtime = time.Unix(secs, nsecs)
+		}
+	}
+	if md.mode == 0 && md.mtime.IsZero() {
+		return
+	}
+
+	// Paths are normalized as in files.NewFileFromPartReader.
+	name := part.FileName()
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		name = unescaped
+	}
+	name = gopath.Clean("/" + name)
+	md.name = gopath.Base(name)
+
+	meta.mu.Lock()
+	meta.files[name] = md
+	meta.mu.Unlock()
+}
+
+func (meta *partsMetadata) get(path string) (*fileMetadata, bool) {
+	meta.mu.Lock()
+	defer meta.mu.Unlock()
+	md, ok := meta.files[path]
+	return md, ok
+}
+
+// withMetadata returns a files.Directory whose files provide the recorded
+// metadata with their Stat() (see files.FileInfo).
+func withMetadata(dir files.Directory, meta *partsMetadata) files.Directory {
+	return &metadataDirectory{Directory: dir, path: "/", meta: meta}
+}
+
+type metadataDirectory struct {
+	files.Directory
+
+	path string
+	meta *partsMetadata
+}
+
+func (dir *metadataDirectory) Entries() files.DirIterator {
+	return &metadataIterator{
+		DirIterator: dir.Directory.Entries(),
+		dir:         dir,
+	}
+}
+
+type metadataIterator struct {
+	files.DirIterator
+
+	dir *metadataDirectory
+}
+
+func (it *metadataIterator) Node() files.Node {
+	n := it.DirIterator.Node()
+	path := gopath.Join(it.dir.path, it.Name())
+	switch n := n.(type) {
+	case files.Directory:
+		return &metadataDirectory{Directory: n, path: path, meta: it.dir.meta}
+	case *files.Symlink:
+		return n
+	case files.File:
+		md, ok := it.dir.meta.get(path)
+		if !ok {
+			return n
+		}
+		return &metadataFile{File: n, md: md}
+	default:
+		return n
+	}
+}
+
+// metadataFile is a files.FileInfo whose Stat() provides the metadata
+// sent for the file.
+type metadataFile struct {
+	files.File
+
+	md *fileMetadata
+}
+
+func (f *metadataFile) AbsPath() string {
+	if fi, ok := f.File.(files.FileInfo); ok {
+		return fi.AbsPath()
+	}
+	return ""
+}
+
+func (f *metadataFile) Stat() os.FileInfo {
+	size, _ := f.File.Size()
+	return &metadataStat{fileMetadata: *f.md, size: size}
+}
+
+// metadataStat implements os.FileInfo.
+type metadataStat struct {
+	fileMetadata
+
+	size int64
+}
+
+func (st *metadataStat) Name() string       { return st.name }
+func (st *metadataStat) Size() int64        { return st.size }
+func (st *metadataStat) Mode() os.FileMode  { return st.mode }
+func (st *metadataStat) ModTime() time.Time { return st.mtime }
+func (st *metadataStat) IsDir() bool        { return false }
+func (st *metadataStat) Sys() interface{}   { return nil }
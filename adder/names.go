@@ -0,0 +1,107 @@
+package adder
+
+import (
+	"errors"
+	"strings"
+
+	files "github.com/ipfs/go-ipfs-files"
+)
+
+// ErrRootNameMultiple is returned when a root name is given for content with
+// several top-level files or directories.
+var ErrRootNameMultiple = errors.New("a root name can only be set when adding a single file or directory")
+
+// renameRoot returns a files.Directory whose single entry is named as
+// given. Iterating it fails when it has more than one entry.
+func renameRoot(dir files.Directory, name string) files.Directory {
+	return &renamedDirectory{Directory: dir, name: name}
+}
+
+type renamedDirectory struct {
+	files.Directory
+
+	name string
+}
+
+func (dir *renamedDirectory) Entries() files.DirIterator {
+	return &renamedIterator{
+		DirIterator: dir.Directory.Entries(),
+		name:        dir.name,
+	}
+}
+
+type renamedIterator struct {
+	files.DirIterator
+
+	name  string
+	count int
+	err   error
+}
+
+func (it *renamedIterator) Next() bool {
+	if it.err != nil || !it.DirIterator.Next() {
+		return false
+	}
+	it.count++
+	if it.count > 1 {
+		it.err = ErrRootNameMultiple
+		return false
+	}
+	return true
+}
+
+func (it *renamedIterator) Name() string {
+	return it.name
+}
+
+func (it *renamedIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.DirIterator.Err()
+}
+
+// skipHidden returns a files.Directory which omits the hidden files and
+// directories (those whose name starts with a dot) found inside the given
+// top-level entries. The top-level entries themselves are kept, as when
+// adding a hidden directory explicitly.
+func skipHidden(dir files.Directory) files.Directory {
+	return &hiddenFilterDirectory{Directory: dir, top: true}
+}
+
+type hiddenFilterDirectory struct {
+	files.Directory
+
+	top bool
+}
+
+func (dir *hiddenFilterDirectory) Entries() files.DirIterator {
+	return &hiddenFilterIterator{
+		DirIterator: dir.Directory.Entries(),
+		top:         dir.top,
+	}
+}
+
+type hiddenFilterIterator struct {
+	files.DirIterator
+
+	top bool
+}
+
+func (it *hiddenFilterIterator) Next() bool {
+	for it.DirIterator.Next() {
+		if it.top || !strings.HasPrefix(it.Name(), ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *hiddenFilterIterator) Node() files.Node {
+	n := it.DirIterator.Node()
+	subdir, ok := n.(files.Directory)
+	if !ok {
+		return n
+	}
+	return &hiddenFilterDirectory{Directory: subdir}
+}
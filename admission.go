@@ -0,0 +1,136 @@
+package ipfscluster
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	"go.opencensus.io/trace"
+)
+
+// freeSpaceMetricName is the name of the metrics sent by the disk informer
+// when it reports the free space of the peers.
+const freeSpaceMetricName = "freespace"
+
+// admissionCheckInterval is how often pins waiting to be admitted check
+// the load of the cluster again.
+var admissionCheckInterval = time.Second
+
+// admitPin makes sure that the cluster can take a new pin: the operations
+// pending in the cluster and the free space which would remain must be
+// within the configured thresholds. When they are not, the pin waits up to
+// AdmissionWait for the load to go down and is then rejected with an
+// ErrCodeOverloaded error, which tells when to retry. Waiting pins are
+// rejected right away when the peer starts draining, as they hold up the
+// shutdown.
+//
+// Only new data pins are subject to admission control. Existing pins,
+// shards and re-allocations are always admitted.
+func (c *Cluster) admitPin(ctx context.Context, pin *api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/admitPin")
+	defer span.End()
+
+	if c.config.AdmissionMaxQueued == 0 && c.config.AdmissionMinFreeSpace == 0 {
+		return nil
+	}
+	if pin.Type != api.DataType {
+		return nil
+	}
+	if _, err := c.PinGet(ctx, pin.Cid); err == nil {
+		return nil
+	}
+
+	err := c.checkAdmission(ctx, pin)
+	if err == nil || c.config.AdmissionWait <= 0 {
+		return err
+	}
+
+	logger.Debugf("%s waits to be admitted: %s", pin.Cid, err)
+	timer := time.NewTimer(c.config.AdmissionWait)
+	defer timer.Stop()
+	ticker := time.NewTicker(admissionCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-timer.C:
+			return err
+		case <-c.drainCh:
+			return errDraining
+		case <-ticker.C:
+			if err = c.checkAdmission(ctx, pin); err == nil {
+				return nil
+			}
+		}
+	}
+}
+
+// checkAdmission returns an ErrCodeOverloaded error when the cluster is
+// over any of the admission thresholds.
+func (c *Cluster) checkAdmission(ctx context.Context, pin *api.Pin) error {
+	// Metrics are refreshed every MonitorPingInterval, so there is
+	// no point in retrying earlier.
+	retryAfter := c.config.MonitorPingInterval
+
+	if max := c.config.AdmissionMaxQueued; max > 0 {
+		pending := c.clusterPendingOperations(ctx)
+		if pending >= max {
+			return api.NewCodedError(
+				api.ErrCodeOverloaded,
+				"%d pin/unpin operations are pending in the cluster (max %d): retry after %s",
+				pending,
+				max,
+				retryAfter,
+			)
+		}
+	}
+
+	if min := c.config.AdmissionMinFreeSpace; min > 0 {
+		metrics := c.monitor.LatestMetrics(ctx, freeSpaceMetricName)
+		if len(metrics) == 0 {
+			// the disk informer does not report free space.
+			return nil
+		}
+		var free uint64
+		for _, m := range metrics {
+			v, err := strconv.ParseUint(m.Value, 10, 64)
+			if err != nil {
+				continue
+			}
+			free += v
+		}
+		replicas := pin.ReplicationFactorMin
+		if replicas <= 0 { // pinned everywhere
+			replicas = len(metrics)
+		}
+		needed := pin.Size * uint64(replicas)
+		if free < needed || free-needed < min {
+			return api.NewCodedError(
+				api.ErrCodeOverloaded,
+				"the cluster would have less than %d bytes of free space left (%d free, %d needed): retry after %s",
+				min,
+				free,
+				needed,
+				retryAfter,
+			)
+		}
+	}
+	return nil
+}
+
+// clusterPendingOperations returns the number of pin and unpin operations
+// pending in the cluster, as reported by the peers in their last ping
+// metrics. The operations of this peer are always up to date.
+func (c *Cluster) clusterPendingOperations(ctx context.Context) int {
+	pending := c.tracker.PendingOperations(ctx)
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		if m.Peer == c.id {
+			continue
+		}
+		pending += m.PendingOperations
+	}
+	return pending
+}
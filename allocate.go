@@ -16,7 +16,7 @@ import (
 // This file gathers allocation logic used when pinning or re-pinning
 // to find which peers should be allocated to a Cid. Allocation is constrained
 // by ReplicationFactorMin and ReplicationFactorMax parameters obtained
-// from the Pin object.
+// from the Pin object, and by the placement policy of the pin, if any.
 
 // The allocation process has several steps:
 //
@@ -39,18 +39,31 @@ import (
 //       ReplicationFactorMax is reached. Error if there are less than
 //       ReplicationFactorMin.
 
-// allocate finds peers to allocate a hash using the informer and the monitor
+// allocate finds peers to allocate a pin using the informer and the monitor
 // it should only be used with valid replicationFactors (if rplMin and rplMax
 // are > 0, then rplMin <= rplMax).
 // It always returns allocations, but if no new allocations are needed,
 // it will return the current ones. Note that allocate() does not take
 // into account if the given CID was previously in a "pin everywhere" mode,
 // and will consider such Pins as currently unallocated ones, providing
-// new allocations as available.
-func (c *Cluster) allocate(ctx context.Context, hash cid.Cid, rplMin, rplMax int, blacklist []peer.ID, prioritylist []peer.ID) ([]peer.ID, error) {
+// new allocations as available. The user allocations of the pin are
+// preferred. The placement constraints stored in the pin (see setupPlacement)
+// restrict the candidates to peers with the allowed tags and in the peer
+// group, and set the metric used to choose among them. When a storage class
+// is given, only peers of that class are considered. When the size of the
+// content is known and SizePrecheckTimeout is set, peers which report less
+// free space than that are not given new allocations.
+func (c *Cluster) allocate(ctx context.Context, pin *api.Pin, blacklist []peer.ID) ([]peer.ID, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/allocate")
 	defer span.End()
 
+	hash := pin.Cid
+	size := pin.Size
+	rplMin := pin.ReplicationFactorMin
+	rplMax := pin.ReplicationFactorMax
+	prioritylist := pin.UserAllocations
+	storageClass := pin.StorageClass
+
 	if (rplMin + rplMax) == 0 {
 		return nil, fmt.Errorf("bad replication factors: %d/%d", rplMin, rplMax)
 	}
@@ -65,7 +78,26 @@ func (c *Cluster) allocate(ctx context.Context, hash cid.Cid, rplMin, rplMax int
 	if err == nil {
 		currentAllocs = currentPin.Allocations
 	}
-	metrics := c.monitor.LatestMetrics(ctx, c.informers[0].Name())
+
+	metricName, allowedTags, groupPeers, err := c.placementConstraints(pin)
+	if err != nil {
+		return nil, err
+	}
+	metrics := c.monitor.LatestMetrics(ctx, metricName)
+
+	exclusions := c.allocationExclusions()
+	var peerTags map[peer.ID]map[string]string
+	if len(allowedTags) > 0 || len(exclusions.Tags) > 0 {
+		peerTags = c.peerTags(ctx)
+	}
+	var peerClasses map[peer.ID]string
+	if storageClass != "" {
+		peerClasses = c.peerStorageClasses(ctx)
+	}
+	var peerFree map[peer.ID]uint64
+	if size > 0 && c.config.SizePrecheckTimeout > 0 {
+		peerFree = c.peersFreeSpace(ctx)
+	}
 
 	currentMetrics := make(map[peer.ID]*api.Metric)
 	candidatesMetrics := make(map[peer.ID]*api.Metric)
@@ -79,6 +111,30 @@ func (c *Cluster) allocate(ctx context.Context, hash cid.Cid, rplMin, rplMax int
 		case containsPeer(blacklist, m.Peer):
 			// discard blacklisted peers
 			continue
+		case len(allowedTags) > 0 && !tagsAllowed(peerTags[m.Peer], allowedTags):
+			// discard peers not allowed by the placement policy
+			continue
+		case storageClass != "" && peerClasses[m.Peer] != storageClass:
+			// discard peers of other storage classes
+			continue
+		case groupPeers != nil && !containsPeer(groupPeers, m.Peer):
+			// discard peers outside the requested group
+			continue
+		case m.Maintenance && !containsPeer(currentAllocs, m.Peer):
+			// peers in maintenance keep their pins but get no
+			// new ones
+			continue
+		case m.Standby && !containsPeer(currentAllocs, m.Peer):
+			// peers in standby get no allocations until
+			// activated
+			continue
+		case exclusions.Excludes(m.Peer, peerTags[m.Peer]) && !containsPeer(currentAllocs, m.Peer):
+			// excluded peers keep their pins but get no new
+			// ones
+			continue
+		case !fitsFreeSpace(peerFree, m.Peer, size) && !containsPeer(currentAllocs, m.Peer):
+			// discard peers without space for the content
+			continue
 		case containsPeer(currentAllocs, m.Peer):
 			currentMetrics[m.Peer] = m
 		case containsPeer(prioritylist, m.Peer):
@@ -106,6 +162,104 @@ func (c *Cluster) allocate(ctx context.Context, hash cid.Cid, rplMin, rplMax int
 	return newAllocs, nil
 }
 
+// hasInformer returns whether an informer with the given name is
+// configured.
+func (c *Cluster) hasInformer(name string) bool {
+	for _, inf := range c.informers {
+		if inf.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// peerTags returns the tags of the peers, as received with their last ping
+// metrics.
+func (c *Cluster) peerTags(ctx context.Context) map[peer.ID]map[string]string {
+	tags := make(map[peer.ID]map[string]string)
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		tags[m.Peer] = m.Tags
+	}
+	tags[c.id] = c.config.Tags
+	return tags
+}
+
+// peerStorageClasses returns the storage classes of the peers, as received
+// with their last ping metrics.
+func (c *Cluster) peerStorageClasses(ctx context.Context) map[peer.ID]string {
+	classes := make(map[peer.ID]string)
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		classes[m.Peer] = m.StorageClass
+	}
+	classes[c.id] = c.config.StorageClass
+	return classes
+}
+
+// fitsFreeSpace returns whether content of the given size fits in the free
+// space of the given peer. It does when the free space is unknown.
+func fitsFreeSpace(free map[peer.ID]uint64, p peer.ID, size uint64) bool {
+	f, ok := free[p]
+	return !ok || f >= size
+}
+
+// placementConstraints returns the informer metric used to sort the
+// candidates, the allowed tags and the peers of the group (nil meaning any
+// peer) for a pin. The constraints stored in the pin are used. Pins stored
+// before they were resolved fall back to the local configuration.
+func (c *Cluster) placementConstraints(pin *api.Pin) (string, map[string][]string, []peer.ID, error) {
+	allocateBy := pin.AllocateBy
+	allowedTags := pin.AllowedTags
+	if pin.Policy != "" && allocateBy == "" && len(allowedTags) == 0 {
+		if p, ok := c.config.PlacementPolicies[pin.Policy]; ok {
+			allocateBy = p.AllocateBy
+			allowedTags = p.AllowedTags
+		}
+	}
+
+	metricName := c.informers[0].Name()
+	if allocateBy != "" {
+		if !c.hasInformer(allocateBy) {
+			return "", nil, nil, fmt.Errorf("placement policy %s: no %s informer configured", pin.Policy, allocateBy)
+		}
+		metricName = allocateBy
+	}
+
+	if pin.PeerGroup == "" {
+		return metricName, allowedTags, nil, nil
+	}
+	groupPeers := pin.GroupPeers
+	if len(groupPeers) == 0 {
+		var ok bool
+		groupPeers, ok = c.config.PeerGroups[pin.PeerGroup]
+		if !ok {
+			return "", nil, nil, fmt.Errorf("unknown peer group: %s", pin.PeerGroup)
+		}
+	}
+	return metricName, allowedTags, groupPeers, nil
+}
+
+// tagsAllowed returns whether, for every allowed tag, the given tags have
+// one of the allowed values.
+func tagsAllowed(tags map[string]string, allowed map[string][]string) bool {
+	for k, values := range allowed {
+		v, ok := tags[k]
+		if !ok {
+			return false
+		}
+		found := false
+		for _, av := range values {
+			if v == av {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // allocationError logs an allocation error
 func allocationError(hash cid.Cid, needed, wanted int, candidatesValid []peer.ID) error {
 	logger.Errorf("Not enough candidates to allocate %s:", hash)
@@ -153,10 +307,14 @@ func (c *Cluster) obtainAllocations(
 	// Reminder: rplMin <= rplMax AND >0
 
 	if wanted < 0 { // allocations above maximum threshold: drop some
-		// This could be done more intelligently by dropping them
-		// according to the allocator order (i.e. free-ing peers
-		// with most used space first).
-		return validAllocations[0 : len(validAllocations)+wanted], nil
+		keep := len(validAllocations) + wanted
+		// Keep the allocations which the allocator prefers, so that
+		// the ones dropped do not depend on map ordering.
+		preferred, err := c.allocator.Allocate(ctx, hash, nil, currentValidMetrics, nil)
+		if err == nil && len(preferred) >= keep {
+			return preferred[0:keep], nil
+		}
+		return validAllocations[0:keep], nil
 	}
 
 	if needed <= 0 { // allocations are above minimal threshold
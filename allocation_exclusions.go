@@ -0,0 +1,133 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	ds "github.com/ipfs/go-datastore"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// allocationExclusionsDatastore is the name of the local datastore where
+// the allocation exclusions set at runtime are persisted.
+const allocationExclusionsDatastore = "allocation_exclusions"
+
+var exclusionsKey = ds.NewKey("exclusions")
+
+// SetAllocationExclusions sends the given exclusions to all the peers in the
+// cluster (see SetAllocationExclusionsLocal). They replace the exclusions
+// previously set at runtime and apply in addition to the ones in the
+// configuration of each peer. It returns an error listing the peers where
+// it failed.
+//
+// Peers which join the cluster afterwards do not receive them: exclusions
+// which must last should be added to the configuration too.
+func (c *Cluster) SetAllocationExclusions(ctx context.Context, ex *api.AllocationExclusions) error {
+	_, span := trace.StartSpan(ctx, "cluster/SetAllocationExclusions")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if ex == nil {
+		ex = &api.AllocationExclusions{}
+	}
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := c.multiCall(
+		ctx,
+		peers,
+		"Cluster",
+		"SetAllocationExclusionsLocal",
+		ex,
+		rpcutil.RPCDiscardReplies(len(peers)),
+	)
+
+	for i, err := range errs {
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %s", peer.IDB58Encode(peers[i]), err)
+		}
+	}
+	return rpcutil.CheckErrs(errs)
+}
+
+// SetAllocationExclusionsLocal replaces the allocation exclusions set at
+// runtime in this peer. They are persisted and survive restarts.
+func (c *Cluster) SetAllocationExclusionsLocal(ctx context.Context, ex *api.AllocationExclusions) error {
+	_, span := trace.StartSpan(ctx, "cluster/SetAllocationExclusionsLocal")
+	defer span.End()
+
+	c.exclusionsMux.Lock()
+	defer c.exclusionsMux.Unlock()
+
+	store := c.localDatastore(allocationExclusionsDatastore)
+	if ex.IsEmpty() {
+		if err := store.Delete(exclusionsKey); err != nil && err != ds.ErrNotFound {
+			return err
+		}
+		c.exclusions = nil
+		logger.Info("allocation exclusions cleared")
+		return nil
+	}
+
+	b, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+	if err := store.Put(exclusionsKey, b); err != nil {
+		return err
+	}
+	c.exclusions = ex
+	logger.Infof("allocation exclusions set: %d peers and %d tags excluded", len(ex.Peers), len(ex.Tags))
+	return nil
+}
+
+// AllocationExclusions returns the allocation exclusions set at runtime in
+// this peer. The ones in the configuration are not included.
+func (c *Cluster) AllocationExclusions(ctx context.Context) *api.AllocationExclusions {
+	_, span := trace.StartSpan(ctx, "cluster/AllocationExclusions")
+	defer span.End()
+
+	c.exclusionsMux.RLock()
+	defer c.exclusionsMux.RUnlock()
+	if c.exclusions == nil {
+		return &api.AllocationExclusions{}
+	}
+	exCopy := *c.exclusions
+	return &exCopy
+}
+
+// allocationExclusions returns the exclusions which apply when allocating:
+// the configured ones and the ones set at runtime.
+func (c *Cluster) allocationExclusions() *api.AllocationExclusions {
+	c.exclusionsMux.RLock()
+	defer c.exclusionsMux.RUnlock()
+	return c.config.AllocationExclusions.Merge(c.exclusions)
+}
+
+// loadAllocationExclusions loads the exclusions set at runtime before the
+// peer was restarted.
+func (c *Cluster) loadAllocationExclusions() error {
+	b, err := c.localDatastore(allocationExclusionsDatastore).Get(exclusionsKey)
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ex := &api.AllocationExclusions{}
+	if err := json.Unmarshal(b, ex); err != nil {
+		return err
+	}
+	c.exclusionsMux.Lock()
+	c.exclusions = ex
+	c.exclusionsMux.Unlock()
+	return nil
+}
@@ -19,13 +19,22 @@ import (
 var logger = logging.Logger("ascendalloc")
 
 // AscendAllocator extends the SimpleAllocator
-type AscendAllocator struct{}
+type AscendAllocator struct {
+	tieBreaker util.TieBreaker
+}
 
 // NewAllocator returns an initialized AscendAllocator
 func NewAllocator() AscendAllocator {
 	return AscendAllocator{}
 }
 
+// NewAllocatorWithTieBreaker returns an AscendAllocator which orders peers
+// with equal metric values with the given TieBreaker (see
+// util.NewTieBreaker). A nil TieBreaker is the same as NewAllocator().
+func NewAllocatorWithTieBreaker(tb util.TieBreaker) AscendAllocator {
+	return AscendAllocator{tieBreaker: tb}
+}
+
 // SetClient does nothing in this allocator
 func (alloc AscendAllocator) SetClient(c *rpc.Client) {}
 
@@ -35,13 +44,20 @@ func (alloc AscendAllocator) Shutdown(_ context.Context) error { return nil }
 // Allocate returns where to allocate a pin request based on metrics which
 // carry a numeric value such as "used disk". We do not pay attention to
 // the metrics of the currently allocated peers and we just sort the
-// candidates based on their metric values (smallest to largest).
+// candidates based on their metric values (smallest to largest). Allocators
+// created with NewAllocatorWithTieBreaker order peers with equal values
+// with their TieBreaker.
 func (alloc AscendAllocator) Allocate(
 	ctx context.Context,
 	c cid.Cid,
 	current, candidates, priority map[peer.ID]*api.Metric,
 ) ([]peer.ID, error) {
 	// sort our metrics
+	if alloc.tieBreaker != nil {
+		first := util.SortNumericTies(c, priority, false, alloc.tieBreaker)
+		last := util.SortNumericTies(c, candidates, false, alloc.tieBreaker)
+		return append(first, last...), nil
+	}
 	first := util.SortNumeric(priority, false)
 	last := util.SortNumeric(candidates, false)
 	return append(first, last...), nil
@@ -19,13 +19,22 @@ import (
 var logger = logging.Logger("descendalloc")
 
 // DescendAllocator extends the SimpleAllocator
-type DescendAllocator struct{}
+type DescendAllocator struct {
+	tieBreaker util.TieBreaker
+}
 
 // NewAllocator returns an initialized DescendAllocator
 func NewAllocator() DescendAllocator {
 	return DescendAllocator{}
 }
 
+// NewAllocatorWithTieBreaker returns a DescendAllocator which orders peers
+// with equal metric values with the given TieBreaker (see
+// util.NewTieBreaker). A nil TieBreaker is the same as NewAllocator().
+func NewAllocatorWithTieBreaker(tb util.TieBreaker) DescendAllocator {
+	return DescendAllocator{tieBreaker: tb}
+}
+
 // SetClient does nothing in this allocator
 func (alloc DescendAllocator) SetClient(c *rpc.Client) {}
 
@@ -35,9 +44,16 @@ func (alloc DescendAllocator) Shutdown(_ context.Context) error { return nil }
 // Allocate returns where to allocate a pin request based on metrics which
 // carry a numeric value such as "used disk". We do not pay attention to
 // the metrics of the currently allocated peers and we just sort the
-// candidates based on their metric values (largest to smallest).
+// candidates based on their metric values (largest to smallest). Allocators
+// created with NewAllocatorWithTieBreaker order peers with equal values
+// with their TieBreaker.
 func (alloc DescendAllocator) Allocate(ctx context.Context, c cid.Cid, current, candidates, priority map[peer.ID]*api.Metric) ([]peer.ID, error) {
 	// sort our metrics
+	if alloc.tieBreaker != nil {
+		first := util.SortNumericTies(c, priority, true, alloc.tieBreaker)
+		last := util.SortNumericTies(c, candidates, true, alloc.tieBreaker)
+		return append(first, last...), nil
+	}
 	first := util.SortNumeric(priority, true)
 	last := util.SortNumeric(candidates, true)
 	return append(first, last...), nil
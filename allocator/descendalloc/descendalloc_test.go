@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/allocator/util"
 	"github.com/ipfs/ipfs-cluster/api"
 
 	cid "github.com/ipfs/go-cid"
@@ -115,3 +116,38 @@ func Test(t *testing.T) {
 		}
 	}
 }
+
+func TestAllocateTieBreaker(t *testing.T) {
+	ctx := context.Background()
+	candidates := make(map[peer.ID]*api.Metric)
+	for _, p := range []peer.ID{peer0, peer1, peer2} {
+		candidates[p] = &api.Metric{
+			Name:   "some-metric",
+			Value:  "5",
+			Expire: inAMinute,
+			Valid:  true,
+		}
+	}
+	candidates[peer3] = &api.Metric{
+		Name:   "some-metric",
+		Value:  "7",
+		Expire: inAMinute,
+		Valid:  true,
+	}
+
+	alloc := NewAllocatorWithTieBreaker(util.NewTieBreaker(util.TieBreakRoundRobin, nil))
+	first := make(map[peer.ID]bool)
+	for i := 0; i < 3; i++ {
+		res, err := alloc.Allocate(ctx, testCid, nil, candidates, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 4 || res[0] != peer3 {
+			t.Fatalf("unexpected allocations: %s", res)
+		}
+		first[res[1]] = true
+	}
+	if len(first) != 3 {
+		t.Error("every tied peer should have come first once")
+	}
+}
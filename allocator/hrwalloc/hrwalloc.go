@@ -0,0 +1,80 @@
+// Package hrwalloc implements an ipfscluster.PinAllocator which returns
+// allocations derived deterministically from the CID and the peer IDs,
+// using rendezvous (highest random weight) hashing. Every candidate peer
+// gets a score from hashing the CID together with its ID, and peers are
+// ordered by score. Metric values are ignored.
+//
+// Thus, identical clusters allocate a CID to the same peers, and when a
+// peer joins or leaves, only the CIDs for which that peer scores highest
+// change their allocations.
+package hrwalloc
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// HRWAllocator orders peers by their rendezvous hashing score for the
+// allocated CID.
+type HRWAllocator struct{}
+
+// NewAllocator returns an initialized HRWAllocator
+func NewAllocator() HRWAllocator {
+	return HRWAllocator{}
+}
+
+// SetClient does nothing in this allocator
+func (alloc HRWAllocator) SetClient(c *rpc.Client) {}
+
+// Shutdown does nothing in this allocator
+func (alloc HRWAllocator) Shutdown(_ context.Context) error { return nil }
+
+// Allocate returns the priority peers followed by the candidate peers,
+// each group sorted by their score for the given CID, from highest to
+// lowest. Peers with metrics which should be discarded are left out.
+func (alloc HRWAllocator) Allocate(ctx context.Context, c cid.Cid, current, candidates, priority map[peer.ID]*api.Metric) ([]peer.ID, error) {
+	first := SortByScore(c, priority)
+	last := SortByScore(c, candidates)
+	return append(first, last...), nil
+}
+
+// Score returns the rendezvous hashing score of a peer for a CID.
+func Score(c cid.Cid, p peer.ID) uint64 {
+	h := sha256.New()
+	h.Write(c.Bytes())
+	h.Write([]byte(p))
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+// SortByScore returns the peers in the given metrics map which should not
+// be discarded, from highest to lowest score for the given CID. Ties are
+// broken by peer ID.
+func SortByScore(c cid.Cid, metrics map[peer.ID]*api.Metric) []peer.ID {
+	scores := make(map[peer.ID]uint64, len(metrics))
+	peers := make([]peer.ID, 0, len(metrics))
+	for p, m := range metrics {
+		if m.Discard() {
+			continue
+		}
+		peers = append(peers, p)
+		scores[p] = Score(c, p)
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		si, sj := scores[peers[i]], scores[peers[j]]
+		if si != sj {
+			return si > sj
+		}
+		return bytes.Compare([]byte(peers[i]), []byte(peers[j])) < 0
+	})
+	return peers
+}
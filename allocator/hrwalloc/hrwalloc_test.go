@@ -0,0 +1,98 @@
+package hrwalloc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+var (
+	peer0      = peer.ID("QmUQ6Nsejt1SuZAu8yL8WgqQZHHAYreLVYYa4VPsLUCed7")
+	peer1      = peer.ID("QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6")
+	peer2      = peer.ID("QmPrSBATWGAN56fiiEWEhKX3L1F3mTghEQR7vQwaeo7zHi")
+	peer3      = peer.ID("QmPGDFvBkgWhvzEK9qaTWrWurSwqXNmhnK3hgELPdZZNPa")
+	testCid, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq")
+)
+
+var inAMinute = time.Now().Add(time.Minute).UnixNano()
+
+func metrics(peers ...peer.ID) map[peer.ID]*api.Metric {
+	m := make(map[peer.ID]*api.Metric)
+	for _, p := range peers {
+		m[p] = &api.Metric{
+			Name:   "some-metric",
+			Value:  "1",
+			Expire: inAMinute,
+			Valid:  true,
+		}
+	}
+	return m
+}
+
+func TestAllocateDeterministic(t *testing.T) {
+	ctx := context.Background()
+	alloc := &HRWAllocator{}
+
+	expected, err := alloc.Allocate(ctx, testCid, nil, metrics(peer0, peer1, peer2, peer3), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != 4 {
+		t.Fatal("expected all peers to be returned")
+	}
+	for i := 1; i < len(expected); i++ {
+		if Score(testCid, expected[i-1]) < Score(testCid, expected[i]) {
+			t.Fatal("peers are not sorted by score")
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		res, _ := alloc.Allocate(ctx, testCid, nil, metrics(peer3, peer2, peer1, peer0), nil)
+		for j := range res {
+			if res[j] != expected[j] {
+				t.Fatal("allocations are not deterministic")
+			}
+		}
+	}
+}
+
+func TestAllocatePeerRemoved(t *testing.T) {
+	ctx := context.Background()
+	alloc := &HRWAllocator{}
+
+	all, _ := alloc.Allocate(ctx, testCid, nil, metrics(peer0, peer1, peer2, peer3), nil)
+	removed := all[0]
+	m := metrics(peer0, peer1, peer2, peer3)
+	delete(m, removed)
+	res, _ := alloc.Allocate(ctx, testCid, nil, m, nil)
+	for i := range res {
+		if res[i] != all[i+1] {
+			t.Fatal("removing a peer should not change the order of the rest")
+		}
+	}
+}
+
+func TestAllocatePriorityAndDiscard(t *testing.T) {
+	ctx := context.Background()
+	alloc := &HRWAllocator{}
+
+	candidates := metrics(peer0, peer1, peer2)
+	candidates[peer2].Valid = false
+	res, _ := alloc.Allocate(ctx, testCid, nil, candidates, metrics(peer3))
+	if len(res) != 3 {
+		t.Fatal("expected 3 peers, got", len(res))
+	}
+	if res[0] != peer3 {
+		t.Error("priority peers should come first")
+	}
+	for _, p := range res {
+		if p == peer2 {
+			t.Error("invalid metrics should be discarded")
+		}
+	}
+}
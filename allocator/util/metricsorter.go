@@ -1,15 +1,17 @@
 // Package util is a utility package used by the allocator
 // implementations. This package provides the SortNumeric function, which may be
 // used by an allocator to sort peers by their metric values (ascending or
-// descending).
+// descending), and the tie breakers which order peers with equal values.
 package util
 
 import (
+	"bytes"
 	"sort"
 	"strconv"
 
 	"github.com/ipfs/ipfs-cluster/api"
 
+	cid "github.com/ipfs/go-cid"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 )
 
@@ -31,6 +33,39 @@ func SortNumeric(candidates map[peer.ID]*api.Metric, reverse bool) []peer.ID {
 		vMap[k] = val
 	}
 
+	return sortMetrics(vMap, peers, reverse)
+}
+
+// SortNumericTies is like SortNumeric, but peers with equal metric values
+// are ordered by the given TieBreaker when allocating the given CID, or by
+// peer ID when it is nil, instead of being left in no particular order.
+func SortNumericTies(c cid.Cid, candidates map[peer.ID]*api.Metric, reverse bool, tb TieBreaker) []peer.ID {
+	peers := SortNumeric(candidates, reverse)
+	vMap := make(map[peer.ID]uint64, len(peers))
+	for _, p := range peers {
+		vMap[p], _ = strconv.ParseUint(candidates[p].Value, 10, 64)
+	}
+
+	for start := 0; start < len(peers); {
+		end := start + 1
+		for end < len(peers) && vMap[peers[end]] == vMap[peers[start]] {
+			end++
+		}
+		tied := peers[start:end]
+		if len(tied) > 1 {
+			sort.Slice(tied, func(i, j int) bool {
+				return bytes.Compare([]byte(tied[i]), []byte(tied[j])) < 0
+			})
+			if tb != nil {
+				tb.BreakTies(c, tied)
+			}
+		}
+		start = end
+	}
+	return peers
+}
+
+func sortMetrics(vMap map[peer.ID]uint64, peers []peer.ID, reverse bool) []peer.ID {
 	sorter := &metricSorter{
 		m:       vMap,
 		peers:   peers,
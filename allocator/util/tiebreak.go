@@ -0,0 +1,137 @@
+package util
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"strconv"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	logging "github.com/ipfs/go-log"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+var logger = logging.Logger("allocator")
+
+// Tie-breaking strategies which can be given to NewTieBreaker.
+const (
+	// TieBreakNone leaves peers with equal metric values in no
+	// particular order.
+	TieBreakNone = "none"
+	// TieBreakPeerHash orders peers with equal metric values by the
+	// hash of the allocated CID and their peer ID, so that ties are
+	// won by different peers for different CIDs.
+	TieBreakPeerHash = "peer_hash"
+	// TieBreakRoundRobin orders peers with equal metric values by
+	// their peer ID, rotated by a counter which is increased on every
+	// tie, so that ties are won by each peer in turn.
+	TieBreakRoundRobin = "round_robin"
+)
+
+// TieBreaker orders peers with equal metric values.
+type TieBreaker interface {
+	// BreakTies reorders peers which have the same metric value when
+	// allocating the given CID. They are given sorted by peer ID.
+	BreakTies(c cid.Cid, peers []peer.ID)
+}
+
+// NewTieBreaker returns the TieBreaker for the given strategy. The
+// round-robin counter is persisted in the given datastore, which may be
+// nil. It returns nil for TieBreakNone, an empty or an unknown strategy.
+func NewTieBreaker(strategy string, store ds.Datastore) TieBreaker {
+	switch strategy {
+	case TieBreakPeerHash:
+		return PeerHashTieBreaker{}
+	case TieBreakRoundRobin:
+		return NewRoundRobinTieBreaker(store)
+	default:
+		return nil
+	}
+}
+
+// PeerHashTieBreaker implements the TieBreakPeerHash strategy.
+type PeerHashTieBreaker struct{}
+
+// BreakTies orders the peers by the hash of the CID and their ID, from
+// lowest to highest.
+func (PeerHashTieBreaker) BreakTies(c cid.Cid, peers []peer.ID) {
+	hashes := make(map[peer.ID]uint64, len(peers))
+	for _, p := range peers {
+		h := sha256.New()
+		h.Write(c.Bytes())
+		h.Write([]byte(p))
+		hashes[p] = binary.BigEndian.Uint64(h.Sum(nil))
+	}
+	sortPeers(peers, func(a, b peer.ID) bool {
+		return hashes[a] < hashes[b]
+	})
+}
+
+var roundRobinKey = ds.NewKey("round_robin")
+
+// RoundRobinTieBreaker implements the TieBreakRoundRobin strategy.
+type RoundRobinTieBreaker struct {
+	mu      sync.Mutex
+	store   ds.Datastore
+	counter uint64
+}
+
+// NewRoundRobinTieBreaker returns a RoundRobinTieBreaker which persists
+// its counter in the given datastore, when not nil, and resumes from the
+// counter persisted there.
+func NewRoundRobinTieBreaker(store ds.Datastore) *RoundRobinTieBreaker {
+	rr := &RoundRobinTieBreaker{store: store}
+	if store == nil {
+		return rr
+	}
+	b, err := store.Get(roundRobinKey)
+	if err != nil {
+		return rr
+	}
+	n, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		logger.Warningf("ignoring the persisted round-robin counter: %s", err)
+		return rr
+	}
+	rr.counter = n
+	return rr
+}
+
+// BreakTies rotates the peers by the counter and increases it.
+func (rr *RoundRobinTieBreaker) BreakTies(c cid.Cid, peers []peer.ID) {
+	if len(peers) < 2 {
+		return
+	}
+	rr.mu.Lock()
+	n := rr.counter
+	rr.counter++
+	if rr.store != nil {
+		err := rr.store.Put(roundRobinKey, []byte(strconv.FormatUint(rr.counter, 10)))
+		if err != nil {
+			logger.Warningf("error persisting the round-robin counter: %s", err)
+		}
+	}
+	rr.mu.Unlock()
+
+	k := int(n % uint64(len(peers)))
+	rotated := append(append([]peer.ID{}, peers[k:]...), peers[:k]...)
+	copy(peers, rotated)
+}
+
+// sortPeers sorts the peers with the given function, breaking ties by
+// peer ID.
+func sortPeers(peers []peer.ID, less func(a, b peer.ID) bool) {
+	sort.Slice(peers, func(i, j int) bool {
+		a, b := peers[i], peers[j]
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return bytes.Compare([]byte(a), []byte(b)) < 0
+	})
+}
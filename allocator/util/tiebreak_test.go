@@ -0,0 +1,95 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+var (
+	peer0   = peer.ID("QmUQ6Nsejt1SuZAu8yL8WgqQZHHAYreLVYYa4VPsLUCed7")
+	peer1   = peer.ID("QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6")
+	peer2   = peer.ID("QmPrSBATWGAN56fiiEWEhKX3L1F3mTghEQR7vQwaeo7zHi")
+	cid1, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmmq")
+	cid2, _ = cid.Decode("QmP63DkAFEnDYNjDYBpyNDfttu1fvUw99x1brscPzpqmma")
+)
+
+func tiedMetrics() map[peer.ID]*api.Metric {
+	metrics := make(map[peer.ID]*api.Metric)
+	for _, p := range []peer.ID{peer0, peer1, peer2} {
+		m := &api.Metric{
+			Name:  "some-metric",
+			Value: "5",
+			Valid: true,
+		}
+		m.SetTTL(time.Minute)
+		metrics[p] = m
+	}
+	return metrics
+}
+
+func TestSortNumericTies(t *testing.T) {
+	metrics := tiedMetrics()
+	res := SortNumericTies(cid1, metrics, true, nil)
+	// sorted by peer ID
+	if len(res) != 3 || res[0] != peer2 || res[1] != peer0 || res[2] != peer1 {
+		t.Errorf("unexpected order: %s", res)
+	}
+
+	metrics[peer1].Value = "6"
+	res = SortNumericTies(cid1, metrics, true, nil)
+	if res[0] != peer1 || res[1] != peer2 || res[2] != peer0 {
+		t.Errorf("unexpected order: %s", res)
+	}
+}
+
+func TestPeerHashTieBreaker(t *testing.T) {
+	metrics := tiedMetrics()
+	tb := NewTieBreaker(TieBreakPeerHash, nil)
+	res1 := SortNumericTies(cid1, metrics, true, tb)
+	res2 := SortNumericTies(cid1, metrics, true, tb)
+	for i := range res1 {
+		if res1[i] != res2[i] {
+			t.Fatal("the order should be the same for the same cid")
+		}
+	}
+
+	res3 := SortNumericTies(cid2, metrics, true, tb)
+	same := true
+	for i := range res1 {
+		if res1[i] != res3[i] {
+			same = false
+		}
+	}
+	if same {
+		t.Error("the order should depend on the cid")
+	}
+}
+
+func TestRoundRobinTieBreaker(t *testing.T) {
+	store := dssync.MutexWrap(ds.NewMapDatastore())
+	metrics := tiedMetrics()
+	tb := NewTieBreaker(TieBreakRoundRobin, store)
+	res1 := SortNumericTies(cid1, metrics, true, tb)
+	res2 := SortNumericTies(cid1, metrics, true, tb)
+	if res1[0] == res2[0] {
+		t.Error("ties should be won in turns")
+	}
+
+	// The counter is resumed.
+	tb = NewTieBreaker(TieBreakRoundRobin, store)
+	res3 := SortNumericTies(cid1, metrics, true, tb)
+	if res3[0] == res1[0] || res3[0] == res2[0] {
+		t.Errorf("the counter should have been persisted: %s", res3)
+	}
+
+	if NewTieBreaker(TieBreakNone, store) != nil {
+		t.Error("no tie breaker expected")
+	}
+}
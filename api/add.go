@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	cid "github.com/ipfs/go-cid"
 )
@@ -33,14 +36,34 @@ type AddParams struct {
 	RawLeaves      bool
 	Hidden         bool
 	Wrap           bool
+	RootName       string
 	Shard          bool
 	Progress       bool
 	CidVersion     int
 	HashFun        string
 	StreamChannels bool
 	NoCopy         bool
+	// PreserveMode and PreserveMtime store the mode and the
+	// modification time of the files in their UnixFS nodes (UnixFS
+	// 1.5). Clients send them with the AddModeHeader and
+	// AddMtimeHeader headers of each file.
+	PreserveMode  bool
+	PreserveMtime bool
+	// Mode and Mtime, when not zero, are the permissions and the
+	// modification time stored for every file instead.
+	Mode  os.FileMode
+	Mtime time.Time
 }
 
+// Headers of the parts of multipart add requests which carry the mode (in
+// octal) and the modification time (in seconds and nanoseconds since the
+// Unix epoch) of the files, for PreserveMode and PreserveMtime.
+const (
+	AddModeHeader       = "Mode"
+	AddMtimeHeader      = "Mtime"
+	AddMtimeNsecsHeader = "Mtime-Nsecs"
+)
+
 // DefaultAddParams returns a AddParams object with standard defaults
 func DefaultAddParams() *AddParams {
 	return &AddParams{
@@ -143,6 +166,10 @@ func AddParamsFromQuery(query url.Values) (*AddParams, error) {
 	if err != nil {
 		return nil, err
 	}
+	params.RootName = query.Get("root-name")
+	if strings.Contains(params.RootName, "/") {
+		return nil, errors.New("root-name cannot contain slashes")
+	}
 	err = parseBoolParam(query, "shard", &params.Shard)
 	if err != nil {
 		return nil, err
@@ -168,6 +195,39 @@ func AddParamsFromQuery(query url.Values) (*AddParams, error) {
 		return nil, err
 	}
 
+	err = parseBoolParam(query, "preserve-mode", &params.PreserveMode)
+	if err != nil {
+		return nil, err
+	}
+
+	err = parseBoolParam(query, "preserve-mtime", &params.PreserveMtime)
+	if err != nil {
+		return nil, err
+	}
+
+	if v := query.Get("mode"); v != "" {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || mode > 0777 {
+			return nil, errors.New("parameter mode invalid")
+		}
+		params.Mode = os.FileMode(mode)
+	}
+
+	if v := query.Get("mtime"); v != "" {
+		secs, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("parameter mtime invalid")
+		}
+		params.Mtime = time.Unix(secs, 0)
+	}
+
+	if params.PreserveMode && params.Mode != 0 {
+		return nil, errors.New("preserve-mode and mode cannot be used together")
+	}
+	if params.PreserveMtime && !params.Mtime.IsZero() {
+		return nil, errors.New("preserve-mtime and mtime cannot be used together")
+	}
+
 	return params, nil
 }
 
@@ -189,11 +249,22 @@ func (p *AddParams) ToQueryString() (string, error) {
 	query.Set("raw-leaves", fmt.Sprintf("%t", p.RawLeaves))
 	query.Set("hidden", fmt.Sprintf("%t", p.Hidden))
 	query.Set("wrap-with-directory", fmt.Sprintf("%t", p.Wrap))
+	if p.RootName != "" {
+		query.Set("root-name", p.RootName)
+	}
 	query.Set("progress", fmt.Sprintf("%t", p.Progress))
 	query.Set("cid-version", fmt.Sprintf("%d", p.CidVersion))
 	query.Set("hash", p.HashFun)
 	query.Set("stream-channels", fmt.Sprintf("%t", p.StreamChannels))
 	query.Set("nocopy", fmt.Sprintf("%t", p.NoCopy))
+	query.Set("preserve-mode", fmt.Sprintf("%t", p.PreserveMode))
+	query.Set("preserve-mtime", fmt.Sprintf("%t", p.PreserveMtime))
+	if p.Mode != 0 {
+		query.Set("mode", strconv.FormatUint(uint64(p.Mode.Perm()), 8))
+	}
+	if !p.Mtime.IsZero() {
+		query.Set("mtime", strconv.FormatInt(p.Mtime.Unix(), 10))
+	}
 	return query.Encode(), nil
 }
 
@@ -208,8 +279,13 @@ func (p *AddParams) Equals(p2 *AddParams) bool {
 		p.RawLeaves == p2.RawLeaves &&
 		p.Hidden == p2.Hidden &&
 		p.Wrap == p2.Wrap &&
+		p.RootName == p2.RootName &&
 		p.CidVersion == p2.CidVersion &&
 		p.HashFun == p2.HashFun &&
 		p.StreamChannels == p2.StreamChannels &&
-		p.NoCopy == p2.NoCopy
+		p.NoCopy == p2.NoCopy &&
+		p.PreserveMode == p2.PreserveMode &&
+		p.PreserveMtime == p2.PreserveMtime &&
+		p.Mode == p2.Mode &&
+		p.Mtime.Equal(p2.Mtime)
 }
@@ -6,7 +6,7 @@ import (
 )
 
 func TestAddParams_FromQuery(t *testing.T) {
-	qStr := "layout=balanced&chunker=size-262144&name=test&raw-leaves=true&hidden=true&shard=true&replication-min=2&replication-max=4&shard-size=1"
+	qStr := "layout=balanced&chunker=size-262144&name=test&raw-leaves=true&hidden=true&shard=true&replication-min=2&replication-max=4&shard-size=1&wrap-with-directory=true&root-name=data"
 
 	q, err := url.ParseQuery(qStr)
 	if err != nil {
@@ -20,12 +20,54 @@ func TestAddParams_FromQuery(t *testing.T) {
 	if p.Layout != "balanced" ||
 		p.Chunker != "size-262144" ||
 		p.Name != "test" ||
-		!p.RawLeaves || !p.Hidden || !p.Shard ||
+		!p.RawLeaves || !p.Hidden || !p.Shard || !p.Wrap ||
+		p.RootName != "data" ||
 		p.ReplicationFactorMin != 2 ||
 		p.ReplicationFactorMax != 4 ||
 		p.ShardSize != 1 {
 		t.Fatal("did not parse the query correctly")
 	}
+
+	q.Set("root-name", "a/b")
+	_, err = AddParamsFromQuery(q)
+	if err == nil {
+		t.Error("expected an error with a slash in the root name")
+	}
+}
+
+func TestAddParams_FromQueryMetadata(t *testing.T) {
+	q, err := url.ParseQuery("preserve-mode=true&mtime=1600000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := AddParamsFromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.PreserveMode || p.PreserveMtime || p.Mtime.Unix() != 1600000000 {
+		t.Error("did not parse the metadata options correctly")
+	}
+
+	q.Set("mode", "755")
+	_, err = AddParamsFromQuery(q)
+	if err == nil {
+		t.Error("expected an error with both preserve-mode and mode")
+	}
+
+	q.Del("preserve-mode")
+	p, err = AddParamsFromQuery(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Mode != 0755 {
+		t.Errorf("unexpected mode: %o", p.Mode)
+	}
+
+	q.Set("mode", "1777")
+	_, err = AddParamsFromQuery(q)
+	if err == nil {
+		t.Error("expected an error with an invalid mode")
+	}
 }
 
 func TestAddParams_ToQueryString(t *testing.T) {
@@ -34,7 +76,10 @@ func TestAddParams_ToQueryString(t *testing.T) {
 	p.ReplicationFactorMax = 6
 	p.Name = "something"
 	p.RawLeaves = true
+	p.RootName = "data"
 	p.ShardSize = 1020
+	p.PreserveMtime = true
+	p.Mode = 0640
 	qstr, err := p.ToQueryString()
 	if err != nil {
 		t.Fatal(err)
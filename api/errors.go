@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ErrorCode identifies a kind of error, so that clients can react to it
+// without relying on the wording of error messages.
+type ErrorCode string
+
+// Error codes set by the cluster components. Their names are stable.
+const (
+	// ErrCodeNotLeader means that the consensus has no leader to commit
+	// the operation, or that this peer cannot reach it.
+	ErrCodeNotLeader ErrorCode = "ERR_NOT_LEADER"
+	// ErrCodePinQueueFull means that the pin tracker cannot queue more
+	// operations.
+	ErrCodePinQueueFull ErrorCode = "ERR_PIN_QUEUE_FULL"
+	// ErrCodeOutdatedState means that the shared state of the peer is not
+	// in line with the consensus log.
+	ErrCodeOutdatedState ErrorCode = "ERR_OUTDATED_STATE"
+	// ErrCodeNotFound means that the item is not part of the pinset.
+	ErrCodeNotFound ErrorCode = "ERR_NOT_FOUND"
+	// ErrCodeShuttingDown means that the peer is shutting down and does
+	// not accept writes.
+	ErrCodeShuttingDown ErrorCode = "ERR_SHUTTING_DOWN"
+	// ErrCodeQuotaExceeded means that the operation would exceed a
+	// namespace or user quota.
+	ErrCodeQuotaExceeded ErrorCode = "ERR_QUOTA_EXCEEDED"
+	// ErrCodePinProtected means that the pin is protected and can only
+	// be removed with a forced unpin.
+	ErrCodePinProtected ErrorCode = "ERR_PIN_PROTECTED"
+	// ErrCodeOverloaded means that the cluster does not admit new pins
+	// right now because of its load. The message tells when to retry
+	// (see RetryAfterOf).
+	ErrCodeOverloaded ErrorCode = "ERR_OVERLOADED"
+	// ErrCodeNoSpace means that no peer has enough free space to store
+	// the content of the pin.
+	ErrCodeNoSpace ErrorCode = "ERR_NO_SPACE"
+)
+
+// CodedError is an error with an ErrorCode. Its message starts with the
+// code, so that the code survives RPC calls (which only transmit error
+// messages) and errors which wrap the message of this one.
+type CodedError struct {
+	Code    ErrorCode
+	Message string
+}
+
+// NewCodedError returns a CodedError with the given code and formatted
+// message.
+func NewCodedError(code ErrorCode, format string, a ...interface{}) *CodedError {
+	return &CodedError{
+		Code:    code,
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+// Error returns the code and the message of the error.
+func (e *CodedError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+var errorCodeRegexp = regexp.MustCompile(`\bERR_[A-Z_]+[A-Z]\b`)
+
+// ErrorCodeOf returns the code of the given error: that of a *CodedError or
+// *Error, or else the first code found in the error message. It returns an
+// empty code when there is none.
+func ErrorCodeOf(err error) ErrorCode {
+	switch e := err.(type) {
+	case nil:
+		return ""
+	case *CodedError:
+		return e.Code
+	case *Error:
+		if e.ErrorCode != "" {
+			return e.ErrorCode
+		}
+	}
+	return ErrorCode(errorCodeRegexp.FindString(err.Error()))
+}
+
+var retryAfterRegexp = regexp.MustCompile(`\bretry after ([0-9][0-9.a-zµ]*)`)
+
+// RetryAfterOf returns how long to wait before retrying, as told by the
+// message of the given error ("... retry after 30s"). It returns 0 when the
+// message gives no time.
+func RetryAfterOf(err error) time.Duration {
+	if err == nil {
+		return 0
+	}
+	m := retryAfterRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	d, perr := time.ParseDuration(m[1])
+	if perr != nil || d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	err := NewCodedError(ErrCodePinQueueFull, "queue is full (%d items)", 10)
+	if err.Error() != "ERR_PIN_QUEUE_FULL: queue is full (10 items)" {
+		t.Error("unexpected message:", err)
+	}
+
+	testcases := []struct {
+		err  error
+		code ErrorCode
+	}{
+		{nil, ""},
+		{errors.New("some error"), ""},
+		{errors.New("ERR_: not a code"), ""},
+		{err, ErrCodePinQueueFull},
+		// Errors received through RPC only keep the message.
+		{errors.New(err.Error()), ErrCodePinQueueFull},
+		{fmt.Errorf("pinning: %s", err), ErrCodePinQueueFull},
+		{&Error{Code: 503, Message: "x", ErrorCode: ErrCodeNotLeader}, ErrCodeNotLeader},
+		{&Error{Code: 500, Message: err.Error()}, ErrCodePinQueueFull},
+	}
+
+	for i, tc := range testcases {
+		if code := ErrorCodeOf(tc.err); code != tc.code {
+			t.Errorf("%d: expected %q, got %q", i, tc.code, code)
+		}
+	}
+}
+
+func TestRetryAfterOf(t *testing.T) {
+	err := NewCodedError(ErrCodeOverloaded, "too many queued operations: retry after 1m30s")
+
+	testcases := []struct {
+		err error
+		d   time.Duration
+	}{
+		{nil, 0},
+		{errors.New("some error"), 0},
+		{errors.New("retry after later"), 0},
+		{err, 90 * time.Second},
+		{fmt.Errorf("pinning: %s", err), 90 * time.Second},
+	}
+
+	for i, tc := range testcases {
+		if d := RetryAfterOf(tc.err); d != tc.d {
+			t.Errorf("%d: expected %s, got %s", i, tc.d, d)
+		}
+	}
+}
@@ -71,6 +71,16 @@ type Config struct {
 	// accepted by the server
 	MaxHeaderBytes int
 
+	// Maximum size in bytes of request bodies (0 means unlimited).
+	// Larger requests are rejected with 413 (Request Entity Too Large).
+	MaxBodySize int64
+
+	// Maximum number of files and directories, and maximum depth of
+	// their paths, in the multipart requests to /add (0 means
+	// unlimited).
+	MaxAddFiles     int
+	MaxAddPathDepth int
+
 	// Server-side amount of time a Keep-Alive connection will be
 	// kept idle before being reused
 	IdleTimeout time.Duration
@@ -109,6 +119,9 @@ type jsonConfig struct {
 	WriteTimeout      string `json:"write_timeout"`
 	IdleTimeout       string `json:"idle_timeout"`
 	MaxHeaderBytes    int    `json:"max_header_bytes"`
+	MaxBodySize       int64  `json:"max_body_size,omitempty"`
+	MaxAddFiles       int    `json:"max_add_files,omitempty"`
+	MaxAddPathDepth   int    `json:"max_add_path_depth,omitempty"`
 
 	ExtractHeadersExtra []string `json:"extract_headers_extra,omitempty"`
 	ExtractHeadersPath  string   `json:"extract_headers_path,omitempty"`
@@ -159,6 +172,9 @@ func (cfg *Config) Default() error {
 	cfg.ExtractHeadersPath = DefaultExtractHeadersPath
 	cfg.ExtractHeadersTTL = DefaultExtractHeadersTTL
 	cfg.MaxHeaderBytes = DefaultMaxHeaderBytes
+	cfg.MaxBodySize = 0
+	cfg.MaxAddFiles = 0
+	cfg.MaxAddPathDepth = 0
 
 	return nil
 }
@@ -218,6 +234,18 @@ func (cfg *Config) Validate() error {
 		err = fmt.Errorf("ipfsproxy.max_header_size must be greater or equal to %d", minMaxHeaderBytes)
 	}
 
+	if cfg.MaxBodySize < 0 {
+		err = errors.New("ipfsproxy.max_body_size is invalid")
+	}
+
+	if cfg.MaxAddFiles < 0 {
+		err = errors.New("ipfsproxy.max_add_files is invalid")
+	}
+
+	if cfg.MaxAddPathDepth < 0 {
+		err = errors.New("ipfsproxy.max_add_path_depth is invalid")
+	}
+
 	return err
 }
 
@@ -278,6 +306,10 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 		cfg.MaxHeaderBytes = jcfg.MaxHeaderBytes
 	}
 
+	cfg.MaxBodySize = jcfg.MaxBodySize
+	cfg.MaxAddFiles = jcfg.MaxAddFiles
+	cfg.MaxAddPathDepth = jcfg.MaxAddPathDepth
+
 	if extra := jcfg.ExtractHeadersExtra; extra != nil && len(extra) > 0 {
 		cfg.ExtractHeadersExtra = extra
 	}
@@ -320,6 +352,9 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 	jcfg.WriteTimeout = cfg.WriteTimeout.String()
 	jcfg.IdleTimeout = cfg.IdleTimeout.String()
 	jcfg.MaxHeaderBytes = cfg.MaxHeaderBytes
+	jcfg.MaxBodySize = cfg.MaxBodySize
+	jcfg.MaxAddFiles = cfg.MaxAddFiles
+	jcfg.MaxAddPathDepth = cfg.MaxAddPathDepth
 	jcfg.NodeHTTPS = cfg.NodeHTTPS
 	jcfg.LogFile = cfg.LogFile
 
@@ -81,6 +81,14 @@ func TestLoadJSON(t *testing.T) {
 	if err == nil {
 		t.Error("expected error in extract_headers_ttl")
 	}
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.MaxBodySize = -1
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error in max_body_size")
+	}
 }
 
 func TestToJSON(t *testing.T) {
@@ -15,8 +15,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/adder"
 	"github.com/ipfs/ipfs-cluster/adder/adderutils"
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/observations"
 	"github.com/ipfs/ipfs-cluster/rpcutil"
 
 	handlers "github.com/gorilla/handlers"
@@ -165,6 +167,8 @@ func New(cfg *Config) (*Server, error) {
 			},
 		}
 	}
+	handler = maxBodySizeHandler(cfg.MaxBodySize, handler)
+	handler = observations.HTTPHandler(context.Background(), "ipfsproxy", handler)
 
 	var writer io.Writer
 	if cfg.LogFile != "" {
@@ -331,6 +335,27 @@ func (proxy *Server) run() {
 }
 
 // ipfsErrorResponder writes an http error response just like IPFS would.
+// maxBodySizeHandler wraps a given handler so that request bodies larger
+// than maxSize are rejected, including those of the requests forwarded to
+// the IPFS daemon. Bodies of unknown length are cut when reading past the
+// limit.
+func maxBodySizeHandler(maxSize int64, h http.Handler) http.Handler {
+	if maxSize <= 0 {
+		return h
+	}
+
+	wrap := func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxSize {
+			msg := fmt.Sprintf("request body larger than %d bytes", maxSize)
+			ipfsErrorResponder(w, msg, http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(wrap)
+}
+
 func ipfsErrorResponder(w http.ResponseWriter, errMsg string, code int) {
 	res := ipfsError{errMsg}
 	resBytes, _ := json.Marshal(res)
@@ -581,6 +606,10 @@ func (proxy *Server) addHandler(w http.ResponseWriter, r *http.Request) {
 		reader,
 		w,
 		outputTransform,
+		adder.Limits{
+			MaxFiles:     proxy.config.MaxAddFiles,
+			MaxPathDepth: proxy.config.MaxAddPathDepth,
+		},
 	)
 
 	// any errors have been sent as Trailer
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+// Manifest formats accepted by ParseManifest.
+const (
+	ManifestJSON = "json"
+	ManifestCSV  = "csv"
+)
+
+// ManifestEntry is an item to pin from a manifest, along with the options
+// which apply only to it. Unset options take the values given for the
+// whole manifest.
+//
+// In JSON, a manifest is a list of objects with the "cid", "name",
+// "replication", "metadata" (an object) and "priority" keys. In CSV, it
+// has a header row naming the columns, among "cid", "name",
+// "replication", "metadata" (as "key=value" pairs separated by ";") and
+// "priority". Only "cid" is required.
+type ManifestEntry struct {
+	Cid  cid.Cid
+	Name string
+	// Sets both the minimum and the maximum replication factors.
+	Replication int
+	Metadata    map[string]string
+	Priority    bool
+}
+
+type manifestEntryJSON struct {
+	Cid         string            `json:"cid"`
+	Name        string            `json:"name,omitempty"`
+	Replication int               `json:"replication,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Priority    bool              `json:"priority,omitempty"`
+}
+
+// MarshalJSON encodes the entry as it appears in JSON manifests, with the
+// CID as a string.
+func (e *ManifestEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&manifestEntryJSON{
+		Cid:         e.Cid.String(),
+		Name:        e.Name,
+		Replication: e.Replication,
+		Metadata:    e.Metadata,
+		Priority:    e.Priority,
+	})
+}
+
+// UnmarshalJSON decodes an entry of a JSON manifest.
+func (e *ManifestEntry) UnmarshalJSON(b []byte) error {
+	var ej manifestEntryJSON
+	if err := json.Unmarshal(b, &ej); err != nil {
+		return err
+	}
+	c, err := cid.Decode(ej.Cid)
+	if err != nil {
+		return fmt.Errorf("invalid cid %q: %s", ej.Cid, err)
+	}
+	*e = ManifestEntry{
+		Cid:         c,
+		Name:        ej.Name,
+		Replication: ej.Replication,
+		Metadata:    ej.Metadata,
+		Priority:    ej.Priority,
+	}
+	return e.Validate()
+}
+
+// Validate checks that the entry can be pinned.
+func (e *ManifestEntry) Validate() error {
+	if !e.Cid.Defined() {
+		return errors.New("manifest entries need a cid")
+	}
+	if e.Replication < -1 {
+		return fmt.Errorf("%s: invalid replication factor: %d", e.Cid, e.Replication)
+	}
+	return nil
+}
+
+// PinOptions returns the options to pin the entry with: the given ones,
+// overridden by those set in the entry. Metadata are merged.
+func (e *ManifestEntry) PinOptions(opts PinOptions) PinOptions {
+	if e.Name != "" {
+		opts.Name = e.Name
+	}
+	if e.Replication != 0 {
+		opts.ReplicationFactorMin = e.Replication
+		opts.ReplicationFactorMax = e.Replication
+	}
+	if len(e.Metadata) > 0 {
+		meta := make(map[string]string, len(opts.Metadata)+len(e.Metadata))
+		for k, v := range opts.Metadata {
+			meta[k] = v
+		}
+		for k, v := range e.Metadata {
+			meta[k] = v
+		}
+		opts.Metadata = meta
+	}
+	if e.Priority {
+		opts.Priority = true
+	}
+	return opts
+}
+
+// ParseManifest reads a manifest in the given format (ManifestJSON or
+// ManifestCSV).
+func ParseManifest(r io.Reader, format string) ([]*ManifestEntry, error) {
+	switch format {
+	case ManifestJSON:
+		return parseManifestJSON(r)
+	case ManifestCSV:
+		return parseManifestCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown manifest format: %q", format)
+	}
+}
+
+func parseManifestJSON(r io.Reader) ([]*ManifestEntry, error) {
+	var entries []*ManifestEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("error decoding the manifest: %s", err)
+	}
+	for i, e := range entries {
+		if e == nil {
+			return nil, fmt.Errorf("entry %d: empty entry", i+1)
+		}
+	}
+	return entries, nil
+}
+
+func parseManifestCSV(r io.Reader) ([]*ManifestEntry, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the manifest header: %s", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, col := range header {
+		col = strings.ToLower(strings.TrimSpace(col))
+		switch col {
+		case "cid", "name", "replication", "metadata", "priority":
+		default:
+			return nil, fmt.Errorf("unknown manifest column: %q", col)
+		}
+		columns[col] = i
+	}
+	if _, ok := columns["cid"]; !ok {
+		return nil, errors.New("the manifest has no cid column")
+	}
+
+	var entries []*ManifestEntry
+	for line := 2; ; line++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading the manifest: %s", err)
+		}
+		e, err := manifestEntryFromCSV(columns, record)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func manifestEntryFromCSV(columns map[string]int, record []string) (*ManifestEntry, error) {
+	field := func(col string) string {
+		i, ok := columns[col]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	e := &ManifestEntry{Name: field("name")}
+	var err error
+	e.Cid, err = cid.Decode(field("cid"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cid %q: %s", field("cid"), err)
+	}
+	if v := field("replication"); v != "" {
+		e.Replication, err = strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replication %q", v)
+		}
+	}
+	if v := field("priority"); v != "" {
+		e.Priority, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority %q", v)
+		}
+	}
+	if v := field("metadata"); v != "" {
+		e.Metadata = make(map[string]string)
+		for _, kv := range strings.Split(v, ";") {
+			if strings.TrimSpace(kv) == "" {
+				continue
+			}
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("metadata not in the format key=value: %q", kv)
+			}
+			e.Metadata[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return e, e.Validate()
+}
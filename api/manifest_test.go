@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	check := func(t *testing.T, entries []*ManifestEntry) {
+		t.Helper()
+		if len(entries) != 2 {
+			t.Fatalf("expected 2 entries, got %d", len(entries))
+		}
+		e := entries[0]
+		if !e.Cid.Equals(testCid1) || e.Name != "one" || e.Replication != 2 ||
+			e.Metadata["team"] != "data" || e.Metadata["src"] != "export" || !e.Priority {
+			t.Errorf("unexpected first entry: %+v", e)
+		}
+		e = entries[1]
+		if !e.Cid.Equals(testCid2) || e.Name != "" || e.Replication != 0 || len(e.Metadata) != 0 || e.Priority {
+			t.Errorf("unexpected second entry: %+v", e)
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		manifest := `[
+  {"cid": "` + testCid1.String() + `", "name": "one", "replication": 2, "metadata": {"team": "data", "src": "export"}, "priority": true},
+  {"cid": "` + testCid2.String() + `"}
+]`
+		entries, err := ParseManifest(strings.NewReader(manifest), ManifestJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, entries)
+
+		// Entries encode as they are read.
+		b, err := json.Marshal(entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err = ParseManifest(bytes.NewReader(b), ManifestJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, entries)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		manifest := "CID,name,replication,metadata,priority\n" +
+			testCid1.String() + ",one,2,team=data;src=export,true\n" +
+			testCid2.String() + ",,,,\n"
+		entries, err := ParseManifest(strings.NewReader(manifest), ManifestCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, entries)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		bad := []struct {
+			format   string
+			manifest string
+		}{
+			{ManifestJSON, `[{"name": "nocid"}]`},
+			{ManifestJSON, `[{"cid": "` + testCid1.String() + `", "replication": -2}]`},
+			{ManifestCSV, "name\none\n"},
+			{ManifestCSV, "cid,size\n" + testCid1.String() + ",10\n"},
+			{ManifestCSV, "cid,replication\n" + testCid1.String() + ",two\n"},
+			{ManifestCSV, "cid,metadata\n" + testCid1.String() + ",team\n"},
+			{"yaml", ""},
+		}
+		for _, b := range bad {
+			_, err := ParseManifest(strings.NewReader(b.manifest), b.format)
+			if err == nil {
+				t.Errorf("expected an error with %s manifest %q", b.format, b.manifest)
+			}
+		}
+	})
+}
+
+func TestManifestEntryPinOptions(t *testing.T) {
+	base := PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 3,
+		Name:                 "base",
+		Metadata:             map[string]string{"team": "ops", "env": "prod"},
+	}
+	e := &ManifestEntry{
+		Cid:         testCid1,
+		Replication: 2,
+		Metadata:    map[string]string{"team": "data"},
+	}
+	opts := e.PinOptions(base)
+	if opts.Name != "base" || opts.ReplicationFactorMin != 2 || opts.ReplicationFactorMax != 2 {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if opts.Metadata["team"] != "data" || opts.Metadata["env"] != "prod" {
+		t.Errorf("unexpected metadata: %v", opts.Metadata)
+	}
+	if base.Metadata["team"] != "ops" {
+		t.Error("the base options should not be modified")
+	}
+}
@@ -61,6 +61,7 @@ type Pin struct {
 	MaxDepth             int32       `protobuf:"zigzag32,4,opt,name=MaxDepth,proto3" json:"MaxDepth,omitempty"`
 	Reference            []byte      `protobuf:"bytes,5,opt,name=Reference,proto3" json:"Reference,omitempty"`
 	Options              *PinOptions `protobuf:"bytes,6,opt,name=Options,proto3" json:"Options,omitempty"`
+	Size                 uint64      `protobuf:"varint,7,opt,name=Size,proto3" json:"Size,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
 	XXX_unrecognized     []byte      `json:"-"`
 	XXX_sizecache        int32       `json:"-"`
@@ -133,6 +134,13 @@ func (m *Pin) GetOptions() *PinOptions {
 	return nil
 }
 
+func (m *Pin) GetSize() uint64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
 type PinOptions struct {
 	ReplicationFactorMin int32             `protobuf:"zigzag32,1,opt,name=ReplicationFactorMin,proto3" json:"ReplicationFactorMin,omitempty"`
 	ReplicationFactorMax int32             `protobuf:"zigzag32,2,opt,name=ReplicationFactorMax,proto3" json:"ReplicationFactorMax,omitempty"`
@@ -141,6 +149,20 @@ type PinOptions struct {
 	Metadata             map[string]string `protobuf:"bytes,6,rep,name=Metadata,proto3" json:"Metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 	PinUpdate            []byte            `protobuf:"bytes,7,opt,name=PinUpdate,proto3" json:"PinUpdate,omitempty"`
 	ExpireAt             uint64            `protobuf:"varint,8,opt,name=ExpireAt,proto3" json:"ExpireAt,omitempty"`
+	Policy               string            `protobuf:"bytes,9,opt,name=Policy,proto3" json:"Policy,omitempty"`
+	Namespace            string            `protobuf:"bytes,10,opt,name=Namespace,proto3" json:"Namespace,omitempty"`
+	Owner                string            `protobuf:"bytes,11,opt,name=Owner,proto3" json:"Owner,omitempty"`
+	Priority             bool              `protobuf:"varint,12,opt,name=Priority,proto3" json:"Priority,omitempty"`
+	FetchRateLimit       uint64            `protobuf:"varint,13,opt,name=FetchRateLimit,proto3" json:"FetchRateLimit,omitempty"`
+	LocalPin             bool              `protobuf:"varint,14,opt,name=LocalPin,proto3" json:"LocalPin,omitempty"`
+	StorageClass         string            `protobuf:"bytes,15,opt,name=StorageClass,proto3" json:"StorageClass,omitempty"`
+	Collection           string            `protobuf:"bytes,16,opt,name=Collection,proto3" json:"Collection,omitempty"`
+	Origins              [][]byte          `protobuf:"bytes,17,rep,name=Origins,proto3" json:"Origins,omitempty"`
+	Protected            bool              `protobuf:"varint,18,opt,name=Protected,proto3" json:"Protected,omitempty"`
+	PeerGroup            string            `protobuf:"bytes,19,opt,name=PeerGroup,proto3" json:"PeerGroup,omitempty"`
+	AllocateBy           string            `protobuf:"bytes,20,opt,name=AllocateBy,proto3" json:"AllocateBy,omitempty"`
+	AllowedTags          []string          `protobuf:"bytes,21,rep,name=AllowedTags,proto3" json:"AllowedTags,omitempty"`
+	GroupPeers           [][]byte          `protobuf:"bytes,22,rep,name=GroupPeers,proto3" json:"GroupPeers,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
 	XXX_unrecognized     []byte            `json:"-"`
 	XXX_sizecache        int32             `json:"-"`
@@ -220,6 +242,104 @@ func (m *PinOptions) GetExpireAt() uint64 {
 	return 0
 }
 
+func (m *PinOptions) GetPolicy() string {
+	if m != nil {
+		return m.Policy
+	}
+	return ""
+}
+
+func (m *PinOptions) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *PinOptions) GetOwner() string {
+	if m != nil {
+		return m.Owner
+	}
+	return ""
+}
+
+func (m *PinOptions) GetPriority() bool {
+	if m != nil {
+		return m.Priority
+	}
+	return false
+}
+
+func (m *PinOptions) GetFetchRateLimit() uint64 {
+	if m != nil {
+		return m.FetchRateLimit
+	}
+	return 0
+}
+
+func (m *PinOptions) GetLocalPin() bool {
+	if m != nil {
+		return m.LocalPin
+	}
+	return false
+}
+
+func (m *PinOptions) GetStorageClass() string {
+	if m != nil {
+		return m.StorageClass
+	}
+	return ""
+}
+
+func (m *PinOptions) GetCollection() string {
+	if m != nil {
+		return m.Collection
+	}
+	return ""
+}
+
+func (m *PinOptions) GetOrigins() [][]byte {
+	if m != nil {
+		return m.Origins
+	}
+	return nil
+}
+
+func (m *PinOptions) GetProtected() bool {
+	if m != nil {
+		return m.Protected
+	}
+	return false
+}
+
+func (m *PinOptions) GetPeerGroup() string {
+	if m != nil {
+		return m.PeerGroup
+	}
+	return ""
+}
+
+func (m *PinOptions) GetAllocateBy() string {
+	if m != nil {
+		return m.AllocateBy
+	}
+	return ""
+}
+
+func (m *PinOptions) GetAllowedTags() []string {
+	if m != nil {
+		return m.AllowedTags
+	}
+	return nil
+}
+
+func (m *PinOptions) GetGroupPeers() [][]byte {
+	if m != nil {
+		return m.GroupPeers
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterEnum("api.pb.Pin_PinType", Pin_PinType_name, Pin_PinType_value)
 	proto.RegisterType((*Pin)(nil), "api.pb.Pin")
@@ -230,31 +350,43 @@ func init() {
 func init() { proto.RegisterFile("types.proto", fileDescriptor_d938547f84707355) }
 
 var fileDescriptor_d938547f84707355 = []byte{
-	// 405 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x52, 0xc1, 0x6e, 0xd3, 0x40,
-	0x10, 0x65, 0x6d, 0xc7, 0xb1, 0xc7, 0x69, 0x95, 0x0e, 0x3d, 0xac, 0x2a, 0x0e, 0xab, 0x5c, 0xf0,
-	0x01, 0xf9, 0x60, 0x2e, 0x08, 0xb8, 0x84, 0xa6, 0x20, 0x21, 0x05, 0xa2, 0x2d, 0xfd, 0x80, 0x6d,
-	0xbc, 0xa8, 0x2b, 0x8c, 0xbd, 0x72, 0xb6, 0xc8, 0xe6, 0x6f, 0xf8, 0x34, 0xfe, 0x04, 0xed, 0x6e,
-	0xea, 0x14, 0x35, 0x07, 0x4b, 0xf3, 0xde, 0xcc, 0x9b, 0x19, 0xbf, 0x1d, 0xc8, 0xcc, 0xa0, 0xe5,
-	0xae, 0xd0, 0x5d, 0x6b, 0x5a, 0x8c, 0x85, 0x56, 0x85, 0xbe, 0x5d, 0xfc, 0x09, 0x20, 0xdc, 0xa8,
-	0x06, 0xe7, 0x10, 0x5e, 0xaa, 0x8a, 0x12, 0x46, 0xf2, 0x19, 0xb7, 0x21, 0xbe, 0x84, 0xe8, 0xdb,
-	0xa0, 0x25, 0x0d, 0x18, 0xc9, 0x4f, 0xcb, 0xe7, 0x85, 0x17, 0x14, 0x1b, 0xd5, 0xd8, 0xcf, 0xa6,
-	0xb8, 0x2b, 0x40, 0x06, 0xd9, 0xb2, 0xae, 0xdb, 0xad, 0x30, 0xaa, 0x6d, 0x76, 0x34, 0x64, 0x61,
-	0x3e, 0xe3, 0x8f, 0x29, 0xbc, 0x80, 0x64, 0x2d, 0xfa, 0x95, 0xd4, 0xe6, 0x8e, 0x46, 0x8c, 0xe4,
-	0x67, 0x7c, 0xc4, 0xf8, 0x02, 0x52, 0x2e, 0xbf, 0xcb, 0x4e, 0x36, 0x5b, 0x49, 0x27, 0x6e, 0xfc,
-	0x81, 0xc0, 0x57, 0x30, 0xfd, 0xaa, 0x7d, 0xdf, 0x98, 0x91, 0x3c, 0x2b, 0xf1, 0xd1, 0x1e, 0xfb,
-	0x0c, 0x7f, 0x28, 0x59, 0xdc, 0xc0, 0x74, 0xbf, 0x1a, 0x66, 0x30, 0xfd, 0x20, 0x2a, 0x1b, 0xce,
-	0x9f, 0xe1, 0x0c, 0x92, 0x95, 0x30, 0xc2, 0x21, 0x62, 0xd1, 0x5a, 0xee, 0x51, 0x80, 0x08, 0xa7,
-	0x97, 0xf5, 0xfd, 0xce, 0xc8, 0x6e, 0xb5, 0xfc, 0xe4, 0xb8, 0x10, 0x4f, 0x20, 0xbd, 0xbe, 0x13,
-	0x9d, 0x97, 0x47, 0x8b, 0xbf, 0x01, 0xc0, 0x61, 0x1c, 0x96, 0x70, 0xce, 0xa5, 0xae, 0x95, 0xff,
-	0xbb, 0x8f, 0x62, 0x6b, 0xda, 0x6e, 0xad, 0x1a, 0xe7, 0xdd, 0x19, 0x3f, 0x9a, 0x3b, 0xae, 0x11,
-	0xbd, 0x33, 0xf7, 0xa8, 0x46, 0xf4, 0x88, 0x10, 0x7d, 0x11, 0x3f, 0x25, 0x0d, 0x19, 0xc9, 0x53,
-	0xee, 0x62, 0xeb, 0x96, 0xdb, 0xec, 0x5a, 0xfd, 0x96, 0xce, 0xca, 0x88, 0x1f, 0x08, 0x7c, 0xef,
-	0xff, 0xac, 0x12, 0x46, 0xd0, 0x98, 0x85, 0x79, 0x56, 0xb2, 0xa7, 0x76, 0x15, 0x0f, 0x25, 0x57,
-	0x8d, 0xe9, 0x06, 0x3e, 0x2a, 0x6c, 0xef, 0x8d, 0x6a, 0x6e, 0x74, 0x25, 0x8c, 0xa4, 0x53, 0xff,
-	0x12, 0x23, 0x61, 0xdf, 0xf0, 0xaa, 0xd7, 0xaa, 0x93, 0x4b, 0x43, 0x13, 0x37, 0x78, 0xc4, 0x17,
-	0xef, 0xe0, 0xe4, 0xbf, 0xa6, 0xf6, 0x9a, 0x7e, 0xc8, 0xc1, 0x39, 0x92, 0x72, 0x1b, 0xe2, 0x39,
-	0x4c, 0x7e, 0x89, 0xfa, 0xde, 0x9f, 0x53, 0xca, 0x3d, 0x78, 0x1b, 0xbc, 0x21, 0x9f, 0xa3, 0x64,
-	0x32, 0x8f, 0x6f, 0x63, 0x77, 0x96, 0xaf, 0xff, 0x05, 0x00, 0x00, 0xff, 0xff, 0xaf, 0x59, 0x74,
-	0x86, 0xa5, 0x02, 0x00, 0x00,
+	// 601 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x54, 0xcd, 0x4e, 0xdb, 0x40,
+	0x10, 0xae, 0x63, 0xe3, 0xc4, 0x93, 0x90, 0x86, 0x85, 0xa2, 0x11, 0x42, 0x95, 0x95, 0x43, 0xeb,
+	0x43, 0x95, 0x43, 0x7a, 0xa9, 0xda, 0x5e, 0x20, 0xfc, 0x48, 0x15, 0x94, 0x68, 0x81, 0x07, 0x58,
+	0x9c, 0x29, 0xac, 0x6a, 0x6c, 0x6b, 0xbd, 0x94, 0xb8, 0x6f, 0xd0, 0x07, 0xea, 0xfb, 0x55, 0xbb,
+	0x9b, 0xc4, 0xa1, 0xa5, 0x07, 0x4b, 0xf3, 0x7d, 0xf3, 0xf7, 0xcd, 0xac, 0x77, 0xa1, 0xab, 0xeb,
+	0x92, 0xaa, 0x51, 0xa9, 0x0a, 0x5d, 0xb0, 0x50, 0x94, 0x72, 0x54, 0xde, 0x0c, 0x7f, 0xb7, 0xc0,
+	0x9f, 0xca, 0x9c, 0x0d, 0xc0, 0x9f, 0xc8, 0x19, 0x7a, 0xb1, 0x97, 0xf4, 0xb8, 0x31, 0xd9, 0x5b,
+	0x08, 0xae, 0xea, 0x92, 0xb0, 0x15, 0x7b, 0x49, 0x7f, 0xbc, 0x3d, 0x72, 0x09, 0xa3, 0xa9, 0xcc,
+	0xcd, 0x67, 0x5c, 0xdc, 0x06, 0xb0, 0x18, 0xba, 0x07, 0x59, 0x56, 0xa4, 0x42, 0xcb, 0x22, 0xaf,
+	0xd0, 0x8f, 0xfd, 0xa4, 0xc7, 0xd7, 0x29, 0xb6, 0x07, 0x9d, 0x73, 0x31, 0x3f, 0xa2, 0x52, 0xdf,
+	0x61, 0x10, 0x7b, 0xc9, 0x16, 0x5f, 0x61, 0xb6, 0x0f, 0x11, 0xa7, 0x6f, 0xa4, 0x28, 0x4f, 0x09,
+	0x37, 0x6c, 0xfb, 0x86, 0x60, 0xef, 0xa0, 0x7d, 0x51, 0xba, 0xba, 0x61, 0xec, 0x25, 0xdd, 0x31,
+	0x5b, 0xd3, 0xb1, 0xf0, 0xf0, 0x65, 0x08, 0x63, 0x10, 0x5c, 0xca, 0x9f, 0x84, 0xed, 0xd8, 0x4b,
+	0x02, 0x6e, 0xed, 0xe1, 0x35, 0xb4, 0x17, 0x72, 0x59, 0x17, 0xda, 0x87, 0x62, 0x66, 0xcc, 0xc1,
+	0x0b, 0xd6, 0x83, 0xce, 0x91, 0xd0, 0xc2, 0x22, 0xcf, 0xa0, 0x73, 0x5a, 0xa0, 0x16, 0x63, 0xd0,
+	0x9f, 0x64, 0x0f, 0x95, 0x26, 0x75, 0x74, 0x70, 0x6a, 0x39, 0x9f, 0x6d, 0x42, 0x74, 0x79, 0x27,
+	0x94, 0x4b, 0x0f, 0x86, 0xbf, 0x42, 0x80, 0x46, 0x02, 0x1b, 0xc3, 0x0e, 0xa7, 0x32, 0x93, 0x6e,
+	0xe2, 0x13, 0x91, 0xea, 0x42, 0x9d, 0xcb, 0xdc, 0xee, 0x73, 0x8b, 0x3f, 0xeb, 0x7b, 0x3e, 0x47,
+	0xcc, 0xb1, 0xf5, 0xbf, 0x1c, 0x31, 0x37, 0x13, 0x7e, 0x15, 0xf7, 0x84, 0x7e, 0xec, 0x25, 0x11,
+	0xb7, 0x36, 0xdb, 0x5f, 0x28, 0xb3, 0xa3, 0x07, 0x76, 0xf4, 0x86, 0x60, 0x9f, 0xdd, 0x64, 0x33,
+	0xa1, 0x05, 0x86, 0xb1, 0x9f, 0x74, 0xc7, 0xf1, 0xbf, 0x2b, 0x1c, 0x2d, 0x43, 0x8e, 0x73, 0xad,
+	0x6a, 0xbe, 0xca, 0x30, 0xb5, 0xa7, 0x32, 0xbf, 0x2e, 0x67, 0x42, 0xbb, 0xb5, 0xf6, 0x78, 0x43,
+	0x98, 0x73, 0x3d, 0x9e, 0x97, 0x52, 0xd1, 0x81, 0xc6, 0x8e, 0x6d, 0xbc, 0xc2, 0x6c, 0x17, 0xc2,
+	0x69, 0x91, 0xc9, 0xb4, 0xc6, 0xc8, 0x6a, 0x5d, 0x20, 0x53, 0xd1, 0xa8, 0xae, 0x4a, 0x91, 0x12,
+	0x82, 0x75, 0x35, 0x04, 0xdb, 0x81, 0x8d, 0x8b, 0xc7, 0x9c, 0x14, 0x76, 0xad, 0xc7, 0x01, 0xd3,
+	0x67, 0xaa, 0x64, 0xa1, 0xa4, 0xae, 0xb1, 0x17, 0x7b, 0x49, 0x87, 0xaf, 0x30, 0x7b, 0x03, 0xfd,
+	0x13, 0xd2, 0xe9, 0x1d, 0x17, 0x9a, 0xce, 0xe4, 0xbd, 0xd4, 0xb8, 0x69, 0x95, 0xfc, 0xc5, 0x9a,
+	0x1a, 0x67, 0x45, 0x2a, 0xb2, 0xa9, 0xcc, 0xb1, 0xef, 0x6a, 0x2c, 0x31, 0x1b, 0x42, 0xef, 0x52,
+	0x17, 0x4a, 0xdc, 0xd2, 0x24, 0x13, 0x55, 0x85, 0x2f, 0x6d, 0xf3, 0x27, 0x1c, 0x7b, 0x0d, 0x30,
+	0x29, 0xb2, 0x8c, 0x52, 0xb3, 0x30, 0x1c, 0xd8, 0x88, 0x35, 0x86, 0x21, 0xb4, 0x2f, 0x94, 0xbc,
+	0x95, 0x79, 0x85, 0x5b, 0xf6, 0x06, 0x2c, 0xa1, 0xdd, 0xa1, 0x2a, 0x34, 0xa5, 0x9a, 0x66, 0xc8,
+	0x6c, 0xeb, 0x86, 0xb0, 0x5e, 0x22, 0x75, 0xaa, 0x8a, 0x87, 0x12, 0xb7, 0xdd, 0x3e, 0x56, 0x84,
+	0xe9, 0xba, 0xb8, 0x48, 0x74, 0x58, 0xe3, 0x8e, 0xeb, 0xda, 0x30, 0xcb, 0xbb, 0xf7, 0x48, 0xb3,
+	0x2b, 0x71, 0x5b, 0xe1, 0xab, 0xd8, 0x4f, 0x22, 0xbe, 0x4e, 0x99, 0x0a, 0xb6, 0x94, 0xa9, 0x59,
+	0xe1, 0xae, 0x95, 0xb6, 0xc6, 0xec, 0x7d, 0x82, 0xcd, 0x27, 0x87, 0x6f, 0x5e, 0x82, 0xef, 0x54,
+	0xdb, 0x3f, 0x37, 0xe2, 0xc6, 0x34, 0x87, 0xf2, 0x43, 0x64, 0x0f, 0xee, 0x29, 0x88, 0xb8, 0x03,
+	0x1f, 0x5b, 0x1f, 0xbc, 0x2f, 0x41, 0x67, 0x63, 0x10, 0xde, 0x84, 0xf6, 0x49, 0x79, 0xff, 0x67,
+	0x00, 0x3e, 0x64, 0x31, 0x10, 0x61, 0x04, 0x00, 0x00,
 }
@@ -0,0 +1,208 @@
+package rest
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Casings of the JSON field names in the responses (see
+// Config.JSONFieldCasing). Only the names of the fields of the api types are
+// affected: the keys of maps (i.e. peer IDs or metadata keys) are kept.
+const (
+	// SnakeCase uses the field names of the api types as they are
+	// (i.e. "replication_factor_min").
+	SnakeCase = "snake_case"
+	// CamelCase converts them to camel case (i.e. "replicationFactorMin").
+	CamelCase = "camelCase"
+)
+
+// FieldCasingHeader can be set in requests to choose the casing of the JSON
+// field names in the response, overriding the configured one. Responses set
+// it to the casing used.
+const FieldCasingHeader = "X-Json-Field-Casing"
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func validCasing(casing string) bool {
+	return casing == SnakeCase || casing == CamelCase
+}
+
+// fieldCasing sets the FieldCasingHeader of the response to the casing
+// requested or, by default, configured. sendResponse reads it from there.
+func (api *API) fieldCasing(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		casing := r.Header.Get(FieldCasingHeader)
+		if casing == "" {
+			casing = api.config.JSONFieldCasing
+		}
+		if !validCasing(casing) {
+			api.sendResponse(w, http.StatusBadRequest, fmt.Errorf("unknown %s: %s", FieldCasingHeader, casing), nil)
+			return
+		}
+		w.Header().Set(FieldCasingHeader, casing)
+		handler(w, r)
+	}
+}
+
+// recase returns an object which encodes to the same JSON as v, but with
+// the field names in the given casing.
+func recase(v interface{}, casing string) interface{} {
+	if casing != CamelCase {
+		return v
+	}
+	return recaseValue(reflect.ValueOf(v))
+}
+
+func recaseValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	// Types with their own encoding are left to it, as encoding/json
+	// does.
+	t := v.Type()
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil
+		}
+		return v.Interface()
+	}
+	if v.CanAddr() {
+		pt := reflect.PtrTo(t)
+		if pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType) {
+			return v.Addr().Interface()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return recaseValue(v.Elem())
+	case reflect.Struct:
+		obj := make(map[string]interface{})
+		recaseFields(v, obj)
+		return obj
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		obj := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			obj[mapKey(iter.Key())] = recaseValue(iter.Value())
+		}
+		return obj
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v.Interface() // base64
+		}
+		fallthrough
+	case reflect.Array:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = recaseValue(v.Index(i))
+		}
+		return list
+	default:
+		return v.Interface()
+	}
+}
+
+// recaseFields adds the exported fields of a struct to obj, following the
+// json tags. Fields of embedded structs are added as if they were fields of
+// the struct.
+func recaseFields(v reflect.Value, obj map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		omitEmpty := false
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name = tag[:idx]
+			omitEmpty = strings.Contains(tag[idx:], ",omitempty")
+		}
+		fv := v.Field(i)
+
+		if sf.Anonymous && name == "" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				recaseFields(fv, obj)
+				continue
+			}
+		}
+		if sf.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		obj[camelCase(name)] = recaseValue(fv)
+	}
+}
+
+func mapKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// isEmptyValue follows the omitempty rules of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// camelCase converts snake_case names to camelCase.
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestCamelCase(t *testing.T) {
+	for in, out := range map[string]string{
+		"cid":                    "cid",
+		"replication_factor_min": "replicationFactorMin",
+		"peer_map":               "peerMap",
+		"a__b":                   "aB",
+	} {
+		if got := camelCase(in); got != out {
+			t.Errorf("camelCase(%s): got %s, want %s", in, got, out)
+		}
+	}
+}
+
+func TestRecase(t *testing.T) {
+	pin := api.PinWithOpts(test.Cid1, api.PinOptions{
+		ReplicationFactorMin: 1,
+		Metadata: map[string]string{
+			"some_key": "value",
+		},
+	})
+	pin.Allocations = []peer.ID{test.PeerID1}
+
+	snake, err := json.Marshal(recase(pin, SnakeCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := json.Marshal(pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(snake) != string(orig) {
+		t.Error("expected snake_case to keep the encoding")
+	}
+
+	camel, err := json.Marshal(recase(pin, CamelCase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var obj map[string]interface{}
+	err = json.Unmarshal(camel, &obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ci, ok := obj["cid"].(map[string]interface{})
+	if !ok || ci["/"] != test.Cid1.String() {
+		t.Error("expected the cid to use its own encoding:", obj["cid"])
+	}
+	if obj["replicationFactorMin"] != float64(1) {
+		t.Error("expected embedded fields in camel case:", string(camel))
+	}
+	if _, ok := obj["replication_factor_min"]; ok {
+		t.Error("expected no snake_case field names")
+	}
+	if _, ok := obj["policy"]; ok {
+		t.Error("expected omitempty fields to be omitted")
+	}
+	meta, ok := obj["metadata"].(map[string]interface{})
+	if !ok || meta["some_key"] != "value" {
+		t.Error("expected map keys to be kept:", obj["metadata"])
+	}
+	allocs, ok := obj["allocations"].([]interface{})
+	if !ok || len(allocs) != 1 || allocs[0] != test.PeerID1.Pretty() {
+		t.Error("expected peer IDs to use their own encoding:", obj["allocations"])
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
@@ -51,10 +52,29 @@ type Client interface {
 
 	// Peers requests ID information for all cluster peers.
 	Peers(context.Context) ([]*api.ID, error)
+	// PeerNames returns the peernames of the cluster peers, indexed by
+	// peer ID.
+	PeerNames(context.Context) (map[string]string, error)
 	// PeerAdd adds a new peer to the cluster.
 	PeerAdd(ctx context.Context, pid peer.ID) (*api.ID, error)
 	// PeerRm removes a current peer from the cluster
 	PeerRm(ctx context.Context, pid peer.ID) error
+	// PeerRmDryRun reports the impact of removing a peer without
+	// removing it.
+	PeerRmDryRun(ctx context.Context, pid peer.ID) (*api.PeerRemoveReport, error)
+	// PeerMaintenance enables or disables the maintenance mode of a
+	// peer. Peers in maintenance receive no new allocations. When
+	// repin is set, the pins of the peer are re-allocated to others.
+	PeerMaintenance(ctx context.Context, pid peer.ID, enabled, repin bool) error
+	// PeerActivate takes a peer out of standby mode, so that it starts
+	// receiving allocations.
+	PeerActivate(ctx context.Context, pid peer.ID) error
+	// KnownPeers returns the cluster peers in the peerstore of the
+	// contacted peer, with their names, addresses and tags.
+	KnownPeers(ctx context.Context) ([]*api.KnownPeer, error)
+	// ImportKnownPeers adds the given peers to the peerstore of the
+	// contacted peer.
+	ImportKnownPeers(ctx context.Context, known []*api.KnownPeer) error
 
 	// Add imports files to the cluster from the given paths.
 	Add(ctx context.Context, paths []string, params *api.AddParams, out chan<- *api.AddedOutput) error
@@ -64,8 +84,26 @@ type Client interface {
 	// Pin tracks a Cid with the given replication factor and a name for
 	// human-friendliness.
 	Pin(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error)
+	// StreamPins pins every Cid received on the in channel, with the
+	// given options, over a single long-lived connection. An
+	// acknowledgement is sent on the out channel for every Cid, in the
+	// same order, once it is committed. The server stops reading Cids
+	// while its pin queue is too long. StreamPins returns when the in
+	// channel is closed and all Cids have been acknowledged, and closes
+	// the out channel.
+	StreamPins(ctx context.Context, in <-chan cid.Cid, opts api.PinOptions, out chan<- *api.PinAck) error
+	// PinManifest pins the entries of a manifest, in order, with the
+	// given options overridden by those of each entry. It returns an
+	// acknowledgement for every entry.
+	PinManifest(ctx context.Context, entries []*api.ManifestEntry, opts api.PinOptions) ([]*api.PinAck, error)
 	// Unpin untracks a Cid from cluster.
 	Unpin(ctx context.Context, ci cid.Cid) (*api.Pin, error)
+	// ForcePin pins a Cid like Pin, but it also allows to lift the
+	// protection of a protected pin.
+	ForcePin(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error)
+	// ForceUnpin untracks a Cid from cluster even if it is protected.
+	// Only admins can force unpins.
+	ForceUnpin(ctx context.Context, ci cid.Cid) (*api.Pin, error)
 
 	// PinPath resolves given path into a cid and performs the pin operation.
 	PinPath(ctx context.Context, path string, opts api.PinOptions) (*api.Pin, error)
@@ -76,8 +114,20 @@ type Client interface {
 	// Allocations returns the consensus state listing all tracked items
 	// and the peers that should be pinning them.
 	Allocations(ctx context.Context, filter api.PinType) ([]*api.Pin, error)
+	// AllocationsQuery returns the items in the consensus state which
+	// match the given query.
+	AllocationsQuery(ctx context.Context, query *api.PinQuery) ([]*api.Pin, error)
+	// PinsView returns a page of the items in the consensus state which
+	// match the given query, along with their status in the cluster.
+	PinsView(ctx context.Context, query *api.PinViewQuery) (*api.PinViewPage, error)
 	// Allocation returns the current allocations for a given Cid.
 	Allocation(ctx context.Context, ci cid.Cid) (*api.Pin, error)
+	// AllocationExclusions returns the peers excluded from new
+	// allocations at runtime in the contacted peer.
+	AllocationExclusions(ctx context.Context) (*api.AllocationExclusions, error)
+	// SetAllocationExclusions replaces the peers excluded from new
+	// allocations at runtime in all cluster peers.
+	SetAllocationExclusions(ctx context.Context, ex *api.AllocationExclusions) error
 
 	// Status returns the current ipfs state for a given Cid. If local is true,
 	// the information affects only the current peer, otherwise the information
@@ -85,6 +135,10 @@ type Client interface {
 	Status(ctx context.Context, ci cid.Cid, local bool) (*api.GlobalPinInfo, error)
 	// StatusAll gathers Status() for all tracked items.
 	StatusAll(ctx context.Context, filter api.TrackerStatus, local bool) ([]*api.GlobalPinInfo, error)
+	// StatusChanges returns the status of the items whose status changed
+	// in any cluster peer after the given time. The returned Until time
+	// should be used as since in the next call.
+	StatusChanges(ctx context.Context, since time.Time) (*api.StatusChanges, error)
 
 	// Recover retriggers pin or unpin ipfs operations for a Cid in error
 	// state.  If local is true, the operation is limited to the current
@@ -94,6 +148,36 @@ type Client interface {
 	// local is true, the operation is limited to the current peer.
 	// Otherwise, it happens everywhere.
 	RecoverAll(ctx context.Context, local bool) ([]*api.GlobalPinInfo, error)
+	// ScheduleRecover registers a recover schedule in the contacted
+	// peer, which recovers the items in error state every rs.Interval
+	// until none remain or rs.MaxAttempts is reached.
+	ScheduleRecover(ctx context.Context, rs *api.RecoverSchedule) (*api.RecoverSchedule, error)
+	// RecoverSchedules lists the recover schedules of the contacted peer.
+	RecoverSchedules(ctx context.Context) ([]*api.RecoverSchedule, error)
+	// CancelRecoverSchedule cancels a recover schedule of the contacted
+	// peer.
+	CancelRecoverSchedule(ctx context.Context, id string) error
+
+	// SubmitJob queues a long-running operation in the contacted peer.
+	SubmitJob(ctx context.Context, j *api.Job) (*api.Job, error)
+	// Jobs lists the jobs of the contacted peer.
+	Jobs(ctx context.Context) ([]*api.Job, error)
+	// Job returns the job with the given ID from the contacted peer.
+	Job(ctx context.Context, id string) (*api.Job, error)
+	// CancelJob cancels a queued or running job of the contacted peer.
+	CancelJob(ctx context.Context, id string) error
+
+	// PinCollection pins all the CIDs of a collection with the given
+	// options, or none of them.
+	PinCollection(ctx context.Context, col *api.Collection) (*api.Collection, error)
+	// UnpinCollection unpins all the CIDs of a collection.
+	UnpinCollection(ctx context.Context, name string) (*api.Collection, error)
+	// Collections lists the collections in the shared pinset.
+	Collections(ctx context.Context) ([]*api.Collection, error)
+	// Collection returns the CIDs of the given collection.
+	Collection(ctx context.Context, name string) (*api.Collection, error)
+	// CollectionStatus returns the status of every CID in a collection.
+	CollectionStatus(ctx context.Context, name string) ([]*api.GlobalPinInfo, error)
 
 	// Version returns the ipfs-cluster peer's version.
 	Version(context.Context) (*api.Version, error)
@@ -112,10 +196,75 @@ type Client interface {
 	// MetricNames returns the list of metric types.
 	MetricNames(ctx context.Context) ([]string, error)
 
+	// Alerts returns the alerts which are currently active in the
+	// cluster peer.
+	Alerts(ctx context.Context) ([]*api.Alert, error)
+
+	// TrackerOperations returns the operations which the pin tracker of
+	// the cluster peer is currently keeping track of.
+	TrackerOperations(ctx context.Context) ([]*api.TrackerOperation, error)
+
+	// RepinProgress returns the progress of the re-allocations of pins
+	// of down or removed peers.
+	RepinProgress(ctx context.Context) ([]*api.RepinProgress, error)
+
+	// Stats returns a compact summary of the health of the cluster and
+	// of every peer, meant for dashboards.
+	Stats(ctx context.Context) (*api.ClusterStats, error)
+
+	// QuotaUsage returns the pins in every namespace and made by every
+	// user, along with their quotas.
+	QuotaUsage(ctx context.Context) ([]*api.QuotaUsage, error)
+
+	// ShardsGC unpins the shards of sharded content which are not
+	// referenced by any pin and returns them.
+	ShardsGC(ctx context.Context) ([]*api.Pin, error)
+
+	// StateCheckpoint writes the shared state as a DAG to IPFS and pins
+	// it in the cluster. It returns the pin of the checkpoint.
+	StateCheckpoint(ctx context.Context) (*api.Pin, error)
+	// StateCheckpoints returns the pins of the state checkpoints.
+	StateCheckpoints(ctx context.Context) ([]*api.Pin, error)
+	// StateCheckpointPins returns the pins in the state checkpoint with
+	// the given root.
+	StateCheckpointPins(ctx context.Context, root cid.Cid) ([]*api.Pin, error)
+
 	// RepoGC runs garbage collection on IPFS daemons of cluster peers and
 	// returns collected CIDs. If local is true, it would garbage collect
 	// only on contacted peer, otherwise on all peers' IPFS daemons.
 	RepoGC(ctx context.Context, local bool) (*api.GlobalRepoGC, error)
+	// ProvideStrategy returns the provide strategy followed by the
+	// contacted peer.
+	ProvideStrategy(ctx context.Context) (*api.ProvideStrategy, error)
+	// SetProvideStrategy sets the provide strategy of all cluster peers,
+	// which configure the reprovider of their IPFS daemons accordingly.
+	SetProvideStrategy(ctx context.Context, ps *api.ProvideStrategy) error
+
+	// Prefetch asks the given cluster peers (all of them if none are
+	// given) to fetch the DAG under a Cid into their IPFS repositories
+	// without pinning it. It returns once all of them are done.
+	Prefetch(ctx context.Context, ci cid.Cid, peers []peer.ID) ([]*api.PrefetchResult, error)
+
+	// SetLogLevel changes the log level of a logging facility in the
+	// contacted peer.
+	SetLogLevel(ctx context.Context, facility, level string) error
+
+	// Upgrade upgrades all cluster peers to the given release, one at a
+	// time, restarting each of them.
+	Upgrade(ctx context.Context, opts api.UpgradeOptions) error
+
+	// BeginSecretRotation makes all cluster peers accept connections
+	// using the given cluster secret, in addition to the current one.
+	BeginSecretRotation(ctx context.Context, secret []byte) error
+
+	// FinalizeSecretRotation makes all cluster peers use the secret given
+	// to BeginSecretRotation as their cluster secret.
+	FinalizeSecretRotation(ctx context.Context) error
+
+	// PushConfig makes all cluster peers apply and save the given
+	// options of configuration sections. Sections without a
+	// configuration take the pushable options of the contacted peer.
+	PushConfig(ctx context.Context, sections []*api.ConfigSection) error
 }
 
 // Config allows to configure the parameters to connect
@@ -160,6 +309,12 @@ type Config struct {
 	// hosts.
 	DisableKeepAlives bool
 
+	// APIPrefix is the path prefix of the REST API endpoints (i.e.
+	// "/v1"). "/" uses the unprefixed legacy endpoints. When empty, the
+	// /v1 endpoints are used if the peer serves them, falling back to
+	// the legacy endpoints of older peers otherwise.
+	APIPrefix string
+
 	// LogLevel defines the verbosity of the logging facility
 	LogLevel string
 }
@@ -175,6 +330,10 @@ type defaultClient struct {
 	hostname  string
 	client    *http.Client
 	p2p       host.Host
+
+	prefixMux        sync.Mutex
+	prefix           string
+	prefixNegotiated bool
 }
 
 // NewDefaultClient initializes a client given a Config.
@@ -3,6 +3,8 @@ package client
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -307,3 +309,61 @@ func TestIPFS(t *testing.T) {
 		t.Error("pin type unexpected")
 	}
 }
+
+func TestAPIPrefixNegotiation(t *testing.T) {
+	ctx := context.Background()
+	legacy := true
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if legacy && strings.HasPrefix(r.URL.Path, apiVersionPrefix+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"version": "0.0.1"}`))
+	}))
+	defer srv.Close()
+
+	newClient := func(prefix string) Client {
+		hostPort := strings.Split(strings.TrimPrefix(srv.URL, "http://"), ":")
+		c, err := NewDefaultClient(&Config{
+			Host:              hostPort[0],
+			Port:              hostPort[1],
+			DisableKeepAlives: true,
+			APIPrefix:         prefix,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	c := newClient("")
+	for i := 0; i < 2; i++ {
+		if _, err := c.Version(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fmt.Sprint(paths) != "[/v1/version /version /version]" {
+		t.Error("expected a single check and the legacy endpoints:", paths)
+	}
+
+	legacy = false
+	paths = nil
+	c = newClient("")
+	if _, err := c.Version(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(paths) != "[/v1/version /v1/version]" {
+		t.Error("expected the /v1 endpoints:", paths)
+	}
+
+	paths = nil
+	c = newClient("/")
+	if _, err := c.Version(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(paths) != "[/version]" {
+		t.Error("expected the configured prefix to be used without check:", paths)
+	}
+}
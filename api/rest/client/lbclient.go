@@ -3,6 +3,7 @@ package client
 import (
 	"context"
 	"sync/atomic"
+	"time"
 
 	cid "github.com/ipfs/go-cid"
 	shell "github.com/ipfs/go-ipfs-api"
@@ -97,7 +98,9 @@ func (lc *loadBalancingClient) retry(count int, call func(Client) error) error {
 		return err
 	}
 
-	if apiErr.Code != 0 {
+	// Requests which reached a peer are not retried, unless the peer
+	// refused them because it is shutting down.
+	if apiErr.Code != 0 && apiErr.ErrorCode != api.ErrCodeShuttingDown {
 		return err
 	}
 
@@ -135,6 +138,19 @@ func (lc *loadBalancingClient) Peers(ctx context.Context) ([]*api.ID, error) {
 	return peers, err
 }
 
+// PeerNames returns the peernames of the cluster peers, indexed by peer ID.
+func (lc *loadBalancingClient) PeerNames(ctx context.Context) (map[string]string, error) {
+	var names map[string]string
+	call := func(c Client) error {
+		var err error
+		names, err = c.PeerNames(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return names, err
+}
+
 // PeerAdd adds a new peer to the cluster.
 func (lc *loadBalancingClient) PeerAdd(ctx context.Context, pid peer.ID) (*api.ID, error) {
 	var id *api.ID
@@ -157,6 +173,60 @@ func (lc *loadBalancingClient) PeerRm(ctx context.Context, id peer.ID) error {
 	return lc.retry(0, call)
 }
 
+// PeerRmDryRun reports the impact of removing a peer without removing it.
+func (lc *loadBalancingClient) PeerRmDryRun(ctx context.Context, id peer.ID) (*api.PeerRemoveReport, error) {
+	var report *api.PeerRemoveReport
+	call := func(c Client) error {
+		var err error
+		report, err = c.PeerRmDryRun(ctx, id)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return report, err
+}
+
+// PeerMaintenance enables or disables the maintenance mode of a peer.
+func (lc *loadBalancingClient) PeerMaintenance(ctx context.Context, id peer.ID, enabled, repin bool) error {
+	call := func(c Client) error {
+		return c.PeerMaintenance(ctx, id, enabled, repin)
+	}
+
+	return lc.retry(0, call)
+}
+
+// PeerActivate takes a peer out of standby mode.
+func (lc *loadBalancingClient) PeerActivate(ctx context.Context, id peer.ID) error {
+	call := func(c Client) error {
+		return c.PeerActivate(ctx, id)
+	}
+
+	return lc.retry(0, call)
+}
+
+// KnownPeers returns the cluster peers in the peerstore of the contacted
+// peer, with their names, addresses and tags.
+func (lc *loadBalancingClient) KnownPeers(ctx context.Context) ([]*api.KnownPeer, error) {
+	var known []*api.KnownPeer
+	call := func(c Client) error {
+		var err error
+		known, err = c.KnownPeers(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return known, err
+}
+
+// ImportKnownPeers adds the given peers to the peerstore of the contacted
+// peer.
+func (lc *loadBalancingClient) ImportKnownPeers(ctx context.Context, known []*api.KnownPeer) error {
+	call := func(c Client) error {
+		return c.ImportKnownPeers(ctx, known)
+	}
+	return lc.retry(0, call)
+}
+
 // Pin tracks a Cid with the given replication factor and a name for
 // human-friendliness.
 func (lc *loadBalancingClient) Pin(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error) {
@@ -171,6 +241,31 @@ func (lc *loadBalancingClient) Pin(ctx context.Context, ci cid.Cid, opts api.Pin
 	return pin, err
 }
 
+// StreamPins pins every Cid received on the in channel over a single
+// long-lived connection and sends an acknowledgement for each on the out
+// channel.
+func (lc *loadBalancingClient) StreamPins(ctx context.Context, in <-chan cid.Cid, opts api.PinOptions, out chan<- *api.PinAck) error {
+	call := func(c Client) error {
+		return c.StreamPins(ctx, in, opts, out)
+	}
+
+	return lc.retry(0, call)
+}
+
+// PinManifest pins the entries of a manifest, in order, with the given
+// options overridden by those of each entry.
+func (lc *loadBalancingClient) PinManifest(ctx context.Context, entries []*api.ManifestEntry, opts api.PinOptions) ([]*api.PinAck, error) {
+	var acks []*api.PinAck
+	call := func(c Client) error {
+		var err error
+		acks, err = c.PinManifest(ctx, entries, opts)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return acks, err
+}
+
 // Unpin untracks a Cid from cluster.
 func (lc *loadBalancingClient) Unpin(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
 	var pin *api.Pin
@@ -184,6 +279,33 @@ func (lc *loadBalancingClient) Unpin(ctx context.Context, ci cid.Cid) (*api.Pin,
 	return pin, err
 }
 
+// ForcePin pins a Cid like Pin, but it also allows to lift the protection of
+// a protected pin.
+func (lc *loadBalancingClient) ForcePin(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.ForcePin(ctx, ci, opts)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
+// ForceUnpin untracks a Cid from cluster even if it is protected.
+func (lc *loadBalancingClient) ForceUnpin(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.ForceUnpin(ctx, ci)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pin, err
+}
+
 // PinPath allows to pin an element by the given IPFS path.
 func (lc *loadBalancingClient) PinPath(ctx context.Context, path string, opts api.PinOptions) (*api.Pin, error) {
 	var pin *api.Pin
@@ -225,6 +347,34 @@ func (lc *loadBalancingClient) Allocations(ctx context.Context, filter api.PinTy
 	return pins, err
 }
 
+// AllocationsQuery returns the items in the consensus state which match
+// the given query.
+func (lc *loadBalancingClient) AllocationsQuery(ctx context.Context, query *api.PinQuery) ([]*api.Pin, error) {
+	var pins []*api.Pin
+	call := func(c Client) error {
+		var err error
+		pins, err = c.AllocationsQuery(ctx, query)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pins, err
+}
+
+// PinsView returns a page of the items in the consensus state which match
+// the given query, along with their status in the cluster.
+func (lc *loadBalancingClient) PinsView(ctx context.Context, query *api.PinViewQuery) (*api.PinViewPage, error) {
+	var page *api.PinViewPage
+	call := func(c Client) error {
+		var err error
+		page, err = c.PinsView(ctx, query)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return page, err
+}
+
 // Allocation returns the current allocations for a given Cid.
 func (lc *loadBalancingClient) Allocation(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
 	var pin *api.Pin
@@ -238,6 +388,29 @@ func (lc *loadBalancingClient) Allocation(ctx context.Context, ci cid.Cid) (*api
 	return pin, err
 }
 
+// AllocationExclusions returns the peers excluded from new allocations at
+// runtime in the contacted peer.
+func (lc *loadBalancingClient) AllocationExclusions(ctx context.Context) (*api.AllocationExclusions, error) {
+	var ex *api.AllocationExclusions
+	call := func(c Client) error {
+		var err error
+		ex, err = c.AllocationExclusions(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return ex, err
+}
+
+// SetAllocationExclusions replaces the peers excluded from new allocations
+// at runtime in all cluster peers.
+func (lc *loadBalancingClient) SetAllocationExclusions(ctx context.Context, ex *api.AllocationExclusions) error {
+	call := func(c Client) error {
+		return c.SetAllocationExclusions(ctx, ex)
+	}
+	return lc.retry(0, call)
+}
+
 // Status returns the current ipfs state for a given Cid. If local is true,
 // the information affects only the current peer, otherwise the information
 // is fetched from all cluster peers.
@@ -270,6 +443,21 @@ func (lc *loadBalancingClient) StatusAll(ctx context.Context, filter api.Tracker
 	return pinInfos, err
 }
 
+// StatusChanges returns the status of the items whose status changed in any
+// cluster peer after the given time. The returned Until time should be used
+// as since in the next call.
+func (lc *loadBalancingClient) StatusChanges(ctx context.Context, since time.Time) (*api.StatusChanges, error) {
+	var changes *api.StatusChanges
+	call := func(c Client) error {
+		var err error
+		changes, err = c.StatusChanges(ctx, since)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return changes, err
+}
+
 // Recover retriggers pin or unpin ipfs operations for a Cid in error state.
 // If local is true, the operation is limited to the current peer, otherwise
 // it happens on every cluster peer.
@@ -300,6 +488,159 @@ func (lc *loadBalancingClient) RecoverAll(ctx context.Context, local bool) ([]*a
 	return pinInfos, err
 }
 
+// ScheduleRecover registers a recover schedule in one of the peers, which
+// recovers the items in error state every rs.Interval until none remain or
+// rs.MaxAttempts is reached.
+func (lc *loadBalancingClient) ScheduleRecover(ctx context.Context, rs *api.RecoverSchedule) (*api.RecoverSchedule, error) {
+	var sched *api.RecoverSchedule
+	call := func(c Client) error {
+		var err error
+		sched, err = c.ScheduleRecover(ctx, rs)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return sched, err
+}
+
+// RecoverSchedules lists the recover schedules of one of the peers.
+// Schedules are kept by the peer where they were registered.
+func (lc *loadBalancingClient) RecoverSchedules(ctx context.Context) ([]*api.RecoverSchedule, error) {
+	var scheds []*api.RecoverSchedule
+	call := func(c Client) error {
+		var err error
+		scheds, err = c.RecoverSchedules(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return scheds, err
+}
+
+// CancelRecoverSchedule cancels a recover schedule of one of the peers.
+func (lc *loadBalancingClient) CancelRecoverSchedule(ctx context.Context, id string) error {
+	call := func(c Client) error {
+		return c.CancelRecoverSchedule(ctx, id)
+	}
+
+	return lc.retry(0, call)
+}
+
+// SubmitJob queues a long-running operation in one of the peers. Jobs are
+// kept by the peer where they were submitted.
+func (lc *loadBalancingClient) SubmitJob(ctx context.Context, j *api.Job) (*api.Job, error) {
+	var queued *api.Job
+	call := func(c Client) error {
+		var err error
+		queued, err = c.SubmitJob(ctx, j)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return queued, err
+}
+
+// Jobs lists the jobs of one of the peers.
+func (lc *loadBalancingClient) Jobs(ctx context.Context) ([]*api.Job, error) {
+	var jobs []*api.Job
+	call := func(c Client) error {
+		var err error
+		jobs, err = c.Jobs(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return jobs, err
+}
+
+// Job returns the job with the given ID from one of the peers.
+func (lc *loadBalancingClient) Job(ctx context.Context, id string) (*api.Job, error) {
+	var j *api.Job
+	call := func(c Client) error {
+		var err error
+		j, err = c.Job(ctx, id)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return j, err
+}
+
+// CancelJob cancels a queued or running job of one of the peers.
+func (lc *loadBalancingClient) CancelJob(ctx context.Context, id string) error {
+	call := func(c Client) error {
+		return c.CancelJob(ctx, id)
+	}
+
+	return lc.retry(0, call)
+}
+
+// PinCollection pins all the CIDs of a collection with the given options,
+// or none of them.
+func (lc *loadBalancingClient) PinCollection(ctx context.Context, col *api.Collection) (*api.Collection, error) {
+	var pinned *api.Collection
+	call := func(c Client) error {
+		var err error
+		pinned, err = c.PinCollection(ctx, col)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return pinned, err
+}
+
+// UnpinCollection unpins all the CIDs of a collection.
+func (lc *loadBalancingClient) UnpinCollection(ctx context.Context, name string) (*api.Collection, error) {
+	var col *api.Collection
+	call := func(c Client) error {
+		var err error
+		col, err = c.UnpinCollection(ctx, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return col, err
+}
+
+// Collections lists the collections in the shared pinset.
+func (lc *loadBalancingClient) Collections(ctx context.Context) ([]*api.Collection, error) {
+	var cols []*api.Collection
+	call := func(c Client) error {
+		var err error
+		cols, err = c.Collections(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return cols, err
+}
+
+// Collection returns the CIDs of the given collection.
+func (lc *loadBalancingClient) Collection(ctx context.Context, name string) (*api.Collection, error) {
+	var col *api.Collection
+	call := func(c Client) error {
+		var err error
+		col, err = c.Collection(ctx, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return col, err
+}
+
+// CollectionStatus returns the status of every CID in a collection.
+func (lc *loadBalancingClient) CollectionStatus(ctx context.Context, name string) ([]*api.GlobalPinInfo, error) {
+	var gpis []*api.GlobalPinInfo
+	call := func(c Client) error {
+		var err error
+		gpis, err = c.CollectionStatus(ctx, name)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return gpis, err
+}
+
 // Version returns the ipfs-cluster peer's version.
 func (lc *loadBalancingClient) Version(ctx context.Context) (*api.Version, error) {
 	var v *api.Version
@@ -354,6 +695,164 @@ func (lc *loadBalancingClient) MetricNames(ctx context.Context) ([]string, error
 	return metricNames, err
 }
 
+// Alerts returns the alerts which are currently active in the cluster
+// peer.
+func (lc *loadBalancingClient) Alerts(ctx context.Context) ([]*api.Alert, error) {
+	var alerts []*api.Alert
+	call := func(c Client) error {
+		var err error
+		alerts, err = c.Alerts(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return alerts, err
+}
+
+// TrackerOperations returns the operations which the pin tracker of the
+// cluster peer is currently keeping track of.
+func (lc *loadBalancingClient) TrackerOperations(ctx context.Context) ([]*api.TrackerOperation, error) {
+	var ops []*api.TrackerOperation
+	call := func(c Client) error {
+		var err error
+		ops, err = c.TrackerOperations(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return ops, err
+}
+
+// RepinProgress returns the progress of the re-allocations of pins of down
+// or removed peers.
+func (lc *loadBalancingClient) RepinProgress(ctx context.Context) ([]*api.RepinProgress, error) {
+	var progress []*api.RepinProgress
+	call := func(c Client) error {
+		var err error
+		progress, err = c.RepinProgress(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return progress, err
+}
+
+// Stats returns a compact summary of the health of the cluster and of every
+// peer, meant for dashboards.
+func (lc *loadBalancingClient) Stats(ctx context.Context) (*api.ClusterStats, error) {
+	var stats *api.ClusterStats
+	call := func(c Client) error {
+		var err error
+		stats, err = c.Stats(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return stats, err
+}
+
+// QuotaUsage returns the pins in every namespace and made by every user,
+// along with their quotas.
+func (lc *loadBalancingClient) QuotaUsage(ctx context.Context) ([]*api.QuotaUsage, error) {
+	var usage []*api.QuotaUsage
+	call := func(c Client) error {
+		var err error
+		usage, err = c.QuotaUsage(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return usage, err
+}
+
+// ShardsGC unpins the shards of sharded content which are not referenced by
+// any pin and returns them.
+func (lc *loadBalancingClient) ShardsGC(ctx context.Context) ([]*api.Pin, error) {
+	var unpinned []*api.Pin
+	call := func(c Client) error {
+		var err error
+		unpinned, err = c.ShardsGC(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return unpinned, err
+}
+
+// ProvideStrategy returns the provide strategy followed by the contacted
+// peer.
+func (lc *loadBalancingClient) ProvideStrategy(ctx context.Context) (*api.ProvideStrategy, error) {
+	var ps *api.ProvideStrategy
+	call := func(c Client) error {
+		var err error
+		ps, err = c.ProvideStrategy(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return ps, err
+}
+
+// SetProvideStrategy sets the provide strategy of all cluster peers, which
+// configure the reprovider of their IPFS daemons accordingly.
+func (lc *loadBalancingClient) SetProvideStrategy(ctx context.Context, ps *api.ProvideStrategy) error {
+	call := func(c Client) error {
+		return c.SetProvideStrategy(ctx, ps)
+	}
+	return lc.retry(0, call)
+}
+
+// StateCheckpoint writes the shared state as a DAG to IPFS and pins it in
+// the cluster. It returns the pin of the checkpoint.
+func (lc *loadBalancingClient) StateCheckpoint(ctx context.Context) (*api.Pin, error) {
+	var pin *api.Pin
+	call := func(c Client) error {
+		var err error
+		pin, err = c.StateCheckpoint(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return pin, err
+}
+
+// StateCheckpoints returns the pins of the state checkpoints.
+func (lc *loadBalancingClient) StateCheckpoints(ctx context.Context) ([]*api.Pin, error) {
+	var checkpoints []*api.Pin
+	call := func(c Client) error {
+		var err error
+		checkpoints, err = c.StateCheckpoints(ctx)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return checkpoints, err
+}
+
+// StateCheckpointPins returns the pins in the state checkpoint with the
+// given root.
+func (lc *loadBalancingClient) StateCheckpointPins(ctx context.Context, root cid.Cid) ([]*api.Pin, error) {
+	var pins []*api.Pin
+	call := func(c Client) error {
+		var err error
+		pins, err = c.StateCheckpointPins(ctx, root)
+		return err
+	}
+
+	err := lc.retry(0, call)
+
+	return pins, err
+}
+
 // RepoGC runs garbage collection on IPFS daemons of cluster peers and
 // returns collected CIDs. If local is true, it would garbage collect
 // only on contacted peer, otherwise on all peers' IPFS daemons.
@@ -370,6 +869,68 @@ func (lc *loadBalancingClient) RepoGC(ctx context.Context, local bool) (*api.Glo
 	return repoGC, err
 }
 
+// Prefetch asks the given cluster peers (all of them if none are given) to
+// fetch the DAG under a Cid into their IPFS repositories without pinning it.
+// It returns once all of them are done.
+func (lc *loadBalancingClient) Prefetch(ctx context.Context, ci cid.Cid, peers []peer.ID) ([]*api.PrefetchResult, error) {
+	var results []*api.PrefetchResult
+
+	call := func(c Client) error {
+		var err error
+		results, err = c.Prefetch(ctx, ci, peers)
+		return err
+	}
+
+	err := lc.retry(0, call)
+	return results, err
+}
+
+// SetLogLevel changes the log level of a logging facility in the contacted
+// peer.
+func (lc *loadBalancingClient) SetLogLevel(ctx context.Context, facility, level string) error {
+	call := func(c Client) error {
+		return c.SetLogLevel(ctx, facility, level)
+	}
+	return lc.retry(0, call)
+}
+
+// Upgrade upgrades all cluster peers to the given release, one at a
+// time, restarting each of them.
+func (lc *loadBalancingClient) Upgrade(ctx context.Context, opts api.UpgradeOptions) error {
+	call := func(c Client) error {
+		return c.Upgrade(ctx, opts)
+	}
+	return lc.retry(0, call)
+}
+
+// BeginSecretRotation makes all cluster peers accept connections using the
+// given cluster secret, in addition to the current one.
+func (lc *loadBalancingClient) BeginSecretRotation(ctx context.Context, secret []byte) error {
+	call := func(c Client) error {
+		return c.BeginSecretRotation(ctx, secret)
+	}
+	return lc.retry(0, call)
+}
+
+// FinalizeSecretRotation makes all cluster peers use the secret given to
+// BeginSecretRotation as their cluster secret.
+func (lc *loadBalancingClient) FinalizeSecretRotation(ctx context.Context) error {
+	call := func(c Client) error {
+		return c.FinalizeSecretRotation(ctx)
+	}
+	return lc.retry(0, call)
+}
+
+// PushConfig makes all cluster peers apply and save the given configuration
+// sections. Sections without a configuration take the one of the contacted
+// peer.
+func (lc *loadBalancingClient) PushConfig(ctx context.Context, sections []*api.ConfigSection) error {
+	call := func(c Client) error {
+		return c.PushConfig(ctx, sections)
+	}
+	return lc.retry(0, call)
+}
+
 // Add imports files to the cluster from the given paths. A path can
 // either be a local filesystem location or an web url (http:// or https://).
 // In the latter case, the destination will be downloaded with a GET request.
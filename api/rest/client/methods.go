@@ -3,14 +3,19 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
@@ -20,6 +25,7 @@ import (
 	gopath "github.com/ipfs/go-path"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 
+	websocket "github.com/gorilla/websocket"
 	"go.opencensus.io/trace"
 )
 
@@ -43,10 +49,24 @@ func (c *defaultClient) Peers(ctx context.Context) ([]*api.ID, error) {
 	return ids, err
 }
 
+// PeerNames returns the peernames of the cluster peers, indexed by peer ID.
+func (c *defaultClient) PeerNames(ctx context.Context) (map[string]string, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PeerNames")
+	defer span.End()
+
+	var names map[string]string
+	err := c.do(ctx, "GET", "/peers/names", nil, nil, &names)
+	return names, err
+}
+
 type peerAddBody struct {
 	PeerID string `json:"peer_id"`
 }
 
+type secretRotationBody struct {
+	Secret string `json:"secret"`
+}
+
 // PeerAdd adds a new peer to the cluster.
 func (c *defaultClient) PeerAdd(ctx context.Context, pid peer.ID) (*api.ID, error) {
 	ctx, span := trace.StartSpan(ctx, "client/PeerAdd")
@@ -72,6 +92,63 @@ func (c *defaultClient) PeerRm(ctx context.Context, id peer.ID) error {
 	return c.do(ctx, "DELETE", fmt.Sprintf("/peers/%s", id.Pretty()), nil, nil, nil)
 }
 
+// PeerRmDryRun reports the impact of removing a peer without removing it.
+func (c *defaultClient) PeerRmDryRun(ctx context.Context, id peer.ID) (*api.PeerRemoveReport, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PeerRmDryRun")
+	defer span.End()
+
+	var report api.PeerRemoveReport
+	err := c.do(ctx, "DELETE", fmt.Sprintf("/peers/%s?dry-run=true", id.Pretty()), nil, nil, &report)
+	return &report, err
+}
+
+// PeerMaintenance enables or disables the maintenance mode of a peer. Peers
+// in maintenance receive no new allocations. When repin is set, the pins of
+// the peer are re-allocated to others.
+func (c *defaultClient) PeerMaintenance(ctx context.Context, id peer.ID, enabled, repin bool) error {
+	ctx, span := trace.StartSpan(ctx, "client/PeerMaintenance")
+	defer span.End()
+
+	path := fmt.Sprintf("/peers/%s/maintenance?enabled=%t&repin=%t", id.Pretty(), enabled, repin)
+	return c.do(ctx, "POST", path, nil, nil, nil)
+}
+
+// PeerActivate takes a peer out of standby mode, so that it starts receiving
+// allocations.
+func (c *defaultClient) PeerActivate(ctx context.Context, id peer.ID) error {
+	ctx, span := trace.StartSpan(ctx, "client/PeerActivate")
+	defer span.End()
+
+	return c.do(ctx, "POST", fmt.Sprintf("/peers/%s/activate", id.Pretty()), nil, nil, nil)
+}
+
+// KnownPeers returns the cluster peers in the peerstore of the contacted
+// peer, with their names, addresses and tags.
+func (c *defaultClient) KnownPeers(ctx context.Context) ([]*api.KnownPeer, error) {
+	ctx, span := trace.StartSpan(ctx, "client/KnownPeers")
+	defer span.End()
+
+	var known []*api.KnownPeer
+	err := c.do(ctx, "GET", "/peerstore", nil, nil, &known)
+	return known, err
+}
+
+// ImportKnownPeers adds the given peers to the peerstore of the contacted
+// peer.
+func (c *defaultClient) ImportKnownPeers(ctx context.Context, known []*api.KnownPeer) error {
+	ctx, span := trace.StartSpan(ctx, "client/ImportKnownPeers")
+	defer span.End()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(known)
+	if err != nil {
+		return err
+	}
+
+	return c.do(ctx, "POST", "/peerstore", nil, &buf, nil)
+}
+
 // Pin tracks a Cid with the given replication factor and a name for
 // human-friendliness.
 func (c *defaultClient) Pin(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error) {
@@ -101,6 +178,97 @@ func (c *defaultClient) Pin(ctx context.Context, ci cid.Cid, opts api.PinOptions
 	return &pin, nil
 }
 
+// PinManifest pins the entries of a manifest, in order, with the given
+// options overridden by those of each entry. It returns an acknowledgement
+// for every entry.
+func (c *defaultClient) PinManifest(ctx context.Context, entries []*api.ManifestEntry, opts api.PinOptions) ([]*api.PinAck, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinManifest")
+	defer span.End()
+
+	query, err := opts.ToQuery()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(entries); err != nil {
+		return nil, err
+	}
+
+	var acks []*api.PinAck
+	err = c.do(ctx, "POST", "/pins/manifest?"+query, nil, &buf, &acks)
+	return acks, err
+}
+
+// StreamPins pins every Cid received on the in channel over a single
+// websocket connection and sends an acknowledgement for each on the out
+// channel, which is closed when done.
+func (c *defaultClient) StreamPins(ctx context.Context, in <-chan cid.Cid, opts api.PinOptions, out chan<- *api.PinAck) error {
+	ctx, span := trace.StartSpan(ctx, "client/StreamPins")
+	defer span.End()
+	defer close(out)
+
+	query, err := opts.ToQuery()
+	if err != nil {
+		return err
+	}
+	conn, err := c.dialWebsocket(ctx, "/pins/stream?"+query)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The sender writes Cids until the input is exhausted, while
+	// we read acknowledgements below.
+	var sent int64
+	stop := make(chan struct{})
+	sendDone := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				sendDone <- errors.New("stream interrupted before all pins were sent")
+				return
+			case <-ctx.Done():
+				conn.Close()
+				sendDone <- ctx.Err()
+				return
+			case ci, ok := <-in:
+				if !ok {
+					sendDone <- conn.WriteMessage(
+						websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+					)
+					return
+				}
+				err := conn.WriteMessage(websocket.TextMessage, []byte(ci.String()))
+				if err != nil {
+					sendDone <- err
+					return
+				}
+				atomic.AddInt64(&sent, 1)
+			}
+		}
+	}()
+
+	var acked int64
+	for {
+		var ack api.PinAck
+		readErr := conn.ReadJSON(&ack)
+		if readErr != nil {
+			close(stop)
+			if err := <-sendDone; err != nil {
+				return err
+			}
+			if acked < atomic.LoadInt64(&sent) {
+				return readErr
+			}
+			return nil
+		}
+		acked++
+		out <- &ack
+	}
+}
+
 // Unpin untracks a Cid from cluster.
 func (c *defaultClient) Unpin(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
 	ctx, span := trace.StartSpan(ctx, "client/Unpin")
@@ -113,6 +281,47 @@ func (c *defaultClient) Unpin(ctx context.Context, ci cid.Cid) (*api.Pin, error)
 	return &pin, nil
 }
 
+// ForcePin pins a Cid like Pin, but it also allows to lift the protection of
+// a protected pin.
+func (c *defaultClient) ForcePin(ctx context.Context, ci cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ForcePin")
+	defer span.End()
+
+	query, err := opts.ToQuery()
+	if err != nil {
+		return nil, err
+	}
+	var pin api.Pin
+	err = c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf(
+			"/pins/%s?force=true&%s",
+			ci.String(),
+			query,
+		),
+		nil,
+		nil,
+		&pin,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
+// ForceUnpin untracks a Cid from cluster even if it is protected.
+func (c *defaultClient) ForceUnpin(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ForceUnpin")
+	defer span.End()
+	var pin api.Pin
+	err := c.do(ctx, "DELETE", fmt.Sprintf("/pins/%s?force=true", ci.String()), nil, nil, &pin)
+	if err != nil {
+		return nil, err
+	}
+	return &pin, nil
+}
+
 // PinPath allows to pin an element by the given IPFS path.
 func (c *defaultClient) PinPath(ctx context.Context, path string, opts api.PinOptions) (*api.Pin, error) {
 	ctx, span := trace.StartSpan(ctx, "client/PinPath")
@@ -191,6 +400,31 @@ func (c *defaultClient) Allocations(ctx context.Context, filter api.PinType) ([]
 	return pins, err
 }
 
+// AllocationsQuery returns the items in the consensus state which match
+// the given query.
+func (c *defaultClient) AllocationsQuery(ctx context.Context, query *api.PinQuery) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/AllocationsQuery")
+	defer span.End()
+
+	var pins []*api.Pin
+	err := c.do(ctx, "GET", fmt.Sprintf("/allocations?%s", query.ToQuery()), nil, nil, &pins)
+	return pins, err
+}
+
+// PinsView returns a page of the items in the consensus state which match
+// the given query, along with their status in the cluster.
+func (c *defaultClient) PinsView(ctx context.Context, query *api.PinViewQuery) (*api.PinViewPage, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinsView")
+	defer span.End()
+
+	var page api.PinViewPage
+	err := c.do(ctx, "GET", fmt.Sprintf("/pins/view?%s", query.ToQuery()), nil, nil, &page)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
 // Allocation returns the current allocations for a given Cid.
 func (c *defaultClient) Allocation(ctx context.Context, ci cid.Cid) (*api.Pin, error) {
 	ctx, span := trace.StartSpan(ctx, "client/Allocation")
@@ -201,6 +435,31 @@ func (c *defaultClient) Allocation(ctx context.Context, ci cid.Cid) (*api.Pin, e
 	return &pin, err
 }
 
+// AllocationExclusions returns the peers excluded from new allocations at
+// runtime in the contacted peer. The exclusions in its configuration are
+// not included.
+func (c *defaultClient) AllocationExclusions(ctx context.Context) (*api.AllocationExclusions, error) {
+	ctx, span := trace.StartSpan(ctx, "client/AllocationExclusions")
+	defer span.End()
+
+	var ex api.AllocationExclusions
+	err := c.do(ctx, "GET", "/allocations/exclusions", nil, nil, &ex)
+	return &ex, err
+}
+
+// SetAllocationExclusions replaces the peers excluded from new allocations
+// at runtime in all cluster peers. Empty exclusions clear them.
+func (c *defaultClient) SetAllocationExclusions(ctx context.Context, ex *api.AllocationExclusions) error {
+	ctx, span := trace.StartSpan(ctx, "client/SetAllocationExclusions")
+	defer span.End()
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(ex); err != nil {
+		return err
+	}
+	return c.do(ctx, "POST", "/allocations/exclusions", nil, &buf, nil)
+}
+
 // Status returns the current ipfs state for a given Cid. If local is true,
 // the information affects only the current peer, otherwise the information
 // is fetched from all cluster peers.
@@ -250,6 +509,30 @@ func (c *defaultClient) StatusAll(ctx context.Context, filter api.TrackerStatus,
 	return gpis, err
 }
 
+// StatusChanges returns the status of the items whose status changed in any
+// cluster peer after the given time. The returned Until time should be used
+// as since in the next call.
+func (c *defaultClient) StatusChanges(ctx context.Context, since time.Time) (*api.StatusChanges, error) {
+	ctx, span := trace.StartSpan(ctx, "client/StatusChanges")
+	defer span.End()
+
+	sinceStr, err := since.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes api.StatusChanges
+	err = c.do(
+		ctx,
+		"GET",
+		fmt.Sprintf("/pins/changes?since=%s", url.QueryEscape(string(sinceStr))),
+		nil,
+		nil,
+		&changes,
+	)
+	return &changes, err
+}
+
 // Recover retriggers pin or unpin ipfs operations for a Cid in error state.
 // If local is true, the operation is limited to the current peer, otherwise
 // it happens on every cluster peer.
@@ -274,6 +557,145 @@ func (c *defaultClient) RecoverAll(ctx context.Context, local bool) ([]*api.Glob
 	return gpis, err
 }
 
+// ScheduleRecover registers a recover schedule in the contacted peer, which
+// recovers the items in error state every rs.Interval until none remain or
+// rs.MaxAttempts is reached. When rs.Cid is undefined, all tracked items are
+// recovered.
+func (c *defaultClient) ScheduleRecover(ctx context.Context, rs *api.RecoverSchedule) (*api.RecoverSchedule, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ScheduleRecover")
+	defer span.End()
+
+	query := url.Values{}
+	if rs.Cid.Defined() {
+		query.Set("cid", rs.Cid.String())
+	}
+	query.Set("local", fmt.Sprintf("%t", rs.Local))
+	query.Set("interval", rs.Interval.String())
+	query.Set("max_attempts", fmt.Sprintf("%d", rs.MaxAttempts))
+
+	var sched api.RecoverSchedule
+	err := c.do(ctx, "POST", "/recover/schedules?"+query.Encode(), nil, nil, &sched)
+	return &sched, err
+}
+
+// RecoverSchedules lists the recover schedules of the contacted peer.
+func (c *defaultClient) RecoverSchedules(ctx context.Context) ([]*api.RecoverSchedule, error) {
+	ctx, span := trace.StartSpan(ctx, "client/RecoverSchedules")
+	defer span.End()
+
+	var scheds []*api.RecoverSchedule
+	err := c.do(ctx, "GET", "/recover/schedules", nil, nil, &scheds)
+	return scheds, err
+}
+
+// CancelRecoverSchedule cancels a recover schedule of the contacted peer.
+func (c *defaultClient) CancelRecoverSchedule(ctx context.Context, id string) error {
+	ctx, span := trace.StartSpan(ctx, "client/CancelRecoverSchedule")
+	defer span.End()
+
+	return c.do(ctx, "DELETE", "/recover/schedules/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// SubmitJob queues a long-running operation in the contacted peer. Jobs
+// run one after another and survive restarts of the peer.
+func (c *defaultClient) SubmitJob(ctx context.Context, j *api.Job) (*api.Job, error) {
+	ctx, span := trace.StartSpan(ctx, "client/SubmitJob")
+	defer span.End()
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(j); err != nil {
+		return nil, err
+	}
+
+	var queued api.Job
+	err := c.do(ctx, "POST", "/jobs", nil, &buf, &queued)
+	return &queued, err
+}
+
+// Jobs lists the jobs of the contacted peer.
+func (c *defaultClient) Jobs(ctx context.Context) ([]*api.Job, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Jobs")
+	defer span.End()
+
+	var jobs []*api.Job
+	err := c.do(ctx, "GET", "/jobs", nil, nil, &jobs)
+	return jobs, err
+}
+
+// Job returns the job with the given ID from the contacted peer.
+func (c *defaultClient) Job(ctx context.Context, id string) (*api.Job, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Job")
+	defer span.End()
+
+	var j api.Job
+	err := c.do(ctx, "GET", "/jobs/"+url.PathEscape(id), nil, nil, &j)
+	return &j, err
+}
+
+// CancelJob cancels a queued or running job of the contacted peer.
+func (c *defaultClient) CancelJob(ctx context.Context, id string) error {
+	ctx, span := trace.StartSpan(ctx, "client/CancelJob")
+	defer span.End()
+
+	return c.do(ctx, "DELETE", "/jobs/"+url.PathEscape(id), nil, nil, nil)
+}
+
+// PinCollection pins all the CIDs of a collection with the given options,
+// or none of them.
+func (c *defaultClient) PinCollection(ctx context.Context, col *api.Collection) (*api.Collection, error) {
+	ctx, span := trace.StartSpan(ctx, "client/PinCollection")
+	defer span.End()
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(col); err != nil {
+		return nil, err
+	}
+
+	var pinned api.Collection
+	err := c.do(ctx, "POST", "/collections/"+url.PathEscape(col.Name), nil, &buf, &pinned)
+	return &pinned, err
+}
+
+// UnpinCollection unpins all the CIDs of a collection.
+func (c *defaultClient) UnpinCollection(ctx context.Context, name string) (*api.Collection, error) {
+	ctx, span := trace.StartSpan(ctx, "client/UnpinCollection")
+	defer span.End()
+
+	var col api.Collection
+	err := c.do(ctx, "DELETE", "/collections/"+url.PathEscape(name), nil, nil, &col)
+	return &col, err
+}
+
+// Collections lists the collections in the shared pinset.
+func (c *defaultClient) Collections(ctx context.Context) ([]*api.Collection, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Collections")
+	defer span.End()
+
+	var cols []*api.Collection
+	err := c.do(ctx, "GET", "/collections", nil, nil, &cols)
+	return cols, err
+}
+
+// Collection returns the CIDs of the given collection.
+func (c *defaultClient) Collection(ctx context.Context, name string) (*api.Collection, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Collection")
+	defer span.End()
+
+	var col api.Collection
+	err := c.do(ctx, "GET", "/collections/"+url.PathEscape(name), nil, nil, &col)
+	return &col, err
+}
+
+// CollectionStatus returns the status of every CID in a collection.
+func (c *defaultClient) CollectionStatus(ctx context.Context, name string) ([]*api.GlobalPinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "client/CollectionStatus")
+	defer span.End()
+
+	var gpis []*api.GlobalPinInfo
+	err := c.do(ctx, "GET", "/collections/"+url.PathEscape(name)+"/status", nil, nil, &gpis)
+	return gpis, err
+}
+
 // Version returns the ipfs-cluster peer's version.
 func (c *defaultClient) Version(ctx context.Context) (*api.Version, error) {
 	ctx, span := trace.StartSpan(ctx, "client/Version")
@@ -319,6 +741,128 @@ func (c *defaultClient) MetricNames(ctx context.Context) ([]string, error) {
 	return metricsNames, err
 }
 
+// Alerts returns the alerts which are currently active in the cluster
+// peer.
+func (c *defaultClient) Alerts(ctx context.Context) ([]*api.Alert, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Alerts")
+	defer span.End()
+
+	var alerts []*api.Alert
+	err := c.do(ctx, "GET", "/health/alerts", nil, nil, &alerts)
+	return alerts, err
+}
+
+// TrackerOperations returns the operations which the pin tracker of the
+// cluster peer is currently keeping track of.
+func (c *defaultClient) TrackerOperations(ctx context.Context) ([]*api.TrackerOperation, error) {
+	ctx, span := trace.StartSpan(ctx, "client/TrackerOperations")
+	defer span.End()
+
+	var ops []*api.TrackerOperation
+	err := c.do(ctx, "GET", "/tracker/operations", nil, nil, &ops)
+	return ops, err
+}
+
+// RepinProgress returns the progress of the re-allocations of pins of down
+// or removed peers.
+func (c *defaultClient) RepinProgress(ctx context.Context) ([]*api.RepinProgress, error) {
+	ctx, span := trace.StartSpan(ctx, "client/RepinProgress")
+	defer span.End()
+
+	var progress []*api.RepinProgress
+	err := c.do(ctx, "GET", "/health/repinning", nil, nil, &progress)
+	return progress, err
+}
+
+// Stats returns a compact summary of the health of the cluster and of every
+// peer, meant for dashboards.
+func (c *defaultClient) Stats(ctx context.Context) (*api.ClusterStats, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Stats")
+	defer span.End()
+
+	var stats api.ClusterStats
+	err := c.do(ctx, "GET", "/health/stats", nil, nil, &stats)
+	return &stats, err
+}
+
+// QuotaUsage returns the pins in every namespace and made by every user,
+// along with their quotas.
+func (c *defaultClient) QuotaUsage(ctx context.Context) ([]*api.QuotaUsage, error) {
+	ctx, span := trace.StartSpan(ctx, "client/QuotaUsage")
+	defer span.End()
+
+	var usage []*api.QuotaUsage
+	err := c.do(ctx, "GET", "/usage", nil, nil, &usage)
+	return usage, err
+}
+
+// ShardsGC unpins the shards of sharded content which are not referenced by
+// any pin and returns them.
+func (c *defaultClient) ShardsGC(ctx context.Context) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ShardsGC")
+	defer span.End()
+
+	var unpinned []*api.Pin
+	err := c.do(ctx, "POST", "/shards/gc", nil, nil, &unpinned)
+	return unpinned, err
+}
+
+// ProvideStrategy returns the provide strategy followed by the contacted
+// peer.
+func (c *defaultClient) ProvideStrategy(ctx context.Context) (*api.ProvideStrategy, error) {
+	ctx, span := trace.StartSpan(ctx, "client/ProvideStrategy")
+	defer span.End()
+
+	var ps api.ProvideStrategy
+	err := c.do(ctx, "GET", "/ipfs/provide", nil, nil, &ps)
+	return &ps, err
+}
+
+// SetProvideStrategy sets the provide strategy of all cluster peers, which
+// configure the reprovider of their IPFS daemons accordingly.
+func (c *defaultClient) SetProvideStrategy(ctx context.Context, ps *api.ProvideStrategy) error {
+	ctx, span := trace.StartSpan(ctx, "client/SetProvideStrategy")
+	defer span.End()
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(ps); err != nil {
+		return err
+	}
+	return c.do(ctx, "POST", "/ipfs/provide", nil, &buf, nil)
+}
+
+// StateCheckpoint writes the shared state as a DAG to IPFS and pins it in
+// the cluster. It returns the pin of the checkpoint.
+func (c *defaultClient) StateCheckpoint(ctx context.Context) (*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/StateCheckpoint")
+	defer span.End()
+
+	var pin api.Pin
+	err := c.do(ctx, "POST", "/state/checkpoints", nil, nil, &pin)
+	return &pin, err
+}
+
+// StateCheckpoints returns the pins of the state checkpoints.
+func (c *defaultClient) StateCheckpoints(ctx context.Context) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/StateCheckpoints")
+	defer span.End()
+
+	var checkpoints []*api.Pin
+	err := c.do(ctx, "GET", "/state/checkpoints", nil, nil, &checkpoints)
+	return checkpoints, err
+}
+
+// StateCheckpointPins returns the pins in the state checkpoint with the
+// given root.
+func (c *defaultClient) StateCheckpointPins(ctx context.Context, root cid.Cid) ([]*api.Pin, error) {
+	ctx, span := trace.StartSpan(ctx, "client/StateCheckpointPins")
+	defer span.End()
+
+	var pins []*api.Pin
+	err := c.do(ctx, "GET", "/state/checkpoints/"+root.String(), nil, nil, &pins)
+	return pins, err
+}
+
 // RepoGC runs garbage collection on IPFS daemons of cluster peers and
 // returns collected CIDs. If local is true, it would garbage collect
 // only on contacted peer, otherwise on all peers' IPFS daemons.
@@ -339,6 +883,102 @@ func (c *defaultClient) RepoGC(ctx context.Context, local bool) (*api.GlobalRepo
 	return &repoGC, err
 }
 
+// Prefetch asks the given cluster peers (all of them if none are given) to
+// fetch the DAG under a Cid into their IPFS repositories without pinning it.
+// It returns once all of them are done.
+func (c *defaultClient) Prefetch(ctx context.Context, ci cid.Cid, peers []peer.ID) ([]*api.PrefetchResult, error) {
+	ctx, span := trace.StartSpan(ctx, "client/Prefetch")
+	defer span.End()
+
+	path := fmt.Sprintf("/prefetch/%s", ci)
+	if len(peers) > 0 {
+		path += "?peers=" + strings.Join(api.PeersToStrings(peers), ",")
+	}
+
+	var results []*api.PrefetchResult
+	err := c.do(ctx, "POST", path, nil, nil, &results)
+	return results, err
+}
+
+// SetLogLevel changes the log level of a logging facility in the contacted
+// peer.
+func (c *defaultClient) SetLogLevel(ctx context.Context, facility, level string) error {
+	ctx, span := trace.StartSpan(ctx, "client/SetLogLevel")
+	defer span.End()
+
+	return c.do(
+		ctx,
+		"POST",
+		fmt.Sprintf("/log/level/%s/%s", url.PathEscape(facility), url.PathEscape(level)),
+		nil,
+		nil,
+		nil,
+	)
+}
+
+// Upgrade upgrades all cluster peers to the given release, one at a
+// time, restarting each of them.
+func (c *defaultClient) Upgrade(ctx context.Context, opts api.UpgradeOptions) error {
+	ctx, span := trace.StartSpan(ctx, "client/Upgrade")
+	defer span.End()
+
+	q := url.Values{}
+	q.Set("version", opts.Version)
+	if opts.DistURL != "" {
+		q.Set("dist-url", opts.DistURL)
+	}
+	if opts.Force {
+		q.Set("force", "true")
+	}
+
+	return c.do(
+		ctx,
+		"POST",
+		"/upgrade?"+q.Encode(),
+		nil,
+		nil,
+		nil,
+	)
+}
+
+// BeginSecretRotation makes all cluster peers accept connections using the
+// given cluster secret, in addition to the current one.
+func (c *defaultClient) BeginSecretRotation(ctx context.Context, secret []byte) error {
+	ctx, span := trace.StartSpan(ctx, "client/BeginSecretRotation")
+	defer span.End()
+
+	body := secretRotationBody{hex.EncodeToString(secret)}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.Encode(body)
+
+	return c.do(ctx, "POST", "/secret/rotation", nil, &buf, nil)
+}
+
+// FinalizeSecretRotation makes all cluster peers use the secret given to
+// BeginSecretRotation as their cluster secret.
+func (c *defaultClient) FinalizeSecretRotation(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "client/FinalizeSecretRotation")
+	defer span.End()
+
+	return c.do(ctx, "POST", "/secret/rotation/finalize", nil, nil, nil)
+}
+
+// PushConfig makes all cluster peers apply and save the given configuration
+// sections. Sections without a configuration take the one of the contacted
+// peer.
+func (c *defaultClient) PushConfig(ctx context.Context, sections []*api.ConfigSection) error {
+	ctx, span := trace.StartSpan(ctx, "client/PushConfig")
+	defer span.End()
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(sections); err != nil {
+		return err
+	}
+	return c.do(ctx, "POST", "/config/push", nil, &buf, nil)
+}
+
 // WaitFor is a utility function that allows for a caller to wait for a
 // particular status for a CID (as defined by StatusFilterParams).
 // It returns the final status for that CID and an error, if there was.
@@ -548,7 +1188,53 @@ func (c *defaultClient) Add(
 	// If `form` is set to true, the multipart data will have
 	// a Content-Type of 'multipart/form-data', if `form` is false,
 	// the Content-Type will be 'multipart/mixed'.
-	return c.AddMultiFile(ctx, files.NewMultiFileReader(sliceFile, true), params, out)
+	multiFileR := files.NewMultiFileReader(sliceFile, true)
+	if params.PreserveMode || params.PreserveMtime {
+		return c.addMultipart(ctx, withFileMetadata(multiFileR, params), multiFileR.Boundary(), params, out)
+	}
+	return c.AddMultiFile(ctx, multiFileR, params, out)
+}
+
+// withFileMetadata returns a multipart body with the parts of the given
+// reader, adding the mode and the modification time of the local files to
+// their headers, as set to be preserved in the params.
+func withFileMetadata(multiFileR *files.MultiFileReader, params *api.AddParams) io.Reader {
+	pr, pw := io.Pipe()
+	go func() {
+		mr := multipart.NewReader(multiFileR, multiFileR.Boundary())
+		mw := multipart.NewWriter(pw)
+		err := mw.SetBoundary(multiFileR.Boundary())
+		for err == nil {
+			var part *multipart.Part
+			part, err = mr.NextPart()
+			if err != nil {
+				break
+			}
+			header := part.Header
+			if abspath := header.Get("abspath"); abspath != "" {
+				if stat, serr := os.Lstat(abspath); serr == nil && stat.Mode().IsRegular() {
+					if params.PreserveMode {
+						header.Set(api.AddModeHeader, strconv.FormatUint(uint64(stat.Mode().Perm()), 8))
+					}
+					if params.PreserveMtime {
+						mtime := stat.ModTime()
+						header.Set(api.AddMtimeHeader, strconv.FormatInt(mtime.Unix(), 10))
+						header.Set(api.AddMtimeNsecsHeader, strconv.Itoa(mtime.Nanosecond()))
+					}
+				}
+			}
+			var w io.Writer
+			w, err = mw.CreatePart(header)
+			if err == nil {
+				_, err = io.Copy(w, part)
+			}
+		}
+		if err == io.EOF {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
 }
 
 // AddMultiFile imports new files from a MultiFileReader. See Add().
@@ -561,10 +1247,21 @@ func (c *defaultClient) AddMultiFile(
 	ctx, span := trace.StartSpan(ctx, "client/AddMultiFile")
 	defer span.End()
 
+	return c.addMultipart(ctx, multiFileR, multiFileR.Boundary(), params, out)
+}
+
+// addMultipart posts the given multipart body to the add endpoint.
+func (c *defaultClient) addMultipart(
+	ctx context.Context,
+	body io.Reader,
+	boundary string,
+	params *api.AddParams,
+	out chan<- *api.AddedOutput,
+) error {
 	defer close(out)
 
 	headers := make(map[string]string)
-	headers["Content-Type"] = "multipart/form-data; boundary=" + multiFileR.Boundary()
+	headers["Content-Type"] = "multipart/form-data; boundary=" + boundary
 
 	// This method must run with StreamChannels set.
 	params.StreamChannels = true
@@ -592,7 +1289,7 @@ func (c *defaultClient) AddMultiFile(
 		"POST",
 		"/add?"+queryStr,
 		headers,
-		multiFileR,
+		body,
 		handler,
 	)
 	return err
@@ -83,51 +83,778 @@ func TestPeers(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestPeerNames(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		names, err := c.PeerNames(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names[peer.IDB58Encode(test.PeerID1)] != test.PeerName1 {
+			t.Error("expected the peername of PeerID1")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestPeersWithError(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
-	testF := func(t *testing.T, c Client) {
-		addr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/44444")
-		c, _ = NewDefaultClient(&Config{APIAddr: addr, DisableKeepAlives: true})
-		ids, err := c.Peers(ctx)
-		if err == nil {
-			t.Fatal("expected error")
+	testF := func(t *testing.T, c Client) {
+		addr, _ := ma.NewMultiaddr("/ip4/127.0.0.1/tcp/44444")
+		c, _ = NewDefaultClient(&Config{APIAddr: addr, DisableKeepAlives: true})
+		ids, err := c.Peers(ctx)
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if ids != nil {
+			t.Fatal("expected no ids")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPeerAdd(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		id, err := c.PeerAdd(ctx, test.PeerID1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.ID != test.PeerID1 {
+			t.Error("bad peer")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPeerRm(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		err := c.PeerRm(ctx, test.PeerID1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPeerRmDryRun(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		report, err := c.PeerRmDryRun(ctx, test.PeerID1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Peer != test.PeerID1 || report.ReallocatedPins != 2 {
+			t.Error("unexpected report")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPeerMaintenance(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		err := c.PeerMaintenance(ctx, test.PeerID1, true, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.PeerMaintenance(ctx, test.PeerID1, false, true)
+		if err == nil {
+			t.Error("expected an error re-allocating pins when disabling maintenance")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPeerActivate(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		err := c.PeerActivate(ctx, test.PeerID1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestKnownPeers(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		known, err := c.KnownPeers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(known) != 1 || known[0].ID != test.PeerID1 {
+			t.Fatal("unexpected known peers")
+		}
+
+		err = c.ImportKnownPeers(ctx, known)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPin(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		opts := types.PinOptions{
+			ReplicationFactorMin: 6,
+			ReplicationFactorMax: 7,
+			Name:                 "hello there",
+		}
+		_, err := c.Pin(ctx, test.Cid1, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPinManifest(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		entries := []*types.ManifestEntry{
+			{Cid: test.Cid1, Name: "one", Replication: 2},
+			{Cid: test.ErrorCid},
+		}
+		acks, err := c.PinManifest(ctx, entries, types.PinOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(acks) != 2 {
+			t.Fatal("expected an acknowledgement per entry")
+		}
+		if !acks[0].Cid.Equals(test.Cid1) || acks[0].Error != "" {
+			t.Errorf("unexpected first acknowledgement: %+v", acks[0])
+		}
+		if !acks[1].Cid.Equals(test.ErrorCid) || acks[1].Error == "" {
+			t.Errorf("expected an error pinning %s", test.ErrorCid)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStreamPins(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		in := make(chan cid.Cid, 2)
+		in <- test.Cid1
+		in <- test.ErrorCid
+		close(in)
+		out := make(chan *types.PinAck, 2)
+
+		err := c.StreamPins(ctx, in, types.PinOptions{Name: "stream"}, out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var acks []*types.PinAck
+		for ack := range out {
+			acks = append(acks, ack)
+		}
+		if len(acks) != 2 {
+			t.Fatal("expected 2 acks, got", len(acks))
+		}
+		if !acks[0].Cid.Equals(test.Cid1) || acks[0].Error != "" {
+			t.Error("expected a successful ack for Cid1")
+		}
+		if !acks[1].Cid.Equals(test.ErrorCid) || acks[1].Error == "" {
+			t.Error("expected an error ack for ErrorCid")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestUnpin(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		_, err := c.Unpin(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestForcePin(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		opts := types.PinOptions{
+			ReplicationFactorMin: 1,
+			ReplicationFactorMax: 2,
+			Name:                 "testname",
+		}
+		pin, err := c.ForcePin(ctx, test.Cid1, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) || pin.Name != "testname" {
+			t.Error("expected the pinned cid with its options")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestForceUnpin(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.ForceUnpin(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("expected the unpinned cid")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+type pathCase struct {
+	path        string
+	wantErr     bool
+	expectedCid string
+}
+
+var pathTestCases = []pathCase{
+	{
+		test.CidResolved.String(),
+		false,
+		test.CidResolved.String(),
+	},
+	{
+		test.PathIPFS1,
+		false,
+		"QmaNJ5acV31sx8jq626qTpAWW4DXKw34aGhx53dECLvXbY",
+	},
+	{
+		test.PathIPFS2,
+		false,
+		test.CidResolved.String(),
+	},
+	{
+		test.PathIPNS1,
+		false,
+		test.CidResolved.String(),
+	},
+	{
+		test.PathIPLD1,
+		false,
+		"QmaNJ5acV31sx8jq626qTpAWW4DXKw34aGhx53dECLvXbY",
+	},
+	{
+		test.InvalidPath1,
+		true,
+		"",
+	},
+}
+
+func TestPinPath(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	opts := types.PinOptions{
+		ReplicationFactorMin: 6,
+		ReplicationFactorMax: 7,
+		Name:                 "hello there",
+		UserAllocations:      []peer.ID{test.PeerID1, test.PeerID2},
+	}
+
+	testF := func(t *testing.T, c Client) {
+		for _, testCase := range pathTestCases {
+			ec, _ := cid.Decode(testCase.expectedCid)
+			resultantPin := types.PinWithOpts(ec, opts)
+			p := testCase.path
+			pin, err := c.PinPath(ctx, p, opts)
+			if err != nil {
+				if testCase.wantErr {
+					continue
+				}
+				t.Fatalf("unexpected error %s: %s", p, err)
+			}
+
+			if !pin.Equals(resultantPin) {
+				t.Errorf("expected different pin: %s", p)
+				t.Errorf("expected: %+v", resultantPin)
+				t.Errorf("actual: %+v", pin)
+			}
+
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestUnpinPath(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		for _, testCase := range pathTestCases {
+			p := testCase.path
+			pin, err := c.UnpinPath(ctx, p)
+			if err != nil {
+				if testCase.wantErr {
+					continue
+				}
+				t.Fatalf("unepected error %s: %s", p, err)
+			}
+
+			if pin.Cid.String() != testCase.expectedCid {
+				t.Errorf("bad resolved Cid: %s, %s", p, pin.Cid)
+			}
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAllocations(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pins, err := c.Allocations(ctx, types.DataType|types.MetaType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) == 0 {
+			t.Error("should be some pins")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestPinsView(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		page, err := c.PinsView(ctx, &types.PinViewQuery{
+			PinQuery: types.PinQuery{Type: types.DataType},
+			Offset:   1,
+			Limit:    1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 3 || len(page.Items) != 1 || !page.Items[0].Pin.Cid.Equals(test.Cid2) {
+			t.Error("unexpected pin view page: ", page)
+		}
+		if page.Items[0].Status == nil {
+			t.Error("expected the status of the pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAllocationsQuery(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pins, err := c.AllocationsQuery(ctx, &types.PinQuery{
+			Type:      types.DataType,
+			Namespace: test.Namespace1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid3) {
+			t.Error("expected only the pin in the namespace")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAllocation(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.Allocation(ctx, test.Cid1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("should be same pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestAllocationExclusions(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		ex, err := c.AllocationExclusions(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ex.Peers) != 1 || ex.Peers[0] != test.PeerID3 {
+			t.Errorf("unexpected exclusions: %+v", ex)
+		}
+
+		err = c.SetAllocationExclusions(ctx, &types.AllocationExclusions{
+			Peers: []peer.ID{test.PeerID1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestProvideStrategy(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		ps, err := c.ProvideStrategy(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ps.Strategy != types.ProvidePinned {
+			t.Errorf("unexpected provide strategy: %+v", ps)
+		}
+
+		err = c.SetProvideStrategy(ctx, &types.ProvideStrategy{
+			Strategy: types.ProvideAll,
+			Peers:    []peer.ID{test.PeerID1},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStatus(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.Status(ctx, test.Cid1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("should be same pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStatusChanges(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		since := time.Now().Add(-time.Minute)
+		changes, err := c.StatusChanges(ctx, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changes.Until.Before(since) {
+			t.Error("until should be after since")
+		}
+		if len(changes.Changes) != 1 || !changes.Changes[0].Cid.Equals(test.Cid3) {
+			t.Error("unexpected changes")
+		}
+
+		_, err = c.StatusChanges(ctx, time.Time{})
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestStatusAll(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pins, err := c.StatusAll(ctx, 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(pins) == 0 {
+			t.Error("there should be some pins")
+		}
+
+		// With local true
+		pins, err = c.StatusAll(ctx, 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 2 {
+			t.Error("there should be two pins")
+		}
+
+		// With filter option
+		pins, err = c.StatusAll(ctx, types.TrackerStatusPinning, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 {
+			t.Error("there should be one pin")
+		}
+
+		pins, err = c.StatusAll(ctx, types.TrackerStatusPinned|types.TrackerStatusError, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 2 {
+			t.Error("there should be two pins")
+		}
+
+		pins, err = c.StatusAll(ctx, 1<<25, false)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestRecover(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.Recover(ctx, test.Cid1, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("should be same pin")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestRecoverSchedules(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		sched, err := c.ScheduleRecover(ctx, &types.RecoverSchedule{
+			Interval:    time.Minute,
+			MaxAttempts: 3,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sched.ID == "" || sched.Interval != time.Minute || sched.MaxAttempts != 3 {
+			t.Error("unexpected recover schedule")
+		}
+
+		scheds, err := c.RecoverSchedules(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(scheds) != 1 {
+			t.Fatal("expected 1 recover schedule")
+		}
+
+		err = c.CancelRecoverSchedule(ctx, scheds[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
+func TestJobs(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		j, err := c.SubmitJob(ctx, &types.Job{Kind: types.JobRecover})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.ID != "1" || j.Kind != types.JobRecover || j.Status != types.JobQueued {
+			t.Error("unexpected job")
+		}
+
+		jobs, err := c.Jobs(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(jobs) != 1 {
+			t.Fatal("expected 1 job")
+		}
+
+		j, err = c.Job(ctx, jobs[0].ID)
+		if err != nil {
+			t.Fatal(err)
 		}
-		if ids != nil {
-			t.Fatal("expected no ids")
+		if j.Progress != 10 || j.Total != 20 {
+			t.Error("unexpected job progress")
+		}
+
+		err = c.CancelJob(ctx, j.ID)
+		if err != nil {
+			t.Fatal(err)
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestPeerAdd(t *testing.T) {
+func TestCollections(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		id, err := c.PeerAdd(ctx, test.PeerID1)
+		col, err := c.PinCollection(ctx, &types.Collection{
+			Name: "dataset",
+			Cids: []cid.Cid{test.Cid1},
+		})
 		if err != nil {
 			t.Fatal(err)
 		}
-		if id.ID != test.PeerID1 {
-			t.Error("bad peer")
+		if col.Name != "dataset" || len(col.Cids) != 1 {
+			t.Error("unexpected collection")
+		}
+
+		cols, err := c.Collections(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cols) != 1 {
+			t.Fatal("expected 1 collection")
+		}
+
+		col, err = c.Collection(ctx, "dataset")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(col.Cids) != 2 {
+			t.Error("expected 2 cids")
+		}
+
+		gpis, err := c.CollectionStatus(ctx, "dataset")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(gpis) != 2 {
+			t.Error("expected the status of 2 cids")
+		}
+
+		_, err = c.UnpinCollection(ctx, "dataset")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.Collection(ctx, "other")
+		if err == nil {
+			t.Error("expected an error with an unknown collection")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestPeerRm(t *testing.T) {
+func TestRecoverAll(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		err := c.PeerRm(ctx, test.PeerID1)
+		_, err := c.RecoverAll(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = c.RecoverAll(ctx, false)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -136,238 +863,198 @@ func TestPeerRm(t *testing.T) {
 	testClients(t, api, testF)
 }
 
-func TestPin(t *testing.T) {
+func TestGetConnectGraph(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		opts := types.PinOptions{
-			ReplicationFactorMin: 6,
-			ReplicationFactorMax: 7,
-			Name:                 "hello there",
-		}
-		_, err := c.Pin(ctx, test.Cid1, opts)
+		cg, err := c.GetConnectGraph(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
+		if len(cg.IPFSLinks) != 3 || len(cg.ClusterLinks) != 3 ||
+			len(cg.ClustertoIPFS) != 3 {
+			t.Fatal("Bad graph")
+		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestUnpin(t *testing.T) {
+func TestMetrics(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		_, err := c.Unpin(ctx, test.Cid1)
+		m, err := c.Metrics(ctx, "somemetricstype")
 		if err != nil {
 			t.Fatal(err)
 		}
+
+		if len(m) == 0 {
+			t.Fatal("No metrics found")
+		}
 	}
 
 	testClients(t, api, testF)
 }
 
-type pathCase struct {
-	path        string
-	wantErr     bool
-	expectedCid string
-}
-
-var pathTestCases = []pathCase{
-	{
-		test.CidResolved.String(),
-		false,
-		test.CidResolved.String(),
-	},
-	{
-		test.PathIPFS1,
-		false,
-		"QmaNJ5acV31sx8jq626qTpAWW4DXKw34aGhx53dECLvXbY",
-	},
-	{
-		test.PathIPFS2,
-		false,
-		test.CidResolved.String(),
-	},
-	{
-		test.PathIPNS1,
-		false,
-		test.CidResolved.String(),
-	},
-	{
-		test.PathIPLD1,
-		false,
-		"QmaNJ5acV31sx8jq626qTpAWW4DXKw34aGhx53dECLvXbY",
-	},
-	{
-		test.InvalidPath1,
-		true,
-		"",
-	},
-}
-
-func TestPinPath(t *testing.T) {
+func TestAlerts(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
-	opts := types.PinOptions{
-		ReplicationFactorMin: 6,
-		ReplicationFactorMax: 7,
-		Name:                 "hello there",
-		UserAllocations:      []peer.ID{test.PeerID1, test.PeerID2},
-	}
-
 	testF := func(t *testing.T, c Client) {
-		for _, testCase := range pathTestCases {
-			ec, _ := cid.Decode(testCase.expectedCid)
-			resultantPin := types.PinWithOpts(ec, opts)
-			p := testCase.path
-			pin, err := c.PinPath(ctx, p, opts)
-			if err != nil {
-				if testCase.wantErr {
-					continue
-				}
-				t.Fatalf("unexpected error %s: %s", p, err)
-			}
-
-			if !pin.Equals(resultantPin) {
-				t.Errorf("expected different pin: %s", p)
-				t.Errorf("expected: %+v", resultantPin)
-				t.Errorf("actual: %+v", pin)
-			}
-
+		alerts, err := c.Alerts(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alerts) != 1 {
+			t.Fatal("expected one alert")
+		}
+		if alerts[0].Peer != test.PeerID2 {
+			t.Error("unexpected alert peer")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestUnpinPath(t *testing.T) {
+func TestTrackerOperations(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		for _, testCase := range pathTestCases {
-			p := testCase.path
-			pin, err := c.UnpinPath(ctx, p)
-			if err != nil {
-				if testCase.wantErr {
-					continue
-				}
-				t.Fatalf("unepected error %s: %s", p, err)
-			}
-
-			if pin.Cid.String() != testCase.expectedCid {
-				t.Errorf("bad resolved Cid: %s, %s", p, pin.Cid)
-			}
+		ops, err := c.TrackerOperations(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ops) != 1 {
+			t.Fatal("expected one operation")
+		}
+		if !ops[0].Cid.Equals(test.Cid1) {
+			t.Error("unexpected operation cid")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestAllocations(t *testing.T) {
+func TestStats(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pins, err := c.Allocations(ctx, types.DataType|types.MetaType)
+		stats, err := c.Stats(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(pins) == 0 {
-			t.Error("should be some pins")
+		if stats.Pins != 3 || len(stats.Peers) != 1 {
+			t.Fatal("unexpected stats")
+		}
+		if stats.Peers[0].Peer != test.PeerID1 {
+			t.Error("unexpected peer stats")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestAllocation(t *testing.T) {
+func TestRepinProgress(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pin, err := c.Allocation(ctx, test.Cid1)
+		progress, err := c.RepinProgress(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !pin.Cid.Equals(test.Cid1) {
-			t.Error("should be same pin")
+		if len(progress) != 1 {
+			t.Fatal("expected one progress report")
+		}
+		if progress[0].Peer != test.PeerID2 {
+			t.Error("unexpected progress report peer")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestStatus(t *testing.T) {
+func TestQuotaUsage(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pin, err := c.Status(ctx, test.Cid1, false)
+		usage, err := c.QuotaUsage(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !pin.Cid.Equals(test.Cid1) {
-			t.Error("should be same pin")
+		if len(usage) != 2 {
+			t.Fatal("expected two usage reports")
+		}
+		if usage[0].Namespace != test.Namespace1 || usage[0].MaxPins != 10 {
+			t.Error("unexpected namespace usage")
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestStatusAll(t *testing.T) {
+func TestShardsGC(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pins, err := c.StatusAll(ctx, 0, false)
+		unpinned, err := c.ShardsGC(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-
-		if len(pins) == 0 {
-			t.Error("there should be some pins")
+		if len(unpinned) != 1 || !unpinned[0].Cid.Equals(test.Cid1) {
+			t.Error("expected one unpinned shard")
 		}
+	}
 
-		// With local true
-		pins, err = c.StatusAll(ctx, 0, true)
+	testClients(t, api, testF)
+}
+
+func TestStateCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		pin, err := c.StateCheckpoint(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(pins) != 2 {
-			t.Error("there should be two pins")
+		if !pin.Cid.Equals(test.Cid1) {
+			t.Error("unexpected checkpoint pin")
 		}
 
-		// With filter option
-		pins, err = c.StatusAll(ctx, types.TrackerStatusPinning, false)
+		checkpoints, err := c.StateCheckpoints(ctx)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(pins) != 1 {
-			t.Error("there should be one pin")
+		if len(checkpoints) != 1 {
+			t.Error("expected one checkpoint")
 		}
 
-		pins, err = c.StatusAll(ctx, types.TrackerStatusPinned|types.TrackerStatusError, false)
+		pins, err := c.StateCheckpointPins(ctx, test.Cid1)
 		if err != nil {
 			t.Fatal(err)
 		}
 		if len(pins) != 2 {
-			t.Error("there should be two pins")
+			t.Error("expected two pins in the checkpoint")
 		}
 
-		pins, err = c.StatusAll(ctx, 1<<25, false)
+		_, err = c.StateCheckpointPins(ctx, test.ErrorCid)
 		if err == nil {
 			t.Error("expected an error")
 		}
@@ -376,36 +1063,32 @@ func TestStatusAll(t *testing.T) {
 	testClients(t, api, testF)
 }
 
-func TestRecover(t *testing.T) {
+func TestSetLogLevel(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		pin, err := c.Recover(ctx, test.Cid1, false)
+		err := c.SetLogLevel(ctx, "consensus", "debug")
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !pin.Cid.Equals(test.Cid1) {
-			t.Error("should be same pin")
-		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestRecoverAll(t *testing.T) {
+func TestUpgrade(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		_, err := c.RecoverAll(ctx, true)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		_, err = c.RecoverAll(ctx, false)
+		err := c.Upgrade(ctx, types.UpgradeOptions{
+			Version: "0.12.2",
+			DistURL: "http://127.0.0.1:8080",
+			Force:   true,
+		})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -414,38 +1097,47 @@ func TestRecoverAll(t *testing.T) {
 	testClients(t, api, testF)
 }
 
-func TestGetConnectGraph(t *testing.T) {
+func TestSecretRotation(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		cg, err := c.GetConnectGraph(ctx)
+		err := c.BeginSecretRotation(ctx, make([]byte, 32))
 		if err != nil {
 			t.Fatal(err)
 		}
-		if len(cg.IPFSLinks) != 3 || len(cg.ClusterLinks) != 3 ||
-			len(cg.ClustertoIPFS) != 3 {
-			t.Fatal("Bad graph")
+
+		err = c.BeginSecretRotation(ctx, make([]byte, 10))
+		if err == nil {
+			t.Error("expected an error with a short secret")
+		}
+
+		err = c.FinalizeSecretRotation(ctx)
+		if err != nil {
+			t.Fatal(err)
 		}
 	}
 
 	testClients(t, api, testF)
 }
 
-func TestMetrics(t *testing.T) {
+func TestPushConfig(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
 	defer shutdown(api)
 
 	testF := func(t *testing.T, c Client) {
-		m, err := c.Metrics(ctx, "somemetricstype")
+		err := c.PushConfig(ctx, []*types.ConfigSection{
+			{Name: "disk", Config: []byte(`{"metric_ttl": "1m"}`)},
+		})
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		if len(m) == 0 {
-			t.Fatal("No metrics found")
+		err = c.PushConfig(ctx, []*types.ConfigSection{{Name: "raft"}})
+		if err == nil {
+			t.Error("expected an error pushing a section which cannot be pushed")
 		}
 	}
 
@@ -631,6 +1323,37 @@ func TestAddMultiFile(t *testing.T) {
 	testClients(t, api, testF)
 }
 
+func TestPrefetch(t *testing.T) {
+	ctx := context.Background()
+	api := testAPI(t)
+	defer shutdown(api)
+
+	testF := func(t *testing.T, c Client) {
+		results, err := c.Prefetch(ctx, test.Cid1, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Peer != test.PeerID1 {
+			t.Error("unexpected prefetch results")
+		}
+
+		results, err = c.Prefetch(ctx, test.Cid1, []peer.ID{test.PeerID2, test.PeerID3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[1].Peer != test.PeerID3 {
+			t.Error("unexpected prefetch results")
+		}
+
+		_, err = c.Prefetch(ctx, test.ErrorCid, nil)
+		if err == nil {
+			t.Error("expected an error")
+		}
+	}
+
+	testClients(t, api, testF)
+}
+
 func TestRepoGC(t *testing.T) {
 	ctx := context.Background()
 	api := testAPI(t)
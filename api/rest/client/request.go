@@ -13,6 +13,17 @@ import (
 	"github.com/ipfs/ipfs-cluster/api"
 )
 
+// apiVersionPrefix is the path prefix of the version of the REST API
+// endpoints used by the client (see rest.APIVersionPrefix).
+const apiVersionPrefix = "/v1"
+
+// The client always asks for the JSON field names of the api types, whatever
+// the casing configured in the peer (see rest.FieldCasingHeader).
+const (
+	fieldCasingHeader = "X-Json-Field-Casing"
+	snakeCase         = "snake_case"
+)
+
 type responseDecoder func(d *json.Decoder) error
 
 func (c *defaultClient) do(
@@ -58,7 +69,17 @@ func (c *defaultClient) doRequest(
 	)
 	defer span.End()
 
-	urlpath := c.net + "://" + c.hostname + "/" + strings.TrimPrefix(path, "/")
+	ctx = trace.NewContext(ctx, span)
+	urlpath := c.net + "://" + c.hostname + c.apiPrefix(ctx) + "/" + strings.TrimPrefix(path, "/")
+	return c.sendRequest(ctx, method, urlpath, headers, body)
+}
+
+func (c *defaultClient) sendRequest(
+	ctx context.Context,
+	method, urlpath string,
+	headers map[string]string,
+	body io.Reader,
+) (*http.Response, error) {
 	logger.Debugf("%s: %s", method, urlpath)
 
 	r, err := http.NewRequestWithContext(ctx, method, urlpath, body)
@@ -72,6 +93,7 @@ func (c *defaultClient) doRequest(
 	if c.config.Username != "" {
 		r.SetBasicAuth(c.config.Username, c.config.Password)
 	}
+	r.Header.Set(fieldCasingHeader, snakeCase)
 
 	if headers != nil {
 		for k, v := range headers {
@@ -83,11 +105,42 @@ func (c *defaultClient) doRequest(
 		r.ContentLength = -1 // this lets go use "chunked".
 	}
 
-	ctx = trace.NewContext(ctx, span)
-	r = r.WithContext(ctx)
-
 	return c.client.Do(r)
 }
+
+// apiPrefix returns the path prefix of the REST API endpoints. Unless it is
+// set in the configuration, the first request checks whether the peer serves
+// the /v1 endpoints and the unprefixed legacy endpoints are used otherwise.
+// The check is retried with the next request when the peer cannot be
+// reached.
+func (c *defaultClient) apiPrefix(ctx context.Context) string {
+	if c.config.APIPrefix != "" {
+		return strings.TrimSuffix(c.config.APIPrefix, "/")
+	}
+
+	c.prefixMux.Lock()
+	defer c.prefixMux.Unlock()
+	if c.prefixNegotiated {
+		return c.prefix
+	}
+
+	urlpath := c.net + "://" + c.hostname + apiVersionPrefix + "/version"
+	resp, err := c.sendRequest(ctx, "GET", urlpath, nil, nil)
+	if err != nil {
+		return apiVersionPrefix
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	c.prefix = apiVersionPrefix
+	if resp.StatusCode == http.StatusNotFound {
+		logger.Infof("the peer does not serve the %s endpoints: using the legacy ones", apiVersionPrefix)
+		c.prefix = ""
+	}
+	c.prefixNegotiated = true
+	return c.prefix
+}
+
 func (c *defaultClient) handleResponse(resp *http.Response, obj interface{}) error {
 	body, err := ioutil.ReadAll(resp.Body)
 	resp.Body.Close()
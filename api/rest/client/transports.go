@@ -6,12 +6,16 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/api"
+
 	libp2p "github.com/libp2p/go-libp2p"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
 	ipnet "github.com/libp2p/go-libp2p-core/pnet"
+	gostream "github.com/libp2p/go-libp2p-gostream"
 	p2phttp "github.com/libp2p/go-libp2p-http"
 	pnet "github.com/libp2p/go-libp2p-pnet"
 	libp2pquic "github.com/libp2p/go-libp2p-quic-transport"
@@ -20,6 +24,8 @@ import (
 	madns "github.com/multiformats/go-multiaddr-dns"
 	manet "github.com/multiformats/go-multiaddr-net"
 	"github.com/tv42/httpunix"
+
+	websocket "github.com/gorilla/websocket"
 )
 
 // This is essentially a http.DefaultTransport. We should not mess
@@ -128,3 +134,61 @@ func (c *defaultClient) enableUnix() error {
 	c.hostname = "restapi"
 	return nil
 }
+
+// dialWebsocket opens a websocket to the given API path over the same kind
+// of transport (TCP, TLS, unix socket or libp2p) used for regular requests.
+func (c *defaultClient) dialWebsocket(ctx context.Context, path string) (*websocket.Conn, error) {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 45 * time.Second,
+		NetDialContext:   c.transport.DialContext,
+		TLSClientConfig:  c.transport.TLSClientConfig,
+	}
+
+	scheme := "ws"
+	switch c.net {
+	case "https":
+		scheme = "wss"
+	case "libp2p":
+		pid, err := peer.IDB58Decode(c.hostname)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Proxy = nil
+		dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return gostream.Dial(ctx, c.p2p, pid, p2phttp.DefaultP2PProtocol)
+		}
+	case httpunix.Scheme:
+		_, addr, err := manet.DialArgs(c.config.APIAddr)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Proxy = nil
+		dialer.NetDialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", addr)
+		}
+	}
+
+	urlpath := scheme + "://" + c.hostname + c.apiPrefix(ctx) + "/" + strings.TrimPrefix(path, "/")
+	logger.Debugf("websocket: %s", urlpath)
+
+	// Build the headers as for regular requests.
+	r, err := http.NewRequest("GET", urlpath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.Username != "" {
+		r.SetBasicAuth(c.config.Username, c.config.Password)
+	}
+	r.Header.Set(fieldCasingHeader, snakeCase)
+
+	conn, resp, err := dialer.DialContext(ctx, urlpath, r.Header)
+	if err != nil {
+		if resp != nil && resp.StatusCode > 399 {
+			return nil, c.handleResponse(resp, nil)
+		}
+		return nil, &api.Error{Code: 0, Message: err.Error()}
+	}
+	return conn, nil
+}
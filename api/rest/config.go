@@ -33,11 +33,13 @@ var DefaultHTTPListenAddrs = []string{
 
 // These are the default values for Config
 const (
-	DefaultReadTimeout       = 0
-	DefaultReadHeaderTimeout = 5 * time.Second
-	DefaultWriteTimeout      = 0
-	DefaultIdleTimeout       = 120 * time.Second
-	DefaultMaxHeaderBytes    = minMaxHeaderBytes
+	DefaultReadTimeout          = 0
+	DefaultReadHeaderTimeout    = 5 * time.Second
+	DefaultWriteTimeout         = 0
+	DefaultIdleTimeout          = 120 * time.Second
+	DefaultMaxHeaderBytes       = minMaxHeaderBytes
+	DefaultStreamPinsMaxPending = 5000
+	DefaultJSONFieldCasing      = SnakeCase
 )
 
 // These are the default values for Config.
@@ -45,6 +47,18 @@ var (
 	DefaultHeaders = map[string][]string{}
 )
 
+// Roles which can be assigned to the BasicAuthCredentials users. Each role
+// gives access to a set of endpoint groups.
+const (
+	// RoleAdmin users can use all the endpoints.
+	RoleAdmin = "admin"
+	// RolePinner users can read the cluster status and pin and unpin
+	// content.
+	RolePinner = "pinner"
+	// RoleReader users can only read the cluster status.
+	RoleReader = "reader"
+)
+
 // CORS defaults
 var (
 	DefaultCORSAllowedOrigins = []string{"*"}
@@ -59,6 +73,7 @@ var (
 		"X-Stream-Output",
 		"X-Chunked-Output",
 		"X-Content-Length",
+		"Traceresponse",
 	}
 	DefaultCORSAllowCredentials = true
 	DefaultCORSMaxAge           time.Duration // 0. Means always.
@@ -101,6 +116,36 @@ type Config struct {
 	// accepted by the server
 	MaxHeaderBytes int
 
+	// Number of pin/unpin operations queued in the pin tracker above
+	// which pins received over a pin stream are not committed until the
+	// queue shrinks.
+	StreamPinsMaxPending int
+
+	// Maximum size in bytes of request bodies (0 means unlimited).
+	// Larger requests are rejected with 413 (Request Entity Too Large).
+	MaxBodySize int64
+
+	// Maximum number of files and directories, and maximum depth of
+	// their paths, in the multipart requests to the /add endpoint (0
+	// means unlimited).
+	MaxAddFiles     int
+	MaxAddPathDepth int
+
+	// DisableLegacyRoutes stops serving the endpoints without the
+	// version prefix (i.e. /id instead of /v1/id), which are kept for
+	// older clients.
+	DisableLegacyRoutes bool
+
+	// JSONFieldCasing is the casing of the JSON field names in the
+	// responses: SnakeCase (the default) or CamelCase. Requests can
+	// choose a different one with the FieldCasingHeader.
+	JSONFieldCasing string
+
+	// EnableWebUI serves a simple dashboard under /ui/ which shows the
+	// peers, pin statuses, pin tracker queues and alerts, and allows
+	// adding files, using the REST API endpoints.
+	EnableWebUI bool
+
 	// Listen address for the Libp2p REST API endpoint.
 	Libp2pListenAddr []ma.Multiaddr
 
@@ -113,6 +158,17 @@ type Config struct {
 	// which are authorized to use Basic Authentication
 	BasicAuthCredentials map[string]string
 
+	// BasicAuthNamespaces restricts some of the BasicAuthCredentials
+	// users to a pin namespace, by username. Pins made by these users
+	// are placed in their namespace and they can only see and modify
+	// the pins in it. Other users are not restricted.
+	BasicAuthNamespaces map[string]string
+
+	// BasicAuthRoles assigns roles (admin, pinner, reader) to some of
+	// the BasicAuthCredentials users, by username. Users without a role
+	// are admins.
+	BasicAuthRoles map[string]string
+
 	// HTTPLogFile is path of the file that would save HTTP API logs. If this
 	// path is empty, HTTP logs would be sent to standard output. This path
 	// should either be absolute or relative to cluster base directory. Its
@@ -133,6 +189,15 @@ type Config struct {
 
 	// Tracing flag used to skip tracing specific paths when not enabled.
 	Tracing bool
+
+	// TrustTraceParent makes the spans of requests children of the span
+	// given in their "traceparent" header (W3C Trace Context), so that
+	// traces started by clients continue through the RPC calls and the
+	// IPFS requests of every peer involved. Otherwise, requests start
+	// new traces which are only linked to the given span, as untrusted
+	// clients could otherwise decide what gets sampled. The trace of a
+	// request is returned in its "traceresponse" header in any case.
+	TrustTraceParent bool
 }
 
 type jsonConfig struct {
@@ -144,12 +209,22 @@ type jsonConfig struct {
 	WriteTimeout           string             `json:"write_timeout"`
 	IdleTimeout            string             `json:"idle_timeout"`
 	MaxHeaderBytes         int                `json:"max_header_bytes"`
+	StreamPinsMaxPending   int                `json:"stream_pins_max_pending,omitempty"`
+	MaxBodySize            int64              `json:"max_body_size,omitempty"`
+	MaxAddFiles            int                `json:"max_add_files,omitempty"`
+	MaxAddPathDepth        int                `json:"max_add_path_depth,omitempty"`
+	DisableLegacyRoutes    bool               `json:"disable_legacy_routes,omitempty"`
+	JSONFieldCasing        string             `json:"json_field_casing,omitempty"`
+	EnableWebUI            bool               `json:"enable_web_ui,omitempty"`
+	TrustTraceParent       bool               `json:"trust_trace_parent,omitempty"`
 
 	Libp2pListenMultiaddress ipfsconfig.Strings `json:"libp2p_listen_multiaddress,omitempty"`
 	ID                       string             `json:"id,omitempty"`
 	PrivateKey               string             `json:"private_key,omitempty"`
 
 	BasicAuthCredentials map[string]string   `json:"basic_auth_credentials"`
+	BasicAuthNamespaces  map[string]string   `json:"basic_auth_namespaces,omitempty"`
+	BasicAuthRoles       map[string]string   `json:"basic_auth_roles,omitempty"`
 	HTTPLogFile          string              `json:"http_log_file"`
 	Headers              map[string][]string `json:"headers"`
 
@@ -200,6 +275,14 @@ func (cfg *Config) Default() error {
 	cfg.WriteTimeout = DefaultWriteTimeout
 	cfg.IdleTimeout = DefaultIdleTimeout
 	cfg.MaxHeaderBytes = DefaultMaxHeaderBytes
+	cfg.StreamPinsMaxPending = DefaultStreamPinsMaxPending
+	cfg.MaxBodySize = 0
+	cfg.MaxAddFiles = 0
+	cfg.MaxAddPathDepth = 0
+	cfg.DisableLegacyRoutes = false
+	cfg.JSONFieldCasing = DefaultJSONFieldCasing
+	cfg.EnableWebUI = false
+	cfg.TrustTraceParent = false
 
 	// libp2p
 	cfg.ID = ""
@@ -208,6 +291,8 @@ func (cfg *Config) Default() error {
 
 	// Auth
 	cfg.BasicAuthCredentials = nil
+	cfg.BasicAuthNamespaces = nil
+	cfg.BasicAuthRoles = nil
 
 	// Logs
 	cfg.HTTPLogFile = ""
@@ -254,12 +339,40 @@ func (cfg *Config) Validate() error {
 		return errors.New("restapi.idle_timeout invalid")
 	case cfg.MaxHeaderBytes < minMaxHeaderBytes:
 		return fmt.Errorf("restapi.max_header_bytes must be not less then %d", minMaxHeaderBytes)
+	case cfg.StreamPinsMaxPending <= 0:
+		return errors.New("restapi.stream_pins_max_pending must be positive")
+	case cfg.MaxBodySize < 0:
+		return errors.New("restapi.max_body_size is invalid")
+	case cfg.MaxAddFiles < 0:
+		return errors.New("restapi.max_add_files is invalid")
+	case cfg.MaxAddPathDepth < 0:
+		return errors.New("restapi.max_add_path_depth is invalid")
 	case cfg.BasicAuthCredentials != nil && len(cfg.BasicAuthCredentials) == 0:
 		return errors.New("restapi.basic_auth_creds should be null or have at least one entry")
 	case (cfg.pathSSLCertFile != "" || cfg.pathSSLKeyFile != "") && cfg.TLS == nil:
 		return errors.New("restapi: missing TLS configuration")
 	case (cfg.CORSMaxAge < 0):
 		return errors.New("restapi.cors_max_age is invalid")
+	case !validCasing(cfg.JSONFieldCasing):
+		return fmt.Errorf("restapi.json_field_casing must be %s or %s", SnakeCase, CamelCase)
+	}
+
+	for user, ns := range cfg.BasicAuthNamespaces {
+		if _, ok := cfg.BasicAuthCredentials[user]; !ok {
+			return fmt.Errorf("restapi.basic_auth_namespaces: %s is not in basic_auth_credentials", user)
+		}
+		if ns == "" {
+			return fmt.Errorf("restapi.basic_auth_namespaces: empty namespace for %s", user)
+		}
+	}
+
+	for user, role := range cfg.BasicAuthRoles {
+		if _, ok := cfg.BasicAuthCredentials[user]; !ok {
+			return fmt.Errorf("restapi.basic_auth_roles: %s is not in basic_auth_credentials", user)
+		}
+		if _, ok := roleEndpoints[role]; !ok {
+			return fmt.Errorf("restapi.basic_auth_roles: unknown role %q for %s", role, user)
+		}
 	}
 
 	return cfg.validateLibp2p()
@@ -307,6 +420,8 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 
 	// Other options
 	cfg.BasicAuthCredentials = jcfg.BasicAuthCredentials
+	cfg.BasicAuthNamespaces = jcfg.BasicAuthNamespaces
+	cfg.BasicAuthRoles = jcfg.BasicAuthRoles
 	cfg.HTTPLogFile = jcfg.HTTPLogFile
 	cfg.Headers = jcfg.Headers
 
@@ -337,6 +452,20 @@ func (cfg *Config) loadHTTPOptions(jcfg *jsonConfig) error {
 		cfg.MaxHeaderBytes = jcfg.MaxHeaderBytes
 	}
 
+	if jcfg.StreamPinsMaxPending == 0 {
+		cfg.StreamPinsMaxPending = DefaultStreamPinsMaxPending
+	} else {
+		cfg.StreamPinsMaxPending = jcfg.StreamPinsMaxPending
+	}
+
+	cfg.MaxBodySize = jcfg.MaxBodySize
+	cfg.MaxAddFiles = jcfg.MaxAddFiles
+	cfg.MaxAddPathDepth = jcfg.MaxAddPathDepth
+	cfg.DisableLegacyRoutes = jcfg.DisableLegacyRoutes
+	config.SetIfNotDefault(jcfg.JSONFieldCasing, &cfg.JSONFieldCasing)
+	cfg.EnableWebUI = jcfg.EnableWebUI
+	cfg.TrustTraceParent = jcfg.TrustTraceParent
+
 	// CORS
 	cfg.CORSAllowedOrigins = jcfg.CORSAllowedOrigins
 	cfg.CORSAllowedMethods = jcfg.CORSAllowedMethods
@@ -461,7 +590,17 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 		WriteTimeout:           cfg.WriteTimeout.String(),
 		IdleTimeout:            cfg.IdleTimeout.String(),
 		MaxHeaderBytes:         cfg.MaxHeaderBytes,
+		StreamPinsMaxPending:   cfg.StreamPinsMaxPending,
+		MaxBodySize:            cfg.MaxBodySize,
+		MaxAddFiles:            cfg.MaxAddFiles,
+		MaxAddPathDepth:        cfg.MaxAddPathDepth,
+		DisableLegacyRoutes:    cfg.DisableLegacyRoutes,
+		JSONFieldCasing:        cfg.JSONFieldCasing,
+		EnableWebUI:            cfg.EnableWebUI,
+		TrustTraceParent:       cfg.TrustTraceParent,
 		BasicAuthCredentials:   cfg.BasicAuthCredentials,
+		BasicAuthNamespaces:    cfg.BasicAuthNamespaces,
+		BasicAuthRoles:         cfg.BasicAuthRoles,
 		HTTPLogFile:            cfg.HTTPLogFile,
 		Headers:                cfg.Headers,
 		CORSAllowedOrigins:     cfg.CORSAllowedOrigins,
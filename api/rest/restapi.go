@@ -5,25 +5,41 @@
 // HTTP(s) listener. The second is by tunneling HTTP through a libp2p
 // stream (thus getting an encrypted channel without the need to setup
 // TLS). Both ways can be used at the same time, or disabled.
+//
+// All endpoints are served under a version prefix (i.e. /v1/id). The
+// requests and responses of a version use the JSON encoding of the types in
+// the api package and their field names are stable: new fields may be
+// added, but renaming or removing fields requires a new version prefix.
+// The endpoints are also served without prefix for older clients, unless
+// disable_legacy_routes is set. Those legacy routes follow the current
+// encoding of the api types, whatever it is. The field names can be given in
+// camel case instead (see Config.JSONFieldCasing and FieldCasingHeader),
+// except in the output of the /add endpoint.
 package rest
 
 import (
 	"context"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"mime"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/adder"
 	"github.com/ipfs/ipfs-cluster/adder/adderutils"
 	types "github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/faults"
+	"github.com/ipfs/ipfs-cluster/observations"
 	"github.com/ipfs/ipfs-cluster/state"
 
 	cid "github.com/ipfs/go-cid"
@@ -42,6 +58,7 @@ import (
 
 	handlers "github.com/gorilla/handlers"
 	mux "github.com/gorilla/mux"
+	websocket "github.com/gorilla/websocket"
 	"github.com/rs/cors"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
@@ -69,9 +86,27 @@ var (
 	ErrHTTPEndpointNotEnabled = errors.New("the HTTP endpoint is not enabled")
 )
 
+// APIVersionPrefix is the path prefix of the current version of the
+// endpoints.
+const APIVersionPrefix = "/v1"
+
 // Used by sendResponse to set the right status
 const autoStatus = -1
 
+// Page sizes of the pin view endpoint: the one used when the request does not
+// set a limit and the largest one allowed. Every item requires obtaining its
+// status from the allocated peers.
+const (
+	defaultPinViewLimit = 100
+	maxPinViewLimit     = 1000
+)
+
+// How often the pin tracker queue is checked while a pin stream waits for
+// it to shrink.
+var streamPinsCheckInterval = time.Second
+
+var wsUpgrader = websocket.Upgrader{}
+
 // For making a random sharding ID
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
@@ -109,6 +144,10 @@ type peerAddBody struct {
 	PeerID string `json:"peer_id"`
 }
 
+type secretRotationBody struct {
+	Secret string `json:"secret"`
+}
+
 type logWriter struct {
 }
 
@@ -138,15 +177,17 @@ func NewAPIWithHost(ctx context.Context, cfg *Config, h host.Host) (*API, error)
 		cfg.BasicAuthCredentials,
 		cors.New(*cfg.corsOptions()).Handler(router),
 	)
+	handler = maxBodySizeHandler(cfg.MaxBodySize, handler)
 	if cfg.Tracing {
 		handler = &ochttp.Handler{
-			IsPublicEndpoint: true,
+			IsPublicEndpoint: !cfg.TrustTraceParent,
 			Propagation:      &tracecontext.HTTPFormat{},
-			Handler:          handler,
+			Handler:          traceResponseHandler(handler),
 			StartOptions:     trace.StartOptions{SpanKind: trace.SpanKindServer},
 			FormatSpanName:   func(req *http.Request) string { return req.Host + ":" + req.URL.Path + ":" + req.Method },
 		}
 	}
+	handler = observations.HTTPHandler(ctx, "restapi", handler)
 
 	var writer io.Writer
 	if cfg.HTTPLogFile != "" {
@@ -291,16 +332,37 @@ func (api *API) Host() host.Host {
 
 func (api *API) addRoutes(router *mux.Router) {
 	for _, route := range api.routes() {
+		handler := route.HandlerFunc
+		if _, ok := namespacedRoutes[route.Name]; !ok {
+			handler = api.forbidNamespaced(handler)
+		}
+		handler = api.authorizeRole(route.Name, handler)
+		handler = api.fieldCasing(handler)
+		tagged := ochttp.WithRouteTag(
+			http.HandlerFunc(handler),
+			"/"+route.Name,
+		)
 		router.
 			Methods(route.Method).
-			Path(route.Pattern).
+			Path(APIVersionPrefix + route.Pattern).
 			Name(route.Name).
-			Handler(
-				ochttp.WithRouteTag(
-					http.HandlerFunc(route.HandlerFunc),
-					"/"+route.Name,
-				),
-			)
+			Handler(tagged)
+		if !api.config.DisableLegacyRoutes {
+			router.
+				Methods(route.Method).
+				Path(route.Pattern).
+				Handler(tagged)
+		}
+	}
+	if api.config.EnableWebUI {
+		router.
+			Methods("GET").
+			Path(WebUIPath).
+			Name("WebUI").
+			Handler(ochttp.WithRouteTag(
+				http.HandlerFunc(api.webUIHandler),
+				"/WebUI",
+			))
 	}
 	router.NotFoundHandler = ochttp.WithRouteTag(
 		http.HandlerFunc(api.notFoundHandler),
@@ -348,6 +410,252 @@ func basicAuthHandler(credentials map[string]string, h http.Handler) http.Handle
 	return http.HandlerFunc(wrap)
 }
 
+// maxBodySizeHandler wraps a given handler so that request bodies larger
+// than maxSize are rejected. Bodies of unknown length are cut when reading
+// past the limit.
+func maxBodySizeHandler(maxSize int64, h http.Handler) http.Handler {
+	if maxSize <= 0 {
+		return h
+	}
+
+	wrap := func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxSize {
+			resp, err := json.Marshal(&types.Error{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf("request body larger than %d bytes", maxSize),
+			})
+			if err != nil {
+				logger.Error(err)
+				return
+			}
+			http.Error(w, string(resp), http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(wrap)
+}
+
+// traceResponseHeader is the header with the trace of a request in the
+// W3C Trace Context format, so that users can find it.
+const traceResponseHeader = "Traceresponse"
+
+// traceResponseHandler wraps a given handler so that responses carry the
+// trace of their request in the traceResponseHeader. Traces continue
+// through the RPC calls made while serving the request.
+func traceResponseHandler(h http.Handler) http.Handler {
+	wrap := func(w http.ResponseWriter, r *http.Request) {
+		if span := trace.FromContext(r.Context()); span != nil {
+			sc := span.SpanContext()
+			w.Header().Set(
+				traceResponseHeader,
+				fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.TraceOptions),
+			)
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(wrap)
+}
+
+// namespacedRoutes are the routes that users restricted to a pin namespace
+// (see Config.BasicAuthNamespaces) can use.
+var namespacedRoutes = map[string]struct{}{
+	"ID":          {},
+	"Version":     {},
+	"Add":         {},
+	"Allocations": {},
+	"Allocation":  {},
+	"StatusAll":   {},
+	"Status":      {},
+	"Recover":     {},
+	"Pin":         {},
+	"PinPath":     {},
+	"StreamPins":  {},
+	"PinManifest": {},
+	"Unpin":       {},
+	"UnpinPath":   {},
+	"QuotaUsage":  {},
+}
+
+// endpointGroup labels a group of routes which roles give access to.
+type endpointGroup string
+
+// Endpoint groups. Routes which are not in readRoutes nor in pinRoutes are
+// admin routes.
+const (
+	readEndpoints  endpointGroup = "read"
+	pinEndpoints   endpointGroup = "pin"
+	adminEndpoints endpointGroup = "admin"
+)
+
+// roleEndpoints maps the roles that can be given to users (see
+// Config.BasicAuthRoles) to the endpoint groups they can use.
+var roleEndpoints = map[string][]endpointGroup{
+	RoleAdmin:  {readEndpoints, pinEndpoints, adminEndpoints},
+	RolePinner: {readEndpoints, pinEndpoints},
+	RoleReader: {readEndpoints},
+}
+
+// readRoutes are the routes which only read the cluster status.
+var readRoutes = map[string]struct{}{
+	"ID":                   {},
+	"Version":              {},
+	"Peers":                {},
+	"PeerNames":            {},
+	"KnownPeers":           {},
+	"Allocations":          {},
+	"Allocation":           {},
+	"AllocationExclusions": {},
+	"ProvideStrategy":      {},
+	"StatusAll":            {},
+	"PinsView":             {},
+	"StatusChanges":        {},
+	"Status":               {},
+	"RecoverSchedules":     {},
+	"Jobs":                 {},
+	"Job":                  {},
+	"Collections":          {},
+	"Collection":           {},
+	"CollectionStatus":     {},
+	"ConnectionGraph":      {},
+	"Alerts":               {},
+	"RepinProgress":        {},
+	"Stats":                {},
+	"TrackerOperations":    {},
+	"QuotaUsage":           {},
+	"Metrics":              {},
+	"MetricNames":          {},
+	"StateCheckpoints":     {},
+	"StateCheckpointPins":  {},
+}
+
+// pinRoutes are the routes which add, pin, unpin or fetch content.
+var pinRoutes = map[string]struct{}{
+	"Add":             {},
+	"Recover":         {},
+	"RecoverAll":      {},
+	"StreamPins":      {},
+	"PinManifest":     {},
+	"Pin":             {},
+	"PinPath":         {},
+	"Unpin":           {},
+	"UnpinPath":       {},
+	"PinCollection":   {},
+	"UnpinCollection": {},
+	"Prefetch":        {},
+}
+
+// routeEndpointGroup returns the endpoint group of the route with the given
+// name.
+func routeEndpointGroup(name string) endpointGroup {
+	if _, ok := readRoutes[name]; ok {
+		return readEndpoints
+	}
+	if _, ok := pinRoutes[name]; ok {
+		return pinEndpoints
+	}
+	return adminEndpoints
+}
+
+// role returns the role of the user making the request. Users without a
+// role, and all requests when Basic Authentication is disabled, are admins.
+func (api *API) role(r *http.Request) string {
+	if role, ok := api.config.BasicAuthRoles[api.user(r)]; ok {
+		return role
+	}
+	return RoleAdmin
+}
+
+// authorizeRole wraps the handler of the route with the given name so that
+// it rejects requests from users whose role does not give access to the
+// endpoint group of the route.
+func (api *API) authorizeRole(name string, h http.HandlerFunc) http.HandlerFunc {
+	group := routeEndpointGroup(name)
+	return func(w http.ResponseWriter, r *http.Request) {
+		role := api.role(r)
+		for _, g := range roleEndpoints[role] {
+			if g == group {
+				h(w, r)
+				return
+			}
+		}
+		api.sendResponse(w, http.StatusForbidden, fmt.Errorf("forbidden for users with the %s role", role), nil)
+	}
+}
+
+// user returns the name of the user making the request, when Basic
+// Authentication is enabled.
+func (api *API) user(r *http.Request) string {
+	if api.config.BasicAuthCredentials == nil {
+		return ""
+	}
+	username, _, _ := r.BasicAuth()
+	return username
+}
+
+// namespace returns the pin namespace to which the user making the request
+// is restricted, if any.
+func (api *API) namespace(r *http.Request) string {
+	return api.config.BasicAuthNamespaces[api.user(r)]
+}
+
+// forbidNamespaced wraps a handler so that it rejects requests from users
+// restricted to a namespace.
+func (api *API) forbidNamespaced(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if api.namespace(r) != "" {
+			api.sendResponse(w, http.StatusForbidden, errors.New("forbidden for users restricted to a namespace"), nil)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// scopePinOptions sets the user making the request as the owner of the
+// pins and places the pins made by users restricted to a namespace in it.
+func (api *API) scopePinOptions(r *http.Request, opts *types.PinOptions) {
+	opts.Owner = api.user(r)
+	if ns := api.namespace(r); ns != "" {
+		opts.Namespace = ns
+	}
+}
+
+// listNamespace returns the namespace to which listings are restricted:
+// the one of the user making the request or, for unrestricted users, the
+// one given in the "namespace" query parameter.
+func (api *API) listNamespace(r *http.Request) string {
+	if ns := api.namespace(r); ns != "" {
+		return ns
+	}
+	return r.URL.Query().Get("namespace")
+}
+
+// checkPinNamespace returns true when the user making the request can
+// access the pin for the given cid. Otherwise, it responds as if the pin did
+// not exist, so that pins in other namespaces are not disclosed.
+func (api *API) checkPinNamespace(w http.ResponseWriter, r *http.Request, c cid.Cid) bool {
+	ns := api.namespace(r)
+	if ns == "" {
+		return true
+	}
+
+	var pin types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinGet",
+		c,
+		&pin,
+	)
+	if err != nil || pin.Namespace != ns {
+		api.sendResponse(w, http.StatusNotFound, state.ErrNotFound, nil)
+		return false
+	}
+	return true
+}
+
 func unauthorizedResp() (string, error) {
 	apiError := &types.Error{
 		Code:    401,
@@ -358,7 +666,7 @@ func unauthorizedResp() (string, error) {
 }
 
 func (api *API) routes() []route {
-	return []route{
+	routes := []route{
 		{
 			"ID",
 			"GET",
@@ -385,12 +693,42 @@ func (api *API) routes() []route {
 			"/peers",
 			api.peerAddHandler,
 		},
+		{
+			"PeerNames",
+			"GET",
+			"/peers/names",
+			api.peerNamesHandler,
+		},
 		{
 			"PeerRemove",
 			"DELETE",
 			"/peers/{peer}",
 			api.peerRemoveHandler,
 		},
+		{
+			"PeerMaintenance",
+			"POST",
+			"/peers/{peer}/maintenance",
+			api.peerMaintenanceHandler,
+		},
+		{
+			"PeerActivate",
+			"POST",
+			"/peers/{peer}/activate",
+			api.peerActivateHandler,
+		},
+		{
+			"KnownPeers",
+			"GET",
+			"/peerstore",
+			api.knownPeersHandler,
+		},
+		{
+			"ImportKnownPeers",
+			"POST",
+			"/peerstore",
+			api.importKnownPeersHandler,
+		},
 		{
 			"Add",
 			"POST",
@@ -403,6 +741,18 @@ func (api *API) routes() []route {
 			"/allocations",
 			api.allocationsHandler,
 		},
+		{
+			"AllocationExclusions",
+			"GET",
+			"/allocations/exclusions",
+			api.allocationExclusionsHandler,
+		},
+		{
+			"SetAllocationExclusions",
+			"POST",
+			"/allocations/exclusions",
+			api.setAllocationExclusionsHandler,
+		},
 		{
 			"Allocation",
 			"GET",
@@ -415,6 +765,18 @@ func (api *API) routes() []route {
 			"/pins",
 			api.statusAllHandler,
 		},
+		{
+			"StatusChanges",
+			"GET",
+			"/pins/changes",
+			api.statusChangesHandler,
+		},
+		{
+			"PinsView",
+			"GET",
+			"/pins/view",
+			api.pinsViewHandler,
+		},
 		{
 			"Recover",
 			"POST",
@@ -427,6 +789,90 @@ func (api *API) routes() []route {
 			"/pins/recover",
 			api.recoverAllHandler,
 		},
+		{
+			"PinManifest",
+			"POST",
+			"/pins/manifest",
+			api.pinManifestHandler,
+		},
+		{
+			"ScheduleRecover",
+			"POST",
+			"/recover/schedules",
+			api.scheduleRecoverHandler,
+		},
+		{
+			"RecoverSchedules",
+			"GET",
+			"/recover/schedules",
+			api.recoverSchedulesHandler,
+		},
+		{
+			"CancelRecoverSchedule",
+			"DELETE",
+			"/recover/schedules/{id}",
+			api.cancelRecoverScheduleHandler,
+		},
+		{
+			"SubmitJob",
+			"POST",
+			"/jobs",
+			api.submitJobHandler,
+		},
+		{
+			"Jobs",
+			"GET",
+			"/jobs",
+			api.jobsHandler,
+		},
+		{
+			"Job",
+			"GET",
+			"/jobs/{id}",
+			api.jobHandler,
+		},
+		{
+			"CancelJob",
+			"DELETE",
+			"/jobs/{id}",
+			api.cancelJobHandler,
+		},
+		{
+			"Collections",
+			"GET",
+			"/collections",
+			api.collectionsHandler,
+		},
+		{
+			"Collection",
+			"GET",
+			"/collections/{name}",
+			api.collectionHandler,
+		},
+		{
+			"CollectionStatus",
+			"GET",
+			"/collections/{name}/status",
+			api.collectionStatusHandler,
+		},
+		{
+			"PinCollection",
+			"POST",
+			"/collections/{name}",
+			api.pinCollectionHandler,
+		},
+		{
+			"UnpinCollection",
+			"DELETE",
+			"/collections/{name}",
+			api.unpinCollectionHandler,
+		},
+		{
+			"StreamPins",
+			"GET",
+			"/pins/stream",
+			api.streamPinsHandler,
+		},
 		{
 			"Status",
 			"GET",
@@ -463,12 +909,114 @@ func (api *API) routes() []route {
 			"/ipfs/gc",
 			api.repoGCHandler,
 		},
+		{
+			"ProvideStrategy",
+			"GET",
+			"/ipfs/provide",
+			api.provideStrategyHandler,
+		},
+		{
+			"SetProvideStrategy",
+			"POST",
+			"/ipfs/provide",
+			api.setProvideStrategyHandler,
+		},
+		{
+			"Prefetch",
+			"POST",
+			"/prefetch/{hash}",
+			api.prefetchHandler,
+		},
+		{
+			"ShardsGC",
+			"POST",
+			"/shards/gc",
+			api.shardsGCHandler,
+		},
+		{
+			"StateCheckpoint",
+			"POST",
+			"/state/checkpoints",
+			api.stateCheckpointHandler,
+		},
+		{
+			"StateCheckpoints",
+			"GET",
+			"/state/checkpoints",
+			api.stateCheckpointsHandler,
+		},
+		{
+			"StateCheckpointPins",
+			"GET",
+			"/state/checkpoints/{hash}",
+			api.stateCheckpointPinsHandler,
+		},
+		{
+			"SetLogLevel",
+			"POST",
+			"/log/level/{facility}/{level}",
+			api.setLogLevelHandler,
+		},
+		{
+			"Upgrade",
+			"POST",
+			"/upgrade",
+			api.upgradeHandler,
+		},
+		{
+			"BeginSecretRotation",
+			"POST",
+			"/secret/rotation",
+			api.beginSecretRotationHandler,
+		},
+		{
+			"FinalizeSecretRotation",
+			"POST",
+			"/secret/rotation/finalize",
+			api.finalizeSecretRotationHandler,
+		},
+		{
+			"PushConfig",
+			"POST",
+			"/config/push",
+			api.pushConfigHandler,
+		},
 		{
 			"ConnectionGraph",
 			"GET",
 			"/health/graph",
 			api.graphHandler,
 		},
+		{
+			"Alerts",
+			"GET",
+			"/health/alerts",
+			api.alertsHandler,
+		},
+		{
+			"RepinProgress",
+			"GET",
+			"/health/repinning",
+			api.repinProgressHandler,
+		},
+		{
+			"Stats",
+			"GET",
+			"/health/stats",
+			api.statsHandler,
+		},
+		{
+			"TrackerOperations",
+			"GET",
+			"/tracker/operations",
+			api.trackerOperationsHandler,
+		},
+		{
+			"QuotaUsage",
+			"GET",
+			"/usage",
+			api.quotaUsageHandler,
+		},
 		{
 			"Metrics",
 			"GET",
@@ -482,6 +1030,26 @@ func (api *API) routes() []route {
 			api.metricNamesHandler,
 		},
 	}
+
+	// Fault injection is only available in binaries built with the
+	// "faults" tag.
+	if faults.Enabled {
+		routes = append(routes,
+			route{
+				"Faults",
+				"GET",
+				"/debug/faults",
+				api.faultsHandler,
+			},
+			route{
+				"SetFaults",
+				"POST",
+				"/debug/faults",
+				api.setFaultsHandler,
+			},
+		)
+	}
+	return routes
 }
 
 func (api *API) run(ctx context.Context) {
@@ -633,6 +1201,81 @@ func (api *API) graphHandler(w http.ResponseWriter, r *http.Request) {
 	api.sendResponse(w, autoStatus, err, graph)
 }
 
+func (api *API) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	var alerts []*types.Alert
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Alerts",
+		struct{}{},
+		&alerts,
+	)
+	api.sendResponse(w, autoStatus, err, alerts)
+}
+
+func (api *API) trackerOperationsHandler(w http.ResponseWriter, r *http.Request) {
+	var ops []*types.TrackerOperation
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"PinTracker",
+		"Operations",
+		struct{}{},
+		&ops,
+	)
+	api.sendResponse(w, autoStatus, err, ops)
+}
+
+func (api *API) repinProgressHandler(w http.ResponseWriter, r *http.Request) {
+	var progress []*types.RepinProgress
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"RepinProgress",
+		struct{}{},
+		&progress,
+	)
+	api.sendResponse(w, autoStatus, err, progress)
+}
+
+func (api *API) statsHandler(w http.ResponseWriter, r *http.Request) {
+	var stats types.ClusterStats
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Stats",
+		struct{}{},
+		&stats,
+	)
+	api.sendResponse(w, autoStatus, err, stats)
+}
+
+func (api *API) quotaUsageHandler(w http.ResponseWriter, r *http.Request) {
+	var usage []*types.QuotaUsage
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"QuotaUsage",
+		struct{}{},
+		&usage,
+	)
+	if ns := api.namespace(r); ns != "" {
+		user := api.user(r)
+		var own []*types.QuotaUsage
+		for _, u := range usage {
+			if u.Namespace == ns || u.User == user {
+				own = append(own, u)
+			}
+		}
+		usage = own
+	}
+	api.sendResponse(w, autoStatus, err, usage)
+}
+
 func (api *API) metricsHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	name := vars["name"]
@@ -674,6 +1317,7 @@ func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
 		api.sendResponse(w, http.StatusBadRequest, err, nil)
 		return
 	}
+	api.scopePinOptions(r, &params.PinOptions)
 
 	api.setHeaders(w)
 
@@ -685,6 +1329,10 @@ func (api *API) addHandler(w http.ResponseWriter, r *http.Request) {
 		reader,
 		w,
 		nil,
+		adder.Limits{
+			MaxFiles:     api.config.MaxAddFiles,
+			MaxPathDepth: api.config.MaxAddPathDepth,
+		},
 	)
 
 	return
@@ -704,6 +1352,42 @@ func (api *API) peerListHandler(w http.ResponseWriter, r *http.Request) {
 	api.sendResponse(w, autoStatus, err, peers)
 }
 
+func (api *API) peerNamesHandler(w http.ResponseWriter, r *http.Request) {
+	var names map[string]string
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PeerNames",
+		struct{}{},
+		&names,
+	)
+	api.sendResponse(w, autoStatus, err, names)
+}
+
+func (api *API) faultsHandler(w http.ResponseWriter, r *http.Request) {
+	api.sendResponse(w, autoStatus, nil, faults.Get())
+}
+
+func (api *API) setFaultsHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var settings faults.Settings
+	err := dec.Decode(&settings)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+	err = faults.Set(settings)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	logger.Warningf("fault injection settings changed: %+v", settings)
+	api.sendResponse(w, autoStatus, nil, faults.Get())
+}
+
 func (api *API) peerAddHandler(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	defer r.Body.Close()
@@ -733,8 +1417,108 @@ func (api *API) peerAddHandler(w http.ResponseWriter, r *http.Request) {
 	api.sendResponse(w, autoStatus, err, &id)
 }
 
+func (api *API) knownPeersHandler(w http.ResponseWriter, r *http.Request) {
+	var known []*types.KnownPeer
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"KnownPeers",
+		struct{}{},
+		&known,
+	)
+	api.sendResponse(w, autoStatus, err, known)
+}
+
+func (api *API) importKnownPeersHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var known []*types.KnownPeer
+	err := dec.Decode(&known)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ImportKnownPeers",
+		known,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) peerMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	p := api.parsePidOrError(w, r)
+	if p == "" {
+		return
+	}
+
+	q := r.URL.Query()
+	var m types.PeerMaintenance
+	var err error
+	m.Peer = p
+	m.Enabled, err = strconv.ParseBool(q.Get("enabled"))
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding enabled parameter"), nil)
+		return
+	}
+	if repin := q.Get("repin"); repin != "" {
+		m.Repin, err = strconv.ParseBool(repin)
+		if err != nil {
+			api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding repin parameter"), nil)
+			return
+		}
+	}
+
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PeerMaintenance",
+		&m,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) peerActivateHandler(w http.ResponseWriter, r *http.Request) {
+	p := api.parsePidOrError(w, r)
+	if p == "" {
+		return
+	}
+
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PeerActivate",
+		p,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
 func (api *API) peerRemoveHandler(w http.ResponseWriter, r *http.Request) {
 	if p := api.parsePidOrError(w, r); p != "" {
+		if r.URL.Query().Get("dry-run") == "true" {
+			var report types.PeerRemoveReport
+			err := api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				"PeerRemoveDryRun",
+				p,
+				&report,
+			)
+			api.sendResponse(w, autoStatus, err, report)
+			return
+		}
+
 		err := api.rpcClient.CallContext(
 			r.Context(),
 			"",
@@ -748,15 +1532,24 @@ func (api *API) peerRemoveHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) pinHandler(w http.ResponseWriter, r *http.Request) {
+	force, ok := api.parseForceOrError(w, r)
+	if !ok {
+		return
+	}
 	if pin := api.parseCidOrError(w, r); pin != nil {
 		logger.Debugf("rest api pinHandler: %s", pin.Cid)
+		api.scopePinOptions(r, &pin.PinOptions)
 		// span.AddAttributes(trace.StringAttribute("cid", pin.Cid))
+		method := "Pin"
+		if force {
+			method = "ForcePin"
+		}
 		var pinObj types.Pin
 		err := api.rpcClient.CallContext(
 			r.Context(),
 			"",
 			"Cluster",
-			"Pin",
+			method,
 			pin,
 			&pinObj,
 		)
@@ -765,16 +1558,189 @@ func (api *API) pinHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// streamPinsHandler upgrades the connection to a websocket on which the
+// client sends one CID per message. Every CID is pinned with the options
+// given in the query and acknowledged with a PinAck, in order, once the
+// pin has been committed. When the pin tracker has more than
+// StreamPinsMaxPending operations queued, no more pins are committed until
+// the queue shrinks, and the client is slowed down by not reading further
+// messages.
+func (api *API) streamPinsHandler(w http.ResponseWriter, r *http.Request) {
+	opts := types.PinOptions{}
+	err := opts.FromQuery(r.URL.Query())
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+	api.scopePinOptions(r, &opts)
+
+	casing := w.Header().Get(FieldCasingHeader)
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an error.
+		logger.Error(err)
+		return
+	}
+	defer conn.Close()
+	// Clear any deadlines set by the HTTP server on the hijacked
+	// connection: the stream may last for a very long time.
+	conn.UnderlyingConn().SetDeadline(time.Time{})
+
+	ctx := r.Context()
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Error(err)
+			}
+			return
+		}
+
+		ack := &types.PinAck{}
+		ack.Cid, err = cid.Decode(strings.TrimSpace(string(msg)))
+		if err == nil {
+			err = api.waitPendingOperations(ctx)
+		}
+		if err == nil {
+			pin := types.PinWithOpts(ack.Cid, opts)
+			pin.MaxDepth = -1
+			err = api.rpcClient.CallContext(
+				ctx,
+				"",
+				"Cluster",
+				"Pin",
+				pin,
+				&types.Pin{},
+			)
+		}
+		if err != nil {
+			ack.Error = err.Error()
+		}
+
+		if err := conn.WriteJSON(recase(ack, casing)); err != nil {
+			logger.Error(err)
+			return
+		}
+	}
+}
+
+// pinManifestHandler pins the entries of the manifest in the request body,
+// in order, with the options given in the query overridden by those of
+// each entry. The manifest is read as CSV when the Content-Type is
+// "text/csv" and as JSON otherwise. It responds with a PinAck for every
+// entry. Like streamed pins, entries are not committed while the pin
+// tracker has more than StreamPinsMaxPending operations queued.
+func (api *API) pinManifestHandler(w http.ResponseWriter, r *http.Request) {
+	opts := types.PinOptions{}
+	err := opts.FromQuery(r.URL.Query())
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	format := types.ManifestJSON
+	if mediatype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediatype == "text/csv" {
+		format = types.ManifestCSV
+	}
+	defer r.Body.Close()
+	entries, err := types.ParseManifest(r.Body, format)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	ctx := r.Context()
+	acks := make([]*types.PinAck, 0, len(entries))
+	for _, e := range entries {
+		ack := &types.PinAck{Cid: e.Cid}
+		err := api.waitPendingOperations(ctx)
+		if err == nil {
+			pin := types.PinWithOpts(e.Cid, e.PinOptions(opts))
+			api.scopePinOptions(r, &pin.PinOptions)
+			pin.MaxDepth = -1
+			err = api.rpcClient.CallContext(
+				ctx,
+				"",
+				"Cluster",
+				"Pin",
+				pin,
+				&types.Pin{},
+			)
+		}
+		if err != nil {
+			ack.Error = err.Error()
+		}
+		acks = append(acks, ack)
+	}
+	api.sendResponse(w, autoStatus, nil, acks)
+}
+
+// waitPendingOperations blocks while the local pin tracker has more
+// operations queued than allowed by StreamPinsMaxPending.
+func (api *API) waitPendingOperations(ctx context.Context) error {
+	ticker := time.NewTicker(streamPinsCheckInterval)
+	defer ticker.Stop()
+	for {
+		var pending int
+		err := api.rpcClient.CallContext(
+			ctx,
+			"",
+			"PinTracker",
+			"PendingOperations",
+			struct{}{},
+			&pending,
+		)
+		if err != nil || pending < api.config.StreamPinsMaxPending {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseForceOrError returns whether the request forces the unpin of
+// protected pins (or a re-pin lifting their protection), which only admins
+// can do. It returns false in ok when an
+// error response has been sent.
+func (api *API) parseForceOrError(w http.ResponseWriter, r *http.Request) (force bool, ok bool) {
+	v := r.URL.Query().Get("force")
+	if v == "" {
+		return false, true
+	}
+	force, err := strconv.ParseBool(v)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding force parameter"), nil)
+		return false, false
+	}
+	if force && api.role(r) != RoleAdmin {
+		api.sendResponse(w, http.StatusForbidden, errors.New("forcing pins or unpins is only allowed for admins"), nil)
+		return false, false
+	}
+	return force, true
+}
+
 func (api *API) unpinHandler(w http.ResponseWriter, r *http.Request) {
-	if pin := api.parseCidOrError(w, r); pin != nil {
+	force, ok := api.parseForceOrError(w, r)
+	if !ok {
+		return
+	}
+	if pin := api.parseCidOrError(w, r); pin != nil && api.checkPinNamespace(w, r, pin.Cid) {
 		logger.Debugf("rest api unpinHandler: %s", pin.Cid)
 		// span.AddAttributes(trace.StringAttribute("cid", pin.Cid))
+		method := "Unpin"
+		if force {
+			method = "ForceUnpin"
+		}
 		var pinObj types.Pin
 		err := api.rpcClient.CallContext(
 			r.Context(),
 			"",
 			"Cluster",
-			"Unpin",
+			method,
 			pin,
 			&pinObj,
 		)
@@ -791,6 +1757,7 @@ func (api *API) pinPathHandler(w http.ResponseWriter, r *http.Request) {
 	var pin types.Pin
 	if pinpath := api.parsePinPathOrError(w, r); pinpath != nil {
 		logger.Debugf("rest api pinPathHandler: %s", pinpath.Path)
+		api.scopePinOptions(r, &pinpath.PinOptions)
 		err := api.rpcClient.CallContext(
 			r.Context(),
 			"",
@@ -806,17 +1773,51 @@ func (api *API) pinPathHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) unpinPathHandler(w http.ResponseWriter, r *http.Request) {
+	force, ok := api.parseForceOrError(w, r)
+	if !ok {
+		return
+	}
 	var pin types.Pin
 	if pinpath := api.parsePinPathOrError(w, r); pinpath != nil {
 		logger.Debugf("rest api unpinPathHandler: %s", pinpath.Path)
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"UnpinPath",
-			pinpath,
-			&pin,
-		)
+		var c cid.Cid
+		if force || api.namespace(r) != "" {
+			err := api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"IPFSConnector",
+				"Resolve",
+				pinpath.Path,
+				&c,
+			)
+			if err != nil {
+				api.sendResponse(w, autoStatus, err, nil)
+				return
+			}
+			if !api.checkPinNamespace(w, r, c) {
+				return
+			}
+		}
+		var err error
+		if force {
+			err = api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				"ForceUnpin",
+				types.PinCid(c),
+				&pin,
+			)
+		} else {
+			err = api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				"UnpinPath",
+				pinpath,
+				&pin,
+			)
+		}
 		if err != nil && err.Error() == state.ErrNotFound.Error() {
 			api.sendResponse(w, http.StatusNotFound, err, nil)
 			return
@@ -827,39 +1828,56 @@ func (api *API) unpinPathHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (api *API) allocationsHandler(w http.ResponseWriter, r *http.Request) {
-	queryValues := r.URL.Query()
-	filterStr := queryValues.Get("filter")
-	var filter types.PinType
-	for _, f := range strings.Split(filterStr, ",") {
-		filter |= types.PinTypeFromString(f)
-	}
-
-	if filter == types.BadType {
-		api.sendResponse(w, http.StatusBadRequest, errors.New("invalid filter value"), nil)
+	query := &types.PinQuery{}
+	err := query.FromQuery(r.URL.Query())
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
 		return
 	}
+	query.Namespace = api.listNamespace(r)
 
-	var pins []*types.Pin
-	err := api.rpcClient.CallContext(
+	pins := make([]*types.Pin, 0)
+	err = api.rpcClient.CallContext(
 		r.Context(),
 		"",
 		"Cluster",
-		"Pins",
-		struct{}{},
+		"PinsQuery",
+		query,
 		&pins,
 	)
-	outPins := make([]*types.Pin, 0)
-	for _, pin := range pins {
-		if filter&pin.Type > 0 {
-			// add this pin to output
-			outPins = append(outPins, pin)
-		}
+	api.sendResponse(w, autoStatus, err, pins)
+}
+
+func (api *API) pinsViewHandler(w http.ResponseWriter, r *http.Request) {
+	query := &types.PinViewQuery{}
+	err := query.FromQuery(r.URL.Query())
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
 	}
-	api.sendResponse(w, autoStatus, err, outPins)
+	if query.Limit == 0 {
+		query.Limit = defaultPinViewLimit
+	}
+	if query.Limit > maxPinViewLimit {
+		api.sendResponse(w, http.StatusBadRequest, fmt.Errorf("limit cannot be larger than %d", maxPinViewLimit), nil)
+		return
+	}
+	query.Namespace = api.listNamespace(r)
+
+	var page types.PinViewPage
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinsView",
+		query,
+		&page,
+	)
+	api.sendResponse(w, autoStatus, err, page)
 }
 
 func (api *API) allocationHandler(w http.ResponseWriter, r *http.Request) {
-	if pin := api.parseCidOrError(w, r); pin != nil {
+	if pin := api.parseCidOrError(w, r); pin != nil && api.checkPinNamespace(w, r, pin.Cid) {
 		var pinResp types.Pin
 		err := api.rpcClient.CallContext(
 			r.Context(),
@@ -877,6 +1895,76 @@ func (api *API) allocationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (api *API) allocationExclusionsHandler(w http.ResponseWriter, r *http.Request) {
+	var ex types.AllocationExclusions
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"AllocationExclusions",
+		struct{}{},
+		&ex,
+	)
+	api.sendResponse(w, autoStatus, err, ex)
+}
+
+func (api *API) setAllocationExclusionsHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var ex types.AllocationExclusions
+	err := dec.Decode(&ex)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"SetAllocationExclusions",
+		&ex,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) provideStrategyHandler(w http.ResponseWriter, r *http.Request) {
+	var ps types.ProvideStrategy
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ProvideStrategy",
+		struct{}{},
+		&ps,
+	)
+	api.sendResponse(w, autoStatus, err, ps)
+}
+
+func (api *API) setProvideStrategyHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var ps types.ProvideStrategy
+	err := dec.Decode(&ps)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"SetProvideStrategy",
+		&ps,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
 // filterGlobalPinInfos takes a GlobalPinInfo slice and discards
 // any item in it which does not carry a PinInfo matching the
 // filter (OR-wise).
@@ -898,70 +1986,416 @@ func filterGlobalPinInfos(globalPinInfos []*types.GlobalPinInfo, filter types.Tr
 		}
 	}
 
-	return filteredGlobalPinInfos
+	return filteredGlobalPinInfos
+}
+
+// filterGlobalPinInfosByNamespace discards the items in a GlobalPinInfo
+// slice which do not correspond to any of the given pins in the given
+// namespace.
+func filterGlobalPinInfosByNamespace(globalPinInfos []*types.GlobalPinInfo, pins []*types.Pin, ns string) []*types.GlobalPinInfo {
+	inNamespace := make(map[cid.Cid]struct{})
+	for _, pin := range pins {
+		if pin.Namespace == ns {
+			inNamespace[pin.Cid] = struct{}{}
+		}
+	}
+
+	var filteredGlobalPinInfos []*types.GlobalPinInfo
+	for _, globalPinInfo := range globalPinInfos {
+		if _, ok := inNamespace[globalPinInfo.Cid]; ok {
+			filteredGlobalPinInfos = append(filteredGlobalPinInfos, globalPinInfo)
+		}
+	}
+	return filteredGlobalPinInfos
+}
+
+func (api *API) statusChangesHandler(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	err := since.UnmarshalText([]byte(r.URL.Query().Get("since")))
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("since must be a RFC3339 timestamp"), nil)
+		return
+	}
+
+	var changes types.StatusChanges
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"StatusChanges",
+		since,
+		&changes,
+	)
+	api.sendResponse(w, autoStatus, err, changes)
+}
+
+func (api *API) statusAllHandler(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	local := queryValues.Get("local")
+
+	var globalPinInfos []*types.GlobalPinInfo
+
+	filterStr := queryValues.Get("filter")
+	filter := types.TrackerStatusFromString(filterStr)
+	if filter == types.TrackerStatusUndefined && filterStr != "" {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("invalid filter value"), nil)
+		return
+	}
+
+	if local == "true" {
+		var pinInfos []*types.PinInfo
+
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"StatusAllLocal",
+			struct{}{},
+			&pinInfos,
+		)
+		if err != nil {
+			api.sendResponse(w, autoStatus, err, nil)
+			return
+		}
+		globalPinInfos = pinInfosToGlobal(pinInfos)
+	} else {
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"StatusAll",
+			struct{}{},
+			&globalPinInfos,
+		)
+		if err != nil {
+			api.sendResponse(w, autoStatus, err, nil)
+			return
+		}
+	}
+
+	globalPinInfos = filterGlobalPinInfos(globalPinInfos, filter)
+
+	if ns := api.listNamespace(r); ns != "" {
+		var pins []*types.Pin
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"Pins",
+			struct{}{},
+			&pins,
+		)
+		if err != nil {
+			api.sendResponse(w, autoStatus, err, nil)
+			return
+		}
+		globalPinInfos = filterGlobalPinInfosByNamespace(globalPinInfos, pins, ns)
+	}
+
+	api.sendResponse(w, autoStatus, nil, globalPinInfos)
+}
+
+func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	local := queryValues.Get("local")
+
+	if pin := api.parseCidOrError(w, r); pin != nil && api.checkPinNamespace(w, r, pin.Cid) {
+		if local == "true" {
+			var pinInfo types.PinInfo
+			err := api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				"StatusLocal",
+				pin.Cid,
+				&pinInfo,
+			)
+			api.sendResponse(w, autoStatus, err, pinInfoToGlobal(&pinInfo))
+		} else {
+			var pinInfo types.GlobalPinInfo
+			err := api.rpcClient.CallContext(
+				r.Context(),
+				"",
+				"Cluster",
+				"Status",
+				pin.Cid,
+				&pinInfo,
+			)
+			api.sendResponse(w, autoStatus, err, pinInfo)
+		}
+	}
+}
+
+func (api *API) recoverAllHandler(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	local := queryValues.Get("local")
+	if local == "true" {
+		var pinInfos []*types.PinInfo
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"RecoverAllLocal",
+			struct{}{},
+			&pinInfos,
+		)
+		api.sendResponse(w, autoStatus, err, pinInfosToGlobal(pinInfos))
+	} else {
+		var globalPinInfos []*types.GlobalPinInfo
+		err := api.rpcClient.CallContext(
+			r.Context(),
+			"",
+			"Cluster",
+			"RecoverAll",
+			struct{}{},
+			&globalPinInfos,
+		)
+		api.sendResponse(w, autoStatus, err, globalPinInfos)
+	}
+}
+
+func (api *API) scheduleRecoverHandler(w http.ResponseWriter, r *http.Request) {
+	queryValues := r.URL.Query()
+	rs := &types.RecoverSchedule{
+		Local: queryValues.Get("local") == "true",
+	}
+
+	if v := queryValues.Get("cid"); v != "" {
+		c, err := cid.Decode(v)
+		if err != nil {
+			api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding Cid: "+err.Error()), nil)
+			return
+		}
+		if !api.checkPinNamespace(w, r, c) {
+			return
+		}
+		rs.Cid = c
+	}
+
+	interval, err := time.ParseDuration(queryValues.Get("interval"))
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error parsing interval: "+err.Error()), nil)
+		return
+	}
+	rs.Interval = interval
+
+	maxAttempts, err := strconv.Atoi(queryValues.Get("max_attempts"))
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error parsing max_attempts: "+err.Error()), nil)
+		return
+	}
+	rs.MaxAttempts = maxAttempts
+
+	if err := rs.Validate(); err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	var sched types.RecoverSchedule
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ScheduleRecover",
+		rs,
+		&sched,
+	)
+	api.sendResponse(w, autoStatus, err, sched)
+}
+
+func (api *API) recoverSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	var scheds []*types.RecoverSchedule
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"RecoverSchedules",
+		struct{}{},
+		&scheds,
+	)
+	api.sendResponse(w, autoStatus, err, scheds)
+}
+
+func (api *API) cancelRecoverScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"CancelRecoverSchedule",
+		vars["id"],
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) submitJobHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var j types.Job
+	err := dec.Decode(&j)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+
+	if err := j.Validate(); err != nil {
+		api.sendResponse(w, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	var queued types.Job
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"SubmitJob",
+		&j,
+		&queued,
+	)
+	api.sendResponse(w, autoStatus, err, queued)
+}
+
+func (api *API) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	var jobs []*types.Job
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Jobs",
+		struct{}{},
+		&jobs,
+	)
+	api.sendResponse(w, autoStatus, err, jobs)
+}
+
+func (api *API) jobHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var j types.Job
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Job",
+		vars["id"],
+		&j,
+	)
+	api.sendResponse(w, autoStatus, err, j)
+}
+
+func (api *API) cancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"CancelJob",
+		vars["id"],
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) collectionsHandler(w http.ResponseWriter, r *http.Request) {
+	var cols []*types.Collection
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Collections",
+		struct{}{},
+		&cols,
+	)
+	api.sendResponse(w, autoStatus, err, cols)
+}
+
+func (api *API) collectionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var col types.Collection
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Collection",
+		vars["name"],
+		&col,
+	)
+	api.sendResponse(w, autoStatus, err, col)
 }
 
-func (api *API) statusAllHandler(w http.ResponseWriter, r *http.Request) {
-	queryValues := r.URL.Query()
-	local := queryValues.Get("local")
+func (api *API) collectionStatusHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var gpis []*types.GlobalPinInfo
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"CollectionStatus",
+		vars["name"],
+		&gpis,
+	)
+	api.sendResponse(w, autoStatus, err, gpis)
+}
 
-	var globalPinInfos []*types.GlobalPinInfo
+func (api *API) pinCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
 
-	filterStr := queryValues.Get("filter")
-	filter := types.TrackerStatusFromString(filterStr)
-	if filter == types.TrackerStatusUndefined && filterStr != "" {
-		api.sendResponse(w, http.StatusBadRequest, errors.New("invalid filter value"), nil)
+	var col types.Collection
+	err := dec.Decode(&col)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
 		return
 	}
-
-	if local == "true" {
-		var pinInfos []*types.PinInfo
-
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"StatusAllLocal",
-			struct{}{},
-			&pinInfos,
-		)
-		if err != nil {
-			api.sendResponse(w, autoStatus, err, nil)
-			return
-		}
-		globalPinInfos = pinInfosToGlobal(pinInfos)
-	} else {
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"StatusAll",
-			struct{}{},
-			&globalPinInfos,
-		)
-		if err != nil {
-			api.sendResponse(w, autoStatus, err, nil)
-			return
-		}
+	col.Name = mux.Vars(r)["name"]
+	if len(col.Cids) == 0 {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("collection has no cids"), nil)
+		return
+	}
+	if col.Options == nil {
+		col.Options = &types.PinOptions{}
 	}
+	api.scopePinOptions(r, col.Options)
 
-	globalPinInfos = filterGlobalPinInfos(globalPinInfos, filter)
+	var pinned types.Collection
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PinCollection",
+		&col,
+		&pinned,
+	)
+	api.sendResponse(w, autoStatus, err, pinned)
+}
 
-	api.sendResponse(w, autoStatus, nil, globalPinInfos)
+func (api *API) unpinCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	var col types.Collection
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"UnpinCollection",
+		vars["name"],
+		&col,
+	)
+	api.sendResponse(w, autoStatus, err, col)
 }
 
-func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
+func (api *API) recoverHandler(w http.ResponseWriter, r *http.Request) {
 	queryValues := r.URL.Query()
 	local := queryValues.Get("local")
 
-	if pin := api.parseCidOrError(w, r); pin != nil {
+	if pin := api.parseCidOrError(w, r); pin != nil && api.checkPinNamespace(w, r, pin.Cid) {
 		if local == "true" {
 			var pinInfo types.PinInfo
 			err := api.rpcClient.CallContext(
 				r.Context(),
 				"",
 				"Cluster",
-				"StatusLocal",
+				"RecoverLocal",
 				pin.Cid,
 				&pinInfo,
 			)
@@ -972,7 +2406,7 @@ func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
 				r.Context(),
 				"",
 				"Cluster",
-				"Status",
+				"Recover",
 				pin.Cid,
 				&pinInfo,
 			)
@@ -981,63 +2415,62 @@ func (api *API) statusHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (api *API) recoverAllHandler(w http.ResponseWriter, r *http.Request) {
-	queryValues := r.URL.Query()
-	local := queryValues.Get("local")
-	if local == "true" {
-		var pinInfos []*types.PinInfo
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"RecoverAllLocal",
-			struct{}{},
-			&pinInfos,
-		)
-		api.sendResponse(w, autoStatus, err, pinInfosToGlobal(pinInfos))
-	} else {
-		var globalPinInfos []*types.GlobalPinInfo
-		err := api.rpcClient.CallContext(
-			r.Context(),
-			"",
-			"Cluster",
-			"RecoverAll",
-			struct{}{},
-			&globalPinInfos,
-		)
-		api.sendResponse(w, autoStatus, err, globalPinInfos)
-	}
+func (api *API) shardsGCHandler(w http.ResponseWriter, r *http.Request) {
+	var unpinned []*types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"ShardsGC",
+		struct{}{},
+		&unpinned,
+	)
+	api.sendResponse(w, autoStatus, err, unpinned)
 }
 
-func (api *API) recoverHandler(w http.ResponseWriter, r *http.Request) {
-	queryValues := r.URL.Query()
-	local := queryValues.Get("local")
+func (api *API) stateCheckpointHandler(w http.ResponseWriter, r *http.Request) {
+	var pin types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"StateCheckpoint",
+		struct{}{},
+		&pin,
+	)
+	api.sendResponse(w, autoStatus, err, pin)
+}
 
-	if pin := api.parseCidOrError(w, r); pin != nil {
-		if local == "true" {
-			var pinInfo types.PinInfo
-			err := api.rpcClient.CallContext(
-				r.Context(),
-				"",
-				"Cluster",
-				"RecoverLocal",
-				pin.Cid,
-				&pinInfo,
-			)
-			api.sendResponse(w, autoStatus, err, pinInfoToGlobal(&pinInfo))
-		} else {
-			var pinInfo types.GlobalPinInfo
-			err := api.rpcClient.CallContext(
-				r.Context(),
-				"",
-				"Cluster",
-				"Recover",
-				pin.Cid,
-				&pinInfo,
-			)
-			api.sendResponse(w, autoStatus, err, pinInfo)
-		}
+func (api *API) stateCheckpointsHandler(w http.ResponseWriter, r *http.Request) {
+	var checkpoints []*types.Pin
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"StateCheckpoints",
+		struct{}{},
+		&checkpoints,
+	)
+	api.sendResponse(w, autoStatus, err, checkpoints)
+}
+
+func (api *API) stateCheckpointPinsHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := cid.Decode(mux.Vars(r)["hash"])
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding Cid: "+err.Error()), nil)
+		return
 	}
+
+	var pins []*types.Pin
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"StateCheckpointPins",
+		c,
+		&pins,
+	)
+	api.sendResponse(w, autoStatus, err, pins)
 }
 
 func (api *API) repoGCHandler(w http.ResponseWriter, r *http.Request) {
@@ -1071,6 +2504,147 @@ func (api *API) repoGCHandler(w http.ResponseWriter, r *http.Request) {
 	api.sendResponse(w, autoStatus, err, repoGC)
 }
 
+func (api *API) prefetchHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := cid.Decode(mux.Vars(r)["hash"])
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding Cid: "+err.Error()), nil)
+		return
+	}
+
+	var peers []peer.ID
+	if peersStr := r.URL.Query().Get("peers"); peersStr != "" {
+		for _, p := range strings.Split(peersStr, ",") {
+			pid, err := peer.IDB58Decode(p)
+			if err != nil {
+				api.sendResponse(w, http.StatusBadRequest, fmt.Errorf("error decoding peer ID %s: %s", p, err), nil)
+				return
+			}
+			peers = append(peers, pid)
+		}
+	}
+
+	var results []*types.PrefetchResult
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"Prefetch",
+		&types.Prefetch{
+			Cid:   c,
+			Peers: peers,
+		},
+		&results,
+	)
+	api.sendResponse(w, autoStatus, err, results)
+}
+
+func (api *API) setLogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	logLevel := &types.LogLevel{
+		Facility: vars["facility"],
+		Level:    vars["level"],
+	}
+
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"SetLogLevel",
+		logLevel,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) upgradeHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := &types.UpgradeOptions{
+		Version: q.Get("version"),
+		DistURL: q.Get("dist-url"),
+		Force:   q.Get("force") == "true",
+	}
+	if opts.Version == "" {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("missing version"), nil)
+		return
+	}
+
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"RollingUpgrade",
+		opts,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) beginSecretRotationHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var body secretRotationBody
+	err := dec.Decode(&body)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+
+	secret, err := hex.DecodeString(body.Secret)
+	if err != nil || len(secret) != 32 {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("the secret should be 64 hexadecimal characters"), nil)
+		return
+	}
+
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"BeginSecretRotation",
+		secret,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) finalizeSecretRotationHandler(w http.ResponseWriter, r *http.Request) {
+	err := api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"FinalizeSecretRotation",
+		struct{}{},
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
+func (api *API) pushConfigHandler(w http.ResponseWriter, r *http.Request) {
+	dec := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var sections []*types.ConfigSection
+	err := dec.Decode(&sections)
+	if err != nil {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("error decoding request body"), nil)
+		return
+	}
+	if len(sections) == 0 {
+		api.sendResponse(w, http.StatusBadRequest, errors.New("no configuration sections given"), nil)
+		return
+	}
+
+	err = api.rpcClient.CallContext(
+		r.Context(),
+		"",
+		"Cluster",
+		"PushConfig",
+		sections,
+		&struct{}{},
+	)
+	api.sendResponse(w, autoStatus, err, nil)
+}
+
 func repoGCToGlobal(r *types.RepoGC) types.GlobalRepoGC {
 	return types.GlobalRepoGC{
 		PeerMap: map[string]*types.RepoGC{
@@ -1166,14 +2740,22 @@ func (api *API) sendResponse(
 
 	// Send an error
 	if err != nil {
+		code := types.ErrorCodeOf(err)
 		if status == autoStatus || status < 400 { // set a default error status
-			status = http.StatusInternalServerError
+			status = errorCodeStatus(code)
+		}
+		// Round up, so that clients do not retry too early.
+		retryAfter := int((types.RetryAfterOf(err) + time.Second - 1) / time.Second)
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
 		}
 		w.WriteHeader(status)
 
 		errorResp := types.Error{
-			Code:    status,
-			Message: err.Error(),
+			Code:       status,
+			Message:    err.Error(),
+			ErrorCode:  code,
+			RetryAfter: retryAfter,
 		}
 		logger.Errorf("sending error response: %d: %s", status, err.Error())
 
@@ -1191,7 +2773,7 @@ func (api *API) sendResponse(
 
 		w.WriteHeader(status)
 
-		if err = enc.Encode(resp); err != nil {
+		if err = enc.Encode(recase(resp, w.Header().Get(FieldCasingHeader))); err != nil {
 			logger.Error(err)
 		}
 		return
@@ -1205,6 +2787,26 @@ func (api *API) sendResponse(
 	w.WriteHeader(status)
 }
 
+// errorCodeStatus returns the HTTP status for errors with the given code.
+func errorCodeStatus(code types.ErrorCode) int {
+	switch code {
+	case types.ErrCodeNotFound:
+		return http.StatusNotFound
+	case types.ErrCodeQuotaExceeded:
+		return http.StatusForbidden
+	case types.ErrCodeOutdatedState:
+		return http.StatusConflict
+	case types.ErrCodePinProtected:
+		return http.StatusLocked
+	case types.ErrCodeNoSpace:
+		return http.StatusInsufficientStorage
+	case types.ErrCodeNotLeader, types.ErrCodePinQueueFull, types.ErrCodeShuttingDown, types.ErrCodeOverloaded:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // this sets all the headers that are common to all responses
 // from this API. Called from sendResponse() and /add.
 func (api *API) setHeaders(w http.ResponseWriter) {
@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/http/httputil"
 	"os"
 	"path/filepath"
@@ -19,6 +20,7 @@ import (
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/faults"
 	"github.com/ipfs/ipfs-cluster/test"
 
 	cid "github.com/ipfs/go-cid"
@@ -28,6 +30,9 @@ import (
 	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
 	p2phttp "github.com/libp2p/go-libp2p-http"
 	ma "github.com/multiformats/go-multiaddr"
+
+	websocket "github.com/gorilla/websocket"
+	"go.opencensus.io/trace"
 )
 
 const (
@@ -371,6 +376,25 @@ func TestAPIPeerstEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIPeerNamesEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var names map[string]string
+		makeGet(t, rest, url(rest)+"/peers/names", &names)
+		if len(names) != 3 {
+			t.Fatal("expected 3 peer names")
+		}
+		if names[peer.IDB58Encode(test.PeerID1)] != test.PeerName1 {
+			t.Error("unexpected peer name: ", names)
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestAPIPeerAddEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -535,6 +559,63 @@ func TestAPIPeerRemoveEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIPeerMaintenanceEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		path := url(rest) + "/peers/" + test.PeerID1.Pretty() + "/maintenance"
+		makePost(t, rest, path+"?enabled=true&repin=true", []byte{}, &struct{}{})
+		makePost(t, rest, path+"?enabled=false", []byte{}, &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, path+"?enabled=maybe", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request with an invalid enabled parameter")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIPeerActivateEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		makePost(t, rest, url(rest)+"/peers/"+test.PeerID1.Pretty()+"/activate", []byte{}, &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/peers/abc/activate", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request with an invalid peer ID")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIPeerRemoveDryRunEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var report api.PeerRemoveReport
+		makeDelete(t, rest, url(rest)+"/peers/"+test.PeerID1.Pretty()+"?dry-run=true", &report)
+		if report.Peer != test.PeerID1 {
+			t.Error("unexpected peer in report")
+		}
+		if report.ReallocatedPins != 2 || report.Receivers[test.PeerID2.Pretty()] != 2 {
+			t.Error("unexpected report")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestConnectGraphEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -593,6 +674,48 @@ func TestAPIPinEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIStreamPinsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	wsURL := "ws" + strings.TrimPrefix(httpURL(rest), "http") + "/pins/stream?name=stream"
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	inputs := []string{test.Cid1.String(), test.ErrorCid.String(), "abcd"}
+	for _, in := range inputs {
+		err := conn.WriteMessage(websocket.TextMessage, []byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, in := range inputs {
+		var ack api.PinAck
+		if err := conn.ReadJSON(&ack); err != nil {
+			t.Fatal(err)
+		}
+		switch i {
+		case 0:
+			if ack.Error != "" || ack.Cid.String() != in {
+				t.Error("expected a successful ack for", in, ack)
+			}
+		case 1:
+			if ack.Error != test.ErrBadCid.Error() {
+				t.Error("expected different error: ", ack.Error)
+			}
+		case 2:
+			if ack.Error == "" {
+				t.Error("should fail with bad Cid")
+			}
+		}
+	}
+}
+
 type pathCase struct {
 	path        string
 	opts        api.PinOptions
@@ -756,6 +879,52 @@ func TestAPIUnpinEndpointWithPath(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIPinsViewEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var page api.PinViewPage
+		makeGet(t, rest, url(rest)+"/pins/view", &page)
+		if page.Total != 3 || len(page.Items) != 3 {
+			t.Fatal("unexpected pin view: ", page)
+		}
+		for _, item := range page.Items {
+			if item.Pin == nil || item.Status == nil || !item.Status.Cid.Equals(item.Pin.Cid) {
+				t.Error("expected a pin with its status: ", item)
+			}
+		}
+
+		page = api.PinViewPage{}
+		makeGet(t, rest, url(rest)+"/pins/view?offset=1&limit=1", &page)
+		if page.Total != 3 || page.Offset != 1 || len(page.Items) != 1 ||
+			!page.Items[0].Pin.Cid.Equals(test.Cid2) {
+			t.Error("unexpected pin view page: ", page)
+		}
+
+		page = api.PinViewPage{}
+		makeGet(t, rest, url(rest)+"/pins/view?namespace="+test.Namespace1, &page)
+		if page.Total != 1 || !page.Items[0].Pin.Cid.Equals(test.Cid3) {
+			t.Error("unexpected pin view: ", page)
+		}
+
+		errResp := api.Error{}
+		makeGet(t, rest, url(rest)+"/pins/view?limit=100000", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("a too large limit should 400")
+		}
+
+		errResp = api.Error{}
+		makeGet(t, rest, url(rest)+"/pins/view?offset=abc", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("an invalid offset should 400")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestAPIAllocationsEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -777,11 +946,27 @@ func TestAPIAllocationsEndpoint(t *testing.T) {
 			t.Error("unexpected pin list: ", resp)
 		}
 
+		makeGet(t, rest, url(rest)+"/allocations?namespace="+test.Namespace1, &resp)
+		if len(resp) != 1 || !resp[0].Cid.Equals(test.Cid3) {
+			t.Error("unexpected pin list: ", resp)
+		}
+
+		makeGet(t, rest, url(rest)+"/allocations?meta-foo=", &resp)
+		if len(resp) != 0 {
+			t.Error("no pins have the metadata key: ", resp)
+		}
+
 		errResp := api.Error{}
 		makeGet(t, rest, url(rest)+"/allocations?filter=invalid", &errResp)
 		if errResp.Code != http.StatusBadRequest {
 			t.Error("an invalid filter value should 400")
 		}
+
+		errResp = api.Error{}
+		makeGet(t, rest, url(rest)+"/allocations?allocation=abc", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("an invalid allocation value should 400")
+		}
 	}
 
 	testBothEndpoints(t, tf)
@@ -809,6 +994,56 @@ func TestAPIAllocationEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
+func TestAPIAllocationExclusionsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var ex api.AllocationExclusions
+		makeGet(t, rest, url(rest)+"/allocations/exclusions", &ex)
+		if len(ex.Peers) != 1 || ex.Peers[0] != test.PeerID3 || ex.Tags["rack"][0] != "old" {
+			t.Errorf("unexpected exclusions: %+v", ex)
+		}
+
+		body := []byte(`{"peers": ["` + test.PeerID1.Pretty() + `"], "tags": {"rack": ["r1"]}}`)
+		makePost(t, rest, url(rest)+"/allocations/exclusions", body, &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/allocations/exclusions", []byte(`{"peers": ["abc"]}`), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request with an invalid peer ID")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIProvideStrategyEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var ps api.ProvideStrategy
+		makeGet(t, rest, url(rest)+"/ipfs/provide", &ps)
+		if ps.Strategy != api.ProvidePinned || len(ps.Peers) != 1 {
+			t.Errorf("unexpected provide strategy: %+v", ps)
+		}
+
+		body := []byte(`{"strategy": "roots", "peers": ["` + test.PeerID1.Pretty() + `"]}`)
+		makePost(t, rest, url(rest)+"/ipfs/provide", body, &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/ipfs/provide", []byte(`{"strategy": "some"}`), &errResp)
+		if errResp.Code != http.StatusInternalServerError {
+			t.Error("expected an error with an unknown strategy")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
 func TestAPIMetricsEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
@@ -833,174 +1068,664 @@ func TestAPIMetricsEndpoint(t *testing.T) {
 	testBothEndpoints(t, tf)
 }
 
-func TestAPIMetricNamesEndpoint(t *testing.T) {
+func TestAPIAlertsEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
 	defer rest.Shutdown(ctx)
 
 	tf := func(t *testing.T, url urlF) {
-		var resp []string
-		makeGet(t, rest, url(rest)+"/monitor/metrics", &resp)
-		if len(resp) == 0 {
-			t.Fatal("No metric names found")
+		var resp []*api.Alert
+		makeGet(t, rest, url(rest)+"/health/alerts", &resp)
+		if len(resp) != 1 {
+			t.Fatal("expected one alert")
+		}
+		if resp[0].Peer != test.PeerID2 || resp[0].MetricName != "ping" {
+			t.Error("unexpected alert")
 		}
 	}
 
 	testBothEndpoints(t, tf)
 }
 
-func TestAPIStatusAllEndpoint(t *testing.T) {
+func TestAPIPrefetchEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
 	defer rest.Shutdown(ctx)
 
 	tf := func(t *testing.T, url urlF) {
-		var resp []*api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins", &resp)
-
-		if len(resp) != 3 ||
-			!resp[0].Cid.Equals(test.Cid1) ||
-			resp[1].PeerMap[peer.IDB58Encode(test.PeerID1)].Status.String() != "pinning" {
-			t.Errorf("unexpected statusAll resp")
+		var resp []*api.PrefetchResult
+		makePost(t, rest, url(rest)+"/prefetch/"+test.Cid1.String(), []byte{}, &resp)
+		if len(resp) != 1 || resp[0].Peer != test.PeerID1 || resp[0].Error != "" {
+			t.Error("unexpected prefetch results: ", resp)
 		}
 
-		// Test local=true
-		var resp2 []*api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins?local=true", &resp2)
-		if len(resp2) != 2 {
-			t.Errorf("unexpected statusAll+local resp:\n %+v", resp2)
+		peers := peer.IDB58Encode(test.PeerID2) + "," + peer.IDB58Encode(test.PeerID3)
+		makePost(t, rest, url(rest)+"/prefetch/"+test.Cid1.String()+"?peers="+peers, []byte{}, &resp)
+		if len(resp) != 2 || resp[0].Peer != test.PeerID2 || resp[1].Peer != test.PeerID3 {
+			t.Error("unexpected prefetch results: ", resp)
 		}
 
-		// Test with filter
-		var resp3 []*api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins?filter=queued", &resp3)
-		if len(resp3) != 0 {
-			t.Errorf("unexpected statusAll+filter=queued resp:\n %+v", resp3)
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/prefetch/"+test.Cid1.String()+"?peers=abc", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("should fail with a bad peer ID")
 		}
 
-		var resp4 []*api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins?filter=pinned", &resp4)
-		if len(resp4) != 1 {
-			t.Errorf("unexpected statusAll+filter=pinned resp:\n %+v", resp4)
+		makePost(t, rest, url(rest)+"/prefetch/"+test.ErrorCid.String(), []byte{}, &errResp)
+		if errResp.Message != test.ErrBadCid.Error() {
+			t.Error("expected a different error: ", errResp.Message)
 		}
+	}
 
-		var resp5 []*api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins?filter=pin_error", &resp5)
-		if len(resp5) != 1 {
-			t.Errorf("unexpected statusAll+filter=pin_error resp:\n %+v", resp5)
-		}
+	testBothEndpoints(t, tf)
+}
 
-		var resp6 []*api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins?filter=error", &resp6)
-		if len(resp6) != 1 {
-			t.Errorf("unexpected statusAll+filter=error resp:\n %+v", resp6)
+func TestAPIStatusChangesEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		since := time.Now().UTC().Add(-time.Minute)
+		sinceStr, _ := since.MarshalText()
+		var resp api.StatusChanges
+		makeGet(t, rest, url(rest)+"/pins/changes?since="+string(sinceStr), &resp)
+		if !resp.Since.Equal(since) || resp.Until.Before(since) {
+			t.Error("unexpected since or until")
+		}
+		if len(resp.Changes) != 1 || !resp.Changes[0].Cid.Equals(test.Cid3) {
+			t.Error("unexpected changes: ", resp.Changes)
 		}
 
-		var resp7 []*api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins?filter=error,pinned", &resp7)
-		if len(resp7) != 2 {
-			t.Errorf("unexpected statusAll+filter=error,pinned resp:\n %+v", resp7)
+		errResp := api.Error{}
+		makeGet(t, rest, url(rest)+"/pins/changes?since=abc", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("should fail with a bad since value")
 		}
 
-		var errorResp api.Error
-		makeGet(t, rest, url(rest)+"/pins?filter=invalid", &errorResp)
-		if errorResp.Code != http.StatusBadRequest {
-			t.Error("an invalid filter value should 400")
+		zero, _ := time.Time{}.MarshalText()
+		makeGet(t, rest, url(rest)+"/pins/changes?since="+string(zero), &errResp)
+		if errResp.Message != test.ErrChangesTruncated.Error() {
+			t.Error("expected a different error: ", errResp.Message)
 		}
 	}
 
 	testBothEndpoints(t, tf)
 }
 
-func TestAPIStatusEndpoint(t *testing.T) {
+func TestAPITrackerOperationsEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
 	defer rest.Shutdown(ctx)
 
 	tf := func(t *testing.T, url urlF) {
-		var resp api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins/"+test.Cid1.String(), &resp)
-
-		if !resp.Cid.Equals(test.Cid1) {
-			t.Error("expected the same cid")
-		}
-		info, ok := resp.PeerMap[peer.IDB58Encode(test.PeerID1)]
-		if !ok {
-			t.Fatal("expected info for test.PeerID1")
+		var resp []*api.TrackerOperation
+		makeGet(t, rest, url(rest)+"/tracker/operations", &resp)
+		if len(resp) != 1 {
+			t.Fatal("expected one operation")
 		}
-		if info.Status.String() != "pinned" {
-			t.Error("expected different status")
+		if !resp[0].Cid.Equals(test.Cid1) || resp[0].Type != "pin" {
+			t.Error("unexpected operation")
 		}
+	}
 
-		// Test local=true
-		var resp2 api.GlobalPinInfo
-		makeGet(t, rest, url(rest)+"/pins/"+test.Cid1.String()+"?local=true", &resp2)
+	testBothEndpoints(t, tf)
+}
 
-		if !resp2.Cid.Equals(test.Cid1) {
-			t.Error("expected the same cid")
-		}
-		info, ok = resp2.PeerMap[peer.IDB58Encode(test.PeerID2)]
-		if !ok {
-			t.Fatal("expected info for test.PeerID2")
+func TestAPIRepinProgressEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var resp []*api.RepinProgress
+		makeGet(t, rest, url(rest)+"/health/repinning", &resp)
+		if len(resp) != 1 {
+			t.Fatal("expected one progress report")
 		}
-		if info.Status.String() != "pinned" {
-			t.Error("expected different status")
+		if resp[0].Peer != test.PeerID2 || resp[0].Total != 2 || resp[0].Repinned != 1 {
+			t.Error("unexpected progress report")
 		}
 	}
 
 	testBothEndpoints(t, tf)
 }
 
-func TestAPIRecoverEndpoint(t *testing.T) {
+func TestAPIPinManifestEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
 	defer rest.Shutdown(ctx)
 
 	tf := func(t *testing.T, url urlF) {
-		var resp api.GlobalPinInfo
-		makePost(t, rest, url(rest)+"/pins/"+test.Cid1.String()+"/recover", []byte{}, &resp)
-
-		if !resp.Cid.Equals(test.Cid1) {
-			t.Error("expected the same cid")
+		manifest := "cid,name,replication\n" +
+			test.Cid1.String() + ",one,2\n" +
+			test.ErrorCid.String() + ",,\n"
+		var resp []*api.PinAck
+		makePostWithContentType(t, rest, url(rest)+"/pins/manifest", []byte(manifest), "text/csv", &resp)
+		if len(resp) != 2 {
+			t.Fatal("expected an acknowledgement per entry")
 		}
-		info, ok := resp.PeerMap[peer.IDB58Encode(test.PeerID1)]
-		if !ok {
-			t.Fatal("expected info for test.PeerID1")
+		if !resp[0].Cid.Equals(test.Cid1) || resp[0].Error != "" {
+			t.Errorf("unexpected first acknowledgement: %+v", resp[0])
 		}
-		if info.Status.String() != "pinned" {
-			t.Error("expected different status")
+		if resp[1].Error == "" {
+			t.Error("expected an error pinning the second entry")
+		}
+
+		var errResp api.Error
+		makePostWithContentType(t, rest, url(rest)+"/pins/manifest", []byte("name\none\n"), "text/csv", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request error with an invalid manifest")
 		}
 	}
 
 	testBothEndpoints(t, tf)
 }
 
-func TestAPIRecoverAllEndpoint(t *testing.T) {
+func TestAPIStatsEndpoint(t *testing.T) {
 	ctx := context.Background()
 	rest := testAPI(t)
 	defer rest.Shutdown(ctx)
 
 	tf := func(t *testing.T, url urlF) {
-		var resp []*api.GlobalPinInfo
-		makePost(t, rest, url(rest)+"/pins/recover?local=true", []byte{}, &resp)
-		if len(resp) != 0 {
-			t.Fatal("bad response length")
+		var resp api.ClusterStats
+		makeGet(t, rest, url(rest)+"/health/stats", &resp)
+		if resp.Pins != 3 || resp.Leader != test.PeerID1 || len(resp.Peers) != 1 {
+			t.Fatal("unexpected stats")
 		}
-
-		var resp1 []*api.GlobalPinInfo
-		makePost(t, rest, url(rest)+"/pins/recover", []byte{}, &resp1)
-		if len(resp1) == 0 {
-			t.Fatal("bad response length")
+		if ps := resp.Peers[0]; ps.Pinned != 2 || ps.QueueDepth != 1 || ps.FreeSpace != 1000 {
+			t.Error("unexpected peer stats")
 		}
 	}
 
 	testBothEndpoints(t, tf)
 }
 
-func TestAPILogging(t *testing.T) {
+func TestAPIQuotaUsageEndpoint(t *testing.T) {
 	ctx := context.Background()
-	cfg := &Config{}
-	cfg.Default()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var resp []*api.QuotaUsage
+		makeGet(t, rest, url(rest)+"/usage", &resp)
+		if len(resp) != 2 {
+			t.Fatal("expected two usage reports")
+		}
+		if resp[0].Namespace != test.Namespace1 || resp[0].Pins != 1 || resp[0].Bytes != 1000 {
+			t.Error("unexpected namespace usage")
+		}
+		if resp[1].User != "alice" || resp[1].Pins != 2 {
+			t.Error("unexpected user usage")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIShardsGCEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var resp []*api.Pin
+		makePost(t, rest, url(rest)+"/shards/gc", []byte{}, &resp)
+		if len(resp) != 1 || !resp[0].Cid.Equals(test.Cid1) || resp[0].Type != api.ShardType {
+			t.Error("expected one unpinned shard")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIStateCheckpointEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var pin api.Pin
+		makePost(t, rest, url(rest)+"/state/checkpoints", []byte{}, &pin)
+		if !pin.Cid.Equals(test.Cid1) || pin.Metadata["state-checkpoint"] == "" {
+			t.Error("expected a checkpoint pin")
+		}
+
+		var checkpoints []*api.Pin
+		makeGet(t, rest, url(rest)+"/state/checkpoints", &checkpoints)
+		if len(checkpoints) != 1 {
+			t.Error("expected one checkpoint")
+		}
+
+		var pins []*api.Pin
+		makeGet(t, rest, url(rest)+"/state/checkpoints/"+test.Cid1.String(), &pins)
+		if len(pins) != 2 {
+			t.Error("expected two pins in the checkpoint")
+		}
+
+		errResp := api.Error{}
+		makeGet(t, rest, url(rest)+"/state/checkpoints/abc", &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected a bad request error")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPISetLogLevelEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		makePost(t, rest, url(rest)+"/log/level/consensus/debug", []byte{}, &struct{}{})
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIUpgradeEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		makePost(t, rest, url(rest)+"/upgrade?version=0.12.2", []byte{}, &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/upgrade", []byte{}, &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected bad request when version is missing")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPISecretRotationEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		secret := strings.Repeat("ab", 32)
+		makePost(t, rest, url(rest)+"/secret/rotation", []byte(`{"secret":"`+secret+`"}`), &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/secret/rotation", []byte(`{"secret":"abcd"}`), &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected bad request when the secret is too short")
+		}
+
+		makePost(t, rest, url(rest)+"/secret/rotation/finalize", []byte{}, &struct{}{})
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIPushConfigEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		body := []byte(`[{"name": "disk", "config": {"metric_ttl": "1m"}}]`)
+		makePost(t, rest, url(rest)+"/config/push", body, &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/config/push", []byte(`[]`), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected bad request without sections")
+		}
+
+		errResp = api.Error{}
+		makePost(t, rest, url(rest)+"/config/push", []byte(`[{"name": "raft"}]`), &errResp)
+		if errResp.Code == 0 {
+			t.Error("expected an error pushing a section which cannot be pushed")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIPeerstoreEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var known []*api.KnownPeer
+		makeGet(t, rest, url(rest)+"/peerstore", &known)
+		if len(known) != 1 {
+			t.Fatal("expected 1 known peer")
+		}
+		if known[0].ID != test.PeerID1 || known[0].Peername != test.PeerName1 {
+			t.Error("unexpected known peer")
+		}
+		if len(known[0].Addresses) != 1 || known[0].Tags["ipfs-cluster"] != 1 {
+			t.Error("expected addresses and tags")
+		}
+
+		body, _ := json.Marshal(known)
+		makePost(t, rest, url(rest)+"/peerstore", body, &struct{}{})
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/peerstore", []byte("abc"), &errResp)
+		if errResp.Code != 400 {
+			t.Error("expected bad request with a bad body")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIMetricNamesEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var resp []string
+		makeGet(t, rest, url(rest)+"/monitor/metrics", &resp)
+		if len(resp) == 0 {
+			t.Fatal("No metric names found")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIStatusAllEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var resp []*api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins", &resp)
+
+		if len(resp) != 3 ||
+			!resp[0].Cid.Equals(test.Cid1) ||
+			resp[1].PeerMap[peer.IDB58Encode(test.PeerID1)].Status.String() != "pinning" {
+			t.Errorf("unexpected statusAll resp")
+		}
+
+		// Test local=true
+		var resp2 []*api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins?local=true", &resp2)
+		if len(resp2) != 2 {
+			t.Errorf("unexpected statusAll+local resp:\n %+v", resp2)
+		}
+
+		// Test with filter
+		var resp3 []*api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins?filter=queued", &resp3)
+		if len(resp3) != 0 {
+			t.Errorf("unexpected statusAll+filter=queued resp:\n %+v", resp3)
+		}
+
+		var resp4 []*api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins?filter=pinned", &resp4)
+		if len(resp4) != 1 {
+			t.Errorf("unexpected statusAll+filter=pinned resp:\n %+v", resp4)
+		}
+
+		var resp5 []*api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins?filter=pin_error", &resp5)
+		if len(resp5) != 1 {
+			t.Errorf("unexpected statusAll+filter=pin_error resp:\n %+v", resp5)
+		}
+
+		var resp6 []*api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins?filter=error", &resp6)
+		if len(resp6) != 1 {
+			t.Errorf("unexpected statusAll+filter=error resp:\n %+v", resp6)
+		}
+
+		var resp7 []*api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins?filter=error,pinned", &resp7)
+		if len(resp7) != 2 {
+			t.Errorf("unexpected statusAll+filter=error,pinned resp:\n %+v", resp7)
+		}
+
+		var errorResp api.Error
+		makeGet(t, rest, url(rest)+"/pins?filter=invalid", &errorResp)
+		if errorResp.Code != http.StatusBadRequest {
+			t.Error("an invalid filter value should 400")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIStatusEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var resp api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins/"+test.Cid1.String(), &resp)
+
+		if !resp.Cid.Equals(test.Cid1) {
+			t.Error("expected the same cid")
+		}
+		info, ok := resp.PeerMap[peer.IDB58Encode(test.PeerID1)]
+		if !ok {
+			t.Fatal("expected info for test.PeerID1")
+		}
+		if info.Status.String() != "pinned" {
+			t.Error("expected different status")
+		}
+		if resp.Status != api.GlobalPinStatusSatisfied {
+			t.Error("expected a satisfied global status")
+		}
+
+		// Test local=true
+		var resp2 api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/pins/"+test.Cid1.String()+"?local=true", &resp2)
+
+		if !resp2.Cid.Equals(test.Cid1) {
+			t.Error("expected the same cid")
+		}
+		info, ok = resp2.PeerMap[peer.IDB58Encode(test.PeerID2)]
+		if !ok {
+			t.Fatal("expected info for test.PeerID2")
+		}
+		if info.Status.String() != "pinned" {
+			t.Error("expected different status")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIRecoverEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var resp api.GlobalPinInfo
+		makePost(t, rest, url(rest)+"/pins/"+test.Cid1.String()+"/recover", []byte{}, &resp)
+
+		if !resp.Cid.Equals(test.Cid1) {
+			t.Error("expected the same cid")
+		}
+		info, ok := resp.PeerMap[peer.IDB58Encode(test.PeerID1)]
+		if !ok {
+			t.Fatal("expected info for test.PeerID1")
+		}
+		if info.Status.String() != "pinned" {
+			t.Error("expected different status")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIRecoverAllEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var resp []*api.GlobalPinInfo
+		makePost(t, rest, url(rest)+"/pins/recover?local=true", []byte{}, &resp)
+		if len(resp) != 0 {
+			t.Fatal("bad response length")
+		}
+
+		var resp1 []*api.GlobalPinInfo
+		makePost(t, rest, url(rest)+"/pins/recover", []byte{}, &resp1)
+		if len(resp1) == 0 {
+			t.Fatal("bad response length")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIRecoverScheduleEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var sched api.RecoverSchedule
+		makePost(t, rest, url(rest)+"/recover/schedules?cid="+test.Cid1.String()+"&interval=10m&max_attempts=5", []byte{}, &sched)
+		if sched.ID == "" || !sched.Cid.Equals(test.Cid1) {
+			t.Error("unexpected recover schedule: ", sched)
+		}
+		if sched.Interval != 10*time.Minute || sched.MaxAttempts != 5 {
+			t.Error("the interval and max attempts should have been set")
+		}
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/recover/schedules?interval=10m&max_attempts=0", []byte{}, &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected an error when max_attempts is not positive")
+		}
+
+		var scheds []*api.RecoverSchedule
+		makeGet(t, rest, url(rest)+"/recover/schedules", &scheds)
+		if len(scheds) != 1 || scheds[0].ID != "1" {
+			t.Fatal("expected 1 recover schedule")
+		}
+
+		makeDelete(t, rest, url(rest)+"/recover/schedules/1", &struct{}{})
+
+		errResp = api.Error{}
+		makeDelete(t, rest, url(rest)+"/recover/schedules/2", &errResp)
+		if errResp.Code == 0 {
+			t.Error("expected an error cancelling an unknown schedule")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIJobEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var j api.Job
+		body := []byte(`{"kind": "import", "pins": [{"cid": {"/": "` + test.Cid1.String() + `"}}]}`)
+		makePost(t, rest, url(rest)+"/jobs", body, &j)
+		if j.ID != "1" || j.Kind != api.JobImport || j.Total != 1 {
+			t.Error("unexpected job: ", j)
+		}
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/jobs", []byte(`{"kind": "rebalance"}`), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected an error with an unknown job kind")
+		}
+
+		var jobs []*api.Job
+		makeGet(t, rest, url(rest)+"/jobs", &jobs)
+		if len(jobs) != 1 || jobs[0].ID != "1" {
+			t.Fatal("expected 1 job")
+		}
+
+		j = api.Job{}
+		makeGet(t, rest, url(rest)+"/jobs/1", &j)
+		if j.Progress != 10 || j.Total != 20 {
+			t.Error("unexpected job progress: ", j)
+		}
+
+		makeDelete(t, rest, url(rest)+"/jobs/1", &struct{}{})
+
+		errResp = api.Error{}
+		makeDelete(t, rest, url(rest)+"/jobs/2", &errResp)
+		if errResp.Code == 0 {
+			t.Error("expected an error cancelling an unknown job")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPICollectionEndpoints(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var col api.Collection
+		body := []byte(`{"cids": [{"/": "` + test.Cid1.String() + `"}], "options": {"replication_factor_min": 1}}`)
+		makePost(t, rest, url(rest)+"/collections/dataset", body, &col)
+		if col.Name != "dataset" || len(col.Cids) != 1 || !col.Cids[0].Equals(test.Cid1) {
+			t.Error("unexpected collection: ", col)
+		}
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/collections/dataset", []byte(`{"cids": []}`), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("expected an error pinning an empty collection")
+		}
+
+		var cols []*api.Collection
+		makeGet(t, rest, url(rest)+"/collections", &cols)
+		if len(cols) != 1 || cols[0].Name != "dataset" {
+			t.Fatal("expected 1 collection")
+		}
+
+		col = api.Collection{}
+		makeGet(t, rest, url(rest)+"/collections/dataset", &col)
+		if len(col.Cids) != 2 {
+			t.Error("expected 2 cids in the collection")
+		}
+
+		var gpis []*api.GlobalPinInfo
+		makeGet(t, rest, url(rest)+"/collections/dataset/status", &gpis)
+		if len(gpis) != 2 || gpis[0].Status != api.GlobalPinStatusSatisfied {
+			t.Error("unexpected collection status")
+		}
+
+		col = api.Collection{}
+		makeDelete(t, rest, url(rest)+"/collections/dataset", &col)
+		if len(col.Cids) != 2 {
+			t.Error("expected 2 unpinned cids")
+		}
+
+		errResp = api.Error{}
+		makeGet(t, rest, url(rest)+"/collections/other", &errResp)
+		if errResp.Code == 0 {
+			t.Error("expected an error with an unknown collection")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPILogging(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
 
 	logFile, err := filepath.Abs("http.log")
 	if err != nil {
@@ -1395,6 +2120,451 @@ func TestBasicAuth(t *testing.T) {
 	}
 }
 
+func TestAPINamespaces(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName: validUserPassword,
+		adminUserName: adminUserPassword,
+	}
+	cfg.BasicAuthNamespaces = map[string]string{
+		validUserName: test.Namespace1,
+	}
+	rest := testAPIwithConfig(t, cfg, "namespaces")
+	defer rest.Shutdown(ctx)
+
+	tenant := makeBasicAuthRequestShaper(validUserName, validUserPassword)
+	admin := makeBasicAuthRequestShaper(adminUserName, adminUserPassword)
+
+	onlyNamespacePins := func(resp *http.Response) error {
+		var pins []*api.Pin
+		err := json.NewDecoder(resp.Body).Decode(&pins)
+		if err != nil {
+			return err
+		}
+		if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid3) {
+			return fmt.Errorf("expected only the pins in the namespace: %v", pins)
+		}
+		return nil
+	}
+
+	for _, tc := range []httpTestcase{
+		httpTestcase{
+			method: "GET",
+			path:   "/peers",
+			shaper: tenant,
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusForbidden)
+			},
+		},
+		httpTestcase{
+			method: "GET",
+			path:   "/peers",
+			shaper: admin,
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusOK)
+			},
+		},
+		httpTestcase{
+			method:  "GET",
+			path:    "/allocations",
+			shaper:  tenant,
+			checker: onlyNamespacePins,
+		},
+		httpTestcase{
+			method:  "GET",
+			path:    "/allocations?namespace=" + test.Namespace1,
+			shaper:  admin,
+			checker: onlyNamespacePins,
+		},
+		httpTestcase{
+			method: "GET",
+			path:   "/allocations/" + test.Cid3.String(),
+			shaper: tenant,
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusOK)
+			},
+		},
+		httpTestcase{
+			method: "GET",
+			path:   "/allocations/" + test.Cid1.String(),
+			shaper: tenant,
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusNotFound)
+			},
+		},
+		httpTestcase{
+			method: "DELETE",
+			path:   "/pins/" + test.Cid1.String(),
+			shaper: tenant,
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusNotFound)
+			},
+		},
+		httpTestcase{
+			method: "GET",
+			path:   "/usage",
+			shaper: tenant,
+			checker: func(resp *http.Response) error {
+				var usage []*api.QuotaUsage
+				err := json.NewDecoder(resp.Body).Decode(&usage)
+				if err != nil {
+					return err
+				}
+				if len(usage) != 1 || usage[0].Namespace != test.Namespace1 {
+					return fmt.Errorf("expected only the usage of the namespace: %v", usage)
+				}
+				return nil
+			},
+		},
+	} {
+		testBothEndpoints(t, tc.getTestFunction(rest))
+	}
+}
+
+func TestAPIRoles(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName: validUserPassword,
+		adminUserName: adminUserPassword,
+	}
+	cfg.BasicAuthRoles = map[string]string{
+		validUserName: RoleReader,
+	}
+	rest := testAPIwithConfig(t, cfg, "roles")
+	defer rest.Shutdown(ctx)
+
+	reader := makeBasicAuthRequestShaper(validUserName, validUserPassword)
+	admin := makeBasicAuthRequestShaper(adminUserName, adminUserPassword)
+
+	statusChecker := func(code int) func(*http.Response) error {
+		return func(resp *http.Response) error {
+			return httpStatusCodeChecker(resp, code)
+		}
+	}
+
+	for _, tc := range []httpTestcase{
+		httpTestcase{
+			method:  "GET",
+			path:    "/pins/" + test.Cid1.String(),
+			shaper:  reader,
+			checker: statusChecker(http.StatusOK),
+		},
+		httpTestcase{
+			method:  "POST",
+			path:    "/pins/" + test.Cid1.String(),
+			shaper:  reader,
+			checker: statusChecker(http.StatusForbidden),
+		},
+		httpTestcase{
+			method:  "POST",
+			path:    "/pins/" + test.Cid1.String(),
+			shaper:  admin,
+			checker: statusChecker(http.StatusOK),
+		},
+		httpTestcase{
+			method:  "DELETE",
+			path:    "/peers/" + test.PeerID1.Pretty(),
+			shaper:  reader,
+			checker: statusChecker(http.StatusForbidden),
+		},
+	} {
+		testBothEndpoints(t, tc.getTestFunction(rest))
+	}
+}
+
+func TestAPIForceUnpin(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.BasicAuthCredentials = map[string]string{
+		validUserName: validUserPassword,
+		adminUserName: adminUserPassword,
+	}
+	cfg.BasicAuthRoles = map[string]string{
+		validUserName: RolePinner,
+	}
+	rest := testAPIwithConfig(t, cfg, "force unpin")
+	defer rest.Shutdown(ctx)
+
+	pinner := makeBasicAuthRequestShaper(validUserName, validUserPassword)
+	admin := makeBasicAuthRequestShaper(adminUserName, adminUserPassword)
+
+	statusChecker := func(code int) func(*http.Response) error {
+		return func(resp *http.Response) error {
+			return httpStatusCodeChecker(resp, code)
+		}
+	}
+
+	for _, tc := range []httpTestcase{
+		httpTestcase{
+			method:  "DELETE",
+			path:    "/pins/" + test.Cid1.String(),
+			shaper:  pinner,
+			checker: statusChecker(http.StatusOK),
+		},
+		httpTestcase{
+			method:  "DELETE",
+			path:    "/pins/" + test.Cid1.String() + "?force=true",
+			shaper:  pinner,
+			checker: statusChecker(http.StatusForbidden),
+		},
+		httpTestcase{
+			method:  "DELETE",
+			path:    "/pins" + pathTestCases[0].path + "?force=true",
+			shaper:  pinner,
+			checker: statusChecker(http.StatusForbidden),
+		},
+		httpTestcase{
+			method:  "DELETE",
+			path:    "/pins/" + test.Cid1.String() + "?force=true",
+			shaper:  admin,
+			checker: statusChecker(http.StatusOK),
+		},
+		httpTestcase{
+			method:  "DELETE",
+			path:    "/pins" + pathTestCases[0].path + "?force=true",
+			shaper:  admin,
+			checker: statusChecker(http.StatusOK),
+		},
+		httpTestcase{
+			method:  "DELETE",
+			path:    "/pins/" + test.Cid1.String() + "?force=abc",
+			shaper:  admin,
+			checker: statusChecker(http.StatusBadRequest),
+		},
+		httpTestcase{
+			method:  "POST",
+			path:    "/pins/" + test.Cid1.String() + "?force=true",
+			shaper:  pinner,
+			checker: statusChecker(http.StatusForbidden),
+		},
+		httpTestcase{
+			method:  "POST",
+			path:    "/pins/" + test.Cid1.String() + "?force=true",
+			shaper:  admin,
+			checker: statusChecker(http.StatusOK),
+		},
+	} {
+		testBothEndpoints(t, tc.getTestFunction(rest))
+	}
+}
+
+func TestAPIFaultsEndpoint(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+	defer faults.Set(faults.Settings{})
+
+	tf := func(t *testing.T, url urlF) {
+		if !faults.Enabled {
+			errResp := api.Error{}
+			makeGet(t, rest, url(rest)+"/debug/faults", &errResp)
+			if errResp.Code != http.StatusNotFound {
+				t.Error("the faults endpoint should not exist without the faults build tag")
+			}
+			return
+		}
+
+		var settings faults.Settings
+		body := []byte(`{"rpc_drop_rate": 0.5, "commit_delay": "1s"}`)
+		makePost(t, rest, url(rest)+"/debug/faults", body, &settings)
+		if settings.RPCDropRate != 0.5 || settings.CommitDelay != time.Second {
+			t.Error("unexpected settings: ", settings)
+		}
+
+		settings = faults.Settings{}
+		makeGet(t, rest, url(rest)+"/debug/faults", &settings)
+		if settings.RPCDropRate != 0.5 {
+			t.Error("unexpected settings: ", settings)
+		}
+
+		errResp := api.Error{}
+		makePost(t, rest, url(rest)+"/debug/faults", []byte(`{"rpc_drop_rate": 2}`), &errResp)
+		if errResp.Code != http.StatusBadRequest {
+			t.Error("invalid settings should 400")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIMaxBodySize(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.MaxBodySize = 64
+	rest := testAPIwithConfig(t, cfg, "max body size")
+	defer rest.Shutdown(ctx)
+
+	bodyShaper := func(size int) requestShaper {
+		return func(req *http.Request) error {
+			body := bytes.Repeat([]byte("a"), size)
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(size)
+			return nil
+		}
+	}
+
+	for _, tc := range []httpTestcase{
+		httpTestcase{
+			method: "POST",
+			path:   "/add",
+			shaper: bodyShaper(128),
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusRequestEntityTooLarge)
+			},
+		},
+		httpTestcase{
+			method: "GET",
+			path:   "/id",
+			shaper: bodyShaper(32),
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusOK)
+			},
+		},
+	} {
+		testBothEndpoints(t, tc.getTestFunction(rest))
+	}
+}
+
+func TestAPIVersionedRoutes(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tf := func(t *testing.T, url urlF) {
+		var id api.ID
+		makeGet(t, rest, url(rest)+APIVersionPrefix+"/id", &id)
+		if id.ID.Pretty() != test.PeerID1.Pretty() {
+			t.Error("expected correct id from the versioned route")
+		}
+
+		id = api.ID{}
+		makeGet(t, rest, url(rest)+"/id", &id)
+		if id.ID.Pretty() != test.PeerID1.Pretty() {
+			t.Error("expected correct id from the legacy route")
+		}
+	}
+
+	testBothEndpoints(t, tf)
+}
+
+func TestAPIDisableLegacyRoutes(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.DisableLegacyRoutes = true
+	rest := testAPIwithConfig(t, cfg, "no legacy routes")
+	defer rest.Shutdown(ctx)
+
+	for _, tc := range []httpTestcase{
+		httpTestcase{
+			method: "GET",
+			path:   APIVersionPrefix + "/id",
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusOK)
+			},
+		},
+		httpTestcase{
+			method: "GET",
+			path:   "/id",
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusNotFound)
+			},
+		},
+	} {
+		testBothEndpoints(t, tc.getTestFunction(rest))
+	}
+}
+
+func TestAPIJSONFieldCasing(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	cfg.JSONFieldCasing = CamelCase
+	rest := testAPIwithConfig(t, cfg, "camel case")
+	defer rest.Shutdown(ctx)
+
+	casingChecker := func(casing, field string) responseChecker {
+		return func(resp *http.Response) error {
+			if err := httpStatusCodeChecker(resp, http.StatusOK); err != nil {
+				return err
+			}
+			if h := resp.Header.Get(FieldCasingHeader); h != casing {
+				return fmt.Errorf("unexpected %s: %s", FieldCasingHeader, h)
+			}
+			var obj map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+				return err
+			}
+			if _, ok := obj[field]; !ok {
+				return fmt.Errorf("expected the %s field: %v", field, obj)
+			}
+			return nil
+		}
+	}
+
+	for _, tc := range []httpTestcase{
+		httpTestcase{
+			method:  "GET",
+			path:    APIVersionPrefix + "/id",
+			checker: casingChecker(CamelCase, "clusterPeers"),
+		},
+		httpTestcase{
+			method:  "GET",
+			path:    APIVersionPrefix + "/id",
+			header:  http.Header{FieldCasingHeader: []string{SnakeCase}},
+			checker: casingChecker(SnakeCase, "cluster_peers"),
+		},
+		httpTestcase{
+			method: "GET",
+			path:   APIVersionPrefix + "/id",
+			header: http.Header{FieldCasingHeader: []string{"kebab-case"}},
+			checker: func(resp *http.Response) error {
+				return httpStatusCodeChecker(resp, http.StatusBadRequest)
+			},
+		},
+	} {
+		testBothEndpoints(t, tc.getTestFunction(rest))
+	}
+}
+
+func TestAPIWebUI(t *testing.T) {
+	ctx := context.Background()
+	rest := testAPI(t)
+	defer rest.Shutdown(ctx)
+
+	tc := httpTestcase{
+		method: "GET",
+		path:   WebUIPath,
+		checker: func(resp *http.Response) error {
+			return httpStatusCodeChecker(resp, http.StatusNotFound)
+		},
+	}
+	testBothEndpoints(t, tc.getTestFunction(rest))
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.EnableWebUI = true
+	uiRest := testAPIwithConfig(t, cfg, "web ui")
+	defer uiRest.Shutdown(ctx)
+
+	tc.checker = func(resp *http.Response) error {
+		if err := httpStatusCodeChecker(resp, http.StatusOK); err != nil {
+			return err
+		}
+		if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			return fmt.Errorf("unexpected content type: %s", ct)
+		}
+		return nil
+	}
+	testBothEndpoints(t, tc.getTestFunction(uiRest))
+}
+
 func TestLimitMaxHeaderSize(t *testing.T) {
 	const maxHeaderBytes = 4 * DefaultMaxHeaderBytes
 	cfg := &Config{}
@@ -1421,3 +2591,23 @@ func TestLimitMaxHeaderSize(t *testing.T) {
 		testBothEndpoints(t, tc.getTestFunction(rest))
 	}
 }
+
+func TestTraceResponseHandler(t *testing.T) {
+	h := traceResponseHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/id", nil))
+	if v := rec.Header().Get(traceResponseHeader); v != "" {
+		t.Errorf("expected no %s header without a span: %s", traceResponseHeader, v)
+	}
+
+	ctx, span := trace.StartSpan(context.Background(), "test", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/id", nil).WithContext(ctx))
+	sc := span.SpanContext()
+	expected := fmt.Sprintf("00-%s-%s-01", sc.TraceID, sc.SpanID)
+	if v := rec.Header().Get(traceResponseHeader); v != expected {
+		t.Errorf("expected %s header %s, got %s", traceResponseHeader, expected, v)
+	}
+}
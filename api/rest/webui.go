@@ -0,0 +1,196 @@
+package rest
+
+import (
+	"net/http"
+)
+
+// WebUIPath is where the web UI is served when enabled (see
+// Config.EnableWebUI).
+const WebUIPath = "/ui/"
+
+// webUIHandler serves the web UI page. The page is static: it uses the
+// versioned REST API endpoints to show the peers, the pin statuses, the
+// pin tracker queues and the health alerts, and to add files. Those
+// requests are authenticated and authorized like any other, so users only
+// see what their role gives them access to.
+func (api *API) webUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(webUIPage))
+}
+
+const webUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>IPFS Cluster</title>
+<style>
+body { font-family: sans-serif; margin: 1em 2em; color: #222; }
+h1 { font-size: 1.4em; }
+h2 { font-size: 1.1em; margin-top: 1.5em; border-bottom: 1px solid #ccc; }
+table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+th, td { text-align: left; padding: 0.2em 0.6em; border-bottom: 1px solid #eee; }
+code, .mono { font-family: monospace; }
+.error { color: #b00; }
+#status { color: #666; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>IPFS Cluster</h1>
+<div id="status"></div>
+
+<h2>Peers</h2>
+<table id="peers"><thead><tr><th>Name</th><th>ID</th><th>IPFS</th><th>Version</th><th>Error</th></tr></thead><tbody></tbody></table>
+
+<h2>Pin tracker queues</h2>
+<table id="queues"><thead><tr><th>Peer</th><th>Queued</th><th>In progress</th><th>Errors</th></tr></thead><tbody></tbody></table>
+
+<h2>Alerts</h2>
+<table id="alerts"><thead><tr><th>Peer</th><th>Metric</th><th>Triggered at</th></tr></thead><tbody></tbody></table>
+
+<h2>Pins</h2>
+<label>Filter:
+<select id="filter">
+<option value="">all</option>
+<option value="error">error</option>
+<option value="queued">queued</option>
+<option value="pinning">pinning</option>
+<option value="pinned">pinned</option>
+<option value="remote">remote</option>
+</select>
+</label>
+<table id="pins"><thead><tr><th>CID</th><th>Status</th><th>Peers</th></tr></thead><tbody></tbody></table>
+
+<h2>Add</h2>
+<form id="add">
+<input type="file" name="file" multiple required>
+<label>Name: <input type="text" name="name"></label>
+<button type="submit">Add</button>
+</form>
+<pre id="added"></pre>
+
+<script>
+"use strict";
+var api = "/v1";
+
+function cell(row, text, cls) {
+	var td = document.createElement("td");
+	td.textContent = text === undefined || text === null ? "" : text;
+	if (cls) { td.className = cls; }
+	row.appendChild(td);
+}
+
+function fill(id, items, render) {
+	var tbody = document.querySelector("#" + id + " tbody");
+	tbody.innerHTML = "";
+	(items || []).forEach(function (item) {
+		var tr = document.createElement("tr");
+		render(tr, item);
+		tbody.appendChild(tr);
+	});
+}
+
+function get(path) {
+	return fetch(api + path, {credentials: "same-origin"}).then(function (res) {
+		return res.json().then(function (body) {
+			if (!res.ok) {
+				throw new Error(path + ": " + (body.message || res.status));
+			}
+			return body;
+		});
+	});
+}
+
+function cidStr(c) {
+	return c && c["/"] ? c["/"] : c;
+}
+
+function refresh() {
+	var errors = [];
+	var fail = function (err) { errors.push(err.message); };
+
+	var peers = get("/peers").then(function (peers) {
+		fill("peers", peers, function (tr, p) {
+			cell(tr, p.peername);
+			cell(tr, p.id, "mono");
+			cell(tr, p.ipfs && p.ipfs.id, "mono");
+			cell(tr, p.version);
+			cell(tr, p.error, "error");
+		});
+	}).catch(fail);
+
+	var queues = get("/tracker/operations").then(function (ops) {
+		var byPeer = {};
+		(ops || []).forEach(function (op) {
+			var name = op.peername || op.peer;
+			var q = byPeer[name] = byPeer[name] || {queued: 0, progress: 0, errors: 0};
+			if (op.phase === "queued") { q.queued++; }
+			if (op.phase === "inprogress") { q.progress++; }
+			if (op.phase === "error") { q.errors++; }
+		});
+		fill("queues", Object.keys(byPeer).sort(), function (tr, name) {
+			var q = byPeer[name];
+			cell(tr, name);
+			cell(tr, q.queued);
+			cell(tr, q.progress);
+			cell(tr, q.errors, q.errors ? "error" : "");
+		});
+	}).catch(fail);
+
+	var alerts = get("/health/alerts").then(function (alerts) {
+		fill("alerts", alerts, function (tr, a) {
+			cell(tr, a.peer, "mono");
+			cell(tr, a.metric_name);
+			cell(tr, a.triggered_at);
+		});
+	}).catch(fail);
+
+	var filter = document.getElementById("filter").value;
+	var pins = get("/pins" + (filter ? "?filter=" + filter : "")).then(function (gpis) {
+		fill("pins", gpis, function (tr, gpi) {
+			cell(tr, cidStr(gpi.cid), "mono");
+			cell(tr, gpi.status, gpi.status === "error" ? "error" : "");
+			var sts = Object.keys(gpi.peer_map || {}).map(function (k) {
+				var pi = gpi.peer_map[k];
+				return (pi.peername || k) + ": " + pi.status;
+			});
+			cell(tr, sts.join(", "));
+		});
+	}).catch(fail);
+
+	Promise.all([peers, queues, alerts, pins]).then(function () {
+		var status = document.getElementById("status");
+		status.textContent = "Updated " + new Date().toLocaleTimeString() +
+			(errors.length ? ". Errors: " + errors.join("; ") : "");
+		status.className = errors.length ? "error" : "";
+	});
+}
+
+document.getElementById("filter").addEventListener("change", refresh);
+
+document.getElementById("add").addEventListener("submit", function (ev) {
+	ev.preventDefault();
+	var form = ev.target;
+	var data = new FormData();
+	Array.prototype.forEach.call(form.elements.file.files, function (f) {
+		data.append("file", f, f.name);
+	});
+	var name = form.elements.name.value;
+	var q = name ? "?name=" + encodeURIComponent(name) : "";
+	var out = document.getElementById("added");
+	out.textContent = "Adding...";
+	fetch(api + "/add" + q, {method: "POST", body: data, credentials: "same-origin"})
+		.then(function (res) { return res.text(); })
+		.then(function (text) { out.textContent = text; refresh(); })
+		.catch(function (err) { out.textContent = err.message; });
+});
+
+refresh();
+setInterval(refresh, 10000);
+</script>
+</body>
+</html>
+`
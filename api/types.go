@@ -21,6 +21,7 @@ import (
 
 	cid "github.com/ipfs/go-cid"
 	logging "github.com/ipfs/go-log"
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	protocol "github.com/libp2p/go-libp2p-core/protocol"
 	multiaddr "github.com/multiformats/go-multiaddr"
@@ -251,6 +252,9 @@ type GlobalPinInfo struct {
 	// Peer IDs are of string Kind(). We can't use peer IDs here
 	// as Go ignores TextMarshaler.
 	PeerMap map[string]*PinInfo `json:"peer_map" codec:"pm,omitempty"`
+	// Status summarizes the PeerMap with regard to the replication
+	// factor of the pin. It is only set for items in the pinset.
+	Status GlobalPinStatus `json:"status,omitempty" codec:"gs,omitempty"`
 }
 
 // String returns the string representation of a GlobalPinInfo.
@@ -263,6 +267,57 @@ func (gpi *GlobalPinInfo) String() string {
 	return str
 }
 
+// GlobalPinStatus labels an item of the pinset according to the number of
+// its allocations which report it as pinned.
+type GlobalPinStatus string
+
+// GlobalPinStatus values.
+const (
+	// GlobalPinStatusSatisfied means that the item is pinned in at least
+	// ReplicationFactorMin peers, or in all the peers when the pin is
+	// replicated everywhere.
+	GlobalPinStatusSatisfied GlobalPinStatus = "satisfied"
+	// GlobalPinStatusDegraded means that the item is pinned in some peers,
+	// or is being pinned, but in fewer peers than needed.
+	GlobalPinStatusDegraded GlobalPinStatus = "degraded"
+	// GlobalPinStatusFailed means that the item is not pinned anywhere and
+	// that no peer is pinning it.
+	GlobalPinStatusFailed GlobalPinStatus = "failed"
+)
+
+// SetStatus sets the Status of the GlobalPinInfo by comparing the number of
+// peers which report the item as pinned with the given minimum replication
+// factor. Pins replicated everywhere (a factor of -1) need to be pinned in
+// all the peers which are not reported as "remote".
+func (gpi *GlobalPinInfo) SetStatus(rplMin int) {
+	var pinned, inProgress, allocated int
+	for _, pinfo := range gpi.PeerMap {
+		switch pinfo.Status {
+		case TrackerStatusRemote:
+			continue
+		case TrackerStatusPinned:
+			pinned++
+		case TrackerStatusPinQueued, TrackerStatusPinning:
+			inProgress++
+		}
+		allocated++
+	}
+
+	required := rplMin
+	if required <= 0 {
+		required = allocated
+	}
+
+	switch {
+	case pinned >= required && pinned > 0:
+		gpi.Status = GlobalPinStatusSatisfied
+	case pinned == 0 && inProgress == 0:
+		gpi.Status = GlobalPinStatusFailed
+	default:
+		gpi.Status = GlobalPinStatusDegraded
+	}
+}
+
 // PinInfo holds information about local pins.
 type PinInfo struct {
 	Cid      cid.Cid       `json:"cid" codec:"c"`
@@ -271,6 +326,52 @@ type PinInfo struct {
 	Status   TrackerStatus `json:"status" codec:"st,omitempty"`
 	TS       time.Time     `json:"timestamp" codec:"ts,omitempty"`
 	Error    string        `json:"error" codec:"e,omitempty"`
+	// Local is set for local pins, which are not part of the shared
+	// pinset.
+	Local bool `json:"local,omitempty" codec:"l,omitempty"`
+}
+
+// StatusChanges contains the status of the pins which changed in the
+// cluster peers between Since and Until. Only the peers in which the
+// status of a pin changed are included in its PeerMap. Until should be
+// used as Since when asking for the next changes.
+type StatusChanges struct {
+	Since   time.Time        `json:"since" codec:"s,omitempty"`
+	Until   time.Time        `json:"until" codec:"u,omitempty"`
+	Changes []*GlobalPinInfo `json:"changes" codec:"c,omitempty"`
+}
+
+// TrackerOperation describes an operation (pin, unpin...) which is being
+// tracked by a peer's PinTracker, as seen from the inside of the tracker.
+type TrackerOperation struct {
+	Cid      cid.Cid   `json:"cid" codec:"c"`
+	Peer     peer.ID   `json:"peer" codec:"p,omitempty"`
+	PeerName string    `json:"peername" codec:"pn,omitempty"`
+	Type     string    `json:"type" codec:"t,omitempty"`
+	Phase    string    `json:"phase" codec:"ph,omitempty"`
+	Priority bool      `json:"priority,omitempty" codec:"pr,omitempty"`
+	Created  time.Time `json:"created" codec:"cr,omitempty"`
+	TS       time.Time `json:"timestamp" codec:"ts,omitempty"`
+	Attempts int       `json:"attempts" codec:"a,omitempty"`
+	Error    string    `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// PageRequest asks a cluster peer for a page of a long listing, so that
+// it can be transferred over RPC in several smaller replies. The first page
+// is requested without Session. The peer then keeps a snapshot of the
+// listing under the returned Session until the last page is obtained.
+type PageRequest struct {
+	Session string `json:"session,omitempty" codec:"s,omitempty"`
+	Offset  int    `json:"offset,omitempty" codec:"o,omitempty"`
+	Limit   int    `json:"limit" codec:"l,omitempty"`
+}
+
+// PinInfoPage is a page of a PinInfo listing. More is set when further
+// pages can be requested in the same Session.
+type PinInfoPage struct {
+	Session string     `json:"session,omitempty" codec:"s,omitempty"`
+	Items   []*PinInfo `json:"items" codec:"i,omitempty"`
+	More    bool       `json:"more" codec:"m,omitempty"`
 }
 
 // Version holds version information
@@ -278,6 +379,16 @@ type Version struct {
 	Version string `json:"version" codec:"v"`
 }
 
+// UpgradeOptions describes an upgrade of cluster peers to a release.
+type UpgradeOptions struct {
+	Version string `json:"version" codec:"v"`
+	// DistURL is the location from which the release is downloaded. The
+	// upgraded peers use their default location when empty.
+	DistURL string `json:"dist_url,omitempty" codec:"d,omitempty"`
+	// Force allows downgrades.
+	Force bool `json:"force,omitempty" codec:"f,omitempty"`
+}
+
 // ConnectGraph holds information about the connectivity of the cluster To
 // read, traverse the keys of ClusterLinks.  Each such id is one of the peers
 // of the "ClusterID" peer running the query.  ClusterLinks[id] in turn lists
@@ -364,14 +475,51 @@ type ID struct {
 	Error                 string      `json:"error" codec:"e,omitempty"`
 	IPFS                  *IPFSID     `json:"ipfs,omitempty" codec:"ip,omitempty"`
 	Peername              string      `json:"peername" codec:"pn,omitempty"`
+	// Set when the peer is in maintenance mode and receives no new
+	// allocations.
+	Maintenance bool `json:"maintenance,omitempty" codec:"mt,omitempty"`
+	// Set when the peer is in standby mode and receives no allocations
+	// until activated.
+	Standby bool `json:"standby,omitempty" codec:"sb,omitempty"`
+	// PinStatus is the last summary of the pins tracked by the peer,
+	// as broadcasted in its metrics.
+	PinStatus *PinStatusSummary `json:"pin_status,omitempty" codec:"ps,omitempty"`
+	// LastMetric is the time (UnixNano) when the last ping metric from
+	// the peer was received by the peer answering the request.
+	LastMetric int64 `json:"last_metric,omitempty" codec:"lm,omitempty"`
+	// RTT is the round-trip time to the peer as measured by the peer
+	// answering the request.
+	RTT time.Duration `json:"rtt,omitempty" codec:"rt,omitempty"`
 	//PublicKey          crypto.PubKey
 }
 
+// PinStatusSummary counts the pins tracked by a peer in each status. Peers
+// broadcast it regularly in their "pinstatus" metrics, so that their
+// workload is known without requesting the status of every pin.
+type PinStatusSummary struct {
+	Pinned  int `json:"pinned" codec:"pd,omitempty"`
+	Pinning int `json:"pinning" codec:"pg,omitempty"`
+	Queued  int `json:"queued" codec:"q,omitempty"`
+	Error   int `json:"error" codec:"e,omitempty"`
+}
+
 // IPFSID is used to store information about the underlying IPFS daemon
 type IPFSID struct {
-	ID        peer.ID     `json:"id,omitempty" codec:"i,omitempty"`
+	ID           peer.ID     `json:"id,omitempty" codec:"i,omitempty"`
+	Addresses    []Multiaddr `json:"addresses" codec:"a,omitempty"`
+	AgentVersion string      `json:"agent_version,omitempty" codec:"av,omitempty"`
+	Error        string      `json:"error" codec:"e,omitempty"`
+}
+
+// KnownPeer is an entry of the peerstore of a cluster peer, with the
+// addresses known for a peer and its annotations. It is used to export the
+// peerstore and import it on other peers.
+type KnownPeer struct {
+	ID        peer.ID     `json:"id" codec:"i,omitempty"`
+	Peername  string      `json:"peername,omitempty" codec:"pn,omitempty"`
 	Addresses []Multiaddr `json:"addresses" codec:"a,omitempty"`
-	Error     string      `json:"error" codec:"e,omitempty"`
+	// Connection manager tags and their values.
+	Tags map[string]int `json:"tags,omitempty" codec:"t,omitempty"`
 }
 
 // PinType specifies which sort of Pin object we are dealing with.
@@ -472,6 +620,48 @@ type PinOptions struct {
 	ExpireAt             time.Time         `json:"expire_at" codec:"e,omitempty"`
 	Metadata             map[string]string `json:"metadata" codec:"m,omitempty"`
 	PinUpdate            cid.Cid           `json:"pin_update,omitempty" codec:"pu,omitempty"`
+	Policy               string            `json:"policy,omitempty" codec:"pl,omitempty"`
+	Namespace            string            `json:"namespace,omitempty" codec:"ns,omitempty"`
+	// Priority pins are pinned by the peer trackers before any other
+	// queued pins.
+	Priority bool `json:"priority,omitempty" codec:"pr,omitempty"`
+	// FetchRateLimit is the maximum number of blocks per second that
+	// the IPFS daemons of the allocated peers should fetch for this pin
+	// (0 means unlimited).
+	FetchRateLimit uint64 `json:"fetch_rate_limit,omitempty" codec:"fr,omitempty"`
+	// LocalPin pins are pinned and tracked only by the peer receiving
+	// them, without being written to the shared state.
+	LocalPin bool `json:"local_pin,omitempty" codec:"lp,omitempty"`
+	// StorageClass restricts allocations to the peers advertising the
+	// given storage class (i.e. "ssd", "hdd", "archive").
+	StorageClass string `json:"storage_class,omitempty" codec:"sc,omitempty"`
+	// PeerGroup restricts allocations to the peers in the given group,
+	// as defined in the "peer_groups" of the cluster configuration
+	// (i.e. "hot", "archive").
+	PeerGroup string `json:"peer_group,omitempty" codec:"pg,omitempty"`
+	// AllocateBy, AllowedTags and GroupPeers are the allocation
+	// constraints resolved from the placement policy and the peer group
+	// when the item is pinned. They are stored with the pin so that
+	// re-allocations respect them on any peer, even when its
+	// configuration does not define the policy or the group. They are
+	// set by Cluster and cannot be given as query arguments.
+	AllocateBy  string              `json:"allocate_by,omitempty" codec:"ab,omitempty"`
+	AllowedTags map[string][]string `json:"allowed_tags,omitempty" codec:"at,omitempty"`
+	GroupPeers  []peer.ID           `json:"group_peers,omitempty" codec:"gp,omitempty"`
+	// Collection is the name of the collection the pin belongs to, if
+	// any (see Collection).
+	Collection string `json:"collection,omitempty" codec:"cl,omitempty"`
+	// Origins are multiaddresses of peers known to provide the content.
+	// The IPFS daemons of the allocated peers connect to them before
+	// pinning, so that they do not depend on content routing to find
+	// the blocks.
+	Origins []Multiaddr `json:"origins,omitempty" codec:"or,omitempty"`
+	// Protected pins cannot be unpinned unless the unpin is forced,
+	// which only admins can do.
+	Protected bool `json:"protected,omitempty" codec:"pt,omitempty"`
+	// Owner is the API user which made the pin, when known. It is set
+	// by the APIs and cannot be given as a query argument.
+	Owner string `json:"owner,omitempty" codec:"o,omitempty"`
 }
 
 // Equals returns true if two PinOption objects are equivalent. po and po2 may
@@ -501,6 +691,72 @@ func (po *PinOptions) Equals(po2 *PinOptions) bool {
 		return false
 	}
 
+	if po.Policy != po2.Policy {
+		return false
+	}
+
+	if po.Namespace != po2.Namespace {
+		return false
+	}
+
+	if po.Owner != po2.Owner {
+		return false
+	}
+
+	if po.Priority != po2.Priority {
+		return false
+	}
+
+	if po.FetchRateLimit != po2.FetchRateLimit {
+		return false
+	}
+
+	if po.LocalPin != po2.LocalPin {
+		return false
+	}
+
+	if po.StorageClass != po2.StorageClass {
+		return false
+	}
+
+	if po.PeerGroup != po2.PeerGroup {
+		return false
+	}
+
+	if po.AllocateBy != po2.AllocateBy {
+		return false
+	}
+
+	if !equalTags(po.AllowedTags, po2.AllowedTags) {
+		return false
+	}
+
+	groupPeers1 := PeersToStrings(po.GroupPeers)
+	groupPeers2 := PeersToStrings(po2.GroupPeers)
+	sort.Strings(groupPeers1)
+	sort.Strings(groupPeers2)
+	if strings.Join(groupPeers1, ",") != strings.Join(groupPeers2, ",") {
+		return false
+	}
+
+	if po.Collection != po2.Collection {
+		return false
+	}
+
+	if po.Protected != po2.Protected {
+		return false
+	}
+
+	if len(po.Origins) != len(po2.Origins) {
+		return false
+	}
+
+	for i := range po.Origins {
+		if po.Origins[i].String() != po2.Origins[i].String() {
+			return false
+		}
+	}
+
 	lenAllocs1 := len(po.UserAllocations)
 	lenAllocs2 := len(po2.UserAllocations)
 	if lenAllocs1 != lenAllocs2 {
@@ -532,6 +788,28 @@ func (po *PinOptions) Equals(po2 *PinOptions) bool {
 	return true
 }
 
+// equalTags returns true if both sets of allowed tags have the same values
+// for the same tags, in any order.
+func equalTags(tags1, tags2 map[string][]string) bool {
+	if len(tags1) != len(tags2) {
+		return false
+	}
+	for k, v1 := range tags1 {
+		v2, ok := tags2[k]
+		if !ok || len(v1) != len(v2) {
+			return false
+		}
+		s1 := append([]string{}, v1...)
+		s2 := append([]string{}, v2...)
+		sort.Strings(s1)
+		sort.Strings(s2)
+		if strings.Join(s1, ",") != strings.Join(s2, ",") {
+			return false
+		}
+	}
+	return true
+}
+
 // ToQuery returns the PinOption as query arguments.
 func (po *PinOptions) ToQuery() (string, error) {
 	q := url.Values{}
@@ -556,12 +834,51 @@ func (po *PinOptions) ToQuery() (string, error) {
 	if po.PinUpdate != cid.Undef {
 		q.Set("pin-update", po.PinUpdate.String())
 	}
+	if po.Policy != "" {
+		q.Set("policy", po.Policy)
+	}
+	if po.Namespace != "" {
+		q.Set("namespace", po.Namespace)
+	}
+	if po.Priority {
+		q.Set("priority", "true")
+	}
+	if po.FetchRateLimit > 0 {
+		q.Set("fetch-rate-limit", fmt.Sprintf("%d", po.FetchRateLimit))
+	}
+	if po.LocalPin {
+		q.Set("local-pin", "true")
+	}
+	if po.StorageClass != "" {
+		q.Set("storage-class", po.StorageClass)
+	}
+	if po.PeerGroup != "" {
+		q.Set("peer-group", po.PeerGroup)
+	}
+	if po.Collection != "" {
+		q.Set("collection", po.Collection)
+	}
+	if len(po.Origins) > 0 {
+		origins := make([]string, len(po.Origins))
+		for i, o := range po.Origins {
+			origins[i] = o.String()
+		}
+		q.Set("origins", strings.Join(origins, ","))
+	}
+	if po.Protected {
+		q.Set("protected", "true")
+	}
 	return q.Encode(), nil
 }
 
 // FromQuery is the inverse of ToQuery().
 func (po *PinOptions) FromQuery(q url.Values) error {
 	po.Name = q.Get("name")
+	po.Policy = q.Get("policy")
+	po.Namespace = q.Get("namespace")
+	po.StorageClass = q.Get("storage-class")
+	po.PeerGroup = q.Get("peer-group")
+	po.Collection = q.Get("collection")
 	rplStr := q.Get("replication")
 	if rplStr != "" { // override
 		q.Set("replication-min", rplStr)
@@ -590,6 +907,50 @@ func (po *PinOptions) FromQuery(q url.Values) error {
 		po.UserAllocations = StringsToPeers(strings.Split(allocs, ","))
 	}
 
+	if v := q.Get("origins"); v != "" {
+		origins := strings.Split(v, ",")
+		po.Origins = make([]Multiaddr, 0, len(origins))
+		for _, o := range origins {
+			maddr, err := NewMultiaddr(strings.TrimSpace(o))
+			if err != nil {
+				return errors.Wrap(err, "error decoding origins parameter")
+			}
+			po.Origins = append(po.Origins, maddr)
+		}
+	}
+
+	if v := q.Get("priority"); v != "" {
+		priority, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("parameter priority is invalid")
+		}
+		po.Priority = priority
+	}
+
+	if v := q.Get("fetch-rate-limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return errors.New("parameter fetch-rate-limit is invalid")
+		}
+		po.FetchRateLimit = limit
+	}
+
+	if v := q.Get("local-pin"); v != "" {
+		localPin, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("parameter local-pin is invalid")
+		}
+		po.LocalPin = localPin
+	}
+
+	if v := q.Get("protected"); v != "" {
+		protected, err := strconv.ParseBool(v)
+		if err != nil {
+			return errors.New("parameter protected is invalid")
+		}
+		po.Protected = protected
+	}
+
 	if v := q.Get("expire-at"); v != "" {
 		var tm time.Time
 		err := tm.UnmarshalText([]byte(v))
@@ -655,6 +1016,10 @@ type Pin struct {
 	// it is the previous shard CID.
 	// When not needed the pointer is nil
 	Reference *cid.Cid `json:"reference" codec:"r,omitempty"`
+
+	// Size is the cumulative size of the pinned DAG in bytes, when
+	// known. It is set for pins in namespaces with a quota.
+	Size uint64 `json:"size,omitempty" codec:"sz,omitempty"`
 }
 
 // String is a string representation of a Pin.
@@ -724,6 +1089,28 @@ func (pin *Pin) ProtoMarshal() ([]byte, error) {
 		expireAtProto = uint64(pin.ExpireAt.Unix())
 	}
 
+	var allowedTags []string
+	for k, values := range pin.AllowedTags {
+		for _, v := range values {
+			allowedTags = append(allowedTags, k+"="+v)
+		}
+	}
+	sort.Strings(allowedTags)
+
+	groupPeers := make([][]byte, len(pin.GroupPeers))
+	for i, pid := range pin.GroupPeers {
+		bs, err := pid.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		groupPeers[i] = bs
+	}
+
+	origins := make([][]byte, len(pin.Origins))
+	for i, o := range pin.Origins {
+		origins[i] = o.Bytes()
+	}
+
 	opts := &pb.PinOptions{
 		ReplicationFactorMin: int32(pin.ReplicationFactorMin),
 		ReplicationFactorMax: int32(pin.ReplicationFactorMax),
@@ -733,6 +1120,21 @@ func (pin *Pin) ProtoMarshal() ([]byte, error) {
 		Metadata:  pin.Metadata,
 		PinUpdate: pin.PinUpdate.Bytes(),
 		ExpireAt:  expireAtProto,
+		Policy:    pin.Policy,
+		Namespace: pin.Namespace,
+		Owner:     pin.Owner,
+		Priority:  pin.Priority,
+
+		FetchRateLimit: pin.FetchRateLimit,
+		LocalPin:       pin.LocalPin,
+		StorageClass:   pin.StorageClass,
+		PeerGroup:      pin.PeerGroup,
+		AllocateBy:     pin.AllocateBy,
+		AllowedTags:    allowedTags,
+		GroupPeers:     groupPeers,
+		Collection:     pin.Collection,
+		Origins:        origins,
+		Protected:      pin.Protected,
 	}
 
 	pbPin := &pb.Pin{
@@ -741,6 +1143,7 @@ func (pin *Pin) ProtoMarshal() ([]byte, error) {
 		Allocations: allocs,
 		MaxDepth:    int32(pin.MaxDepth),
 		Options:     opts,
+		Size:        pin.Size,
 	}
 	if ref := pin.Reference; ref != nil {
 		pbPin.Reference = ref.Bytes()
@@ -784,6 +1187,7 @@ func (pin *Pin) ProtoUnmarshal(data []byte) error {
 	} else {
 		pin.Reference = &ref
 	}
+	pin.Size = pbPin.GetSize()
 
 	opts := pbPin.GetOptions()
 	pin.ReplicationFactorMin = int(opts.GetReplicationFactorMin())
@@ -800,6 +1204,44 @@ func (pin *Pin) ProtoUnmarshal(data []byte) error {
 	if err == nil {
 		pin.PinUpdate = pinUpdate
 	}
+	pin.Policy = opts.GetPolicy()
+	pin.Namespace = opts.GetNamespace()
+	pin.Owner = opts.GetOwner()
+	pin.Priority = opts.GetPriority()
+	pin.FetchRateLimit = opts.GetFetchRateLimit()
+	pin.LocalPin = opts.GetLocalPin()
+	pin.StorageClass = opts.GetStorageClass()
+	pin.PeerGroup = opts.GetPeerGroup()
+	pin.AllocateBy = opts.GetAllocateBy()
+	pin.AllowedTags = nil
+	for _, t := range opts.GetAllowedTags() {
+		kv := strings.SplitN(t, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("bad allowed tag: %s", t)
+		}
+		if pin.AllowedTags == nil {
+			pin.AllowedTags = make(map[string][]string)
+		}
+		pin.AllowedTags[kv[0]] = append(pin.AllowedTags[kv[0]], kv[1])
+	}
+	pin.GroupPeers = nil
+	for _, pidb := range opts.GetGroupPeers() {
+		pid, err := peer.IDFromBytes(pidb)
+		if err != nil {
+			return err
+		}
+		pin.GroupPeers = append(pin.GroupPeers, pid)
+	}
+	pin.Collection = opts.GetCollection()
+	pin.Protected = opts.GetProtected()
+	pin.Origins = nil
+	for _, o := range opts.GetOrigins() {
+		maddr, err := multiaddr.NewMultiaddrBytes(o)
+		if err != nil {
+			return err
+		}
+		pin.Origins = append(pin.Origins, NewMultiaddrWithValue(maddr))
+	}
 	return nil
 }
 
@@ -877,6 +1319,213 @@ func (pin *Pin) ExpiredAt(t time.Time) bool {
 	return pin.ExpireAt.Before(t)
 }
 
+// Degraded returns whether the pin is allocated to fewer peers than its
+// ReplicationFactorMax, while ReplicationFactorMin is still met. Degraded
+// pins are not failed, but they should be allocated to more peers when
+// possible.
+func (pin *Pin) Degraded() bool {
+	if pin.Type == MetaType || pin.ReplicationFactorMin < 0 || pin.ReplicationFactorMax < 0 {
+		return false
+	}
+	n := len(pin.Allocations)
+	return n >= pin.ReplicationFactorMin && n < pin.ReplicationFactorMax
+}
+
+// Anonymized returns a copy of the pin which keeps only what is needed to
+// pin the same content again: the CID, the replication factors and the
+// type, depth and reference describing the DAG. Names, metadata,
+// allocations and every other option, which may reveal who pinned the
+// content or how the cluster is laid out, are left out.
+func (pin *Pin) Anonymized() *Pin {
+	anon := &Pin{
+		Cid:       pin.Cid,
+		Type:      pin.Type,
+		MaxDepth:  pin.MaxDepth,
+		Reference: pin.Reference,
+	}
+	anon.ReplicationFactorMin = pin.ReplicationFactorMin
+	anon.ReplicationFactorMax = pin.ReplicationFactorMax
+	return anon
+}
+
+// PinQuery selects pins from the pinset. Empty fields match any pin.
+type PinQuery struct {
+	// Type is a filter of pin types. AllType when unset.
+	Type      PinType `json:"type" codec:"t,omitempty"`
+	Namespace string  `json:"namespace,omitempty" codec:"ns,omitempty"`
+	// Metadata selects the pins which have all the given metadata keys.
+	// Keys with empty values match any value.
+	Metadata   map[string]string `json:"metadata,omitempty" codec:"m,omitempty"`
+	Allocation peer.ID           `json:"allocation,omitempty" codec:"a,omitempty"`
+	Collection string            `json:"collection,omitempty" codec:"cl,omitempty"`
+}
+
+// Matches returns true if the given pin is selected by the query.
+func (q *PinQuery) Matches(pin *Pin) bool {
+	if q.Type != 0 && q.Type&pin.Type == 0 {
+		return false
+	}
+	if q.Namespace != "" && pin.Namespace != q.Namespace {
+		return false
+	}
+	if q.Collection != "" && pin.Collection != q.Collection {
+		return false
+	}
+	for k, v := range q.Metadata {
+		pv, ok := pin.Metadata[k]
+		if !ok || v != "" && pv != v {
+			return false
+		}
+	}
+	if q.Allocation == "" {
+		return true
+	}
+	for _, p := range pin.Allocations {
+		if p == q.Allocation {
+			return true
+		}
+	}
+	return false
+}
+
+// ToQuery returns the PinQuery as query arguments for the allocations
+// endpoint of the REST API.
+func (q *PinQuery) ToQuery() string {
+	v := url.Values{}
+	if q.Type != 0 && q.Type != AllType {
+		var types []string
+		for _, t := range []PinType{DataType, MetaType, ClusterDAGType, ShardType} {
+			if t&q.Type > 0 { // the filter includes this type
+				types = append(types, t.String())
+			}
+		}
+		v.Set("filter", strings.Join(types, ","))
+	} else {
+		v.Set("filter", "all")
+	}
+	if q.Namespace != "" {
+		v.Set("namespace", q.Namespace)
+	}
+	for k, val := range q.Metadata {
+		if k == "" {
+			continue
+		}
+		v.Set(pinOptionsMetaPrefix+k, val)
+	}
+	if q.Allocation != "" {
+		v.Set("allocation", peer.IDB58Encode(q.Allocation))
+	}
+	if q.Collection != "" {
+		v.Set("collection", q.Collection)
+	}
+	return v.Encode()
+}
+
+// FromQuery is the inverse of ToQuery().
+func (q *PinQuery) FromQuery(v url.Values) error {
+	q.Type = 0
+	for _, f := range strings.Split(v.Get("filter"), ",") {
+		q.Type |= PinTypeFromString(f)
+	}
+	if q.Type == BadType {
+		return errors.New("invalid filter value")
+	}
+
+	q.Namespace = v.Get("namespace")
+	q.Collection = v.Get("collection")
+
+	q.Metadata = make(map[string]string)
+	for k := range v {
+		if !strings.HasPrefix(k, pinOptionsMetaPrefix) {
+			continue
+		}
+		metaKey := strings.TrimPrefix(k, pinOptionsMetaPrefix)
+		if metaKey == "" {
+			continue
+		}
+		q.Metadata[metaKey] = v.Get(k)
+	}
+
+	q.Allocation = ""
+	if allocStr := v.Get("allocation"); allocStr != "" {
+		pid, err := peer.IDB58Decode(allocStr)
+		if err != nil {
+			return errors.Wrap(err, "allocation cannot be parsed")
+		}
+		q.Allocation = pid
+	}
+	return nil
+}
+
+// PinViewQuery selects a page of the pins matching a PinQuery, sorted by
+// CID, for the pin view endpoint of the REST API.
+type PinViewQuery struct {
+	PinQuery
+	Offset int `json:"offset,omitempty" codec:"o,omitempty"`
+	Limit  int `json:"limit,omitempty" codec:"l,omitempty"`
+}
+
+// ToQuery returns the PinViewQuery as query arguments for the pin view
+// endpoint of the REST API.
+func (q *PinViewQuery) ToQuery() string {
+	v, _ := url.ParseQuery(q.PinQuery.ToQuery())
+	if q.Offset > 0 {
+		v.Set("offset", strconv.Itoa(q.Offset))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return v.Encode()
+}
+
+// FromQuery is the inverse of ToQuery().
+func (q *PinViewQuery) FromQuery(v url.Values) error {
+	if err := q.PinQuery.FromQuery(v); err != nil {
+		return err
+	}
+
+	q.Offset = 0
+	if offset := v.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return errors.New("parameter offset is invalid")
+		}
+		q.Offset = n
+	}
+
+	q.Limit = 0
+	if limit := v.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return errors.New("parameter limit is invalid")
+		}
+		q.Limit = n
+	}
+	return nil
+}
+
+// PinView is a pin in the shared state along with its status in the
+// cluster peers.
+type PinView struct {
+	Pin    *Pin           `json:"pin" codec:"p,omitempty"`
+	Status *GlobalPinInfo `json:"status" codec:"s,omitempty"`
+}
+
+// PinViewPage is a page of the pins matching a PinViewQuery. Total is the
+// number of pins matching the query across all pages.
+type PinViewPage struct {
+	Items  []*PinView `json:"items" codec:"i,omitempty"`
+	Offset int        `json:"offset" codec:"o,omitempty"`
+	Total  int        `json:"total" codec:"t,omitempty"`
+}
+
+// PinAck acknowledges a pin sent over a pin stream, once it has been
+// committed to the shared state or once it has failed.
+type PinAck struct {
+	Cid   cid.Cid `json:"cid" codec:"c"`
+	Error string  `json:"error,omitempty" codec:"e,omitempty"`
+}
+
 // NodeWithMeta specifies a block of data and a set of optional metadata fields
 // carrying information about the encoded ipld node
 type NodeWithMeta struct {
@@ -895,7 +1544,8 @@ func (n *NodeWithMeta) Size() uint64 {
 // pin allocations by a PinAllocator. IPFS cluster is agnostic to
 // the Value, which should be interpreted by the PinAllocator.
 // The ReceivedAt value is a timestamp representing when a peer has received
-// the metric value.
+// the metric value. The SentAt value is set by the peer sending the metric
+// and allows to detect clock differences between peers.
 type Metric struct {
 	Name       string  `json:"name" codec:"n,omitempty"`
 	Peer       peer.ID `json:"peer" codec:"p,omitempty"`
@@ -903,6 +1553,25 @@ type Metric struct {
 	Expire     int64   `json:"expire" codec:"e,omitempty"`
 	Valid      bool    `json:"valid" codec:"d,omitempty"`
 	ReceivedAt int64   `json:"received_at" codec:"t,omitempty"` // ReceivedAt contains a UnixNano timestamp
+	SentAt     int64   `json:"sent_at" codec:"s,omitempty"`     // SentAt contains a UnixNano timestamp
+	// Tags of the peer which issued the metric.
+	Tags map[string]string `json:"tags,omitempty" codec:"tg,omitempty"`
+	// Peername of the peer which issued the metric.
+	Peername string `json:"peername,omitempty" codec:"pn,omitempty"`
+	// Set when the peer which issued the metric is in maintenance mode
+	// and should not receive new allocations.
+	Maintenance bool `json:"maintenance,omitempty" codec:"mt,omitempty"`
+	// Set when the peer which issued the metric is in standby mode and
+	// should not receive allocations.
+	Standby bool `json:"standby,omitempty" codec:"sb,omitempty"`
+	// Storage class of the peer which issued the metric.
+	StorageClass string `json:"storage_class,omitempty" codec:"sc,omitempty"`
+	// Number of pin and unpin operations queued or in progress in the
+	// peer which issued the metric. Only set in ping metrics.
+	PendingOperations int `json:"pending_operations,omitempty" codec:"po,omitempty"`
+	// Summary of the pins tracked by the peer which issued the metric.
+	// Only set in pinstatus metrics.
+	PinStatus *PinStatusSummary `json:"pin_status,omitempty" codec:"ps,omitempty"`
 }
 
 // SetTTL sets Metric to expire after the given time.Duration
@@ -923,6 +1592,17 @@ func (m *Metric) Expired() bool {
 	return time.Now().After(expDate)
 }
 
+// ClockSkew returns the difference between the time the metric was received
+// and the time it was sent, as measured by the clocks of the receiving and
+// the sending peers. It includes the time the metric spent in transit. It
+// returns 0 when either timestamp is not known.
+func (m *Metric) ClockSkew() time.Duration {
+	if m.SentAt == 0 || m.ReceivedAt == 0 {
+		return 0
+	}
+	return time.Duration(m.ReceivedAt - m.SentAt)
+}
+
 // Discard returns if the metric not valid or has expired
 func (m *Metric) Discard() bool {
 	return !m.Valid || m.Expired()
@@ -940,16 +1620,24 @@ func (es MetricSlice) Less(i, j int) bool {
 	return es[i].Peer < es[j].Peer
 }
 
-// Alert carries alerting information about a peer. WIP.
+// Alert carries alerting information about a peer.
 type Alert struct {
-	Peer       peer.ID
-	MetricName string
+	Peer        peer.ID   `json:"peer" codec:"p"`
+	MetricName  string    `json:"metric_name" codec:"n"`
+	TriggeredAt time.Time `json:"triggered_at" codec:"t,omitempty"`
+	// Peername and Tags of the peer, as sent with the expired metric.
+	Peername string            `json:"peername,omitempty" codec:"pn,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty" codec:"tg,omitempty"`
 }
 
 // Error can be used by APIs to return errors.
 type Error struct {
-	Code    int    `json:"code" codec:"o,omitempty"`
-	Message string `json:"message" codec:"m,omitempty"`
+	Code      int       `json:"code" codec:"o,omitempty"`
+	Message   string    `json:"message" codec:"m,omitempty"`
+	ErrorCode ErrorCode `json:"error_code,omitempty" codec:"e,omitempty"`
+	// RetryAfter is the number of seconds to wait before retrying,
+	// when known.
+	RetryAfter int `json:"retry_after,omitempty" codec:"r,omitempty"`
 }
 
 // Error implements the error interface and returns the error's message.
@@ -957,12 +1645,37 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
 }
 
+// Prefetch asks cluster peers to fetch the DAG under a Cid into their IPFS
+// blockstores without pinning it. When no peers are given, all the cluster
+// peers fetch it.
+type Prefetch struct {
+	Cid   cid.Cid   `json:"cid" codec:"c"`
+	Peers []peer.ID `json:"peers,omitempty" codec:"p,omitempty"`
+}
+
+// PrefetchResult is the outcome of a Prefetch in a cluster peer.
+type PrefetchResult struct {
+	Cid      cid.Cid `json:"cid" codec:"c"`
+	Peer     peer.ID `json:"peer" codec:"p"`
+	PeerName string  `json:"peername" codec:"pn,omitempty"`
+	Error    string  `json:"error,omitempty" codec:"e,omitempty"`
+}
+
 // IPFSRepoStat wraps information about the IPFS repository.
 type IPFSRepoStat struct {
 	RepoSize   uint64 `codec:"r,omitempty"`
 	StorageMax uint64 `codec:"s, omitempty"`
 }
 
+// IPNSPublish asks the IPFS daemon to publish a Cid under the IPNS name of
+// one of its keys. Records are valid for Lifetime, or for the default of the
+// daemon when it is 0.
+type IPNSPublish struct {
+	Cid      cid.Cid       `codec:"c,omitempty"`
+	Key      string        `codec:"k,omitempty"`
+	Lifetime time.Duration `codec:"l,omitempty"`
+}
+
 // IPFSRepoGC represents the streaming response sent from repo gc API of IPFS.
 type IPFSRepoGC struct {
 	Key   cid.Cid `json:"key,omitempty" codec:"k,omitempty"`
@@ -982,3 +1695,415 @@ type RepoGC struct {
 type GlobalRepoGC struct {
 	PeerMap map[string]*RepoGC `json:"peer_map" codec:"pm,omitempty"`
 }
+
+// PeerRemoveReport describes the impact of removing a peer from the cluster:
+// the pins that would need to be re-allocated and the peers that would
+// receive them.
+type PeerRemoveReport struct {
+	Peer peer.ID `json:"peer" codec:"p,omitempty"`
+	// Pins allocated to the peer.
+	AllocatedPins int `json:"allocated_pins" codec:"a,omitempty"`
+	// Pins which would be re-allocated to other peers.
+	ReallocatedPins int `json:"reallocated_pins" codec:"r,omitempty"`
+	// Pins for which no new allocations could be found.
+	UnallocatablePins int `json:"unallocatable_pins" codec:"u,omitempty"`
+	// Size of the IPFS repository of the peer. This is an upper bound
+	// of the data that needs to be moved.
+	RepoSize uint64 `json:"repo_size" codec:"s,omitempty"`
+	// Number of pins that each peer would receive, indexed by peer ID.
+	Receivers map[string]int `json:"receivers" codec:"rc,omitempty"`
+	// Set when repinning is disabled and pins would not be re-allocated.
+	RepinningDisabled bool `json:"repinning_disabled" codec:"d,omitempty"`
+}
+
+// RepinProgress reports the progress of the re-allocation of the pins of a
+// peer which is down or has been removed.
+type RepinProgress struct {
+	Peer peer.ID `json:"peer" codec:"p,omitempty"`
+	// Why pins are re-allocated: "down", "removed" or "maintenance".
+	Reason string `json:"reason" codec:"r,omitempty"`
+	// When the re-allocation started.
+	Started time.Time `json:"started" codec:"s,omitempty"`
+	// Pins to be re-allocated.
+	Total int `json:"total" codec:"t,omitempty"`
+	// Pins which have been re-allocated.
+	Repinned int `json:"repinned" codec:"rp,omitempty"`
+	// Pins which could not be re-allocated.
+	Failed int `json:"failed" codec:"f,omitempty"`
+	// Set when all pins have been processed.
+	Done bool `json:"done" codec:"d,omitempty"`
+}
+
+// RecoverSchedule describes a series of recover operations for the items in
+// error state, which are attempted every Interval until no items remain in
+// error or MaxAttempts is reached.
+type RecoverSchedule struct {
+	ID string `json:"id" codec:"i,omitempty"`
+	// The item to recover. All tracked items when undefined.
+	Cid cid.Cid `json:"cid,omitempty" codec:"c,omitempty"`
+	// Only recover items on the peer running the schedule.
+	Local       bool          `json:"local" codec:"l,omitempty"`
+	Interval    time.Duration `json:"interval" codec:"in,omitempty"`
+	MaxAttempts int           `json:"max_attempts" codec:"m,omitempty"`
+	// Recover operations performed so far.
+	Attempts    int       `json:"attempts" codec:"a,omitempty"`
+	Created     time.Time `json:"created" codec:"cr,omitempty"`
+	LastAttempt time.Time `json:"last_attempt" codec:"la,omitempty"`
+	NextAttempt time.Time `json:"next_attempt" codec:"na,omitempty"`
+	// Items which remained in error after the last attempt.
+	Errored int `json:"errored" codec:"e,omitempty"`
+	// Set when the schedule has finished.
+	Done bool `json:"done" codec:"d,omitempty"`
+}
+
+// Validate checks that the schedule can be run.
+func (rs *RecoverSchedule) Validate() error {
+	if rs.Interval <= 0 {
+		return errors.New("the recover interval must be positive")
+	}
+	if rs.MaxAttempts <= 0 {
+		return errors.New("the maximum number of recover attempts must be positive")
+	}
+	return nil
+}
+
+// JobKind identifies the operation performed by a Job.
+type JobKind string
+
+// JobKind values.
+const (
+	// JobRecover jobs recover all the items in error in the cluster.
+	JobRecover JobKind = "recover"
+	// JobRepoGC jobs run a garbage collection on all the IPFS daemons.
+	JobRepoGC JobKind = "repo-gc"
+	// JobShardsGC jobs unpin the shards no longer referenced by any
+	// sharded pin.
+	JobShardsGC JobKind = "shards-gc"
+	// JobImport jobs pin the Pins of the job, one after another.
+	JobImport JobKind = "import"
+)
+
+// JobStatus is the status of a Job.
+type JobStatus string
+
+// JobStatus values.
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobDone      JobStatus = "done"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Finished returns true for the statuses of jobs which will not run again.
+func (st JobStatus) Finished() bool {
+	return st == JobDone || st == JobFailed || st == JobCancelled
+}
+
+// Job describes a long-running operation queued in a peer. Jobs run one
+// after another in the background and are resumed when the peer restarts.
+type Job struct {
+	ID     string    `json:"id" codec:"i,omitempty"`
+	Kind   JobKind   `json:"kind" codec:"k,omitempty"`
+	Status JobStatus `json:"status" codec:"s,omitempty"`
+	// Pins to import. They are only given when submitting the job and
+	// are stored apart from it until it finishes.
+	Pins []*Pin `json:"pins,omitempty" codec:"p,omitempty"`
+	// Items processed so far, out of Total when it is known.
+	Progress int `json:"progress" codec:"pr,omitempty"`
+	Total    int `json:"total" codec:"t,omitempty"`
+	// Items which could not be processed.
+	Failed   int       `json:"failed" codec:"f,omitempty"`
+	Created  time.Time `json:"created" codec:"cr,omitempty"`
+	Started  time.Time `json:"started" codec:"st,omitempty"`
+	Finished time.Time `json:"finished" codec:"fi,omitempty"`
+	Error    string    `json:"error,omitempty" codec:"e,omitempty"`
+}
+
+// Validate checks that the job can be run.
+func (j *Job) Validate() error {
+	switch j.Kind {
+	case JobRecover, JobRepoGC, JobShardsGC:
+		if len(j.Pins) > 0 {
+			return fmt.Errorf("%s jobs do not take pins", j.Kind)
+		}
+	case JobImport:
+		if len(j.Pins) == 0 {
+			return errors.New("import jobs need pins to import")
+		}
+		for _, pin := range j.Pins {
+			if pin == nil || !pin.Cid.Defined() {
+				return errors.New("import jobs cannot have undefined pins")
+			}
+		}
+	default:
+		return fmt.Errorf("unknown job kind: %q", j.Kind)
+	}
+	return nil
+}
+
+// QuotaUsage reports the pins in a namespace or made by a user, along with
+// the quota which applies to them, if any.
+type QuotaUsage struct {
+	// Only one of Namespace and User is set.
+	Namespace string `json:"namespace,omitempty" codec:"ns,omitempty"`
+	User      string `json:"user,omitempty" codec:"u,omitempty"`
+	// Number of pins.
+	Pins int `json:"pins" codec:"p,omitempty"`
+	// Cumulative size of the pins, as far as it is known. Sizes are
+	// only obtained for pins subject to a quota.
+	Bytes uint64 `json:"bytes" codec:"b,omitempty"`
+	// Quota. 0 means unlimited.
+	MaxPins  int    `json:"max_pins" codec:"mp,omitempty"`
+	MaxBytes uint64 `json:"max_bytes" codec:"mb,omitempty"`
+}
+
+// ClusterStats is a compact summary of the health of the cluster, meant to
+// be scraped in a single request by dashboards. The metrics endpoint of
+// each peer provides the full details.
+type ClusterStats struct {
+	// The peer which collected the stats.
+	Peer peer.ID `json:"peer" codec:"p,omitempty"`
+	// When the stats were collected.
+	Time time.Time `json:"time" codec:"t,omitempty"`
+	// Pins in the shared state.
+	Pins int `json:"pins" codec:"pi,omitempty"`
+	// Peers in the consensus peerset.
+	ConsensusPeers int `json:"consensus_peers" codec:"cp,omitempty"`
+	// Consensus leader, for consensus components which have one.
+	Leader peer.ID `json:"leader,omitempty" codec:"l,omitempty"`
+	// Set when the consensus state or the peerset cannot be read.
+	ConsensusError string `json:"consensus_error,omitempty" codec:"ce,omitempty"`
+	// Stats of every peer in the peerset.
+	Peers []*PeerStats `json:"peers" codec:"ps,omitempty"`
+}
+
+// PeerStats summarises the pins tracked by a peer and its health.
+type PeerStats struct {
+	Peer     peer.ID `json:"peer" codec:"p,omitempty"`
+	Peername string  `json:"peername" codec:"pn,omitempty"`
+	// Free space reported with the last "freespace" metric of the peer.
+	// 0 when unknown.
+	FreeSpace uint64 `json:"freespace" codec:"fs,omitempty"`
+	// Tracked items in each status.
+	Pinned  int `json:"pinned" codec:"pd,omitempty"`
+	Pinning int `json:"pinning" codec:"pg,omitempty"`
+	Queued  int `json:"queued" codec:"q,omitempty"`
+	// Items in error or unexpectedly unpinned.
+	Errors int `json:"errors" codec:"e,omitempty"`
+	// Pin and unpin operations queued or in progress.
+	QueueDepth int `json:"queue_depth" codec:"qd,omitempty"`
+	// Consensus leader as seen by the peer, for consensus components
+	// which have one. Peers seeing different leaders point to a
+	// problem.
+	Leader peer.ID `json:"leader,omitempty" codec:"l,omitempty"`
+	// Set when the stats of the peer could not be obtained.
+	Error string `json:"error,omitempty" codec:"er,omitempty"`
+}
+
+// LogLevel is used to change the log level of a logging facility.
+type LogLevel struct {
+	Facility string `json:"facility" codec:"f,omitempty"`
+	Level    string `json:"level" codec:"l,omitempty"`
+}
+
+// Collection is a named group of CIDs in the shared pinset, which are
+// pinned, unpinned and checked together. The pins of a collection carry its
+// name in their options.
+type Collection struct {
+	Name string    `json:"name" codec:"n,omitempty"`
+	Cids []cid.Cid `json:"cids" codec:"c,omitempty"`
+	// Options to pin the CIDs with. Only used when pinning a
+	// collection.
+	Options *PinOptions `json:"options,omitempty" codec:"o,omitempty"`
+}
+
+// ConfigSection is a section of the configuration of a peer, identified by
+// its key (i.e. "disk" for the disk informer), which is pushed to all the
+// peers of the cluster.
+type ConfigSection struct {
+	Name string `json:"name" codec:"n,omitempty"`
+	// Config is the JSON configuration of the section, as found in the
+	// configuration file. When empty, the current configuration of the
+	// pushing peer is used.
+	Config json.RawMessage `json:"config,omitempty" codec:"c,omitempty"`
+}
+
+// AllocationExclusions lists peers which must not receive new allocations,
+// either by ID or by tag. Excluded peers remain in the cluster and keep the
+// pins already allocated to them.
+type AllocationExclusions struct {
+	Peers []peer.ID `json:"peers,omitempty" codec:"p,omitempty"`
+	// Tags excludes the peers which have, for any of the keys, one of
+	// the given values.
+	Tags map[string][]string `json:"tags,omitempty" codec:"t,omitempty"`
+}
+
+// IsEmpty returns whether no peers are excluded.
+func (ex *AllocationExclusions) IsEmpty() bool {
+	return ex == nil || (len(ex.Peers) == 0 && len(ex.Tags) == 0)
+}
+
+// Excludes returns whether the peer with the given ID and tags is
+// excluded.
+func (ex *AllocationExclusions) Excludes(pid peer.ID, tags map[string]string) bool {
+	if ex == nil {
+		return false
+	}
+	for _, p := range ex.Peers {
+		if p == pid {
+			return true
+		}
+	}
+	for k, values := range ex.Tags {
+		v, ok := tags[k]
+		if !ok {
+			continue
+		}
+		for _, ev := range values {
+			if v == ev {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Merge returns the exclusions of both ex and other.
+func (ex *AllocationExclusions) Merge(other *AllocationExclusions) *AllocationExclusions {
+	merged := &AllocationExclusions{}
+	for _, e := range []*AllocationExclusions{ex, other} {
+		if e == nil {
+			continue
+		}
+		for _, p := range e.Peers {
+			if !merged.Excludes(p, nil) {
+				merged.Peers = append(merged.Peers, p)
+			}
+		}
+		for k, values := range e.Tags {
+			if merged.Tags == nil {
+				merged.Tags = make(map[string][]string)
+			}
+			merged.Tags[k] = append(merged.Tags[k], values...)
+		}
+	}
+	return merged
+}
+
+// Reprovider strategies of the IPFS daemons, which decide the content they
+// announce to the DHT.
+const (
+	// ProvideAll announces all the blocks in the repository.
+	ProvideAll = "all"
+	// ProvidePinned announces the pinned blocks only.
+	ProvidePinned = "pinned"
+	// ProvideRoots announces the roots of the pins only.
+	ProvideRoots = "roots"
+)
+
+// ProvideStrategy decides which IPFS daemons of the cluster announce
+// content to the DHT and which content they announce. With highly
+// replicated pins, having a few peers announce the content avoids every
+// IPFS daemon providing the same blocks.
+type ProvideStrategy struct {
+	// Strategy is the reprovider strategy of the IPFS daemons which
+	// announce content (ProvideAll, ProvidePinned or ProvideRoots).
+	Strategy string `json:"strategy" codec:"s,omitempty"`
+	// Peers, when not empty, are the only cluster peers whose IPFS
+	// daemons announce content. The others disable their reprovider.
+	Peers []peer.ID `json:"peers,omitempty" codec:"p,omitempty"`
+}
+
+// Validate returns an error if the strategy is unknown.
+func (ps *ProvideStrategy) Validate() error {
+	switch ps.Strategy {
+	case ProvideAll, ProvidePinned, ProvideRoots:
+		return nil
+	default:
+		return fmt.Errorf("the provide strategy must be %q, %q or %q", ProvideAll, ProvidePinned, ProvideRoots)
+	}
+}
+
+// Provides returns whether the IPFS daemon of the given peer announces
+// content.
+func (ps *ProvideStrategy) Provides(pid peer.ID) bool {
+	if len(ps.Peers) == 0 {
+		return true
+	}
+	for _, p := range ps.Peers {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// IPFSReprovider configures how an IPFS daemon announces content to the
+// DHT. An Interval of 0 disables announcing.
+type IPFSReprovider struct {
+	Strategy string        `codec:"s,omitempty"`
+	Interval time.Duration `codec:"i,omitempty"`
+}
+
+// PeerMaintenance enables or disables the maintenance mode of a peer.
+type PeerMaintenance struct {
+	Peer    peer.ID `json:"peer" codec:"p,omitempty"`
+	Enabled bool    `json:"enabled" codec:"e,omitempty"`
+	// Re-allocate the pins of the peer to other peers when enabling
+	// maintenance.
+	Repin bool `json:"repin" codec:"r,omitempty"`
+}
+
+// PeerRotation is a request from a cluster peer to replace its peer ID with
+// a new one. It is signed with the private key of the old peer ID.
+type PeerRotation struct {
+	Old peer.ID `json:"old" codec:"o,omitempty"`
+	New peer.ID `json:"new" codec:"n,omitempty"`
+	// Public key of the old peer ID.
+	PubKey []byte `json:"pubkey" codec:"k,omitempty"`
+	// Signature of the old and new peer IDs with the old key.
+	Signature []byte `json:"signature" codec:"s,omitempty"`
+}
+
+func (rot *PeerRotation) signedData() []byte {
+	return []byte(string(rot.Old) + string(rot.New))
+}
+
+// Sign sets the public key and signature of the rotation using the private
+// key of the old peer ID.
+func (rot *PeerRotation) Sign(priv crypto.PrivKey) error {
+	pub, err := crypto.MarshalPublicKey(priv.GetPublic())
+	if err != nil {
+		return err
+	}
+	sig, err := priv.Sign(rot.signedData())
+	if err != nil {
+		return err
+	}
+	rot.PubKey = pub
+	rot.Signature = sig
+	return nil
+}
+
+// Verify checks that the rotation was signed by the key of the old peer ID.
+func (rot *PeerRotation) Verify() error {
+	if rot.Old == "" || rot.New == "" || rot.Old == rot.New {
+		return errors.New("a peer rotation needs different old and new peer IDs")
+	}
+	pub, err := crypto.UnmarshalPublicKey(rot.PubKey)
+	if err != nil {
+		return err
+	}
+	if !rot.Old.MatchesPublicKey(pub) {
+		return errors.New("public key does not match the old peer ID")
+	}
+	ok, err := pub.Verify(rot.signedData(), rot.Signature)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("invalid peer rotation signature")
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	cid "github.com/ipfs/go-cid"
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	ma "github.com/multiformats/go-multiaddr"
 
@@ -189,6 +190,20 @@ func TestPinOptionsQuery(t *testing.T) {
 				"hello":  "bye",
 				"hello2": "bye2",
 			},
+			Policy:    "archive",
+			Namespace: "team-a",
+			Priority:  true,
+
+			FetchRateLimit: 50,
+			LocalPin:       true,
+			StorageClass:   "ssd",
+			PeerGroup:      "hot",
+			Collection:     "dataset",
+			Origins: []Multiaddr{
+				mustMultiaddr("/ip4/1.2.3.4/tcp/4001/p2p/QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc"),
+				mustMultiaddr("/dns4/example.org/tcp/4001"),
+			},
+			Protected: true,
 		},
 		&PinOptions{
 			ReplicationFactorMax: -1,
@@ -230,6 +245,88 @@ func TestPinOptionsQuery(t *testing.T) {
 	}
 }
 
+func TestPinQuery(t *testing.T) {
+	pin := PinWithOpts(testCid1, PinOptions{
+		Metadata: map[string]string{
+			"team": "a",
+		},
+		Namespace:  "ns",
+		Collection: "dataset",
+	})
+	pin.Allocations = []peer.ID{testPeerID1}
+
+	testcases := []struct {
+		q       *PinQuery
+		matches bool
+	}{
+		{&PinQuery{}, true},
+		{&PinQuery{Type: AllType, Namespace: "ns"}, true},
+		{&PinQuery{Type: MetaType}, false},
+		{&PinQuery{Namespace: "other"}, false},
+		{&PinQuery{Metadata: map[string]string{"team": ""}}, true},
+		{&PinQuery{Metadata: map[string]string{"team": "a"}}, true},
+		{&PinQuery{Metadata: map[string]string{"team": "b"}}, false},
+		{&PinQuery{Metadata: map[string]string{"owner": ""}}, false},
+		{&PinQuery{Allocation: testPeerID1}, true},
+		{&PinQuery{Allocation: testPeerID2}, false},
+		{&PinQuery{Collection: "dataset"}, true},
+		{&PinQuery{Collection: "other"}, false},
+	}
+
+	for i, tc := range testcases {
+		if tc.q.Matches(pin) != tc.matches {
+			t.Errorf("%d: expected Matches to be %t", i, tc.matches)
+		}
+
+		v, err := url.ParseQuery(tc.q.ToQuery())
+		if err != nil {
+			t.Fatal(err)
+		}
+		q2 := &PinQuery{}
+		err = q2.FromQuery(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q2.Matches(pin) != tc.matches {
+			t.Errorf("%d: expected Matches to be %t after FromQuery", i, tc.matches)
+		}
+	}
+
+	err := (&PinQuery{}).FromQuery(url.Values{"filter": []string{"abc"}})
+	if err == nil {
+		t.Error("expected an error with an invalid filter")
+	}
+}
+
+func TestPinViewQuery(t *testing.T) {
+	q := &PinViewQuery{
+		PinQuery: PinQuery{Type: DataType, Namespace: "ns"},
+		Offset:   20,
+		Limit:    10,
+	}
+	v, err := url.ParseQuery(q.ToQuery())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q2 := &PinViewQuery{}
+	err = q2.FromQuery(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q2.Type != DataType || q2.Namespace != "ns" || q2.Offset != 20 || q2.Limit != 10 {
+		t.Errorf("unexpected query after FromQuery: %+v", q2)
+	}
+
+	for _, bad := range []url.Values{
+		{"offset": []string{"-1"}},
+		{"limit": []string{"abc"}},
+	} {
+		if err := (&PinViewQuery{}).FromQuery(bad); err == nil {
+			t.Errorf("expected an error with %v", bad)
+		}
+	}
+}
+
 func TestIDCodec(t *testing.T) {
 	TestPeerID1, _ := peer.IDB58Decode("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
 	TestPeerID2, _ := peer.IDB58Decode("QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6")
@@ -291,3 +388,254 @@ func TestPinCodec(t *testing.T) {
 	}
 
 }
+
+func TestPinProtoOrigins(t *testing.T) {
+	ci, _ := cid.Decode("QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc")
+	pin := PinCid(ci)
+	pin.Origins = []Multiaddr{
+		mustMultiaddr("/ip4/1.2.3.4/tcp/4001/p2p/QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc"),
+	}
+
+	b, err := pin.ProtoMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pin2 Pin
+	err = pin2.ProtoUnmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.Equals(&pin2) {
+		t.Errorf("expected equal pins: %+v %+v", pin, pin2)
+	}
+}
+
+func TestPinProtoPlacement(t *testing.T) {
+	pin := PinCid(testCid1)
+	pin.Policy = "archive"
+	pin.PeerGroup = "hot"
+	pin.AllocateBy = "freespace"
+	pin.AllowedTags = map[string][]string{
+		"region": {"eu", "us"},
+		"tier":   {"hdd"},
+	}
+	pin.GroupPeers = []peer.ID{testPeerID1, testPeerID2}
+
+	b, err := pin.ProtoMarshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pin2 Pin
+	err = pin2.ProtoUnmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.Equals(&pin2) {
+		t.Errorf("expected equal pins: %+v %+v", pin, pin2)
+	}
+
+	pin2.AllowedTags["tier"] = []string{"ssd"}
+	if pin.Equals(&pin2) {
+		t.Error("expected different allowed tags to make pins different")
+	}
+}
+
+func mustMultiaddr(s string) Multiaddr {
+	maddr, err := NewMultiaddr(s)
+	if err != nil {
+		panic(err)
+	}
+	return maddr
+}
+
+func TestPinDegraded(t *testing.T) {
+	pin := PinCid(testCid1)
+	pin.ReplicationFactorMin = 2
+	pin.ReplicationFactorMax = 3
+
+	pin.Allocations = []peer.ID{testPeerID1}
+	if pin.Degraded() {
+		t.Error("pin below ReplicationFactorMin should not be degraded")
+	}
+
+	pin.Allocations = []peer.ID{testPeerID1, testPeerID2}
+	if !pin.Degraded() {
+		t.Error("pin should be degraded")
+	}
+
+	pin.Allocations = []peer.ID{testPeerID1, testPeerID2, testPeerID3}
+	if pin.Degraded() {
+		t.Error("pin with ReplicationFactorMax allocations should not be degraded")
+	}
+
+	pin.ReplicationFactorMin = -1
+	pin.ReplicationFactorMax = -1
+	pin.Allocations = nil
+	if pin.Degraded() {
+		t.Error("pin everywhere should not be degraded")
+	}
+}
+
+func TestPinAnonymized(t *testing.T) {
+	pin := PinWithOpts(testCid1, PinOptions{
+		ReplicationFactorMin: 2,
+		ReplicationFactorMax: 3,
+		Name:                 "secret",
+		UserAllocations:      []peer.ID{testPeerID1},
+		Metadata:             map[string]string{"team": "data"},
+		Namespace:            "ns",
+		Owner:                "user",
+	})
+	pin.Allocations = []peer.ID{testPeerID1, testPeerID2}
+	pin.Size = 1000
+
+	anon := pin.Anonymized()
+	if !anon.Cid.Equals(testCid1) || anon.Type != pin.Type || anon.MaxDepth != pin.MaxDepth {
+		t.Error("the anonymized pin should refer to the same content")
+	}
+	if anon.ReplicationFactorMin != 2 || anon.ReplicationFactorMax != 3 {
+		t.Error("the anonymized pin should keep the replication factors")
+	}
+	if anon.Name != "" || len(anon.Metadata) != 0 || len(anon.Allocations) != 0 ||
+		len(anon.UserAllocations) != 0 || anon.Namespace != "" || anon.Owner != "" || anon.Size != 0 {
+		t.Errorf("unexpected anonymized pin: %+v", anon)
+	}
+	if pin.Name != "secret" || len(pin.Allocations) != 2 {
+		t.Error("the original pin should not be modified")
+	}
+}
+
+func TestGlobalPinInfoSetStatus(t *testing.T) {
+	gpi := &GlobalPinInfo{
+		Cid: testCid1,
+		PeerMap: map[string]*PinInfo{
+			"peer1": {Status: TrackerStatusPinned},
+			"peer2": {Status: TrackerStatusPinning},
+			"peer3": {Status: TrackerStatusRemote},
+		},
+	}
+
+	gpi.SetStatus(1)
+	if gpi.Status != GlobalPinStatusSatisfied {
+		t.Error("pin should be satisfied")
+	}
+
+	gpi.SetStatus(2)
+	if gpi.Status != GlobalPinStatusDegraded {
+		t.Error("pin should be degraded")
+	}
+
+	gpi.SetStatus(-1)
+	if gpi.Status != GlobalPinStatusDegraded {
+		t.Error("pin everywhere should be degraded")
+	}
+
+	gpi.PeerMap["peer2"].Status = TrackerStatusPinned
+	gpi.SetStatus(-1)
+	if gpi.Status != GlobalPinStatusSatisfied {
+		t.Error("pin everywhere should be satisfied")
+	}
+
+	gpi.PeerMap["peer1"].Status = TrackerStatusPinError
+	gpi.PeerMap["peer2"].Status = TrackerStatusClusterError
+	gpi.SetStatus(1)
+	if gpi.Status != GlobalPinStatusFailed {
+		t.Error("pin should have failed")
+	}
+}
+
+func TestPeerRotation(t *testing.T) {
+	newKey := func() (crypto.PrivKey, peer.ID) {
+		priv, pub, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pid, err := peer.IDFromPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return priv, pid
+	}
+
+	oldPriv, oldID := newKey()
+	newPriv, newID := newKey()
+
+	rot := &PeerRotation{Old: oldID, New: newID}
+	if err := rot.Verify(); err == nil {
+		t.Error("expected an error verifying an unsigned rotation")
+	}
+
+	if err := rot.Sign(newPriv); err != nil {
+		t.Fatal(err)
+	}
+	if err := rot.Verify(); err == nil {
+		t.Error("expected an error verifying a rotation signed with the new key")
+	}
+
+	if err := rot.Sign(oldPriv); err != nil {
+		t.Fatal(err)
+	}
+	if err := rot.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	rot.New = testPeerID1
+	if err := rot.Verify(); err == nil {
+		t.Error("expected an error verifying a modified rotation")
+	}
+}
+
+func TestProvideStrategy(t *testing.T) {
+	ps := &ProvideStrategy{Strategy: "everything"}
+	if ps.Validate() == nil {
+		t.Error("expected an error with an unknown strategy")
+	}
+
+	ps = &ProvideStrategy{Strategy: ProvidePinned}
+	if err := ps.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !ps.Provides(testPeerID1) {
+		t.Error("all peers should provide when none are given")
+	}
+
+	ps.Peers = []peer.ID{testPeerID2}
+	if ps.Provides(testPeerID1) || !ps.Provides(testPeerID2) {
+		t.Error("only the given peers should provide")
+	}
+}
+
+func TestAllocationExclusions(t *testing.T) {
+	var nilEx *AllocationExclusions
+	if !nilEx.IsEmpty() || nilEx.Excludes(testPeerID1, nil) {
+		t.Error("nil exclusions should exclude nothing")
+	}
+
+	ex := &AllocationExclusions{
+		Peers: []peer.ID{testPeerID1},
+		Tags:  map[string][]string{"rack": {"r1", "r2"}},
+	}
+	if ex.IsEmpty() {
+		t.Error("exclusions should not be empty")
+	}
+	if !ex.Excludes(testPeerID1, nil) {
+		t.Error("peer should be excluded by ID")
+	}
+	if !ex.Excludes(testPeerID2, map[string]string{"rack": "r2"}) {
+		t.Error("peer should be excluded by tag")
+	}
+	if ex.Excludes(testPeerID2, map[string]string{"rack": "r3", "region": "r1"}) {
+		t.Error("peer should not be excluded")
+	}
+
+	merged := ex.Merge(&AllocationExclusions{
+		Peers: []peer.ID{testPeerID1, testPeerID3},
+		Tags:  map[string][]string{"region": {"eu"}},
+	})
+	if len(merged.Peers) != 2 || len(merged.Tags) != 2 {
+		t.Errorf("unexpected merged exclusions: %+v", merged)
+	}
+	if !merged.Excludes(testPeerID4, map[string]string{"region": "eu"}) {
+		t.Error("peer should be excluded by a merged tag")
+	}
+}
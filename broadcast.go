@@ -0,0 +1,69 @@
+package ipfscluster
+
+import (
+	"context"
+
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// broadcastOptions returns the options to call the given endpoint on
+// several peers: at most BroadcastConcurrency calls at once, each with the
+// timeout configured for the endpoint. This peer is called first, as its
+// answer is cheap, and the peers which did not answer the last broadcast
+// are called last, so that they do not hold back the rest.
+func (c *Cluster) broadcastOptions(svc, method string) rpcutil.BroadcastOptions {
+	c.unreachableMux.Lock()
+	last := make([]peer.ID, 0, len(c.unreachable))
+	for p := range c.unreachable {
+		last = append(last, p)
+	}
+	c.unreachableMux.Unlock()
+
+	return rpcutil.BroadcastOptions{
+		Concurrency: c.config.BroadcastConcurrency,
+		Timeout:     c.config.RPCTimeouts[svc+"."+method],
+		First:       []peer.ID{c.id},
+		Last:        last,
+	}
+}
+
+// broadcast runs fn for every destination as set by broadcastOptions and
+// returns their errors in the order of dests.
+func (c *Cluster) broadcast(
+	ctx context.Context,
+	dests []peer.ID,
+	svc, method string,
+	fn func(ctx context.Context, i int) error,
+) []error {
+	errs := rpcutil.Broadcast(ctx, dests, c.broadcastOptions(svc, method), fn)
+	c.updateUnreachable(dests, errs)
+	return errs
+}
+
+// multiCall calls the given endpoint on every destination, like
+// rpc.Client.MultiCall, but with the limits set by broadcastOptions.
+func (c *Cluster) multiCall(
+	ctx context.Context,
+	dests []peer.ID,
+	svc, method string,
+	args interface{},
+	replies []interface{},
+) []error {
+	errs := rpcutil.MultiCall(ctx, c.rpcClient, dests, svc, method, args, replies, c.broadcastOptions(svc, method))
+	c.updateUnreachable(dests, errs)
+	return errs
+}
+
+func (c *Cluster) updateUnreachable(dests []peer.ID, errs []error) {
+	c.unreachableMux.Lock()
+	defer c.unreachableMux.Unlock()
+	for i, err := range errs {
+		if rpcutil.IsUnreachable(err) {
+			c.unreachable[dests[i]] = struct{}{}
+		} else if err == nil {
+			delete(c.unreachable, dests[i])
+		}
+	}
+}
@@ -2,8 +2,11 @@ package ipfscluster
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"math/rand"
 	"mime/multipart"
 	"sort"
 	"sync"
@@ -13,6 +16,7 @@ import (
 	"github.com/ipfs/ipfs-cluster/adder/sharding"
 	"github.com/ipfs/ipfs-cluster/adder/single"
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/config"
 	"github.com/ipfs/ipfs-cluster/pstoremgr"
 	"github.com/ipfs/ipfs-cluster/rpcutil"
 	"github.com/ipfs/ipfs-cluster/state"
@@ -26,6 +30,7 @@ import (
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	"github.com/libp2p/go-libp2p/p2p/discovery"
+	ping "github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	ma "github.com/multiformats/go-multiaddr"
 
 	ocgorpc "github.com/lanzafame/go-libp2p-ocgorpc"
@@ -39,16 +44,30 @@ import (
 var ReadyTimeout = 30 * time.Second
 
 const (
-	pingMetricName      = "ping"
-	bootstrapCount      = 3
-	reBootstrapInterval = 30 * time.Second
-	mdnsServiceTag      = "_ipfs-cluster-discovery._udp"
+	pingMetricName       = "ping"
+	pinStatusMetricName  = "pinstatus"
+	bootstrapCount       = 3
+	reBootstrapInterval  = 30 * time.Second
+	dnsResolveInterval   = 5 * time.Minute
+	addrExchangeInterval = 2 * time.Minute
+	dhtDiscoveryInterval = time.Minute
+	topUpInterval        = 5 * time.Minute
+	repinCheckInterval   = 10 * time.Second
+	mdnsServiceTag       = "_ipfs-cluster-discovery._udp"
+	connMgrProtectTag    = "ipfs-cluster"
+	drainCheckInterval   = 500 * time.Millisecond
+	peersPingTimeout     = 5 * time.Second
 )
 
 var (
 	errFollowerMode = errors.New("this peer is configured to be in follower mode. Write operations are disabled")
+	errDraining     = api.NewCodedError(api.ErrCodeShuttingDown, "this peer is shutting down. Write operations are disabled")
 )
 
+func errPinProtected(h cid.Cid) error {
+	return api.NewCodedError(api.ErrCodePinProtected, "%s is protected and can only be unpinned with force", h)
+}
+
 // Cluster is the main IPFS cluster component. It provides
 // the go-API for it and orchestrates the components that make up the system.
 type Cluster struct {
@@ -83,10 +102,77 @@ type Cluster struct {
 	// peerAdd
 	paMux sync.Mutex
 
-	// shutdown function and related variables
+	// peers which did not answer the last broadcast
+	unreachable    map[peer.ID]struct{}
+	unreachableMux sync.Mutex
+
+	// active alerts, indexed by peer and metric name
+	alerts    map[string]*api.Alert
+	alertsMux sync.Mutex
+
+	// re-allocations of the pins of down or removed peers
+	repins    map[peer.ID]*api.RepinProgress
+	downSince map[peer.ID]time.Time
+	nextRepin time.Time
+	repinsMux sync.Mutex
+
+	// recover schedules run by this peer, indexed by ID
+	recovers      map[string]*recoverSchedule
+	nextRecoverID uint64
+	recoversMux   sync.Mutex
+
+	// long-running jobs queued in this peer, indexed by ID
+	jobs      map[string]*job
+	nextJobID uint64
+	jobsMux   sync.Mutex
+	jobsCh    chan struct{}
+
+	// maintenance mode (no new allocations)
+	maintenanceMux sync.RWMutex
+	maintenance    bool
+
+	// standby mode (no allocations until activated)
+	standbyMux sync.RWMutex
+	standby    bool
+
+	// allocation exclusions set at runtime
+	exclusionsMux sync.RWMutex
+	exclusions    *api.AllocationExclusions
+
+	// provide strategy set at runtime
+	provideMux      sync.RWMutex
+	provideStrategy *api.ProvideStrategy
+
+	// startup, shutdown function and related variables
 	shutdownLock sync.Mutex
+	startedB     bool
 	shutdownB    bool
+	restarting   bool
 	removed      bool
+
+	// pinset writes in progress and draining (on shutdown). drainCh is
+	// closed when draining starts.
+	writesWg sync.WaitGroup
+	drainMux sync.RWMutex
+	draining bool
+	drainCh  chan struct{}
+
+	// upgrades of the running binary
+	upgradeF   UpgradeFunc
+	upgradeMux sync.Mutex
+
+	// configuration sections which can be pushed by other peers, and
+	// the options pushed to them since the peer started
+	sharedConfigs   map[string]config.ComponentConfig
+	pushedConfigs   map[string]map[string]json.RawMessage
+	configSaver     ConfigSaver
+	sharedConfigMux sync.Mutex
+
+	// in-memory index of the shared pinset
+	pinIndex *pinIndex
+
+	// snapshots of the status listings being served in pages over RPC
+	statusPager *pinInfoPager
 }
 
 // NewCluster builds a new IPFS Cluster peer. It initializes a LibP2P host,
@@ -95,6 +181,9 @@ type Cluster struct {
 // The new cluster peer may still be performing initialization tasks when
 // this call returns (consensus may still be bootstrapping). Use Cluster.Ready()
 // if you need to wait until the peer is fully up.
+//
+// NewCluster is equivalent to calling New with all the components and then
+// Start.
 func NewCluster(
 	ctx context.Context,
 	host host.Host,
@@ -110,103 +199,27 @@ func NewCluster(
 	informers []Informer,
 	tracer Tracer,
 ) (*Cluster, error) {
-	err := cfg.Validate()
+	c, err := New(
+		ctx,
+		host,
+		cfg,
+		WithDHT(dht),
+		WithDatastore(datastore),
+		WithConsensus(consensus),
+		WithAPIs(apis...),
+		WithIPFSConnector(ipfs),
+		WithPinTracker(tracker),
+		WithPeerMonitor(monitor),
+		WithPinAllocator(allocator),
+		WithInformers(informers...),
+		WithTracer(tracer),
+	)
 	if err != nil {
 		return nil, err
 	}
-
-	if host == nil {
-		return nil, errors.New("cluster host is nil")
-	}
-
-	if len(informers) == 0 {
-		return nil, errors.New("no informers are passed")
-	}
-
-	ctx, cancel := context.WithCancel(ctx)
-
-	listenAddrs := ""
-	for _, addr := range host.Addrs() {
-		listenAddrs += fmt.Sprintf("        %s/p2p/%s\n", addr, host.ID().Pretty())
-	}
-
-	logger.Infof("IPFS Cluster v%s listening on:\n%s\n", version.Version, listenAddrs)
-
-	peerManager := pstoremgr.New(ctx, host, cfg.GetPeerstorePath())
-
-	var mdns discovery.Service
-	if cfg.MDNSInterval > 0 {
-		mdns, err := discovery.NewMdnsService(ctx, host, cfg.MDNSInterval, mdnsServiceTag)
-		if err != nil {
-			cancel()
-			return nil, err
-		}
-		mdns.RegisterNotifee(peerManager)
-	}
-
-	c := &Cluster{
-		ctx:         ctx,
-		cancel:      cancel,
-		id:          host.ID(),
-		config:      cfg,
-		host:        host,
-		dht:         dht,
-		discovery:   mdns,
-		datastore:   datastore,
-		consensus:   consensus,
-		apis:        apis,
-		ipfs:        ipfs,
-		tracker:     tracker,
-		monitor:     monitor,
-		allocator:   allocator,
-		informers:   informers,
-		tracer:      tracer,
-		peerManager: peerManager,
-		shutdownB:   false,
-		removed:     false,
-		doneCh:      make(chan struct{}),
-		readyCh:     make(chan struct{}),
-		readyB:      false,
-	}
-
-	// Import known cluster peers from peerstore file and config. Set
-	// a non permanent TTL.
-	c.peerManager.ImportPeersFromPeerstore(false, peerstore.AddressTTL)
-	c.peerManager.ImportPeers(c.config.PeerAddresses, false, peerstore.AddressTTL)
-	// Attempt to connect to some peers (up to bootstrapCount)
-	connectedPeers := c.peerManager.Bootstrap(bootstrapCount)
-	// We cannot warn when count is low as this as this is normal if going
-	// to Join() later.
-	logger.Debugf("bootstrap count %d", len(connectedPeers))
-	// Log a ping metric for every connected peer. This will make them
-	// visible as peers without having to wait for them to send one.
-	for _, p := range connectedPeers {
-		if err := c.logPingMetric(ctx, p); err != nil {
-			logger.Warning(err)
-		}
-	}
-
-	// Bootstrap the DHT now that we possibly have some connections
-	c.dht.Bootstrap(c.ctx)
-
-	// After setupRPC components can do their tasks with a fully operative
-	// routed libp2p host with some connections and a working DHT (hopefully).
-	err = c.setupRPC()
-	if err != nil {
-		c.Shutdown(ctx)
+	if err := c.Start(ctx); err != nil {
 		return nil, err
 	}
-	c.setupRPCClients()
-
-	// Note: It is very important to first call Add() once in a non-racy
-	// place
-	c.wg.Add(1)
-	go func() {
-		defer c.wg.Done()
-		c.ready(ReadyTimeout)
-		c.run()
-	}()
-
 	return c, nil
 }
 
@@ -252,31 +265,61 @@ func (c *Cluster) watchPinset() {
 	ctx, span := trace.StartSpan(c.ctx, "cluster/watchPinset")
 	defer span.End()
 
-	stateSyncTicker := time.NewTicker(c.config.StateSyncInterval)
-	recoverTicker := time.NewTicker(c.config.PinRecoverInterval)
+	// Peers restarted at once should not hit their IPFS daemons at the
+	// same time: the first runs are staggered depending on the peer ID
+	// and every interval gets a random delay.
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	jittered := func(d time.Duration) time.Duration {
+		if c.config.SyncJitter <= 0 {
+			return d
+		}
+		return d + time.Duration(rng.Int63n(int64(c.config.SyncJitter)))
+	}
+
+	stateSyncTimer := time.NewTimer(peerStagger(c.id, "state_sync", c.config.StateSyncInterval))
+	recoverTimer := time.NewTimer(peerStagger(c.id, "recover", c.config.PinRecoverInterval))
 
 	for {
 		select {
-		case <-stateSyncTicker.C:
+		case <-stateSyncTimer.C:
 			logger.Debug("auto-triggering StateSync()")
 			c.StateSync(ctx)
-		case <-recoverTicker.C:
+			stateSyncTimer.Reset(jittered(c.config.StateSyncInterval))
+		case <-recoverTimer.C:
 			logger.Debug("auto-triggering RecoverAllLocal()")
 			c.RecoverAllLocal(ctx)
+			recoverTimer.Reset(jittered(c.config.PinRecoverInterval))
 		case <-c.ctx.Done():
-			stateSyncTicker.Stop()
-			recoverTicker.Stop()
+			stateSyncTimer.Stop()
+			recoverTimer.Stop()
 			return
 		}
 	}
 }
 
+// peerStagger returns a delay in (0, interval] which depends on the given
+// peer ID and operation, so that peers run periodic operations at
+// different times while keeping the same delay across restarts.
+func peerStagger(pid peer.ID, op string, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return interval
+	}
+	h := fnv.New64a()
+	h.Write([]byte(pid))
+	h.Write([]byte(op))
+	return time.Duration(h.Sum64()%uint64(interval)) + 1
+}
+
 func (c *Cluster) sendInformerMetric(ctx context.Context, informer Informer) (*api.Metric, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/sendInformerMetric")
 	defer span.End()
 
 	metric := informer.GetMetric(ctx)
 	metric.Peer = c.id
+	metric.Peername = c.config.Peername
+	metric.Tags = c.config.Tags
+	metric.Maintenance = c.inMaintenance()
+	metric.Standby = c.inStandby()
 	return metric, c.monitor.PublishMetric(ctx, metric)
 }
 
@@ -343,9 +386,74 @@ func (c *Cluster) sendPingMetric(ctx context.Context) (*api.Metric, error) {
 	defer span.End()
 
 	metric := &api.Metric{
-		Name:  pingMetricName,
-		Peer:  c.id,
-		Valid: true,
+		Name:              pingMetricName,
+		Peer:              c.id,
+		Valid:             true,
+		Tags:              c.config.Tags,
+		Peername:          c.config.Peername,
+		Maintenance:       c.inMaintenance(),
+		Standby:           c.inStandby(),
+		StorageClass:      c.config.StorageClass,
+		PendingOperations: c.tracker.PendingOperations(ctx),
+	}
+	metric.SetTTL(c.config.MonitorPingInterval * 2)
+	return metric, c.monitor.PublishMetric(ctx, metric)
+}
+
+// pinStatusSummary counts the pins tracked by this peer in each status.
+// Pins being pinned, queued or in error are obtained from the operations of
+// the pin tracker, and the rest of the pins allocated to this peer are
+// considered pinned. It returns nil until the pinset index is ready.
+func (c *Cluster) pinStatusSummary(ctx context.Context) *api.PinStatusSummary {
+	allocated, ok := c.pinIndex.countAllocated(c.id)
+	if !ok {
+		return nil
+	}
+
+	summary := &api.PinStatusSummary{}
+	notPinned := 0
+	for _, op := range c.tracker.Operations(ctx) {
+		switch op.Phase {
+		case "queued":
+			summary.Queued++
+		case "inprogress":
+			if op.Type == "pin" {
+				summary.Pinning++
+			}
+		case "error":
+			summary.Error++
+		default:
+			continue
+		}
+		if op.Type == "pin" {
+			notPinned++
+		}
+	}
+	if allocated > notPinned {
+		summary.Pinned = allocated - notPinned
+	}
+	return summary
+}
+
+// sendPinStatusMetric broadcasts the summary of the pins tracked by this
+// peer. The value of the metric is the number of pins in the summary.
+func (c *Cluster) sendPinStatusMetric(ctx context.Context) (*api.Metric, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/sendPinStatusMetric")
+	defer span.End()
+
+	summary := c.pinStatusSummary(ctx)
+	if summary == nil {
+		return nil, nil
+	}
+	total := summary.Pinned + summary.Pinning + summary.Queued + summary.Error
+	metric := &api.Metric{
+		Name:      pinStatusMetricName,
+		Peer:      c.id,
+		Value:     fmt.Sprintf("%d", total),
+		Valid:     true,
+		Peername:  c.config.Peername,
+		Tags:      c.config.Tags,
+		PinStatus: summary,
 	}
 	metric.SetTTL(c.config.MonitorPingInterval * 2)
 	return metric, c.monitor.PublishMetric(ctx, metric)
@@ -379,6 +487,9 @@ func (c *Cluster) pushPingMetrics(ctx context.Context) {
 	ticker := time.NewTicker(c.config.MonitorPingInterval)
 	for {
 		c.sendPingMetric(ctx)
+		if _, err := c.sendPinStatusMetric(ctx); err != nil {
+			logger.Errorf("error publishing the pin status metric: %s", err)
+		}
 
 		select {
 		case <-ctx.Done():
@@ -395,6 +506,10 @@ func (c *Cluster) alertsHandler() {
 		case <-c.ctx.Done():
 			return
 		case alrt := <-c.monitor.Alerts():
+			c.alertsMux.Lock()
+			c.alerts[alertKey(alrt)] = alrt
+			c.alertsMux.Unlock()
+
 			// Follower peers do not care about alerts.
 			// They can do nothing about them.
 			if c.config.FollowerMode {
@@ -408,29 +523,21 @@ func (c *Cluster) alertsHandler() {
 
 			if c.config.DisableRepinning {
 				logger.Debugf("repinning is disabled. Will not re-allocate pins on alerts")
-				return
+				continue
 			}
 
-			cState, err := c.consensus.State(c.ctx)
-			if err != nil {
-				logger.Warning(err)
-				return
-			}
-			list, err := cState.List(c.ctx)
-			if err != nil {
-				logger.Warning(err)
-				return
-			}
-			for _, pin := range list {
-				if len(pin.Allocations) == 1 && containsPeer(pin.Allocations, alrt.Peer) {
-					logger.Warning("a pin with only one allocation cannot be repinned")
-					logger.Warning("to make repinning possible, pin with a replication factor of 2+")
-					continue
-				}
-				if c.shouldPeerRepinCid(alrt.Peer, pin) {
-					c.repinFromPeer(c.ctx, alrt.Peer, pin)
-				}
+			since := c.markPeerDown(alrt.Peer, alrt.TriggeredAt)
+			if c.config.RepinDelay > 0 {
+				// repinDownPeers() takes care of it.
+				logger.Infof("pins allocated to %s will be re-allocated if it is down for %s", alrt.Peer.Pretty(), c.config.RepinDelay)
+				continue
 			}
+
+			c.wg.Add(1)
+			go func(p peer.ID) {
+				defer c.wg.Done()
+				c.repinDownPeer(c.ctx, p, since)
+			}(alrt.Peer)
 		}
 	}
 }
@@ -456,10 +563,17 @@ func (c *Cluster) shouldPeerRepinCid(failed peer.ID, pin *api.Pin) bool {
 
 // detects any changes in the peerset and saves the configuration. When it
 // detects that we have been removed from the peerset, it shuts down this peer.
+// Connections to peers in the peerset are protected from being closed by the
+// connection manager.
 func (c *Cluster) watchPeers() {
 	ticker := time.NewTicker(c.config.PeerWatchInterval)
 	defer ticker.Stop()
 
+	var protected map[peer.ID]struct{}
+	if peers, err := c.consensus.Peers(c.ctx); err == nil {
+		protected = c.protectPeers(peers, protected)
+	}
+
 	for {
 		select {
 		case <-c.ctx.Done():
@@ -478,6 +592,7 @@ func (c *Cluster) watchPeers() {
 					break
 				}
 			}
+			protected = c.protectPeers(peers, protected)
 
 			if !hasMe {
 				c.shutdownLock.Lock()
@@ -491,6 +606,29 @@ func (c *Cluster) watchPeers() {
 	}
 }
 
+// protectPeers protects the connections to the given peers from being
+// pruned by the connection manager, and removes the protection from the
+// previously protected peers which are no longer in the list. It returns
+// the new set of protected peers.
+func (c *Cluster) protectPeers(peers []peer.ID, previous map[peer.ID]struct{}) map[peer.ID]struct{} {
+	connman := c.host.ConnManager()
+	protected := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		if p == c.id {
+			continue
+		}
+		connman.Protect(p, connMgrProtectTag)
+		protected[p] = struct{}{}
+	}
+
+	for p := range previous {
+		if _, ok := protected[p]; !ok {
+			connman.Unprotect(p, connMgrProtectTag)
+		}
+	}
+	return protected
+}
+
 // reBootstrap regularly attempts to bootstrap (re-connect to peers from the
 // peerstore). This should ensure that we auto-recover from situations in
 // which the network was completely gone and we lost all peers.
@@ -511,8 +649,24 @@ func (c *Cluster) reBootstrap() {
 	}
 }
 
+// reResolveDNS regularly resolves the DNS multiaddresses of known peers
+// again, so that peers whose IPs have changed can still be reached.
+func (c *Cluster) reResolveDNS() {
+	ticker := time.NewTicker(dnsResolveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.peerManager.ResolveDNSAddrs()
+		}
+	}
+}
+
 // find all Cids pinned to a given peer and triggers re-pins on them.
-func (c *Cluster) vacatePeer(ctx context.Context, p peer.ID) {
+func (c *Cluster) vacatePeer(ctx context.Context, p peer.ID, reason string) {
 	ctx, span := trace.StartSpan(ctx, "cluster/vacatePeer")
 	defer span.End()
 
@@ -531,24 +685,35 @@ func (c *Cluster) vacatePeer(ctx context.Context, p peer.ID) {
 		logger.Warning(err)
 		return
 	}
+	var pins []*api.Pin
 	for _, pin := range list {
 		if containsPeer(pin.Allocations, p) {
-			c.repinFromPeer(ctx, p, pin)
+			pins = append(pins, pin)
 		}
 	}
+
+	progress, ok := c.startRepin(p, reason, time.Now())
+	if !ok {
+		return
+	}
+	c.repinPins(ctx, progress, pins)
 }
 
 // repinFromPeer triggers a repin on a given pin object blacklisting one of the
 // allocations.
-func (c *Cluster) repinFromPeer(ctx context.Context, p peer.ID, pin *api.Pin) {
+func (c *Cluster) repinFromPeer(ctx context.Context, p peer.ID, pin *api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "cluster/repinFromPeer")
 	defer span.End()
 
 	pin.Allocations = nil // force re-allocations
-	_, ok, err := c.pin(ctx, pin, []peer.ID{p})
-	if ok && err == nil {
+	_, ok, err := c.pin(ctx, pin, []peer.ID{p}, false)
+	if err != nil {
+		return err
+	}
+	if ok {
 		logger.Infof("repinned %s out of %s", pin.Cid, p.Pretty())
 	}
+	return nil
 }
 
 // run launches some go-routines which live throughout the cluster's life
@@ -590,6 +755,66 @@ func (c *Cluster) run() {
 		defer c.wg.Done()
 		c.reBootstrap()
 	}()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.reResolveDNS()
+	}()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.exchangeAddrs()
+	}()
+
+	c.runLeaderTasks()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.repinDownPeers()
+	}()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.runJobs()
+	}()
+
+	if c.config.KeepAliveInterval > 0 {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.keepAlive()
+		}()
+	}
+
+	if c.config.DHTRendezvous != "" {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.dhtDiscovery()
+		}()
+	}
+
+	if c.config.IPNSPublishKey != "" {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.publishPinsetLoop()
+		}()
+	}
+
+	if ps := c.ProvideStrategy(c.ctx); ps.Strategy != "" {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			if err := c.applyProvideStrategy(c.ctx, ps); err != nil {
+				logger.Errorf("error setting the reprovider of the IPFS daemon: %s", err)
+			}
+		}()
+	}
 }
 
 func (c *Cluster) ready(timeout time.Duration) {
@@ -623,7 +848,18 @@ This might be due to one or several causes:
 		// Consensus ready means the state is up to date. Every item
 		// in the state that is not pinned will appear as PinError so
 		// we can proceed to recover all of those in the tracker.
+		// Local pins are not in the state and are handed to the
+		// tracker first.
+		if err := c.trackLocalPins(ctx); err != nil {
+			logger.Errorf("error tracking the local pins: %s", err)
+		}
 		c.RecoverAllLocal(ctx)
+		if err := c.rebuildPinIndex(ctx); err != nil {
+			logger.Errorf("error indexing the pinset: %s", err)
+		}
+		if err := c.loadRecoverSchedules(); err != nil {
+			logger.Errorf("error loading recover schedules: %s", err)
+		}
 	case <-c.ctx.Done():
 		return
 	}
@@ -648,10 +884,10 @@ This might be due to one or several causes:
 		}
 	}
 
-	close(c.readyCh)
 	c.shutdownLock.Lock()
 	c.readyB = true
 	c.shutdownLock.Unlock()
+	close(c.readyCh)
 	logger.Info("** IPFS Cluster is READY **")
 }
 
@@ -661,6 +897,41 @@ func (c *Cluster) Ready() <-chan struct{} {
 	return c.readyCh
 }
 
+// Alive checks that the consensus and the pin tracker of this peer answer
+// before the given context is cancelled. A peer which does not answer is
+// likely hung.
+func (c *Cluster) Alive(ctx context.Context) error {
+	_, span := trace.StartSpan(ctx, "cluster/Alive")
+	defer span.End()
+
+	// Components which hang may not honor the context, so the check
+	// runs apart.
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := c.consensus.Peers(ctx)
+		if err == nil {
+			c.tracker.PendingOperations(ctx)
+		}
+		errCh <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errCh:
+		return err
+	}
+}
+
+// ShutdownForRestart shuts down the peer like Shutdown, but never leaves the
+// cluster, as the peer is going to be started again.
+func (c *Cluster) ShutdownForRestart(ctx context.Context) error {
+	c.shutdownLock.Lock()
+	c.restarting = true
+	c.shutdownLock.Unlock()
+	return c.Shutdown(ctx)
+}
+
 // Shutdown performs all the necessary operations to shutdown
 // the IPFS Cluster peer:
 // * Save peerstore with the current peers
@@ -675,6 +946,10 @@ func (c *Cluster) Shutdown(ctx context.Context) error {
 	defer span.End()
 	ctx = trace.NewContext(c.ctx, span)
 
+	// Draining may take up to ShutdownDrainTimeout, so it does not hold
+	// shutdownLock.
+	c.drain(ctx)
+
 	c.shutdownLock.Lock()
 	defer c.shutdownLock.Unlock()
 
@@ -702,7 +977,7 @@ func (c *Cluster) Shutdown(ctx context.Context) error {
 	// - consensus is initialized
 	// - cluster was ready (no bootstrapping error)
 	// - We are not removed already (means watchPeers() called us)
-	if c.consensus != nil && c.config.LeaveOnShutdown && c.readyB && !c.removed {
+	if c.consensus != nil && c.config.LeaveOnShutdown && !c.restarting && c.readyB && !c.removed {
 		c.removed = true
 		_, err := c.consensus.Peers(ctx)
 		if err == nil {
@@ -779,6 +1054,74 @@ func (c *Cluster) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// startWrite registers an ongoing pinset write operation. It fails when
+// the peer is draining. writesWg.Done() must be called when the write
+// finishes.
+func (c *Cluster) startWrite() error {
+	c.drainMux.RLock()
+	defer c.drainMux.RUnlock()
+	if c.draining {
+		return errDraining
+	}
+	c.writesWg.Add(1)
+	return nil
+}
+
+// drain stops accepting pinset writes and waits, up to
+// ShutdownDrainTimeout, for the ongoing ones to be committed and for the
+// tracker to finish all pending operations. Anything left will be
+// picked up again on the next start, as the shared state is the source
+// of truth for the pinset.
+func (c *Cluster) drain(ctx context.Context) {
+	c.drainMux.Lock()
+	if !c.draining {
+		c.draining = true
+		close(c.drainCh)
+	}
+	c.drainMux.Unlock()
+
+	c.shutdownLock.Lock()
+	ready := c.readyB && !c.shutdownB
+	c.shutdownLock.Unlock()
+
+	timeout := c.config.ShutdownDrainTimeout
+	if timeout <= 0 || !ready {
+		return
+	}
+
+	logger.Infof("draining pinset operations (up to %s)", timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	writesDone := make(chan struct{})
+	go func() {
+		c.writesWg.Wait()
+		close(writesDone)
+	}()
+
+	select {
+	case <-writesDone:
+	case <-ctx.Done():
+		logger.Warning("timed out waiting for pinset operations to be committed")
+		return
+	}
+
+	ticker := time.NewTicker(drainCheckInterval)
+	defer ticker.Stop()
+	for {
+		pending := c.tracker.PendingOperations(ctx)
+		if pending == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			logger.Warningf("shutting down with %d pending pin/unpin operations. They will be retried on restart", pending)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 // Done provides a way to learn if the Peer has been shutdown
 // (for example, because it has been removed from the Cluster)
 func (c *Cluster) Done() <-chan struct{} {
@@ -836,6 +1179,8 @@ func (c *Cluster) ID(ctx context.Context) *api.ID {
 		RPCProtocolVersion:    version.RPCProtocol,
 		IPFS:                  ipfsID,
 		Peername:              c.config.Peername,
+		Maintenance:           c.inMaintenance(),
+		Standby:               c.inStandby(),
 	}
 	if err != nil {
 		id.Error = err.Error()
@@ -897,7 +1242,7 @@ func (c *Cluster) PeerRemove(ctx context.Context, pid peer.ID) error {
 	// We need to repin before removing the peer, otherwise, it won't
 	// be able to submit the pins.
 	logger.Infof("re-allocating all CIDs directly associated to %s", pid)
-	c.vacatePeer(ctx, pid)
+	c.vacatePeer(ctx, pid, repinReasonRemoved)
 
 	err := c.consensus.RmPeer(ctx, pid)
 	if err != nil {
@@ -908,6 +1253,193 @@ func (c *Cluster) PeerRemove(ctx context.Context, pid peer.ID) error {
 	return nil
 }
 
+// PeerRemoveDryRun reports the impact of removing the given peer with
+// PeerRemove, without removing it: how many pins would be re-allocated and
+// to which peers. Nothing is modified.
+func (c *Cluster) PeerRemoveDryRun(ctx context.Context, pid peer.ID) (*api.PeerRemoveReport, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PeerRemoveDryRun")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !containsPeer(peers, pid) {
+		return nil, fmt.Errorf("%s is not a cluster peer", pid.Pretty())
+	}
+
+	report := &api.PeerRemoveReport{
+		Peer:              pid,
+		Receivers:         make(map[string]int),
+		RepinningDisabled: c.config.DisableRepinning,
+	}
+
+	var repoStat api.IPFSRepoStat
+	err = c.rpcClient.CallContext(
+		ctx,
+		pid,
+		"IPFSConnector",
+		"RepoStat",
+		struct{}{},
+		&repoStat,
+	)
+	if err != nil {
+		logger.Warningf("cannot obtain repository size of %s: %s", pid.Pretty(), err)
+	}
+	report.RepoSize = repoStat.RepoSize
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pin := range list {
+		if !containsPeer(pin.Allocations, pid) {
+			continue
+		}
+		report.AllocatedPins++
+		if c.config.DisableRepinning {
+			continue
+		}
+
+		// Same allocation as done by repinFromPeer().
+		allocs, err := c.allocate(ctx, pin, []peer.ID{pid})
+		if err != nil {
+			report.UnallocatablePins++
+			continue
+		}
+		report.ReallocatedPins++
+		for _, a := range allocs {
+			if !containsPeer(pin.Allocations, a) {
+				report.Receivers[peer.IDB58Encode(a)]++
+			}
+		}
+	}
+	return report, nil
+}
+
+// RotatePeer replaces the ID of a cluster peer with a new one, as requested
+// by the peer itself with a signed api.PeerRotation. The new ID is added to
+// the consensus peerset, pins allocated to the old ID are allocated to the
+// new one and the old ID is removed from the peerset. Finally, all peers are
+// asked to replace the old ID with the new one with RotatePeerLocal.
+func (c *Cluster) RotatePeer(ctx context.Context, rot *api.PeerRotation) error {
+	_, span := trace.StartSpan(ctx, "cluster/RotatePeer")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if err := rot.Verify(); err != nil {
+		return err
+	}
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+	if !containsPeer(peers, rot.Old) {
+		return fmt.Errorf("%s is not a cluster peer", rot.Old.Pretty())
+	}
+
+	logger.Infof("rotating peer ID %s to %s", rot.Old.Pretty(), rot.New.Pretty())
+	err = c.consensus.AddPeer(ctx, rot.New)
+	if err != nil {
+		return err
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := cState.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, pin := range list {
+		if !containsPeer(pin.Allocations, rot.Old) {
+			continue
+		}
+		for i, a := range pin.Allocations {
+			if a == rot.Old {
+				pin.Allocations[i] = rot.New
+			}
+		}
+		err := c.consensus.LogPin(ctx, pin)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Not all consensus components manage the peerset (i.e. crdt).
+	err = c.consensus.RmPeer(ctx, rot.Old)
+	if err != nil {
+		logger.Warningf("could not remove %s from the peerset: %s", rot.Old.Pretty(), err)
+	}
+
+	peers, err = c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+	var dests []peer.ID
+	for _, p := range peers {
+		if p != rot.Old && p != rot.New {
+			dests = append(dests, p)
+		}
+	}
+	if !containsPeer(dests, c.id) {
+		dests = append(dests, c.id)
+	}
+
+	errs := c.multiCall(
+		ctx,
+		dests,
+		"Cluster",
+		"RotatePeerLocal",
+		rot,
+		rpcutil.RPCDiscardReplies(len(dests)),
+	)
+	for i, err := range errs {
+		if err != nil {
+			logger.Warningf("%s could not rotate %s: %s", dests[i].Pretty(), rot.Old.Pretty(), err)
+		}
+	}
+	return nil
+}
+
+// RotatePeerLocal replaces the old peer ID in a signed api.PeerRotation with
+// the new one in this peer's peerstore and set of trusted peers.
+func (c *Cluster) RotatePeerLocal(ctx context.Context, rot *api.PeerRotation) error {
+	_, span := trace.StartSpan(ctx, "cluster/RotatePeerLocal")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if err := rot.Verify(); err != nil {
+		return err
+	}
+
+	if c.consensus.IsTrustedPeer(ctx, rot.Old) {
+		err := c.consensus.Trust(ctx, rot.New)
+		if err != nil {
+			return err
+		}
+		err = c.consensus.Distrust(ctx, rot.Old)
+		if err != nil {
+			return err
+		}
+		logger.Warningf("%s is now trusted instead of %s. Update trusted_peers in the configuration accordingly", rot.New.Pretty(), rot.Old.Pretty())
+	}
+
+	addrs := c.host.Peerstore().Addrs(rot.Old)
+	c.host.Peerstore().AddAddrs(rot.New, addrs, peerstore.PermanentAddrTTL)
+	c.peerManager.RmPeer(rot.Old)
+	logger.Infof("peer ID %s rotated to %s", rot.Old.Pretty(), rot.New.Pretty())
+	return nil
+}
+
 // Join adds this peer to an existing cluster by bootstrapping to a
 // given multiaddress. It works by calling PeerAdd on the destination
 // cluster and making sure that the new peer is ready to discover and contact
@@ -969,9 +1501,11 @@ func (c *Cluster) Join(ctx context.Context, addr ma.Multiaddr) error {
 	// by triggering 1 round of bootstrap in the background.
 	// Note that our regular bootstrap process is still running in the
 	// background since we created the cluster.
-	go func() {
-		c.dht.BootstrapOnce(ctx, dht.DefaultBootstrapConfig)
-	}()
+	if c.dht != nil {
+		go func() {
+			c.dht.BootstrapOnce(ctx, dht.DefaultBootstrapConfig)
+		}()
+	}
 
 	// ConnectSwarms in the background after a while, when we have likely
 	// received some metrics.
@@ -997,65 +1531,135 @@ func (c *Cluster) Join(ctx context.Context, addr ma.Multiaddr) error {
 // StateSync performs maintenance tasks on the global state that require
 // looping through all the items. It is triggered automatically on
 // StateSyncInterval. Currently it:
-//   * Sends unpin for expired items for which this peer is "closest"
-//     (skipped for follower peers)
+//   * Rebuilds the in-memory pinset index
+// Expired items are unpinned by the "unpin_expired" leader task (see
+// unpinExpired), so that only one peer sends the unpins.
 func (c *Cluster) StateSync(ctx context.Context) error {
 	_, span := trace.StartSpan(ctx, "cluster/StateSync")
 	defer span.End()
 	ctx = trace.NewContext(c.ctx, span)
 
-	if c.config.FollowerMode {
-		return nil
+	c.pinIndex.startRebuild()
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		c.pinIndex.finishRebuild(nil)
+		return err
 	}
 
-	cState, err := c.consensus.State(ctx)
+	clusterPins, err := cState.List(ctx)
 	if err != nil {
+		c.pinIndex.finishRebuild(nil)
 		return err
 	}
+	if clusterPins == nil {
+		clusterPins = []*api.Pin{}
+	}
+	c.pinIndex.finishRebuild(clusterPins)
+	return nil
+}
 
+// unpinExpired sends unpin for the items of the global state which have
+// expired. It runs as a leader task.
+func (c *Cluster) unpinExpired(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/unpinExpired")
+	defer span.End()
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return err
+	}
 	timeNow := time.Now()
 	clusterPins, err := cState.List(ctx)
 	if err != nil {
 		return err
 	}
 
-	// Only trigger pin operations if we are the closest with respect to
-	// other trusted peers. We cannot know if our peer ID is trusted by
-	// other peers in the Cluster. This assumes yes. Setting FollowerMode
-	// is a way to assume the opposite and skip this completely.
-	trustedPeers, err := c.getTrustedPeers(ctx)
-	if err != nil {
-		return nil
+	for _, p := range clusterPins {
+		if !p.ExpiredAt(timeNow) {
+			continue
+		}
+		logger.Infof("Unpinning %s: pin expired at %s", p.Cid, p.ExpireAt)
+		if _, err := c.unpin(ctx, p.Cid, true); err != nil {
+			logger.Error(err)
+		}
+	}
+	return nil
+}
+
+// StatusAll returns the GlobalPinInfo for all tracked Cids in all peers.
+// If an error happens, the slice will contain as much information as
+// could be fetched from other peers.
+func (c *Cluster) StatusAll(ctx context.Context) ([]*api.GlobalPinInfo, error) {
+	_, span := trace.StartSpan(ctx, "cluster/StatusAll")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	return c.globalPinInfoSlice(ctx, "PinTracker", "StatusAll")
+}
+
+// StatusChanges returns the status of the Cids whose status changed after
+// the given time in any of the current peers, along with the time until
+// which changes are included. It fails when any peer cannot provide its
+// changes (i.e. because they are too old), in which case the full status
+// should be obtained with StatusAll.
+func (c *Cluster) StatusChanges(ctx context.Context, since time.Time) (*api.StatusChanges, error) {
+	_, span := trace.StartSpan(ctx, "cluster/StatusChanges")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	var members []peer.ID
+	var err error
+	if c.config.FollowerMode {
+		members = []peer.ID{c.host.ID()}
+	} else {
+		members, err = c.consensus.Peers(ctx)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
 	}
+	lenMembers := len(members)
 
-	checker := distanceChecker{
-		local:      c.id,
-		otherPeers: trustedPeers,
-		cache:      make(map[peer.ID]distance, len(trustedPeers)+1),
+	// Changes happening while we ask are returned again next time
+	// rather than lost.
+	changes := &api.StatusChanges{
+		Since: since,
+		Until: time.Now(),
 	}
 
-	// Unpin expired items when we are the closest peer to them.
-	for _, p := range clusterPins {
-		if p.ExpiredAt(timeNow) && checker.isClosest(p.Cid) {
-			logger.Infof("Unpinning %s: pin expired at %s", p.Cid, p.ExpireAt)
-			if _, err := c.Unpin(ctx, p.Cid); err != nil {
-				logger.Error(err)
+	replies := make([][]*api.PinInfo, lenMembers, lenMembers)
+	errs := c.multiCall(
+		ctx,
+		members,
+		"PinTracker",
+		"StatusChanges",
+		since,
+		rpcutil.CopyPinInfoSliceToIfaces(replies),
+	)
+
+	gpis := make(map[cid.Cid]*api.GlobalPinInfo)
+	for i, r := range replies {
+		if e := errs[i]; e != nil {
+			if rpc.IsAuthorizationError(e) {
+				logger.Debug("rpc auth error", e)
+				continue
 			}
+			return nil, fmt.Errorf("error obtaining status changes from %s: %s", members[i].Pretty(), e)
+		}
+		for _, pinfo := range r {
+			gpi, ok := gpis[pinfo.Cid]
+			if !ok {
+				gpi = &api.GlobalPinInfo{
+					Cid:     pinfo.Cid,
+					PeerMap: make(map[string]*api.PinInfo),
+				}
+				gpis[pinfo.Cid] = gpi
+				changes.Changes = append(changes.Changes, gpi)
+			}
+			gpi.PeerMap[peer.IDB58Encode(pinfo.Peer)] = pinfo
 		}
 	}
-
-	return nil
-}
-
-// StatusAll returns the GlobalPinInfo for all tracked Cids in all peers.
-// If an error happens, the slice will contain as much information as
-// could be fetched from other peers.
-func (c *Cluster) StatusAll(ctx context.Context) ([]*api.GlobalPinInfo, error) {
-	_, span := trace.StartSpan(ctx, "cluster/StatusAll")
-	defer span.End()
-	ctx = trace.NewContext(c.ctx, span)
-
-	return c.globalPinInfoSlice(ctx, "PinTracker", "StatusAll")
+	return changes, nil
 }
 
 // StatusAllLocal returns the PinInfo for all the tracked Cids in this peer.
@@ -1234,26 +1838,102 @@ func (c *Cluster) Pin(ctx context.Context, h cid.Cid, opts api.PinOptions) (*api
 	ctx = trace.NewContext(c.ctx, span)
 	pin := api.PinWithOpts(h, opts)
 
-	result, _, err := c.pin(ctx, pin, []peer.ID{})
+	result, _, err := c.pin(ctx, pin, []peer.ID{}, false)
+	return result, err
+}
+
+// ForcePin works like Pin but it also allows re-pinning protected pins with
+// options that lift their protection (see checkProtectedRepin).
+func (c *Cluster) ForcePin(ctx context.Context, h cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ForcePin")
+	defer span.End()
+
+	ctx = trace.NewContext(c.ctx, span)
+	pin := api.PinWithOpts(h, opts)
+
+	result, _, err := c.pin(ctx, pin, []peer.ID{}, true)
 	return result, err
 }
 
+// checkProtectedRepin returns an error when the given pin would lift the
+// protection of an existing protected pin, either by not being protected or
+// by expiring earlier. Otherwise a re-pin would allow to remove protected
+// pins without forcing.
+func checkProtectedRepin(existing, pin *api.Pin) error {
+	if existing == nil || !existing.Protected {
+		return nil
+	}
+	if !pin.Protected {
+		return errPinProtected(pin.Cid)
+	}
+	if !pin.ExpireAt.IsZero() &&
+		(existing.ExpireAt.IsZero() || pin.ExpireAt.Before(existing.ExpireAt)) {
+		return errPinProtected(pin.Cid)
+	}
+	return nil
+}
+
 // sets the default replication factor in a pin when it's set to 0
 func (c *Cluster) setupReplicationFactor(pin *api.Pin) error {
 	rplMin := pin.ReplicationFactorMin
 	rplMax := pin.ReplicationFactorMax
 	if rplMin == 0 {
 		rplMin = c.config.ReplicationFactorMin
-		pin.ReplicationFactorMin = rplMin
 	}
 	if rplMax == 0 {
 		rplMax = c.config.ReplicationFactorMax
-		pin.ReplicationFactorMax = rplMax
 	}
+	pin.ReplicationFactorMin = rplMin
+	pin.ReplicationFactorMax = rplMax
 
 	return isReplicationFactorValid(rplMin, rplMax)
 }
 
+// setupPlacement resolves the placement policy and the peer group of a pin
+// into the constraints stored with it (AllocateBy, AllowedTags and
+// GroupPeers), so that any peer can re-allocate it even if its
+// configuration does not define them. When this peer does not know the
+// policy or the group, the constraints stored in the existing pin, or
+// already carried by the given one (i.e. when restoring pins), are kept.
+// Replication factors not set in the pin are taken from the policy.
+func (c *Cluster) setupPlacement(pin, existing *api.Pin) error {
+	if pin.Policy == "" {
+		pin.AllocateBy = ""
+		pin.AllowedTags = nil
+	} else if policy, ok := c.config.PlacementPolicies[pin.Policy]; ok {
+		if pin.ReplicationFactorMin == 0 {
+			pin.ReplicationFactorMin = policy.ReplicationFactorMin
+		}
+		if pin.ReplicationFactorMax == 0 {
+			pin.ReplicationFactorMax = policy.ReplicationFactorMax
+		}
+		pin.AllocateBy = policy.AllocateBy
+		pin.AllowedTags = policy.AllowedTags
+	} else if existing != nil && existing.Policy == pin.Policy {
+		if pin.ReplicationFactorMin == 0 {
+			pin.ReplicationFactorMin = existing.ReplicationFactorMin
+		}
+		if pin.ReplicationFactorMax == 0 {
+			pin.ReplicationFactorMax = existing.ReplicationFactorMax
+		}
+		pin.AllocateBy = existing.AllocateBy
+		pin.AllowedTags = existing.AllowedTags
+	} else if pin.AllocateBy == "" && len(pin.AllowedTags) == 0 {
+		return fmt.Errorf("unknown placement policy: %s", pin.Policy)
+	}
+
+	if pin.PeerGroup == "" {
+		pin.GroupPeers = nil
+	} else if peers, ok := c.config.PeerGroups[pin.PeerGroup]; ok {
+		pin.GroupPeers = peers
+	} else if existing != nil && existing.PeerGroup == pin.PeerGroup && len(existing.GroupPeers) > 0 {
+		pin.GroupPeers = existing.GroupPeers
+	} else if len(pin.GroupPeers) == 0 {
+		return fmt.Errorf("unknown peer group: %s", pin.PeerGroup)
+	}
+	return nil
+}
+
 // basic checks on the pin type to check it's well-formed.
 func checkPinType(pin *api.Pin) error {
 	switch pin.Type {
@@ -1293,27 +1973,33 @@ func checkPinType(pin *api.Pin) error {
 	return nil
 }
 
-// setupPin ensures that the Pin object is fit for pinning. We check
-// and set the replication factors and ensure that the pinType matches the
-// metadata consistently.
-func (c *Cluster) setupPin(ctx context.Context, pin *api.Pin) error {
+// setupPin ensures that the Pin object is fit for pinning. We resolve the
+// placement constraints, check and set the replication factors and ensure that the pinType matches the
+// metadata consistently. Unless forced, protected pins cannot be re-pinned
+// in a way that lifts their protection.
+func (c *Cluster) setupPin(ctx context.Context, pin *api.Pin, force bool) error {
 	ctx, span := trace.StartSpan(ctx, "cluster/setupPin")
 	defer span.End()
 
-	err := c.setupReplicationFactor(pin)
-	if err != nil {
+	existing, err := c.PinGet(ctx, pin.Cid)
+	if err != nil && err != state.ErrNotFound {
 		return err
 	}
 
-	if !pin.ExpireAt.IsZero() && pin.ExpireAt.Before(time.Now()) {
-		return errors.New("pin.ExpireAt set before current time")
+	err = c.setupPlacement(pin, existing)
+	if err != nil {
+		return err
 	}
 
-	existing, err := c.PinGet(ctx, pin.Cid)
-	if err != nil && err != state.ErrNotFound {
+	err = c.setupReplicationFactor(pin)
+	if err != nil {
 		return err
 	}
 
+	if !pin.ExpireAt.IsZero() && pin.ExpireAt.Before(time.Now()) {
+		return errors.New("pin.ExpireAt set before current time")
+	}
+
 	if existing != nil && existing.Type != pin.Type {
 		msg := "cannot repin CID with different tracking method, "
 		msg += "clear state with pin rm to proceed. "
@@ -1321,42 +2007,87 @@ func (c *Cluster) setupPin(ctx context.Context, pin *api.Pin) error {
 		return fmt.Errorf(msg, pin.Type, existing.Type)
 	}
 
+	if !force {
+		if err := checkProtectedRepin(existing, pin); err != nil {
+			return err
+		}
+	}
+
 	return checkPinType(pin)
 }
 
 // pin performs the actual pinning and supports a blacklist to be able to
 // evacuate a node and returns the pin object that it tried to pin, whether
 // the pin was submitted to the consensus layer or skipped (due to error or to
-// the fact that it was already valid) and error.
+// the fact that it was already valid) and error. Protected pins can only be
+// re-pinned without protection when force is set.
 //
 // This is the method called by the Cluster.Pin RPC endpoint.
 func (c *Cluster) pin(
 	ctx context.Context,
 	pin *api.Pin,
 	blacklist []peer.ID,
+	force bool,
 ) (*api.Pin, bool, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/pin")
 	defer span.End()
 
-	if c.config.FollowerMode {
-		return nil, false, errFollowerMode
+	if err := c.startWrite(); err != nil {
+		return pin, false, err
 	}
+	defer c.writesWg.Done()
 
 	if pin.Cid == cid.Undef {
 		return pin, false, errors.New("bad pin object")
 	}
 
+	// Local pins do not touch the shared state, so followers can
+	// make them too.
+	if pin.LocalPin {
+		return pin, false, c.pinLocal(ctx, pin)
+	}
+
+	if c.config.FollowerMode {
+		return nil, false, errFollowerMode
+	}
+
 	// Handle pin updates when the option is set
 	if update := pin.PinUpdate; update != cid.Undef && !update.Equals(pin.Cid) {
-		pin, err := c.PinUpdate(ctx, update, pin.Cid, pin.PinOptions)
+		pin, err := c.pinUpdate(ctx, update, pin.Cid, pin.PinOptions, force)
 		return pin, true, err
 	}
 
+	local, err := c.isLocalPin(ctx, pin.Cid)
+	if err != nil {
+		return pin, false, err
+	}
+	if local {
+		return pin, false, errPinnedLocally
+	}
+
 	// setup pin might produce some side-effects to our pin
-	err := c.setupPin(ctx, pin)
+	err = c.setupPin(ctx, pin, force)
+	if err != nil {
+		return pin, false, err
+	}
+
+	err = c.checkQuotas(ctx, pin)
+	if err != nil {
+		return pin, false, err
+	}
+
+	err = c.precheckSize(ctx, pin)
 	if err != nil {
 		return pin, false, err
 	}
+
+	// Re-allocations (with a blacklist) are not new load.
+	if len(blacklist) == 0 {
+		if err := c.admitPin(ctx, pin); err != nil {
+			return pin, false, err
+		}
+	}
+
 	if pin.Type == api.MetaType {
 		return pin, true, c.consensus.LogPin(ctx, pin)
 	}
@@ -1380,20 +2111,22 @@ func (c *Cluster) pin(
 	// allocate() will check which peers are currently allocated
 	// and try to respect them.
 	if len(pin.Allocations) == 0 {
-		allocs, err := c.allocate(
-			ctx,
-			pin.Cid,
-			pin.ReplicationFactorMin,
-			pin.ReplicationFactorMax,
-			blacklist,
-			pin.UserAllocations,
-		)
+		allocs, err := c.allocate(ctx, pin, blacklist)
 		if err != nil {
 			return pin, false, err
 		}
 		pin.Allocations = allocs
 	}
 
+	if pin.Degraded() {
+		logger.Warningf(
+			"%s is degraded: allocated to %d peers, wanted %d. It will be topped up when more peers are available",
+			pin.Cid,
+			len(pin.Allocations),
+			pin.ReplicationFactorMax,
+		)
+	}
+
 	if len(pin.Allocations) == 0 {
 		logger.Infof("pinning %s everywhere:", pin.Cid)
 	} else {
@@ -1409,21 +2142,52 @@ func (c *Cluster) pin(
 //
 // Unpin does not reflect the success or failure of underlying IPFS daemon
 // unpinning operations, which happen in async fashion.
+//
+// Protected pins cannot be unpinned with Unpin (see ForceUnpin).
 func (c *Cluster) Unpin(ctx context.Context, h cid.Cid) (*api.Pin, error) {
 	_, span := trace.StartSpan(ctx, "cluster/Unpin")
 	defer span.End()
 	ctx = trace.NewContext(c.ctx, span)
 
+	return c.unpin(ctx, h, false)
+}
+
+// ForceUnpin works like Unpin but it also unpins protected pins.
+func (c *Cluster) ForceUnpin(ctx context.Context, h cid.Cid) (*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ForceUnpin")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	return c.unpin(ctx, h, true)
+}
+
+// unpin removes a pin from the local pins or the shared state. Protected pins
+// are only removed when force is set.
+func (c *Cluster) unpin(ctx context.Context, h cid.Cid, force bool) (*api.Pin, error) {
+	if err := c.startWrite(); err != nil {
+		return nil, err
+	}
+	defer c.writesWg.Done()
+
+	pin, local, err := c.unpinLocal(ctx, h, force)
+	if local || err != nil {
+		return pin, err
+	}
+
 	if c.config.FollowerMode {
 		return nil, errFollowerMode
 	}
 
 	logger.Info("IPFS cluster unpinning:", h)
-	pin, err := c.PinGet(ctx, h)
+	pin, err = c.PinGet(ctx, h)
 	if err != nil {
 		return nil, err
 	}
 
+	if pin.Protected && !force {
+		return pin, errPinProtected(h)
+	}
+
 	switch pin.Type {
 	case api.DataType:
 		return pin, c.consensus.LogUnpin(ctx, pin)
@@ -1448,7 +2212,8 @@ func (c *Cluster) Unpin(ctx context.Context, h cid.Cid) (*api.Pin, error) {
 // unpinClusterDag unpins the clusterDAG metadata node and the shard metadata
 // nodes that it references.  It handles the case where multiple parents
 // reference the same metadata node, only unpinning those nodes without
-// existing references
+// existing references. When the references cannot be determined, no shards
+// are unpinned: they can be cleaned up later with ShardsGC().
 func (c *Cluster) unpinClusterDag(metaPin *api.Pin) error {
 	ctx, span := trace.StartSpan(c.ctx, "cluster/unpinClusterDag")
 	defer span.End()
@@ -1458,9 +2223,20 @@ func (c *Cluster) unpinClusterDag(metaPin *api.Pin) error {
 		return err
 	}
 
-	// TODO: FIXME: potentially unpinning shards which are referenced
-	// by other clusterDAGs.
+	refs, err := c.shardReferences(ctx, metaPin.Cid)
+	if err != nil {
+		logger.Warningf("not unpinning the shards of %s: %s", metaPin.Cid, err)
+		return nil
+	}
+
 	for _, ci := range cids {
+		if ci.Equals(metaPin.Cid) {
+			continue // unpinned by the caller
+		}
+		if refs[ci] > 0 {
+			logger.Debugf("%s is referenced by other pins: not unpinning", ci)
+			continue
+		}
 		err = c.consensus.LogUnpin(ctx, api.PinCid(ci))
 		if err != nil {
 			return err
@@ -1479,7 +2255,19 @@ func (c *Cluster) unpinClusterDag(metaPin *api.Pin) error {
 // IPFSConnector supports it - the default one does). This may offer
 // significant speed when pinning items which are similar to previously pinned
 // content.
+//
+// A protected "to" pin cannot be replaced by an update which lifts its
+// protection.
 func (c *Cluster) PinUpdate(ctx context.Context, from cid.Cid, to cid.Cid, opts api.PinOptions) (*api.Pin, error) {
+	return c.pinUpdate(ctx, from, to, opts, false)
+}
+
+func (c *Cluster) pinUpdate(ctx context.Context, from cid.Cid, to cid.Cid, opts api.PinOptions, force bool) (*api.Pin, error) {
+	if err := c.startWrite(); err != nil {
+		return nil, err
+	}
+	defer c.writesWg.Done()
+
 	existing, err := c.PinGet(ctx, from)
 	if err != nil { // including when the existing pin is not found
 		return nil, err
@@ -1491,12 +2279,34 @@ func (c *Cluster) PinUpdate(ctx context.Context, from cid.Cid, to cid.Cid, opts
 		return nil, errors.New("this pin type cannot be updated")
 	}
 
+	if existing.Namespace != opts.Namespace {
+		// Respond as if it did not exist, so that pins in other
+		// namespaces are not disclosed.
+		return nil, state.ErrNotFound
+	}
+
 	existing.Cid = to
 	existing.PinUpdate = from
+	existing.Size = 0
 	if opts.Name != "" {
 		existing.Name = opts.Name
 	}
 
+	if !force {
+		current, err := c.PinGet(ctx, to)
+		if err != nil && err != state.ErrNotFound {
+			return nil, err
+		}
+		if err := checkProtectedRepin(current, existing); err != nil {
+			return nil, err
+		}
+	}
+
+	err = c.checkQuotas(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+
 	return existing, c.consensus.LogPin(ctx, existing)
 }
 
@@ -1552,6 +2362,62 @@ func (c *Cluster) Version() string {
 	return version.Version.String()
 }
 
+// SetLogLevel changes the log level of the given logging facility in this
+// peer. The facility can also be one of LoggingFacilityAliases, or "*" to
+// change all of them.
+func (c *Cluster) SetLogLevel(ctx context.Context, facility, level string) error {
+	_, span := trace.StartSpan(ctx, "cluster/SetLogLevel")
+	defer span.End()
+
+	err := setLogLevel(facility, level)
+	if err != nil {
+		return err
+	}
+	logger.Infof("log level for %s set to %s", facility, level)
+	return nil
+}
+
+// Alerts returns the alerts received by this peer which are still
+// active. An alert stops being active once a valid metric with the same name
+// is received again from the affected peer.
+func (c *Cluster) Alerts(ctx context.Context) []*api.Alert {
+	_, span := trace.StartSpan(ctx, "cluster/Alerts")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	c.alertsMux.Lock()
+	defer c.alertsMux.Unlock()
+
+	latest := make(map[string]map[peer.ID]*api.Metric)
+	alerts := make([]*api.Alert, 0, len(c.alerts))
+	for k, alrt := range c.alerts {
+		metrics, ok := latest[alrt.MetricName]
+		if !ok {
+			metrics = make(map[peer.ID]*api.Metric)
+			for _, m := range c.monitor.LatestMetrics(ctx, alrt.MetricName) {
+				metrics[m.Peer] = m
+			}
+			latest[alrt.MetricName] = metrics
+		}
+
+		m, ok := metrics[alrt.Peer]
+		if ok && time.Unix(0, m.ReceivedAt).After(alrt.TriggeredAt) {
+			delete(c.alerts, k) // peer has recovered
+			continue
+		}
+		alerts = append(alerts, alrt)
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].TriggeredAt.Before(alerts[j].TriggeredAt)
+	})
+	return alerts
+}
+
+func alertKey(alrt *api.Alert) string {
+	return peer.IDB58Encode(alrt.Peer) + "/" + alrt.MetricName
+}
+
 // Peers returns the IDs of the members of this Cluster.
 func (c *Cluster) Peers(ctx context.Context) []*api.ID {
 	_, span := trace.StartSpan(ctx, "cluster/Peers")
@@ -1568,11 +2434,13 @@ func (c *Cluster) Peers(ctx context.Context) []*api.ID {
 
 	peers := make([]*api.ID, lenMembers, lenMembers)
 
-	ctxs, cancels := rpcutil.CtxsWithCancel(ctx, lenMembers)
-	defer rpcutil.MultiCancel(cancels)
+	rttCh := make(chan map[peer.ID]time.Duration, 1)
+	go func() {
+		rttCh <- c.pingPeers(ctx, members)
+	}()
 
-	errs := c.rpcClient.MultiCall(
-		ctxs,
+	errs := c.multiCall(
+		ctx,
 		members,
 		"Cluster",
 		"ID",
@@ -1582,6 +2450,7 @@ func (c *Cluster) Peers(ctx context.Context) []*api.ID {
 
 	finalPeers := []*api.ID{}
 
+	var names map[string]string
 	for i, err := range errs {
 		if err == nil {
 			finalPeers = append(finalPeers, peers[i])
@@ -1592,14 +2461,72 @@ func (c *Cluster) Peers(ctx context.Context) []*api.ID {
 			continue
 		}
 
+		if names == nil {
+			names = c.PeerNames(ctx)
+		}
 		peers[i] = &api.ID{}
 		peers[i].ID = members[i]
+		if name, ok := names[peer.IDB58Encode(members[i])]; ok {
+			peers[i].Peername = name
+		}
 		peers[i].Error = err.Error()
 	}
 
+	summaries := make(map[peer.ID]*api.PinStatusSummary)
+	for _, m := range c.monitor.LatestMetrics(ctx, pinStatusMetricName) {
+		summaries[m.Peer] = m.PinStatus
+	}
+	lastMetrics := make(map[peer.ID]int64)
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		lastMetrics[m.Peer] = m.ReceivedAt
+	}
+	rtts := <-rttCh
+	for _, p := range peers {
+		if p == nil {
+			continue
+		}
+		p.LastMetric = lastMetrics[p.ID]
+		p.RTT = rtts[p.ID]
+		if p.Error == "" {
+			p.PinStatus = summaries[p.ID]
+		}
+	}
 	return peers
 }
 
+// pingPeers measures the round-trip time to the given peers with the libp2p
+// ping protocol. Peers which do not answer within peersPingTimeout are left
+// out. This peer is not pinged.
+func (c *Cluster) pingPeers(ctx context.Context, peers []peer.ID) map[peer.ID]time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, peersPingTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	rtts := make(map[peer.ID]time.Duration, len(peers))
+	for _, p := range peers {
+		if p == c.id {
+			continue
+		}
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			pctx, pcancel := context.WithCancel(ctx)
+			defer pcancel()
+			res, ok := <-ping.Ping(pctx, c.host, p)
+			if !ok || res.Error != nil {
+				logger.Debugf("error pinging %s: %v", p, res.Error)
+				return
+			}
+			mu.Lock()
+			rtts[p] = res.RTT
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+	return rtts
+}
+
 // getTrustedPeers gives listed of trusted peers except the current peer.
 func (c *Cluster) getTrustedPeers(ctx context.Context) ([]peer.ID, error) {
 	peers, err := c.consensus.Peers(ctx)
@@ -1643,6 +2570,8 @@ func (c *Cluster) globalPinInfoCid(ctx context.Context, comp, method string, h c
 	var dests []peer.ID
 	// un-allocated peers, we will set remote status
 	var remote []peer.ID
+	// the pin, to summarize its status. Not known in follower mode.
+	var pin *api.Pin
 	timeNow := time.Now()
 
 	// set dests and remote
@@ -1658,7 +2587,7 @@ func (c *Cluster) globalPinInfoCid(ctx context.Context, comp, method string, h c
 		}
 
 		// If pin is not part of the pinset, mark it unpinned
-		pin, err := c.PinGet(ctx, h)
+		pin, err = c.PinGet(ctx, h)
 		if err == state.ErrNotFound {
 			setTrackerStatus(gpin, h, members, api.TrackerStatusUnpinned, timeNow)
 			return gpin, nil
@@ -1682,11 +2611,8 @@ func (c *Cluster) globalPinInfoCid(ctx context.Context, comp, method string, h c
 
 	lenDests := len(dests)
 	replies := make([]*api.PinInfo, lenDests, lenDests)
-	ctxs, cancels := rpcutil.CtxsWithCancel(ctx, lenDests)
-	defer rpcutil.MultiCancel(cancels)
-
-	errs := c.rpcClient.MultiCall(
-		ctxs,
+	errs := c.multiCall(
+		ctx,
 		dests,
 		comp,
 		method,
@@ -1694,6 +2620,7 @@ func (c *Cluster) globalPinInfoCid(ctx context.Context, comp, method string, h c
 		rpcutil.CopyPinInfoToIfaces(replies),
 	)
 
+	var names map[string]string
 	for i, r := range replies {
 		e := errs[i]
 
@@ -1710,16 +2637,22 @@ func (c *Cluster) globalPinInfoCid(ctx context.Context, comp, method string, h c
 
 		// Deal with error cases (err != nil): wrap errors in PinInfo
 		logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, dests[i], e)
+		if names == nil {
+			names = c.PeerNames(ctx)
+		}
 		gpin.PeerMap[peer.IDB58Encode(dests[i])] = &api.PinInfo{
 			Cid:      h,
 			Peer:     dests[i],
-			PeerName: dests[i].String(),
+			PeerName: peerName(names, dests[i]),
 			Status:   api.TrackerStatusClusterError,
 			TS:       timeNow,
 			Error:    e.Error(),
 		}
 	}
 
+	if pin != nil {
+		gpin.SetStatus(pin.ReplicationFactorMin)
+	}
 	return gpin, nil
 }
 
@@ -1743,21 +2676,10 @@ func (c *Cluster) globalPinInfoSlice(ctx context.Context, comp, method string) (
 	}
 	lenMembers := len(members)
 
-	replies := make([][]*api.PinInfo, lenMembers, lenMembers)
-
-	ctxs, cancels := rpcutil.CtxsWithCancel(ctx, lenMembers)
-	defer rpcutil.MultiCancel(cancels)
-
-	errs := c.rpcClient.MultiCall(
-		ctxs,
-		members,
-		comp,
-		method,
-		struct{}{},
-		rpcutil.CopyPinInfoSliceToIfaces(replies),
-	)
-
+	var mergeMux sync.Mutex
 	mergePins := func(pins []*api.PinInfo) {
+		mergeMux.Lock()
+		defer mergeMux.Unlock()
 		for _, p := range pins {
 			if p == nil {
 				continue
@@ -1776,29 +2698,71 @@ func (c *Cluster) globalPinInfoSlice(ctx context.Context, comp, method string) (
 		}
 	}
 
+	var errs []error
+	if comp == "PinTracker" && method == "StatusAll" && c.config.RPCPageSize > 0 {
+		// Obtain the status in pages and merge them as they arrive,
+		// rather than holding the full replies of all peers.
+		errs = c.broadcast(ctx, members, comp, method, func(ctx context.Context, i int) error {
+			return c.statusAllPaged(ctx, members[i], mergePins)
+		})
+	} else {
+		replies := make([][]*api.PinInfo, lenMembers, lenMembers)
+
+		errs = c.multiCall(
+			ctx,
+			members,
+			comp,
+			method,
+			struct{}{},
+			rpcutil.CopyPinInfoSliceToIfaces(replies),
+		)
+		for i, r := range replies {
+			if errs[i] == nil {
+				mergePins(r)
+			}
+		}
+	}
+
 	erroredPeers := make(map[peer.ID]string)
-	for i, r := range replies {
-		if e := errs[i]; e != nil { // This error must come from not being able to contact that cluster member
+	for i, e := range errs {
+		if e != nil { // This error must come from not being able to contact that cluster member
 			if rpc.IsAuthorizationError(e) {
 				logger.Debug("rpc auth error", e)
 				continue
 			}
 			logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, members[i], e)
 			erroredPeers[members[i]] = e.Error()
-		} else {
-			mergePins(r)
 		}
 	}
 
 	// Merge any errors
+	var names map[string]string
+	if len(erroredPeers) > 0 {
+		names = c.PeerNames(ctx)
+	}
 	for p, msg := range erroredPeers {
 		for c := range fullMap {
 			fullMap[c].PeerMap[peer.IDB58Encode(p)] = &api.PinInfo{
-				Cid:    c,
-				Peer:   p,
-				Status: api.TrackerStatusClusterError,
-				TS:     time.Now(),
-				Error:  msg,
+				Cid:      c,
+				Peer:     p,
+				PeerName: peerName(names, p),
+				Status:   api.TrackerStatusClusterError,
+				TS:       time.Now(),
+				Error:    msg,
+			}
+		}
+	}
+
+	// Summarize the status of the items in the pinset. Only the local
+	// status is known in follower mode.
+	if !c.config.FollowerMode {
+		rplMins, err := c.replicationFactorsMin(ctx)
+		if err != nil {
+			logger.Error(err)
+		}
+		for h, gpin := range fullMap {
+			if rplMin, ok := rplMins[h]; ok {
+				gpin.SetStatus(rplMin)
 			}
 		}
 	}
@@ -1810,10 +2774,31 @@ func (c *Cluster) globalPinInfoSlice(ctx context.Context, comp, method string) (
 	return infos, nil
 }
 
+// replicationFactorsMin returns the ReplicationFactorMin of every item in
+// the pinset.
+func (c *Cluster) replicationFactorsMin(ctx context.Context) (map[cid.Cid]int, error) {
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rplMins := make(map[cid.Cid]int, len(pins))
+	for _, pin := range pins {
+		rplMins[pin.Cid] = pin.ReplicationFactorMin
+	}
+	return rplMins, nil
+}
+
 func (c *Cluster) getIDForPeer(ctx context.Context, pid peer.ID) (*api.ID, error) {
 	ctx, span := trace.StartSpan(ctx, "cluster/getIDForPeer")
 	defer span.End()
 
+	ctx, cancel := c.rpcContext(ctx, "Cluster", "ID")
+	defer cancel()
+
 	var id api.ID
 	err := c.rpcClient.CallContext(
 		ctx,
@@ -1924,6 +2909,50 @@ func diffPeers(peers1, peers2 []peer.ID) (added, removed []peer.ID) {
 	return
 }
 
+// Prefetch asks the given peers (all peers when none are given) to fetch
+// the DAG under the given Cid into their IPFS repositories without pinning
+// it. It waits until all peers are done and returns a result for each of
+// them.
+func (c *Cluster) Prefetch(ctx context.Context, h cid.Cid, peers []peer.ID) ([]*api.PrefetchResult, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Prefetch")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if len(peers) == 0 {
+		members, err := c.consensus.Peers(ctx)
+		if err != nil {
+			logger.Error(err)
+			return nil, err
+		}
+		peers = members
+	}
+
+	logger.Infof("prefetching %s in %d peers", h, len(peers))
+	errs := c.multiCall(
+		ctx,
+		peers,
+		"IPFSConnector",
+		"Prefetch",
+		h,
+		rpcutil.RPCDiscardReplies(len(peers)),
+	)
+
+	names := c.PeerNames(ctx)
+	results := make([]*api.PrefetchResult, len(peers))
+	for i, p := range peers {
+		results[i] = &api.PrefetchResult{
+			Cid:      h,
+			Peer:     p,
+			PeerName: peerName(names, p),
+		}
+		if errs[i] != nil {
+			logger.Errorf("%s: error prefetching %s in %s: %s", c.id, h, p, errs[i])
+			results[i].Error = errs[i].Error()
+		}
+	}
+	return results, nil
+}
+
 // RepoGC performs garbage collection sweep on all peers' IPFS repo.
 func (c *Cluster) RepoGC(ctx context.Context) (*api.GlobalRepoGC, error) {
 	_, span := trace.StartSpan(ctx, "cluster/RepoGC")
@@ -1938,6 +2967,7 @@ func (c *Cluster) RepoGC(ctx context.Context) (*api.GlobalRepoGC, error) {
 
 	// to club `RepoGCLocal` responses of all peers into one
 	globalRepoGC := api.GlobalRepoGC{PeerMap: make(map[string]*api.RepoGC)}
+	var names map[string]string
 	for _, member := range members {
 		var repoGC api.RepoGC
 		err = c.rpcClient.CallContext(
@@ -1960,9 +2990,12 @@ func (c *Cluster) RepoGC(ctx context.Context) (*api.GlobalRepoGC, error) {
 
 		logger.Errorf("%s: error in broadcast response from %s: %s ", c.id, member, err)
 
+		if names == nil {
+			names = c.PeerNames(ctx)
+		}
 		globalRepoGC.PeerMap[peer.IDB58Encode(member)] = &api.RepoGC{
 			Peer:     member,
-			Peername: peer.IDB58Encode(member),
+			Peername: peerName(names, member),
 			Keys:     []api.IPFSRepoGC{},
 			Error:    err.Error(),
 		}
@@ -11,9 +11,12 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/allocator/util"
+	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/config"
 
 	ipfsconfig "github.com/ipfs/go-ipfs-config"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 	pnet "github.com/libp2p/go-libp2p-pnet"
 	ma "github.com/multiformats/go-multiaddr"
 
@@ -30,20 +33,46 @@ var DefaultListenAddrs = []string{
 
 // Configuration defaults
 const (
-	DefaultEnableRelayHop      = true
-	DefaultStateSyncInterval   = 5 * time.Minute
-	DefaultPinRecoverInterval  = 12 * time.Minute
-	DefaultMonitorPingInterval = 15 * time.Second
-	DefaultPeerWatchInterval   = 5 * time.Second
-	DefaultReplicationFactor   = -1
-	DefaultLeaveOnShutdown     = false
-	DefaultDisableRepinning    = false
-	DefaultPeerstoreFile       = "peerstore"
-	DefaultConnMgrHighWater    = 400
-	DefaultConnMgrLowWater     = 100
-	DefaultConnMgrGracePeriod  = 2 * time.Minute
-	DefaultFollowerMode        = false
-	DefaultMDNSInterval        = 10 * time.Second
+	DefaultEnableRelayHop       = true
+	DefaultStateSyncInterval    = 5 * time.Minute
+	DefaultPinRecoverInterval   = 12 * time.Minute
+	DefaultSyncJitter           = 30 * time.Second
+	DefaultMonitorPingInterval  = 15 * time.Second
+	DefaultPeerWatchInterval    = 5 * time.Second
+	DefaultReplicationFactor    = -1
+	DefaultLeaveOnShutdown      = false
+	DefaultDisableRepinning     = false
+	DefaultRepinDelay           = 0
+	DefaultRepinRateLimit       = 0
+	DefaultAdmissionMaxQueued   = 0
+	DefaultAdmissionMinFree     = 0
+	DefaultAdmissionWait        = 0
+	DefaultSizePrecheckTimeout  = 0
+	DefaultPeerstoreFile        = "peerstore"
+	DefaultConnMgrHighWater     = 400
+	DefaultConnMgrLowWater      = 100
+	DefaultConnMgrGracePeriod   = 2 * time.Minute
+	DefaultFollowerMode         = false
+	DefaultStandby              = false
+	DefaultMDNSInterval         = 10 * time.Second
+	DefaultKeepAliveInterval    = 0
+	DefaultShutdownDrainTimeout = 10 * time.Second
+	DefaultJobRetention         = 7 * 24 * time.Hour
+	DefaultRPCPageSize          = 5000
+	DefaultBroadcastConcurrency = 32
+	DefaultAllocator            = AllocatorDescend
+	DefaultAllocatorTieBreak    = util.TieBreakNone
+	DefaultIPNSPublishInterval  = 10 * time.Minute
+)
+
+// Allocators which can be selected with the "allocator" option.
+const (
+	// AllocatorDescend allocates to the peers with the highest metric
+	// values.
+	AllocatorDescend = "descend"
+	// AllocatorHRW allocates to peers chosen deterministically from the
+	// CID and the peer IDs (rendezvous hashing).
+	AllocatorHRW = "hrw"
 )
 
 // ConnMgrConfig configures the libp2p host connection manager.
@@ -53,6 +82,39 @@ type ConnMgrConfig struct {
 	GracePeriod time.Duration
 }
 
+// PlacementPolicy is a named set of placement options which pins can
+// reference by name (see api.PinOptions.Policy), so that applications do
+// not need to set them on every pin.
+type PlacementPolicy struct {
+	// Replication factors for pins using the policy. 0 means the
+	// cluster defaults are used. Factors set in the pin options take
+	// precedence.
+	ReplicationFactorMin int
+	ReplicationFactorMax int
+
+	// AllowedTags restricts allocations to peers whose tags match: for
+	// every tag listed, the value of the peer tag must be one of the
+	// given values.
+	AllowedTags map[string][]string
+
+	// AllocateBy is the name of the informer metric used to choose
+	// among candidate peers. Empty means the first configured informer.
+	AllocateBy string
+}
+
+// Quota limits the pins that can be added to a namespace (see
+// api.PinOptions.Namespace) or by a user (see api.PinOptions.Owner).
+// 0 means unlimited.
+type Quota struct {
+	// MaxPins is the maximum number of pins.
+	MaxPins int
+
+	// MaxBytes is the maximum cumulative size of the pins. The size of
+	// every new pin is obtained from IPFS before pinning it, and pins are
+	// rejected when it cannot be obtained.
+	MaxBytes uint64
+}
+
 // Config is the configuration object containing customizable variables to
 // initialize the main ipfs-cluster component. It implements the
 // config.ComponentConfig interface.
@@ -69,9 +131,45 @@ type Config struct {
 	// 64 characters and contain only hexadecimal characters (`[0-9a-f]`).
 	Secret []byte
 
-	// RPCPolicy defines access control to RPC endpoints.
+	// TransitionSecret is set while the cluster secret is being rotated
+	// to it. Connections from peers using it are accepted, while the
+	// peer keeps using Secret to connect to others.
+	TransitionSecret []byte
+
+	// protector is the private network protector created by
+	// NewClusterHost, which allows changing the secrets at runtime.
+	protector *secretProtector
+
+	// RPCPolicy defines access control to RPC endpoints. It is
+	// DefaultRPCPolicy with the changes from the "rpc_policy" JSON option
+	// applied. This allows, for example, that only trusted peers add new
+	// peers to collaborative clusters.
 	RPCPolicy map[string]RPCEndpointType
 
+	// rpcPolicyOverrides are the entries from the "rpc_policy" JSON
+	// option, kept to write them back when saving.
+	rpcPolicyOverrides map[string]string
+
+	// RPCTimeouts sets the maximum duration of calls to RPC endpoints,
+	// both when making them and when serving them. It is
+	// DefaultRPCTimeouts with the changes from the "rpc_timeouts" JSON
+	// option applied. A 0 timeout disables it.
+	RPCTimeouts map[string]time.Duration
+
+	// rpcTimeoutOverrides are the entries from the "rpc_timeouts" JSON
+	// option, kept to write them back when saving.
+	rpcTimeoutOverrides map[string]string
+
+	// RPCPageSize is the number of items in every reply when obtaining
+	// long listings (the status of all pins) from other peers, which are
+	// sent in several replies to avoid memory spikes.
+	RPCPageSize int
+
+	// BroadcastConcurrency is the maximum number of peers contacted at
+	// the same time when making the same request to all of them (i.e. to
+	// obtain the status of all pins).
+	BroadcastConcurrency int
+
 	// Leave Cluster on shutdown. Politely informs other peers
 	// of the departure and removes itself from the consensus
 	// peer set. The Cluster size will be reduced by one.
@@ -85,6 +183,19 @@ type Config struct {
 	// an intermediate (Hop Relay) node in relay circuits for connected peers.
 	EnableRelayHop bool
 
+	// AnnounceAddr, when set, replaces the addresses that this peer
+	// announces to others. Peers behind NAT with forwarded ports can use
+	// it to announce their public addresses.
+	AnnounceAddr []ma.Multiaddr
+
+	// DisableNATPortMap disables opening ports in the NAT router using
+	// UPnP or NAT-PMP.
+	DisableNATPortMap bool
+
+	// DisableAutoRelay disables announcing addresses through relays when
+	// this peer is found to be behind NAT.
+	DisableAutoRelay bool
+
 	// ConnMgr holds configuration values for the connection manager for
 	// the libp2p host.
 	// FIXME: This only applies to ipfs-cluster-service.
@@ -100,6 +211,13 @@ type Config struct {
 	// which will retry to pin/unpin items in error state.
 	PinRecoverInterval time.Duration
 
+	// SyncJitter is the maximum random delay added to every
+	// StateSyncInterval and PinRecoverInterval, so that peers do not
+	// sync at the same time. Besides, the first runs after starting are
+	// staggered over the intervals depending on the peer ID. 0 disables
+	// the random delay.
+	SyncJitter time.Duration
+
 	// ReplicationFactorMax indicates the target number of nodes
 	// that should pin content. For exampe, a replication_factor of
 	// 3 will have cluster allocate each pinned hash to 3 peers if
@@ -134,17 +252,165 @@ type Config struct {
 	// mDNS.
 	MDNSInterval time.Duration
 
+	// KeepAliveInterval is the time between pings to every cluster peer,
+	// which keep the connections to them from being idle and detect
+	// stale ones. Peers which are not connected or do not answer are
+	// re-connected right away, rather than when they are next needed,
+	// so that the first request after an idle period does not pay the
+	// dial latency or fail. 0 disables it.
+	KeepAliveInterval time.Duration
+
+	// DHTRendezvous is a name for this cluster used to find other peers
+	// on the DHT: peers advertise themselves under a key derived from it
+	// and connect to the peers advertising the same key. When the cluster
+	// does not use a secret, the DHT is the public IPFS DHT, which allows
+	// new peers to find the cluster without bootstrap addresses. Empty
+	// disables DHT discovery.
+	DHTRendezvous string
+
+	// IPNSPublishKey is the name of a key in the IPFS daemon under which
+	// this peer publishes a DAG listing the cluster pinset every
+	// IPNSPublishInterval, so that it can be followed and audited
+	// without access to the cluster. The key is created when it does
+	// not exist. Empty disables publishing.
+	IPNSPublishKey string
+
+	// IPNSPublishInterval is the time between publications of the
+	// pinset when IPNSPublishKey is set.
+	IPNSPublishInterval time.Duration
+
+	// IPNSRecordLifetime is how long the published IPNS records are
+	// valid. 0 uses the default of the IPFS daemon.
+	IPNSRecordLifetime time.Duration
+
 	// If true, DisableRepinning, ensures that no repinning happens
-	// when a node goes down.
-	// This is useful when doing certain types of maintenance, or simply
+	// when a node goes down or is removed, and that degraded pins are not
+	// topped up automatically. Re-replication is then left to the
+	// operator.
+	// This is useful when doing certain types of maintenance, in
+	// collaborative clusters where peers come and go often, or simply
 	// when not wanting to rely on the monitoring system which needs a revamp.
 	DisableRepinning bool
 
+	// RepinDelay is how long a peer must be down before the pins
+	// allocated to it are re-allocated to other peers. 0 re-allocates
+	// them as soon as the peer is detected to be down. Pins are always
+	// re-allocated right away when a peer is removed.
+	RepinDelay time.Duration
+
+	// RepinRateLimit is the maximum number of pins re-allocated per
+	// minute when peers go down or are removed, so that the cluster is
+	// not overloaded by re-allocations. 0 means no limit.
+	RepinRateLimit int
+
+	// AdmissionMaxQueued is the number of pin and unpin operations
+	// pending in the cluster (the sum of those reported by every peer
+	// with its ping metrics) from which new pins are not admitted, to
+	// protect the cluster from ingestion overload. 0 disables the check.
+	AdmissionMaxQueued int
+
+	// AdmissionMinFreeSpace is the free space, in bytes, that must
+	// remain in the cluster (the sum of the "freespace" metrics) once
+	// the replicas of a new pin of known size are stored. New pins are
+	// not admitted below it. 0 disables the check.
+	AdmissionMinFreeSpace uint64
+
+	// AdmissionWait is how long new pins wait to be admitted before
+	// being rejected when the cluster is overloaded. 0 rejects them
+	// right away. Rejected pins tell clients when to retry.
+	AdmissionWait time.Duration
+
+	// SizePrecheckTimeout enables resolving the size of new pins before
+	// allocating them, waiting for IPFS up to this long. Peers without
+	// enough free space for a pin of known size are not allocated, and
+	// pins larger than the free space of every peer are rejected. 0
+	// disables it and pins are allocated without knowing their size.
+	SizePrecheckTimeout time.Duration
+
+	// Allocator selects how peers are chosen among the candidates when
+	// allocating pins: AllocatorDescend (by metric value) or AllocatorHRW
+	// (deterministically from the CID and the peer IDs, so that
+	// identical clusters allocate identically and membership changes
+	// move few pins).
+	Allocator string
+
+	// AllocatorTieBreak selects how the descend allocator orders peers
+	// with equal metric values: "none" (in no particular order, which
+	// may favour the same peers), "peer_hash" (by a hash of the CID and
+	// the peer ID) or "round_robin" (in turns, with a counter persisted
+	// in the local datastore). See the allocator/util package.
+	AllocatorTieBreak string
+
 	// FollowerMode disables broadcast requests from this peer
 	// (sync, recover, status) and disallows pinset management
 	// operations (Pin/Unpin).
 	FollowerMode bool
 
+	// Standby starts the peer in standby mode: it takes part in the
+	// consensus and is monitored like any other peer, but receives no
+	// allocations until it is activated (see Cluster.PeerActivate). The
+	// activation is persisted, so the peer stays active after
+	// restarting.
+	Standby bool
+
+	// ShutdownDrainTimeout is the maximum time that a peer waits, when
+	// shutting down, for ongoing pinset operations to be committed and
+	// for queued and in-progress pin/unpin operations to finish. New
+	// Pin/Unpin requests are rejected meanwhile. Operations which do not
+	// finish in time are retried when the peer starts again. Set to 0
+	// to shutdown without waiting.
+	ShutdownDrainTimeout time.Duration
+
+	// JobRetention is how long finished jobs (see Cluster.SubmitJob) are
+	// kept in the job list before they are removed. 0 keeps them
+	// forever.
+	JobRetention time.Duration
+
+	// Tags are key-value labels for this peer (i.e. "region": "eu"),
+	// sent to other peers along with all its metrics and included in the
+	// alerts about them. Placement policies can restrict allocations to
+	// peers with certain tags. They are also set as labels on the
+	// Prometheus metrics of the peer.
+	Tags map[string]string
+
+	// StorageClass is the kind of storage of this peer (i.e. "ssd",
+	// "hdd", "archive"), sent to other peers along with its ping
+	// metrics. Pins requesting a storage class are only allocated to
+	// peers of that class.
+	StorageClass string
+
+	// PlacementPolicies are named sets of placement options that pins
+	// can use.
+	PlacementPolicies map[string]*PlacementPolicy
+
+	// PeerGroups are named groups of peers (i.e. "hot", "archive"). Pins
+	// requesting a group are only allocated to peers in it. Groups
+	// should be the same in every peer.
+	PeerGroups map[string][]peer.ID
+
+	// AllocationExclusions lists peers, by ID or by tag, which never
+	// receive new allocations from this peer, i.e. hardware which is
+	// being decommissioned. They can be extended at runtime with
+	// Cluster.SetAllocationExclusions.
+	AllocationExclusions *api.AllocationExclusions
+
+	// ProvideStrategy decides which IPFS daemons of the cluster announce
+	// content to the DHT and which content they announce. Every peer
+	// sets the reprovider options of its IPFS daemon accordingly when
+	// starting. It can be replaced at runtime with
+	// Cluster.SetProvideStrategy. Nil leaves the IPFS daemons as they
+	// are.
+	ProvideStrategy *api.ProvideStrategy
+
+	// NamespaceQuotas sets quotas for pin namespaces, by namespace name.
+	// Namespaces without a quota are not limited.
+	NamespaceQuotas map[string]*Quota
+
+	// UserQuotas sets quotas for the pins made by the users of the APIs
+	// (see api.PinOptions.Owner), by username. Users without a quota are
+	// not limited.
+	UserQuotas map[string]*Quota
+
 	// Peerstore file specifies the file on which we persist the
 	// libp2p host peerstore addresses. This file is regularly saved.
 	PeerstoreFile string
@@ -162,25 +428,85 @@ type Config struct {
 // saved using JSON. Most configuration keys are converted into simple types
 // like strings, and key names aim to be self-explanatory for the user.
 type configJSON struct {
-	ID                   string             `json:"id,omitempty"`
-	Peername             string             `json:"peername"`
-	PrivateKey           string             `json:"private_key,omitempty"`
-	Secret               string             `json:"secret"`
-	LeaveOnShutdown      bool               `json:"leave_on_shutdown"`
-	ListenMultiaddress   ipfsconfig.Strings `json:"listen_multiaddress"`
-	EnableRelayHop       bool               `json:"enable_relay_hop"`
-	ConnectionManager    *connMgrConfigJSON `json:"connection_manager"`
-	StateSyncInterval    string             `json:"state_sync_interval"`
-	PinRecoverInterval   string             `json:"pin_recover_interval"`
-	ReplicationFactorMin int                `json:"replication_factor_min"`
-	ReplicationFactorMax int                `json:"replication_factor_max"`
-	MonitorPingInterval  string             `json:"monitor_ping_interval"`
-	PeerWatchInterval    string             `json:"peer_watch_interval"`
-	MDNSInterval         string             `json:"mdns_interval"`
-	DisableRepinning     bool               `json:"disable_repinning"`
-	FollowerMode         bool               `json:"follower_mode,omitempty"`
-	PeerstoreFile        string             `json:"peerstore_file,omitempty"`
-	PeerAddresses        []string           `json:"peer_addresses"`
+	ID                   string                          `json:"id,omitempty"`
+	Peername             string                          `json:"peername"`
+	PrivateKey           string                          `json:"private_key,omitempty"`
+	Secret               string                          `json:"secret"`
+	TransitionSecret     string                          `json:"transition_secret,omitempty"`
+	LeaveOnShutdown      bool                            `json:"leave_on_shutdown"`
+	ListenMultiaddress   ipfsconfig.Strings              `json:"listen_multiaddress"`
+	EnableRelayHop       bool                            `json:"enable_relay_hop"`
+	AnnounceMultiaddress ipfsconfig.Strings              `json:"announce_multiaddress,omitempty"`
+	DisableNATPortMap    bool                            `json:"disable_nat_port_map,omitempty"`
+	DisableAutoRelay     bool                            `json:"disable_auto_relay,omitempty"`
+	ConnectionManager    *connMgrConfigJSON              `json:"connection_manager"`
+	StateSyncInterval    string                          `json:"state_sync_interval"`
+	PinRecoverInterval   string                          `json:"pin_recover_interval"`
+	SyncJitter           string                          `json:"sync_jitter"`
+	ReplicationFactorMin int                             `json:"replication_factor_min"`
+	ReplicationFactorMax int                             `json:"replication_factor_max"`
+	MonitorPingInterval  string                          `json:"monitor_ping_interval"`
+	PeerWatchInterval    string                          `json:"peer_watch_interval"`
+	MDNSInterval         string                          `json:"mdns_interval"`
+	KeepAliveInterval    string                          `json:"keep_alive_interval,omitempty"`
+	DHTRendezvous        string                          `json:"dht_rendezvous,omitempty"`
+	IPNSPublishKey       string                          `json:"ipns_publish_key,omitempty"`
+	IPNSPublishInterval  string                          `json:"ipns_publish_interval,omitempty"`
+	IPNSRecordLifetime   string                          `json:"ipns_record_lifetime,omitempty"`
+	DisableRepinning     bool                            `json:"disable_repinning"`
+	RepinDelay           string                          `json:"repin_delay"`
+	RepinRateLimit       int                             `json:"repin_rate_limit"`
+	AdmissionMaxQueued   int                             `json:"admission_max_queued,omitempty"`
+	AdmissionMinFree     uint64                          `json:"admission_min_free_space,omitempty"`
+	AdmissionWait        string                          `json:"admission_wait,omitempty"`
+	SizePrecheckTimeout  string                          `json:"size_precheck_timeout,omitempty"`
+	Allocator            string                          `json:"allocator,omitempty"`
+	AllocatorTieBreak    string                          `json:"allocator_tie_break,omitempty"`
+	FollowerMode         bool                            `json:"follower_mode,omitempty"`
+	Standby              bool                            `json:"standby,omitempty"`
+	ShutdownDrainTimeout string                          `json:"shutdown_drain_timeout"`
+	JobRetention         string                          `json:"job_retention"`
+	Tags                 map[string]string               `json:"tags,omitempty"`
+	StorageClass         string                          `json:"storage_class,omitempty"`
+	PlacementPolicies    map[string]*placementPolicyJSON `json:"placement_policies,omitempty"`
+	PeerGroups           map[string][]string             `json:"peer_groups,omitempty"`
+	AllocationExclusions *allocationExclusionsJSON       `json:"allocation_exclusions,omitempty"`
+	ProvideStrategy      *provideStrategyJSON            `json:"provide_strategy,omitempty"`
+	NamespaceQuotas      map[string]*quotaJSON           `json:"namespace_quotas,omitempty"`
+	UserQuotas           map[string]*quotaJSON           `json:"user_quotas,omitempty"`
+	PeerstoreFile        string                          `json:"peerstore_file,omitempty"`
+	PeerAddresses        []string                        `json:"peer_addresses"`
+	RPCPolicy            map[string]string               `json:"rpc_policy,omitempty"`
+	RPCTimeouts          map[string]string               `json:"rpc_timeouts,omitempty"`
+	RPCPageSize          int                             `json:"rpc_page_size"`
+	BroadcastConcurrency int                             `json:"broadcast_concurrency"`
+}
+
+// placementPolicyJSON represents a PlacementPolicy in the configuration.
+type placementPolicyJSON struct {
+	ReplicationFactorMin int                 `json:"replication_factor_min,omitempty"`
+	ReplicationFactorMax int                 `json:"replication_factor_max,omitempty"`
+	AllowedTags          map[string][]string `json:"allowed_tags,omitempty"`
+	AllocateBy           string              `json:"allocate_by,omitempty"`
+}
+
+// allocationExclusionsJSON represents the AllocationExclusions in the
+// configuration.
+type allocationExclusionsJSON struct {
+	Peers []string            `json:"peers,omitempty"`
+	Tags  map[string][]string `json:"tags,omitempty"`
+}
+
+// provideStrategyJSON represents the ProvideStrategy in the configuration.
+type provideStrategyJSON struct {
+	Strategy string   `json:"strategy"`
+	Peers    []string `json:"peers,omitempty"`
+}
+
+// quotaJSON represents a Quota in the configuration.
+type quotaJSON struct {
+	MaxPins  int    `json:"max_pins,omitempty"`
+	MaxBytes uint64 `json:"max_bytes,omitempty"`
 }
 
 // connMgrConfigJSON configures the libp2p host connection manager.
@@ -264,6 +590,10 @@ func (cfg *Config) Validate() error {
 		return errors.New("cluster.pin_recover_interval is invalid")
 	}
 
+	if cfg.SyncJitter < 0 {
+		return errors.New("cluster.sync_jitter is invalid")
+	}
+
 	if cfg.MonitorPingInterval <= 0 {
 		return errors.New("cluster.monitoring_interval is invalid")
 	}
@@ -272,6 +602,67 @@ func (cfg *Config) Validate() error {
 		return errors.New("cluster.peer_watch_interval is invalid")
 	}
 
+	if cfg.ShutdownDrainTimeout < 0 {
+		return errors.New("cluster.shutdown_drain_timeout is invalid")
+	}
+
+	if cfg.JobRetention < 0 {
+		return errors.New("cluster.job_retention is invalid")
+	}
+
+	if cfg.RepinDelay < 0 {
+		return errors.New("cluster.repin_delay is invalid")
+	}
+
+	if cfg.RepinRateLimit < 0 {
+		return errors.New("cluster.repin_rate_limit is invalid")
+	}
+
+	if cfg.AdmissionMaxQueued < 0 {
+		return errors.New("cluster.admission_max_queued is invalid")
+	}
+
+	if cfg.AdmissionWait < 0 {
+		return errors.New("cluster.admission_wait is invalid")
+	}
+
+	if cfg.SizePrecheckTimeout < 0 {
+		return errors.New("cluster.size_precheck_timeout is invalid")
+	}
+
+	if cfg.KeepAliveInterval < 0 {
+		return errors.New("cluster.keep_alive_interval is invalid")
+	}
+
+	if cfg.IPNSPublishKey != "" && cfg.IPNSPublishInterval <= 0 {
+		return errors.New("cluster.ipns_publish_interval is invalid")
+	}
+
+	if cfg.IPNSRecordLifetime < 0 {
+		return errors.New("cluster.ipns_record_lifetime is invalid")
+	}
+
+	switch cfg.Allocator {
+	case "", AllocatorDescend, AllocatorHRW:
+	default:
+		return fmt.Errorf("cluster.allocator must be %q or %q", AllocatorDescend, AllocatorHRW)
+	}
+
+	switch cfg.AllocatorTieBreak {
+	case "", util.TieBreakNone, util.TieBreakPeerHash, util.TieBreakRoundRobin:
+	default:
+		return fmt.Errorf(
+			"cluster.allocator_tie_break must be %q, %q or %q",
+			util.TieBreakNone,
+			util.TieBreakPeerHash,
+			util.TieBreakRoundRobin,
+		)
+	}
+
+	if len(cfg.TransitionSecret) > 0 && len(cfg.Secret) == 0 {
+		return errors.New("cluster.transition_secret needs cluster.secret to be set")
+	}
+
 	rfMax := cfg.ReplicationFactorMax
 	rfMin := cfg.ReplicationFactorMin
 
@@ -279,9 +670,77 @@ func (cfg *Config) Validate() error {
 		return err
 	}
 
+	for name, p := range cfg.PlacementPolicies {
+		if name == "" {
+			return errors.New("cluster.placement_policies: policies need a name")
+		}
+		if p.ReplicationFactorMin == 0 && p.ReplicationFactorMax == 0 {
+			continue
+		}
+		if err := isReplicationFactorValid(p.ReplicationFactorMin, p.ReplicationFactorMax); err != nil {
+			return fmt.Errorf("cluster.placement_policies.%s: %s", name, err)
+		}
+	}
+
+	for name, peers := range cfg.PeerGroups {
+		if name == "" {
+			return errors.New("cluster.peer_groups: groups need a name")
+		}
+		if len(peers) == 0 {
+			return fmt.Errorf("cluster.peer_groups.%s: groups need peers", name)
+		}
+	}
+
+	if ex := cfg.AllocationExclusions; ex != nil {
+		for k := range ex.Tags {
+			if k == "" {
+				return errors.New("cluster.allocation_exclusions: tags need a key")
+			}
+		}
+	}
+
+	if ps := cfg.ProvideStrategy; ps != nil {
+		if err := ps.Validate(); err != nil {
+			return fmt.Errorf("cluster.provide_strategy: %s", err)
+		}
+	}
+
+	if err := areQuotasValid("namespace_quotas", cfg.NamespaceQuotas); err != nil {
+		return err
+	}
+	if err := areQuotasValid("user_quotas", cfg.UserQuotas); err != nil {
+		return err
+	}
+
+	if cfg.RPCPageSize <= 0 {
+		return errors.New("cluster.rpc_page_size is invalid")
+	}
+
+	if cfg.BroadcastConcurrency <= 0 {
+		return errors.New("cluster.broadcast_concurrency is invalid")
+	}
+
+	for endpoint, t := range cfg.RPCTimeouts {
+		if t < 0 {
+			return fmt.Errorf("cluster.rpc_timeouts: %s: timeout is invalid", endpoint)
+		}
+	}
+
 	return isRPCPolicyValid(cfg.RPCPolicy)
 }
 
+func areQuotasValid(key string, quotas map[string]*Quota) error {
+	for name, q := range quotas {
+		if name == "" {
+			return fmt.Errorf("cluster.%s: quotas need a name", key)
+		}
+		if q.MaxPins < 0 {
+			return fmt.Errorf("cluster.%s.%s: max_pins is invalid", key, name)
+		}
+	}
+	return nil
+}
+
 func isReplicationFactorValid(rplMin, rplMax int) error {
 	// check Max and Min are correct
 	if rplMin == 0 || rplMax == 0 {
@@ -357,16 +816,51 @@ func (cfg *Config) setDefaults() {
 	cfg.LeaveOnShutdown = DefaultLeaveOnShutdown
 	cfg.StateSyncInterval = DefaultStateSyncInterval
 	cfg.PinRecoverInterval = DefaultPinRecoverInterval
+	cfg.SyncJitter = DefaultSyncJitter
 	cfg.ReplicationFactorMin = DefaultReplicationFactor
 	cfg.ReplicationFactorMax = DefaultReplicationFactor
 	cfg.MonitorPingInterval = DefaultMonitorPingInterval
 	cfg.PeerWatchInterval = DefaultPeerWatchInterval
 	cfg.MDNSInterval = DefaultMDNSInterval
+	cfg.KeepAliveInterval = DefaultKeepAliveInterval
 	cfg.DisableRepinning = DefaultDisableRepinning
+	cfg.RepinDelay = DefaultRepinDelay
+	cfg.RepinRateLimit = DefaultRepinRateLimit
+	cfg.AdmissionMaxQueued = DefaultAdmissionMaxQueued
+	cfg.AdmissionMinFreeSpace = DefaultAdmissionMinFree
+	cfg.AdmissionWait = DefaultAdmissionWait
+	cfg.SizePrecheckTimeout = DefaultSizePrecheckTimeout
+	cfg.Allocator = DefaultAllocator
+	cfg.AllocatorTieBreak = DefaultAllocatorTieBreak
+	cfg.IPNSPublishKey = ""
+	cfg.IPNSPublishInterval = DefaultIPNSPublishInterval
+	cfg.IPNSRecordLifetime = 0
 	cfg.FollowerMode = DefaultFollowerMode
+	cfg.Standby = DefaultStandby
+	cfg.ShutdownDrainTimeout = DefaultShutdownDrainTimeout
+	cfg.JobRetention = DefaultJobRetention
+	cfg.Tags = nil
+	cfg.StorageClass = ""
+	cfg.PlacementPolicies = nil
+	cfg.AllocationExclusions = nil
+	cfg.PeerGroups = nil
+	cfg.ProvideStrategy = nil
+	cfg.NamespaceQuotas = nil
+	cfg.UserQuotas = nil
 	cfg.PeerstoreFile = "" // empty so it gets omitted.
 	cfg.PeerAddresses = []ma.Multiaddr{}
-	cfg.RPCPolicy = DefaultRPCPolicy
+	cfg.RPCPolicy = make(map[string]RPCEndpointType, len(DefaultRPCPolicy))
+	for endpoint, t := range DefaultRPCPolicy {
+		cfg.RPCPolicy[endpoint] = t
+	}
+	cfg.rpcPolicyOverrides = nil
+	cfg.RPCTimeouts = make(map[string]time.Duration, len(DefaultRPCTimeouts))
+	for endpoint, t := range DefaultRPCTimeouts {
+		cfg.RPCTimeouts[endpoint] = t
+	}
+	cfg.rpcTimeoutOverrides = nil
+	cfg.RPCPageSize = DefaultRPCPageSize
+	cfg.BroadcastConcurrency = DefaultBroadcastConcurrency
 }
 
 // LoadJSON receives a raw json-formatted configuration and
@@ -397,6 +891,16 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 	}
 	cfg.Secret = clusterSecret
 
+	cfg.TransitionSecret = nil
+	if jcfg.TransitionSecret != "" {
+		transitionSecret, err := DecodeClusterSecret(jcfg.TransitionSecret)
+		if err != nil {
+			err = fmt.Errorf("error loading transition secret from config: %s", err)
+			return err
+		}
+		cfg.TransitionSecret = transitionSecret
+	}
+
 	var listenAddrs []ma.Multiaddr
 	for _, addr := range jcfg.ListenMultiaddress {
 		listenAddr, err := ma.NewMultiaddr(addr)
@@ -409,6 +913,18 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 
 	cfg.ListenAddr = listenAddrs
 	cfg.EnableRelayHop = jcfg.EnableRelayHop
+
+	cfg.AnnounceAddr = nil
+	for _, addr := range jcfg.AnnounceMultiaddress {
+		announceAddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			err = fmt.Errorf("error parsing an announce_multiaddress: %s", err)
+			return err
+		}
+		cfg.AnnounceAddr = append(cfg.AnnounceAddr, announceAddr)
+	}
+	cfg.DisableNATPortMap = jcfg.DisableNATPortMap
+	cfg.DisableAutoRelay = jcfg.DisableAutoRelay
 	if conman := jcfg.ConnectionManager; conman != nil {
 		cfg.ConnMgr = ConnMgrConfig{
 			HighWater: jcfg.ConnectionManager.HighWater,
@@ -430,9 +946,18 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 	err = config.ParseDurations("cluster",
 		&config.DurationOpt{Duration: jcfg.StateSyncInterval, Dst: &cfg.StateSyncInterval, Name: "state_sync_interval"},
 		&config.DurationOpt{Duration: jcfg.PinRecoverInterval, Dst: &cfg.PinRecoverInterval, Name: "pin_recover_interval"},
+		&config.DurationOpt{Duration: jcfg.SyncJitter, Dst: &cfg.SyncJitter, Name: "sync_jitter"},
 		&config.DurationOpt{Duration: jcfg.MonitorPingInterval, Dst: &cfg.MonitorPingInterval, Name: "monitor_ping_interval"},
 		&config.DurationOpt{Duration: jcfg.PeerWatchInterval, Dst: &cfg.PeerWatchInterval, Name: "peer_watch_interval"},
 		&config.DurationOpt{Duration: jcfg.MDNSInterval, Dst: &cfg.MDNSInterval, Name: "mdns_interval"},
+		&config.DurationOpt{Duration: jcfg.KeepAliveInterval, Dst: &cfg.KeepAliveInterval, Name: "keep_alive_interval"},
+		&config.DurationOpt{Duration: jcfg.ShutdownDrainTimeout, Dst: &cfg.ShutdownDrainTimeout, Name: "shutdown_drain_timeout"},
+		&config.DurationOpt{Duration: jcfg.JobRetention, Dst: &cfg.JobRetention, Name: "job_retention"},
+		&config.DurationOpt{Duration: jcfg.RepinDelay, Dst: &cfg.RepinDelay, Name: "repin_delay"},
+		&config.DurationOpt{Duration: jcfg.AdmissionWait, Dst: &cfg.AdmissionWait, Name: "admission_wait"},
+		&config.DurationOpt{Duration: jcfg.SizePrecheckTimeout, Dst: &cfg.SizePrecheckTimeout, Name: "size_precheck_timeout"},
+		&config.DurationOpt{Duration: jcfg.IPNSPublishInterval, Dst: &cfg.IPNSPublishInterval, Name: "ipns_publish_interval"},
+		&config.DurationOpt{Duration: jcfg.IPNSRecordLifetime, Dst: &cfg.IPNSRecordLifetime, Name: "ipns_record_lifetime"},
 	)
 	if err != nil {
 		return err
@@ -448,9 +973,103 @@ func (cfg *Config) applyConfigJSON(jcfg *configJSON) error {
 		cfg.PeerAddresses = append(cfg.PeerAddresses, peerAddr)
 	}
 
+	cfg.DHTRendezvous = jcfg.DHTRendezvous
+	cfg.IPNSPublishKey = jcfg.IPNSPublishKey
 	cfg.LeaveOnShutdown = jcfg.LeaveOnShutdown
+	cfg.Tags = jcfg.Tags
+	cfg.StorageClass = jcfg.StorageClass
+	if len(jcfg.PlacementPolicies) > 0 {
+		cfg.PlacementPolicies = make(map[string]*PlacementPolicy, len(jcfg.PlacementPolicies))
+	}
+	for name, p := range jcfg.PlacementPolicies {
+		if p == nil {
+			continue
+		}
+		cfg.PlacementPolicies[name] = &PlacementPolicy{
+			ReplicationFactorMin: p.ReplicationFactorMin,
+			ReplicationFactorMax: p.ReplicationFactorMax,
+			AllowedTags:          p.AllowedTags,
+			AllocateBy:           p.AllocateBy,
+		}
+	}
+	if len(jcfg.PeerGroups) > 0 {
+		cfg.PeerGroups = make(map[string][]peer.ID, len(jcfg.PeerGroups))
+	}
+	for name, peers := range jcfg.PeerGroups {
+		pids := make([]peer.ID, 0, len(peers))
+		for _, p := range peers {
+			pid, err := peer.IDB58Decode(p)
+			if err != nil {
+				return fmt.Errorf("error parsing peer_groups.%s: %s", name, err)
+			}
+			pids = append(pids, pid)
+		}
+		cfg.PeerGroups[name] = pids
+	}
+	if ex := jcfg.AllocationExclusions; ex != nil {
+		cfg.AllocationExclusions = &api.AllocationExclusions{Tags: ex.Tags}
+		for _, p := range ex.Peers {
+			pid, err := peer.IDB58Decode(p)
+			if err != nil {
+				return fmt.Errorf("error parsing allocation_exclusions: %s", err)
+			}
+			cfg.AllocationExclusions.Peers = append(cfg.AllocationExclusions.Peers, pid)
+		}
+	}
+	// envconfig allocates the section when it is missing: an empty one
+	// leaves the strategy unset.
+	if ps := jcfg.ProvideStrategy; ps != nil && (ps.Strategy != "" || len(ps.Peers) > 0) {
+		cfg.ProvideStrategy = &api.ProvideStrategy{Strategy: ps.Strategy}
+		for _, p := range ps.Peers {
+			pid, err := peer.IDB58Decode(p)
+			if err != nil {
+				return fmt.Errorf("error parsing provide_strategy: %s", err)
+			}
+			cfg.ProvideStrategy.Peers = append(cfg.ProvideStrategy.Peers, pid)
+		}
+	}
+	cfg.NamespaceQuotas = quotasFromJSON(jcfg.NamespaceQuotas)
+	cfg.UserQuotas = quotasFromJSON(jcfg.UserQuotas)
 	cfg.DisableRepinning = jcfg.DisableRepinning
+	cfg.RepinRateLimit = jcfg.RepinRateLimit
+	cfg.AdmissionMaxQueued = jcfg.AdmissionMaxQueued
+	cfg.AdmissionMinFreeSpace = jcfg.AdmissionMinFree
+	config.SetIfNotDefault(jcfg.Allocator, &cfg.Allocator)
+	config.SetIfNotDefault(jcfg.AllocatorTieBreak, &cfg.AllocatorTieBreak)
 	cfg.FollowerMode = jcfg.FollowerMode
+	cfg.Standby = jcfg.Standby
+	config.SetIfNotDefault(jcfg.RPCPageSize, &cfg.RPCPageSize)
+	config.SetIfNotDefault(jcfg.BroadcastConcurrency, &cfg.BroadcastConcurrency)
+
+	if len(jcfg.RPCPolicy) > 0 {
+		cfg.rpcPolicyOverrides = make(map[string]string, len(jcfg.RPCPolicy))
+	}
+	for endpoint, t := range jcfg.RPCPolicy {
+		if _, ok := DefaultRPCPolicy[endpoint]; !ok {
+			return fmt.Errorf("cluster.rpc_policy: unknown RPC endpoint %s", endpoint)
+		}
+		endpointType, err := parseRPCEndpointType(t)
+		if err != nil {
+			return fmt.Errorf("cluster.rpc_policy: %s: %s", endpoint, err)
+		}
+		cfg.RPCPolicy[endpoint] = endpointType
+		cfg.rpcPolicyOverrides[endpoint] = t
+	}
+
+	if len(jcfg.RPCTimeouts) > 0 {
+		cfg.rpcTimeoutOverrides = make(map[string]string, len(jcfg.RPCTimeouts))
+	}
+	for endpoint, t := range jcfg.RPCTimeouts {
+		if _, ok := DefaultRPCPolicy[endpoint]; !ok {
+			return fmt.Errorf("cluster.rpc_timeouts: unknown RPC endpoint %s", endpoint)
+		}
+		timeout, err := time.ParseDuration(t)
+		if err != nil {
+			return fmt.Errorf("cluster.rpc_timeouts: %s: %s", endpoint, err)
+		}
+		cfg.RPCTimeouts[endpoint] = timeout
+		cfg.rpcTimeoutOverrides[endpoint] = t
+	}
 
 	return cfg.Validate()
 }
@@ -479,6 +1098,7 @@ func (cfg *Config) toConfigJSON() (jcfg *configJSON, err error) {
 	// Set all configuration fields
 	jcfg.Peername = cfg.Peername
 	jcfg.Secret = EncodeProtectorKey(cfg.Secret)
+	jcfg.TransitionSecret = EncodeProtectorKey(cfg.TransitionSecret)
 	jcfg.ReplicationFactorMin = cfg.ReplicationFactorMin
 	jcfg.ReplicationFactorMax = cfg.ReplicationFactorMax
 	jcfg.LeaveOnShutdown = cfg.LeaveOnShutdown
@@ -488,6 +1108,11 @@ func (cfg *Config) toConfigJSON() (jcfg *configJSON, err error) {
 	}
 	jcfg.ListenMultiaddress = ipfsconfig.Strings(listenAddrs)
 	jcfg.EnableRelayHop = cfg.EnableRelayHop
+	for _, addr := range cfg.AnnounceAddr {
+		jcfg.AnnounceMultiaddress = append(jcfg.AnnounceMultiaddress, addr.String())
+	}
+	jcfg.DisableNATPortMap = cfg.DisableNATPortMap
+	jcfg.DisableAutoRelay = cfg.DisableAutoRelay
 	jcfg.ConnectionManager = &connMgrConfigJSON{
 		HighWater:   cfg.ConnMgr.HighWater,
 		LowWater:    cfg.ConnMgr.LowWater,
@@ -495,20 +1120,115 @@ func (cfg *Config) toConfigJSON() (jcfg *configJSON, err error) {
 	}
 	jcfg.StateSyncInterval = cfg.StateSyncInterval.String()
 	jcfg.PinRecoverInterval = cfg.PinRecoverInterval.String()
+	jcfg.SyncJitter = cfg.SyncJitter.String()
 	jcfg.MonitorPingInterval = cfg.MonitorPingInterval.String()
 	jcfg.PeerWatchInterval = cfg.PeerWatchInterval.String()
 	jcfg.MDNSInterval = cfg.MDNSInterval.String()
+	jcfg.DHTRendezvous = cfg.DHTRendezvous
+	if cfg.IPNSPublishKey != "" {
+		jcfg.IPNSPublishKey = cfg.IPNSPublishKey
+		jcfg.IPNSPublishInterval = cfg.IPNSPublishInterval.String()
+	}
+	if cfg.IPNSRecordLifetime > 0 {
+		jcfg.IPNSRecordLifetime = cfg.IPNSRecordLifetime.String()
+	}
 	jcfg.DisableRepinning = cfg.DisableRepinning
+	jcfg.RepinDelay = cfg.RepinDelay.String()
+	jcfg.RepinRateLimit = cfg.RepinRateLimit
+	jcfg.AdmissionMaxQueued = cfg.AdmissionMaxQueued
+	jcfg.AdmissionMinFree = cfg.AdmissionMinFreeSpace
+	if cfg.AdmissionWait > 0 {
+		jcfg.AdmissionWait = cfg.AdmissionWait.String()
+	}
+	if cfg.SizePrecheckTimeout > 0 {
+		jcfg.SizePrecheckTimeout = cfg.SizePrecheckTimeout.String()
+	}
+	if cfg.KeepAliveInterval > 0 {
+		jcfg.KeepAliveInterval = cfg.KeepAliveInterval.String()
+	}
+	jcfg.Allocator = cfg.Allocator
+	jcfg.AllocatorTieBreak = cfg.AllocatorTieBreak
 	jcfg.PeerstoreFile = cfg.PeerstoreFile
 	jcfg.PeerAddresses = []string{}
 	for _, addr := range cfg.PeerAddresses {
 		jcfg.PeerAddresses = append(jcfg.PeerAddresses, addr.String())
 	}
 	jcfg.FollowerMode = cfg.FollowerMode
+	jcfg.Standby = cfg.Standby
+	jcfg.ShutdownDrainTimeout = cfg.ShutdownDrainTimeout.String()
+	jcfg.JobRetention = cfg.JobRetention.String()
+	jcfg.Tags = cfg.Tags
+	jcfg.StorageClass = cfg.StorageClass
+	if len(cfg.PlacementPolicies) > 0 {
+		jcfg.PlacementPolicies = make(map[string]*placementPolicyJSON, len(cfg.PlacementPolicies))
+	}
+	for name, p := range cfg.PlacementPolicies {
+		jcfg.PlacementPolicies[name] = &placementPolicyJSON{
+			ReplicationFactorMin: p.ReplicationFactorMin,
+			ReplicationFactorMax: p.ReplicationFactorMax,
+			AllowedTags:          p.AllowedTags,
+			AllocateBy:           p.AllocateBy,
+		}
+	}
+	if len(cfg.PeerGroups) > 0 {
+		jcfg.PeerGroups = make(map[string][]string, len(cfg.PeerGroups))
+	}
+	for name, peers := range cfg.PeerGroups {
+		jcfg.PeerGroups[name] = api.PeersToStrings(peers)
+	}
+	if ex := cfg.AllocationExclusions; !ex.IsEmpty() {
+		jcfg.AllocationExclusions = &allocationExclusionsJSON{Tags: ex.Tags}
+		for _, p := range ex.Peers {
+			jcfg.AllocationExclusions.Peers = append(jcfg.AllocationExclusions.Peers, peer.IDB58Encode(p))
+		}
+	}
+	if ps := cfg.ProvideStrategy; ps != nil {
+		jcfg.ProvideStrategy = &provideStrategyJSON{Strategy: ps.Strategy}
+		for _, p := range ps.Peers {
+			jcfg.ProvideStrategy.Peers = append(jcfg.ProvideStrategy.Peers, peer.IDB58Encode(p))
+		}
+	}
+	jcfg.NamespaceQuotas = quotasToJSON(cfg.NamespaceQuotas)
+	jcfg.UserQuotas = quotasToJSON(cfg.UserQuotas)
+	jcfg.RPCPolicy = cfg.rpcPolicyOverrides
+	jcfg.RPCTimeouts = cfg.rpcTimeoutOverrides
+	jcfg.RPCPageSize = cfg.RPCPageSize
+	jcfg.BroadcastConcurrency = cfg.BroadcastConcurrency
 
 	return
 }
 
+func quotasFromJSON(jquotas map[string]*quotaJSON) map[string]*Quota {
+	if len(jquotas) == 0 {
+		return nil
+	}
+	quotas := make(map[string]*Quota, len(jquotas))
+	for name, q := range jquotas {
+		if q == nil {
+			continue
+		}
+		quotas[name] = &Quota{
+			MaxPins:  q.MaxPins,
+			MaxBytes: q.MaxBytes,
+		}
+	}
+	return quotas
+}
+
+func quotasToJSON(quotas map[string]*Quota) map[string]*quotaJSON {
+	if len(quotas) == 0 {
+		return nil
+	}
+	jquotas := make(map[string]*quotaJSON, len(quotas))
+	for name, q := range quotas {
+		jquotas[name] = &quotaJSON{
+			MaxPins:  q.MaxPins,
+			MaxBytes: q.MaxBytes,
+		}
+	}
+	return jquotas
+}
+
 // GetPeerstorePath returns the full path of the
 // PeerstoreFile, obtained by concatenating that value
 // with BaseDir of the configuration, if set.
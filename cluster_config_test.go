@@ -6,7 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ipfs/ipfs-cluster/allocator/util"
+
 	ipfsconfig "github.com/ipfs/go-ipfs-config"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 )
 
 var ccfgTestJSON = []byte(`
@@ -139,6 +142,85 @@ func TestLoadJSON(t *testing.T) {
 		}
 	})
 
+	t.Run("dht rendezvous", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.DHTRendezvous = "mycluster" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.DHTRendezvous != "mycluster" {
+			t.Error("dht_rendezvous not loaded")
+		}
+	})
+
+	t.Run("ipns publishing", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) {
+			j.IPNSPublishKey = "pinset"
+			j.IPNSRecordLifetime = "48h"
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.IPNSPublishKey != "pinset" ||
+			cfg.IPNSPublishInterval != DefaultIPNSPublishInterval ||
+			cfg.IPNSRecordLifetime != 48*time.Hour {
+			t.Error("ipns publishing options not loaded")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) {
+			j.IPNSPublishKey = "pinset"
+			j.IPNSPublishInterval = "0s"
+		})
+		if err == nil {
+			t.Error("expected an error with a 0 ipns_publish_interval")
+		}
+	})
+
+	t.Run("nat options", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) {
+			j.AnnounceMultiaddress = []string{"/ip4/1.2.3.4/tcp/9096"}
+			j.DisableNATPortMap = true
+			j.DisableAutoRelay = true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.AnnounceAddr) != 1 || cfg.AnnounceAddr[0].String() != "/ip4/1.2.3.4/tcp/9096" {
+			t.Error("announce_multiaddress not loaded")
+		}
+		if !cfg.DisableNATPortMap || !cfg.DisableAutoRelay {
+			t.Error("expected NAT port map and auto relay to be disabled")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) { j.AnnounceMultiaddress = []string{"abc"} })
+		if err == nil {
+			t.Error("expected error parsing announce_multiaddress")
+		}
+	})
+
+	t.Run("transition secret", func(t *testing.T) {
+		transition := "1588b80d5cb05374fa142aed6cbb047d1f4ef8ef15e37eba68c65b9d30df67ed"
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.TransitionSecret = transition })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if EncodeProtectorKey(cfg.TransitionSecret) != transition {
+			t.Error("transition secret not loaded")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) { j.TransitionSecret = "abc" })
+		if err == nil {
+			t.Error("expected error decoding transition secret")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) {
+			j.Secret = ""
+			j.TransitionSecret = transition
+		})
+		if err == nil {
+			t.Error("expected error with a transition secret and no secret")
+		}
+	})
+
 	t.Run("default replication factors", func(t *testing.T) {
 		cfg, err := loadJSON2(
 			t,
@@ -205,6 +287,470 @@ func TestLoadJSON(t *testing.T) {
 			t.Error("default conn manager values not set")
 		}
 	})
+
+	t.Run("rpc policy", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RPCPolicy = map[string]string{"Cluster.PeerAdd": "trusted"}
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.RPCPolicy["Cluster.PeerAdd"] != RPCTrusted {
+			t.Error("expected Cluster.PeerAdd to be trusted")
+		}
+		if DefaultRPCPolicy["Cluster.PeerAdd"] != RPCOpen {
+			t.Error("the default policy should not be modified")
+		}
+		if cfg.RPCPolicy["Cluster.ID"] != RPCOpen {
+			t.Error("other endpoints should keep their default")
+		}
+
+		j, err := cfg.toConfigJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(j.RPCPolicy) != 1 || j.RPCPolicy["Cluster.PeerAdd"] != "trusted" {
+			t.Error("only the rpc_policy overrides should be saved")
+		}
+	})
+
+	t.Run("rpc timeouts", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RPCTimeouts = map[string]string{
+					"Cluster.StatusLocal": "10s",
+					"PinTracker.Track":    "0s",
+				}
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.RPCTimeouts["Cluster.StatusLocal"] != 10*time.Second {
+			t.Error("expected Cluster.StatusLocal to time out after 10s")
+		}
+		if cfg.RPCTimeouts["PinTracker.Track"] != 0 {
+			t.Error("expected PinTracker.Track to have no timeout")
+		}
+		if cfg.RPCTimeouts["Cluster.ID"] != DefaultRPCTimeouts["Cluster.ID"] {
+			t.Error("other endpoints should keep their default")
+		}
+
+		j, err := cfg.toConfigJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(j.RPCTimeouts) != 2 || j.RPCTimeouts["Cluster.StatusLocal"] != "10s" {
+			t.Error("only the rpc_timeouts overrides should be saved")
+		}
+	})
+
+	t.Run("repinning policy", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RepinDelay = "10m"
+				j.RepinRateLimit = 30
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.RepinDelay != 10*time.Minute {
+			t.Error("expected repin_delay to be 10m")
+		}
+		if cfg.RepinRateLimit != 30 {
+			t.Error("expected repin_rate_limit to be 30")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RepinRateLimit = -1
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with a negative repin_rate_limit")
+		}
+	})
+
+	t.Run("sync_jitter", func(t *testing.T) {
+		cfg := loadJSON(t)
+		if cfg.SyncJitter != DefaultSyncJitter {
+			t.Error("expected the default sync_jitter")
+		}
+
+		_, err := loadJSON2(t, func(j *configJSON) { j.SyncJitter = "-1s" })
+		if err == nil {
+			t.Error("expected an error with a negative sync_jitter")
+		}
+	})
+
+	t.Run("admission", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.AdmissionMaxQueued = 1000
+				j.AdmissionMinFree = 1 << 30
+				j.AdmissionWait = "5s"
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.AdmissionMaxQueued != 1000 ||
+			cfg.AdmissionMinFreeSpace != 1<<30 ||
+			cfg.AdmissionWait != 5*time.Second {
+			t.Error("unexpected admission values")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.AdmissionMaxQueued = -1
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with a negative admission_max_queued")
+		}
+	})
+
+	t.Run("size precheck", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.SizePrecheckTimeout = "10s" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.SizePrecheckTimeout != 10*time.Second {
+			t.Error("unexpected size_precheck_timeout")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) { j.SizePrecheckTimeout = "-1s" })
+		if err == nil {
+			t.Error("expected an error with a negative size_precheck_timeout")
+		}
+	})
+
+	t.Run("keep alive interval", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.KeepAliveInterval = "20s" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.KeepAliveInterval != 20*time.Second {
+			t.Error("unexpected keep_alive_interval")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) { j.KeepAliveInterval = "-1s" })
+		if err == nil {
+			t.Error("expected an error with a negative keep_alive_interval")
+		}
+	})
+
+	t.Run("allocator", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.Allocator = "" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Allocator != AllocatorDescend {
+			t.Error("expected the default allocator")
+		}
+
+		cfg, err = loadJSON2(t, func(j *configJSON) { j.Allocator = AllocatorHRW })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Allocator != AllocatorHRW {
+			t.Error("expected the hrw allocator")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) { j.Allocator = "random" })
+		if err == nil {
+			t.Error("expected an error with an unknown allocator")
+		}
+	})
+
+	t.Run("allocator tie break", func(t *testing.T) {
+		cfg, err := loadJSON2(t, func(j *configJSON) { j.AllocatorTieBreak = "" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.AllocatorTieBreak != util.TieBreakNone {
+			t.Error("expected no tie breaking by default")
+		}
+
+		cfg, err = loadJSON2(t, func(j *configJSON) { j.AllocatorTieBreak = "round_robin" })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.AllocatorTieBreak != util.TieBreakRoundRobin {
+			t.Error("expected round-robin tie breaking")
+		}
+
+		_, err = loadJSON2(t, func(j *configJSON) { j.AllocatorTieBreak = "random" })
+		if err == nil {
+			t.Error("expected an error with an unknown tie breaking strategy")
+		}
+	})
+
+	t.Run("placement policies", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.Tags = map[string]string{"region": "eu"}
+				j.StorageClass = "hdd"
+				j.PlacementPolicies = map[string]*placementPolicyJSON{
+					"archive": {
+						ReplicationFactorMin: 2,
+						ReplicationFactorMax: 3,
+						AllowedTags:          map[string][]string{"region": {"eu"}},
+						AllocateBy:           "freespace",
+					},
+				}
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Tags["region"] != "eu" {
+			t.Error("expected region tag")
+		}
+		if cfg.StorageClass != "hdd" {
+			t.Error("expected hdd storage class")
+		}
+		p, ok := cfg.PlacementPolicies["archive"]
+		if !ok {
+			t.Fatal("expected archive policy")
+		}
+		if p.ReplicationFactorMin != 2 || p.ReplicationFactorMax != 3 ||
+			p.AllowedTags["region"][0] != "eu" || p.AllocateBy != "freespace" {
+			t.Error("unexpected policy values")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.PlacementPolicies = map[string]*placementPolicyJSON{
+					"bad": {
+						ReplicationFactorMin: 3,
+						ReplicationFactorMax: 2,
+					},
+				}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with bad policy replication factors")
+		}
+	})
+
+	t.Run("peer groups", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.PeerGroups = map[string][]string{
+					"hot": {"QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc"},
+				}
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hot, ok := cfg.PeerGroups["hot"]
+		if !ok || len(hot) != 1 || peer.IDB58Encode(hot[0]) != "QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc" {
+			t.Error("expected the hot peer group")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.PeerGroups = map[string][]string{"hot": {"abc"}}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with a bad peer ID")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.PeerGroups = map[string][]string{"hot": {}}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with an empty group")
+		}
+	})
+
+	t.Run("allocation exclusions", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.AllocationExclusions = &allocationExclusionsJSON{
+					Peers: []string{"QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc"},
+					Tags:  map[string][]string{"rack": {"old"}},
+				}
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ex := cfg.AllocationExclusions
+		if ex == nil || len(ex.Peers) != 1 || ex.Tags["rack"][0] != "old" {
+			t.Fatalf("unexpected exclusions: %+v", ex)
+		}
+		if peer.IDB58Encode(ex.Peers[0]) != "QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc" {
+			t.Error("unexpected excluded peer")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.AllocationExclusions = &allocationExclusionsJSON{
+					Peers: []string{"abc"},
+				}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with a bad peer ID")
+		}
+	})
+
+	t.Run("provide strategy", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.ProvideStrategy = &provideStrategyJSON{
+					Strategy: "roots",
+					Peers:    []string{"QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc"},
+				}
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps := cfg.ProvideStrategy
+		if ps == nil || ps.Strategy != "roots" || len(ps.Peers) != 1 {
+			t.Fatalf("unexpected provide strategy: %+v", ps)
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.ProvideStrategy = &provideStrategyJSON{Strategy: "some"}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with an unknown strategy")
+		}
+	})
+
+	t.Run("quotas", func(t *testing.T) {
+		cfg, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.NamespaceQuotas = map[string]*quotaJSON{
+					"team-a": {
+						MaxPins:  10,
+						MaxBytes: 1000,
+					},
+				}
+				j.UserQuotas = map[string]*quotaJSON{
+					"alice": {
+						MaxBytes: 500,
+					},
+				}
+			},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, ok := cfg.NamespaceQuotas["team-a"]
+		if !ok {
+			t.Fatal("expected team-a quota")
+		}
+		if q.MaxPins != 10 || q.MaxBytes != 1000 {
+			t.Error("unexpected quota values")
+		}
+		q, ok = cfg.UserQuotas["alice"]
+		if !ok {
+			t.Fatal("expected alice quota")
+		}
+		if q.MaxPins != 0 || q.MaxBytes != 500 {
+			t.Error("unexpected user quota values")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.NamespaceQuotas = map[string]*quotaJSON{
+					"team-a": {
+						MaxPins: -1,
+					},
+				}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with a negative max_pins")
+		}
+	})
+
+	t.Run("bad rpc policy", func(t *testing.T) {
+		_, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RPCPolicy = map[string]string{"Cluster.PeerAdd": "everyone"}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with an unknown endpoint type")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RPCPolicy = map[string]string{"Cluster.Nothing": "open"}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with an unknown endpoint")
+		}
+	})
+
+	t.Run("bad rpc timeouts", func(t *testing.T) {
+		_, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RPCTimeouts = map[string]string{"Cluster.ID": "-1s"}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with a negative timeout")
+		}
+
+		_, err = loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.RPCTimeouts = map[string]string{"Cluster.Nothing": "1s"}
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with an unknown endpoint")
+		}
+	})
+
+	t.Run("bad broadcast concurrency", func(t *testing.T) {
+		_, err := loadJSON2(
+			t,
+			func(j *configJSON) {
+				j.BroadcastConcurrency = -1
+			},
+		)
+		if err == nil {
+			t.Error("expected an error with a negative broadcast_concurrency")
+		}
+	})
 }
 
 func TestToJSON(t *testing.T) {
@@ -242,6 +788,17 @@ func TestApplyEnvVars(t *testing.T) {
 	}
 }
 
+func TestApplyEnvVarsNoProvideStrategy(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if err := cfg.ApplyEnvVars(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ProvideStrategy != nil {
+		t.Errorf("expected no provide strategy, got: %+v", cfg.ProvideStrategy)
+	}
+}
+
 func TestValidate(t *testing.T) {
 	cfg := &Config{}
 
@@ -275,4 +832,16 @@ func TestValidate(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.ShutdownDrainTimeout = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
+
+	cfg.Default()
+	cfg.JobRetention = -1
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating")
+	}
 }
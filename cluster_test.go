@@ -1,6 +1,7 @@
 package ipfscluster
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,7 +17,9 @@ import (
 	"github.com/ipfs/ipfs-cluster/allocator/ascendalloc"
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/informer/disk"
 	"github.com/ipfs/ipfs-cluster/informer/numpin"
+	"github.com/ipfs/ipfs-cluster/ipfsconn/nullconn"
 	"github.com/ipfs/ipfs-cluster/monitor/pubsubmon"
 	"github.com/ipfs/ipfs-cluster/pintracker/stateless"
 	"github.com/ipfs/ipfs-cluster/state"
@@ -23,9 +27,14 @@ import (
 	"github.com/ipfs/ipfs-cluster/version"
 
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	cbor "github.com/ipfs/go-ipld-cbor"
 	gopath "github.com/ipfs/go-path"
+	libp2p "github.com/libp2p/go-libp2p"
+	connmgr "github.com/libp2p/go-libp2p-core/connmgr"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
+	mh "github.com/multiformats/go-multihash"
 )
 
 type mockComponent struct {
@@ -49,16 +58,32 @@ type mockProxy struct {
 	mockComponent
 }
 
+type mockLeaderTasker struct {
+	mockComponent
+	runs int
+}
+
+func (lt *mockLeaderTasker) LeaderTask() (string, time.Duration, func(context.Context) error) {
+	return "mock", time.Minute, func(ctx context.Context) error {
+		lt.runs++
+		return nil
+	}
+}
+
 type mockConnector struct {
 	mockComponent
 
 	pins   sync.Map
 	blocks sync.Map
+	ipns   sync.Map
+
+	reprovider atomic.Value
 }
 
 func (ipfs *mockConnector) ID(ctx context.Context) (*api.IPFSID, error) {
 	return &api.IPFSID{
-		ID: test.PeerID1,
+		ID:           test.PeerID1,
+		AgentVersion: test.IpfsAgentVersion,
 	}, nil
 }
 
@@ -110,6 +135,10 @@ func (ipfs *mockConnector) RepoStat(ctx context.Context) (*api.IPFSRepoStat, err
 	return &api.IPFSRepoStat{RepoSize: 100, StorageMax: 1000}, nil
 }
 
+func (ipfs *mockConnector) DagSize(ctx context.Context, c cid.Cid) (uint64, error) {
+	return 1000, nil
+}
+
 func (ipfs *mockConnector) RepoGC(ctx context.Context) (*api.RepoGC, error) {
 	return &api.RepoGC{
 		Keys: []api.IPFSRepoGC{
@@ -136,6 +165,23 @@ func (ipfs *mockConnector) BlockPut(ctx context.Context, nwm *api.NodeWithMeta)
 	return nil
 }
 
+func (ipfs *mockConnector) Prefetch(ctx context.Context, c cid.Cid) error {
+	if c.Equals(test.ErrorCid) {
+		return errors.New("mock prefetch error")
+	}
+	return nil
+}
+
+func (ipfs *mockConnector) NamePublish(ctx context.Context, pub *api.IPNSPublish) (string, error) {
+	ipfs.ipns.Store(pub.Key, pub.Cid)
+	return test.PeerID1.Pretty(), nil
+}
+
+func (ipfs *mockConnector) SetReprovider(ctx context.Context, rp *api.IPFSReprovider) error {
+	ipfs.reprovider.Store(*rp)
+	return nil
+}
+
 func (ipfs *mockConnector) BlockGet(ctx context.Context, c cid.Cid) ([]byte, error) {
 	d, ok := ipfs.blocks.Load(c.String())
 	if !ok {
@@ -259,6 +305,69 @@ func TestClusterStateSync(t *testing.T) {
 	}
 }
 
+func TestClusterUnpinExpired(t *testing.T) {
+	ctx := context.Background()
+	cleanState()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{ExpireAt: time.Now().Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	time.Sleep(time.Second)
+
+	// StateSync leaves expired pins to the leader task.
+	err = cl.StateSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cl.PinGet(ctx, test.Cid1); err != nil {
+		t.Error("StateSync should not unpin expired pins")
+	}
+
+	var ran bool
+	for _, task := range cl.leaderTasks() {
+		if task.name == "unpin_expired" {
+			ran = cl.runLeaderTaskOnce(ctx, task)
+		}
+	}
+	if !ran {
+		t.Fatal("expected the single peer to run the unpin_expired task")
+	}
+	pinDelay()
+
+	if _, err := cl.PinGet(ctx, test.Cid1); err == nil {
+		t.Error("the expired pin should have been unpinned")
+	}
+	if _, err := cl.PinGet(ctx, test.Cid2); err != nil {
+		t.Error("pins without expiration should be kept")
+	}
+}
+
+func TestPeerStagger(t *testing.T) {
+	interval := 5 * time.Minute
+	d1 := peerStagger(test.PeerID1, "state_sync", interval)
+	if d1 <= 0 || d1 > interval {
+		t.Errorf("stagger out of the interval: %s", d1)
+	}
+	if d1 != peerStagger(test.PeerID1, "state_sync", interval) {
+		t.Error("stagger should not change for the same peer")
+	}
+	if d1 == peerStagger(test.PeerID2, "state_sync", interval) {
+		t.Error("peers should be staggered")
+	}
+	if d1 == peerStagger(test.PeerID1, "recover", interval) {
+		t.Error("operations should be staggered")
+	}
+}
+
 func TestClusterID(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -327,6 +436,244 @@ func TestPinExpired(t *testing.T) {
 	}
 }
 
+func TestClusterLocalPin(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	pin, err := cl.Pin(ctx, test.Cid1, api.PinOptions{LocalPin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pin.Allocations) != 1 || pin.Allocations[0] != cl.id {
+		t.Error("local pins should be allocated to the peer")
+	}
+
+	_, err = cl.PinGet(ctx, test.Cid1)
+	if err != state.ErrNotFound {
+		t.Error("local pins should not be in the shared state")
+	}
+
+	pinDelay()
+
+	pinfo := cl.tracker.Status(ctx, test.Cid1)
+	if pinfo.Status != api.TrackerStatusPinned || !pinfo.Local {
+		t.Error("the local pin should be pinned and marked as local")
+	}
+
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != errPinnedLocally {
+		t.Error("expected an error pinning a local pin in the shared pinset")
+	}
+
+	_, err = cl.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := cl.isLocalPin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if local {
+		t.Error("the local pin should have been removed")
+	}
+
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{LocalPin: true})
+	if err == nil {
+		t.Error("expected an error pinning a shared pin locally")
+	}
+}
+
+func TestClusterPinPolicy(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.PlacementPolicies = map[string]*PlacementPolicy{
+		"everywhere": {
+			ReplicationFactorMin: -1,
+			ReplicationFactorMax: -1,
+		},
+	}
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Policy: "nothing"})
+	if err == nil {
+		t.Error("expected an error with an unknown policy")
+	}
+
+	pin, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Policy: "everywhere"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.ReplicationFactorMin != -1 || pin.ReplicationFactorMax != -1 {
+		t.Error("expected the replication factors of the policy")
+	}
+	if pin.Policy != "everywhere" {
+		t.Error("expected the pin to keep the policy name")
+	}
+}
+
+func TestClusterPinNamespaces(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	// The mock connector reports 1000 bytes for every DAG.
+	cl.config.NamespaceQuotas = map[string]*Quota{
+		"team-a": {
+			MaxPins: 1,
+		},
+	}
+
+	pin, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Namespace: "team-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Namespace != "team-a" {
+		t.Error("expected the pin to keep the namespace")
+	}
+
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Namespace: "team-a"})
+	if err == nil {
+		t.Error("expected an error when going over the pin quota")
+	}
+
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{Namespace: "team-a"})
+	if err != nil {
+		t.Error("re-pinning should not count towards the quota:", err)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{Namespace: "team-b"})
+	if err != errPinNamespace {
+		t.Error("expected an error which does not disclose the other namespace:", err)
+	}
+
+	cl.config.NamespaceQuotas["team-a"] = &Quota{MaxBytes: 1500}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Namespace: "team-a"})
+	if err == nil {
+		t.Error("expected an error when going over the bytes quota")
+	}
+
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Namespace: "team-b"})
+	if err != nil {
+		t.Error("namespaces without quota should not be limited:", err)
+	}
+}
+
+func TestClusterQuotaUsage(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.UserQuotas = map[string]*Quota{
+		"alice": {
+			MaxPins: 1,
+		},
+	}
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Namespace: "team-a", Owner: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Owner: "alice"})
+	if err == nil {
+		t.Error("expected an error when going over the user quota")
+	}
+
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{Owner: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pin, err := cl.Pin(ctx, test.Cid1, api.PinOptions{Namespace: "team-a", Owner: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Owner != "alice" {
+		t.Error("pins should keep their owner")
+	}
+
+	usage, err := cl.QuotaUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 3 {
+		t.Fatalf("expected 3 usage reports, got %d", len(usage))
+	}
+	// The mock connector reports 1000 bytes for every DAG, but only pins
+	// subject to a quota are sized.
+	if u := usage[0]; u.Namespace != "team-a" || u.Pins != 1 || u.Bytes != 1000 {
+		t.Errorf("unexpected namespace usage: %+v", u)
+	}
+	if u := usage[1]; u.User != "alice" || u.Pins != 1 || u.MaxPins != 1 {
+		t.Errorf("unexpected usage for alice: %+v", u)
+	}
+	if u := usage[2]; u.User != "bob" || u.Pins != 1 || u.Bytes != 0 {
+		t.Errorf("unexpected usage for bob: %+v", u)
+	}
+
+	// Once the pinset index is ready, the usage comes from it and follows
+	// pins and unpins.
+	err = cl.StateSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := cl.QuotaUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexed) != len(usage) {
+		t.Fatalf("expected %d usage reports, got %d", len(usage), len(indexed))
+	}
+	for i := range usage {
+		if *indexed[i] != *usage[i] {
+			t.Errorf("the indexed usage should match: %+v, %+v", indexed[i], usage[i])
+		}
+	}
+
+	_, err = cl.Unpin(ctx, test.Cid2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	usage, err = cl.QuotaUsage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != 2 || usage[1].User != "alice" {
+		t.Errorf("bob should have no pins left: %+v", usage)
+	}
+}
+
+func TestTagsAllowed(t *testing.T) {
+	allowed := map[string][]string{
+		"region": {"eu", "us"},
+		"disk":   {"ssd"},
+	}
+
+	if !tagsAllowed(map[string]string{"region": "us", "disk": "ssd", "other": "x"}, allowed) {
+		t.Error("tags should be allowed")
+	}
+	if tagsAllowed(map[string]string{"region": "asia", "disk": "ssd"}, allowed) {
+		t.Error("tag value should not be allowed")
+	}
+	if tagsAllowed(map[string]string{"region": "eu"}, allowed) {
+		t.Error("missing tag should not be allowed")
+	}
+	if !tagsAllowed(nil, nil) {
+		t.Error("everything should be allowed without allowed tags")
+	}
+}
+
 func TestClusterPinPath(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -489,58 +836,173 @@ func TestUnpinShard(t *testing.T) {
 	})
 }
 
-// func singleShardedPin(t *testing.T, cl *Cluster) {
-// 	cShard, _ := cid.Decode(test.ShardCid)
-// 	cCdag, _ := cid.Decode(test.CdagCid)
-// 	cMeta, _ := cid.Decode(test.MetaRootCid)
-// 	pinMeta(t, cl, []cid.Cid{cShard}, cCdag, cMeta)
-// }
-
-// func pinMeta(t *testing.T, cl *Cluster, shardCids []cid.Cid, cCdag, cMeta cid.Cid) {
-// 	for _, cShard := range shardCids {
-// 		shardPin := api.Pin{
-// 			Cid:      cShard,
-// 			Type:     api.ShardType,
-// 			MaxDepth: 1,
-// 			PinOptions: api.PinOptions{
-// 				ReplicationFactorMin: -1,
-// 				ReplicationFactorMax: -1,
-// 			},
-// 		}
-// 		err := cl.Pin(shardPin)
-// 		if err != nil {
-// 			t.Fatal("shard pin should have worked:", err)
-// 		}
-// 	}
+func addShardedTestContent(t *testing.T, cl *Cluster) (root cid.Cid, pinnedCids []cid.Cid) {
+	ctx := context.Background()
+	sth := test.NewShardingTestHelper()
+	defer sth.Clean(t)
 
-// 	parents := cid.NewSet()
-// 	parents.Add(cMeta)
-// 	cdagPin := api.Pin{
-// 		Cid:      cCdag,
-// 		Type:     api.ClusterDAGType,
-// 		MaxDepth: 0,
-// 		PinOptions: api.PinOptions{
-// 			ReplicationFactorMin: -1,
-// 			ReplicationFactorMax: -1,
-// 		},
-// 	}
-// 	err := cl.Pin(cdagPin)
-// 	if err != nil {
-// 		t.Fatal("pin should have worked:", err)
-// 	}
+	params := api.DefaultAddParams()
+	params.Shard = true
+	params.Name = "testshard"
+	mfr, closer := sth.GetTreeMultiReader(t)
+	defer closer.Close()
+	r := multipart.NewReader(mfr, mfr.Boundary())
+	root, err := cl.AddFile(r, params)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-// 	metaPin := api.Pin{
-// 		Cid:        cMeta,
-// 		Type:       api.MetaType,
-// 		Clusterdag: cCdag,
-// 	}
-// 	err = cl.Pin(metaPin)
-// 	if err != nil {
-// 		t.Fatal("pin should have worked:", err)
-// 	}
-// }
+	pinDelay()
 
-// func TestClusterPinMeta(t *testing.T) {
+	metaPin, _ := cl.PinGet(ctx, root)
+	cDag, _ := cl.PinGet(ctx, *metaPin.Reference)
+	pinnedCids = append(pinnedCids, cDag.Cid)
+	cDagBlock, _ := cl.ipfs.BlockGet(ctx, cDag.Cid)
+	cDagNode, _ := sharding.CborDataToNode(cDagBlock, "cbor")
+	for _, l := range cDagNode.Links() {
+		pinnedCids = append(pinnedCids, l.Cid)
+	}
+	return root, pinnedCids
+}
+
+func TestUnpinSharedShards(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	root, shardCids := addShardedTestContent(t, cl)
+
+	// A second MetaPin referencing the same ClusterDAG.
+	metaPin2 := api.PinWithOpts(test.Cid1, api.PinOptions{Name: "meta2"})
+	metaPin2.Type = api.MetaType
+	metaPin2.Reference = &shardCids[0]
+	_, _, err := cl.pin(ctx, metaPin2, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	_, err = cl.Unpin(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	for _, c := range shardCids {
+		_, err := cl.PinGet(ctx, c)
+		if err != nil {
+			t.Errorf("%s is referenced by another pin and should not have been unpinned", c)
+		}
+	}
+
+	_, err = cl.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	for _, c := range shardCids {
+		_, err := cl.PinGet(ctx, c)
+		if err == nil {
+			t.Errorf("%s should have been unpinned", c)
+		}
+	}
+}
+
+func TestShardsGC(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	root, shardCids := addShardedTestContent(t, cl)
+
+	unpinned, err := cl.ShardsGC(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unpinned) != 0 {
+		t.Fatal("referenced shards should not be unpinned")
+	}
+
+	// Remove the MetaPin only, leaving the shards behind.
+	metaPin, _ := cl.PinGet(ctx, root)
+	err = cl.consensus.LogUnpin(ctx, metaPin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	unpinned, err = cl.ShardsGC(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unpinned) != len(shardCids) {
+		t.Fatalf("expected %d unpinned items, got %d", len(shardCids), len(unpinned))
+	}
+	pinDelay()
+
+	for _, c := range shardCids {
+		_, err := cl.PinGet(ctx, c)
+		if err == nil {
+			t.Errorf("%s should have been unpinned", c)
+		}
+	}
+}
+
+// func singleShardedPin(t *testing.T, cl *Cluster) {
+// 	cShard, _ := cid.Decode(test.ShardCid)
+// 	cCdag, _ := cid.Decode(test.CdagCid)
+// 	cMeta, _ := cid.Decode(test.MetaRootCid)
+// 	pinMeta(t, cl, []cid.Cid{cShard}, cCdag, cMeta)
+// }
+
+// func pinMeta(t *testing.T, cl *Cluster, shardCids []cid.Cid, cCdag, cMeta cid.Cid) {
+// 	for _, cShard := range shardCids {
+// 		shardPin := api.Pin{
+// 			Cid:      cShard,
+// 			Type:     api.ShardType,
+// 			MaxDepth: 1,
+// 			PinOptions: api.PinOptions{
+// 				ReplicationFactorMin: -1,
+// 				ReplicationFactorMax: -1,
+// 			},
+// 		}
+// 		err := cl.Pin(shardPin)
+// 		if err != nil {
+// 			t.Fatal("shard pin should have worked:", err)
+// 		}
+// 	}
+
+// 	parents := cid.NewSet()
+// 	parents.Add(cMeta)
+// 	cdagPin := api.Pin{
+// 		Cid:      cCdag,
+// 		Type:     api.ClusterDAGType,
+// 		MaxDepth: 0,
+// 		PinOptions: api.PinOptions{
+// 			ReplicationFactorMin: -1,
+// 			ReplicationFactorMax: -1,
+// 		},
+// 	}
+// 	err := cl.Pin(cdagPin)
+// 	if err != nil {
+// 		t.Fatal("pin should have worked:", err)
+// 	}
+
+// 	metaPin := api.Pin{
+// 		Cid:        cMeta,
+// 		Type:       api.MetaType,
+// 		Clusterdag: cCdag,
+// 	}
+// 	err = cl.Pin(metaPin)
+// 	if err != nil {
+// 		t.Fatal("pin should have worked:", err)
+// 	}
+// }
+
+// func TestClusterPinMeta(t *testing.T) {
 // 	cl, _, _, _ := testingCluster(t)
 // 	defer cleanState()
 // 	defer cl.Shutdown()
@@ -800,6 +1262,260 @@ func TestClusterPins(t *testing.T) {
 	}
 }
 
+func TestClusterPinsView(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	for _, h := range []cid.Cid{test.Cid1, test.Cid2, test.Cid3} {
+		_, err := cl.Pin(ctx, h, api.PinOptions{})
+		if err != nil {
+			t.Fatal("pin should have worked:", err)
+		}
+	}
+
+	pinDelay()
+
+	page, err := cl.PinsView(ctx, &api.PinViewQuery{
+		PinQuery: api.PinQuery{Type: api.DataType},
+		Offset:   1,
+		Limit:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.Offset != 1 || len(page.Items) != 1 {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+	item := page.Items[0]
+	if item.Status == nil || !item.Status.Cid.Equals(item.Pin.Cid) {
+		t.Error("expected the status of the pin")
+	}
+	if item.Status.PeerMap[peer.IDB58Encode(cl.id)].Status != api.TrackerStatusPinned {
+		t.Error("expected the pin to be pinned in the peer")
+	}
+
+	page, err = cl.PinsView(ctx, &api.PinViewQuery{Offset: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || len(page.Items) != 0 {
+		t.Errorf("expected an empty page past the end: %+v", page)
+	}
+}
+
+func TestClusterPinsQuery(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	opts := api.PinOptions{
+		Metadata: map[string]string{"team": "a"},
+	}
+	_, err := cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+
+	pinDelay()
+
+	check := func(t *testing.T) {
+		pins, err := cl.PinsQuery(ctx, &api.PinQuery{
+			Metadata: map[string]string{"team": ""},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid1) {
+			t.Error("expected only the pin with the metadata key")
+		}
+
+		pins, err = cl.PinsQuery(ctx, &api.PinQuery{Type: api.DataType})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pins) != 2 {
+			t.Error("expected both pins")
+		}
+	}
+
+	t.Run("index", check)
+
+	_, err = cl.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	pins, err := cl.PinsQuery(ctx, &api.PinQuery{
+		Metadata: map[string]string{"team": "a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Error("unpinned items should be removed from the index")
+	}
+
+	// After a rebuild, the index should still match the state.
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+	err = cl.StateSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("rebuilt", check)
+}
+
+func TestPinInfoPager(t *testing.T) {
+	var items []*api.PinInfo
+	for i := 0; i < 5; i++ {
+		items = append(items, &api.PinInfo{Cid: test.Cid1})
+	}
+	var lists int32
+	stream := func(ctx context.Context, out chan<- *api.PinInfo) error {
+		defer close(out)
+		atomic.AddInt32(&lists, 1)
+		for _, item := range items {
+			select {
+			case out <- item:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	pg := newPinInfoPager()
+	req := &api.PageRequest{Limit: 2}
+	var got []*api.PinInfo
+	for pages := 1; ; pages++ {
+		page, err := pg.page(req, stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, page.Items...)
+		if !page.More {
+			if pages != 3 {
+				t.Errorf("expected 3 pages, got %d", pages)
+			}
+			break
+		}
+		req.Session = page.Session
+		req.Offset += len(page.Items)
+	}
+	if len(got) != len(items) {
+		t.Errorf("expected %d items, got %d", len(items), len(got))
+	}
+	if atomic.LoadInt32(&lists) != 1 {
+		t.Error("the listing should be obtained once per session")
+	}
+	if len(pg.sessions) != 0 {
+		t.Error("the session should be removed after the last page")
+	}
+
+	_, err := pg.page(req, stream)
+	if err != errPageSessionNotFound {
+		t.Error("expected an error with a finished session")
+	}
+
+	page, err := pg.page(&api.PageRequest{Limit: 10}, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.More || page.Session != "" || len(page.Items) != len(items) {
+		t.Error("short listings should be served in a single page")
+	}
+
+	page, err = pg.page(&api.PageRequest{Limit: 5}, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.More || page.Session != "" || len(page.Items) != len(items) {
+		t.Error("listings of exactly one page should be served in a single page")
+	}
+
+	failing := func(ctx context.Context, out chan<- *api.PinInfo) error {
+		close(out)
+		return errors.New("listing failed")
+	}
+	if _, err := pg.page(&api.PageRequest{Limit: 2}, failing); err == nil {
+		t.Error("expected the error of the listing")
+	}
+}
+
+func TestPinInfoPagerSessions(t *testing.T) {
+	defer func(n int, ttl time.Duration) {
+		maxPageSessions = n
+		pageSessionTTL = ttl
+	}(maxPageSessions, pageSessionTTL)
+	maxPageSessions = 2
+	pageSessionTTL = 200 * time.Millisecond
+
+	var running int32
+	// An endless listing.
+	stream := func(ctx context.Context, out chan<- *api.PinInfo) error {
+		defer close(out)
+		atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			select {
+			case out <- &api.PinInfo{Cid: test.Cid1}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	pg := newPinInfoPager()
+	var sessions []*api.PinInfoPage
+	for i := 0; i < maxPageSessions; i++ {
+		page, err := pg.page(&api.PageRequest{Limit: 1}, stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, page)
+	}
+
+	_, err := pg.page(&api.PageRequest{Limit: 1}, stream)
+	if err != errTooManyPageSessions {
+		t.Error("expected an error with too many sessions:", err)
+	}
+
+	_, err = pg.page(&api.PageRequest{Session: sessions[0].Session, Offset: 5, Limit: 1}, stream)
+	if err == nil {
+		t.Error("expected an error with a wrong offset")
+	}
+	_, err = pg.page(&api.PageRequest{Session: sessions[0].Session, Offset: 1, Limit: 1}, stream)
+	if err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(3 * pageSessionTTL)
+	pg.mu.Lock()
+	left := len(pg.sessions)
+	pg.mu.Unlock()
+	if left != 0 {
+		t.Error("expired sessions should have been removed")
+	}
+	if atomic.LoadInt32(&running) != 0 {
+		t.Error("the listings of expired sessions should have been stopped")
+	}
+
+	_, err = pg.page(&api.PageRequest{Limit: 1}, stream)
+	if err != nil {
+		t.Error("new sessions should be allowed after expiration:", err)
+	}
+}
+
 func TestClusterPinGet(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -861,20 +1577,110 @@ func TestClusterUnpin(t *testing.T) {
 	}
 }
 
-func TestClusterUnpinPath(t *testing.T) {
+func TestClusterUnpinProtected(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
 	defer cleanState()
 	defer cl.Shutdown(ctx)
 
-	// Unpin should error without pin being committed to state
-	_, err := cl.UnpinPath(ctx, test.PathIPFS2)
-	if err == nil {
-		t.Error("unpin with path should have failed")
+	c := test.Cid1
+	_, err := cl.Pin(ctx, c, api.PinOptions{Protected: true})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
 	}
 
-	// Unpin after pin should succeed
-	pin, err := cl.PinPath(ctx, test.PathIPFS2, api.PinOptions{})
+	_, err = cl.Unpin(ctx, c)
+	if api.ErrorCodeOf(err) != api.ErrCodePinProtected {
+		t.Fatal("expected a protected pin error:", err)
+	}
+
+	if _, err := cl.PinGet(ctx, c); err != nil {
+		t.Fatal("the protected pin should still be pinned:", err)
+	}
+
+	_, err = cl.ForceUnpin(ctx, c)
+	if err != nil {
+		t.Fatal("forced unpin should have worked:", err)
+	}
+}
+
+func TestClusterRepinProtected(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	c := test.Cid1
+	// The state keeps the expiry with second precision.
+	expireAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	_, err := cl.Pin(ctx, c, api.PinOptions{Protected: true, ExpireAt: expireAt})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+
+	bypasses := map[string]api.PinOptions{
+		"unprotected":    {},
+		"earlier expiry": {Protected: true, ExpireAt: time.Now().Add(time.Minute)},
+	}
+	for name, opts := range bypasses {
+		_, err := cl.Pin(ctx, c, opts)
+		if api.ErrorCodeOf(err) != api.ErrCodePinProtected {
+			t.Errorf("%s: expected a protected pin error: %s", name, err)
+		}
+	}
+
+	// Pinning another item and updating it to the protected one would
+	// replace its options too.
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, c, api.PinOptions{PinUpdate: test.Cid2})
+	if api.ErrorCodeOf(err) != api.ErrCodePinProtected {
+		t.Error("pin update: expected a protected pin error:", err)
+	}
+
+	pin, err := cl.PinGet(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.Protected || !pin.ExpireAt.Equal(expireAt) {
+		t.Fatal("the protected pin should not have changed")
+	}
+
+	// Keeping the protection or extending it is allowed.
+	_, err = cl.Pin(ctx, c, api.PinOptions{Protected: true, ExpireAt: expireAt.Add(time.Hour), Name: "a"})
+	if err != nil {
+		t.Error("extending the protected pin should have worked:", err)
+	}
+
+	_, err = cl.ForcePin(ctx, c, api.PinOptions{})
+	if err != nil {
+		t.Fatal("forced pin should have worked:", err)
+	}
+	pin, err = cl.PinGet(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Protected {
+		t.Error("forced pin should have lifted the protection")
+	}
+}
+
+func TestClusterUnpinPath(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	// Unpin should error without pin being committed to state
+	_, err := cl.UnpinPath(ctx, test.PathIPFS2)
+	if err == nil {
+		t.Error("unpin with path should have failed")
+	}
+
+	// Unpin after pin should succeed
+	pin, err := cl.PinPath(ctx, test.PathIPFS2, api.PinOptions{})
 	if err != nil {
 		t.Fatal("pin with path should have worked:", err)
 	}
@@ -912,6 +1718,10 @@ func TestClusterPeers(t *testing.T) {
 		fmt.Println(ident.ID)
 		t.Error("bad member")
 	}
+
+	if peers[0].IPFS.AgentVersion != test.IpfsAgentVersion {
+		t.Error("expected the version of the IPFS daemon")
+	}
 }
 
 func TestVersion(t *testing.T) {
@@ -973,6 +1783,254 @@ func TestClusterRepoGC(t *testing.T) {
 
 }
 
+func TestClusterStatusChanges(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	since := time.Now()
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal("pin should have worked:", err)
+	}
+	pinDelay()
+
+	changes, err := cl.StatusChanges(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Until.Before(since) {
+		t.Error("until should be after since")
+	}
+	if len(changes.Changes) != 1 || !changes.Changes[0].Cid.Equals(test.Cid1) {
+		t.Fatal("expected the change of the pinned cid")
+	}
+	pinfo := changes.Changes[0].PeerMap[peer.IDB58Encode(cl.id)]
+	if pinfo == nil || pinfo.Status != api.TrackerStatusPinned {
+		t.Error("expected the cid to be pinned in the peer")
+	}
+
+	changes, err = cl.StatusChanges(ctx, changes.Until)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Changes) != 0 {
+		t.Error("expected no changes")
+	}
+
+	_, err = cl.StatusChanges(ctx, since.Add(-time.Hour))
+	if err == nil {
+		t.Error("expected an error for changes before the peer started")
+	}
+}
+
+func TestClusterPrefetch(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	results, err := cl.Prefetch(ctx, test.Cid1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Peer != cl.id || results[0].Error != "" {
+		t.Error("expected a successful prefetch in the only peer")
+	}
+
+	results, err = cl.Prefetch(ctx, test.ErrorCid, []peer.ID{cl.id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Error == "" {
+		t.Error("expected a prefetch error")
+	}
+}
+
+func TestClusterPublishPinset(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.IPNSPublishKey = "pinset"
+	for _, c := range []cid.Cid{test.Cid1, test.Cid2, test.Cid3} {
+		_, err := cl.Pin(ctx, c, api.PinOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root, name, err := cl.publishPinset(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != test.PeerID1.Pretty() {
+		t.Error("unexpected ipns name")
+	}
+	published, ok := ipfs.ipns.Load("pinset")
+	if !ok || !published.(cid.Cid).Equals(root) {
+		t.Fatal("the pinset root was not published under the key")
+	}
+	if _, ok := ipfs.pins.Load(root.String()); !ok {
+		t.Error("the pinset root should be pinned")
+	}
+
+	data, err := ipfs.BlockGet(ctx, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootNode, err := cbor.Decode(data, mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count, _, err := rootNode.Resolve([]string{"count"})
+	if err != nil || fmt.Sprint(count) != "3" {
+		t.Errorf("unexpected pin count in the pinset root: %v", count)
+	}
+	if len(rootNode.Links()) != 1 {
+		t.Fatal("expected a single leaf in the pinset DAG")
+	}
+	if _, err := ipfs.BlockGet(ctx, rootNode.Links()[0].Cid); err != nil {
+		t.Error("the pinset leaf was not written")
+	}
+
+	// An unchanged pinset is published with the same root.
+	root2, _, err := cl.publishPinset(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !root2.Equals(root) {
+		t.Error("the root should not change when the pinset does not")
+	}
+
+	_, err = cl.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root3, _, err := cl.publishPinset(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root3.Equals(root) {
+		t.Error("the root should change with the pinset")
+	}
+	if _, ok := ipfs.pins.Load(root.String()); ok {
+		t.Error("the previous pinset root should have been unpinned")
+	}
+}
+
+func TestClusterStateCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	for _, c := range []cid.Cid{test.Cid1, test.Cid2} {
+		_, err := cl.Pin(ctx, c, api.PinOptions{Name: "pin-" + c.String()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkpoint, err := cl.StateCheckpoint(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Metadata[StateCheckpointMetaKey] != "2" || checkpoint.ReplicationFactorMin != -1 {
+		t.Errorf("unexpected checkpoint pin: %+v", checkpoint)
+	}
+	pinDelay()
+	if _, ok := ipfs.pins.Load(checkpoint.Cid.String()); !ok {
+		t.Error("the checkpoint should be pinned")
+	}
+
+	checkpoints, err := cl.StateCheckpoints(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checkpoints) != 1 || !checkpoints[0].Cid.Equals(checkpoint.Cid) {
+		t.Error("expected the checkpoint to be listed")
+	}
+
+	pins, err := cl.StateCheckpointPins(ctx, checkpoint.Cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 {
+		t.Fatalf("expected 2 pins in the checkpoint, got %d", len(pins))
+	}
+	for _, pin := range pins {
+		if pin.Name != "pin-"+pin.Cid.String() {
+			t.Error("the pins were not restored with their options")
+		}
+	}
+
+	// Other DAGs are not checkpoints
+	_, err = cl.StateCheckpointPins(ctx, test.Cid1)
+	if err == nil {
+		t.Error("expected an error reading a cid which is not a checkpoint")
+	}
+}
+
+func TestClusterProvideStrategy(t *testing.T) {
+	ctx := context.Background()
+	cl, _, ipfs, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	if ps := cl.ProvideStrategy(ctx); ps.Strategy != "" {
+		t.Error("no provide strategy should be set by default")
+	}
+
+	err := cl.SetProvideStrategy(ctx, &api.ProvideStrategy{Strategy: "some"})
+	if err == nil {
+		t.Error("expected an error with an unknown strategy")
+	}
+
+	err = cl.SetProvideStrategy(ctx, &api.ProvideStrategy{
+		Strategy: api.ProvidePinned,
+		Peers:    []peer.ID{test.PeerID2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp, ok := ipfs.reprovider.Load().(api.IPFSReprovider)
+	if !ok || rp.Strategy != api.ProvidePinned || rp.Interval != 0 {
+		t.Errorf("the reprovider should be disabled in peers not given: %+v", rp)
+	}
+
+	err = cl.SetProvideStrategy(ctx, &api.ProvideStrategy{
+		Strategy: api.ProvideRoots,
+		Peers:    []peer.ID{cl.id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp = ipfs.reprovider.Load().(api.IPFSReprovider)
+	if rp.Strategy != api.ProvideRoots || rp.Interval != reprovideInterval {
+		t.Errorf("unexpected reprovider settings: %+v", rp)
+	}
+
+	// The strategy is persisted
+	cl.provideStrategy = nil
+	if err := cl.loadProvideStrategy(); err != nil {
+		t.Fatal(err)
+	}
+	if ps := cl.ProvideStrategy(ctx); ps.Strategy != api.ProvideRoots {
+		t.Error("the provide strategy was not persisted")
+	}
+
+	err = cl.SetProvideStrategy(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps := cl.ProvideStrategy(ctx); ps.Strategy != "" {
+		t.Error("the provide strategy should be cleared")
+	}
+}
+
 func TestClusterRepoGCLocal(t *testing.T) {
 	ctx := context.Background()
 	cl, _, _, _ := testingCluster(t)
@@ -1007,3 +2065,1242 @@ func testRepoGC(t *testing.T, repoGC *api.RepoGC) {
 		t.Errorf("expected a different cid, expected: %s, found: %s", test.Cid1, repoGC.Keys[0].Key)
 	}
 }
+
+func TestClusterSetLogLevel(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	err := cl.SetLogLevel(ctx, "consensus", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.SetLogLevel(ctx, "*", logLevel)
+
+	err = cl.SetLogLevel(ctx, "cluster", "verbose")
+	if err == nil {
+		t.Error("expected an error with a bad level")
+	}
+
+	err = cl.SetLogLevel(ctx, "nonexistent", "debug")
+	if err == nil {
+		t.Error("expected an error with an unknown facility")
+	}
+}
+
+type protectConnMgr struct {
+	connmgr.NullConnMgr
+	protected map[peer.ID]string
+}
+
+func (cm *protectConnMgr) Protect(p peer.ID, tag string) {
+	cm.protected[p] = tag
+}
+
+func (cm *protectConnMgr) Unprotect(p peer.ID, tag string) bool {
+	delete(cm.protected, p)
+	return false
+}
+
+func TestClusterProtectPeers(t *testing.T) {
+	ctx := context.Background()
+	cm := &protectConnMgr{protected: make(map[peer.ID]string)}
+	h, err := libp2p.New(ctx, libp2p.ConnectionManager(cm))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	c := &Cluster{host: h, id: h.ID()}
+	protected := c.protectPeers([]peer.ID{h.ID(), test.PeerID1, test.PeerID2}, nil)
+	if len(cm.protected) != 2 || len(protected) != 2 {
+		t.Fatal("expected 2 protected peers")
+	}
+	if _, ok := cm.protected[h.ID()]; ok {
+		t.Error("our own peer should not be protected")
+	}
+
+	c.protectPeers([]peer.ID{test.PeerID2, test.PeerID3}, protected)
+	if _, ok := cm.protected[test.PeerID1]; ok {
+		t.Error("removed peer should not be protected anymore")
+	}
+	if cm.protected[test.PeerID3] != connMgrProtectTag {
+		t.Error("new peer should be protected")
+	}
+}
+
+func TestClusterRepinProgress(t *testing.T) {
+	ctx := context.Background()
+	c := &Cluster{
+		config:    &Config{RepinRateLimit: 600}, // one every 100ms
+		repins:    make(map[peer.ID]*api.RepinProgress),
+		downSince: make(map[peer.ID]time.Time),
+	}
+
+	since := c.markPeerDown(test.PeerID2, time.Now())
+	if s := c.markPeerDown(test.PeerID2, time.Now()); !s.Equal(since) {
+		t.Error("the time since the peer is down should not change")
+	}
+
+	progress, ok := c.startRepin(test.PeerID2, repinReasonDown, since)
+	if !ok {
+		t.Fatal("expected re-allocation to start")
+	}
+	if _, ok := c.startRepin(test.PeerID2, repinReasonDown, since); ok {
+		t.Error("re-allocation should not start twice for the same failure")
+	}
+
+	c.repinPins(ctx, progress, nil)
+	local := c.RepinProgressLocal(ctx)
+	if len(local) != 1 || local[0].Peer != test.PeerID2 || !local[0].Done {
+		t.Error("expected a finished progress report")
+	}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := c.waitRepin(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Error("re-allocations should be rate limited")
+	}
+}
+
+func TestClusterScheduleRecover(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.ScheduleRecover(ctx, &api.RecoverSchedule{Interval: time.Second})
+	if err == nil {
+		t.Fatal("expected an error without max attempts")
+	}
+
+	sched, err := cl.ScheduleRecover(ctx, &api.RecoverSchedule{
+		Local:       true,
+		Interval:    100 * time.Millisecond,
+		MaxAttempts: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is in error, so the first attempt finishes the schedule.
+	time.Sleep(time.Second)
+	scheds := cl.RecoverSchedules(ctx)
+	if len(scheds) != 1 || scheds[0].ID != sched.ID {
+		t.Fatal("expected the registered schedule")
+	}
+	if !scheds[0].Done || scheds[0].Attempts != 1 || scheds[0].Errored != 0 {
+		t.Errorf("unexpected schedule state: %+v", scheds[0])
+	}
+
+	err = cl.CancelRecoverSchedule(ctx, sched.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cl.RecoverSchedules(ctx)) != 0 {
+		t.Error("the schedule should have been removed")
+	}
+	if cl.CancelRecoverSchedule(ctx, sched.ID) == nil {
+		t.Error("expected an error cancelling an unknown schedule")
+	}
+}
+
+func TestClusterRecoverSchedulesPersisted(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	sched, err := cl.ScheduleRecover(ctx, &api.RecoverSchedule{
+		Local:       true,
+		Interval:    time.Hour,
+		MaxAttempts: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	// Forget the schedules as if the peer had been restarted.
+	cl.recoversMux.Lock()
+	cl.recovers = make(map[string]*recoverSchedule)
+	cl.nextRecoverID = 0
+	cl.recoversMux.Unlock()
+
+	err = cl.loadRecoverSchedules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheds := cl.RecoverSchedules(ctx)
+	if len(scheds) != 1 || scheds[0].ID != sched.ID || !scheds[0].Done {
+		t.Fatal("expected the finished schedule to be loaded")
+	}
+
+	next, err := cl.ScheduleRecover(ctx, &api.RecoverSchedule{
+		Interval:    time.Hour,
+		MaxAttempts: 3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID == sched.ID {
+		t.Error("schedule IDs should not be reused")
+	}
+}
+
+func TestClusterJobs(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.SubmitJob(ctx, &api.Job{Kind: "rebalance"})
+	if err == nil {
+		t.Error("expected an error with an unknown job kind")
+	}
+
+	pins := []*api.Pin{
+		api.PinCid(test.Cid1),
+		api.PinCid(test.Cid2),
+	}
+	j, err := cl.SubmitJob(ctx, &api.Job{Kind: api.JobImport, Pins: pins})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != api.JobQueued || j.Total != 2 {
+		t.Error("expected a queued job with 2 items")
+	}
+	b, err := cl.localDatastore(jobsDatastore).Get(ds.NewKey(j.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte(`"pins"`)) {
+		t.Error("the pins should be stored apart from the job")
+	}
+
+	time.Sleep(time.Second)
+
+	j, err = cl.Job(ctx, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status != api.JobDone || j.Progress != 2 || j.Failed != 0 {
+		t.Fatalf("expected a finished import job: %+v", j)
+	}
+	for _, p := range pins {
+		if _, err := cl.PinGet(ctx, p.Cid); err != nil {
+			t.Error("expected the imported items to be pinned")
+		}
+	}
+
+	err = cl.CancelJob(ctx, j.ID)
+	if err != errJobFinished {
+		t.Error("expected an error cancelling a finished job")
+	}
+	for i := range pins {
+		if has, _ := cl.localDatastore(jobPinsDatastore).Has(jobPinKey(j.ID, i)); has {
+			t.Error("the pins of finished jobs should be removed")
+		}
+	}
+
+	// Forget the jobs as if the peer had been restarted.
+	cl.jobsMux.Lock()
+	cl.jobs = make(map[string]*job)
+	cl.nextJobID = 0
+	cl.jobsMux.Unlock()
+
+	err = cl.loadJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := cl.Jobs(ctx)
+	if len(jobs) != 1 || jobs[0].ID != j.ID || jobs[0].Status != api.JobDone {
+		t.Fatal("expected the finished job to be loaded")
+	}
+
+	next, err := cl.SubmitJob(ctx, &api.Job{Kind: api.JobShardsGC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID == j.ID {
+		t.Error("job IDs should not be reused")
+	}
+	time.Sleep(time.Second)
+
+	// Finished jobs are removed after JobRetention, but their IDs are
+	// not reused.
+	cl.config.JobRetention = time.Millisecond
+	if jobs := cl.Jobs(ctx); len(jobs) != 0 {
+		t.Fatalf("expected the finished jobs to be removed: %+v", jobs)
+	}
+	cl.jobsMux.Lock()
+	cl.jobs = make(map[string]*job)
+	cl.nextJobID = 0
+	cl.jobsMux.Unlock()
+	err = cl.loadJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cl.Jobs(ctx)) != 0 {
+		t.Error("removed jobs should not be loaded")
+	}
+	last, err := cl.SubmitJob(ctx, &api.Job{Kind: api.JobShardsGC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID == j.ID || last.ID == next.ID {
+		t.Error("the IDs of removed jobs should not be reused")
+	}
+}
+
+func TestClusterPeerMaintenance(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	err := cl.PeerMaintenance(ctx, &api.PeerMaintenance{Repin: true})
+	if err == nil {
+		t.Error("expected an error re-allocating pins when disabling maintenance")
+	}
+
+	err = cl.PeerMaintenance(ctx, &api.PeerMaintenance{Peer: cl.id, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cl.ID(ctx).Maintenance {
+		t.Error("the peer should be in maintenance")
+	}
+
+	time.Sleep(time.Second)
+
+	opts := api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	}
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err == nil {
+		t.Error("a peer in maintenance should not receive new allocations")
+	}
+
+	// Forget the mode as if the peer had been restarted.
+	cl.maintenanceMux.Lock()
+	cl.maintenance = false
+	cl.maintenanceMux.Unlock()
+
+	err = cl.loadMaintenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cl.inMaintenance() {
+		t.Fatal("the maintenance mode should be persisted")
+	}
+
+	err = cl.PeerMaintenance(ctx, &api.PeerMaintenance{Enabled: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterPeerActivate(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	// Set standby as if configured.
+	cl.config.Standby = true
+	err := cl.loadStandby()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cl.ID(ctx).Standby {
+		t.Fatal("the peer should be in standby")
+	}
+	cl.sendPingMetric(ctx)
+	cl.sendInformersMetrics(ctx)
+
+	opts := api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	}
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err == nil {
+		t.Error("a peer in standby should not receive allocations")
+	}
+
+	err = cl.PeerActivate(ctx, cl.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl.inStandby() {
+		t.Error("the peer should be active")
+	}
+
+	// The activation survives restarts.
+	err = cl.loadStandby()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl.inStandby() {
+		t.Error("the activation should be persisted")
+	}
+
+	time.Sleep(time.Second)
+
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterAllocationExclusions(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.sendPingMetric(ctx)
+	cl.sendInformersMetrics(ctx)
+	time.Sleep(time.Second)
+
+	opts := api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+	}
+
+	err := cl.SetAllocationExclusions(ctx, &api.AllocationExclusions{
+		Peers: []peer.ID{cl.id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex := cl.AllocationExclusions(ctx); len(ex.Peers) != 1 || ex.Peers[0] != cl.id {
+		t.Fatalf("unexpected exclusions: %+v", ex)
+	}
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err == nil {
+		t.Error("an excluded peer should not receive allocations")
+	}
+
+	// Exclusions survive restarts.
+	cl.exclusions = nil
+	err = cl.loadAllocationExclusions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cl.AllocationExclusions(ctx).IsEmpty() {
+		t.Error("the exclusions should be persisted")
+	}
+
+	err = cl.SetAllocationExclusions(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Exclusions by tag, from the configuration.
+	cl.config.Tags = map[string]string{"rack": "old"}
+	cl.config.AllocationExclusions = &api.AllocationExclusions{
+		Tags: map[string][]string{"rack": {"old"}},
+	}
+	_, err = cl.Pin(ctx, test.Cid2, opts)
+	if err == nil {
+		t.Error("a peer excluded by tag should not receive allocations")
+	}
+
+	// Excluded peers keep their current pins.
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClusterPinAdmission(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another peer reports its queue.
+	m := &api.Metric{
+		Name:              pingMetricName,
+		Peer:              test.PeerID2,
+		Valid:             true,
+		PendingOperations: 5,
+	}
+	m.SetTTL(time.Minute)
+	err = cl.monitor.LogMetric(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cl.config.AdmissionMaxQueued = 5
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if api.ErrorCodeOf(err) != api.ErrCodeOverloaded {
+		t.Fatalf("expected an overloaded error, got %v", err)
+	}
+	if api.RetryAfterOf(err) != cl.config.MonitorPingInterval {
+		t.Error("the error should tell when to retry")
+	}
+
+	// Existing pins are admitted.
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Pins wait to be admitted.
+	cl.config.AdmissionWait = 2 * time.Second
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		idle := &api.Metric{
+			Name:  pingMetricName,
+			Peer:  test.PeerID2,
+			Valid: true,
+		}
+		idle.SetTTL(time.Minute)
+		cl.monitor.LogMetric(ctx, idle)
+	}()
+	_, err = cl.Pin(ctx, test.Cid2, api.PinOptions{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// pendingTracker is a PinTracker reporting a given number of pending
+// operations.
+type pendingTracker struct {
+	PinTracker
+	pending int32
+}
+
+func (pt *pendingTracker) PendingOperations(ctx context.Context) int {
+	return int(atomic.LoadInt32(&pt.pending))
+}
+
+func TestClusterDrain(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	tracker := &pendingTracker{PinTracker: cl.tracker}
+	cl.tracker = tracker
+	cl.config.ShutdownDrainTimeout = 5 * time.Second
+
+	// Ongoing writes are awaited.
+	if err := cl.startWrite(); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		cl.writesWg.Done()
+	}()
+	start := time.Now()
+	cl.drain(ctx)
+	if time.Since(start) < 500*time.Millisecond {
+		t.Error("drain should wait for the ongoing writes")
+	}
+	if err := cl.startWrite(); err != errDraining {
+		t.Error("writes should be rejected once draining")
+	}
+	if _, err := cl.Pin(ctx, test.Cid1, api.PinOptions{}); api.ErrorCodeOf(err) != api.ErrCodeShuttingDown {
+		t.Errorf("expected a shutting down error, got %v", err)
+	}
+
+	// Pending operations are awaited.
+	atomic.StoreInt32(&tracker.pending, 1)
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		atomic.StoreInt32(&tracker.pending, 0)
+	}()
+	start = time.Now()
+	cl.drain(ctx)
+	if time.Since(start) < 500*time.Millisecond {
+		t.Error("drain should wait for the pending operations")
+	}
+
+	// The timeout is honoured.
+	atomic.StoreInt32(&tracker.pending, 1)
+	cl.config.ShutdownDrainTimeout = time.Second
+	start = time.Now()
+	cl.drain(ctx)
+	if d := time.Since(start); d < time.Second || d > 3*time.Second {
+		t.Errorf("drain should give up after the timeout (took %s)", d)
+	}
+	atomic.StoreInt32(&tracker.pending, 0)
+}
+
+func TestClusterDrainAdmission(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	// Another peer reports a full queue, so new pins wait to be admitted.
+	m := &api.Metric{
+		Name:              pingMetricName,
+		Peer:              test.PeerID2,
+		Valid:             true,
+		PendingOperations: 5,
+	}
+	m.SetTTL(time.Minute)
+	if err := cl.monitor.LogMetric(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	cl.config.AdmissionMaxQueued = 5
+	cl.config.AdmissionWait = time.Minute
+	cl.config.ShutdownDrainTimeout = 30 * time.Second
+
+	pinErr := make(chan error, 1)
+	go func() {
+		_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+		pinErr <- err
+	}()
+	time.Sleep(500 * time.Millisecond)
+
+	// Draining does not wait for AdmissionWait.
+	start := time.Now()
+	cl.drain(ctx)
+	if time.Since(start) > 5*time.Second {
+		t.Error("drain should not wait for pins waiting to be admitted")
+	}
+	select {
+	case err := <-pinErr:
+		if api.ErrorCodeOf(err) != api.ErrCodeShuttingDown {
+			t.Errorf("expected a shutting down error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the pin should have been rejected")
+	}
+}
+
+func TestClusterPinSizePrecheck(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.SizePrecheckTimeout = time.Second
+	logFree := func(free string) {
+		m := &api.Metric{
+			Name:  freeSpaceMetricName,
+			Peer:  cl.id,
+			Value: free,
+			Valid: true,
+		}
+		m.SetTTL(time.Minute)
+		err := cl.monitor.LogMetric(ctx, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The mock connector sizes everything as 1000 bytes.
+	logFree("500")
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if api.ErrorCodeOf(err) != api.ErrCodeNoSpace {
+		t.Fatalf("expected a no space error, got %v", err)
+	}
+
+	logFree("2000")
+	pin, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Size != 1000 {
+		t.Errorf("expected the pin to be sized: %d", pin.Size)
+	}
+
+	if !fitsFreeSpace(nil, test.PeerID2, 1000) {
+		t.Error("peers with unknown free space should fit anything")
+	}
+	free := map[peer.ID]uint64{test.PeerID2: 500}
+	if fitsFreeSpace(free, test.PeerID2, 1000) {
+		t.Error("the content should not fit")
+	}
+}
+
+func TestClusterPinStatusMetric(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	err = cl.rebuildPinIndex(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := cl.sendPinStatusMetric(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil || m.PinStatus == nil {
+		t.Fatal("expected a pin status metric")
+	}
+	if m.PinStatus.Pinned != 1 || m.Value != "1" {
+		t.Errorf("expected 1 pinned item: %+v", m.PinStatus)
+	}
+
+	// The metric is received through pubsub.
+	time.Sleep(time.Second)
+
+	peers := cl.Peers(ctx)
+	if len(peers) != 1 {
+		t.Fatal("expected 1 peer")
+	}
+	if peers[0].PinStatus == nil || peers[0].PinStatus.Pinned != 1 {
+		t.Error("peers should include their pin status")
+	}
+}
+
+func TestClusterPinStorageClass(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.StorageClass = "ssd"
+	cl.sendPingMetric(ctx)
+	cl.sendInformersMetrics(ctx)
+	time.Sleep(time.Second)
+
+	opts := api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+		StorageClass:         "archive",
+	}
+	_, err := cl.Pin(ctx, test.Cid1, opts)
+	if err == nil {
+		t.Error("expected an error without peers of the storage class")
+	}
+
+	opts.StorageClass = "ssd"
+	pin, err := cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pin.Allocations) != 1 || pin.Allocations[0] != cl.id {
+		t.Error("expected the pin to be allocated to the ssd peer")
+	}
+}
+
+func TestClusterPinPeerGroup(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	cl.config.PeerGroups = map[string][]peer.ID{
+		"hot":     {cl.id},
+		"archive": {test.PeerID2},
+	}
+	cl.sendPingMetric(ctx)
+	cl.sendInformersMetrics(ctx)
+	time.Sleep(time.Second)
+
+	opts := api.PinOptions{
+		ReplicationFactorMin: 1,
+		ReplicationFactorMax: 1,
+		PeerGroup:            "archive",
+	}
+	_, err := cl.Pin(ctx, test.Cid1, opts)
+	if err == nil {
+		t.Error("expected an error without available peers in the group")
+	}
+
+	opts.PeerGroup = "cold"
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err == nil {
+		t.Error("expected an error with an unknown peer group")
+	}
+
+	opts.PeerGroup = "hot"
+	pin, err := cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pin.Allocations) != 1 || pin.Allocations[0] != cl.id {
+		t.Error("expected the pin to be allocated to the hot peer")
+	}
+	if len(pin.GroupPeers) != 1 || pin.GroupPeers[0] != cl.id {
+		t.Error("expected the pin to store the peers of the group")
+	}
+
+	// Peers without the group in their configuration re-pin and
+	// re-allocate using the stored constraints.
+	cl.config.PeerGroups = nil
+	stored, err := cl.PinGet(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocs, err := cl.allocate(ctx, stored, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocs) != 1 || allocs[0] != cl.id {
+		t.Error("expected allocations within the stored group")
+	}
+	_, err = cl.Pin(ctx, test.Cid1, opts)
+	if err != nil {
+		t.Error("expected re-pinning with a stored group to work:", err)
+	}
+	_, err = cl.Pin(ctx, test.Cid2, opts)
+	if err == nil {
+		t.Error("expected an error with an unknown peer group")
+	}
+}
+
+func TestClusterCollections(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.Pin(ctx, test.Cid3, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	col := &api.Collection{
+		Name: "dataset",
+		Cids: []cid.Cid{test.Cid1, test.Cid3},
+	}
+	_, err = cl.PinCollection(ctx, col)
+	if err == nil {
+		t.Error("expected an error with a cid pinned outside the collection")
+	}
+
+	col.Cids = []cid.Cid{test.Cid1, test.Cid2}
+	_, err = cl.PinCollection(ctx, col)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pin, err := cl.PinGet(ctx, test.Cid2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Collection != "dataset" {
+		t.Error("expected the pin to be part of the collection")
+	}
+
+	cols, err := cl.Collections(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cols) != 1 || cols[0].Name != "dataset" || len(cols[0].Cids) != 2 {
+		t.Fatal("expected 1 collection with 2 cids")
+	}
+
+	gpis, err := cl.CollectionStatus(ctx, "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gpis) != 2 {
+		t.Error("expected the status of 2 cids")
+	}
+
+	_, err = cl.UnpinCollection(ctx, "dataset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cl.Collection(ctx, "dataset")
+	if err != errCollectionNotFound {
+		t.Error("expected the collection to be gone after unpinning it")
+	}
+	_, err = cl.PinGet(ctx, test.Cid3)
+	if err != nil {
+		t.Error("pins outside the collection should not be unpinned")
+	}
+}
+
+type mockConfigSaver struct {
+	saved map[string][]byte
+}
+
+func (s *mockConfigSaver) SaveComponentJSON(name string, raw []byte) error {
+	s.saved[name] = raw
+	return nil
+}
+
+func TestClusterPushConfig(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	diskCfg := &disk.Config{}
+	diskCfg.Default()
+	trackerCfg := &stateless.Config{}
+	trackerCfg.Default()
+	numpinCfg := &numpin.Config{}
+	numpinCfg.Default()
+	saver := &mockConfigSaver{saved: make(map[string][]byte)}
+
+	section := &api.ConfigSection{
+		Name:   "disk",
+		Config: []byte(`{"metric_ttl": "1m"}`),
+	}
+	err := cl.PushConfig(ctx, []*api.ConfigSection{section})
+	if err == nil {
+		t.Error("expected an error when pushing is not enabled")
+	}
+
+	cl.SetSharedConfigs(saver, diskCfg, trackerCfg, numpinCfg)
+	err = cl.PushConfig(ctx, []*api.ConfigSection{section})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := &disk.Config{}
+	err = saved.LoadJSON(saver.saved["disk"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.MetricTTL != time.Minute || saved.MetricType != diskCfg.MetricType {
+		t.Error("the pushed options should be saved, keeping the others")
+	}
+
+	section.Config = []byte(`{"metric_ttl": "0s"}`)
+	err = cl.PushConfig(ctx, []*api.ConfigSection{section})
+	if err == nil {
+		t.Error("expected an error with an invalid configuration")
+	}
+
+	section.Config = []byte(`{"metric_type": "reposize"}`)
+	err = cl.PushConfig(ctx, []*api.ConfigSection{section})
+	if err == nil {
+		t.Error("expected an error pushing an option which cannot be applied")
+	}
+
+	// Pushed options are applied by the running components.
+	err = cl.PushConfig(ctx, []*api.ConfigSection{{
+		Name:   "numpin",
+		Config: []byte(`{"metric_ttl": "1m"}`),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := cl.informers[0].GetMetric(ctx).GetTTL(); ttl < 50*time.Second {
+		t.Error("the pushed metric_ttl should be applied, got", ttl)
+	}
+
+	hooks := &api.ConfigSection{
+		Name:   "stateless",
+		Config: []byte(`{"hooks": [{"events": ["pinned"], "exec": ["touch", "/tmp/pwned"]}]}`),
+	}
+	err = cl.PushConfig(ctx, []*api.ConfigSection{hooks})
+	if err == nil {
+		t.Error("expected an error pushing hooks")
+	}
+	err = cl.PushConfigLocal(ctx, []*api.ConfigSection{hooks})
+	if err == nil {
+		t.Error("peers should reject pushed hooks")
+	}
+	if _, ok := saver.saved["stateless"]; ok {
+		t.Error("no tracker configuration should have been saved")
+	}
+
+	// The current section of a peer is pushed without its hooks.
+	trackerCfg.Hooks = []*stateless.Hook{{Events: []string{"pinned"}, Exec: []string{"true"}, Timeout: time.Second}}
+	err = cl.PushConfig(ctx, []*api.ConfigSection{{Name: "stateless"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cl.PushConfig(ctx, []*api.ConfigSection{{Name: "raft"}})
+	if err == nil {
+		t.Error("expected an error pushing a section which cannot be pushed")
+	}
+}
+
+func TestClusterNewOptions(t *testing.T) {
+	ctx := context.Background()
+	ident, clusterCfg, _, _, _, _, _, _, _, _, _, _ := testingConfigs()
+	host, _, _ := createHost(t, ident.PrivateKey, clusterCfg.Secret, clusterCfg.ListenAddr)
+	_, err := New(ctx, host, clusterCfg, WithIPFSConnector(&mockConnector{}))
+	if err == nil {
+		t.Error("expected an error without a consensus component")
+	}
+	host.Close()
+
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	if err := cl.Start(ctx); err == nil {
+		t.Error("expected an error starting the peer twice")
+	}
+	if err := cl.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+var (
+	_ Consensus     = (*test.MockConsensus)(nil)
+	_ IPFSConnector = (*test.MockConnector)(nil)
+	_ IPFSConnector = (*nullconn.Connector)(nil)
+	_ PinTracker    = (*test.MockPinTracker)(nil)
+	_ Informer      = (*test.MockInformer)(nil)
+	_ PeerMonitor   = (*test.MockPeerMonitor)(nil)
+)
+
+func TestClusterWithMockComponents(t *testing.T) {
+	ctx := context.Background()
+	ident, clusterCfg, _, _, _, _, _, _, _, _, _, _ := testingConfigs()
+	host, _, _ := createHost(t, ident.PrivateKey, clusterCfg.Secret, clusterCfg.ListenAddr)
+	clusterCfg.SetBaseDir(filepath.Join(testsFolder, host.ID().Pretty()))
+	defer cleanState()
+
+	ipfs := test.NewMockConnector(test.PeerID1)
+	cl, err := New(
+		ctx,
+		host,
+		clusterCfg,
+		WithConsensus(test.NewMockConsensus(host.ID())),
+		WithIPFSConnector(ipfs),
+		WithPinTracker(test.NewMockPinTracker(host.ID(), clusterCfg.Peername)),
+		WithPeerMonitor(test.NewMockPeerMonitor()),
+		WithInformers(test.NewMockInformer("numpin", "0", time.Minute)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cl.Shutdown(ctx)
+	if err := cl.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cl.WaitReady(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := ipfs.PinLsCid(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st != api.IPFSPinStatusRecursive {
+		t.Error("expected the mock connector to hold a recursive pin")
+	}
+
+	pinfo := cl.StatusLocal(ctx, test.Cid1)
+	if pinfo.Status != api.TrackerStatusPinned {
+		t.Error("expected the item to be pinned, got", pinfo.Status)
+	}
+
+	_, err = cl.Pin(ctx, test.ErrorCid, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinfo = cl.StatusLocal(ctx, test.ErrorCid)
+	if pinfo.Status != api.TrackerStatusPinError {
+		t.Error("expected a pin error, got", pinfo.Status)
+	}
+}
+
+func TestClusterStats(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	_, err := cl.Pin(ctx, test.Cid1, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	m := &api.Metric{
+		Name:  freeSpaceMetricName,
+		Peer:  cl.id,
+		Value: "2000",
+		Valid: true,
+	}
+	m.SetTTL(time.Minute)
+	if err := cl.monitor.LogMetric(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := cl.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Peer != cl.id || stats.Pins != 1 || stats.ConsensusPeers != 1 {
+		t.Errorf("unexpected cluster stats: %+v", stats)
+	}
+	if len(stats.Peers) != 1 {
+		t.Fatal("expected the stats of one peer")
+	}
+	ps := stats.Peers[0]
+	if ps.Peer != cl.id || ps.FreeSpace != 2000 || ps.Pinned != 1 || ps.Error != "" {
+		t.Errorf("unexpected peer stats: %+v", ps)
+	}
+}
+
+func TestClusterUpgrade(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	newer := version.Version
+	newer.Minor++
+	older := version.Version
+	older.Major = 0
+	older.Minor = 0
+	older.Patch = 1
+
+	err := cl.Upgrade(ctx, &api.UpgradeOptions{Version: newer.String()})
+	if err != errUpgradesDisabled {
+		t.Error("upgrades should be disabled without an UpgradeFunc")
+	}
+
+	var got []*api.UpgradeOptions
+	cl.SetUpgradeFunc(func(ctx context.Context, opts *api.UpgradeOptions) error {
+		got = append(got, opts)
+		return nil
+	})
+
+	refused := []*api.UpgradeOptions{
+		{Version: "not-a-version"},
+		{Version: older.String()},
+		{Version: version.Version.String()},
+	}
+	for _, opts := range refused {
+		if err := cl.Upgrade(ctx, opts); err == nil {
+			t.Errorf("upgrade to %s should have been refused", opts.Version)
+		}
+	}
+	if len(got) > 0 {
+		t.Fatal("UpgradeFunc should not have been called")
+	}
+
+	allowed := []*api.UpgradeOptions{
+		{Version: newer.String(), DistURL: "http://dist.example"},
+		{Version: older.String(), Force: true},
+	}
+	for _, opts := range allowed {
+		if err := cl.Upgrade(ctx, opts); err != nil {
+			t.Errorf("upgrade to %s: %s", opts.Version, err)
+		}
+	}
+	if len(got) != 2 || got[0].DistURL != "http://dist.example" || !got[1].Force {
+		t.Error("UpgradeFunc did not receive the upgrade options")
+	}
+
+	if err := cl.RollingUpgrade(ctx, &api.UpgradeOptions{Version: older.String()}); err == nil {
+		t.Error("a rolling downgrade should have been refused")
+	}
+}
+
+func TestClusterLeaderTaskers(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	lt := &mockLeaderTasker{}
+	cl.apis = append(cl.apis, lt)
+
+	var task *leaderTask
+	for _, tk := range cl.leaderTasks() {
+		if tk.name == "mock" {
+			tk := tk
+			task = &tk
+		}
+	}
+	if task == nil || task.interval != time.Minute {
+		t.Fatal("expected the task of the component to be scheduled")
+	}
+	if !cl.runLeaderTaskOnce(ctx, *task) || lt.runs != 1 {
+		t.Error("expected the single peer to run the task of the component")
+	}
+}
+
+// hungTracker is a PinTracker which does not answer until released.
+type hungTracker struct {
+	PinTracker
+	release chan struct{}
+}
+
+func (ht *hungTracker) PendingOperations(ctx context.Context) int {
+	<-ht.release
+	return 0
+}
+
+func TestClusterAlive(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	aliveCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := cl.Alive(aliveCtx); err != nil {
+		t.Fatal("peer should be alive:", err)
+	}
+
+	tracker := &hungTracker{PinTracker: cl.tracker, release: make(chan struct{})}
+	cl.tracker = tracker
+	defer close(tracker.release)
+
+	hungCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if err := cl.Alive(hungCtx); err != context.DeadlineExceeded {
+		t.Error("expected a hung peer not to be alive, got", err)
+	}
+}
+
+func TestClusterLocalPersisters(t *testing.T) {
+	ctx := context.Background()
+	cl, _, _, _ := testingCluster(t)
+	defer cleanState()
+	defer cl.Shutdown(ctx)
+
+	m := &api.Metric{
+		Name:  "persisted",
+		Peer:  test.PeerID1,
+		Value: "1",
+		Valid: true,
+	}
+	m.SetTTL(time.Minute)
+	if err := cl.monitor.LogMetric(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	key := ds.NewKey("/persisted").ChildString(peer.IDB58Encode(test.PeerID1))
+	if ok, err := cl.localDatastore(monitorDatastore).Has(key); err != nil || !ok {
+		t.Error("the monitor should persist metrics in its local datastore:", err)
+	}
+}
@@ -14,13 +14,13 @@ import (
 	crypto "github.com/libp2p/go-libp2p-crypto"
 	host "github.com/libp2p/go-libp2p-host"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
-	pnet "github.com/libp2p/go-libp2p-pnet"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	libp2pquic "github.com/libp2p/go-libp2p-quic-transport"
 	secio "github.com/libp2p/go-libp2p-secio"
 	libp2ptls "github.com/libp2p/go-libp2p-tls"
 	routedhost "github.com/libp2p/go-libp2p/p2p/host/routed"
 	identify "github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 func init() {
@@ -38,7 +38,8 @@ func init() {
 // the provided cluster configuration. Using that host, it creates pubsub and
 // a DHT instances, for shared use by all cluster components. The returned
 // host uses the DHT for routing. The resulting DHT is not bootstrapped. Relay
-// and AutoNATService are additionally setup for this host.
+// and AutoNATService are additionally setup for this host. NAT port mapping
+// and AutoRelay are enabled unless disabled in the configuration.
 func NewClusterHost(
 	ctx context.Context,
 	ident *config.Identity,
@@ -56,19 +57,33 @@ func NewClusterHost(
 	var err error
 	opts := []libp2p.Option{
 		libp2p.ListenAddrs(cfg.ListenAddr...),
-		libp2p.NATPortMap(),
 		libp2p.ConnectionManager(connman),
 		libp2p.Routing(func(h host.Host) (routing.PeerRouting, error) {
 			idht, err = newDHT(ctx, h)
 			return idht, err
 		}),
 		libp2p.EnableRelay(relayOpts...),
-		libp2p.EnableAutoRelay(),
 	}
 
-	prot, err := newProtector(cfg.Secret)
-	if err != nil {
-		return nil, nil, nil, err
+	if !cfg.DisableNATPortMap {
+		opts = append(opts, libp2p.NATPortMap())
+	}
+
+	if !cfg.DisableAutoRelay {
+		opts = append(opts, libp2p.EnableAutoRelay())
+	}
+
+	if len(cfg.AnnounceAddr) > 0 {
+		announce := cfg.AnnounceAddr
+		opts = append(opts, libp2p.AddrsFactory(func([]ma.Multiaddr) []ma.Multiaddr {
+			return announce
+		}))
+	}
+
+	var prot corepnet.Protector
+	if len(cfg.Secret) > 0 {
+		cfg.protector = newSecretProtector(cfg.Secret, cfg.TransitionSecret)
+		prot = cfg.protector
 	}
 
 	h, err := newHost(
@@ -133,9 +148,7 @@ func newProtector(secret []byte) (corepnet.Protector, error) {
 		return nil, nil
 	}
 
-	var key [32]byte
-	copy(key[:], secret)
-	return pnet.NewV1ProtectorFromBytes(&key)
+	return newSecretProtector(secret, nil), nil
 }
 
 func newDHT(ctx context.Context, h host.Host) (*dht.IpfsDHT, error) {
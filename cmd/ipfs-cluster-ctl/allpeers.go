@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+	cli "github.com/urfave/cli"
+)
+
+// globalConfig is the client configuration built from the global flags. It
+// is used as template to contact other peers with --all-peers.
+var globalConfig *client.Config
+
+func allPeersFlag() cli.BoolFlag {
+	return cli.BoolFlag{
+		Name:  "all-peers",
+		Usage: "query every cluster peer directly and compare the answers",
+	}
+}
+
+// peerResult holds the response given by a single peer when using
+// --all-peers.
+type peerResult struct {
+	Peer  peer.ID
+	Resp  interface{}
+	Error string
+}
+
+// peerIDTable holds the ID information reported by every peer with
+// --all-peers. It is printed as a comparison table.
+type peerIDTable []*api.ID
+
+// peerVersion is the version reported by a peer with --all-peers.
+type peerVersion struct {
+	Peer    peer.ID `json:"peer"`
+	Version string  `json:"version"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// peerAPIAddr returns the API address at which the given peer is contacted.
+// When using the libp2p endpoint, the peer's cluster addresses are used.
+// Otherwise, the peer is assumed to expose its HTTP API on the same port as
+// the contacted peer.
+func peerAPIAddr(id *api.ID) (ma.Multiaddr, error) {
+	p2p := client.IsPeerAddress(globalConfig.APIAddr)
+	var tcpAddr ma.Multiaddr
+	if !p2p {
+		port, err := globalConfig.APIAddr.ValueForProtocol(ma.P_TCP)
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine API port: %s", err)
+		}
+		tcpAddr, _ = ma.NewMultiaddr("/tcp/" + port)
+	}
+
+	for _, a := range id.Addresses {
+		addr := a.Value()
+		if manet.IsIPLoopback(addr) {
+			continue
+		}
+		if p2p {
+			return addr, nil
+		}
+		ipAddr, _ := ma.SplitFirst(addr)
+		if ipAddr == nil {
+			continue
+		}
+		return ma.Join(ipAddr, tcpAddr), nil
+	}
+	return nil, errors.New("peer has no usable addresses")
+}
+
+// forAllPeers calls f concurrently with a client for every cluster peer and
+// returns the results in the order of the peers list.
+func forAllPeers(ctx context.Context, f func(context.Context, client.Client) (interface{}, error)) []*peerResult {
+	ids, err := globalClient.Peers(ctx)
+	checkErr("listing cluster peers", err)
+	self, err := globalClient.ID(ctx)
+	checkErr("retrieving peer ID", err)
+
+	results := make([]*peerResult, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		results[i] = &peerResult{Peer: id.ID}
+		if id.Error != "" {
+			results[i].Error = id.Error
+			continue
+		}
+
+		wg.Add(1)
+		go func(r *peerResult, id *api.ID) {
+			defer wg.Done()
+
+			c := globalClient
+			if id.ID != self.ID {
+				addr, err := peerAPIAddr(id)
+				if err != nil {
+					r.Error = err.Error()
+					return
+				}
+				cfg := *globalConfig
+				cfg.APIAddr = addr
+				c, err = client.NewDefaultClient(&cfg)
+				if err != nil {
+					r.Error = err.Error()
+					return
+				}
+			}
+
+			resp, err := f(ctx, c)
+			if err != nil {
+				r.Error = err.Error()
+				return
+			}
+			r.Resp = resp
+		}(results[i], id)
+	}
+	wg.Wait()
+	return results
+}
+
+// allPeersID returns the ID information obtained from every peer.
+func allPeersID(ctx context.Context) peerIDTable {
+	results := forAllPeers(ctx, func(ctx context.Context, c client.Client) (interface{}, error) {
+		return c.ID(ctx)
+	})
+
+	ids := make(peerIDTable, 0, len(results))
+	for _, r := range results {
+		if r.Error != "" {
+			ids = append(ids, &api.ID{ID: r.Peer, Error: r.Error, IPFS: &api.IPFSID{}})
+			continue
+		}
+		ids = append(ids, r.Resp.(*api.ID))
+	}
+	return ids
+}
+
+// allPeersVersion returns the version reported by every peer.
+func allPeersVersion(ctx context.Context) []*peerVersion {
+	results := forAllPeers(ctx, func(ctx context.Context, c client.Client) (interface{}, error) {
+		return c.Version(ctx)
+	})
+
+	versions := make([]*peerVersion, 0, len(results))
+	for _, r := range results {
+		v := &peerVersion{Peer: r.Peer, Error: r.Error}
+		if r.Error == "" {
+			v.Version = r.Resp.(*api.Version).Version
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// allPeersStatus asks every peer for its local status and merges the
+// answers. Peers which cannot be contacted are reported with a
+// cluster_error status in every item.
+func allPeersStatus(ctx context.Context, ci cid.Cid, filter api.TrackerStatus) []*api.GlobalPinInfo {
+	results := forAllPeers(ctx, func(ctx context.Context, c client.Client) (interface{}, error) {
+		if ci != cid.Undef {
+			gpi, err := c.Status(ctx, ci, true)
+			if err != nil {
+				return nil, err
+			}
+			return []*api.GlobalPinInfo{gpi}, nil
+		}
+		return c.StatusAll(ctx, filter, true)
+	})
+
+	var gpis []*api.GlobalPinInfo
+	byCid := make(map[string]*api.GlobalPinInfo)
+	for _, r := range results {
+		if r.Error != "" {
+			continue
+		}
+		for _, gpi := range r.Resp.([]*api.GlobalPinInfo) {
+			merged, ok := byCid[gpi.Cid.String()]
+			if !ok {
+				merged = &api.GlobalPinInfo{
+					Cid:     gpi.Cid,
+					PeerMap: make(map[string]*api.PinInfo),
+				}
+				byCid[gpi.Cid.String()] = merged
+				gpis = append(gpis, merged)
+			}
+			for k, pinfo := range gpi.PeerMap {
+				merged.PeerMap[k] = pinfo
+			}
+		}
+	}
+
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		out("error contacting %s: %s\n", r.Peer.Pretty(), r.Error)
+		for _, gpi := range gpis {
+			gpi.PeerMap[peer.IDB58Encode(r.Peer)] = &api.PinInfo{
+				Cid:    gpi.Cid,
+				Peer:   r.Peer,
+				Status: api.TrackerStatusClusterError,
+				Error:  r.Error,
+			}
+		}
+	}
+	return gpis
+}
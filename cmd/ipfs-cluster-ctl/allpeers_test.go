@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestPeerAPIAddr(t *testing.T) {
+	oldCfg := globalConfig
+	defer func() { globalConfig = oldCfg }()
+
+	id := &api.ID{
+		ID: test.PeerID2,
+		Addresses: []api.Multiaddr{
+			api.NewMultiaddrWithValue(ma.StringCast("/ip4/127.0.0.1/tcp/9096/p2p/" + peer.IDB58Encode(test.PeerID2))),
+			api.NewMultiaddrWithValue(ma.StringCast("/ip4/192.168.1.2/tcp/9096/p2p/" + peer.IDB58Encode(test.PeerID2))),
+		},
+	}
+
+	globalConfig = &client.Config{APIAddr: ma.StringCast("/ip4/127.0.0.1/tcp/9094")}
+	addr, err := peerAPIAddr(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr.String() != "/ip4/192.168.1.2/tcp/9094" {
+		t.Error("expected the peer IP with the API port, got", addr)
+	}
+
+	globalConfig = &client.Config{APIAddr: ma.StringCast("/ip4/127.0.0.1/tcp/9096/p2p/" + peer.IDB58Encode(test.PeerID1))}
+	addr, err = peerAPIAddr(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !addr.Equal(id.Addresses[1].Value()) {
+		t.Error("expected the peer cluster address, got", addr)
+	}
+
+	_, err = peerAPIAddr(&api.ID{ID: test.PeerID2, Addresses: id.Addresses[:1]})
+	if err == nil {
+		t.Error("expected an error for a peer with loopback addresses only")
+	}
+}
+
+func TestAllPeersStatus(t *testing.T) {
+	fc := &fakeClient{
+		self: test.PeerID1,
+		peers: []*api.ID{
+			{ID: test.PeerID1},
+			{ID: test.PeerID2, Error: "unreachable"},
+		},
+		pins: []*api.Pin{
+			api.PinCid(test.Cid1),
+			api.PinCid(test.Cid2),
+		},
+	}
+	defer setGlobalClient(fc)()
+
+	gpis := allPeersStatus(context.Background(), cid.Undef, api.TrackerStatusUndefined)
+	if len(gpis) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(gpis))
+	}
+	for _, gpi := range gpis {
+		if gpi.PeerMap[peer.IDB58Encode(test.PeerID1)].Status != api.TrackerStatusPinned {
+			t.Error("expected the status reported by the local peer")
+		}
+		pinfo, ok := gpi.PeerMap[peer.IDB58Encode(test.PeerID2)]
+		if !ok || pinfo.Status != api.TrackerStatusClusterError || pinfo.Error != "unreachable" {
+			t.Error("expected a cluster_error for the unreachable peer")
+		}
+	}
+}
+
+func TestAllPeersVersion(t *testing.T) {
+	fc := &fakeClient{
+		self: test.PeerID1,
+		peers: []*api.ID{
+			{ID: test.PeerID1},
+			{ID: test.PeerID2, Error: "unreachable"},
+		},
+	}
+	defer setGlobalClient(fc)()
+
+	versions := allPeersVersion(context.Background())
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+	if versions[0].Peer != test.PeerID1 || versions[0].Version != "0.0.1" {
+		t.Error("unexpected version for the local peer:", versions[0])
+	}
+	if versions[1].Peer != test.PeerID2 || versions[1].Error != "unreachable" {
+		t.Error("expected an error for the unreachable peer:", versions[1])
+	}
+}
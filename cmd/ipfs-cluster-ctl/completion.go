@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cli "github.com/urfave/cli"
+)
+
+// completionTimeout limits how long dynamic completions wait for the API,
+// so that a slow or unreachable peer does not block the shell.
+var completionTimeout = 3 * time.Second
+
+// maxCompletionCids is the maximum number of CIDs offered as completions.
+var maxCompletionCids = 200
+
+// The completion scripts call the program with the
+// --generate-bash-completion flag, which prints the candidates for the
+// current command line.
+const bashCompletion = `# bash completion for ipfs-cluster-ctl
+_ipfs_cluster_ctl_complete() {
+  local cur opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  if [[ "$cur" == "-"* ]]; then
+    opts=$( ${COMP_WORDS[@]:0:$COMP_CWORD} ${cur} --generate-bash-completion 2>/dev/null )
+  else
+    opts=$( ${COMP_WORDS[@]:0:$COMP_CWORD} --generate-bash-completion 2>/dev/null )
+  fi
+  COMPREPLY=( $(compgen -W "${opts}" -- ${cur}) )
+  return 0
+}
+
+complete -o bashdefault -o default -F _ipfs_cluster_ctl_complete ipfs-cluster-ctl
+`
+
+const zshCompletion = `#compdef ipfs-cluster-ctl
+# zsh completion for ipfs-cluster-ctl
+_ipfs_cluster_ctl_complete() {
+  local -a opts
+  opts=("${(@f)$(_CLI_ZSH_AUTOCOMPLETE_HACK=1 ${words[@]:0:#words[@]-1} --generate-bash-completion 2>/dev/null)}")
+  _describe 'values' opts
+  return
+}
+
+compdef _ipfs_cluster_ctl_complete ipfs-cluster-ctl
+`
+
+const fishCompletion = `# fish completion for ipfs-cluster-ctl
+function __ipfs_cluster_ctl_complete
+  set -l args (commandline -opc)
+  set -l cur (commandline -ct)
+  if string match -q -- '-*' $cur
+    eval $args $cur --generate-bash-completion 2>/dev/null
+  else
+    eval $args --generate-bash-completion 2>/dev/null
+  end
+end
+
+complete -c ipfs-cluster-ctl -f -a '(__ipfs_cluster_ctl_complete)'
+`
+
+func printCompletion(shell string) error {
+	switch shell {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		return errors.New("unsupported shell. Use bash, zsh or fish")
+	}
+	return nil
+}
+
+// completeFirstArg returns a BashCompleteFunc which offers the results of
+// the given function as candidates for the first argument of a command.
+// Flags are completed as usual.
+func completeFirstArg(f func(context.Context) []string) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		if len(os.Args) > 2 && strings.HasPrefix(os.Args[len(os.Args)-2], "-") {
+			cli.DefaultCompleteWithFlags(&c.Command)(c)
+			return
+		}
+		if c.NArg() > 0 || globalClient == nil {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+		defer cancel()
+		for _, s := range f(ctx) {
+			fmt.Println(s)
+		}
+	}
+}
+
+// completionPeers returns the IDs of the current cluster peers.
+func completionPeers(ctx context.Context) []string {
+	ids, err := globalClient.Peers(ctx)
+	if err != nil {
+		return nil
+	}
+
+	var peers []string
+	for _, id := range ids {
+		if id.Error != "" {
+			continue
+		}
+		peers = append(peers, id.ID.Pretty())
+	}
+	return peers
+}
+
+// completionCids returns the CIDs in the cluster pinset, up to
+// maxCompletionCids.
+func completionCids(ctx context.Context) []string {
+	pins, err := globalClient.Allocations(ctx, api.DataType|api.MetaType)
+	if err != nil {
+		return nil
+	}
+
+	var cids []string
+	for _, pin := range pins {
+		if len(cids) >= maxCompletionCids {
+			break
+		}
+		cids = append(cids, pin.Cid.String())
+	}
+	return cids
+}
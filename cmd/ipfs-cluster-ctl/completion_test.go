@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// fakeClient is a client.Client which answers from memory. Methods not
+// overridden panic.
+type fakeClient struct {
+	client.Client
+	self  peer.ID
+	peers []*api.ID
+	pins  []*api.Pin
+}
+
+func (fc *fakeClient) ID(ctx context.Context) (*api.ID, error) {
+	return &api.ID{ID: fc.self, IPFS: &api.IPFSID{}}, nil
+}
+
+func (fc *fakeClient) Peers(ctx context.Context) ([]*api.ID, error) {
+	return fc.peers, nil
+}
+
+func (fc *fakeClient) Version(ctx context.Context) (*api.Version, error) {
+	return &api.Version{Version: "0.0.1"}, nil
+}
+
+func (fc *fakeClient) Allocations(ctx context.Context, filter api.PinType) ([]*api.Pin, error) {
+	return fc.pins, nil
+}
+
+func (fc *fakeClient) StatusAll(ctx context.Context, filter api.TrackerStatus, local bool) ([]*api.GlobalPinInfo, error) {
+	var gpis []*api.GlobalPinInfo
+	for _, pin := range fc.pins {
+		gpis = append(gpis, &api.GlobalPinInfo{
+			Cid: pin.Cid,
+			PeerMap: map[string]*api.PinInfo{
+				peer.IDB58Encode(fc.self): {
+					Cid:    pin.Cid,
+					Peer:   fc.self,
+					Status: api.TrackerStatusPinned,
+				},
+			},
+		})
+	}
+	return gpis, nil
+}
+
+// setGlobalClient sets the global client and returns a function to restore
+// it.
+func setGlobalClient(c client.Client) func() {
+	old := globalClient
+	globalClient = c
+	return func() {
+		globalClient = old
+	}
+}
+
+func TestPrintCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		if err := printCompletion(shell); err != nil {
+			t.Errorf("%s: %s", shell, err)
+		}
+	}
+	if err := printCompletion("csh"); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
+
+func TestCompletionPeers(t *testing.T) {
+	fc := &fakeClient{
+		peers: []*api.ID{
+			{ID: test.PeerID1},
+			{ID: test.PeerID2, Error: "unreachable"},
+			{ID: test.PeerID3},
+		},
+	}
+	defer setGlobalClient(fc)()
+
+	peers := completionPeers(context.Background())
+	if len(peers) != 2 ||
+		peers[0] != peer.IDB58Encode(test.PeerID1) ||
+		peers[1] != peer.IDB58Encode(test.PeerID3) {
+		t.Error("expected the peers without errors, got", peers)
+	}
+}
+
+func TestCompletionCids(t *testing.T) {
+	fc := &fakeClient{
+		pins: []*api.Pin{
+			api.PinCid(test.Cid1),
+			api.PinCid(test.Cid2),
+			api.PinCid(test.Cid3),
+		},
+	}
+	defer setGlobalClient(fc)()
+
+	cids := completionCids(context.Background())
+	if len(cids) != 3 || cids[0] != test.Cid1.String() {
+		t.Error("expected all the cids, got", cids)
+	}
+
+	oldMax := maxCompletionCids
+	defer func() { maxCompletionCids = oldMax }()
+	maxCompletionCids = 2
+	cids = completionCids(context.Background())
+	if len(cids) != 2 {
+		t.Error("expected cids to be capped, got", cids)
+	}
+}
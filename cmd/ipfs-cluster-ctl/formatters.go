@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
@@ -16,6 +18,52 @@ import (
 	humanize "github.com/dustin/go-humanize"
 )
 
+// statusSummary holds the number of pin entries in each tracker status.
+type statusSummary map[string]int
+
+// newStatusSummary counts the entries in each status across all the given
+// items.
+func newStatusSummary(gpis []*api.GlobalPinInfo) statusSummary {
+	summary := make(statusSummary)
+	for _, gpi := range gpis {
+		for _, pinfo := range gpi.PeerMap {
+			summary[pinfo.Status.String()]++
+		}
+	}
+	return summary
+}
+
+// filterStatus removes from every GlobalPinInfo the entries whose status
+// does not match the given filter and, when a peer (peer ID or peer name) is
+// given, the entries not belonging to it. Items left without any entries
+// are dropped.
+func filterStatus(gpis []*api.GlobalPinInfo, filter api.TrackerStatus, p string) []*api.GlobalPinInfo {
+	if filter == api.TrackerStatusUndefined && p == "" {
+		return gpis
+	}
+
+	filtered := []*api.GlobalPinInfo{}
+	for _, gpi := range gpis {
+		peerMap := make(map[string]*api.PinInfo)
+		for k, pinfo := range gpi.PeerMap {
+			if p != "" && k != p && pinfo.PeerName != p {
+				continue
+			}
+			if !pinfo.Status.Match(filter) {
+				continue
+			}
+			peerMap[k] = pinfo
+		}
+		if len(peerMap) == 0 {
+			continue
+		}
+		selected := *gpi
+		selected.PeerMap = peerMap
+		filtered = append(filtered, &selected)
+	}
+	return filtered
+}
+
 type addedOutputQuiet struct {
 	*api.AddedOutput
 	quiet bool
@@ -67,6 +115,34 @@ func textFormatObject(resp interface{}) {
 		textFormatPrintError(resp.(*api.Error))
 	case *api.Metric:
 		textFormatPrintMetric(resp.(*api.Metric))
+	case *api.Alert:
+		textFormatPrintAlert(resp.(*api.Alert))
+	case *api.RepinProgress:
+		textFormatPrintRepinProgress(resp.(*api.RepinProgress))
+	case *api.ClusterStats:
+		textFormatPrintClusterStats(resp.(*api.ClusterStats))
+	case *api.PrefetchResult:
+		textFormatPrintPrefetchResult(resp.(*api.PrefetchResult))
+	case *api.TrackerOperation:
+		textFormatPrintTrackerOperation(resp.(*api.TrackerOperation))
+	case *api.QuotaUsage:
+		textFormatPrintQuotaUsage(resp.(*api.QuotaUsage))
+	case *api.RecoverSchedule:
+		textFormatPrintRecoverSchedule(resp.(*api.RecoverSchedule))
+	case *api.PinAck:
+		textFormatPrintPinAck(resp.(*api.PinAck))
+	case *api.Job:
+		textFormatPrintJob(resp.(*api.Job))
+	case *api.Collection:
+		textFormatPrintCollection(resp.(*api.Collection))
+	case *api.PinViewPage:
+		textFormatPrintPinViewPage(resp.(*api.PinViewPage))
+	case *api.AllocationExclusions:
+		textFormatPrintAllocationExclusions(resp.(*api.AllocationExclusions))
+	case *api.ProvideStrategy:
+		textFormatPrintProvideStrategy(resp.(*api.ProvideStrategy))
+	case *offlinePinset:
+		textFormatPrintOfflinePinset(resp.(*offlinePinset))
 	case []*api.ID:
 		for _, item := range resp.([]*api.ID) {
 			textFormatObject(item)
@@ -91,8 +167,54 @@ func textFormatObject(resp interface{}) {
 		for _, item := range resp.([]*api.Metric) {
 			textFormatObject(item)
 		}
+	case []*api.Alert:
+		for _, item := range resp.([]*api.Alert) {
+			textFormatObject(item)
+		}
+	case []*api.RepinProgress:
+		for _, item := range resp.([]*api.RepinProgress) {
+			textFormatObject(item)
+		}
+	case []*api.PinAck:
+		for _, item := range resp.([]*api.PinAck) {
+			textFormatObject(item)
+		}
+	case []*api.PrefetchResult:
+		for _, item := range resp.([]*api.PrefetchResult) {
+			textFormatObject(item)
+		}
+	case []*api.TrackerOperation:
+		for _, item := range resp.([]*api.TrackerOperation) {
+			textFormatObject(item)
+		}
+	case []*api.QuotaUsage:
+		for _, item := range resp.([]*api.QuotaUsage) {
+			textFormatObject(item)
+		}
+	case []*api.RecoverSchedule:
+		for _, item := range resp.([]*api.RecoverSchedule) {
+			textFormatObject(item)
+		}
+	case []*api.Job:
+		for _, item := range resp.([]*api.Job) {
+			textFormatObject(item)
+		}
+	case []*api.Collection:
+		for _, item := range resp.([]*api.Collection) {
+			textFormatObject(item)
+		}
 	case *api.GlobalRepoGC:
 		textFormatPrintGlobalRepoGC(resp.(*api.GlobalRepoGC))
+	case *api.PeerRemoveReport:
+		textFormatPrintPeerRemoveReport(resp.(*api.PeerRemoveReport))
+	case peerIDTable:
+		textFormatPrintPeerIDTable(resp.(peerIDTable))
+	case []*peerVersion:
+		textFormatPrintPeerVersions(resp.([]*peerVersion))
+	case statusSummary:
+		textFormatPrintStatusSummary(resp.(statusSummary))
+	case map[string]string:
+		textFormatPrintPeerNames(resp.(map[string]string))
 	case []string:
 		for _, item := range resp.([]string) {
 			textFormatObject(item)
@@ -108,11 +230,19 @@ func textFormatPrintID(obj *api.ID) {
 		return
 	}
 
+	maintenance := ""
+	if obj.Maintenance {
+		maintenance = " | MAINTENANCE"
+	}
+	if obj.Standby {
+		maintenance += " | STANDBY"
+	}
 	fmt.Printf(
-		"%s | %s | Sees %d other peers\n",
+		"%s | %s | Sees %d other peers%s\n",
 		obj.ID.Pretty(),
 		obj.Peername,
 		len(obj.ClusterPeers)-1,
+		maintenance,
 	)
 
 	addrs := make(sort.StringSlice, 0, len(obj.Addresses))
@@ -120,6 +250,12 @@ func textFormatPrintID(obj *api.ID) {
 		addrs = append(addrs, a.String())
 	}
 	addrs.Sort()
+	if health := peerHealthStr(obj); health != "" {
+		fmt.Printf("  > %s\n", health)
+	}
+	if ps := obj.PinStatus; ps != nil {
+		fmt.Printf("  > Pins: %s\n", pinStatusStr(ps))
+	}
 	fmt.Println("  > Addresses:")
 	for _, a := range addrs {
 		fmt.Printf("    - %s\n", a)
@@ -141,7 +277,16 @@ func textFormatPrintID(obj *api.ID) {
 }
 
 func textFormatPrintGPInfo(obj *api.GlobalPinInfo) {
-	fmt.Printf("%s :\n", obj.Cid)
+	if obj.Status != "" {
+		fmt.Printf("%s : %s\n", obj.Cid, strings.ToUpper(string(obj.Status)))
+	} else {
+		fmt.Printf("%s :\n", obj.Cid)
+	}
+	textFormatPrintPeerMap(obj)
+}
+
+// textFormatPrintPeerMap prints the status of the pin in every peer.
+func textFormatPrintPeerMap(obj *api.GlobalPinInfo) {
 	peers := make([]string, 0, len(obj.PeerMap))
 	for k := range obj.PeerMap {
 		peers = append(peers, k)
@@ -155,6 +300,9 @@ func textFormatPrintGPInfo(obj *api.GlobalPinInfo) {
 		} else {
 			fmt.Printf("    > %-20s : %s", k, strings.ToUpper(v.Status.String()))
 		}
+		if v.Local {
+			fmt.Printf(" (LOCAL)")
+		}
 		if v.Error != "" {
 			fmt.Printf(": %s", v.Error)
 		}
@@ -183,11 +331,51 @@ func textFormatPrintPin(obj *api.Pin) {
 	if obj.ReplicationFactorMin < 0 {
 		fmt.Printf("Repl. Factor: -1 | Allocations: [everywhere]")
 	} else {
-		sortAlloc := api.PeersToStrings(obj.Allocations)
+		sortAlloc := make([]string, 0, len(obj.Allocations))
+		for _, p := range obj.Allocations {
+			sortAlloc = append(sortAlloc, peerLabel(p, ""))
+		}
 		sort.Strings(sortAlloc)
 		fmt.Printf("Repl. Factor: %d--%d | Allocations: %s",
 			obj.ReplicationFactorMin, obj.ReplicationFactorMax,
 			sortAlloc)
+		if obj.Degraded() {
+			fmt.Printf(" (degraded)")
+		}
+	}
+	if obj.Policy != "" {
+		fmt.Printf(" | Policy: %s", obj.Policy)
+	}
+	if obj.Namespace != "" {
+		fmt.Printf(" | Namespace: %s", obj.Namespace)
+	}
+	if obj.Owner != "" {
+		fmt.Printf(" | Owner: %s", obj.Owner)
+	}
+	if obj.Priority {
+		fmt.Printf(" | Priority")
+	}
+	if obj.FetchRateLimit > 0 {
+		fmt.Printf(" | Fetch rate limit: %d blocks/s", obj.FetchRateLimit)
+	}
+	if obj.LocalPin {
+		fmt.Printf(" | Local pin")
+	}
+	if obj.Protected {
+		fmt.Printf(" | Protected")
+	}
+	if obj.StorageClass != "" {
+		fmt.Printf(" | Storage class: %s", obj.StorageClass)
+	}
+	if obj.Collection != "" {
+		fmt.Printf(" | Collection: %s", obj.Collection)
+	}
+	if len(obj.Origins) > 0 {
+		origins := make([]string, len(obj.Origins))
+		for i, o := range obj.Origins {
+			origins[i] = o.String()
+		}
+		fmt.Printf(" | Origins: %s", strings.Join(origins, ", "))
 	}
 	var recStr string
 	switch obj.MaxDepth {
@@ -222,14 +410,252 @@ func textFormatPrintAddedOutputQuiet(obj *addedOutputQuiet) {
 }
 
 func textFormatPrintMetric(obj *api.Metric) {
+	var skew string
+	if obj.SentAt != 0 {
+		skew = fmt.Sprintf(" | Clock skew: %s", obj.ClockSkew().Round(time.Millisecond))
+	}
+
 	if obj.Name == "freespace" {
 		u, err := strconv.ParseUint(obj.Value, 10, 64)
 		checkErr("parsing to uint64", err)
-		fmt.Printf("%s | freespace: %s | Expires in: %s\n", peer.IDB58Encode(obj.Peer), humanize.Bytes(u), humanize.Time(time.Unix(0, obj.Expire)))
+		fmt.Printf("%s | freespace: %s | Expires in: %s%s\n", peerLabel(obj.Peer, obj.Peername), humanize.Bytes(u), humanize.Time(time.Unix(0, obj.Expire)), skew)
+		return
+	}
+
+	if obj.Name == "pinstatus" && obj.PinStatus != nil {
+		fmt.Printf("%s | pinstatus: %s | Expires in: %s%s\n", peerLabel(obj.Peer, obj.Peername), pinStatusStr(obj.PinStatus), humanize.Time(time.Unix(0, obj.Expire)), skew)
+		return
+	}
+
+	fmt.Printf("%s | %s | Expires in: %s%s\n", peerLabel(obj.Peer, obj.Peername), obj.Name, humanize.Time(time.Unix(0, obj.Expire)), skew)
+}
+
+// peerHealthStr returns the round-trip time, the time of the last metric
+// and the IPFS version of a peer for the text output.
+func peerHealthStr(obj *api.ID) string {
+	var parts []string
+	if obj.RTT > 0 {
+		parts = append(parts, fmt.Sprintf("RTT: %s", obj.RTT.Round(time.Microsecond)))
+	}
+	if obj.LastMetric != 0 {
+		parts = append(parts, fmt.Sprintf("Last metric: %s", humanize.Time(time.Unix(0, obj.LastMetric))))
+	}
+	if v := ipfsVersion(obj); v != "" {
+		parts = append(parts, fmt.Sprintf("IPFS: %s", v))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// pinStatusStr returns the pin status summary of a peer for the text output.
+func pinStatusStr(ps *api.PinStatusSummary) string {
+	return fmt.Sprintf("%d pinned, %d pinning, %d queued, %d errors", ps.Pinned, ps.Pinning, ps.Queued, ps.Error)
+}
+
+// tagsStr returns the given peer tags, sorted, for the text output.
+func tagsStr(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	strs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		strs = append(strs, k+"="+v)
+	}
+	sort.Strings(strs)
+	return " | Tags: " + strings.Join(strs, ", ")
+}
+
+func textFormatPrintAlert(obj *api.Alert) {
+	fmt.Printf("%s | %s | Triggered %s%s\n", peerLabel(obj.Peer, obj.Peername), obj.MetricName, humanize.Time(obj.TriggeredAt), tagsStr(obj.Tags))
+}
+
+func textFormatPrintPrefetchResult(obj *api.PrefetchResult) {
+	if obj.Error != "" {
+		fmt.Printf("%s | %s | ERROR: %s\n", peerLabel(obj.Peer, ""), obj.Cid, obj.Error)
+		return
+	}
+	fmt.Printf("%s | %s | FETCHED\n", peerLabel(obj.Peer, ""), obj.Cid)
+}
+
+func textFormatPrintTrackerOperation(obj *api.TrackerOperation) {
+	fmt.Printf("%s | %s | %s | Created %s | Updated %s | Attempt %d",
+		obj.Cid,
+		strings.ToUpper(obj.Type),
+		strings.ToUpper(obj.Phase),
+		humanize.Time(obj.Created),
+		humanize.Time(obj.TS),
+		obj.Attempts,
+	)
+	if obj.Priority {
+		fmt.Printf(" | Priority")
+	}
+	if obj.Error != "" {
+		fmt.Printf(" | Error: %s", obj.Error)
+	}
+	fmt.Printf("\n")
+}
+
+func textFormatPrintRepinProgress(obj *api.RepinProgress) {
+	state := "in progress"
+	if obj.Done {
+		state = "done"
+	}
+	fmt.Printf("%s | %s | Started %s | %d/%d repinned, %d failed | %s\n",
+		peerLabel(obj.Peer, ""),
+		strings.ToUpper(obj.Reason),
+		humanize.Time(obj.Started),
+		obj.Repinned,
+		obj.Total,
+		obj.Failed,
+		state,
+	)
+}
+
+func textFormatPrintClusterStats(obj *api.ClusterStats) {
+	leader := "none"
+	if obj.Leader != "" {
+		leader = peerLabel(obj.Leader, "")
+	}
+	fmt.Printf("Pins: %d | Consensus peers: %d | Leader: %s\n", obj.Pins, obj.ConsensusPeers, leader)
+	if obj.ConsensusError != "" {
+		fmt.Printf("Consensus error: %s\n", obj.ConsensusError)
+	}
+	for _, ps := range obj.Peers {
+		if ps.Error != "" {
+			fmt.Printf("  > %s | ERROR: %s\n", peerLabel(ps.Peer, ps.Peername), ps.Error)
+			continue
+		}
+		fmt.Printf("  > %s | freespace: %s | %d pinned, %d pinning, %d queued, %d errors | queue: %d\n",
+			peerLabel(ps.Peer, ps.Peername),
+			humanize.Bytes(ps.FreeSpace),
+			ps.Pinned,
+			ps.Pinning,
+			ps.Queued,
+			ps.Errors,
+			ps.QueueDepth,
+		)
+	}
+}
+
+func textFormatPrintPinAck(obj *api.PinAck) {
+	if obj.Error != "" {
+		fmt.Printf("%s: ERROR: %s\n", obj.Cid, obj.Error)
 		return
 	}
+	fmt.Printf("%s: PINNED\n", obj.Cid)
+}
+
+func textFormatPrintRecoverSchedule(obj *api.RecoverSchedule) {
+	target := "all items"
+	if obj.Cid.Defined() {
+		target = obj.Cid.String()
+	}
+	if obj.Local {
+		target += " (local)"
+	}
+	next := "done"
+	if !obj.Done {
+		next = "next attempt " + humanize.Time(obj.NextAttempt)
+	}
+	fmt.Printf("%s | %s | Every %s | %d/%d attempts | %d in error | %s\n",
+		obj.ID,
+		target,
+		obj.Interval,
+		obj.Attempts,
+		obj.MaxAttempts,
+		obj.Errored,
+		next,
+	)
+}
+
+func textFormatPrintJob(obj *api.Job) {
+	fmt.Printf("%s | %s | %s | %d/%d done", obj.ID, obj.Kind, strings.ToUpper(string(obj.Status)), obj.Progress, obj.Total)
+	if obj.Failed > 0 {
+		fmt.Printf(" | %d failed", obj.Failed)
+	}
+	fmt.Printf(" | Created %s", humanize.Time(obj.Created))
+	if obj.Error != "" {
+		fmt.Printf(" | Error: %s", obj.Error)
+	}
+	fmt.Println()
+}
+
+func textFormatPrintCollection(obj *api.Collection) {
+	fmt.Printf("%s | %d CIDs\n", obj.Name, len(obj.Cids))
+	for _, c := range obj.Cids {
+		fmt.Printf("  > %s\n", c)
+	}
+}
 
-	fmt.Printf("%s | %s | Expires in: %s\n", peer.IDB58Encode(obj.Peer), obj.Name, humanize.Time(time.Unix(0, obj.Expire)))
+func textFormatPrintPinViewPage(obj *api.PinViewPage) {
+	for _, item := range obj.Items {
+		textFormatPrintPin(item.Pin)
+		if item.Status != nil {
+			textFormatPrintPeerMap(item.Status)
+		}
+	}
+	if len(obj.Items) == 0 {
+		fmt.Printf("No pins from %d (total: %d)\n", obj.Offset, obj.Total)
+		return
+	}
+	fmt.Printf("Pins %d-%d (total: %d)\n", obj.Offset+1, obj.Offset+len(obj.Items), obj.Total)
+}
+
+func textFormatPrintQuotaUsage(obj *api.QuotaUsage) {
+	if obj.Namespace != "" {
+		fmt.Printf("Namespace %s", obj.Namespace)
+	} else {
+		fmt.Printf("User %s", obj.User)
+	}
+	fmt.Printf(" | %d pins", obj.Pins)
+	if obj.MaxPins > 0 {
+		fmt.Printf(" (max %d)", obj.MaxPins)
+	}
+	fmt.Printf(" | %s", humanize.Bytes(obj.Bytes))
+	if obj.MaxBytes > 0 {
+		fmt.Printf(" (max %s)", humanize.Bytes(obj.MaxBytes))
+	}
+	fmt.Printf("\n")
+}
+
+func textFormatPrintProvideStrategy(obj *api.ProvideStrategy) {
+	if obj.Strategy == "" {
+		fmt.Println("No provide strategy: the IPFS daemons are not configured by the cluster")
+		return
+	}
+	fmt.Printf("Strategy: %s\n", obj.Strategy)
+	if len(obj.Peers) == 0 {
+		fmt.Println("All IPFS daemons announce content")
+		return
+	}
+	fmt.Println("Only the IPFS daemons of these peers announce content:")
+	for _, p := range obj.Peers {
+		fmt.Printf("  - %s\n", p.Pretty())
+	}
+}
+
+func textFormatPrintOfflinePinset(obj *offlinePinset) {
+	fmt.Printf("# %s\n", obj.Note)
+	for _, pin := range obj.Pins {
+		textFormatPrintPin(pin)
+	}
+}
+
+func textFormatPrintAllocationExclusions(obj *api.AllocationExclusions) {
+	if obj.IsEmpty() {
+		fmt.Println("No peers excluded")
+		return
+	}
+	for _, p := range obj.Peers {
+		fmt.Printf("Peer %s\n", p.Pretty())
+	}
+	keys := make(sort.StringSlice, 0, len(obj.Tags))
+	for k := range obj.Tags {
+		keys = append(keys, k)
+	}
+	keys.Sort()
+	for _, k := range keys {
+		fmt.Printf("Tag %s: %s\n", k, strings.Join(obj.Tags[k], ", "))
+	}
 }
 
 func textFormatPrintGlobalRepoGC(obj *api.GlobalRepoGC) {
@@ -264,10 +690,151 @@ func textFormatPrintGlobalRepoGC(obj *api.GlobalRepoGC) {
 	}
 }
 
+func textFormatPrintPeerRemoveReport(obj *api.PeerRemoveReport) {
+	fmt.Printf("Removing %s (dry run):\n", peerLabel(obj.Peer, ""))
+	fmt.Printf("  > Allocated pins:     %d\n", obj.AllocatedPins)
+	if obj.RepinningDisabled {
+		fmt.Printf("  > Repinning is disabled. Pins would not be re-allocated.\n")
+		return
+	}
+	fmt.Printf("  > Re-allocated pins:  %d\n", obj.ReallocatedPins)
+	fmt.Printf("  > Unallocatable pins: %d\n", obj.UnallocatablePins)
+	fmt.Printf("  > Data to move:       up to %s (IPFS repository size)\n", humanize.Bytes(obj.RepoSize))
+
+	receivers := make(sort.StringSlice, 0, len(obj.Receivers))
+	for p := range obj.Receivers {
+		receivers = append(receivers, p)
+	}
+	receivers.Sort()
+
+	fmt.Printf("  > Receiving peers:\n")
+	for _, p := range receivers {
+		fmt.Printf("    - %s: %d pins\n", p, obj.Receivers[p])
+	}
+}
+
+func textFormatPrintPeerNames(obj map[string]string) {
+	ids := make([]string, 0, len(obj))
+	for id := range obj {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		fmt.Printf("%s | %s\n", id, obj[id])
+	}
+}
+
+// mostCommon returns the value which appears most often in the given list.
+func mostCommon(values []string) string {
+	counts := make(map[string]int)
+	var common string
+	for _, v := range values {
+		counts[v]++
+		if counts[v] > counts[common] {
+			common = v
+		}
+	}
+	return common
+}
+
+func textFormatPrintPeerIDTable(obj peerIDTable) {
+	var versions, peerCounts []string
+	for _, id := range obj {
+		if id.Error != "" {
+			continue
+		}
+		versions = append(versions, id.Version)
+		peerCounts = append(peerCounts, strconv.Itoa(len(id.ClusterPeers)))
+	}
+	commonVersion := mostCommon(versions)
+	commonPeers := mostCommon(peerCounts)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(w, "PEER\t| NAME\t| VERSION\t| PEERS\t| IPFS")
+	for _, id := range obj {
+		if id.Error != "" {
+			fmt.Fprintf(w, "%s\t| ERROR: %s\t|\t|\t|\n", id.ID.Pretty(), id.Error)
+			continue
+		}
+
+		version := id.Version
+		if version != commonVersion {
+			version += " (!)"
+		}
+		peers := strconv.Itoa(len(id.ClusterPeers))
+		if peers != commonPeers {
+			peers += " (!)"
+		}
+		ipfs := id.IPFS.ID.Pretty()
+		if id.IPFS.Error != "" {
+			ipfs = "ERROR: " + id.IPFS.Error
+		}
+		fmt.Fprintf(w, "%s\t| %s\t| %s\t| %s\t| %s\n", id.ID.Pretty(), id.Peername, version, peers, ipfs)
+	}
+	w.Flush()
+}
+
+func textFormatPrintPeerVersions(obj []*peerVersion) {
+	var versions []string
+	for _, v := range obj {
+		if v.Error == "" {
+			versions = append(versions, v.Version)
+		}
+	}
+	common := mostCommon(versions)
+
+	for _, v := range obj {
+		switch {
+		case v.Error != "":
+			fmt.Printf("%s | ERROR: %s\n", v.Peer.Pretty(), v.Error)
+		case v.Version != common:
+			fmt.Printf("%s | %s (!)\n", v.Peer.Pretty(), v.Version)
+		default:
+			fmt.Printf("%s | %s\n", v.Peer.Pretty(), v.Version)
+		}
+	}
+}
+
+func textFormatPrintStatusSummary(obj statusSummary) {
+	statuses := make(sort.StringSlice, 0, len(obj))
+	for st := range obj {
+		statuses = append(statuses, st)
+	}
+	statuses.Sort()
+
+	total := 0
+	for _, st := range statuses {
+		fmt.Printf("%-20s : %d\n", strings.ToUpper(st), obj[st])
+		total += obj[st]
+	}
+	fmt.Printf("%-20s : %d\n", "TOTAL", total)
+}
+
+// errorCodeHints are printed along with the errors with the given codes.
+var errorCodeHints = map[api.ErrorCode]string{
+	api.ErrCodeNotLeader:     "the cluster has no leader right now. Check that most peers are online and retry.",
+	api.ErrCodePinQueueFull:  "the peer is busy. Retry later or increase max_pin_queue_size.",
+	api.ErrCodeOutdatedState: "the state of the peer is not up to date. Retry later or use a different peer.",
+	api.ErrCodeShuttingDown:  "the peer is shutting down. Use a different peer.",
+	api.ErrCodeQuotaExceeded: "unpin content or ask for a larger quota.",
+	api.ErrCodePinProtected:  "the pin is protected. An admin can remove it with --force.",
+	api.ErrCodeOverloaded:    "the cluster is not admitting new pins right now. Retry later.",
+	api.ErrCodeNoSpace:       "free space in the peers or add peers with more storage.",
+}
+
 func textFormatPrintError(obj *api.Error) {
 	fmt.Printf("An error occurred:\n")
 	fmt.Printf("  Code: %d\n", obj.Code)
+	if obj.ErrorCode != "" {
+		fmt.Printf("  Error code: %s\n", obj.ErrorCode)
+	}
 	fmt.Printf("  Message: %s\n", obj.Message)
+	if obj.RetryAfter > 0 {
+		fmt.Printf("  Retry after: %ds\n", obj.RetryAfter)
+	}
+	if hint, ok := errorCodeHints[obj.ErrorCode]; ok {
+		fmt.Printf("  Hint: %s\n", hint)
+	}
 }
 
 func trackerStatusAllString() string {
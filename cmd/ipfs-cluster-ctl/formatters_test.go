@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+func testStatus() []*api.GlobalPinInfo {
+	p1 := peer.IDB58Encode(test.PeerID1)
+	p2 := peer.IDB58Encode(test.PeerID2)
+	return []*api.GlobalPinInfo{
+		{
+			Cid: test.Cid1,
+			PeerMap: map[string]*api.PinInfo{
+				p1: {Peer: test.PeerID1, PeerName: "peer1", Status: api.TrackerStatusPinned},
+				p2: {Peer: test.PeerID2, PeerName: "peer2", Status: api.TrackerStatusPinError},
+			},
+		},
+		{
+			Cid: test.Cid2,
+			PeerMap: map[string]*api.PinInfo{
+				p1: {Peer: test.PeerID1, PeerName: "peer1", Status: api.TrackerStatusPinError},
+				p2: {Peer: test.PeerID2, PeerName: "peer2", Status: api.TrackerStatusPinned},
+			},
+		},
+	}
+}
+
+func TestFilterStatus(t *testing.T) {
+	p1 := peer.IDB58Encode(test.PeerID1)
+
+	if len(filterStatus(testStatus(), api.TrackerStatusUndefined, "")) != 2 {
+		t.Error("nothing should be filtered without filter or peer")
+	}
+
+	byPeer := filterStatus(testStatus(), api.TrackerStatusUndefined, "peer1")
+	if len(byPeer) != 2 {
+		t.Fatal("expected both items")
+	}
+	for _, gpi := range byPeer {
+		if len(gpi.PeerMap) != 1 || gpi.PeerMap[p1] == nil {
+			t.Error("expected only the entries of peer1")
+		}
+	}
+
+	byStatus := filterStatus(testStatus(), api.TrackerStatusPinError, "")
+	if len(byStatus) != 2 {
+		t.Fatal("expected both items")
+	}
+	for _, gpi := range byStatus {
+		if len(gpi.PeerMap) != 1 {
+			t.Error("expected only the entries in error")
+		}
+		for _, pinfo := range gpi.PeerMap {
+			if pinfo.Status != api.TrackerStatusPinError {
+				t.Error("expected only the entries in error")
+			}
+		}
+	}
+
+	// The status filter applies to the entry of the selected peer.
+	both := filterStatus(testStatus(), api.TrackerStatusPinError, p1)
+	if len(both) != 1 || !both[0].Cid.Equals(test.Cid2) {
+		t.Fatal("expected only the item in error in peer1")
+	}
+	if len(both[0].PeerMap) != 1 || both[0].PeerMap[p1] == nil {
+		t.Error("expected only the entry of peer1")
+	}
+
+	if len(filterStatus(testStatus(), api.TrackerStatusPinned, "unknown")) != 0 {
+		t.Error("expected no items for an unknown peer")
+	}
+}
+
+func TestNewStatusSummary(t *testing.T) {
+	summary := newStatusSummary(testStatus())
+	if summary[api.TrackerStatusPinned.String()] != 2 ||
+		summary[api.TrackerStatusPinError.String()] != 2 {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+
+	summary = newStatusSummary(filterStatus(testStatus(), api.TrackerStatusPinError, "peer2"))
+	if len(summary) != 1 || summary[api.TrackerStatusPinError.String()] != 1 {
+		t.Errorf("unexpected filtered summary: %v", summary)
+	}
+}
@@ -2,12 +2,18 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/rand"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -61,6 +67,27 @@ API endpoint, use "--host" with the full cluster libp2p listener
 address (including the "/p2p/<peerID>" part), and --secret (the
 32-byte cluster secret as it appears in the cluster configuration).
 
+When the cluster peer is not running, "pin ls" and "status" can be used
+with --offline and --state, which takes either a file produced by
+"ipfs-cluster-service state export" or the configuration folder of the
+peer. Offline, "status" does not show the status of the items, which cannot
+be obtained: it lists the pins in the state instead, labeled as such.
+
+Connection options can be stored as named profiles in a JSON file
+(%s by default) and selected with --profile. For example:
+
+  {
+    "prod-eu": {
+      "host": "/dns4/cluster.example.com/tcp/9094",
+      "basic_auth": "user:password",
+      "https": true
+    }
+  }
+
+Available profile options are: host, secret, https, no_check_certificate,
+basic_auth, force_http and timeout. Options given in the command line take
+precedence over the profile.
+
 For feedback, bug reports or any additional information, visit
 https://github.com/ipfs/ipfs-cluster.
 `,
@@ -68,7 +95,8 @@ https://github.com/ipfs/ipfs-cluster.
 	programName,
 	programName,
 	programName,
-	defaultHost)
+	defaultHost,
+	"$HOME/"+DefaultProfilesFile)
 
 type peerAddBody struct {
 	Addr string `json:"peer_multiaddress"`
@@ -93,6 +121,7 @@ func main() {
 	app.Usage = "CLI for IPFS Cluster"
 	app.Description = Description
 	app.Version = Version
+	app.EnableBashCompletion = true
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
 			Name:  "host, l",
@@ -136,9 +165,33 @@ requires authorization. implies --https, which you can disable with --force-http
 			Name:  "force-http, f",
 			Usage: "force HTTP. only valid when using BasicAuth",
 		},
+		cli.StringFlag{
+			Name:   "profile",
+			Usage:  "use the connection options from the given profile",
+			EnvVar: "CLUSTER_CTL_PROFILE",
+		},
+		cli.StringFlag{
+			Name:  "profiles-file",
+			Value: defaultProfilesPath(),
+			Usage: "path to the connection profiles file",
+		},
+		cli.BoolFlag{
+			Name:  "offline",
+			Usage: "read the pinset from --state instead of contacting the API",
+		},
+		cli.StringFlag{
+			Name:  "state",
+			Usage: "exported state file or peer configuration folder. only valid with --offline",
+		},
 	}
 
 	app.Before = func(c *cli.Context) error {
+		if name := c.String("profile"); name != "" {
+			p, err := loadProfile(c.String("profiles-file"), name)
+			checkErr("loading profile", err)
+			checkErr("applying profile", applyProfile(c, p))
+		}
+
 		cfg := &client.Config{}
 
 		if c.Bool("debug") {
@@ -178,6 +231,19 @@ requires authorization. implies --https, which you can disable with --force-http
 			checkErr("", errors.New("unsupported encoding"))
 		}
 
+		if c.Bool("offline") {
+			if c.String("state") == "" {
+				checkErr("", errors.New("--offline needs --state"))
+			}
+			if !isOfflineCommand(c.Args()) {
+				checkErr("", errors.New("only \"pin ls\" and \"status\" can run with --offline"))
+			}
+			offlineState, err = loadOfflineState(c.String("state"))
+			checkErr("loading offline state", err)
+			return nil
+		}
+
+		globalConfig = cfg
 		globalClient, err = client.NewDefaultClient(cfg)
 		checkErr("creating API client", err)
 
@@ -205,9 +271,23 @@ requires authorization. implies --https, which you can disable with --force-http
 			Description: `
 This command displays information about the peer that the tool is contacting
 (usually running in localhost).
+
+When the --all-peers flag is passed, every cluster peer is contacted
+directly and concurrently, and the answers are presented together.
+Values which differ from those of the majority of peers are marked with
+"(!)". Peers are contacted using the same API options given to this
+command: with the libp2p endpoint, their cluster addresses are used,
+otherwise they are expected to expose the HTTP API on the same port as
+the contacted peer.
 `,
-			Flags: []cli.Flag{},
+			Flags: []cli.Flag{
+				allPeersFlag(),
+			},
 			Action: func(c *cli.Context) error {
+				if c.Bool("all-peers") {
+					formatResponse(c, allPeersID(ctx), nil)
+					return nil
+				}
 				resp, cerr := globalClient.ID(ctx)
 				formatResponse(c, resp, cerr)
 				return nil
@@ -223,11 +303,67 @@ This command displays information about the peer that the tool is contacting
 					Usage: "list the nodes participating in the IPFS Cluster",
 					Description: `
 This command provides a list of the ID information of all the peers in the Cluster.
+
+Along with it, the peer answering the request reports its round-trip time to
+every peer (RTT), when it last received a metric from them, the version of
+their IPFS daemon and the number of their pins in error.
+
+The list can be sorted by any of these with --sort (id, name, rtt,
+last-metric, errors, ipfs-version) and narrowed down to the peers with
+errors (--errors), the peers slower than --min-rtt, or the peers which have
+not sent metrics for longer than --stale.
 `,
-					Flags:     []cli.Flag{},
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "sort",
+							Usage: "sort the peers by the given column",
+						},
+						cli.BoolFlag{
+							Name:  "reverse, r",
+							Usage: "reverse the sort order",
+						},
+						cli.BoolFlag{
+							Name:  "errors",
+							Usage: "only list the peers with errors or pins in error",
+						},
+						cli.DurationFlag{
+							Name:  "min-rtt",
+							Usage: "only list the peers whose round-trip time is at least this",
+						},
+						cli.DurationFlag{
+							Name:  "stale",
+							Usage: "only list the peers which have not sent metrics for this long",
+						},
+					},
 					ArgsUsage: " ",
 					Action: func(c *cli.Context) error {
 						resp, cerr := globalClient.Peers(ctx)
+						if cerr == nil {
+							resp = filterPeers(resp, peersFilter{
+								errors: c.Bool("errors"),
+								minRTT: c.Duration("min-rtt"),
+								stale:  c.Duration("stale"),
+							}, time.Now())
+							if col := c.String("sort"); col != "" {
+								checkErr("sorting peers", sortPeers(resp, col, c.Bool("reverse")))
+							}
+						}
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "names",
+					Usage: "list the peer names of the cluster peers",
+					Description: `
+This command lists the peer names of the cluster peers, as configured in their
+"peername" setting, alongside their peer IDs. Peer names can be used instead
+of peer IDs in the commands taking peers as arguments or options.
+`,
+					Flags:     []cli.Flag{},
+					ArgsUsage: " ",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.PeerNames(ctx)
 						formatResponse(c, resp, cerr)
 						return nil
 					},
@@ -239,19 +375,220 @@ This command provides a list of the ID information of all the peers in the Clust
 This command removes a peer from the cluster. If the peer is online, it will
 automatically shut down. All other cluster peers should be online for the
 operation to succeed, otherwise some nodes may be left with an outdated list of
-cluster peers.
+cluster peers. The peer can be given by its peer ID or its peer name.
+
+With --dry-run, the peer is not removed. Instead, the command reports how many
+pins would need to be re-allocated, which peers would receive them and the
+amount of data that may need to be moved.
 `,
-					ArgsUsage: "<peer ID>",
-					Flags:     []cli.Flag{},
+					ArgsUsage:    "<peer ID|peer name>",
+					BashComplete: completeFirstArg(completionPeers),
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "report the impact of the removal without removing the peer",
+						},
+					},
 					Action: func(c *cli.Context) error {
 						pid := c.Args().First()
-						p, err := peer.IDB58Decode(pid)
+						p, err := resolvePeer(pid)
 						checkErr("parsing peer ID", err)
+						if c.Bool("dry-run") {
+							resp, cerr := globalClient.PeerRmDryRun(ctx, p)
+							formatResponse(c, resp, cerr)
+							return nil
+						}
 						cerr := globalClient.PeerRm(ctx, p)
 						formatResponse(c, nil, cerr)
 						return nil
 					},
 				},
+				{
+					Name:  "maintenance",
+					Usage: "enable or disable the maintenance mode of a peer",
+					Description: `
+This command puts a peer in maintenance mode ("on") or takes it out of it
+("off"), for example before planned hardware work. A peer in maintenance
+keeps its pins but receives no new allocations: its metrics are advertised
+as invalid to the rest of the cluster. The mode is kept when the peer
+restarts. The peer can be given by its peer ID or its peer name.
+
+With --repin, the pins allocated to the peer are also re-allocated to other
+peers, as when it is removed. The progress can be followed with "health
+repinning".
+`,
+					ArgsUsage:    "<peer ID|peer name> <on|off>",
+					BashComplete: completeFirstArg(completionPeers),
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "repin",
+							Usage: "re-allocate the pins of the peer when enabling maintenance",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 2 {
+							checkErr("", errors.New("a peer and on or off are needed"))
+						}
+						p, err := resolvePeer(c.Args().Get(0))
+						checkErr("parsing peer ID", err)
+						var enabled bool
+						switch c.Args().Get(1) {
+						case "on":
+							enabled = true
+						case "off":
+						default:
+							checkErr("", errors.New("maintenance can only be on or off"))
+						}
+						cerr := globalClient.PeerMaintenance(ctx, p, enabled, c.Bool("repin"))
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "activate",
+					Usage: "take a peer out of standby mode",
+					Description: `
+This command activates a peer started in standby mode (with the "standby"
+option of the cluster configuration). Standby peers take part in the
+consensus and are monitored, but receive no allocations until activated,
+so they can provide capacity right away when needed. The activation is
+kept when the peer restarts. The peer can be given by its peer ID or its
+peer name.
+`,
+					ArgsUsage:    "<peer ID|peer name>",
+					BashComplete: completeFirstArg(completionPeers),
+					Action: func(c *cli.Context) error {
+						p, err := resolvePeer(c.Args().First())
+						checkErr("parsing peer ID", err)
+						cerr := globalClient.PeerActivate(ctx, p)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "exclusions",
+					Usage: "list the peers excluded from new allocations",
+					Description: `
+This command lists the peers, by ID and by tag, which were excluded from new
+allocations with "peers exclude" in the contacted peer. The exclusions in the
+"allocation_exclusions" section of the configuration of each peer apply too
+and are not listed.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.AllocationExclusions(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "exclude",
+					Usage: "exclude peers from new allocations",
+					Description: `
+This command makes all cluster peers stop allocating new pins to the given
+peers, without removing them from the cluster, i.e. when their hardware is
+being decommissioned. Peers can be given by peer ID or peer name with --peer,
+or by tag with --tag key=value (peers with any of the tags are excluded).
+Excluded peers keep the pins allocated to them.
+
+The given exclusions replace the ones set previously with this command.
+Running it without flags clears them. They are kept when peers restart, but
+peers joining the cluster afterwards do not receive them: exclusions which
+must last should be added to the "allocation_exclusions" section of the
+configuration too.
+`,
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name:  "peer",
+							Usage: "peer ID or name to exclude. Can be added multiple times",
+						},
+						cli.StringSliceFlag{
+							Name:  "tag",
+							Usage: "tag (key=value) of the peers to exclude. Can be added multiple times",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						ex := &api.AllocationExclusions{}
+						for _, p := range c.StringSlice("peer") {
+							pid, err := resolvePeer(p)
+							checkErr("parsing peer ID", err)
+							ex.Peers = append(ex.Peers, pid)
+						}
+						for _, tag := range c.StringSlice("tag") {
+							parts := strings.SplitN(tag, "=", 2)
+							if len(parts) != 2 {
+								checkErr("parsing tags", errors.New("tags were not in the format key=value"))
+							}
+							if ex.Tags == nil {
+								ex.Tags = make(map[string][]string)
+							}
+							ex.Tags[parts[0]] = append(ex.Tags[parts[0]], parts[1])
+						}
+						cerr := globalClient.SetAllocationExclusions(ctx, ex)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "export",
+					Usage: "export the peerstore of the contacted peer as JSON",
+					Description: `
+This command exports the cluster peers known to the contacted peer, with
+their peer names, addresses and connection manager tags, as JSON. The
+result can be imported on other peers with "peers import" (or with
+"ipfs-cluster-service peerstore import" before starting them), which is
+useful when provisioning new peers.
+
+The JSON is written to the standard output unless --file is given.
+`,
+					ArgsUsage: " ",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "write the JSON to the given file",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						known, cerr := globalClient.KnownPeers(ctx)
+						checkErr("exporting peerstore", cerr)
+						j, err := json.MarshalIndent(known, "", "    ")
+						checkErr("encoding peerstore", err)
+						j = append(j, '\n')
+						if path := c.String("file"); path != "" {
+							checkErr("writing file", ioutil.WriteFile(path, j, 0600))
+							return nil
+						}
+						fmt.Printf("%s", j)
+						return nil
+					},
+				},
+				{
+					Name:  "import",
+					Usage: "import an exported peerstore in the contacted peer",
+					Description: `
+This command adds the peers in a JSON file, as produced by "peers export",
+to the peerstore of the contacted peer, which tries to connect to them. Use
+"-" to read the JSON from the standard input.
+`,
+					ArgsUsage: "<file>",
+					Action: func(c *cli.Context) error {
+						path := c.Args().First()
+						if path == "" {
+							checkErr("", errors.New("a file is needed"))
+						}
+						var r io.Reader = os.Stdin
+						if path != "-" {
+							f, err := os.Open(path)
+							checkErr("opening file", err)
+							defer f.Close()
+							r = f
+						}
+						var known []*api.KnownPeer
+						checkErr("decoding file", json.NewDecoder(r).Decode(&known))
+						cerr := globalClient.ImportKnownPeers(ctx, known)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -262,7 +599,10 @@ cluster peers.
 Add allows to add and replicate content to several ipfs daemons, performing
 a Cluster Pin operation on success. It takes elements from local paths as
 well as from web URLs (accessed with a GET request). Providing several
-arguments will automatically set --wrap-in-directory.
+arguments will automatically set --wrap-in-directory. The added file or
+directory keeps its own name inside the wrapping directory, unless another
+one is given with --root-name. Hidden files inside added directories are
+skipped unless --hidden is set.
 
 Cluster Add is equivalent to "ipfs add" in terms of DAG building, and supports
 the same options for adjusting the chunker, the DAG layout etc. However,
@@ -321,6 +661,10 @@ content.
 					Name:  "hidden, H",
 					Usage: "Include files that are hidden.  Only takes effect on recursive add",
 				},
+				cli.StringFlag{
+					Name:  "root-name",
+					Usage: "Name given to the added file or directory instead of its own (useful with --wrap-with-directory)",
+				},
 				cli.StringFlag{
 					Name:  "chunker, s",
 					Usage: "'size-<size>' or 'rabin-<min>-<avg>-<max>'",
@@ -369,12 +713,44 @@ content.
 				},
 				cli.StringFlag{
 					Name:  "allocations, allocs",
-					Usage: "Optional comma-separated list of peer IDs",
+					Usage: "Optional comma-separated list of peer IDs or peer names",
+				},
+				cli.StringFlag{
+					Name:  "policy",
+					Usage: "Name of a placement policy defined in the cluster configuration",
+				},
+				cli.StringFlag{
+					Name:  "storage-class",
+					Usage: "Only allocate to peers of this storage class (i.e. ssd, hdd, archive)",
+				},
+				cli.StringFlag{
+					Name:  "peer-group",
+					Usage: "Only allocate to peers in this peer group, as defined in the cluster configuration",
+				},
+				cli.StringFlag{
+					Name:  "namespace",
+					Usage: "Pin namespace. Ignored for credentials restricted to a namespace",
 				},
 				cli.BoolFlag{
 					Name:  "nocopy",
 					Usage: "Add the URL using filestore. Implies raw-leaves. (experimental)",
 				},
+				cli.BoolFlag{
+					Name:  "preserve-mode",
+					Usage: "Store the permissions of the files (UnixFS 1.5)",
+				},
+				cli.BoolFlag{
+					Name:  "preserve-mtime",
+					Usage: "Store the modification times of the files (UnixFS 1.5)",
+				},
+				cli.StringFlag{
+					Name:  "mode",
+					Usage: "Store these permissions (in octal, i.e. 0644) for every file (UnixFS 1.5)",
+				},
+				cli.Int64Flag{
+					Name:  "mtime",
+					Usage: "Store this modification time (in seconds since the Unix epoch) for every file (UnixFS 1.5)",
+				},
 				// TODO: Uncomment when sharding is supported.
 				// cli.BoolFlag{
 				//	Name:  "shard",
@@ -427,8 +803,14 @@ content.
 				p.Metadata = parseMetadata(c.StringSlice("metadata"))
 				p.Name = name
 				if c.String("allocations") != "" {
-					p.UserAllocations = api.StringsToPeers(strings.Split(c.String("allocations"), ","))
+					allocs, err := resolvePeers(strings.Split(c.String("allocations"), ","))
+					checkErr("decoding allocations", err)
+					p.UserAllocations = allocs
 				}
+				p.Policy = c.String("policy")
+				p.StorageClass = c.String("storage-class")
+				p.PeerGroup = c.String("peer-group")
+				p.Namespace = c.String("namespace")
 				//p.Shard = shard
 				//p.ShardSize = c.Uint64("shard-size")
 				p.Shard = false
@@ -439,6 +821,10 @@ content.
 				p.RawLeaves = c.Bool("raw-leaves")
 				p.Hidden = c.Bool("hidden")
 				p.Wrap = c.Bool("wrap-with-directory") || len(paths) > 1
+				p.RootName = c.String("root-name")
+				if p.RootName != "" && len(paths) > 1 {
+					checkErr("", errors.New("--root-name can only be used when adding a single path"))
+				}
 				p.CidVersion = c.Int("cid-version")
 				p.HashFun = c.String("hash")
 				if p.HashFun != defaultAddParams.HashFun {
@@ -451,6 +837,19 @@ content.
 				if p.NoCopy {
 					p.RawLeaves = true
 				}
+				p.PreserveMode = c.Bool("preserve-mode")
+				p.PreserveMtime = c.Bool("preserve-mtime")
+				if mode := c.String("mode"); mode != "" {
+					m, err := strconv.ParseUint(mode, 8, 32)
+					if err == nil && m > 0777 {
+						err = errors.New("only permission bits can be set")
+					}
+					checkErr("parsing mode", err)
+					p.Mode = os.FileMode(m)
+				}
+				if mtime := c.Int64("mtime"); mtime != 0 {
+					p.Mtime = time.Unix(mtime, 0)
+				}
 
 				out := make(chan *api.AddedOutput, 1)
 				var wg sync.WaitGroup
@@ -523,6 +922,54 @@ An optional allocations argument can be provided, allocations should be a
 comma-separated list of peer IDs on which we want to pin. Peers in allocations
 are prioritized over automatically-determined ones, but replication factors
 would stil be respected.
+
+An optional placement policy can be provided by name. Placement policies are
+defined in the "placement_policies" section of the cluster configuration and
+set replication factors, the tags of the peers that can be allocated and the
+metric used to choose among them. Replication factors given as options take
+precedence over those of the policy.
+
+A storage class (i.e. "ssd", "hdd", "archive") can be requested so that the
+pin is only allocated to peers advertising it ("storage_class" in the
+cluster section of their configuration). This allows directing hot and cold
+data to the appropriate peers.
+
+A peer group (i.e. "hot", "archive") can be requested so that the pin is only
+allocated to the peers listed for that group in the "peer_groups" section of
+the cluster configuration.
+
+Pins can be placed in a namespace, which may be subject to quotas on the
+number of pins and their total size. Pins made with API credentials which are
+restricted to a namespace are always placed in it.
+
+Priority pins are sent to IPFS by the allocated peers before any other pins
+waiting in their queues, so that urgent content is fetched first under load.
+
+An optional fetch rate limit sets the maximum number of blocks per second
+that the IPFS daemons of the allocated peers fetch for the pin, so that
+background pins do not starve other traffic. Peers may configure a default
+limit ("fetch_rate_limit" in the "ipfshttp" section) for pins which are not
+priority pins and do not set one.
+
+Local pins (--local-pin) are pinned and tracked only by the peer receiving
+the request, without being added to the shared pinset. They are useful for
+node-specific caches, do not appear in "pin ls" and are marked as local in the
+status output. They are removed with "pin rm" against the same peer.
+
+Protected pins (--protected) cannot be removed with "pin rm" unless the
+unpin is forced with --force, which is only allowed for admin users. Expired
+protected pins are still unpinned. For the same reason, a protected pin can
+only be pinned again without protection, or with an earlier expiration, when
+"pin add" is given --force (admins only, CID arguments only).
+
+Many items can be pinned at once from a manifest file (--manifest) instead of
+a CID argument. Manifests are JSON lists of objects, or CSV files with a
+header row, with a "cid" and, optionally, a "name", a "replication" factor,
+"metadata" (a JSON object, or "key=value" pairs separated by ";" in CSV) and
+"priority" (true or false) for every item. The options given in the command
+apply to all items, unless the manifest sets them. The format is guessed
+from the file extension unless --manifest-format is given. An
+acknowledgement is printed for every item, in order.
 `,
 					ArgsUsage: "<CID|Path>",
 					Flags: []cli.Flag{
@@ -543,7 +990,47 @@ would stil be respected.
 						},
 						cli.StringFlag{
 							Name:  "allocations, allocs",
-							Usage: "Optional comma-separated list of peer IDs",
+							Usage: "Optional comma-separated list of peer IDs or peer names",
+						},
+						cli.StringFlag{
+							Name:  "policy",
+							Usage: "Name of a placement policy defined in the cluster configuration",
+						},
+						cli.StringFlag{
+							Name:  "storage-class",
+							Usage: "Only allocate to peers of this storage class (i.e. ssd, hdd, archive)",
+						},
+						cli.StringFlag{
+							Name:  "peer-group",
+							Usage: "Only allocate to peers in this peer group, as defined in the cluster configuration",
+						},
+						cli.StringFlag{
+							Name:  "namespace",
+							Usage: "Pin namespace. Ignored for credentials restricted to a namespace",
+						},
+						cli.BoolFlag{
+							Name:  "priority",
+							Usage: "Pin before any other queued pins in the cluster peers",
+						},
+						cli.Uint64Flag{
+							Name:  "fetch-rate-limit",
+							Usage: "Maximum number of blocks per second to fetch for this pin (0: unlimited)",
+						},
+						cli.BoolFlag{
+							Name:  "local-pin",
+							Usage: "Pin only in the peer receiving the request, without adding it to the shared pinset",
+						},
+						cli.BoolFlag{
+							Name:  "protected",
+							Usage: "Protect the pin so that it can only be removed with a forced unpin",
+						},
+						cli.BoolFlag{
+							Name:  "force",
+							Usage: "Allow lifting the protection of a protected pin (admins only)",
+						},
+						cli.StringFlag{
+							Name:  "origins",
+							Usage: "Optional comma-separated list of multiaddresses of peers providing the content",
 						},
 						cli.StringFlag{
 							Name:  "name, n",
@@ -571,9 +1058,21 @@ would stil be respected.
 							Value: 0,
 							Usage: "How long to --wait (in seconds), default is indefinitely",
 						},
+						cli.StringFlag{
+							Name:  "manifest",
+							Usage: "Pin the items listed in this manifest file instead of a CID",
+						},
+						cli.StringFlag{
+							Name:  "manifest-format",
+							Usage: "Format of the manifest: json or csv. Guessed from the file extension by default",
+						},
 					},
 					Action: func(c *cli.Context) error {
 						arg := c.Args().First()
+						manifest := c.String("manifest")
+						if manifest != "" && arg != "" {
+							checkErr("", errors.New("a CID cannot be given along with --manifest"))
+						}
 						rpl := c.Int("replication")
 						rplMin := c.Int("replication-min")
 						rplMax := c.Int("replication-max")
@@ -588,10 +1087,9 @@ would stil be respected.
 							for i := range allocs {
 								allocs[i] = strings.TrimSpace(allocs[i])
 							}
-							userAllocs = api.StringsToPeers(allocs)
-							if len(userAllocs) != len(allocs) {
-								checkErr("decoding allocations", errors.New("some peer IDs could not be decoded"))
-							}
+							var err error
+							userAllocs, err = resolvePeers(allocs)
+							checkErr("decoding allocations", err)
 						}
 						var expireAt time.Time
 						if expireIn := c.String("expire-in"); expireIn != "" {
@@ -607,9 +1105,31 @@ would stil be respected.
 							UserAllocations:      userAllocs,
 							ExpireAt:             expireAt,
 							Metadata:             parseMetadata(c.StringSlice("metadata")),
+							Policy:               c.String("policy"),
+							StorageClass:         c.String("storage-class"),
+							PeerGroup:            c.String("peer-group"),
+							Namespace:            c.String("namespace"),
+							Priority:             c.Bool("priority"),
+							FetchRateLimit:       c.Uint64("fetch-rate-limit"),
+							LocalPin:             c.Bool("local-pin"),
+							Origins:              parseOrigins(c.String("origins")),
+							Protected:            c.Bool("protected"),
+						}
+
+						if manifest != "" {
+							pinManifest(ctx, c, manifest, c.String("manifest-format"), opts)
+							return nil
 						}
 
-						pin, cerr := globalClient.PinPath(ctx, arg, opts)
+						var pin *api.Pin
+						var cerr error
+						if c.Bool("force") {
+							ci, err := cid.Decode(arg)
+							checkErr("parsing cid (--force requires a CID)", err)
+							pin, cerr = globalClient.ForcePin(ctx, ci, opts)
+						} else {
+							pin, cerr = globalClient.PinPath(ctx, arg, opts)
+						}
 						if cerr != nil {
 							formatResponse(c, nil, cerr)
 							return nil
@@ -633,9 +1153,17 @@ trigger unpinning operations in all the IPFS nodes holding the content.
 When the request has succeeded, the command returns the status of the CID
 in the cluster. The CID should disappear from the list offered by "pin ls",
 although unpinning operations in the cluster may take longer or fail.
+
+Protected pins can only be removed with --force, which requires admin
+credentials and a CID argument.
 `,
-					ArgsUsage: "<CID|Path>",
+					ArgsUsage:    "<CID|Path>",
+					BashComplete: completeFirstArg(completionCids),
 					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "force",
+							Usage: "Unpin the CID even if it is protected (admins only)",
+						},
 						cli.BoolFlag{
 							Name:  "no-status, ns",
 							Usage: "Prevents fetching pin status after unpinning (faster, quieter)",
@@ -652,7 +1180,15 @@ although unpinning operations in the cluster may take longer or fail.
 					},
 					Action: func(c *cli.Context) error {
 						arg := c.Args().First()
-						pin, cerr := globalClient.UnpinPath(ctx, arg)
+						var pin *api.Pin
+						var cerr error
+						if c.Bool("force") {
+							ci, err := cid.Decode(arg)
+							checkErr("parsing cid (--force requires a CID)", err)
+							pin, cerr = globalClient.ForceUnpin(ctx, ci)
+						} else {
+							pin, cerr = globalClient.UnpinPath(ctx, arg)
+						}
 						if cerr != nil {
 							formatResponse(c, nil, cerr)
 							return nil
@@ -666,6 +1202,149 @@ although unpinning operations in the cluster may take longer or fail.
 						return nil
 					},
 				},
+				{
+					Name:  "stream",
+					Usage: "Pin a large list of CIDs over a single connection",
+					Description: `
+This command reads CIDs, one per line, from the given file or from the
+standard input and pins them all with the same options, sending them to the
+cluster peer over a single long-lived connection. This is meant for
+migrating very large pinsets.
+
+Every CID is acknowledged once it is part of the Cluster's state, in the
+same order in which it was read. The peer stops accepting CIDs while its
+queue of pending pin operations is too long, so that the rest of the
+cluster is not overwhelmed.
+
+The command exits with an error if any of the CIDs could not be pinned.
+`,
+					ArgsUsage: "[file]",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "replication, r",
+							Value: 0,
+							Usage: "Sets a custom replication factor (overrides -rmax and -rmin)",
+						},
+						cli.IntFlag{
+							Name:  "replication-min, rmin",
+							Value: 0,
+							Usage: "Sets the minimum replication factor for the pins",
+						},
+						cli.IntFlag{
+							Name:  "replication-max, rmax",
+							Value: 0,
+							Usage: "Sets the maximum replication factor for the pins",
+						},
+						cli.StringFlag{
+							Name:  "policy",
+							Usage: "Name of a placement policy defined in the cluster configuration",
+						},
+						cli.StringFlag{
+							Name:  "storage-class",
+							Usage: "Only allocate to peers of this storage class (i.e. ssd, hdd, archive)",
+						},
+						cli.StringFlag{
+							Name:  "peer-group",
+							Usage: "Only allocate to peers in this peer group, as defined in the cluster configuration",
+						},
+						cli.StringFlag{
+							Name:  "namespace",
+							Usage: "Pin namespace. Ignored for credentials restricted to a namespace",
+						},
+						cli.BoolFlag{
+							Name:  "priority",
+							Usage: "Pin before any other queued pins in the cluster peers",
+						},
+						cli.Uint64Flag{
+							Name:  "fetch-rate-limit",
+							Usage: "Maximum number of blocks per second to fetch for this pin (0: unlimited)",
+						},
+						cli.StringFlag{
+							Name:  "origins",
+							Usage: "Optional comma-separated list of multiaddresses of peers providing the content",
+						},
+						cli.StringFlag{
+							Name:  "name, n",
+							Value: "",
+							Usage: "Sets a name for the pins",
+						},
+						cli.StringSliceFlag{
+							Name:  "metadata",
+							Usage: "Pin metadata: key=value. Can be added multiple times",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						input := io.Reader(os.Stdin)
+						if fname := c.Args().First(); fname != "" && fname != "-" {
+							f, err := os.Open(fname)
+							checkErr("opening input file", err)
+							defer f.Close()
+							input = f
+						}
+
+						rplMin := c.Int("replication-min")
+						rplMax := c.Int("replication-max")
+						if rpl := c.Int("replication"); rpl != 0 {
+							rplMin = rpl
+							rplMax = rpl
+						}
+						opts := api.PinOptions{
+							ReplicationFactorMin: rplMin,
+							ReplicationFactorMax: rplMax,
+							Name:                 c.String("name"),
+							Metadata:             parseMetadata(c.StringSlice("metadata")),
+							Policy:               c.String("policy"),
+							StorageClass:         c.String("storage-class"),
+							PeerGroup:            c.String("peer-group"),
+							Namespace:            c.String("namespace"),
+							Priority:             c.Bool("priority"),
+							FetchRateLimit:       c.Uint64("fetch-rate-limit"),
+							Origins:              parseOrigins(c.String("origins")),
+						}
+
+						in := make(chan cid.Cid, 1024)
+						out := make(chan *api.PinAck, 1024)
+						readErr := make(chan error, 1)
+						go func() {
+							defer close(in)
+							scanner := bufio.NewScanner(input)
+							for scanner.Scan() {
+								line := strings.TrimSpace(scanner.Text())
+								if line == "" {
+									continue
+								}
+								ci, err := cid.Decode(line)
+								if err != nil {
+									readErr <- fmt.Errorf("%q: %s", line, err)
+									return
+								}
+								in <- ci
+							}
+							readErr <- scanner.Err()
+						}()
+
+						failed := 0
+						done := make(chan struct{})
+						go func() {
+							defer close(done)
+							for ack := range out {
+								if ack.Error != "" {
+									failed++
+								}
+								formatResponse(c, ack, nil)
+							}
+						}()
+
+						cerr := globalClient.StreamPins(ctx, in, opts, out)
+						<-done
+						checkErr("streaming pins", cerr)
+						checkErr("reading CIDs", <-readErr)
+						if failed > 0 {
+							checkErr("streaming pins", fmt.Errorf("%d CIDs could not be pinned", failed))
+						}
+						return nil
+					},
+				},
 				{
 					Name:  "update",
 					Usage: "Pin a new item based on an existing one",
@@ -682,7 +1361,8 @@ are similar.
 Unlike the "pin update" command in the ipfs daemon, this will not unpin the
 existing item from the cluster. Please run "pin rm" for that.
 `,
-					ArgsUsage: "<existing-CID> <new-CID|Path>",
+					ArgsUsage:    "<existing-CID> <new-CID|Path>",
+					BashComplete: completeFirstArg(completionCids),
 					Flags: []cli.Flag{
 						cli.BoolFlag{
 							Name:  "no-status, ns",
@@ -739,20 +1419,56 @@ The filter only takes effect when listing all pins. The possible values are:
   - meta-pin
   - clusterdag-pin
   - shard-pin
+
+The list can be narrowed down to the pins with the given metadata keys (or
+key=value pairs) with --metadata, and to the pins allocated to a peer with
+--allocation. These lookups use the pinset index kept by the cluster peer.
+
+With --status, every pin is listed along with its status in the cluster
+peers. The list is then obtained in pages of --limit pins, starting at
+--offset.
 `,
-					ArgsUsage: "[CID]",
+					ArgsUsage:    "[CID]",
+					BashComplete: completeFirstArg(completionCids),
 					Flags: []cli.Flag{
 						cli.StringFlag{
 							Name:  "filter",
 							Usage: "Comma separated list of pin types. See help above.",
 							Value: "pin",
 						},
-					},
-					Action: func(c *cli.Context) error {
+						cli.StringSliceFlag{
+							Name:  "metadata",
+							Usage: "only list pins with this metadata key or key=value pair. Can be repeated",
+						},
+						cli.StringFlag{
+							Name:  "allocation",
+							Usage: "only list pins allocated to this peer ID or peer name",
+						},
+						cli.BoolFlag{
+							Name:  "status",
+							Usage: "list the pins along with their status in the cluster peers",
+						},
+						cli.IntFlag{
+							Name:  "offset",
+							Usage: "with --status, skip this number of pins",
+						},
+						cli.IntFlag{
+							Name:  "limit",
+							Usage: "with --status, list at most this number of pins (default: 100)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						offline := c.GlobalBool("offline")
 						cidStr := c.Args().First()
 						if cidStr != "" {
 							ci, err := cid.Decode(cidStr)
 							checkErr("parsing cid", err)
+							if offline {
+								resp, err := offlineAllocation(ci)
+								checkErr("reading offline state", err)
+								formatResponse(c, resp, nil)
+								return nil
+							}
 							resp, cerr := globalClient.Allocation(ctx, ci)
 							formatResponse(c, resp, cerr)
 						} else {
@@ -761,13 +1477,76 @@ The filter only takes effect when listing all pins. The possible values are:
 							for _, f := range strFilter {
 								filter |= api.PinTypeFromString(f)
 							}
+							query := &api.PinQuery{
+								Type:     filter,
+								Metadata: make(map[string]string),
+							}
+							for _, m := range c.StringSlice("metadata") {
+								parts := strings.SplitN(m, "=", 2)
+								query.Metadata[parts[0]] = ""
+								if len(parts) == 2 {
+									query.Metadata[parts[0]] = parts[1]
+								}
+							}
+							if allocStr := c.String("allocation"); allocStr != "" {
+								pid, err := resolvePeer(allocStr)
+								checkErr("parsing peer ID", err)
+								query.Allocation = pid
+							}
 
-							resp, cerr := globalClient.Allocations(ctx, filter)
+							if c.Bool("status") {
+								if offline {
+									checkErr("listing pins", errors.New("--status cannot be used with --offline"))
+								}
+								resp, cerr := globalClient.PinsView(ctx, &api.PinViewQuery{
+									PinQuery: *query,
+									Offset:   c.Int("offset"),
+									Limit:    c.Int("limit"),
+								})
+								formatResponse(c, resp, cerr)
+								return nil
+							}
+
+							if offline {
+								pins := []*api.Pin{}
+								for _, pin := range offlineAllocations(filter) {
+									if query.Matches(pin) {
+										pins = append(pins, pin)
+									}
+								}
+								formatResponse(c, pins, nil)
+								return nil
+							}
+
+							if len(query.Metadata) == 0 && query.Allocation == "" {
+								resp, cerr := globalClient.Allocations(ctx, filter)
+								formatResponse(c, resp, cerr)
+								return nil
+							}
+							resp, cerr := globalClient.AllocationsQuery(ctx, query)
 							formatResponse(c, resp, cerr)
 						}
 						return nil
 					},
 				},
+				{
+					Name:  "usage",
+					Usage: "Show the pins in every namespace and by every user",
+					Description: `
+This command displays, for every pin namespace and every API user, how many
+pins they have and their cumulative size, along with their quotas. Quotas are
+set in the "namespace_quotas" and "user_quotas" sections of the cluster
+configuration. Sizes are only obtained for pins subject to a quota.
+
+Credentials restricted to a namespace only see the usage of their namespace
+and their own.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.QuotaUsage(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -779,38 +1558,132 @@ Cluster, including which member is pinning them and any errors.
 If a CID is provided, the status will be only fetched for a single
 item.  Metadata CIDs are included in the status response
 
+Items in the pinset are labeled SATISFIED when they are pinned in at least as
+many peers as their minimum replication factor (or everywhere, for items
+replicated everywhere), DEGRADED when they are pinned in, or being pinned by,
+fewer peers, and FAILED when they are not pinned anywhere and no peer is
+pinning them.
+
 When the --local flag is passed, it will only fetch the status from the
 contacted cluster peer. By default, status will be fetched from all peers.
 
-When the --filter flag is passed, it will only fetch the peer information
+When the --filter flag is passed, it will only show the peer information
 where status of the pin matches at least one of the filter values (a comma
-separated list). The following are valid status values:
-
-` + trackerStatusAllString(),
-			ArgsUsage: "[CID]",
+separated list), also when a single CID is given. The following are valid
+status values:
+
+` + trackerStatusAllString() + `
+
+When the --peer flag is passed, only the information reported by the given
+cluster peer (peer ID or peer name) is shown. Combined with --filter, items
+are shown when the status in that peer matches the filter.
+
+When the --summary flag is passed, the number of items in each status is
+printed instead of the status of every item.
+
+When the --changed-since flag is passed with a duration (i.e. "10m") or an
+RFC3339 timestamp, only the items whose status changed since then (because
+of pin or unpin operations) are shown, along with the peers in which it
+changed. Peers keep a limited number of recent changes: older changes cannot
+be obtained and the full status must be requested instead. Monitoring
+systems can poll the "/pins/changes" API endpoint in the same way.
+
+When the --all-peers flag is passed, every cluster peer is contacted
+directly and concurrently for its local status, and the answers are merged.
+Peers are contacted using the same API options given to this command: with
+the libp2p endpoint, their cluster addresses are used, otherwise they are
+expected to expose the HTTP API on the same port as the contacted peer.
+`,
+			ArgsUsage:    "[CID]",
+			BashComplete: completeFirstArg(completionCids),
 			Flags: []cli.Flag{
 				localFlag(),
 				cli.StringFlag{
 					Name:  "filter",
 					Usage: "comma-separated list of filters",
 				},
+				cli.StringFlag{
+					Name:  "peer",
+					Usage: "only show status information from this peer (ID or name)",
+				},
+				cli.BoolFlag{
+					Name:  "summary",
+					Usage: "print per-status counts instead of each item",
+				},
+				cli.StringFlag{
+					Name:  "changed-since",
+					Usage: "only show items whose status changed since this duration or timestamp",
+				},
+				allPeersFlag(),
 			},
 			Action: func(c *cli.Context) error {
+				if c.GlobalBool("offline") {
+					return offlineStatus(c)
+				}
+
+				filterFlag := c.String("filter")
+				filter := api.TrackerStatusFromString(filterFlag)
+				if filter == api.TrackerStatusUndefined && filterFlag != "" {
+					checkErr("parsing filter flag", errors.New("invalid filter name"))
+				}
+
+				var gpis []*api.GlobalPinInfo
 				cidStr := c.Args().First()
-				if cidStr != "" {
+				if c.Bool("all-peers") {
+					ci := cid.Undef
+					if cidStr != "" {
+						var err error
+						ci, err = cid.Decode(cidStr)
+						checkErr("parsing cid", err)
+					}
+					gpis = allPeersStatus(ctx, ci, filter)
+				} else if cidStr != "" {
 					ci, err := cid.Decode(cidStr)
 					checkErr("parsing cid", err)
 					resp, cerr := globalClient.Status(ctx, ci, c.Bool("local"))
-					formatResponse(c, resp, cerr)
-				} else {
-					filterFlag := c.String("filter")
-					filter := api.TrackerStatusFromString(c.String("filter"))
-					if filter == api.TrackerStatusUndefined && filterFlag != "" {
-						checkErr("parsing filter flag", errors.New("invalid filter name"))
+					if cerr != nil {
+						formatResponse(c, nil, cerr)
+						return nil
 					}
+					gpis = []*api.GlobalPinInfo{resp}
+				} else if sinceStr := c.String("changed-since"); sinceStr != "" {
+					var since time.Time
+					if d, err := time.ParseDuration(sinceStr); err == nil {
+						since = time.Now().Add(-d)
+					} else {
+						err = since.UnmarshalText([]byte(sinceStr))
+						checkErr("parsing changed-since", err)
+					}
+					resp, cerr := globalClient.StatusChanges(ctx, since)
+					if cerr != nil {
+						formatResponse(c, nil, cerr)
+						return nil
+					}
+					gpis = resp.Changes
+				} else {
 					resp, cerr := globalClient.StatusAll(ctx, filter, c.Bool("local"))
-					formatResponse(c, resp, cerr)
+					if cerr != nil {
+						formatResponse(c, nil, cerr)
+						return nil
+					}
+					gpis = resp
+				}
+
+				// Items are selected when any of their peers
+				// matches the filter. Only show the entries
+				// which match (in the given peer).
+				gpis = filterStatus(gpis, filter, c.String("peer"))
+
+				if c.Bool("summary") {
+					formatResponse(c, newStatusSummary(gpis), nil)
+					return nil
 				}
+
+				if cidStr != "" && len(gpis) == 1 {
+					formatResponse(c, gpis[0], nil)
+					return nil
+				}
+				formatResponse(c, gpis, nil)
 				return nil
 			},
 		},
@@ -827,13 +1700,70 @@ CIDs (without argument), it may take a considerably long time.
 
 When the --local flag is passed, it will only trigger recover
 operations on the contacted peer (as opposed to on every peer).
+
+When the --auto flag is passed, the contacted peer registers a recover
+schedule instead of performing a single attempt: items in error state are
+recovered every --interval until none remain in error or --max-attempts
+attempts have been made. The command returns the schedule right away.
+Schedules are persisted by the contacted peer, which resumes the unfinished
+ones when it restarts. They can be listed with --schedules and cancelled with
+--cancel <schedule ID>.
 `,
-			ArgsUsage: "[CID]",
+			ArgsUsage:    "[CID]",
+			BashComplete: completeFirstArg(completionCids),
 			Flags: []cli.Flag{
 				localFlag(),
+				cli.BoolFlag{
+					Name:  "auto",
+					Usage: "register a recover schedule in the contacted peer",
+				},
+				cli.StringFlag{
+					Name:  "interval",
+					Value: "10m",
+					Usage: "time between recover attempts with --auto",
+				},
+				cli.IntFlag{
+					Name:  "max-attempts",
+					Value: 5,
+					Usage: "maximum number of recover attempts with --auto",
+				},
+				cli.BoolFlag{
+					Name:  "schedules",
+					Usage: "list the recover schedules of the contacted peer",
+				},
+				cli.StringFlag{
+					Name:  "cancel",
+					Usage: "cancel the recover schedule with the given ID",
+				},
 			},
 			Action: func(c *cli.Context) error {
 				cidStr := c.Args().First()
+				switch {
+				case c.Bool("schedules"):
+					resp, cerr := globalClient.RecoverSchedules(ctx)
+					formatResponse(c, resp, cerr)
+					return nil
+				case c.String("cancel") != "":
+					cerr := globalClient.CancelRecoverSchedule(ctx, c.String("cancel"))
+					formatResponse(c, nil, cerr)
+					return nil
+				case c.Bool("auto"):
+					interval, err := time.ParseDuration(c.String("interval"))
+					checkErr("parsing interval", err)
+					rs := &api.RecoverSchedule{
+						Local:       c.Bool("local"),
+						Interval:    interval,
+						MaxAttempts: c.Int("max-attempts"),
+					}
+					if cidStr != "" {
+						rs.Cid, err = cid.Decode(cidStr)
+						checkErr("parsing cid", err)
+					}
+					resp, cerr := globalClient.ScheduleRecover(ctx, rs)
+					formatResponse(c, resp, cerr)
+					return nil
+				}
+
 				if cidStr != "" {
 					ci, err := cid.Decode(cidStr)
 					checkErr("parsing cid", err)
@@ -846,6 +1776,263 @@ operations on the contacted peer (as opposed to on every peer).
 				return nil
 			},
 		},
+		{
+			Name:  "jobs",
+			Usage: "Manage long-running operations in the cluster peer",
+			Description: `
+Jobs are long-running operations which run in the background of the
+contacted peer, one after another. They are persisted by the peer and
+resumed when it restarts. Finished jobs are kept, along with their outcome.
+`,
+			Subcommands: []cli.Command{
+				{
+					Name:  "ls",
+					Usage: "list the jobs of the contacted peer",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Jobs(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "status",
+					Usage:     "show the progress of a job",
+					ArgsUsage: "<job ID>",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Job(ctx, c.Args().First())
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "cancel",
+					Usage:     "cancel a queued or running job",
+					ArgsUsage: "<job ID>",
+					Action: func(c *cli.Context) error {
+						cerr := globalClient.CancelJob(ctx, c.Args().First())
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "submit",
+					Usage: "queue a recover, repo-gc or shards-gc job",
+					Description: `
+This command queues a job in the contacted peer and returns it right away.
+Its progress can be followed with "jobs status". The kind of job is one of:
+
+  - recover: recovers all the items in error state in the cluster.
+  - repo-gc: runs garbage collection on the IPFS daemons of all peers.
+  - shards-gc: unpins the shards which are not referenced by any pin.
+`,
+					ArgsUsage: "<recover|repo-gc|shards-gc>",
+					Action: func(c *cli.Context) error {
+						j := &api.Job{Kind: api.JobKind(c.Args().First())}
+						resp, cerr := globalClient.SubmitJob(ctx, j)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "import",
+					Usage: "queue a job pinning the items in a file",
+					Description: `
+This command queues a job in the contacted peer which pins every item in
+the given JSON file, as produced by "ipfs-cluster-ctl --enc=json pin ls",
+with its options. Use "-" to read the JSON from the standard input. Items
+which cannot be pinned are counted as failed and do not stop the job.
+`,
+					ArgsUsage: "<file>",
+					Action: func(c *cli.Context) error {
+						path := c.Args().First()
+						if path == "" {
+							checkErr("", errors.New("a file is needed"))
+						}
+						var r io.Reader = os.Stdin
+						if path != "-" {
+							f, err := os.Open(path)
+							checkErr("opening file", err)
+							defer f.Close()
+							r = f
+						}
+						var pins []*api.Pin
+						checkErr("decoding file", json.NewDecoder(r).Decode(&pins))
+						j := &api.Job{Kind: api.JobImport, Pins: pins}
+						resp, cerr := globalClient.SubmitJob(ctx, j)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "collection",
+			Usage: "Manage named groups of CIDs pinned together",
+			Description: `
+Collections are named groups of CIDs in the shared pinset which are pinned,
+unpinned and checked together. The pins of a collection carry its name and
+can be listed with "pin ls" like any other pin.
+`,
+			Subcommands: []cli.Command{
+				{
+					Name:  "ls",
+					Usage: "list the collections in the cluster",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Collections(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "show",
+					Usage:     "show the CIDs of a collection",
+					ArgsUsage: "<name>",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Collection(ctx, c.Args().First())
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "pin",
+					Usage: "pin a group of CIDs as a collection",
+					Description: `
+This command pins all the given CIDs with the same options and places them in
+the named collection. If any of them cannot be pinned, the ones pinned by
+this command are unpinned again. CIDs which are already pinned outside the
+collection are rejected. Running it again with more CIDs adds them to the
+collection.
+`,
+					ArgsUsage: "<name> <CID> [<CID>...]",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "replication, r",
+							Value: 0,
+							Usage: "Sets a custom replication factor (overrides -rmax and -rmin)",
+						},
+						cli.IntFlag{
+							Name:  "replication-min, rmin",
+							Value: 0,
+							Usage: "Sets the minimum replication factor for the pins",
+						},
+						cli.IntFlag{
+							Name:  "replication-max, rmax",
+							Value: 0,
+							Usage: "Sets the maximum replication factor for the pins",
+						},
+						cli.StringFlag{
+							Name:  "policy",
+							Usage: "Name of a placement policy defined in the cluster configuration",
+						},
+						cli.StringFlag{
+							Name:  "storage-class",
+							Usage: "Only allocate to peers of this storage class (i.e. ssd, hdd, archive)",
+						},
+						cli.StringFlag{
+							Name:  "peer-group",
+							Usage: "Only allocate to peers in this peer group, as defined in the cluster configuration",
+						},
+						cli.StringFlag{
+							Name:  "namespace",
+							Usage: "Pin namespace. Ignored for credentials restricted to a namespace",
+						},
+						cli.StringSliceFlag{
+							Name:  "metadata",
+							Usage: "Pin metadata: key=value. Can be added multiple times",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						args := c.Args()
+						if len(args) < 2 {
+							checkErr("", errors.New("a name and at least one CID are needed"))
+						}
+						var cids []cid.Cid
+						for _, arg := range args[1:] {
+							ci, err := cid.Decode(arg)
+							checkErr("parsing cid", err)
+							cids = append(cids, ci)
+						}
+						rplMin := c.Int("replication-min")
+						rplMax := c.Int("replication-max")
+						if rpl := c.Int("replication"); rpl != 0 {
+							rplMin = rpl
+							rplMax = rpl
+						}
+						col := &api.Collection{
+							Name: args[0],
+							Cids: cids,
+							Options: &api.PinOptions{
+								ReplicationFactorMin: rplMin,
+								ReplicationFactorMax: rplMax,
+								Metadata:             parseMetadata(c.StringSlice("metadata")),
+								Policy:               c.String("policy"),
+								StorageClass:         c.String("storage-class"),
+								PeerGroup:            c.String("peer-group"),
+								Namespace:            c.String("namespace"),
+							},
+						}
+						resp, cerr := globalClient.PinCollection(ctx, col)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "unpin",
+					Usage:     "unpin all the CIDs of a collection",
+					ArgsUsage: "<name>",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.UnpinCollection(ctx, c.Args().First())
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:      "status",
+					Usage:     "show the status of every CID in a collection",
+					ArgsUsage: "<name>",
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.CollectionStatus(ctx, c.Args().First())
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "prefetch",
+			Usage: "Fetch a CID into the IPFS repositories of cluster peers without pinning it",
+			Description: `
+This command asks cluster peers to fetch the whole DAG under the given CID
+into the blockstores of their IPFS daemons, without creating a cluster pin
+or an IPFS pin. It can be used to warm the caches of the peers ahead of a
+pin or of a spike of traffic. The fetched blocks are removed by the next
+garbage collection of the IPFS repositories.
+
+By default, all the cluster peers fetch the content. The --peers flag
+restricts it to the given peers. The command waits until all of them are
+done and shows the result for each one.
+`,
+			ArgsUsage:    "<CID>",
+			BashComplete: completeFirstArg(completionCids),
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "peers",
+					Usage: "Optional comma-separated list of peer IDs or peer names",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				ci, err := cid.Decode(c.Args().First())
+				checkErr("parsing cid", err)
+				var peers []peer.ID
+				if c.String("peers") != "" {
+					peers, err = resolvePeers(strings.Split(c.String("peers"), ","))
+					checkErr("decoding peers", err)
+				}
+				resp, cerr := globalClient.Prefetch(ctx, ci, peers)
+				formatResponse(c, resp, cerr)
+				return nil
+			},
+		},
 
 		{
 			Name:  "version",
@@ -853,15 +2040,175 @@ operations on the contacted peer (as opposed to on every peer).
 			Description: `
 This command retrieves the IPFS Cluster version and can be used
 to check that it matches the CLI version (shown by -v).
+
+When the --all-peers flag is passed, every cluster peer is contacted
+directly and concurrently, and the answers are presented together.
+Values which differ from those of the majority of peers are marked with
+"(!)". Peers are contacted using the same API options given to this
+command: with the libp2p endpoint, their cluster addresses are used,
+otherwise they are expected to expose the HTTP API on the same port as
+the contacted peer.
 `,
 			ArgsUsage: " ",
-			Flags:     []cli.Flag{},
+			Flags: []cli.Flag{
+				allPeersFlag(),
+			},
 			Action: func(c *cli.Context) error {
+				if c.Bool("all-peers") {
+					formatResponse(c, allPeersVersion(ctx), nil)
+					return nil
+				}
 				resp, cerr := globalClient.Version(ctx)
 				formatResponse(c, resp, cerr)
 				return nil
 			},
 		},
+		{
+			Name:  "log",
+			Usage: "Manage logging in the cluster peer",
+			Subcommands: []cli.Command{
+				{
+					Name:  "level",
+					Usage: "change the log level of a logging facility",
+					Description: `
+This command changes the log level of a logging facility in the contacted
+cluster peer at runtime, without restarting it. Changes are not persisted.
+
+The facility can be the name of any cluster component (i.e. "cluster",
+"restapi", "crdt", "pintracker"), "*" for all of them, or one of these
+groups: "consensus", "api" and "tracker". Valid levels are critical, error,
+warning, notice, info and debug.
+
+Example:
+
+$ ipfs-cluster-ctl log level consensus debug
+`,
+					ArgsUsage: "<facility> <level>",
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 2 {
+							checkErr("", errors.New("a facility and a level are needed"))
+						}
+						cerr := globalClient.SetLogLevel(ctx, c.Args().Get(0), c.Args().Get(1))
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "secret",
+			Usage: "Rotate the cluster secret",
+			Subcommands: []cli.Command{
+				{
+					Name:  "rotate",
+					Usage: "start using a new cluster secret",
+					Description: `
+This command starts the rotation of the cluster secret. All cluster peers
+are asked to accept connections using the new secret, while they keep
+using the current one to connect to others. The new secret is saved to
+the "transition_secret" option of their configuration.
+
+When no secret is given, a random one is generated. The secret is printed
+and should be kept, as it is needed to start new peers afterwards.
+
+Once all peers have started the rotation, run "secret finalize" to make
+them use the new secret. Peers which were not running during the rotation
+will need to have the "secret" updated in their configuration manually.
+
+Example:
+
+$ ipfs-cluster-ctl secret rotate
+`,
+					ArgsUsage: "[secret]",
+					Action: func(c *cli.Context) error {
+						var secret []byte
+						var err error
+						if hexSecret := c.Args().First(); hexSecret != "" {
+							secret, err = hex.DecodeString(hexSecret)
+							checkErr("parsing secret", err)
+						} else {
+							secret = make([]byte, 32)
+							_, err = rand.Read(secret)
+							checkErr("generating secret", err)
+						}
+						if len(secret) != 32 {
+							checkErr("", errors.New("the secret should be 64 hexadecimal characters"))
+						}
+						cerr := globalClient.BeginSecretRotation(ctx, secret)
+						checkErr("starting secret rotation", cerr)
+						fmt.Printf("%s\n", hex.EncodeToString(secret))
+						return nil
+					},
+				},
+				{
+					Name:  "finalize",
+					Usage: "complete a cluster secret rotation",
+					Description: `
+This command completes a secret rotation started with "secret rotate". All
+cluster peers start using the new secret and save it to their
+configuration. The previous secret is still accepted for a few minutes
+afterwards. Remember to use the new secret with "--secret" from now on.
+`,
+					Action: func(c *cli.Context) error {
+						cerr := globalClient.FinalizeSecretRotation(ctx)
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "config",
+			Usage: "Manage the configuration of the cluster peers",
+			Subcommands: []cli.Command{
+				{
+					Name:  "push",
+					Usage: "apply a configuration section to all cluster peers",
+					Description: `
+This command sends options of a section of the configuration to all cluster
+peers, which apply them right away and save them to their configuration
+files, so that they do not need to be edited in every peer. Peers only accept them when the contacted
+peer is one of their trusted peers. Only these options can be pushed:
+
+  - disk: metric_ttl
+  - numpin: metric_ttl
+  - stateless: concurrent_pins
+
+The options are read in JSON, as found in the configuration file, from the
+given file, or from the standard input with "-". When no file is given, the
+current options of the contacted peer are pushed. Options which are not
+given keep their current values.
+
+Example:
+
+$ echo '{"metric_ttl": "1m"}' | ipfs-cluster-ctl config push disk -
+`,
+					ArgsUsage: "<section> [<file>]",
+					Action: func(c *cli.Context) error {
+						name := c.Args().First()
+						if name == "" {
+							checkErr("", errors.New("a section is needed"))
+						}
+						section := &api.ConfigSection{Name: name}
+						if path := c.Args().Get(1); path != "" {
+							var r io.Reader = os.Stdin
+							if path != "-" {
+								f, err := os.Open(path)
+								checkErr("opening file", err)
+								defer f.Close()
+								r = f
+							}
+							var raw json.RawMessage
+							checkErr("decoding file", json.NewDecoder(r).Decode(&raw))
+							section.Config = raw
+						}
+						cerr := globalClient.PushConfig(ctx, []*api.ConfigSection{section})
+						formatResponse(c, nil, cerr)
+						return nil
+					},
+				},
+			},
+		},
 		{
 			Name:        "health",
 			Usage:       "Cluster monitoring information",
@@ -921,6 +2268,10 @@ but usually are:
 
 - freespace
 - ping
+
+Metrics include the clock skew between this peer and the peer that sent
+them (which includes the time the metric took to arrive). Large values
+indicate that the clocks of the peers are not in sync.
 `,
 					ArgsUsage: "<metric name>",
 					Action: func(c *cli.Context) error {
@@ -936,6 +2287,85 @@ but usually are:
 						return nil
 					},
 				},
+				{
+					Name:  "alerts",
+					Usage: "List the alerts currently active in this peer",
+					Description: `
+This command displays the alerts received by this peer which are still
+active. Alerts are triggered when the metrics from a cluster peer stop
+arriving as expected (for example, when a peer goes down). An alert stays
+active until a valid metric of the same type is received from the affected
+peer.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Alerts(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "repinning",
+					Usage: "Show the progress of the re-allocation of pins of down or removed peers",
+					Description: `
+This command displays, for every peer which is down or has been removed,
+how many of the pins allocated to it have been re-allocated to other peers.
+Pins are re-allocated immediately when a peer is removed, and once it has
+been down for longer than the "repin_delay" configured in the cluster
+section. They are also re-allocated when a peer is put in maintenance with
+"peers maintenance --repin". The "repin_rate_limit" option limits how many
+pins are re-allocated per minute.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.RepinProgress(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "stats",
+					Usage: "Show a summary of the health of the cluster and its peers",
+					Description: `
+This command displays the number of pins in the shared state, the consensus
+peerset and leader and, for every peer, its free space, how many items it
+tracks in each status, how many are in error and how many pin and unpin
+operations are queued or in progress.
+
+It is designed as a single request for dashboards: use "--enc json"
+and scrape the "/health/stats" REST API endpoint. The Prometheus metrics
+endpoint of each peer provides the full details.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.Stats(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:        "tracker",
+			Usage:       "Inspect the pin tracker",
+			Description: "Inspect the pin tracker",
+			Subcommands: []cli.Command{
+				{
+					Name:  "ops",
+					Usage: "List the operations tracked by the pin tracker of this peer",
+					Description: `
+This command displays the pin and unpin operations which the pin tracker of
+the peer is currently keeping track of, oldest first. For every operation it
+shows the type, the phase (queued, inprogress, done or error), when it was
+created and last updated, the number of attempts (it grows every time a failed
+operation is retried) and the last error, if any.
+
+Unlike "status", which derives a status for every pin, this shows exactly
+what the tracker is doing.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.TrackerOperations(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
 			},
 		},
 		{
@@ -962,6 +2392,180 @@ deamon, otherwise on all IPFS daemons.
 						return nil
 					},
 				},
+				{
+					Name:  "provide",
+					Usage: "show the provide strategy of the contacted peer",
+					Description: `
+This command shows which IPFS daemons of the cluster announce content to the
+DHT and which content they announce (the reprovider strategy), as followed by
+the contacted peer. Use "ipfs provide set" to change it.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.ProvideStrategy(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+					Subcommands: []cli.Command{
+						{
+							Name:  "set",
+							Usage: "set which IPFS daemons announce content to the DHT",
+							Description: `
+This command makes all cluster peers set the reprovider of their IPFS daemons
+to the given strategy: "all" announces all blocks, "pinned" only the pinned
+ones and "roots" only the roots of the pins. When peers are given with
+--peer (by peer ID or peer name), only their IPFS daemons announce content
+and the others disable their reprovider. This avoids every IPFS daemon
+announcing the same blocks in highly replicated clusters.
+
+The IPFS daemons use the new settings once they are restarted. The strategy
+replaces the one in the "provide_strategy" section of the configuration of
+the peers and is kept when they restart. Running the command without
+--strategy clears it.
+`,
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "strategy, s",
+									Usage: "reprovider strategy: all, pinned or roots",
+								},
+								cli.StringSliceFlag{
+									Name:  "peer",
+									Usage: "peer ID or name of a peer which announces content. Can be added multiple times",
+								},
+							},
+							Action: func(c *cli.Context) error {
+								ps := &api.ProvideStrategy{Strategy: c.String("strategy")}
+								for _, p := range c.StringSlice("peer") {
+									pid, err := resolvePeer(p)
+									checkErr("parsing peer ID", err)
+									ps.Peers = append(ps.Peers, pid)
+								}
+								cerr := globalClient.SetProvideStrategy(ctx, ps)
+								formatResponse(c, nil, cerr)
+								return nil
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        "shards",
+			Usage:       "Manage the shards of sharded content",
+			Description: "Manage the shards of sharded content",
+			Subcommands: []cli.Command{
+				{
+					Name:  "gc",
+					Usage: "Unpin the shards which are not referenced by any pin",
+					Description: `
+This command unpins the shards and cluster DAGs of sharded content which are
+not referenced by any pin (for example, because unpinning the content failed
+half-way) and lists them. When sharded content is unpinned, the shards which
+are shared with other sharded content are kept, so this is not needed in
+normal operation.
+
+Do not run this command while sharded content is being added: the shards of
+unfinished additions are not referenced yet and would be unpinned.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.ShardsGC(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:        "checkpoint",
+			Usage:       "Manage checkpoints of the cluster state stored in IPFS",
+			Description: "Manage checkpoints of the cluster state stored in IPFS",
+			Subcommands: []cli.Command{
+				{
+					Name:  "create",
+					Usage: "Write the cluster state to IPFS and pin it everywhere",
+					Description: `
+This command writes the shared state (the pinset) as a DAG to the IPFS daemon
+of the contacted peer and pins its root in the cluster with replication
+factor -1, so that every peer keeps a copy. The pin of the checkpoint is
+returned. Its CID can be used with "checkpoint export" to restore the state.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.StateCheckpoint(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "ls",
+					Usage: "List the state checkpoints",
+					Description: `
+This command lists the pins of the state checkpoints in the cluster. The
+number of pins in every checkpoint is stored as the "state-checkpoint"
+metadata key.
+`,
+					Action: func(c *cli.Context) error {
+						resp, cerr := globalClient.StateCheckpoints(ctx)
+						formatResponse(c, resp, cerr)
+						return nil
+					},
+				},
+				{
+					Name:  "export",
+					Usage: "Export the pins in a state checkpoint",
+					Description: `
+This command reads the state checkpoint with the given CID and writes its
+pins in the format used by "ipfs-cluster-service state export", so that
+it can be restored with "ipfs-cluster-service state import".
+
+The pins are written to the standard output unless --file is given.
+`,
+					ArgsUsage: "<cid>",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "write the pins to the given file",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						ci, err := cid.Decode(c.Args().First())
+						checkErr("parsing cid", err)
+						pins, cerr := globalClient.StateCheckpointPins(ctx, ci)
+						checkErr("reading checkpoint", cerr)
+
+						var w io.Writer = os.Stdout
+						if path := c.String("file"); path != "" {
+							f, err := os.Create(path)
+							checkErr("creating file", err)
+							defer f.Close()
+							w = f
+						}
+						enc := json.NewEncoder(w)
+						for _, pin := range pins {
+							checkErr("writing pins", enc.Encode(pin))
+						}
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:      "completion",
+			Usage:     "Print a shell completion script",
+			ArgsUsage: "<bash|zsh|fish>",
+			Description: `
+This command prints a completion script for the given shell (bash, zsh or
+fish). Completions are dynamic: besides commands and flags, they include
+the current cluster peer IDs and the CIDs in the pinset, obtained from the
+API using the same global options (--host etc.) given to the command being
+completed.
+
+To enable completions, load the output of this command in your shell. For
+example, for bash:
+
+  $ source <(ipfs-cluster-ctl completion bash)
+`,
+			Action: func(c *cli.Context) error {
+				checkErr("generating completion", printCompletion(c.Args().First()))
+				return nil
 			},
 		},
 		{
@@ -1108,6 +2712,38 @@ func waitFor(
 	return client.WaitFor(ctx, globalClient, fp)
 }
 
+// pinManifest pins the items in the given manifest file and prints their
+// acknowledgements. It exits with an error when any item fails.
+func pinManifest(ctx context.Context, c *cli.Context, fname, format string, opts api.PinOptions) {
+	if format == "" {
+		format = api.ManifestJSON
+		if strings.EqualFold(filepath.Ext(fname), ".csv") {
+			format = api.ManifestCSV
+		}
+	}
+	f, err := os.Open(fname)
+	checkErr("opening the manifest", err)
+	entries, err := api.ParseManifest(f, format)
+	f.Close()
+	checkErr("reading the manifest", err)
+
+	acks, cerr := globalClient.PinManifest(ctx, entries, opts)
+	if cerr != nil {
+		formatResponse(c, nil, cerr)
+		return
+	}
+	failed := 0
+	for _, ack := range acks {
+		if ack.Error != "" {
+			failed++
+		}
+	}
+	formatResponse(c, acks, nil)
+	if failed > 0 {
+		checkErr("pinning the manifest", fmt.Errorf("%d items could not be pinned", failed))
+	}
+}
+
 func parseMetadata(metadata []string) map[string]string {
 	metadataMap := make(map[string]string)
 	for _, str := range metadata {
@@ -1121,6 +2757,19 @@ func parseMetadata(metadata []string) map[string]string {
 	return metadataMap
 }
 
+func parseOrigins(origins string) []api.Multiaddr {
+	if origins == "" {
+		return nil
+	}
+	var maddrs []api.Multiaddr
+	for _, str := range strings.Split(origins, ",") {
+		maddr, err := api.NewMultiaddr(strings.TrimSpace(str))
+		checkErr("parsing origins", err)
+		maddrs = append(maddrs, maddr)
+	}
+	return maddrs
+}
+
 // func setupTracing(config tracingConfig) {
 // 	if !config.Enable {
 // 		return
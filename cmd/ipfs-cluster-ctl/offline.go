@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+
+	cid "github.com/ipfs/go-cid"
+	cli "github.com/urfave/cli"
+)
+
+// Names of the configuration files looked up when --state points to the
+// configuration folder of a cluster peer.
+const (
+	offlineConfigFile   = "service.json"
+	offlineIdentityFile = "identity.json"
+)
+
+// offlineCommands lists the commands which can run with --offline.
+var offlineCommands = [][]string{
+	{"pin", "ls"},
+	{"status"},
+}
+
+var errNotFoundOffline = errors.New("cid is not part of the state")
+
+// offlineState holds the pinset loaded with --offline.
+var offlineState []*api.Pin
+
+// isOfflineCommand returns true when the given command-line arguments
+// correspond to one of the offlineCommands.
+func isOfflineCommand(args []string) bool {
+	for _, cmd := range offlineCommands {
+		if len(args) < len(cmd) {
+			continue
+		}
+		match := true
+		for i := range cmd {
+			if args[i] != cmd[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// loadOfflineState reads the pinset from the given path, which can be a
+// file produced by "ipfs-cluster-service state export" or the configuration
+// folder of a cluster peer which is not running.
+func loadOfflineState(path string) ([]*api.Pin, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		return loadOfflineStateFolder(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return loadOfflineStateFile(f)
+}
+
+func loadOfflineStateFile(r io.Reader) ([]*api.Pin, error) {
+	var pins []*api.Pin
+	dec := json.NewDecoder(r)
+	for {
+		var pin api.Pin
+		err := dec.Decode(&pin)
+		if err == io.EOF {
+			return pins, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, &pin)
+	}
+}
+
+func loadOfflineStateFolder(path string) ([]*api.Pin, error) {
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(
+		filepath.Join(path, offlineConfigFile),
+		filepath.Join(path, offlineIdentityFile),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("loading configurations: %s", err)
+	}
+	defer cfgHelper.Manager().Shutdown()
+
+	mgr, err := cmdutils.NewStateManagerWithHelper(cfgHelper)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := mgr.GetStore()
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	st, err := mgr.GetOfflineState(store)
+	if err != nil {
+		return nil, err
+	}
+	return st.List(context.Background())
+}
+
+// offlineAllocation returns the pin for the given cid from the offline
+// state.
+func offlineAllocation(ci cid.Cid) (*api.Pin, error) {
+	for _, pin := range offlineState {
+		if pin.Cid.Equals(ci) {
+			return pin, nil
+		}
+	}
+	return nil, errNotFoundOffline
+}
+
+// offlineAllocations returns the pins of the given types from the offline
+// state.
+func offlineAllocations(filter api.PinType) []*api.Pin {
+	var pins []*api.Pin
+	for _, pin := range offlineState {
+		if pin.Type&filter > 0 {
+			pins = append(pins, pin)
+		}
+	}
+	return pins
+}
+
+// offlineStatusNote labels the output of "status" with --offline.
+const offlineStatusNote = "offline: listing the pinset in the state. The IPFS status of the items is unknown"
+
+// offlinePinset is the answer of "status" with --offline. It is clearly
+// labeled as a pinset listing, so that it is not mistaken for the status of
+// the items.
+type offlinePinset struct {
+	Note string     `json:"note"`
+	Pins []*api.Pin `json:"pins"`
+}
+
+func newOfflinePinset(pins ...*api.Pin) *offlinePinset {
+	if pins == nil {
+		pins = []*api.Pin{}
+	}
+	return &offlinePinset{
+		Note: offlineStatusNote,
+		Pins: pins,
+	}
+}
+
+// offlineStatus runs the status command with --offline. Only the pins in
+// the state can be shown, as the IPFS status of the items is unknown.
+func offlineStatus(c *cli.Context) error {
+	for _, f := range []string{"local", "filter", "peer", "summary", "changed-since", "all-peers"} {
+		if c.IsSet(f) {
+			checkErr("", fmt.Errorf("--%s cannot be used with --offline", f))
+		}
+	}
+
+	cidStr := c.Args().First()
+	if cidStr == "" {
+		formatResponse(c, newOfflinePinset(offlineAllocations(api.AllType)...), nil)
+		return nil
+	}
+
+	ci, err := cid.Decode(cidStr)
+	checkErr("parsing cid", err)
+	resp, err := offlineAllocation(ci)
+	checkErr("reading offline state", err)
+	formatResponse(c, newOfflinePinset(resp), nil)
+	return nil
+}
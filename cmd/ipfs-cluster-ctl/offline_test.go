@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestIsOfflineCommand(t *testing.T) {
+	testcases := []struct {
+		args    []string
+		offline bool
+	}{
+		{[]string{"pin", "ls"}, true},
+		{[]string{"pin", "ls", test.Cid1.String()}, true},
+		{[]string{"status"}, true},
+		{[]string{"pin", "add"}, false},
+		{[]string{"pin"}, false},
+		{[]string{}, false},
+	}
+	for _, tc := range testcases {
+		if isOfflineCommand(tc.args) != tc.offline {
+			t.Errorf("%v: expected offline to be %t", tc.args, tc.offline)
+		}
+	}
+}
+
+func TestLoadOfflineStateFile(t *testing.T) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, c := range []*api.Pin{
+		api.PinCid(test.Cid1),
+		api.PinCid(test.Cid2),
+	} {
+		if err := enc.Encode(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pins, err := loadOfflineStateFile(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 2 || !pins[0].Cid.Equals(test.Cid1) || !pins[1].Cid.Equals(test.Cid2) {
+		t.Fatal("unexpected pins loaded")
+	}
+
+	_, err = loadOfflineStateFile(strings.NewReader("not json"))
+	if err == nil {
+		t.Error("expected an error with a bad state file")
+	}
+}
+
+func TestOfflineAllocations(t *testing.T) {
+	defer func(st []*api.Pin) { offlineState = st }(offlineState)
+
+	meta := api.PinCid(test.Cid2)
+	meta.Type = api.MetaType
+	offlineState = []*api.Pin{api.PinCid(test.Cid1), meta}
+
+	pin, err := offlineAllocation(test.Cid2)
+	if err != nil || pin != meta {
+		t.Error("expected the pin from the offline state")
+	}
+	if _, err := offlineAllocation(test.Cid3); err != errNotFoundOffline {
+		t.Error("expected a not found error")
+	}
+
+	if len(offlineAllocations(api.AllType)) != 2 {
+		t.Error("expected all the pins")
+	}
+	data := offlineAllocations(api.DataType)
+	if len(data) != 1 || !data[0].Cid.Equals(test.Cid1) {
+		t.Error("expected only the data pins")
+	}
+}
+
+func TestOfflinePinset(t *testing.T) {
+	empty := newOfflinePinset()
+	if empty.Pins == nil || len(empty.Pins) != 0 {
+		t.Error("expected an empty list of pins")
+	}
+
+	resp := newOfflinePinset(api.PinCid(test.Cid1))
+	out, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Note string     `json:"note"`
+		Pins []*api.Pin `json:"pins"`
+	}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Note != offlineStatusNote {
+		t.Error("the output should be labeled as a pinset listing")
+	}
+	if len(decoded.Pins) != 1 || !decoded.Pins[0].Cid.Equals(test.Cid1) {
+		t.Error("expected the pins in the output")
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// knownPeerNames caches the peernames of the cluster peers, indexed by peer
+// ID. It is filled on first use.
+var knownPeerNames map[string]string
+
+// peerNames returns the peernames of the cluster peers. Names are not
+// available offline or when the contacted peer does not provide them.
+func peerNames() map[string]string {
+	if knownPeerNames != nil {
+		return knownPeerNames
+	}
+	knownPeerNames = make(map[string]string)
+	if globalClient == nil {
+		return knownPeerNames
+	}
+	names, err := globalClient.PeerNames(context.Background())
+	if err == nil {
+		knownPeerNames = names
+	}
+	return knownPeerNames
+}
+
+// resolvePeer returns the peer ID for the given string, which may be a peer
+// ID or the peername of a cluster peer.
+func resolvePeer(s string) (peer.ID, error) {
+	if pid, err := peer.IDB58Decode(s); err == nil {
+		return pid, nil
+	}
+
+	var found []string
+	for id, name := range peerNames() {
+		if name == s {
+			found = append(found, id)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("%s is neither a peer ID nor the name of a cluster peer", s)
+	case 1:
+		return peer.IDB58Decode(found[0])
+	default:
+		return "", fmt.Errorf("several cluster peers are named %s. Use the peer ID", s)
+	}
+}
+
+// resolvePeers resolves a list of peer IDs or peernames.
+func resolvePeers(strs []string) ([]peer.ID, error) {
+	pids := make([]peer.ID, 0, len(strs))
+	for _, s := range strs {
+		pid, err := resolvePeer(s)
+		if err != nil {
+			return nil, err
+		}
+		pids = append(pids, pid)
+	}
+	return pids, nil
+}
+
+// peerLabel returns the peer ID followed by the peername, when known.
+func peerLabel(pid peer.ID, name string) string {
+	id := peer.IDB58Encode(pid)
+	if name == "" {
+		name = peerNames()[id]
+	}
+	if name == "" {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", id, name)
+}
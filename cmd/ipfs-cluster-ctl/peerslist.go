@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+)
+
+// peerSortColumns are the columns by which "peers ls" can sort the peers,
+// along with the function comparing two peers by that column.
+var peerSortColumns = map[string]func(a, b *api.ID) bool{
+	"id": func(a, b *api.ID) bool {
+		return a.ID.Pretty() < b.ID.Pretty()
+	},
+	"name": func(a, b *api.ID) bool {
+		return a.Peername < b.Peername
+	},
+	"rtt": func(a, b *api.ID) bool {
+		return a.RTT < b.RTT
+	},
+	"last-metric": func(a, b *api.ID) bool {
+		return a.LastMetric < b.LastMetric
+	},
+	"errors": func(a, b *api.ID) bool {
+		return peerErrors(a) < peerErrors(b)
+	},
+	"ipfs-version": func(a, b *api.ID) bool {
+		return ipfsVersion(a) < ipfsVersion(b)
+	},
+}
+
+// peerSortColumnNames returns the names of the columns accepted by
+// sortPeers.
+func peerSortColumnNames() string {
+	names := make([]string, 0, len(peerSortColumns))
+	for k := range peerSortColumns {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// sortPeers sorts the peers by the given column.
+func sortPeers(peers []*api.ID, column string, reverse bool) error {
+	less, ok := peerSortColumns[column]
+	if !ok {
+		return fmt.Errorf("cannot sort by %q. Valid columns: %s", column, peerSortColumnNames())
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		if reverse {
+			return less(peers[j], peers[i])
+		}
+		return less(peers[i], peers[j])
+	})
+	return nil
+}
+
+// peersFilter selects peers in "peers ls". Zero values select all peers.
+type peersFilter struct {
+	// only peers with errors: unreachable, with IPFS errors or with
+	// pins in error.
+	errors bool
+	// only peers whose round-trip time is at least this.
+	minRTT time.Duration
+	// only peers whose last metric is older than this.
+	stale time.Duration
+}
+
+// filterPeers returns the peers selected by the filter.
+func filterPeers(peers []*api.ID, f peersFilter, now time.Time) []*api.ID {
+	out := make([]*api.ID, 0, len(peers))
+	for _, p := range peers {
+		if f.errors && p.Error == "" && (p.IPFS == nil || p.IPFS.Error == "") && peerErrors(p) == 0 {
+			continue
+		}
+		if f.minRTT > 0 && p.RTT < f.minRTT {
+			continue
+		}
+		if f.stale > 0 && p.LastMetric != 0 && now.Sub(time.Unix(0, p.LastMetric)) < f.stale {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// peerErrors returns the number of pins in error in the peer, as reported
+// in its last pin status summary.
+func peerErrors(p *api.ID) int {
+	if p.PinStatus == nil {
+		return 0
+	}
+	return p.PinStatus.Error
+}
+
+// ipfsVersion returns the agent version of the IPFS daemon of the peer.
+func ipfsVersion(p *api.ID) string {
+	if p.IPFS == nil {
+		return ""
+	}
+	return p.IPFS.AgentVersion
+}
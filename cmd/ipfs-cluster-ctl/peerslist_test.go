@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func TestSortAndFilterPeers(t *testing.T) {
+	now := time.Now()
+	peers := []*api.ID{
+		{
+			ID:         test.PeerID1,
+			Peername:   "b",
+			RTT:        20 * time.Millisecond,
+			LastMetric: now.UnixNano(),
+			IPFS:       &api.IPFSID{AgentVersion: "go-ipfs/0.4.23/"},
+		},
+		{
+			ID:         test.PeerID2,
+			Peername:   "a",
+			RTT:        5 * time.Millisecond,
+			LastMetric: now.Add(-time.Hour).UnixNano(),
+			PinStatus:  &api.PinStatusSummary{Error: 3},
+			IPFS:       &api.IPFSID{AgentVersion: "go-ipfs/0.4.22/"},
+		},
+		{
+			ID:       test.PeerID3,
+			Peername: "c",
+			Error:    "unreachable",
+		},
+	}
+
+	err := sortPeers(peers, "name", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peers[0].Peername != "a" || peers[2].Peername != "c" {
+		t.Error("peers should be sorted by name")
+	}
+
+	err = sortPeers(peers, "rtt", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peers[0].ID != test.PeerID1 || peers[2].ID != test.PeerID3 {
+		t.Error("peers should be sorted by decreasing rtt")
+	}
+
+	if sortPeers(peers, "color", false) == nil {
+		t.Error("expected an error sorting by an unknown column")
+	}
+
+	filtered := filterPeers(peers, peersFilter{errors: true}, now)
+	if len(filtered) != 2 {
+		t.Error("expected the peers with errors and pins in error")
+	}
+
+	filtered = filterPeers(peers, peersFilter{minRTT: 10 * time.Millisecond}, now)
+	if len(filtered) != 1 || filtered[0].ID != test.PeerID1 {
+		t.Error("expected the peers slower than 10ms")
+	}
+
+	filtered = filterPeers(peers, peersFilter{stale: time.Minute}, now)
+	if len(filtered) != 2 {
+		t.Error("expected the peers without recent metrics")
+	}
+}
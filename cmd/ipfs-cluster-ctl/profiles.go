@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	cli "github.com/urfave/cli"
+)
+
+// DefaultProfilesFile is the location of the connection profiles file,
+// relative to the user's home.
+const DefaultProfilesFile = ".ipfs-cluster-ctl/profiles.json"
+
+// profile holds a named set of connection options. Each field corresponds
+// to the global flag with the same name. Flags given in the command line
+// take precedence over the values in the profile.
+type profile struct {
+	Host               string `json:"host,omitempty"`
+	Secret             string `json:"secret,omitempty"`
+	HTTPS              bool   `json:"https,omitempty"`
+	NoCheckCertificate bool   `json:"no_check_certificate,omitempty"`
+	BasicAuth          string `json:"basic_auth,omitempty"`
+	ForceHTTP          bool   `json:"force_http,omitempty"`
+	Timeout            int    `json:"timeout,omitempty"`
+}
+
+// flags returns the global flag values set by the profile.
+func (p *profile) flags() map[string]string {
+	f := make(map[string]string)
+	if p.Host != "" {
+		f["host"] = p.Host
+	}
+	if p.Secret != "" {
+		f["secret"] = p.Secret
+	}
+	if p.HTTPS {
+		f["https"] = "true"
+	}
+	if p.NoCheckCertificate {
+		f["no-check-certificate"] = "true"
+	}
+	if p.BasicAuth != "" {
+		f["basic-auth"] = p.BasicAuth
+	}
+	if p.ForceHTTP {
+		f["force-http"] = "true"
+	}
+	if p.Timeout > 0 {
+		f["timeout"] = strconv.Itoa(p.Timeout)
+	}
+	return f
+}
+
+func defaultProfilesPath() string {
+	// Same approach as ipfs-cluster-service: use HOME when set and
+	// fall back to the user's home directory otherwise.
+	home := os.Getenv("HOME")
+	if home == "" {
+		usr, err := user.Current()
+		if err != nil {
+			return ""
+		}
+		home = usr.HomeDir
+	}
+	return filepath.Join(home, DefaultProfilesFile)
+}
+
+// loadProfile reads the profile with the given name from the profiles file
+// at path. The file contains a JSON object with profile names as keys.
+func loadProfile(path, name string) (*profile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles map[string]*profile
+	err = json.Unmarshal(data, &profiles)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", path, err)
+	}
+
+	p, ok := profiles[name]
+	if !ok || p == nil {
+		return nil, fmt.Errorf("profile %q not found in %s", name, path)
+	}
+	return p, nil
+}
+
+// applyProfile sets the global flags from the given profile, unless they
+// were explicitly given in the command line.
+func applyProfile(c *cli.Context, p *profile) error {
+	for name, value := range p.flags() {
+		if c.GlobalIsSet(name) {
+			continue
+		}
+		if err := c.GlobalSet(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
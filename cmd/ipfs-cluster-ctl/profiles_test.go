@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cli "github.com/urfave/cli"
+)
+
+const testProfiles = `{
+  "prod": {
+    "host": "/dns4/cluster.example.com/tcp/9094",
+    "https": true,
+    "basic_auth": "user:pass",
+    "timeout": 120
+  },
+  "empty": null
+}`
+
+func TestLoadProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "profiles.json")
+	err = ioutil.WriteFile(path, []byte(testProfiles), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := loadProfile(path, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := p.flags()
+	expected := map[string]string{
+		"host":       "/dns4/cluster.example.com/tcp/9094",
+		"https":      "true",
+		"basic-auth": "user:pass",
+		"timeout":    "120",
+	}
+	if len(flags) != len(expected) {
+		t.Errorf("expected %d flags, got %v", len(expected), flags)
+	}
+	for k, v := range expected {
+		if flags[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, flags[k])
+		}
+	}
+
+	if _, err := loadProfile(path, "staging"); err == nil {
+		t.Error("expected an error for a missing profile")
+	}
+	if _, err := loadProfile(path, "empty"); err == nil {
+		t.Error("expected an error for an empty profile")
+	}
+	if _, err := loadProfile(filepath.Join(dir, "missing.json"), "prod"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	err = ioutil.WriteFile(path, []byte("{"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadProfile(path, "prod"); err == nil {
+		t.Error("expected an error for an invalid file")
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	set := flag.NewFlagSet("ipfs-cluster-ctl", flag.ContinueOnError)
+	set.String("host", "/ip4/127.0.0.1/tcp/9094", "")
+	set.String("basic-auth", "", "")
+	set.Bool("https", false, "")
+	set.Int("timeout", 0, "")
+	err := set.Parse([]string{"--host", "/ip4/10.0.0.1/tcp/9094"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	global := cli.NewContext(nil, set, nil)
+	c := cli.NewContext(nil, flag.NewFlagSet("id", flag.ContinueOnError), global)
+
+	p := &profile{
+		Host:      "/dns4/cluster.example.com/tcp/9094",
+		BasicAuth: "user:pass",
+		HTTPS:     true,
+		Timeout:   120,
+	}
+	if err := applyProfile(c, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if h := c.GlobalString("host"); h != "/ip4/10.0.0.1/tcp/9094" {
+		t.Error("the command line host should take precedence, got", h)
+	}
+	if a := c.GlobalString("basic-auth"); a != "user:pass" {
+		t.Error("expected basic-auth from the profile, got", a)
+	}
+	if !c.GlobalBool("https") {
+		t.Error("expected https from the profile")
+	}
+	if to := c.GlobalInt("timeout"); to != 120 {
+		t.Error("expected timeout from the profile, got", to)
+	}
+}
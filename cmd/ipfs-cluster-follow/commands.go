@@ -202,7 +202,7 @@ func initCluster(c *cli.Context, ignoreReinit bool, cfgURL string) error {
 		cfgURL = fmt.Sprintf("http://%s/ipns/%s", gw, cfgURL)
 	}
 
-	cfgHelper := cmdutils.NewConfigHelper(configPath, identityPath, "crdt")
+	cfgHelper := cmdutils.NewConfigHelper(configPath, identityPath, "crdt", "")
 	cfgHelper.Manager().Shutdown()
 	cfgHelper.Manager().Source = cfgURL
 	err := cfgHelper.Manager().Default()
@@ -325,7 +325,7 @@ func runCmd(c *cli.Context) error {
 	}
 	alloc := descendalloc.NewAllocator()
 
-	stmgr, err := cmdutils.NewStateManager(cfgHelper.GetConsensus(), cfgHelper.Identity(), cfgs)
+	stmgr, err := cmdutils.NewStateManager(cfgHelper.GetConsensus(), cfgHelper.GetDatastore(), cfgHelper.Identity(), cfgs)
 	if err != nil {
 		return cli.Exit(errors.Wrap(err, "creating state manager"), 1)
 	}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	manet "github.com/multiformats/go-multiaddr-net"
+	cli "github.com/urfave/cli"
+)
+
+// bundleTimeout limits how long each request to the running peer waits
+// for an answer when gathering the debug bundle.
+var bundleTimeout = 30 * time.Second
+
+// redactedValue replaces secrets in the configuration files included in
+// debug bundles.
+const redactedValue = "<redacted>"
+
+// redactedKeys are the configuration keys whose values are never included
+// in debug bundles.
+var redactedKeys = map[string]bool{
+	"secret":                 true,
+	"transition_secret":      true,
+	"private_key":            true,
+	"password":               true,
+	"basic_auth_credentials": true,
+}
+
+// redactJSON replaces the values of all the redactedKeys, at any depth,
+// in the given JSON document. For objects (i.e. basic auth credentials),
+// the keys are kept and only the values are replaced.
+func redactJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue(doc), "", "  ")
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range val {
+			if !redactedKeys[k] {
+				val[k] = redactValue(sub)
+				continue
+			}
+			if obj, ok := sub.(map[string]interface{}); ok {
+				for user := range obj {
+					obj[user] = redactedValue
+				}
+				continue
+			}
+			if sub != "" && sub != nil {
+				val[k] = redactedValue
+			}
+		}
+	case []interface{}:
+		for i, sub := range val {
+			val[i] = redactValue(sub)
+		}
+	}
+	return v
+}
+
+// tailLines returns the last n lines of the given file.
+func tailLines(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := make([]string, 0, n)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(lines) == n {
+			lines = lines[1:]
+		}
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// debugBundle collects the files in a bundle, along with the errors
+// found while gathering them.
+type debugBundle struct {
+	files  []string
+	data   map[string][]byte
+	errors []string
+}
+
+func (b *debugBundle) add(name string, data []byte, err error) {
+	if err != nil {
+		b.errors = append(b.errors, fmt.Sprintf("%s: %s", name, err))
+		return
+	}
+	b.files = append(b.files, name)
+	b.data[name] = data
+}
+
+func (b *debugBundle) addJSON(name string, obj interface{}, err error) {
+	if err != nil {
+		b.add(name, nil, err)
+		return
+	}
+	data, err := json.MarshalIndent(obj, "", "  ")
+	b.add(name, data, err)
+}
+
+func (b *debugBundle) write(path string) error {
+	if len(b.errors) > 0 {
+		b.add("errors.txt", []byte(strings.Join(b.errors, "\n")+"\n"), nil)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	now := time.Now()
+	prefix := strings.TrimSuffix(filepath.Base(path), ".tar.gz")
+	for _, name := range b.files {
+		data := b.data[name]
+		hdr := &tar.Header{
+			Name:    filepath.Join(prefix, name),
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gzw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// fetchGoroutines obtains a goroutine dump from the pprof endpoints served
+// along with the Prometheus metrics.
+func fetchGoroutines(ctx context.Context, addr string) ([]byte, error) {
+	url := fmt.Sprintf("http://%s/debug/pprof/goroutine?debug=2", addr)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// debugBundleCmd gathers information useful for bug reports into a
+// tarball. Anything which cannot be obtained (i.e. because the peer is not
+// running) is listed in the errors.txt file of the bundle.
+func debugBundleCmd(c *cli.Context) error {
+	file := c.String("file")
+	if file == "" {
+		file = fmt.Sprintf("ipfs-cluster-debug-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+	}
+
+	b := &debugBundle{data: make(map[string][]byte)}
+	b.add("version.txt", []byte(version.Version.String()+"\n"), nil)
+
+	for _, p := range []struct{ name, path string }{
+		{"service.json", configPath},
+		{"identity.json", identityPath},
+	} {
+		data, err := ioutil.ReadFile(p.path)
+		if err == nil {
+			data, err = redactJSON(data)
+		}
+		b.add(p.name, data, err)
+	}
+
+	if n := c.Int("log-lines"); n > 0 {
+		logPath := filepath.Join(filepath.Dir(configPath), detachedLogFile)
+		data, err := tailLines(logPath, n)
+		if os.IsNotExist(err) {
+			b.errors = append(b.errors, fmt.Sprintf("%s: no log file found (the peer only writes it when detached)", detachedLogFile))
+		} else {
+			b.add(detachedLogFile, data, err)
+		}
+	}
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	if err != nil {
+		b.add("configuration", nil, err)
+	} else {
+		cfgHelper.Manager().Shutdown()
+		metricsCfg := cfgHelper.Configs().Metrics
+		if !metricsCfg.EnableStats {
+			b.errors = append(b.errors, "goroutines.txt: enable_stats is not set, the pprof endpoints are not available")
+		} else {
+			_, addr, err := manet.DialArgs(metricsCfg.PrometheusEndpoint)
+			if err == nil {
+				ctx, cancel := context.WithTimeout(context.Background(), bundleTimeout)
+				var data []byte
+				data, err = fetchGoroutines(ctx, addr)
+				cancel()
+				b.add("goroutines.txt", data, err)
+			} else {
+				b.add("goroutines.txt", nil, err)
+			}
+		}
+	}
+
+	apiClient, err := localAPIClient()
+	if err != nil {
+		b.add("api", nil, err)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), bundleTimeout)
+		defer cancel()
+
+		id, err := apiClient.ID(ctx)
+		b.addJSON("id.json", id, err)
+		peers, err := apiClient.Peers(ctx)
+		b.addJSON("peers.json", peers, err)
+		ops, err := apiClient.TrackerOperations(ctx)
+		b.addJSON("tracker_operations.json", ops, err)
+
+		names, err := apiClient.MetricNames(ctx)
+		if err != nil {
+			b.add("metrics.json", nil, err)
+		} else {
+			metrics := make(map[string]interface{})
+			for _, name := range names {
+				ms, err := apiClient.Metrics(ctx, name)
+				if err != nil {
+					b.errors = append(b.errors, fmt.Sprintf("metrics.json: %s: %s", name, err))
+					continue
+				}
+				metrics[name] = ms
+			}
+			b.addJSON("metrics.json", metrics, nil)
+		}
+	}
+
+	checkErr("writing debug bundle", b.write(file))
+
+	if n := len(b.errors); n > 0 {
+		out("debug bundle written to %s (%d items could not be collected, see errors.txt)\n", file, n)
+		return nil
+	}
+	out("debug bundle written to %s\n", file)
+	return nil
+}
@@ -2,25 +2,33 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
 	ipfscluster "github.com/ipfs/ipfs-cluster"
-	"github.com/ipfs/ipfs-cluster/allocator/descendalloc"
+	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/api/ipfsproxy"
 	"github.com/ipfs/ipfs-cluster/api/rest"
 	"github.com/ipfs/ipfs-cluster/cmdutils"
 	"github.com/ipfs/ipfs-cluster/config"
 	"github.com/ipfs/ipfs-cluster/consensus/crdt"
 	"github.com/ipfs/ipfs-cluster/consensus/raft"
+	"github.com/ipfs/ipfs-cluster/federation"
 	"github.com/ipfs/ipfs-cluster/informer/disk"
 	"github.com/ipfs/ipfs-cluster/ipfsconn/ipfshttp"
+	"github.com/ipfs/ipfs-cluster/ipfsconn/nullconn"
 	"github.com/ipfs/ipfs-cluster/monitor/pubsubmon"
 	"github.com/ipfs/ipfs-cluster/observations"
 	"github.com/ipfs/ipfs-cluster/pintracker/stateless"
 	"go.opencensus.io/tag"
 
+	semver "github.com/blang/semver"
 	ds "github.com/ipfs/go-datastore"
+	fslock "github.com/ipfs/go-fs-lock"
 	host "github.com/libp2p/go-libp2p-core/host"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
@@ -43,6 +51,14 @@ func parseBootstraps(flagVal []string) (bootstraps []ma.Multiaddr) {
 
 // Runs the cluster peer
 func daemon(c *cli.Context) error {
+	if c.Bool("detach") {
+		return detachDaemon()
+	}
+
+	if c.String("multi") != "" {
+		return multiDaemon(c)
+	}
+
 	logger.Info("Initializing. For verbose output run with \"-l debug\". Please wait...")
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -52,6 +68,7 @@ func daemon(c *cli.Context) error {
 	}
 
 	// Execution lock
+	locker.pidFile = c.String("pid-file")
 	locker.lock()
 	defer locker.tryUnlock()
 
@@ -67,6 +84,13 @@ func daemon(c *cli.Context) error {
 	}
 	cfgHelper.SetupTracing(c.Bool("tracing"))
 
+	if token := c.String("join-token"); token != "" {
+		if len(bootstraps) > 0 {
+			checkErr("", errors.New("--join-token and --bootstrap cannot be used together"))
+		}
+		bootstraps = joinTokenBootstraps(token, cfgs.Cluster.Secret)
+	}
+
 	// Setup bootstrapping
 	raftStaging := false
 	switch cfgHelper.GetConsensus() {
@@ -87,9 +111,20 @@ func daemon(c *cli.Context) error {
 		cfgs.Cluster.LeaveOnShutdown = true
 	}
 
+	if c.Bool("follow") {
+		if cfgHelper.GetConsensus() != cfgs.Crdt.ConfigKey() {
+			checkErr("", errors.New("--follow can only be used with \"crdt\" consensus"))
+		}
+		setupFollowerMode(cfgs)
+	}
+
 	host, pubsub, dht, err := ipfscluster.NewClusterHost(ctx, cfgHelper.Identity(), cfgs.Cluster)
 	checkErr("creating libp2p host", err)
 
+	if raftStaging {
+		fetchRaftSnapshot(ctx, host, cfgs.Raft, bootstraps, c.Duration("bootstrap-timeout"))
+	}
+
 	cluster, err := createCluster(ctx, c, cfgHelper, host, pubsub, dht, raftStaging)
 	checkErr("starting cluster", err)
 
@@ -97,10 +132,88 @@ func daemon(c *cli.Context) error {
 	// if bootstrapping fails, consensus will never be ready
 	// and timeout. So this can happen in background and we
 	// avoid worrying about error handling here (since Cluster
-	// will realize).
-	go bootstrap(ctx, cluster, bootstraps)
+	// will realize). Failed attempts are retried during the
+	// bootstrap-timeout window.
+	go bootstrap(ctx, cluster, bootstraps, c.Duration("bootstrap-timeout"))
+
+	cluster.SetUpgradeFunc(upgradeFunc(cluster))
+	cluster.SetSharedConfigs(cfgHelper.Manager(), cfgs.Diskinf, cfgs.Numpininf, cfgs.Statelesstracker)
 
-	return cmdutils.HandleSignals(ctx, cancel, cluster, host, dht)
+	err = cmdutils.HandleSignals(ctx, cancel, cluster, host, dht)
+	if err != nil {
+		return err
+	}
+	// Only returns when a restart was requested and fails.
+	checkErr("restarting after upgrade", cmdutils.RestartIfRequested())
+	return nil
+}
+
+// detachedLogFile is the file, in the configuration folder, which receives
+// the output of a daemon started with --detach.
+const detachedLogFile = "ipfs-cluster-service.log"
+
+// detachStartWait is the time during which a detached daemon is watched for
+// early failures before returning.
+var detachStartWait = 2 * time.Second
+
+// stripDetachFlag removes the --detach flag, in any of its forms (-detach,
+// --detach=true...), from the given arguments. Arguments after "--" are
+// kept as they are.
+func stripDetachFlag(args []string) []string {
+	var stripped []string
+	for i, arg := range args {
+		if arg == "--" {
+			return append(stripped, args[i:]...)
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name != arg && strings.SplitN(name, "=", 2)[0] == "detach" {
+			continue
+		}
+		stripped = append(stripped, arg)
+	}
+	return stripped
+}
+
+// detachDaemon starts the daemon in the background, with the same arguments
+// except --detach, and returns once it is running.
+func detachDaemon() error {
+	locked, err := fslock.Locked(locker.path, lockFileName)
+	checkErr("checking execution lock", err)
+	if locked {
+		checkErr("", fmt.Errorf("%s is already running with the configuration in %s", programName, locker.path))
+	}
+
+	exe, err := os.Executable()
+	checkErr("finding executable", err)
+
+	args := stripDetachFlag(os.Args[1:])
+
+	logPath := filepath.Join(locker.path, detachedLogFile)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	checkErr("opening log file", err)
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	checkErr("starting daemon", startDetached(cmd))
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-exited:
+		if err == nil {
+			err = errors.New("daemon exited")
+		}
+		checkErr("starting daemon (see %s)", err, logPath)
+	case <-time.After(detachStartWait):
+	}
+
+	out("%s running in the background with PID %d. Output is written to %s\n", programName, cmd.Process.Pid, logPath)
+	return nil
 }
 
 // createCluster creates all the necessary things to produce the cluster
@@ -122,8 +235,11 @@ func createCluster(
 	ctx, err := tag.New(ctx, tag.Upsert(observations.HostKey, host.ID().Pretty()))
 	checkErr("tag context with host id", err)
 
+	// Followers do not expose any APIs.
+	follow := c.Bool("follow")
+
 	var apis []ipfscluster.API
-	if cfgMgr.IsLoadedFromJSON(config.API, cfgs.Restapi.ConfigKey()) {
+	if !follow && cfgMgr.IsLoadedFromJSON(config.API, cfgs.Restapi.ConfigKey()) {
 		var api *rest.API
 		// Do NOT enable default Libp2p API endpoint on CRDT
 		// clusters. Collaborative clusters are likely to share the
@@ -139,29 +255,47 @@ func createCluster(
 
 	}
 
-	if cfgMgr.IsLoadedFromJSON(config.API, cfgs.Ipfsproxy.ConfigKey()) {
+	if !follow && cfgMgr.IsLoadedFromJSON(config.API, cfgs.Ipfsproxy.ConfigKey()) {
 		proxy, err := ipfsproxy.New(cfgs.Ipfsproxy)
 		checkErr("creating IPFS Proxy component", err)
 
 		apis = append(apis, proxy)
 	}
 
-	connector, err := ipfshttp.NewConnector(cfgs.Ipfshttp)
+	if !follow && cfgs.Federation.EnableFederation {
+		fed, err := federation.New(cfgs.Federation)
+		checkErr("creating Federation component", err)
+
+		apis = append(apis, fed)
+	}
+
+	var connector ipfscluster.IPFSConnector
+	if cfgs.Nullconn.Enable {
+		connector, err = nullconn.NewConnector(cfgs.Nullconn)
+	} else {
+		connector, err = ipfshttp.NewConnector(cfgs.Ipfshttp)
+	}
 	checkErr("creating IPFS Connector component", err)
 
 	informer, err := disk.NewInformer(cfgs.Diskinf)
 	checkErr("creating disk informer", err)
-	alloc := descendalloc.NewAllocator()
 
-	ipfscluster.ReadyTimeout = cfgs.Raft.WaitForLeaderTimeout + 5*time.Second
+	ipfscluster.ReadyTimeout = readyTimeout(cfgs.Raft.WaitForLeaderTimeout, raftStaging, c.Duration("bootstrap-timeout"))
 
-	err = observations.SetupMetrics(cfgs.Metrics)
+	err = observations.SetupMetrics(cfgs.Metrics, cfgs.Cluster.Tags)
 	checkErr("setting up Metrics", err)
 
 	tracer, err := observations.SetupTracing(cfgs.Tracing)
 	checkErr("setting up Tracing", err)
 
 	store := setupDatastore(cfgHelper)
+	localStore := setupLocalDatastore(cfgHelper, store)
+	closeStores := func() {
+		if localStore != store {
+			localStore.Close()
+		}
+		store.Close()
+	}
 
 	cons, err := setupConsensus(
 		cfgHelper,
@@ -172,7 +306,7 @@ func createCluster(
 		raftStaging,
 	)
 	if err != nil {
-		store.Close()
+		closeStores()
 		checkErr("setting up Consensus", err)
 	}
 
@@ -186,7 +320,7 @@ func createCluster(
 
 	mon, err := pubsubmon.New(ctx, cfgs.Pubsubmon, pubsub, peersF)
 	if err != nil {
-		store.Close()
+		closeStores()
 		checkErr("setting up PeerMonitor", err)
 	}
 
@@ -195,38 +329,197 @@ func createCluster(
 		host,
 		dht,
 		cfgs.Cluster,
-		store,
+		localStore,
 		cons,
 		apis,
 		connector,
 		tracker,
 		mon,
-		alloc,
+		nil, // the allocator is chosen by cfgs.Cluster.Allocator
 		[]ipfscluster.Informer{informer},
 		tracer,
 	)
 }
 
+// setupFollowerMode adjusts the configuration to run this peer as a
+// follower, which replicates the pinset but does not modify it.
+func setupFollowerMode(cfgs *cmdutils.Configs) {
+	cfgs.Cluster.FollowerMode = true
+	// Do not let trusted peers GC this peer. The policy is copied so
+	// that the change never leaks into a shared map (i.e.
+	// DefaultRPCPolicy).
+	policy := make(map[string]ipfscluster.RPCEndpointType, len(cfgs.Cluster.RPCPolicy))
+	for endpoint, t := range cfgs.Cluster.RPCPolicy {
+		policy[endpoint] = t
+	}
+	policy["Cluster.RepoGCLocal"] = ipfscluster.RPCClosed
+	cfgs.Cluster.RPCPolicy = policy
+}
+
+// upgradeRestartDelay is the time given to an upgraded peer to answer the
+// upgrade request before shutting down for the restart.
+var upgradeRestartDelay = 2 * time.Second
+
+// upgradeFunc returns the function used by the cluster peer to upgrade
+// itself: it downloads the given release, replaces the running binary and
+// restarts the peer.
+func upgradeFunc(cluster *ipfscluster.Cluster) ipfscluster.UpgradeFunc {
+	return func(ctx context.Context, opts *api.UpgradeOptions) error {
+		target, err := semver.ParseTolerant(opts.Version)
+		if err != nil {
+			return err
+		}
+
+		distURL := opts.DistURL
+		if distURL == "" {
+			distURL = cmdutils.DefaultDistURL
+		}
+
+		bin, err := cmdutils.FetchRelease(ctx, distURL, programName, target)
+		if err != nil {
+			return err
+		}
+
+		err = cmdutils.ReplaceExecutable(bin)
+		if err != nil {
+			return err
+		}
+
+		logger.Infof("upgraded to version %s. Restarting", target)
+		cmdutils.RequestRestart()
+		go func() {
+			time.Sleep(upgradeRestartDelay)
+			err := cluster.ShutdownForRestart(context.Background())
+			if err != nil {
+				logger.Error(err)
+			}
+		}()
+		return nil
+	}
+}
+
+// readyTimeout returns how long the peer waits to become ready. Raft peers
+// in staging mode only become ready once they have joined a cluster, so
+// they are also given the time to retry bootstrapping.
+func readyTimeout(waitForLeader time.Duration, raftStaging bool, bootstrapTimeout time.Duration) time.Duration {
+	timeout := waitForLeader + 5*time.Second
+	if raftStaging {
+		timeout += bootstrapTimeout
+	}
+	return timeout
+}
+
+// Backoff limits when retrying to bootstrap.
+var (
+	bootstrapMinBackoff = time.Second
+	bootstrapMaxBackoff = time.Minute
+)
+
+// joiner is the part of the cluster peer used by bootstrap.
+type joiner interface {
+	Join(ctx context.Context, addr ma.Multiaddr) error
+	Peers(ctx context.Context) []*api.ID
+	Done() <-chan struct{}
+}
+
 // bootstrap will bootstrap this peer to one of the bootstrap addresses
-// if there are any.
-func bootstrap(ctx context.Context, cluster *ipfscluster.Cluster, bootstraps []ma.Multiaddr) {
+// if there are any. When none of them can be joined, it retries with
+// exponential backoff until the given window expires.
+func bootstrap(ctx context.Context, cluster joiner, bootstraps []ma.Multiaddr, window time.Duration) {
+	if len(bootstraps) == 0 {
+		return
+	}
+
+	deadline := time.Now().Add(window)
+	backoff := bootstrapMinBackoff
+	for attempt := 1; ; attempt++ {
+		for _, bstrap := range bootstraps {
+			logger.Infof("Bootstrapping to %s (attempt %d)", bstrap, attempt)
+			err := cluster.Join(ctx, bstrap)
+			if err != nil {
+				logger.Warningf("bootstrap to %s failed: %s", bstrap, err)
+				continue
+			}
+			logger.Infof(
+				"bootstrap to %s succeeded. This peer is part of a cluster with %d peers",
+				bstrap,
+				len(cluster.Peers(ctx)),
+			)
+			return
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			logger.Errorf(
+				"could not bootstrap to any of %s after %d attempts. This peer is running WITHOUT joining them (known cluster peers: %d)",
+				bootstraps,
+				attempt,
+				len(cluster.Peers(ctx)),
+			)
+			return
+		}
+
+		logger.Infof("retrying bootstrap in %s", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-cluster.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > bootstrapMaxBackoff {
+			backoff = bootstrapMaxBackoff
+		}
+	}
+}
+
+// fetchRaftSnapshot downloads the latest state snapshot from the first
+// bootstrap peer which provides one, so that joining the cluster does not
+// require replaying its whole Raft log. When no snapshot can be fetched,
+// the peer receives the state through Raft as usual. Bootstrap peers only
+// send snapshots to the peers in their raft snapshot_trusted_peers.
+func fetchRaftSnapshot(ctx context.Context, h host.Host, cfg *raft.Config, bootstraps []ma.Multiaddr, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	for _, bstrap := range bootstraps {
-		logger.Infof("Bootstrapping to %s", bstrap)
-		err := cluster.Join(ctx, bstrap)
+		pinfo, err := peer.AddrInfoFromP2pAddr(bstrap)
 		if err != nil {
-			logger.Errorf("bootstrap to %s failed: %s", bstrap, err)
+			logger.Warningf("cannot fetch snapshot from %s: %s", bstrap, err)
+			continue
+		}
+		err = h.Connect(ctx, *pinfo)
+		if err == nil {
+			err = raft.FetchSnapshot(ctx, h, cfg, pinfo.ID)
 		}
+		if err != nil {
+			logger.Warningf("could not fetch snapshot from %s: %s", bstrap, err)
+			continue
+		}
+		return
 	}
 }
 
 func setupDatastore(cfgHelper *cmdutils.ConfigHelper) ds.Datastore {
-	stmgr, err := cmdutils.NewStateManager(cfgHelper.GetConsensus(), cfgHelper.Identity(), cfgHelper.Configs())
+	stmgr, err := cmdutils.NewStateManagerWithHelper(cfgHelper)
 	checkErr("creating state manager", err)
 	store, err := stmgr.GetStore()
 	checkErr("creating datastore", err)
 	return store
 }
 
+// setupLocalDatastore returns the datastore where the peer persists data
+// locally. CRDT peers keep the shared state in the same datastore.
+func setupLocalDatastore(cfgHelper *cmdutils.ConfigHelper, stateStore ds.Datastore) ds.Datastore {
+	if cfgHelper.GetConsensus() == cfgHelper.Configs().Crdt.ConfigKey() {
+		return stateStore
+	}
+	store, err := cmdutils.NewLocalDatastore(cfgHelper)
+	checkErr("creating local datastore", err)
+	return store
+}
+
 func setupConsensus(
 	cfgHelper *cmdutils.ConfigHelper,
 	h host.Host,
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestStripDetachFlag(t *testing.T) {
+	testcases := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"daemon", "--detach"}, []string{"daemon"}},
+		{[]string{"daemon", "-detach", "--leave"}, []string{"daemon", "--leave"}},
+		{[]string{"daemon", "--detach=true"}, []string{"daemon"}},
+		{[]string{"daemon", "-detach=false", "--stats"}, []string{"daemon", "--stats"}},
+		{[]string{"daemon", "--detached-log"}, []string{"daemon", "--detached-log"}},
+		{[]string{"detach", "daemon"}, []string{"detach", "daemon"}},
+		{[]string{"daemon", "--", "--detach"}, []string{"daemon", "--", "--detach"}},
+	}
+
+	for _, tc := range testcases {
+		got := stripDetachFlag(tc.args)
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%v: expected %v, got %v", tc.args, tc.expected, got)
+		}
+	}
+}
+
+// failingJoiner fails to join the first failures times.
+type failingJoiner struct {
+	mu       sync.Mutex
+	failures int
+	joins    []time.Time
+	done     chan struct{}
+}
+
+func (j *failingJoiner) Join(ctx context.Context, addr ma.Multiaddr) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.joins = append(j.joins, time.Now())
+	if len(j.joins) <= j.failures {
+		return errors.New("join failed")
+	}
+	return nil
+}
+
+func (j *failingJoiner) Peers(ctx context.Context) []*api.ID {
+	return nil
+}
+
+func (j *failingJoiner) Done() <-chan struct{} {
+	return j.done
+}
+
+// setBootstrapBackoff sets the bootstrap backoff limits and returns a
+// function to restore them.
+func setBootstrapBackoff(min, max time.Duration) func() {
+	oldMin, oldMax := bootstrapMinBackoff, bootstrapMaxBackoff
+	bootstrapMinBackoff, bootstrapMaxBackoff = min, max
+	return func() {
+		bootstrapMinBackoff, bootstrapMaxBackoff = oldMin, oldMax
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	ctx := context.Background()
+	addrs := []ma.Multiaddr{ma.StringCast("/ip4/127.0.0.1/tcp/9096")}
+
+	t.Run("retries with backoff", func(t *testing.T) {
+		defer setBootstrapBackoff(20*time.Millisecond, 50*time.Millisecond)()
+		j := &failingJoiner{failures: 3, done: make(chan struct{})}
+		bootstrap(ctx, j, addrs, time.Minute)
+
+		if len(j.joins) != 4 {
+			t.Fatalf("expected 4 join attempts, got %d", len(j.joins))
+		}
+		// 20ms, 40ms and 50ms (capped) between attempts.
+		for i, min := range []time.Duration{20, 40, 50} {
+			if d := j.joins[i+1].Sub(j.joins[i]); d < min*time.Millisecond {
+				t.Errorf("attempt %d: expected a backoff of at least %dms, got %s", i+2, min, d)
+			}
+		}
+	})
+
+	t.Run("gives up when the window expires", func(t *testing.T) {
+		defer setBootstrapBackoff(20*time.Millisecond, time.Second)()
+		j := &failingJoiner{failures: 100, done: make(chan struct{})}
+		bootstrap(ctx, j, addrs, 100*time.Millisecond)
+
+		// 0, 20, 60ms. The next attempt would be at 140ms.
+		if len(j.joins) != 3 {
+			t.Fatalf("expected 3 join attempts, got %d", len(j.joins))
+		}
+	})
+
+	t.Run("stops when the peer shuts down", func(t *testing.T) {
+		defer setBootstrapBackoff(time.Minute, time.Minute)()
+		j := &failingJoiner{failures: 100, done: make(chan struct{})}
+		close(j.done)
+		bootstrap(ctx, j, addrs, time.Hour)
+
+		if len(j.joins) != 1 {
+			t.Fatalf("expected 1 join attempt, got %d", len(j.joins))
+		}
+	})
+
+	t.Run("no bootstrap addresses", func(t *testing.T) {
+		j := &failingJoiner{done: make(chan struct{})}
+		bootstrap(ctx, j, nil, time.Minute)
+		if len(j.joins) != 0 {
+			t.Fatal("should not have tried to join")
+		}
+	})
+}
+
+func TestReadyTimeout(t *testing.T) {
+	if d := readyTimeout(15*time.Second, false, time.Minute); d != 20*time.Second {
+		t.Errorf("expected 20s, got %s", d)
+	}
+	if d := readyTimeout(15*time.Second, true, time.Minute); d != 80*time.Second {
+		t.Errorf("staging: expected 80s, got %s", d)
+	}
+}
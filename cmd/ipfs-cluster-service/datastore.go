@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/consensus/raft"
+	"github.com/ipfs/ipfs-cluster/datastore/badger"
+	"github.com/ipfs/ipfs-cluster/datastore/leveldb"
+
+	humanize "github.com/dustin/go-humanize"
+	cli "github.com/urfave/cli"
+)
+
+func sizeStr(size int64) string {
+	return humanize.IBytes(uint64(size))
+}
+
+// datastoreFolder returns the folder of the configured datastore.
+func datastoreFolder(cfgHelper *cmdutils.ConfigHelper) string {
+	cfgs := cfgHelper.Configs()
+	if cfgHelper.GetDatastore() == cfgs.LevelDB.ConfigKey() {
+		return cfgs.LevelDB.GetFolder()
+	}
+	return cfgs.Badger.GetFolder()
+}
+
+// datastoreStats prints the disk space used by the local datastore and,
+// with "raft" consensus, by the Raft data folder.
+func datastoreStats(c *cli.Context) error {
+	locker.lock()
+	defer locker.tryUnlock()
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+
+	var size int64
+	switch cfgHelper.GetDatastore() {
+	case cfgs.Badger.ConfigKey():
+		size, err = badger.DiskUsage(cfgs.Badger)
+	case cfgs.LevelDB.ConfigKey():
+		size, err = leveldb.DiskUsage(cfgs.LevelDB)
+	default:
+		err = fmt.Errorf("unknown datastore '%s'", cfgHelper.GetDatastore())
+	}
+	checkErr("reading datastore usage", err)
+	out("datastore (%s): %s\n", datastoreFolder(cfgHelper), sizeStr(size))
+
+	if cfgHelper.GetConsensus() != cfgs.Raft.ConfigKey() {
+		return nil
+	}
+
+	usage, err := raft.Usage(cfgs.Raft)
+	checkErr("reading raft data usage", err)
+	out("raft log (%s): %s\n", usage.DataFolder, sizeStr(usage.LogStore))
+	out("raft snapshots: %d (%s)\n", usage.Snapshots, sizeStr(usage.SnapshotsSize))
+	out("raft data backups: %d (%s)\n", usage.Backups, sizeStr(usage.BackupsSize))
+	return nil
+}
+
+// datastoreCompact reclaims the space freed in the local datastore and,
+// with "raft" consensus, in the Raft log store.
+func datastoreCompact(c *cli.Context) error {
+	locker.lock()
+	defer locker.tryUnlock()
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+
+	var before, after int64
+	switch cfgHelper.GetDatastore() {
+	case cfgs.Badger.ConfigKey():
+		before, after, err = badger.Compact(cfgs.Badger)
+	case cfgs.LevelDB.ConfigKey():
+		before, after, err = leveldb.Compact(cfgs.LevelDB)
+	default:
+		err = fmt.Errorf("unknown datastore '%s'", cfgHelper.GetDatastore())
+	}
+	checkErr("compacting datastore", err)
+	out("datastore compacted: %s -> %s\n", sizeStr(before), sizeStr(after))
+
+	if cfgHelper.GetConsensus() != cfgs.Raft.ConfigKey() {
+		return nil
+	}
+
+	before, after, err = raft.CompactLog(cfgs.Raft)
+	checkErr("compacting raft log", err)
+	out("raft log compacted: %s -> %s\n", sizeStr(before), sizeStr(after))
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// processDead returns true when it is certain that no process with the
+// given PID is running.
+func processDead(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 checks whether the process exists. Any error other than
+	// "no such process" (i.e. EPERM) leaves it undetermined.
+	err = p.Signal(syscall.Signal(0))
+	return err == syscall.ESRCH || err == os.ErrProcessDone
+}
+
+// startDetached starts the given command in a new session, so that it is
+// not attached to the current terminal.
+func startDetached(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return cmd.Start()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// errInvalidParameter (ERROR_INVALID_PARAMETER) is returned when opening a
+// process which does not exist.
+const errInvalidParameter = syscall.Errno(87)
+
+// processDead returns true when it is certain that no process with the
+// given PID is running.
+func processDead(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		// Other errors (i.e. access denied) leave it undetermined.
+		serr, ok := err.(*os.SyscallError)
+		return ok && serr.Err == errInvalidParameter
+	}
+	p.Release()
+	return false
+}
+
+// startDetached starts the given command in the background.
+func startDetached(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/ipfsconn/ipfshttp"
+	"github.com/ipfs/ipfs-cluster/pstoremgr"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	libp2p "github.com/libp2p/go-libp2p"
+	host "github.com/libp2p/go-libp2p-core/host"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	pnet "github.com/libp2p/go-libp2p-pnet"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+	cli "github.com/urfave/cli"
+)
+
+// diagnosticsTimeout limits how long each network check waits for an
+// answer.
+var diagnosticsTimeout = 10 * time.Second
+
+// maxClockSkew is the maximum clock difference with other peers which is
+// considered acceptable. Metrics expire based on the sender's clock, so
+// large differences make peers look down.
+var maxClockSkew = 5 * time.Second
+
+// diagnosticResult is the outcome of a single diagnostics check.
+type diagnosticResult struct {
+	check string
+	err   error
+	msg   string
+}
+
+type diagnosticsReport []*diagnosticResult
+
+func (r *diagnosticsReport) add(check string, err error, msg string, args ...interface{}) {
+	*r = append(*r, &diagnosticResult{
+		check: check,
+		err:   err,
+		msg:   fmt.Sprintf(msg, args...),
+	})
+}
+
+func (r diagnosticsReport) failed() bool {
+	for _, res := range r {
+		if res.err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r diagnosticsReport) print() {
+	for _, res := range r {
+		if res.err != nil {
+			fmt.Printf("[FAIL] %s: %s\n", res.check, res.err)
+			continue
+		}
+		fmt.Printf("[ OK ] %s: %s\n", res.check, res.msg)
+	}
+}
+
+// diagnostics checks that the environment is ready to run the peer and
+// prints a report. It exits with an error if any of the checks fails.
+func diagnostics(c *cli.Context) error {
+	locker.lock()
+	defer locker.tryUnlock()
+
+	var report diagnosticsReport
+	defer func() {
+		report.print()
+		if report.failed() {
+			checkErr("", errors.New("some checks failed"))
+		}
+	}()
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	if err != nil {
+		report.add("configuration", err, "")
+		return nil
+	}
+	defer cfgHelper.Manager().Shutdown()
+	report.add("configuration", nil, "loaded from %s", configPath)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	diagnoseIPFS(ctx, cfgHelper, &report)
+	diagnoseListenAddrs(cfgHelper, &report)
+	diagnoseFolders(cfgHelper, &report)
+	diagnosePeers(ctx, cfgHelper, &report)
+	return nil
+}
+
+// diagnoseIPFS checks that the IPFS daemon API can be reached.
+func diagnoseIPFS(ctx context.Context, cfgHelper *cmdutils.ConfigHelper, report *diagnosticsReport) {
+	const check = "ipfs api"
+	cfgs := cfgHelper.Configs()
+
+	if cfgs.Nullconn.Enable {
+		report.add(check, nil, "null connector enabled: IPFS is simulated and nothing is pinned")
+		return
+	}
+
+	connector, err := ipfshttp.NewConnector(cfgs.Ipfshttp)
+	if err != nil {
+		report.add(check, err, "")
+		return
+	}
+	defer connector.Shutdown(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsTimeout)
+	defer cancel()
+	id, err := connector.ID(ctx)
+	if err != nil {
+		report.add(check, fmt.Errorf("%s unreachable: %s", cfgs.Ipfshttp.NodeAddr, err), "")
+		return
+	}
+	report.add(check, nil, "%s reachable (IPFS peer %s)", cfgs.Ipfshttp.NodeAddr, id.ID.Pretty())
+}
+
+// diagnoseListenAddrs checks that the addresses that the peer and its APIs
+// listen on are available.
+func diagnoseListenAddrs(cfgHelper *cmdutils.ConfigHelper, report *diagnosticsReport) {
+	cfgs := cfgHelper.Configs()
+	cfgMgr := cfgHelper.Manager()
+
+	addrs := map[string][]ma.Multiaddr{
+		"cluster listen address": cfgs.Cluster.ListenAddr,
+	}
+	if cfgMgr.IsLoadedFromJSON(config.API, cfgs.Restapi.ConfigKey()) {
+		addrs["restapi listen address"] = cfgs.Restapi.HTTPListenAddr
+	}
+	if cfgMgr.IsLoadedFromJSON(config.API, cfgs.Ipfsproxy.ConfigKey()) {
+		addrs["ipfsproxy listen address"] = cfgs.Ipfsproxy.ListenAddr
+	}
+
+	for _, check := range []string{"cluster listen address", "restapi listen address", "ipfsproxy listen address"} {
+		for _, addr := range addrs[check] {
+			if err := tryListen(addr); err != nil {
+				report.add(check, fmt.Errorf("%s: %s", addr, err), "")
+				continue
+			}
+			report.add(check, nil, "%s available", addr)
+		}
+	}
+}
+
+// tryListen opens and closes a listener on the given address. QUIC
+// addresses are checked by opening a UDP socket.
+func tryListen(addr ma.Multiaddr) error {
+	if _, err := addr.ValueForProtocol(ma.P_QUIC); err == nil {
+		udpAddr := addr.Decapsulate(ma.StringCast("/quic"))
+		pc, err := manet.ListenPacket(udpAddr)
+		if err != nil {
+			return err
+		}
+		return pc.Close()
+	}
+
+	l, err := manet.Listen(addr)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+// diagnoseFolders checks that the configuration and datastore folders are
+// writable.
+func diagnoseFolders(cfgHelper *cmdutils.ConfigHelper, report *diagnosticsReport) {
+	const check = "folder permissions"
+	cfgs := cfgHelper.Configs()
+
+	folders := []string{cfgs.Cluster.BaseDir, datastoreFolder(cfgHelper)}
+	if cfgHelper.GetConsensus() == cfgs.Raft.ConfigKey() {
+		folders = append(folders, cfgs.Raft.GetDataFolder())
+	}
+
+	for _, folder := range folders {
+		if err := tryWrite(folder); err != nil {
+			report.add(check, err, "")
+			continue
+		}
+		report.add(check, nil, "%s is writable", folder)
+	}
+}
+
+// tryWrite writes a temporary file in the given folder. Folders which do
+// not exist yet are checked on their closest existing parent, as they will
+// be created when the peer starts.
+func tryWrite(folder string) error {
+	f := folder
+	for {
+		_, err := os.Stat(f)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		parent := filepath.Dir(f)
+		if parent == f {
+			return fmt.Errorf("%s: no existing parent folder", folder)
+		}
+		f = parent
+	}
+
+	tmp, err := ioutil.TempFile(f, ".diagnostics")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// diagnosePeers connects to the known peers using the cluster secret and
+// compares their clocks with the local one. A connection can only be
+// established when both peers use the same secret.
+func diagnosePeers(ctx context.Context, cfgHelper *cmdutils.ConfigHelper, report *diagnosticsReport) {
+	const (
+		secretCheck = "secret"
+		clockCheck  = "clock skew"
+	)
+	cfgs := cfgHelper.Configs()
+
+	h, err := newOfflineHost(ctx, cfgHelper)
+	if err != nil {
+		report.add(secretCheck, err, "")
+		return
+	}
+	defer h.Close()
+
+	peers := knownPeers(ctx, h, cfgs)
+	if len(peers) == 0 {
+		report.add(secretCheck, nil, "no known peers to compare with")
+		report.add(clockCheck, nil, "no known peers to compare with")
+		return
+	}
+
+	rpcClient := rpc.NewClient(h, version.RPCProtocol)
+	results := make([]*diagnosticResult, 2*len(peers))
+
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func(i int, p peer.ID) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, diagnosticsTimeout)
+			defer cancel()
+
+			secretRes := &diagnosticResult{check: secretCheck}
+			clockRes := &diagnosticResult{check: clockCheck}
+			results[2*i] = secretRes
+			results[2*i+1] = clockRes
+
+			err := h.Connect(ctx, h.Peerstore().PeerInfo(p))
+			if err != nil {
+				secretRes.err = fmt.Errorf("cannot connect to %s (unreachable or using a different secret): %s", p.Pretty(), err)
+				clockRes.msg = fmt.Sprintf("%s not checked", p.Pretty())
+				return
+			}
+			secretRes.msg = fmt.Sprintf("connected to %s", p.Pretty())
+
+			var remote time.Time
+			start := time.Now()
+			err = rpcClient.CallContext(ctx, p, "Cluster", "Time", struct{}{}, &remote)
+			rtt := time.Since(start)
+			if err != nil {
+				clockRes.msg = fmt.Sprintf("%s not checked: %s", p.Pretty(), err)
+				return
+			}
+
+			skew := remote.Sub(start.Add(rtt / 2))
+			if skew > maxClockSkew || skew < -maxClockSkew {
+				clockRes.err = fmt.Errorf("clock of %s differs by %s", p.Pretty(), skew)
+				return
+			}
+			clockRes.msg = fmt.Sprintf("clock of %s differs by %s", p.Pretty(), skew)
+		}(i, p)
+	}
+	wg.Wait()
+
+	*report = append(*report, results...)
+}
+
+// newOfflineHost creates a libp2p host which does not listen and uses the
+// peer identity and the cluster secret. It allows to talk to other peers
+// while this one is not running.
+func newOfflineHost(ctx context.Context, cfgHelper *cmdutils.ConfigHelper) (host.Host, error) {
+	opts := []libp2p.Option{
+		libp2p.Identity(cfgHelper.Identity().PrivateKey),
+		libp2p.NoListenAddrs,
+	}
+
+	if secret := cfgHelper.Configs().Cluster.Secret; len(secret) > 0 {
+		var key [32]byte
+		copy(key[:], secret)
+		prot, err := pnet.NewV1ProtectorFromBytes(&key)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, libp2p.PrivateNetwork(prot))
+	}
+
+	return libp2p.New(ctx, opts...)
+}
+
+// knownPeers loads the peers in the peerstore file and in the
+// peer_addresses configuration into the given host and returns those which
+// have addresses.
+func knownPeers(ctx context.Context, h host.Host, cfgs *cmdutils.Configs) []peer.ID {
+	pm := pstoremgr.New(ctx, h, cfgs.Cluster.GetPeerstorePath())
+	addrs := append(pm.LoadPeerstore(), cfgs.Cluster.PeerAddresses...)
+	pm.ImportPeers(addrs, false, time.Minute)
+
+	var peers []peer.ID
+	for _, p := range h.Peerstore().Peers() {
+		if p != h.ID() && len(h.Peerstore().Addrs(p)) > 0 {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
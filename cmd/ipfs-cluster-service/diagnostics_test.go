@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+func TestDiagnosticsReport(t *testing.T) {
+	var report diagnosticsReport
+	report.add("a", nil, "all good with %s", "a")
+	if report.failed() {
+		t.Fatal("report should not have failed")
+	}
+	if report[0].msg != "all good with a" {
+		t.Error("message not formatted:", report[0].msg)
+	}
+
+	report.add("b", errors.New("bad"), "")
+	if !report.failed() {
+		t.Fatal("report should have failed")
+	}
+}
+
+func TestTryWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := tryWrite(dir); err != nil {
+		t.Error("folder should be writable:", err)
+	}
+
+	// Missing folders are checked on their closest parent.
+	if err := tryWrite(filepath.Join(dir, "a", "b")); err != nil {
+		t.Error("missing folder should be writable:", err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Error("temporary files should have been removed")
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permissions are not enforced for root")
+	}
+	ro := filepath.Join(dir, "ro")
+	if err := os.Mkdir(ro, 0500); err != nil {
+		t.Fatal(err)
+	}
+	if err := tryWrite(filepath.Join(ro, "data")); err == nil {
+		t.Error("expected an error writing in a read-only folder")
+	}
+}
+
+func TestTryListen(t *testing.T) {
+	addr := ma.StringCast("/ip4/127.0.0.1/tcp/0")
+	if err := tryListen(addr); err != nil {
+		t.Fatal("should be able to listen:", err)
+	}
+
+	if err := tryListen(ma.StringCast("/ip4/127.0.0.1/udp/0/quic")); err != nil {
+		t.Fatal("should be able to listen on quic:", err)
+	}
+
+	l, err := manet.Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := tryListen(l.Multiaddr()); err == nil {
+		t.Error("expected an error listening on a used address")
+	}
+}
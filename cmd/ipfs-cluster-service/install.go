@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"text/template"
+
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+
+	cli "github.com/urfave/cli"
+)
+
+// launchdLabelPrefix is prepended to the service name to build the launchd
+// label.
+const launchdLabelPrefix = "io.ipfs."
+
+// serviceInfo holds the values used to generate the service definitions.
+type serviceInfo struct {
+	Name        string
+	Description string
+	Exe         string
+	Folder      string
+	User        string
+	UserMode    bool
+	LogFile     string
+}
+
+var systemdTemplate = template.Must(template.New("systemd").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+}).Parse(`[Unit]
+Description={{ .Description }}
+Documentation=https://cluster.ipfs.io
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=notify
+ExecStart={{ quote .Exe }} --config {{ quote .Folder }} daemon
+Restart=on-failure
+RestartSec=10
+LimitNOFILE=8192
+{{- if .User }}
+User={{ .User }}
+{{- end }}
+
+[Install]
+{{- if .UserMode }}
+WantedBy=default.target
+{{- else }}
+WantedBy=multi-user.target
+{{- end }}
+`))
+
+var launchdTemplate = template.Must(template.New("launchd").Funcs(template.FuncMap{
+	"xml": xmlEscape,
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + launchdLabelPrefix + `{{ xml .Name }}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{ xml .Exe }}</string>
+		<string>--config</string>
+		<string>{{ xml .Folder }}</string>
+		<string>daemon</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>SoftResourceLimits</key>
+	<dict>
+		<key>NumberOfFiles</key>
+		<integer>8192</integer>
+	</dict>
+{{- if .User }}
+	<key>UserName</key>
+	<string>{{ xml .User }}</string>
+{{- end }}
+	<key>StandardOutPath</key>
+	<string>{{ xml .LogFile }}</string>
+	<key>StandardErrorPath</key>
+	<string>{{ xml .LogFile }}</string>
+</dict>
+</plist>
+`))
+
+func xmlEscape(s string) (string, error) {
+	var buf bytes.Buffer
+	err := xml.EscapeText(&buf, []byte(s))
+	return buf.String(), err
+}
+
+// install registers the daemon as a system service for the current
+// platform: a systemd unit on Linux, a launchd agent or daemon on macOS and
+// a Windows service on Windows.
+func install(c *cli.Context) error {
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+
+	exe, err := os.Executable()
+	checkErr("finding executable", err)
+	exe, err = filepath.EvalSymlinks(exe)
+	checkErr("finding executable", err)
+
+	info := &serviceInfo{
+		Name:        c.String("name"),
+		Description: fmt.Sprintf("IPFS Cluster peer %s", cfgs.Cluster.Peername),
+		Exe:         exe,
+		Folder:      filepath.Dir(configPath),
+		UserMode:    c.Bool("user"),
+		LogFile:     filepath.Join(filepath.Dir(configPath), detachedLogFile),
+	}
+
+	// System services run as the user installing them, unless that is
+	// root.
+	if !info.UserMode {
+		u, err := user.Current()
+		checkErr("obtaining current user", err)
+		if u.Uid != "0" {
+			info.User = u.Username
+		}
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		return installSystemd(c, info)
+	case "darwin":
+		return installLaunchd(c, info)
+	case "windows":
+		if c.Bool("print") {
+			checkErr("", errors.New("--print is not supported on windows"))
+		}
+		checkErr("installing Windows service", installWindowsService(info))
+		out("%s installed as Windows service %q\n", programName, info.Name)
+		return nil
+	default:
+		checkErr("", fmt.Errorf("service installation is not supported on %s", runtime.GOOS))
+	}
+	return nil
+}
+
+func installSystemd(c *cli.Context, info *serviceInfo) error {
+	var unit bytes.Buffer
+	checkErr("generating systemd unit", systemdTemplate.Execute(&unit, info))
+	if c.Bool("print") {
+		fmt.Print(unit.String())
+		return nil
+	}
+
+	dir := "/etc/systemd/system"
+	systemctl := []string{}
+	if info.UserMode {
+		cfgDir, err := userConfigDir()
+		checkErr("finding user configuration folder", err)
+		dir = filepath.Join(cfgDir, "systemd", "user")
+		systemctl = append(systemctl, "--user")
+	}
+	path := filepath.Join(dir, info.Name+".service")
+	checkErr("writing systemd unit", writeServiceFile(path, unit.Bytes(), c.Bool("force")))
+	out("systemd unit written to %s\n", path)
+
+	checkErr("reloading systemd", runCmd("systemctl", append(systemctl, "daemon-reload")...))
+	checkErr("enabling unit", runCmd("systemctl", append(systemctl, "enable", info.Name+".service")...))
+	out("unit enabled. Start it with \"systemctl %sstart %s\"\n", userFlag(info.UserMode), info.Name)
+	return nil
+}
+
+func installLaunchd(c *cli.Context, info *serviceInfo) error {
+	var plist bytes.Buffer
+	checkErr("generating launchd plist", launchdTemplate.Execute(&plist, info))
+	if c.Bool("print") {
+		fmt.Print(plist.String())
+		return nil
+	}
+
+	dir := "/Library/LaunchDaemons"
+	if info.UserMode {
+		home, err := os.UserHomeDir()
+		checkErr("finding home folder", err)
+		dir = filepath.Join(home, "Library", "LaunchAgents")
+	}
+	path := filepath.Join(dir, launchdLabelPrefix+info.Name+".plist")
+	checkErr("writing launchd plist", writeServiceFile(path, plist.Bytes(), c.Bool("force")))
+	out("launchd plist written to %s\n", path)
+
+	checkErr("loading plist", runCmd("launchctl", "load", "-w", path))
+	out("service loaded and started\n")
+	return nil
+}
+
+// writeServiceFile writes a service definition, refusing to overwrite an
+// existing one unless forced.
+func writeServiceFile(path string, data []byte, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists. Use --force to overwrite it", path)
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func userConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config"), nil
+}
+
+func userFlag(userMode bool) string {
+	if userMode {
+		return "--user "
+	}
+	return ""
+}
+
+func runCmd(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %s", err, bytes.TrimSpace(output))
+	}
+	return nil
+}
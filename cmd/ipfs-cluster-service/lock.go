@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
 
 	fslock "github.com/ipfs/go-fs-lock"
 	"github.com/ipfs/ipfs-cluster/cmdutils"
@@ -21,6 +25,9 @@ var locker *lock
 type lock struct {
 	lockCloser io.Closer
 	path       string
+	// pidFile, when set, is written with the PID of this process once
+	// the lock is acquired and removed when it is released.
+	pidFile string
 }
 
 func (l *lock) lock() {
@@ -29,12 +36,17 @@ func (l *lock) lock() {
 	}
 
 	// we should have a config folder whenever we try to lock
-	cfgHelper := cmdutils.NewConfigHelper(configPath, identityPath, "")
+	cfgHelper := cmdutils.NewConfigHelper(configPath, identityPath, "", "")
 	cfgHelper.MakeConfigFolder()
 
 	// set the lock file within this function
 	logger.Debug("checking lock")
 	lk, err := fslock.Lock(l.path, lockFileName)
+	if err != nil && staleLock(filepath.Join(l.path, lockFileName)) {
+		logger.Warningf("removing stale lock left by a process which is no longer running: %s", filepath.Join(l.path, lockFileName))
+		os.Remove(filepath.Join(l.path, lockFileName))
+		lk, err = fslock.Lock(l.path, lockFileName)
+	}
 	if err != nil {
 		logger.Debug(err)
 		l.lockCloser = nil
@@ -53,6 +65,11 @@ func (l *lock) lock() {
 	}
 	logger.Debugf("%s execution lock acquired", programName)
 	l.lockCloser = lk
+
+	if l.pidFile != "" {
+		err := ioutil.WriteFile(l.pidFile, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+		checkErr("writing PID file", err)
+	}
 }
 
 func (l *lock) tryUnlock() error {
@@ -61,6 +78,9 @@ func (l *lock) tryUnlock() error {
 		logger.Debug("locking not initialized, unlock is noop")
 		return nil
 	}
+	if l.pidFile != "" {
+		os.Remove(l.pidFile)
+	}
 	err := l.lockCloser.Close()
 	if err != nil {
 		return err
@@ -69,3 +89,24 @@ func (l *lock) tryUnlock() error {
 	l.lockCloser = nil
 	return nil
 }
+
+// staleLock returns true when the lock file at the given path was left
+// behind by a process which is provably no longer running. Active locks
+// held with fcntl use empty lock files, while the portable locking fallback
+// writes the PID of the owner in the file. Lock files without a valid PID
+// are never considered stale: the user must remove them.
+func staleLock(lockPath string) bool {
+	data, err := ioutil.ReadFile(lockPath)
+	if err != nil || len(data) == 0 {
+		return false
+	}
+
+	var meta struct {
+		OwnerPID int
+	}
+	err = json.Unmarshal(data, &meta)
+	if err != nil || meta.OwnerPID <= 0 {
+		return false
+	}
+	return processDead(meta.OwnerPID)
+}
@@ -11,6 +11,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
+	"time"
 
 	ipfscluster "github.com/ipfs/ipfs-cluster"
 	"github.com/ipfs/ipfs-cluster/cmdutils"
@@ -29,8 +30,10 @@ const programName = "ipfs-cluster-service"
 
 // flag defaults
 const (
-	defaultLogLevel  = "info"
-	defaultConsensus = "crdt"
+	defaultLogLevel         = "info"
+	defaultConsensus        = "crdt"
+	defaultDatastore        = "badger"
+	defaultBootstrapTimeout = 5 * time.Minute
 )
 
 const (
@@ -104,6 +107,15 @@ pair or without identifier for overall loglevel. Valid loglevels
 are critical, error, warning, notice, info and debug.
 
 $ ipfs-cluster-service --loglevel info,cluster:debug,pintracker:debug daemon
+
+Run several independent clusters in a single process. Each cluster uses its
+own configuration folder (created with "init"):
+
+$ ipfs-cluster-service -c cluster-a init
+$ ipfs-cluster-service -c cluster-b init --randomports
+$ cat multi.json
+{ "clusters": [ {"name": "a", "folder": "cluster-a"}, {"name": "b", "folder": "cluster-b"} ] }
+$ ipfs-cluster-service daemon --multi multi.json
 `,
 	programName,
 	programName,
@@ -241,6 +253,10 @@ by setting the CLUSTER_SECRET environment variable.
 The --consensus flag allows to select an alternative consensus components for
 in the newly-generated configuration.
 
+The --datastore flag allows to select the datastore backend ('badger' or
+'leveldb') used to persist the shared state (with "crdt" consensus) and the
+data that the peer keeps locally.
+
 Note that the --force flag allows to overwrite an existing
 configuration with default values. To generate a new identity, please
 remove the %s file first and clean any Raft state.
@@ -265,6 +281,11 @@ the peer IDs in the given multiaddresses.
 					Usage: "select consensus component: 'crdt' or 'raft'",
 					Value: defaultConsensus,
 				},
+				cli.StringFlag{
+					Name:  "datastore",
+					Usage: "select datastore component: 'badger' or 'leveldb'",
+					Value: defaultDatastore,
+				},
 				cli.BoolFlag{
 					Name:  "custom-secret, s",
 					Usage: "prompt for the cluster secret (when no source specified)",
@@ -288,7 +309,12 @@ the peer IDs in the given multiaddresses.
 					checkErr("choosing consensus", errors.New("flag value must be set to 'raft' or 'crdt'"))
 				}
 
-				cfgHelper := cmdutils.NewConfigHelper(configPath, identityPath, consensus)
+				datastore := c.String("datastore")
+				if datastore != "badger" && datastore != "leveldb" {
+					checkErr("choosing datastore", errors.New("flag value must be set to 'badger' or 'leveldb'"))
+				}
+
+				cfgHelper := cmdutils.NewConfigHelper(configPath, identityPath, consensus, datastore)
 				defer cfgHelper.Manager().Shutdown() // wait for saves
 
 				configExists := false
@@ -412,6 +438,15 @@ the peer IDs in the given multiaddresses.
 					Name:  "bootstrap, j",
 					Usage: "join a cluster providing a comma-separated list of existing peers multiaddress(es)",
 				},
+				cli.StringFlag{
+					Name:  "join-token",
+					Usage: "join a cluster using a token created with \"token create\" in one of its peers",
+				},
+				cli.DurationFlag{
+					Name:  "bootstrap-timeout",
+					Value: defaultBootstrapTimeout,
+					Usage: "keep retrying to bootstrap during this time when bootstrap peers cannot be joined",
+				},
 				cli.BoolFlag{
 					Name:   "leave, x",
 					Usage:  "remove peer from cluster on exit. Overrides \"leave_on_shutdown\"",
@@ -429,9 +464,76 @@ the peer IDs in the given multiaddresses.
 					Name:  "no-trust",
 					Usage: "do not trust bootstrap peers (only for \"crdt\" consensus)",
 				},
+				cli.BoolFlag{
+					Name:  "follow",
+					Usage: "run as a follower: replicate the pinset with APIs disabled (only for \"crdt\" consensus)",
+				},
+				cli.BoolFlag{
+					Name:  "detach",
+					Usage: "run the daemon in the background and return",
+				},
+				cli.StringFlag{
+					Name:  "pid-file",
+					Usage: "write the PID of the daemon to the given `FILE`",
+				},
+				cli.StringFlag{
+					Name:  "multi",
+					Usage: "run all the clusters listed in the given multi-cluster `FILE`",
+				},
 			},
 			Action: daemon,
 		},
+		{
+			Name:  "diagnostics",
+			Usage: "Checks that the peer is ready to be started",
+			Description: `
+This command runs a series of checks and prints a report, without starting
+the peer. It verifies that the configuration can be loaded, that the IPFS
+daemon API is reachable, that the listen addresses are available and that
+the configuration and datastore folders are writable. It also connects to the
+known peers (from the peerstore and "peer_addresses") to check that they use
+the same cluster secret and that their clocks are in sync.
+
+The command exits with an error when any of the checks fails.
+`,
+			Action: diagnostics,
+		},
+		{
+			Name:  "debug",
+			Usage: "Helpers to troubleshoot the peer",
+			Subcommands: []cli.Command{
+				{
+					Name:  "bundle",
+					Usage: "gathers information about the peer into a tarball for bug reports",
+					Description: `
+This command writes a .tar.gz file with information useful to debug problems
+in this peer, ready to be attached to bug reports. It includes the
+configuration and identity files, with secrets and private keys redacted, and
+the last lines of the log file (only written when the peer runs detached).
+
+When the peer is running, it also includes the peer's ID and the cluster
+peers as seen by the consensus layer, the latest metrics and the pin tracker
+operations queue, obtained through the REST API, along with a goroutine dump
+when "enable_stats" is set.
+
+Items which cannot be collected are listed in the errors.txt file of the
+bundle.
+`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "path of the bundle. Defaults to ipfs-cluster-debug-<date>.tar.gz",
+						},
+						cli.IntFlag{
+							Name:  "log-lines",
+							Value: 1000,
+							Usage: "number of log lines to include. 0 to skip the logs",
+						},
+					},
+					Action: debugBundleCmd,
+				},
+			},
+		},
 		{
 			Name:  "state",
 			Usage: "Manages the peer's consensus state (pinset)",
@@ -443,6 +545,12 @@ the peer IDs in the given multiaddresses.
 This command dumps the current cluster pinset (state) as a JSON file. The
 resulting file can be used to migrate, restore or backup a Cluster peer.
 By default, the state will be printed to stdout.
+
+With --anonymize, only the CIDs, the replication factors and the type of
+every pin are exported: names, metadata, allocations and all other options
+are left out. Anonymized states can be shared publicly or with support
+without revealing who pinned what or how the cluster is laid out, and can
+still be imported.
 `,
 					Flags: []cli.Flag{
 						cli.StringFlag{
@@ -450,6 +558,10 @@ By default, the state will be printed to stdout.
 							Value: "",
 							Usage: "writes to an output file",
 						},
+						cli.BoolFlag{
+							Name:  "anonymize",
+							Usage: "strip names, metadata, allocations and other options from the pins",
+						},
 					},
 					Action: func(c *cli.Context) error {
 						locker.lock()
@@ -470,7 +582,7 @@ By default, the state will be printed to stdout.
 						}
 						defer w.Close()
 
-						checkErr("exporting state", mgr.ExportState(w))
+						checkErr("exporting state", mgr.ExportState(w, c.Bool("anonymize")))
 						logger.Info("state successfully exported")
 						return nil
 					},
@@ -556,6 +668,64 @@ to all effects. Peers may need to bootstrap and sync from scratch after this.
 				},
 			},
 		},
+		{
+			Name:  "datastore",
+			Usage: "Reports and reclaims the disk space used by the local stores",
+			Subcommands: []cli.Command{
+				{
+					Name:  "stats",
+					Usage: "prints the disk space used by the local stores",
+					Description: `
+This command prints the disk space used by the local datastore (badger or
+leveldb) and, when using "raft" consensus, by the Raft log, snapshots and the
+backups of the Raft data folder. The peer must be stopped.
+`,
+					Action: datastoreStats,
+				},
+				{
+					Name:  "compact",
+					Usage: "reclaims the space freed in the local stores",
+					Description: `
+This command compacts the local datastore (badger or leveldb) and, when using
+"raft" consensus, the Raft log store (BoltDB), which do not give back to the
+filesystem the space freed by deleted or truncated entries on their own. It
+prints their sizes before and after. The peer must be stopped.
+`,
+					Action: datastoreCompact,
+				},
+			},
+		},
+		{
+			Name:  "metrics",
+			Usage: "Helpers to monitor peers with Prometheus",
+			Subcommands: []cli.Command{
+				{
+					Name:  "rules",
+					Usage: "prints Prometheus alerting rules for the cluster metrics",
+					Description: `
+This command prints a Prometheus rules file with alerts for the metrics
+exported by the peers when "enable_stats" is set: peers not sending their
+"ping" metrics, IPFS daemons down or restarted, a decreasing number of peers
+and low free space in the IPFS repositories. The alert windows are based on
+the metric TTLs and intervals in the configuration of this peer. The disk
+alert triggers when the free space goes below --disk-headroom, and is not
+generated when it is 0.
+`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "disk-headroom",
+							Value: "10GiB",
+							Usage: "free space under which to alert",
+						},
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "write the rules to the given file instead of stdout",
+						},
+					},
+					Action: metricsRules,
+				},
+			},
+		},
 		{
 			Name:  "version",
 			Usage: "Prints the ipfs-cluster version",
@@ -563,6 +733,218 @@ to all effects. Peers may need to bootstrap and sync from scratch after this.
 				fmt.Printf("%s\n", version.Version)
 				return nil
 			},
+			Subcommands: []cli.Command{
+				{
+					Name:  "check",
+					Usage: "checks whether a newer version is available",
+					Flags: []cli.Flag{
+						distURLFlag(),
+					},
+					Action: versionCheck,
+				},
+			},
+		},
+		{
+			Name:  "install",
+			Usage: "Installs the daemon as a system service",
+			Description: fmt.Sprintf(`
+This command registers "%s daemon" as a service of the
+operating system, using the configuration folder given with --config:
+
+- On Linux, a systemd unit is written to /etc/systemd/system (or to
+  ~/.config/systemd/user with --user) and enabled.
+- On macOS, a launchd daemon is written to /Library/LaunchDaemons (or an
+  agent to ~/Library/LaunchAgents with --user) and loaded.
+- On Windows, a service starting automatically is created.
+
+Services are restarted when they fail. System services run as the user
+running this command (unless it is root). Use --print to only print the
+systemd unit or launchd plist.
+`, programName),
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "name",
+					Value: programName,
+					Usage: "name of the service",
+				},
+				cli.BoolFlag{
+					Name:  "user",
+					Usage: "install a user service (systemd user unit, launchd agent)",
+				},
+				cli.BoolFlag{
+					Name:  "print",
+					Usage: "print the service definition instead of installing it",
+				},
+				cli.BoolFlag{
+					Name:  "force, f",
+					Usage: "overwrite existing service definitions",
+				},
+			},
+			Action: install,
+		},
+		{
+			Name:  "id",
+			Usage: "Manages the identity of this peer",
+			Subcommands: []cli.Command{
+				{
+					Name:  "rotate",
+					Usage: "replaces the peer ID with a new one",
+					Description: fmt.Sprintf(`
+This command generates a new identity (libp2p keypair) for this peer, i.e.
+to respond to a compromised key. The peer must be stopped, but other cluster
+peers must be running and reachable.
+
+The rotation is signed with the current key and sent to one of the known
+peers, which adds the new peer ID to the peerset, moves all the pin
+allocations of the current peer ID to the new one and removes the current
+peer ID. All peers then replace the current peer ID with the new one in
+their peerstores and sets of trusted peers.
+
+Once done, the new identity is written to %s and the peer ID is updated in
+the trusted_peers and init_peerset options of this peer's configuration.
+Other peers using "crdt" consensus need their trusted_peers to be updated
+manually.
+`, DefaultIdentityFile),
+					Action: rotateIdentity,
+				},
+			},
+		},
+		{
+			Name:  "token",
+			Usage: "Manages tokens to join this cluster",
+			Subcommands: []cli.Command{
+				{
+					Name:  "create",
+					Usage: "prints a token which new peers can use to join the cluster",
+					Description: `
+This command prints a join token containing the addresses of this peer, a
+hash of the cluster secret and an expiry date. The token is signed with the
+cluster secret, which it does not contain.
+
+A new peer, configured with the same cluster secret (i.e. with "init
+--custom-secret" or the CLUSTER_SECRET environment variable), joins the
+cluster with "daemon --join-token <token>". It verifies the token before
+using it to bootstrap, and fails early when its secret does not match.
+
+The addresses are the announce_multiaddress, or the listen_multiaddress with
+unspecified IPs replaced by the ones of the network interfaces. Use
+--address to set them instead.
+`,
+					Flags: []cli.Flag{
+						cli.DurationFlag{
+							Name:  "expire-in",
+							Value: defaultTokenExpiry,
+							Usage: "duration after which the token cannot be used",
+						},
+						cli.StringSliceFlag{
+							Name:  "address",
+							Usage: "multiaddress (including /p2p/<peerID>) of a cluster peer. Can be given multiple times",
+						},
+					},
+					Action: createToken,
+				},
+			},
+		},
+		{
+			Name:  "peerstore",
+			Usage: "Exports, imports and restores the peerstore file",
+			Subcommands: []cli.Command{
+				{
+					Name:  "export",
+					Usage: "writes the peerstore file as JSON",
+					Description: `
+This command writes the addresses in the peerstore file of this peer as
+JSON, in the same format as "ipfs-cluster-ctl peers export". Peer names and
+tags are only exported by running peers, using ipfs-cluster-ctl.
+
+The JSON is written to the standard output unless --file is given.
+`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "write the JSON to the given file",
+						},
+					},
+					Action: exportPeerstore,
+				},
+				{
+					Name:  "import",
+					Usage: "adds the peers in a JSON file to the peerstore file",
+					Description: `
+This command adds the peers in a JSON file, as produced by "peerstore
+export" or "ipfs-cluster-ctl peers export", to the peerstore file of this
+peer, so that it can find the rest of the cluster when it starts. This is
+useful when provisioning new peers. The imported peers are tried before the
+ones already in the peerstore. Use "-" to read from the standard input.
+
+The peer must be stopped. Running peers can import peers with
+"ipfs-cluster-ctl peers import".
+`,
+					ArgsUsage: "<file>",
+					Action:    importPeerstore,
+				},
+				{
+					Name:  "restore",
+					Usage: "restores a backup of the peerstore file",
+					Description: `
+Every time the peerstore file changes, its previous version is kept as a
+timestamped backup next to it (peerstore.backup-<time>). The 5 most recent
+backups are kept.
+
+This command replaces the peerstore file with the given backup, or with the
+most recent one when none is given, so that a peer whose peerstore was
+emptied or truncated can find the rest of the cluster again. The current
+peerstore file is backed up first. Use --list to show the available backups.
+
+The peer must be stopped.
+`,
+					ArgsUsage: "[backup]",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "list, l",
+							Usage: "list the available backups, newest first",
+						},
+					},
+					Action: restorePeerstore,
+				},
+			},
+		},
+		{
+			Name:  "upgrade",
+			Usage: "Upgrades the ipfs-cluster-service binary",
+			Description: `
+This command downloads a release of ipfs-cluster-service (the latest one,
+unless --version is given) for the current platform, verifies it against the
+SHA-512 checksum and the signature published with it and replaces the
+running binary. The signature must be made with the release key built into
+this binary. The peer must be restarted afterwards to run the new version.
+Older versions are refused unless --force is given.
+
+With --rolling, the running peer is asked (through its REST API) to upgrade
+every peer in the cluster, one at a time. Each peer downloads the release
+from --dist-url, verifies it, replaces its binary and restarts. The next peer
+is only upgraded once the previous one is back and reports the new version.
+The contacted peer is upgraded last. Peers need to run with the same user
+that owns their binaries for this to work, and must allow remote upgrades by
+setting "Cluster.Upgrade" to "trusted" in the "rpc_policy" option of their
+"cluster" configuration.
+`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "version",
+					Usage: "version to upgrade to (default: latest)",
+				},
+				cli.BoolFlag{
+					Name:  "rolling",
+					Usage: "upgrade all cluster peers one by one through the running peer",
+				},
+				cli.BoolFlag{
+					Name:  "force, f",
+					Usage: "install the release even if it is the current or an older version",
+				},
+				distURLFlag(),
+			},
+			Action: upgrade,
 		},
 	}
 
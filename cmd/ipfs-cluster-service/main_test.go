@@ -1,8 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	ipfscluster "github.com/ipfs/ipfs-cluster"
 	"github.com/ipfs/ipfs-cluster/cmdutils"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -36,3 +43,230 @@ func TestRandomPorts(t *testing.T) {
 		t.Error("expected different ipv6 ports")
 	}
 }
+
+func TestLoadMultiConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "multi.json")
+	writeCfg := func(s string) {
+		if err := ioutil.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeCfg(`{"clusters": [{"name": "a", "folder": "a"}, {"name": "b", "folder": "/b"}]}`)
+	cfg, err := loadMultiConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Clusters[0].Folder != filepath.Join(dir, "a") {
+		t.Error("relative folders should be relative to the file")
+	}
+	if cfg.Clusters[1].Folder != "/b" {
+		t.Error("absolute folders should be kept")
+	}
+
+	writeCfg(`{"clusters": [{"name": "a", "folder": "a"}, {"name": "a", "folder": "b"}]}`)
+	if _, err := loadMultiConfig(path); err == nil {
+		t.Error("expected an error with duplicate names")
+	}
+
+	writeCfg(`{"clusters": [{"name": "a", "folder": "a"}, {"name": "b", "folder": "a"}]}`)
+	if _, err := loadMultiConfig(path); err == nil {
+		t.Error("expected an error with duplicate folders")
+	}
+
+	writeCfg(`{"clusters": []}`)
+	if _, err := loadMultiConfig(path); err == nil {
+		t.Error("expected an error without clusters")
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, lockFileName)
+	testCases := []struct {
+		content string
+		stale   bool
+	}{
+		{"", false},
+		{fmt.Sprintf(`{"OwnerPID": %d}`, os.Getpid()), false},
+		{`{"OwnerPID": 2147483647}`, true},
+		// Only locks owned by a process which is provably dead
+		// are stale.
+		{"garbage", false},
+		{`{"OwnerPID": 0}`, false},
+		{`{"OwnerPID": -1}`, false},
+	}
+
+	for _, tc := range testCases {
+		if err := ioutil.WriteFile(path, []byte(tc.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if staleLock(path) != tc.stale {
+			t.Errorf("%q: expected stale to be %t", tc.content, tc.stale)
+		}
+	}
+
+	if staleLock(filepath.Join(dir, "missing")) {
+		t.Error("a missing lock is not stale")
+	}
+}
+
+func TestServiceTemplates(t *testing.T) {
+	info := &serviceInfo{
+		Name:        "cluster",
+		Description: "IPFS Cluster peer test",
+		Exe:         "/usr/local/bin/ipfs-cluster-service",
+		Folder:      "/home/user/my cluster",
+		User:        "user",
+		LogFile:     "/home/user/my cluster/ipfs-cluster-service.log",
+	}
+
+	var unit bytes.Buffer
+	err := systemdTemplate.Execute(&unit, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`ExecStart="/usr/local/bin/ipfs-cluster-service" --config "/home/user/my cluster" daemon`,
+		"User=user",
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(unit.String(), line) {
+			t.Errorf("systemd unit should contain %q:\n%s", line, unit.String())
+		}
+	}
+
+	info.Folder = "/home/user/a&b"
+	var plist bytes.Buffer
+	err = launchdTemplate.Execute(&plist, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"<string>io.ipfs.cluster</string>",
+		"<string>/home/user/a&amp;b</string>",
+		"<string>user</string>",
+	} {
+		if !strings.Contains(plist.String(), line) {
+			t.Errorf("launchd plist should contain %q:\n%s", line, plist.String())
+		}
+	}
+}
+
+func TestGroupAddrs(t *testing.T) {
+	p1 := "/p2p/QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc"
+	p2 := "/p2p/QmUZ13osndQ5uL4tPWHXe3iBgBgq9gfewcBMSCAuMBsDJ6"
+	var addrs []ma.Multiaddr
+	for _, a := range []string{
+		"/ip4/1.2.3.4/tcp/9096" + p2,
+		"/ip4/1.2.3.5/tcp/9096" + p1,
+		"/ip4/1.2.3.6/tcp/9096" + p2,
+		"/ip4/1.2.3.4/tcp/9096" + p2,
+	} {
+		addrs = append(addrs, ma.StringCast(a))
+	}
+
+	pinfos, err := groupAddrs(addrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pinfos) != 2 {
+		t.Fatal("expected 2 peers")
+	}
+	if "/p2p/"+pinfos[0].ID.Pretty() != p2 || "/p2p/"+pinfos[1].ID.Pretty() != p1 {
+		t.Error("peers should keep the order in which they appear")
+	}
+	if len(pinfos[0].Addrs) != 2 {
+		t.Error("expected duplicated addresses to be removed")
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	cfg := []byte(`{
+  "cluster": {
+    "secret": "abcdef",
+    "peername": "peer1"
+  },
+  "api": {
+    "restapi": {
+      "basic_auth_credentials": {"admin": "pass"},
+      "private_key": ""
+    }
+  },
+  "federation": [{"password": "x"}]
+}`)
+	res, err := redactJSON(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := string(res)
+	for _, secret := range []string{"abcdef", `"pass"`, `"x"`} {
+		if strings.Contains(str, secret) {
+			t.Errorf("%s should have been redacted: %s", secret, str)
+		}
+	}
+	for _, kept := range []string{"peer1", `"admin"`, `"private_key": ""`} {
+		if !strings.Contains(str, kept) {
+			t.Errorf("%s should have been kept: %s", kept, str)
+		}
+	}
+
+	if _, err := redactJSON([]byte("{")); err == nil {
+		t.Error("expected an error with invalid JSON")
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+	err = ioutil.WriteFile(path, []byte("a\nb\nc\nd\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := tailLines(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != "c\nd\n" {
+		t.Errorf("unexpected tail: %q", res)
+	}
+}
+
+func TestSetupFollowerMode(t *testing.T) {
+	cfgs := &cmdutils.Configs{Cluster: &ipfscluster.Config{}}
+	cfgs.Cluster.Default()
+	// Simulate a policy shared with the defaults.
+	cfgs.Cluster.RPCPolicy = ipfscluster.DefaultRPCPolicy
+	defaultGC := ipfscluster.DefaultRPCPolicy["Cluster.RepoGCLocal"]
+
+	setupFollowerMode(cfgs)
+
+	if !cfgs.Cluster.FollowerMode {
+		t.Error("expected follower mode")
+	}
+	if cfgs.Cluster.RPCPolicy["Cluster.RepoGCLocal"] != ipfscluster.RPCClosed {
+		t.Error("Cluster.RepoGCLocal should be closed in follower mode")
+	}
+	if ipfscluster.DefaultRPCPolicy["Cluster.RepoGCLocal"] != defaultGC {
+		t.Error("DefaultRPCPolicy should not have been modified")
+	}
+	if len(cfgs.Cluster.RPCPolicy) != len(ipfscluster.DefaultRPCPolicy) {
+		t.Error("the rest of the policy should have been kept")
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/observations"
+
+	humanize "github.com/dustin/go-humanize"
+	cli "github.com/urfave/cli"
+)
+
+// metricsRules prints Prometheus alerting rules based on the thresholds in
+// the configuration of this peer.
+func metricsRules(c *cli.Context) error {
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+
+	headroom, err := humanize.ParseBytes(c.String("disk-headroom"))
+	checkErr("parsing --disk-headroom", err)
+
+	rules, err := observations.PrometheusRules(observations.RuleThresholds{
+		// Same TTL as the "ping" metrics sent by the peer.
+		PingTTL:           cfgs.Cluster.MonitorPingInterval * 2,
+		PeerWatchInterval: cfgs.Cluster.PeerWatchInterval,
+		FreeSpaceTTL:      cfgs.Diskinf.MetricTTL,
+		DiskHeadroom:      headroom,
+	})
+	checkErr("generating rules", err)
+
+	if file := c.String("file"); file != "" {
+		checkErr("writing rules", ioutil.WriteFile(file, rules, 0644))
+		out("rules written to %s\n", file)
+		return nil
+	}
+	os.Stdout.Write(rules)
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+
+	host "github.com/libp2p/go-libp2p-core/host"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	cli "github.com/urfave/cli"
+)
+
+// multiConfig is the format of the file given to "daemon --multi". It lists
+// the cluster instances run by a single process. Every instance has its own
+// configuration folder (as created by "init"), and therefore its own
+// identity, secret, datastore and APIs.
+type multiConfig struct {
+	Clusters []*multiInstanceConfig `json:"clusters"`
+}
+
+type multiInstanceConfig struct {
+	// Name identifies the instance in the logs.
+	Name string `json:"name"`
+	// Folder is the configuration folder of the instance. Relative
+	// paths are relative to the location of the multi-cluster file.
+	Folder string `json:"folder"`
+}
+
+// instance is a running cluster peer in multi-cluster mode.
+type instance struct {
+	name      string
+	cfgHelper *cmdutils.ConfigHelper
+	cluster   *ipfscluster.Cluster
+	host      host.Host
+	dht       *dht.IpfsDHT
+	locker    *lock
+}
+
+func loadMultiConfig(path string) (*multiConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg multiConfig
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %s", path, err)
+	}
+	if len(cfg.Clusters) == 0 {
+		return nil, fmt.Errorf("%s does not define any clusters", path)
+	}
+
+	names := make(map[string]struct{})
+	folders := make(map[string]struct{})
+	for _, inst := range cfg.Clusters {
+		if inst.Name == "" || inst.Folder == "" {
+			return nil, errors.New("every cluster needs a name and a folder")
+		}
+		if !filepath.IsAbs(inst.Folder) {
+			inst.Folder = filepath.Join(filepath.Dir(path), inst.Folder)
+		}
+		if _, ok := names[inst.Name]; ok {
+			return nil, fmt.Errorf("duplicate cluster name: %s", inst.Name)
+		}
+		if _, ok := folders[inst.Folder]; ok {
+			return nil, fmt.Errorf("duplicate cluster folder: %s", inst.Folder)
+		}
+		names[inst.Name] = struct{}{}
+		folders[inst.Folder] = struct{}{}
+	}
+	return &cfg, nil
+}
+
+// multiDaemon runs all the cluster instances listed in the file given with
+// --multi in this process.
+func multiDaemon(c *cli.Context) error {
+	for _, f := range []string{"bootstrap", "upgrade"} {
+		if c.IsSet(f) {
+			checkErr("", fmt.Errorf("--%s cannot be used with --multi", f))
+		}
+	}
+
+	multiCfg, err := loadMultiConfig(c.String("multi"))
+	checkErr("loading multi-cluster configuration", err)
+
+	logger.Info("Initializing. For verbose output run with \"-l debug\". Please wait...")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var instances []*instance
+	for i, instCfg := range multiCfg.Clusters {
+		inst, err := startInstance(ctx, c, instCfg, i == 0)
+		if err != nil {
+			for _, inst := range instances {
+				inst.cluster.Shutdown(ctx)
+				inst.cfgHelper.Manager().Shutdown()
+				inst.locker.tryUnlock()
+			}
+			checkErr("starting cluster %s", err, instCfg.Name)
+		}
+		instances = append(instances, inst)
+	}
+
+	handleSignalsMulti(ctx, cancel, instances)
+	return nil
+}
+
+// startInstance launches a cluster peer from the given configuration folder.
+// Stats, tracing and the PID file are process-wide, so they are only set up
+// for the first instance.
+func startInstance(ctx context.Context, c *cli.Context, instCfg *multiInstanceConfig, first bool) (*instance, error) {
+	l := &lock{path: instCfg.Folder}
+	if first {
+		l.pidFile = c.String("pid-file")
+	}
+	l.lock()
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(
+		filepath.Join(instCfg.Folder, DefaultConfigFile),
+		filepath.Join(instCfg.Folder, DefaultIdentityFile),
+	)
+	if err != nil {
+		l.tryUnlock()
+		return nil, err
+	}
+	cfgs := cfgHelper.Configs()
+
+	if first {
+		if c.Bool("stats") {
+			cfgs.Metrics.EnableStats = true
+		}
+		cfgHelper.SetupTracing(c.Bool("tracing"))
+	} else {
+		if cfgs.Metrics.EnableStats || cfgs.Tracing.EnableTracing {
+			logger.Warningf("%s: stats and tracing are only enabled for the first cluster", instCfg.Name)
+		}
+		cfgs.Metrics.EnableStats = false
+		cfgs.Tracing.EnableTracing = false
+	}
+
+	if c.Bool("leave") {
+		cfgs.Cluster.LeaveOnShutdown = true
+	}
+
+	if c.Bool("follow") {
+		if cfgHelper.GetConsensus() != cfgs.Crdt.ConfigKey() {
+			cfgHelper.Manager().Shutdown()
+			l.tryUnlock()
+			return nil, errors.New("--follow can only be used with \"crdt\" consensus")
+		}
+		setupFollowerMode(cfgs)
+	}
+
+	h, pubsub, idht, err := ipfscluster.NewClusterHost(ctx, cfgHelper.Identity(), cfgs.Cluster)
+	if err != nil {
+		cfgHelper.Manager().Shutdown()
+		l.tryUnlock()
+		return nil, err
+	}
+
+	cluster, err := createCluster(ctx, c, cfgHelper, h, pubsub, idht, false)
+	if err != nil {
+		h.Close()
+		cfgHelper.Manager().Shutdown()
+		l.tryUnlock()
+		return nil, err
+	}
+	logger.Infof("%s: cluster peer %s started", instCfg.Name, h.ID().Pretty())
+
+	return &instance{
+		name:      instCfg.Name,
+		cfgHelper: cfgHelper,
+		cluster:   cluster,
+		host:      h,
+		dht:       idht,
+		locker:    l,
+	}, nil
+}
+
+// handleSignalsMulti shuts down all the instances on SIGINT, SIGTERM and
+// SIGHUP. The process exits on the third signal. It returns once all the
+// instances have shut down.
+func handleSignalsMulti(ctx context.Context, cancel context.CancelFunc, instances []*instance) {
+	signalChan := make(chan os.Signal, 20)
+	signal.Notify(
+		signalChan,
+		syscall.SIGINT,
+		syscall.SIGTERM,
+		syscall.SIGHUP,
+	)
+
+	var wg sync.WaitGroup
+	for _, inst := range instances {
+		wg.Add(1)
+		go func(inst *instance) {
+			defer wg.Done()
+			<-inst.cluster.Done()
+			inst.dht.Close()
+			inst.host.Close()
+			inst.cfgHelper.Manager().Shutdown()
+			err := inst.locker.tryUnlock()
+			if err != nil {
+				logger.Errorf("%s: error releasing execution lock: %s", inst.name, err)
+			}
+			logger.Infof("%s: cluster peer shut down", inst.name)
+		}(inst)
+	}
+
+	allDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(allDone)
+	}()
+
+	var ctrlcCount int
+	for {
+		select {
+		case <-signalChan:
+			ctrlcCount++
+			switch ctrlcCount {
+			case 1:
+				for _, inst := range instances {
+					go func(inst *instance) {
+						err := inst.cluster.Shutdown(ctx)
+						if err != nil {
+							logger.Errorf("%s: error shutting down cluster: %s", inst.name, err)
+						}
+					}(inst)
+				}
+			case 2:
+				out("Shutdown is taking too long! Press Ctrl-c again to manually kill all clusters.\n")
+			case 3:
+				out("exiting NOW\n")
+				os.Exit(1)
+			}
+		case <-allDone:
+			cancel()
+			return
+		}
+	}
+}
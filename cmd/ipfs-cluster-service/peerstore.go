@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/pstoremgr"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	cli "github.com/urfave/cli"
+)
+
+// peerstoreManager returns a pstoremgr.Manager for the peerstore file of
+// this peer, without a libp2p host.
+func peerstoreManager() *pstoremgr.Manager {
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	cfgHelper.Manager().Shutdown()
+	return pstoremgr.New(context.Background(), nil, cfgHelper.Configs().Cluster.GetPeerstorePath())
+}
+
+// exportPeerstore writes the peerstore file as JSON, in the format used by
+// "ipfs-cluster-ctl peers export". Peer names and tags are only known to
+// running peers, so they are not included.
+func exportPeerstore(c *cli.Context) error {
+	pm := peerstoreManager()
+
+	addrInfos, err := groupAddrs(pm.LoadPeerstore())
+	checkErr("parsing peerstore", err)
+
+	known := []*api.KnownPeer{}
+	for _, pinfo := range addrInfos {
+		kp := &api.KnownPeer{ID: pinfo.ID}
+		for _, a := range pinfo.Addrs {
+			kp.Addresses = append(kp.Addresses, api.NewMultiaddrWithValue(a))
+		}
+		known = append(known, kp)
+	}
+
+	j, err := json.MarshalIndent(known, "", "    ")
+	checkErr("encoding peerstore", err)
+	j = append(j, '\n')
+	if path := c.String("file"); path != "" {
+		checkErr("writing file", ioutil.WriteFile(path, j, 0600))
+		return nil
+	}
+	fmt.Printf("%s", j)
+	return nil
+}
+
+// importPeerstore adds the peers in a JSON file, as produced by "peers
+// export", to the peerstore file. Imported peers take priority over the
+// ones already in it.
+func importPeerstore(c *cli.Context) error {
+	path := c.Args().First()
+	if path == "" {
+		checkErr("", errors.New("a file is needed"))
+	}
+
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		checkErr("opening file", err)
+		defer f.Close()
+		r = f
+	}
+	var known []*api.KnownPeer
+	checkErr("decoding file", json.NewDecoder(r).Decode(&known))
+
+	locker.lock()
+	defer locker.tryUnlock()
+
+	pm := peerstoreManager()
+	var addrs []ma.Multiaddr
+	for _, kp := range known {
+		pinfo := &peer.AddrInfo{ID: kp.ID}
+		for _, a := range kp.Addresses {
+			pinfo.Addrs = append(pinfo.Addrs, a.Value())
+		}
+		p2pAddrs, err := peer.AddrInfoToP2pAddrs(pinfo)
+		checkErr("parsing addresses of %s", err, kp.ID.Pretty())
+		addrs = append(addrs, p2pAddrs...)
+	}
+	addrs = append(addrs, pm.LoadPeerstore()...)
+
+	addrInfos, err := groupAddrs(addrs)
+	checkErr("parsing addresses", err)
+	checkErr("saving peerstore", pm.SavePeerstore(addrInfos))
+	out("%d peers imported. The peerstore has %d peers now.\n", len(known), len(addrInfos))
+	return nil
+}
+
+// restorePeerstore replaces the peerstore file with one of its backups, or
+// lists them.
+func restorePeerstore(c *cli.Context) error {
+	pm := peerstoreManager()
+	backups, err := pm.Backups()
+	checkErr("listing peerstore backups", err)
+
+	if c.Bool("list") {
+		for _, b := range backups {
+			fmt.Println(b)
+		}
+		return nil
+	}
+
+	backup := c.Args().First()
+	if backup == "" {
+		if len(backups) == 0 {
+			checkErr("", errors.New("there are no peerstore backups"))
+		}
+		backup = backups[0]
+	}
+
+	locker.lock()
+	defer locker.tryUnlock()
+
+	checkErr("restoring peerstore", pm.RestorePeerstore(backup))
+	out("peerstore restored from %s.\n", backup)
+	return nil
+}
+
+// groupAddrs groups /p2p/ multiaddresses by peer, keeping the order in
+// which peers first appear and removing duplicated addresses.
+func groupAddrs(addrs []ma.Multiaddr) ([]peer.AddrInfo, error) {
+	var pinfos []peer.AddrInfo
+	index := make(map[peer.ID]int)
+	seen := make(map[string]struct{})
+	for _, a := range addrs {
+		if _, ok := seen[a.String()]; ok {
+			continue
+		}
+		seen[a.String()] = struct{}{}
+
+		pinfo, err := peer.AddrInfoFromP2pAddr(a)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := index[pinfo.ID]
+		if !ok {
+			index[pinfo.ID] = len(pinfos)
+			pinfos = append(pinfos, *pinfo)
+			continue
+		}
+		pinfos[i].Addrs = append(pinfos[i].Addrs, pinfo.Addrs...)
+	}
+	return pinfos, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	cli "github.com/urfave/cli"
+)
+
+// rotateTimeout limits how long we wait for a peer to complete the
+// rotation, which involves updating the allocations of all the pins of
+// this peer.
+var rotateTimeout = 5 * time.Minute
+
+// rotateIdentity generates a new identity for this peer and asks a running
+// cluster peer to replace the current peer ID with the new one in the
+// peerset, the pin allocations and the peerstores of all peers. The new
+// identity is only saved once that has succeeded.
+func rotateIdentity(c *cli.Context) error {
+	locker.lock()
+	defer locker.tryUnlock()
+
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	defer cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+	oldIdent := cfgHelper.Identity()
+
+	newIdent, err := config.NewIdentity()
+	checkErr("generating a new identity", err)
+	newIdentityPath := identityPath + ".new"
+	checkErr("saving new identity", newIdent.SaveJSON(newIdentityPath))
+
+	rot := &api.PeerRotation{
+		Old: oldIdent.ID,
+		New: newIdent.ID,
+	}
+	checkErr("signing peer rotation", rot.Sign(oldIdent.PrivateKey))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The new identity is kept on errors, as the rotation may have
+	// happened even if we did not get an answer.
+	err = requestRotation(ctx, cfgHelper, rot)
+	checkErr("rotating peer ID (the new identity was kept in %s)", err, newIdentityPath)
+	checkErr("replacing identity", os.Rename(newIdentityPath, identityPath))
+
+	replace := func(peers []peer.ID) bool {
+		replaced := false
+		for i, p := range peers {
+			if p == rot.Old {
+				peers[i] = rot.New
+				replaced = true
+			}
+		}
+		return replaced
+	}
+	trustedReplaced := replace(cfgs.Crdt.TrustedPeers)
+	peersetReplaced := replace(cfgs.Raft.InitPeerset)
+	if trustedReplaced || peersetReplaced {
+		checkErr("saving configuration", cfgHelper.SaveConfigToDisk())
+	}
+
+	out("peer ID rotated from %s to %s\n", rot.Old.Pretty(), rot.New.Pretty())
+	out("new identity written to %s\n", identityPath)
+	if cfgHelper.GetConsensus() == cfgs.Crdt.ConfigKey() {
+		out("remember to replace %s with %s in the trusted_peers of all peers\n", rot.Old.Pretty(), rot.New.Pretty())
+	}
+	return nil
+}
+
+// requestRotation sends the rotation to the known peers until one of them
+// accepts it.
+func requestRotation(ctx context.Context, cfgHelper *cmdutils.ConfigHelper, rot *api.PeerRotation) error {
+	h, err := newOfflineHost(ctx, cfgHelper)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	peers := knownPeers(ctx, h, cfgHelper.Configs())
+	if len(peers) == 0 {
+		return errors.New("no known peers to contact. The peer ID can only be rotated when other peers are running")
+	}
+
+	rpcClient := rpc.NewClient(h, version.RPCProtocol)
+	for _, p := range peers {
+		err = func() error {
+			ctx, cancel := context.WithTimeout(ctx, rotateTimeout)
+			defer cancel()
+
+			err := h.Connect(ctx, h.Peerstore().PeerInfo(p))
+			if err != nil {
+				return err
+			}
+			return rpcClient.CallContext(ctx, p, "Cluster", "RotatePeer", rot, &struct{}{})
+		}()
+		if err == nil {
+			return nil
+		}
+		logger.Warningf("%s could not rotate the peer ID: %s", p.Pretty(), err)
+	}
+	return fmt.Errorf("no peer could rotate the peer ID (last error: %s)", err)
+}
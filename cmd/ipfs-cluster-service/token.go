@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+	cli "github.com/urfave/cli"
+)
+
+const defaultTokenExpiry = 24 * time.Hour
+
+// joinToken carries what a new peer needs to join a cluster. Tokens are
+// signed with the cluster secret (HMAC-SHA256), which they do not contain,
+// so that only peers with the secret can create them and verify them.
+type joinToken struct {
+	Addresses  []api.Multiaddr `json:"addresses"`
+	SecretHash string          `json:"secret_hash"`
+	ExpiresAt  time.Time       `json:"expires_at"`
+}
+
+func secretHash(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:])
+}
+
+func tokenMAC(payload, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// encodeJoinToken returns a join token for the given addresses, signed with
+// the given secret.
+func encodeJoinToken(addrs []ma.Multiaddr, secret []byte, expiresAt time.Time) (string, error) {
+	if len(secret) == 0 {
+		return "", errors.New("join tokens need a cluster secret")
+	}
+	if len(addrs) == 0 {
+		return "", errors.New("join tokens need at least one address")
+	}
+
+	tok := joinToken{
+		SecretHash: secretHash(secret),
+		ExpiresAt:  expiresAt.UTC(),
+	}
+	for _, a := range addrs {
+		tok.Addresses = append(tok.Addresses, api.NewMultiaddrWithValue(a))
+	}
+	payload, err := json.Marshal(tok)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(tokenMAC(payload, secret)), nil
+}
+
+// decodeJoinToken verifies a join token with the given secret and returns
+// the addresses in it.
+func decodeJoinToken(token string, secret []byte, now time.Time) ([]ma.Multiaddr, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 2 {
+		return nil, errors.New("malformed join token")
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return nil, errors.New("malformed join token")
+	}
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.New("malformed join token")
+	}
+
+	var tok joinToken
+	if err := json.Unmarshal(payload, &tok); err != nil {
+		return nil, errors.New("malformed join token")
+	}
+
+	// Check the secret first, so that a wrong secret is reported as
+	// such and not as an invalid signature.
+	if tok.SecretHash != secretHash(secret) {
+		return nil, errors.New("the cluster secret of this peer does not match the one of the join token")
+	}
+	if !hmac.Equal(sig, tokenMAC(payload, secret)) {
+		return nil, errors.New("invalid join token signature")
+	}
+	if now.After(tok.ExpiresAt) {
+		return nil, fmt.Errorf("the join token expired at %s", tok.ExpiresAt)
+	}
+
+	addrs := make([]ma.Multiaddr, 0, len(tok.Addresses))
+	for _, a := range tok.Addresses {
+		addrs = append(addrs, a.Value())
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("the join token has no addresses")
+	}
+	return addrs, nil
+}
+
+// tokenAddresses returns the addresses under which other peers can reach
+// this one: the announced addresses or, if none, the listen addresses, with
+// unspecified IPs replaced by the ones of the network interfaces.
+func tokenAddresses(listen []ma.Multiaddr, pid peer.ID) ([]ma.Multiaddr, error) {
+	ifaceAddrs, err := manet.InterfaceMultiaddrs()
+	if err != nil {
+		return nil, err
+	}
+
+	p2pPart, err := ma.NewComponent("p2p", peer.IDB58Encode(pid))
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []ma.Multiaddr
+	for _, l := range listen {
+		if !manet.IsIPUnspecified(l) {
+			addrs = append(addrs, l.Encapsulate(p2pPart))
+			continue
+		}
+		ipPart, rest := ma.SplitFirst(l)
+		for _, iface := range ifaceAddrs {
+			ifacePart, _ := ma.SplitFirst(iface)
+			if ifacePart.Protocol().Code != ipPart.Protocol().Code ||
+				manet.IsIPLoopback(iface) ||
+				manet.IsIP6LinkLocal(iface) {
+				continue
+			}
+			addrs = append(addrs, ma.Join(iface, rest, p2pPart))
+		}
+	}
+	return addrs, nil
+}
+
+// createToken prints a join token for this peer.
+func createToken(c *cli.Context) error {
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	checkErr("loading configuration", err)
+	cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+
+	var addrs []ma.Multiaddr
+	if flagAddrs := c.StringSlice("address"); len(flagAddrs) > 0 {
+		addrs = parseBootstraps(flagAddrs)
+	} else {
+		listen := cfgs.Cluster.AnnounceAddr
+		if len(listen) == 0 {
+			listen = cfgs.Cluster.ListenAddr
+		}
+		addrs, err = tokenAddresses(listen, cfgHelper.Identity().ID)
+		checkErr("obtaining the addresses of this peer", err)
+		if len(addrs) == 0 {
+			checkErr("", errors.New("this peer has no usable addresses: provide them with --address"))
+		}
+	}
+
+	expiry := c.Duration("expire-in")
+	if expiry <= 0 {
+		checkErr("", errors.New("--expire-in must be positive"))
+	}
+
+	token, err := encodeJoinToken(addrs, cfgs.Cluster.Secret, time.Now().Add(expiry))
+	checkErr("creating join token", err)
+	fmt.Println(token)
+	return nil
+}
+
+// joinTokenBootstraps verifies the given join token with the cluster
+// secret of this peer and returns the addresses to bootstrap to.
+func joinTokenBootstraps(token string, secret []byte) []ma.Multiaddr {
+	if len(secret) == 0 {
+		checkErr("", errors.New("a cluster secret is needed to use a join token"))
+	}
+	addrs, err := decodeJoinToken(token, secret, time.Now())
+	checkErr("verifying join token", err)
+	return addrs
+}
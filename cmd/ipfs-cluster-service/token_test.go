@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestJoinToken(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	addr, _ := ma.NewMultiaddr("/ip4/1.2.3.4/tcp/9096/p2p/" + test.PeerID1.Pretty())
+	now := time.Now()
+
+	token, err := encodeJoinToken([]ma.Multiaddr{addr}, secret, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, err := decodeJoinToken(token, secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 1 || !addrs[0].Equal(addr) {
+		t.Errorf("unexpected addresses: %s", addrs)
+	}
+
+	_, err = decodeJoinToken(token, []byte("fedcba9876543210fedcba9876543210"), now)
+	if err == nil || !strings.Contains(err.Error(), "secret") {
+		t.Error("expected a secret mismatch error:", err)
+	}
+
+	_, err = decodeJoinToken(token, secret, now.Add(2*time.Hour))
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Error("expected an expiry error:", err)
+	}
+
+	parts := strings.Split(token, ".")
+	other, err := encodeJoinToken([]ma.Multiaddr{addr}, secret, now.Add(48*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := strings.Split(other, ".")[0] + "." + parts[1]
+	_, err = decodeJoinToken(forged, secret, now)
+	if err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Error("expected a signature error:", err)
+	}
+
+	_, err = encodeJoinToken([]ma.Multiaddr{addr}, nil, now.Add(time.Hour))
+	if err == nil {
+		t.Error("expected an error creating a token without secret")
+	}
+}
+
+func TestTokenAddresses(t *testing.T) {
+	listen, _ := ma.NewMultiaddr("/ip4/0.0.0.0/tcp/9096")
+	addrs, err := tokenAddresses([]ma.Multiaddr{listen}, test.PeerID1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range addrs {
+		if strings.HasPrefix(a.String(), "/ip4/0.0.0.0") || strings.HasPrefix(a.String(), "/ip4/127.") {
+			t.Errorf("unexpected address %s", a)
+		}
+		if !strings.HasSuffix(a.String(), "/tcp/9096/p2p/"+test.PeerID1.Pretty()) {
+			t.Errorf("unexpected address %s", a)
+		}
+	}
+}
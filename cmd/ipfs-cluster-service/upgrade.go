@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+	"github.com/ipfs/ipfs-cluster/cmdutils"
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	semver "github.com/blang/semver"
+	cli "github.com/urfave/cli"
+)
+
+// versionCheckTimeout limits how long we wait for the list of releases.
+var versionCheckTimeout = 30 * time.Second
+
+func distURLFlag() cli.StringFlag {
+	return cli.StringFlag{
+		Name:  "dist-url",
+		Value: cmdutils.DefaultDistURL,
+		Usage: "location of the IPFS distributions",
+	}
+}
+
+// versionCheck prints whether a newer release is available.
+func versionCheck(c *cli.Context) error {
+	ctx, cancel := context.WithTimeout(context.Background(), versionCheckTimeout)
+	defer cancel()
+
+	latest, err := cmdutils.LatestVersion(ctx, c.String("dist-url"), programName)
+	checkErr("checking latest version", err)
+
+	fmt.Printf("current version: %s\n", version.Version)
+	fmt.Printf("latest version:  %s\n", latest)
+	if latest.GT(version.Version) {
+		fmt.Printf("a new version is available. Run \"%s upgrade\" to upgrade.\n", programName)
+	}
+	return nil
+}
+
+// upgrade downloads and installs a new release of this program. With
+// --rolling, it asks the running peer to upgrade every cluster peer instead.
+func upgrade(c *cli.Context) error {
+	ctx := context.Background()
+	distURL := c.String("dist-url")
+
+	var target semver.Version
+	var err error
+	if v := c.String("version"); v != "" {
+		target, err = semver.ParseTolerant(v)
+		checkErr("parsing version", err)
+	} else {
+		tctx, cancel := context.WithTimeout(ctx, versionCheckTimeout)
+		defer cancel()
+		target, err = cmdutils.LatestVersion(tctx, distURL, programName)
+		checkErr("checking latest version", err)
+	}
+
+	if c.Bool("rolling") {
+		apiClient, err := localAPIClient()
+		checkErr("creating API client", err)
+		out("starting rolling upgrade to version %s. This may take a while...\n", target)
+		opts := api.UpgradeOptions{
+			Version: target.String(),
+			DistURL: distURL,
+			Force:   c.Bool("force"),
+		}
+		checkErr("upgrading cluster peers", apiClient.Upgrade(ctx, opts))
+		out("all cluster peers were upgraded to version %s\n", target)
+		return nil
+	}
+
+	if !c.Bool("force") {
+		if target.Equals(version.Version) {
+			out("already running version %s\n", target)
+			return nil
+		}
+		if target.LT(version.Version) {
+			checkErr("", fmt.Errorf("refusing to downgrade from %s to %s without --force", version.Version, target))
+		}
+	}
+
+	bin, err := cmdutils.FetchRelease(ctx, distURL, programName, target)
+	checkErr("downloading release", err)
+	checkErr("replacing binary", cmdutils.ReplaceExecutable(bin))
+	out("%s upgraded to version %s. Restart the peer to run it.\n", programName, target)
+	return nil
+}
+
+// localAPIClient returns a client for the REST API of the running peer, as
+// configured in the peer's configuration.
+func localAPIClient() (client.Client, error) {
+	cfgHelper, err := cmdutils.NewLoadedConfigHelper(configPath, identityPath)
+	if err != nil {
+		return nil, err
+	}
+	cfgHelper.Manager().Shutdown()
+	cfgs := cfgHelper.Configs()
+
+	if !cfgHelper.Manager().IsLoadedFromJSON(config.API, cfgs.Restapi.ConfigKey()) {
+		return nil, errors.New("the REST API is not enabled in this peer")
+	}
+	if len(cfgs.Restapi.HTTPListenAddr) == 0 {
+		return nil, errors.New("the REST API does not listen on any HTTP address")
+	}
+
+	// The certificate is not verified, as we are contacting our own
+	// peer.
+	clientCfg := &client.Config{
+		APIAddr:      cfgs.Restapi.HTTPListenAddr[0],
+		SSL:          cfgs.Restapi.TLS != nil,
+		NoVerifyCert: cfgs.Restapi.TLS != nil,
+	}
+	for user, pass := range cfgs.Restapi.BasicAuthCredentials {
+		clientCfg.Username = user
+		clientCfg.Password = pass
+		break
+	}
+	return client.NewDefaultClient(clientCfg)
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "errors"
+
+// installWindowsService is only supported on Windows.
+func installWindowsService(info *serviceInfo) error {
+	return errors.New("windows services can only be installed on windows")
+}
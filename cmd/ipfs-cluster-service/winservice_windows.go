@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// installWindowsService registers the daemon as a Windows service which
+// starts automatically and is restarted on failure.
+func installWindowsService(info *serviceInfo) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(info.Name)
+	if err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", info.Name)
+	}
+
+	s, err = m.CreateService(
+		info.Name,
+		info.Exe,
+		mgr.Config{
+			DisplayName: "IPFS Cluster",
+			Description: info.Description,
+			StartType:   mgr.StartAutomatic,
+		},
+		"--config", info.Folder, "daemon",
+	)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return s.SetRecoveryActions(
+		[]mgr.RecoveryAction{
+			{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		},
+		uint32((24 * time.Hour).Seconds()),
+	)
+}
@@ -99,7 +99,10 @@ func listenUDP(name, ip string) (io.Closer, int, error) {
 
 // HandleSignals orderly shuts down an IPFS Cluster peer
 // on SIGINT, SIGTERM, SIGHUP. It forces command termination
-// on the 3rd-signal count.
+// on the 3rd-signal count. When running as a systemd service, it
+// notifies systemd when the peer is ready and sends watchdog
+// notifications. When running as a Windows service, stop requests from
+// the service manager are handled like signals.
 func HandleSignals(
 	ctx context.Context,
 	cancel context.CancelFunc,
@@ -115,6 +118,9 @@ func HandleSignals(
 		syscall.SIGHUP,
 	)
 
+	go notifySystemd(ctx, cluster)
+	go runWindowsService(ctx, cluster, signalChan)
+
 	var ctrlcCount int
 	for {
 		select {
@@ -133,6 +139,7 @@ func HandleSignals(
 func handleCtrlC(ctx context.Context, cluster *ipfscluster.Cluster, ctrlcCount int) {
 	switch ctrlcCount {
 	case 1:
+		SdNotify("STOPPING=1")
 		go func() {
 			if err := cluster.Shutdown(ctx); err != nil {
 				ErrorOut("error shutting down cluster: %s", err)
@@ -14,9 +14,12 @@ import (
 	"github.com/ipfs/ipfs-cluster/consensus/crdt"
 	"github.com/ipfs/ipfs-cluster/consensus/raft"
 	"github.com/ipfs/ipfs-cluster/datastore/badger"
+	"github.com/ipfs/ipfs-cluster/datastore/leveldb"
+	"github.com/ipfs/ipfs-cluster/federation"
 	"github.com/ipfs/ipfs-cluster/informer/disk"
 	"github.com/ipfs/ipfs-cluster/informer/numpin"
 	"github.com/ipfs/ipfs-cluster/ipfsconn/ipfshttp"
+	"github.com/ipfs/ipfs-cluster/ipfsconn/nullconn"
 	"github.com/ipfs/ipfs-cluster/monitor/pubsubmon"
 	"github.com/ipfs/ipfs-cluster/observations"
 	"github.com/ipfs/ipfs-cluster/pintracker/stateless"
@@ -27,7 +30,9 @@ type Configs struct {
 	Cluster          *ipfscluster.Config
 	Restapi          *rest.Config
 	Ipfsproxy        *ipfsproxy.Config
+	Federation       *federation.Config
 	Ipfshttp         *ipfshttp.Config
+	Nullconn         *nullconn.Config
 	Raft             *raft.Config
 	Crdt             *crdt.Config
 	Statelesstracker *stateless.Config
@@ -37,6 +42,7 @@ type Configs struct {
 	Metrics          *observations.MetricsConfig
 	Tracing          *observations.TracingConfig
 	Badger           *badger.Config
+	LevelDB          *leveldb.Config
 }
 
 // ConfigHelper helps managing the configuration and identity files with the
@@ -49,16 +55,19 @@ type ConfigHelper struct {
 	configPath   string
 	identityPath string
 	consensus    string
+	datastore    string
 }
 
 // NewConfigHelper creates a config helper given the paths to the
-// configuration and identity files.
+// configuration and identity files. The consensus and datastore components
+// can be left empty when they are to be read from the configuration.
 // Remember to Shutdown() the ConfigHelper.Manager() after use.
-func NewConfigHelper(configPath, identityPath, consensus string) *ConfigHelper {
+func NewConfigHelper(configPath, identityPath, consensus, datastore string) *ConfigHelper {
 	ch := &ConfigHelper{
 		configPath:   configPath,
 		identityPath: identityPath,
 		consensus:    consensus,
+		datastore:    datastore,
 	}
 	ch.init()
 	return ch
@@ -68,13 +77,31 @@ func NewConfigHelper(configPath, identityPath, consensus string) *ConfigHelper {
 // configuration and identity files and loads the configurations from disk.
 // Remember to Shutdown() the ConfigHelper.Manager() after use.
 func NewLoadedConfigHelper(configPath, identityPath string) (*ConfigHelper, error) {
-	cfgHelper := NewConfigHelper(configPath, identityPath, "")
+	cfgHelper := NewConfigHelper(configPath, identityPath, "", "")
 	err := cfgHelper.LoadFromDisk()
 	return cfgHelper, err
 }
 
 // LoadConfigFromDisk parses the configuration from disk.
 func (ch *ConfigHelper) LoadConfigFromDisk() error {
+	err := ch.manager.LoadJSONFileAndEnv(ch.configPath)
+	if err != nil || ch.datastore != "" {
+		return err
+	}
+
+	// All the datastores were registered to find out which one is
+	// configured. Only that one should be, or saving the
+	// configuration would add sections for the others, so we load it
+	// again with it.
+	datastore := ch.GetDatastore()
+	if datastore == "" {
+		return errors.New("several datastore sections are configured, only one is allowed")
+	}
+	ch.manager.Shutdown()
+	ident := ch.identity
+	ch.datastore = datastore
+	ch.init()
+	ch.identity = ident
 	return ch.manager.LoadJSONFileAndEnv(ch.configPath)
 }
 
@@ -171,6 +198,31 @@ func (ch *ConfigHelper) GetConsensus() string {
 	return ch.configs.Raft.ConfigKey()
 }
 
+// GetDatastore returns the datastore backend used by the peer to persist
+// data locally (and the shared state with CRDT consensus).
+// If the ConfigHelper was initialized with a datastore string
+// then it returns that.
+//
+// Otherwise it checks which of the datastore configurations has been
+// loaded. Badger is used when none has, as configurations without a
+// datastore section predate the choice. If several have been loaded, it
+// returns an empty string.
+func (ch *ConfigHelper) GetDatastore() string {
+	if ch.datastore != "" {
+		return ch.datastore
+	}
+	badgerLoaded := ch.manager.IsLoadedFromJSON(config.Datastore, ch.configs.Badger.ConfigKey())
+	levelDBLoaded := ch.manager.IsLoadedFromJSON(config.Datastore, ch.configs.LevelDB.ConfigKey())
+	switch {
+	case badgerLoaded && levelDBLoaded:
+		return ""
+	case levelDBLoaded:
+		return ch.configs.LevelDB.ConfigKey()
+	default:
+		return ch.configs.Badger.ConfigKey()
+	}
+}
+
 // register all current cluster components
 func (ch *ConfigHelper) init() {
 	man := config.NewManager()
@@ -178,7 +230,9 @@ func (ch *ConfigHelper) init() {
 		Cluster:          &ipfscluster.Config{},
 		Restapi:          &rest.Config{},
 		Ipfsproxy:        &ipfsproxy.Config{},
+		Federation:       &federation.Config{},
 		Ipfshttp:         &ipfshttp.Config{},
+		Nullconn:         &nullconn.Config{},
 		Raft:             &raft.Config{},
 		Crdt:             &crdt.Config{},
 		Statelesstracker: &stateless.Config{},
@@ -187,27 +241,38 @@ func (ch *ConfigHelper) init() {
 		Metrics:          &observations.MetricsConfig{},
 		Tracing:          &observations.TracingConfig{},
 		Badger:           &badger.Config{},
+		LevelDB:          &leveldb.Config{},
 	}
 	man.RegisterComponent(config.Cluster, cfgs.Cluster)
 	man.RegisterComponent(config.API, cfgs.Restapi)
 	man.RegisterComponent(config.API, cfgs.Ipfsproxy)
 	man.RegisterComponent(config.IPFSConn, cfgs.Ipfshttp)
+	man.RegisterComponent(config.IPFSConn, cfgs.Nullconn)
 	man.RegisterComponent(config.PinTracker, cfgs.Statelesstracker)
 	man.RegisterComponent(config.Monitor, cfgs.Pubsubmon)
 	man.RegisterComponent(config.Informer, cfgs.Diskinf)
 	man.RegisterComponent(config.Observations, cfgs.Metrics)
 	man.RegisterComponent(config.Observations, cfgs.Tracing)
+	man.RegisterComponent(config.Federation, cfgs.Federation)
 
 	switch ch.consensus {
 	case cfgs.Raft.ConfigKey():
 		man.RegisterComponent(config.Consensus, cfgs.Raft)
 	case cfgs.Crdt.ConfigKey():
 		man.RegisterComponent(config.Consensus, cfgs.Crdt)
-		man.RegisterComponent(config.Datastore, cfgs.Badger)
 	default:
 		man.RegisterComponent(config.Consensus, cfgs.Raft)
 		man.RegisterComponent(config.Consensus, cfgs.Crdt)
+	}
+
+	switch ch.datastore {
+	case cfgs.Badger.ConfigKey():
+		man.RegisterComponent(config.Datastore, cfgs.Badger)
+	case cfgs.LevelDB.ConfigKey():
+		man.RegisterComponent(config.Datastore, cfgs.LevelDB)
+	default:
 		man.RegisterComponent(config.Datastore, cfgs.Badger)
+		man.RegisterComponent(config.Datastore, cfgs.LevelDB)
 	}
 
 	ch.identity = &config.Identity{}
@@ -0,0 +1,96 @@
+package cmdutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetDatastore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cluster-configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgPath := filepath.Join(dir, "service.json")
+	identPath := filepath.Join(dir, "identity.json")
+
+	for _, datastore := range []string{"badger", "leveldb"} {
+		t.Run(datastore, func(t *testing.T) {
+			ch := NewConfigHelper(cfgPath, identPath, "crdt", datastore)
+			if err := ch.Manager().Default(); err != nil {
+				t.Fatal(err)
+			}
+			if err := ch.SaveConfigToDisk(); err != nil {
+				t.Fatal(err)
+			}
+			ch.Manager().Shutdown()
+
+			ch = NewConfigHelper(cfgPath, identPath, "", "")
+			defer ch.Manager().Shutdown()
+			if err := ch.LoadConfigFromDisk(); err != nil {
+				t.Fatal(err)
+			}
+			if ds := ch.GetDatastore(); ds != datastore {
+				t.Fatalf("expected %s, got: %s", datastore, ds)
+			}
+
+			// Saving must not add the sections of other datastores.
+			if err := ch.SaveConfigToDisk(); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := ioutil.ReadFile(cfgPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, other := range []string{`"badger"`, `"leveldb"`} {
+				if other != `"`+datastore+`"` && strings.Contains(string(raw), other) {
+					t.Errorf("saved configuration has a %s section", other)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDatastoreMissingSection(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cluster-configs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfgPath := filepath.Join(dir, "service.json")
+	identPath := filepath.Join(dir, "identity.json")
+
+	ch := NewConfigHelper(cfgPath, identPath, "crdt", "badger")
+	if err := ch.Manager().Default(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ch.Manager().ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.Manager().Shutdown()
+
+	// Remove the datastore section, as in configurations which predate
+	// it.
+	start := strings.Index(string(raw), `"datastore"`)
+	if start < 0 {
+		t.Fatal("no datastore section")
+	}
+	end := strings.Index(string(raw[start:]), "\n  }") + start + len("\n  }")
+	old := string(raw[:start]) + `"datastore": {}` + string(raw[end:])
+	if err := ioutil.WriteFile(cfgPath, []byte(old), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ch = NewConfigHelper(cfgPath, identPath, "", "")
+	defer ch.Manager().Shutdown()
+	if err := ch.LoadConfigFromDisk(); err != nil {
+		t.Fatal(err)
+	}
+	if ds := ch.GetDatastore(); ds != "badger" {
+		t.Errorf("expected badger, got: %s", ds)
+	}
+}
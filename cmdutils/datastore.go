@@ -0,0 +1,30 @@
+package cmdutils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/datastore/badger"
+	"github.com/ipfs/ipfs-cluster/datastore/leveldb"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+// NewLocalDatastore opens the datastore where the peer persists data
+// locally, using the configured datastore backend.
+func NewLocalDatastore(cfgHelper *ConfigHelper) (ds.Datastore, error) {
+	return newDatastore(cfgHelper.GetDatastore(), cfgHelper.Configs())
+}
+
+func newDatastore(datastore string, cfgs *Configs) (ds.Datastore, error) {
+	switch datastore {
+	case cfgs.Badger.ConfigKey():
+		return badger.New(cfgs.Badger)
+	case cfgs.LevelDB.ConfigKey():
+		return leveldb.New(cfgs.LevelDB)
+	case "":
+		return nil, errors.New("could not determine the datastore component")
+	default:
+		return nil, fmt.Errorf("unknown datastore '%s'", datastore)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package cmdutils
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+)
+
+// RestartIfRequested re-executes the running binary with the same arguments
+// and environment if RequestRestart was called. It only returns on error
+// or when no restart was requested.
+func RestartIfRequested() error {
+	if atomic.LoadInt32(&restartRequested) == 0 {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,15 @@
+package cmdutils
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// RestartIfRequested is not supported on windows. It returns an error if
+// RequestRestart was called.
+func RestartIfRequested() error {
+	if atomic.LoadInt32(&restartRequested) == 0 {
+		return nil
+	}
+	return errors.New("restarting is not supported on windows")
+}
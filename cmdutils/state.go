@@ -12,7 +12,6 @@ import (
 	"github.com/ipfs/ipfs-cluster/config"
 	"github.com/ipfs/ipfs-cluster/consensus/crdt"
 	"github.com/ipfs/ipfs-cluster/consensus/raft"
-	"github.com/ipfs/ipfs-cluster/datastore/badger"
 	"github.com/ipfs/ipfs-cluster/datastore/inmem"
 	"github.com/ipfs/ipfs-cluster/pstoremgr"
 	"github.com/ipfs/ipfs-cluster/state"
@@ -24,20 +23,23 @@ import (
 // different cluster states depending on the consensus component used.
 type StateManager interface {
 	ImportState(io.Reader) error
-	ExportState(io.Writer) error
+	// ExportState writes the state as JSON. Pins are anonymized (see
+	// api.Pin.Anonymized) when anonymize is set.
+	ExportState(w io.Writer, anonymize bool) error
 	GetStore() (ds.Datastore, error)
 	GetOfflineState(ds.Datastore) (state.State, error)
 	Clean() error
 }
 
 // NewStateManager returns an state manager implementation for the given
-// consensus ("raft" or "crdt"). It will need initialized configs.
-func NewStateManager(consensus string, ident *config.Identity, cfgs *Configs) (StateManager, error) {
+// consensus ("raft" or "crdt"). With "crdt", the state is kept in the given
+// datastore ("badger" or "leveldb"). It will need initialized configs.
+func NewStateManager(consensus, datastore string, ident *config.Identity, cfgs *Configs) (StateManager, error) {
 	switch consensus {
 	case cfgs.Raft.ConfigKey():
 		return &raftStateManager{ident, cfgs}, nil
 	case cfgs.Crdt.ConfigKey():
-		return &crdtStateManager{ident, cfgs}, nil
+		return &crdtStateManager{ident, cfgs, datastore}, nil
 	case "":
 		return nil, errors.New("could not determine the consensus component")
 	default:
@@ -50,6 +52,7 @@ func NewStateManager(consensus string, ident *config.Identity, cfgs *Configs) (S
 func NewStateManagerWithHelper(cfgHelper *ConfigHelper) (StateManager, error) {
 	return NewStateManager(
 		cfgHelper.GetConsensus(),
+		cfgHelper.GetDatastore(),
 		cfgHelper.Identity(),
 		cfgHelper.Configs(),
 	)
@@ -95,7 +98,7 @@ func (raftsm *raftStateManager) ImportState(r io.Reader) error {
 	return raft.SnapshotSave(raftsm.cfgs.Raft, st, raftPeers)
 }
 
-func (raftsm *raftStateManager) ExportState(w io.Writer) error {
+func (raftsm *raftStateManager) ExportState(w io.Writer, anonymize bool) error {
 	store, err := raftsm.GetStore()
 	if err != nil {
 		return err
@@ -105,7 +108,7 @@ func (raftsm *raftStateManager) ExportState(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	return exportState(w, st)
+	return exportState(w, st, anonymize)
 }
 
 func (raftsm *raftStateManager) Clean() error {
@@ -113,16 +116,13 @@ func (raftsm *raftStateManager) Clean() error {
 }
 
 type crdtStateManager struct {
-	ident *config.Identity
-	cfgs  *Configs
+	ident     *config.Identity
+	cfgs      *Configs
+	datastore string
 }
 
 func (crdtsm *crdtStateManager) GetStore() (ds.Datastore, error) {
-	bds, err := badger.New(crdtsm.cfgs.Badger)
-	if err != nil {
-		return nil, err
-	}
-	return bds, nil
+	return newDatastore(crdtsm.datastore, crdtsm.cfgs)
 }
 
 func (crdtsm *crdtStateManager) GetOfflineState(store ds.Datastore) (state.State, error) {
@@ -154,7 +154,7 @@ func (crdtsm *crdtStateManager) ImportState(r io.Reader) error {
 	return batchingSt.Commit(context.Background())
 }
 
-func (crdtsm *crdtStateManager) ExportState(w io.Writer) error {
+func (crdtsm *crdtStateManager) ExportState(w io.Writer, anonymize bool) error {
 	store, err := crdtsm.GetStore()
 	if err != nil {
 		return err
@@ -164,7 +164,7 @@ func (crdtsm *crdtStateManager) ExportState(w io.Writer) error {
 	if err != nil {
 		return err
 	}
-	return exportState(w, st)
+	return exportState(w, st, anonymize)
 }
 
 func (crdtsm *crdtStateManager) Clean() error {
@@ -196,13 +196,16 @@ func importState(r io.Reader, st state.State) error {
 }
 
 // ExportState saves a json representation of a state
-func exportState(w io.Writer, st state.State) error {
+func exportState(w io.Writer, st state.State, anonymize bool) error {
 	pins, err := st.List(context.Background())
 	if err != nil {
 		return err
 	}
 	enc := json.NewEncoder(w)
 	for _, pin := range pins {
+		if anonymize {
+			pin = pin.Anonymized()
+		}
 		err := enc.Encode(pin)
 		if err != nil {
 			return err
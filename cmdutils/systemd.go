@@ -0,0 +1,107 @@
+package cmdutils
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+)
+
+// SdNotify sends a notification to systemd using the socket given in the
+// NOTIFY_SOCKET environment variable (see sd_notify(3)). It returns false
+// when notifications are not supported (i.e. the process is not running
+// as a systemd service with Type=notify).
+func SdNotify(state string) (bool, error) {
+	socketAddr := &net.UnixAddr{
+		Name: os.Getenv("NOTIFY_SOCKET"),
+		Net:  "unixgram",
+	}
+
+	if socketAddr.Name == "" {
+		return false, nil
+	}
+
+	conn, err := net.DialUnix(socketAddr.Net, nil, socketAddr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SdWatchdogInterval returns the interval at which systemd expects
+// watchdog notifications, as set in the WATCHDOG_USEC environment
+// variable. It returns 0 when the watchdog is not enabled for this process.
+func SdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || pid != os.Getpid() {
+			return 0
+		}
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// notifySystemd tells systemd when the cluster peer is ready and then sends
+// watchdog notifications (when enabled) until the peer shuts down. Watchdog
+// notifications are only sent while the peer is alive (see Cluster.Alive).
+func notifySystemd(ctx context.Context, cluster *ipfscluster.Cluster) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-cluster.Done():
+		return
+	case <-cluster.Ready():
+	}
+
+	ok, err := SdNotify("READY=1")
+	if err != nil {
+		ErrorOut("error notifying systemd: %s\n", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	interval := SdWatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	// Notify twice per interval as recommended in sd_watchdog_enabled(3).
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cluster.Done():
+			return
+		case <-ticker.C:
+			// A hung peer must not notify, so that systemd restarts
+			// it.
+			aliveCtx, cancel := context.WithTimeout(ctx, interval/4)
+			err := cluster.Alive(aliveCtx)
+			cancel()
+			if err != nil {
+				ErrorOut("peer not responding, skipping watchdog notification: %s\n", err)
+				continue
+			}
+			if _, err := SdNotify("WATCHDOG=1"); err != nil {
+				ErrorOut("error sending watchdog notification: %s\n", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cmdutils
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// setEnv sets an environment variable and returns a function to restore it.
+func setEnv(key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if ok {
+			os.Setenv(key, old)
+			return
+		}
+		os.Unsetenv(key)
+	}
+}
+
+func TestSdNotify(t *testing.T) {
+	defer setEnv("NOTIFY_SOCKET", "")()
+	ok, err := SdNotify("READY=1")
+	if ok || err != nil {
+		t.Fatal("notifications should not be supported without NOTIFY_SOCKET")
+	}
+
+	dir, err := ioutil.TempDir("", "systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", addr.Name)
+	ok, err = SdNotify("READY=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("notification should have been sent")
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "READY=1" {
+		t.Errorf("expected READY=1, got %q", buf[:n])
+	}
+
+	os.Setenv("NOTIFY_SOCKET", filepath.Join(dir, "missing.sock"))
+	if _, err := SdNotify("READY=1"); err == nil {
+		t.Error("expected an error with a missing socket")
+	}
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+	defer setEnv("WATCHDOG_USEC", "")()
+	defer setEnv("WATCHDOG_PID", "")()
+
+	testcases := []struct {
+		usec     string
+		pid      string
+		expected time.Duration
+	}{
+		{"", "", 0},
+		{"abc", "", 0},
+		{"-1", "", 0},
+		{"30000000", "", 30 * time.Second},
+		{"30000000", strconv.Itoa(os.Getpid()), 30 * time.Second},
+		{"30000000", strconv.Itoa(os.Getpid() + 1), 0},
+		{"30000000", "abc", 0},
+	}
+
+	for _, tc := range testcases {
+		os.Setenv("WATCHDOG_USEC", tc.usec)
+		os.Setenv("WATCHDOG_PID", tc.pid)
+		if d := SdWatchdogInterval(); d != tc.expected {
+			t.Errorf("usec %q, pid %q: expected %s, got %s", tc.usec, tc.pid, tc.expected, d)
+		}
+	}
+}
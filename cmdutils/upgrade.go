@@ -0,0 +1,245 @@
+package cmdutils
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	semver "github.com/blang/semver"
+)
+
+// DefaultDistURL is the location from which IPFS Cluster releases are
+// downloaded.
+var DefaultDistURL = "https://dist.ipfs.io"
+
+// ReleaseKey is the base64-encoded Ed25519 public key which signs the
+// IPFS Cluster releases. Every downloaded release must come with a detached
+// signature (".sig" file) made with this key. It is pinned at build time
+// with:
+//
+//	-ldflags "-X github.com/ipfs/ipfs-cluster/cmdutils.ReleaseKey=<key>"
+//
+// Upgrades are refused when no key is set.
+var ReleaseKey = ""
+
+// restartRequested is set when the running binary has been replaced and the
+// process should be re-executed after shutting down.
+var restartRequested int32
+
+// LatestVersion returns the most recent released version of the given
+// distribution (i.e. "ipfs-cluster-service"), as listed in the
+// distribution's "versions" file.
+func LatestVersion(ctx context.Context, distURL, dist string) (semver.Version, error) {
+	body, err := distGet(ctx, fmt.Sprintf("%s/%s/versions", distURL, dist))
+	if err != nil {
+		return semver.Version{}, err
+	}
+
+	var latest semver.Version
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		v, err := semver.ParseTolerant(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			continue
+		}
+		// Skip release candidates
+		if len(v.Pre) > 0 {
+			continue
+		}
+		if !found || v.GT(latest) {
+			latest = v
+			found = true
+		}
+	}
+	if !found {
+		return latest, errors.New("no released versions found")
+	}
+	return latest, nil
+}
+
+// FetchRelease downloads the release archive of the given distribution and
+// version for the current platform, verifies it against the SHA-512
+// checksum published along with it and against its detached signature,
+// made with ReleaseKey, and returns the contents of the distribution's
+// binary.
+func FetchRelease(ctx context.Context, distURL, dist string, v semver.Version) ([]byte, error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("upgrades are not supported on windows")
+	}
+
+	key, err := releaseKey()
+	if err != nil {
+		return nil, err
+	}
+
+	archive := fmt.Sprintf("%s_v%s_%s-%s.tar.gz", dist, v, runtime.GOOS, runtime.GOARCH)
+	archiveURL := fmt.Sprintf("%s/%s/v%s/%s", distURL, dist, v, archive)
+
+	data, err := distGet(ctx, archiveURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := distGet(ctx, archiveURL+".sha512")
+	if err != nil {
+		return nil, fmt.Errorf("fetching checksum: %s", err)
+	}
+	if err := verifySHA512(data, sum, archive); err != nil {
+		return nil, err
+	}
+
+	sig, err := distGet(ctx, archiveURL+".sig")
+	if err != nil {
+		return nil, fmt.Errorf("fetching signature: %s", err)
+	}
+	if err := verifySignature(data, sig, key, archive); err != nil {
+		return nil, err
+	}
+
+	return extractBinary(data, path.Join(dist, dist))
+}
+
+// verifySHA512 checks data against a checksum file, as produced by
+// sha512sum.
+func verifySHA512(data, sumFile []byte, name string) error {
+	fields := strings.Fields(string(sumFile))
+	if len(fields) == 0 {
+		return errors.New("empty checksum file")
+	}
+	if len(fields) > 1 && strings.TrimPrefix(fields[1], "*") != name {
+		return fmt.Errorf("checksum file does not correspond to %s", name)
+	}
+
+	expected, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return fmt.Errorf("bad checksum: %s", err)
+	}
+	sum := sha512.Sum512(data)
+	if !bytes.Equal(sum[:], expected) {
+		return fmt.Errorf("checksum verification failed for %s", name)
+	}
+	return nil
+}
+
+// releaseKey decodes ReleaseKey.
+func releaseKey() (ed25519.PublicKey, error) {
+	if ReleaseKey == "" {
+		return nil, errors.New("this binary has no release signing key: upgrades are disabled")
+	}
+	key, err := base64.StdEncoding.DecodeString(ReleaseKey)
+	if err != nil {
+		return nil, fmt.Errorf("bad release signing key: %s", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("bad release signing key: wrong size")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// verifySignature checks data against a base64-encoded detached Ed25519
+// signature.
+func verifySignature(data, sigFile []byte, key ed25519.PublicKey, name string) error {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigFile)))
+	if err != nil {
+		return fmt.Errorf("bad signature: %s", err)
+	}
+	if !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("signature verification failed for %s", name)
+	}
+	return nil
+}
+
+// extractBinary returns the contents of the file with the given name in
+// a .tar.gz archive.
+func extractBinary(archive []byte, name string) ([]byte, error) {
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s not found in release archive", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path.Clean(hdr.Name) == name {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+func distGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// ReplaceExecutable replaces the binary of the running process with the
+// given one. The new binary is written next to the current one and renamed
+// over it, so that the replacement is atomic.
+func ReplaceExecutable(bin []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+
+	tmp := exe + ".new"
+	err = ioutil.WriteFile(tmp, bin, fi.Mode())
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, exe)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// RequestRestart marks the process to be restarted with RestartIfRequested
+// once the cluster peer has shut down.
+func RequestRestart() {
+	atomic.StoreInt32(&restartRequested, 1)
+}
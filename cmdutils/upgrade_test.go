@@ -0,0 +1,150 @@
+package cmdutils
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func makeArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0755,
+			Size: int64(len(content)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tw.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestVerifySHA512(t *testing.T) {
+	data := []byte("release")
+	sum := sha512.Sum512(data)
+	hexSum := hex.EncodeToString(sum[:])
+
+	ok := []string{
+		hexSum,
+		hexSum + "  archive.tar.gz\n",
+		hexSum + " *archive.tar.gz",
+	}
+	for _, f := range ok {
+		if err := verifySHA512(data, []byte(f), "archive.tar.gz"); err != nil {
+			t.Errorf("%q: %s", f, err)
+		}
+	}
+
+	bad := []string{
+		"",
+		"nothex",
+		hexSum + "  other.tar.gz",
+		hex.EncodeToString(make([]byte, sha512.Size)),
+	}
+	for _, f := range bad {
+		if err := verifySHA512(data, []byte(f), "archive.tar.gz"); err == nil {
+			t.Errorf("%q: expected an error", f)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("release")
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data))
+
+	if err := verifySignature(data, []byte(sig+"\n"), pub, "archive"); err != nil {
+		t.Error(err)
+	}
+	if err := verifySignature([]byte("tampered"), []byte(sig), pub, "archive"); err == nil {
+		t.Error("expected an error with tampered data")
+	}
+	if err := verifySignature(data, []byte(sig), otherPub, "archive"); err == nil {
+		t.Error("expected an error with the wrong key")
+	}
+	if err := verifySignature(data, []byte("not base64!"), pub, "archive"); err == nil {
+		t.Error("expected an error with a malformed signature")
+	}
+}
+
+func TestReleaseKey(t *testing.T) {
+	defer func(k string) { ReleaseKey = k }(ReleaseKey)
+
+	ReleaseKey = ""
+	if _, err := releaseKey(); err == nil {
+		t.Error("expected an error without a release key")
+	}
+
+	ReleaseKey = base64.StdEncoding.EncodeToString([]byte("short"))
+	if _, err := releaseKey(); err == nil {
+		t.Error("expected an error with a bad release key")
+	}
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ReleaseKey = base64.StdEncoding.EncodeToString(pub)
+	key, err := releaseKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, pub) {
+		t.Error("decoded the wrong key")
+	}
+}
+
+func TestExtractBinary(t *testing.T) {
+	dist := "ipfs-cluster-service"
+	archive := makeArchive(t, map[string]string{
+		"ipfs-cluster-service/README.md":              "readme",
+		"./ipfs-cluster-service/ipfs-cluster-service": "binary",
+	})
+
+	bin, err := extractBinary(archive, fmt.Sprintf("%s/%s", dist, dist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bin) != "binary" {
+		t.Errorf("extracted the wrong file: %q", bin)
+	}
+
+	_, err = extractBinary(archive, "ipfs-cluster-service/missing")
+	if err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	_, err = extractBinary([]byte("not an archive"), "ipfs-cluster-service/ipfs-cluster-service")
+	if err == nil {
+		t.Error("expected an error for a bad archive")
+	}
+}
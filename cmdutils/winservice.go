@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package cmdutils
+
+import (
+	"context"
+	"os"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+)
+
+// runWindowsService does nothing outside Windows.
+func runWindowsService(ctx context.Context, cluster *ipfscluster.Cluster, signalChan chan<- os.Signal) {}
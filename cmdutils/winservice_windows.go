@@ -0,0 +1,60 @@
+package cmdutils
+
+import (
+	"context"
+	"os"
+
+	ipfscluster "github.com/ipfs/ipfs-cluster"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// runWindowsService reports the status of the peer to the Windows service
+// manager when running as a service, and turns stop and shutdown requests
+// into signals for HandleSignals. It returns once the context is cancelled.
+func runWindowsService(ctx context.Context, cluster *ipfscluster.Cluster, signalChan chan<- os.Signal) {
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil || interactive {
+		return
+	}
+
+	err = svc.Run("", &windowsService{
+		ctx:        ctx,
+		cluster:    cluster,
+		signalChan: signalChan,
+	})
+	if err != nil {
+		ErrorOut("error running as a windows service: %s\n", err)
+	}
+}
+
+type windowsService struct {
+	ctx        context.Context
+	cluster    *ipfscluster.Cluster
+	signalChan chan<- os.Signal
+}
+
+// Execute implements svc.Handler.
+func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+
+	s <- svc.Status{State: svc.StartPending}
+	ready := ws.cluster.Ready()
+	for {
+		select {
+		case <-ready:
+			ready = nil
+			s <- svc.Status{State: svc.Running, Accepts: accepted}
+		case req := <-r:
+			switch req.Cmd {
+			case svc.Interrogate:
+				s <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending}
+				ws.signalChan <- os.Interrupt
+			}
+		case <-ws.ctx.Done():
+			return false, 0
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+
+	cid "github.com/ipfs/go-cid"
+	"go.opencensus.io/trace"
+)
+
+var errCollectionNotFound = errors.New("collection not found")
+
+// PinCollection pins all the CIDs of a collection with the given options.
+// Either all of them are pinned or, when one fails, those pinned by this call
+// are unpinned again. CIDs which are already in the shared pinset as part of
+// a different collection, or of no collection, are rejected.
+func (c *Cluster) PinCollection(ctx context.Context, col *api.Collection) (*api.Collection, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinCollection")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if col.Name == "" {
+		return nil, errors.New("collection name is empty")
+	}
+	if len(col.Cids) == 0 {
+		return nil, errors.New("collection has no cids")
+	}
+
+	var opts api.PinOptions
+	if col.Options != nil {
+		opts = *col.Options
+	}
+	if opts.LocalPin {
+		return nil, errors.New("collections cannot be pinned locally")
+	}
+	if opts.PinUpdate != cid.Undef {
+		return nil, errors.New("collections cannot be pin updates")
+	}
+	opts.Collection = col.Name
+
+	newPins := make(map[cid.Cid]bool, len(col.Cids))
+	for _, h := range col.Cids {
+		existing, err := c.PinGet(ctx, h)
+		switch {
+		case err == state.ErrNotFound:
+			newPins[h] = true
+		case err != nil:
+			return nil, err
+		case existing.Collection != col.Name:
+			return nil, fmt.Errorf("%s is already pinned outside collection %s", h, col.Name)
+		}
+	}
+
+	var pinned []cid.Cid
+	for _, h := range col.Cids {
+		if _, err := c.Pin(ctx, h, opts); err != nil {
+			for _, p := range pinned {
+				if !newPins[p] {
+					continue
+				}
+				if _, rerr := c.unpin(ctx, p, true); rerr != nil {
+					logger.Errorf("collection %s: error rolling back pin of %s: %s", col.Name, p, rerr)
+				}
+			}
+			return nil, fmt.Errorf("error pinning %s: %s", h, err)
+		}
+		pinned = append(pinned, h)
+	}
+
+	logger.Infof("pinned collection %s (%d cids)", col.Name, len(pinned))
+	return &api.Collection{
+		Name:    col.Name,
+		Cids:    pinned,
+		Options: &opts,
+	}, nil
+}
+
+// UnpinCollection unpins all the CIDs of a collection.
+func (c *Cluster) UnpinCollection(ctx context.Context, name string) (*api.Collection, error) {
+	_, span := trace.StartSpan(ctx, "cluster/UnpinCollection")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	col, err := c.Collection(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range col.Cids {
+		if _, err := c.Unpin(ctx, h); err != nil && err != state.ErrNotFound {
+			return nil, fmt.Errorf("error unpinning %s: %s", h, err)
+		}
+	}
+
+	logger.Infof("unpinned collection %s (%d cids)", name, len(col.Cids))
+	return col, nil
+}
+
+// Collection returns the CIDs in the shared pinset which belong to the given
+// collection.
+func (c *Cluster) Collection(ctx context.Context, name string) (*api.Collection, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Collection")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	pins, err := c.PinsQuery(ctx, &api.PinQuery{Type: api.AllType, Collection: name})
+	if err != nil {
+		return nil, err
+	}
+	if len(pins) == 0 {
+		return nil, errCollectionNotFound
+	}
+
+	col := &api.Collection{Name: name}
+	for _, pin := range pins {
+		col.Cids = append(col.Cids, pin.Cid)
+	}
+	return col, nil
+}
+
+// Collections returns all the collections in the shared pinset, sorted by
+// name.
+func (c *Cluster) Collections(ctx context.Context) ([]*api.Collection, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Collections")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	pins, err := c.PinsQuery(ctx, &api.PinQuery{Type: api.AllType})
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*api.Collection)
+	for _, pin := range pins {
+		if pin.Collection == "" {
+			continue
+		}
+		col, ok := byName[pin.Collection]
+		if !ok {
+			col = &api.Collection{Name: pin.Collection}
+			byName[pin.Collection] = col
+		}
+		col.Cids = append(col.Cids, pin.Cid)
+	}
+
+	out := make([]*api.Collection, 0, len(byName))
+	for _, col := range byName {
+		out = append(out, col)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// CollectionStatus returns the GlobalPinInfo of every CID in a collection.
+func (c *Cluster) CollectionStatus(ctx context.Context, name string) ([]*api.GlobalPinInfo, error) {
+	_, span := trace.StartSpan(ctx, "cluster/CollectionStatus")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	col, err := c.Collection(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	gpis := make([]*api.GlobalPinInfo, 0, len(col.Cids))
+	for _, h := range col.Cids {
+		gpi, err := c.Status(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		gpis = append(gpis, gpi)
+	}
+	return gpis, nil
+}
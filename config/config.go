@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"path/filepath"
+	"reflect"
 	"sync"
 	"time"
 
@@ -74,6 +75,7 @@ const (
 	Informer
 	Observations
 	Datastore
+	Federation
 	endTypes // keep this at the end
 )
 
@@ -131,6 +133,11 @@ type Manager struct {
 	// so it can be saved to the same place.
 	path    string
 	saveMux sync.Mutex
+
+	// component configurations saved with SaveComponentJSON, which
+	// are written instead of the current ones.
+	pendingMux sync.Mutex
+	pending    map[string][]byte
 }
 
 // NewManager returns a correctly initialized Manager
@@ -205,6 +212,7 @@ type jsonConfig struct {
 	Informer     jsonSection      `json:"informer,omitempty"`
 	Observations jsonSection      `json:"observations,omitempty"`
 	Datastore    jsonSection      `json:"datastore,omitempty"`
+	Federation   jsonSection      `json:"federation,omitempty"`
 }
 
 func (jcfg *jsonConfig) getSection(i SectionType) *jsonSection {
@@ -229,6 +237,8 @@ func (jcfg *jsonConfig) getSection(i SectionType) *jsonSection {
 		return &jcfg.Observations
 	case Datastore:
 		return &jcfg.Datastore
+	case Federation:
+		return &jcfg.Federation
 	default:
 		return nil
 	}
@@ -412,6 +422,9 @@ func (cfg *Manager) LoadJSON(bs []byte) error {
 	}
 
 	cfg.jsonCfg = jcfg
+	cfg.pendingMux.Lock()
+	cfg.pending = nil
+	cfg.pendingMux.Unlock()
 	// Handle remote source
 	if jcfg.Source != "" {
 		return cfg.LoadJSONFromHTTPSource(jcfg.Source)
@@ -523,13 +536,19 @@ func (cfg *Manager) ToJSON() ([]byte, error) {
 
 	// Given a Section and a *jsonSection, it updates the
 	// component-configurations in the latter.
+	cfg.pendingMux.Lock()
+	defer cfg.pendingMux.Unlock()
+
 	updateJSONConfigs := func(section Section, dest *jsonSection) error {
 		for k, v := range section {
 			v.SetBaseDir(dir)
 			logger.Debugf("writing changes for %s section", k)
-			j, err := v.ToJSON()
-			if err != nil {
-				return err
+			j, ok := cfg.pending[k]
+			if !ok {
+				j, err = v.ToJSON()
+				if err != nil {
+					return err
+				}
 			}
 			if *dest == nil {
 				*dest = make(jsonSection)
@@ -555,6 +574,63 @@ func (cfg *Manager) ToJSON() ([]byte, error) {
 	return DefaultJSONMarshal(jcfg)
 }
 
+// SaveComponentJSON saves the given JSON configuration for the registered
+// component with the given key to the configuration file, without applying
+// it to the component, which keeps its current values until the
+// configuration is loaded again (usually on restart). It is checked with a
+// new configuration object of the same type first.
+func (cfg *Manager) SaveComponentJSON(name string, raw []byte) error {
+	if cfg.Source != "" {
+		return errors.New("the configuration is loaded from a remote source")
+	}
+
+	var comp ComponentConfig
+	for _, section := range cfg.sections {
+		if c, ok := section[name]; ok {
+			comp = c
+		}
+	}
+	if comp == nil {
+		return fmt.Errorf("%s is not a registered component", name)
+	}
+
+	check, err := CheckJSON(comp, raw)
+	if err != nil {
+		return err
+	}
+	// Save it as the component would.
+	raw, err = check.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	cfg.pendingMux.Lock()
+	if cfg.pending == nil {
+		cfg.pending = make(map[string][]byte)
+	}
+	cfg.pending[name] = raw
+	cfg.pendingMux.Unlock()
+
+	return cfg.SaveJSON("")
+}
+
+// CheckJSON loads the given JSON in a new configuration object of the same
+// type as the given one, which is left untouched, and validates it. It
+// returns the new configuration object.
+func CheckJSON(ccfg ComponentConfig, raw []byte) (ComponentConfig, error) {
+	check, ok := reflect.New(reflect.TypeOf(ccfg).Elem()).Interface().(ComponentConfig)
+	if !ok {
+		return nil, fmt.Errorf("cannot check the %s configuration", ccfg.ConfigKey())
+	}
+	if err := check.LoadJSON(raw); err != nil {
+		return nil, err
+	}
+	if err := check.Validate(); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
 // IsLoadedFromJSON tells whether the given component belonging to
 // the given section type is present in the cluster JSON
 // config or not.
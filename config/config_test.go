@@ -2,9 +2,14 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -61,6 +66,11 @@ var mockJSON = []byte(`{
     "mock": {
       "a": "b"
     }
+  },
+  "federation": {
+    "mock": {
+      "a": "b"
+    }
   }
 }`)
 
@@ -176,3 +186,88 @@ func TestSaveWithSource(t *testing.T) {
 		t.Error("should have generated a source-only json")
 	}
 }
+
+type valueCfg struct {
+	Saver
+	Value string `json:"value"`
+}
+
+func (v *valueCfg) ConfigKey() string {
+	return "value"
+}
+
+func (v *valueCfg) LoadJSON(raw []byte) error {
+	return json.Unmarshal(raw, v)
+}
+
+func (v *valueCfg) ToJSON() ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (v *valueCfg) Default() error {
+	v.Value = "default"
+	return nil
+}
+
+func (v *valueCfg) ApplyEnvVars() error {
+	return nil
+}
+
+func (v *valueCfg) Validate() error {
+	if v.Value == "" {
+		return errors.New("empty value")
+	}
+	return nil
+}
+
+func TestSaveComponentJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "service.json")
+
+	cfgMgr := setupConfigManager()
+	vcfg := &valueCfg{}
+	cfgMgr.RegisterComponent(Informer, vcfg)
+	cfgMgr.Default()
+	err = cfgMgr.SaveJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cfgMgr.SaveComponentJSON("value", []byte(`{"value": ""}`))
+	if err == nil {
+		t.Error("expected an error saving an invalid configuration")
+	}
+	err = cfgMgr.SaveComponentJSON("other", []byte(`{"value": "a"}`))
+	if err == nil {
+		t.Error("expected an error saving an unregistered component")
+	}
+
+	err = cfgMgr.SaveComponentJSON("value", []byte(`{"value": "pushed"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vcfg.Value != "default" {
+		t.Error("the saved configuration should not be applied")
+	}
+
+	// Later saves keep the saved configuration.
+	err = cfgMgr.SaveJSON("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfgMgr2 := setupConfigManager()
+	vcfg2 := &valueCfg{}
+	cfgMgr2.RegisterComponent(Informer, vcfg2)
+	err = cfgMgr2.LoadJSONFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vcfg2.Value != "pushed" {
+		t.Errorf("expected the saved configuration to be loaded, got %q", vcfg2.Value)
+	}
+}
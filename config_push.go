@@ -0,0 +1,296 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+var errNoConfigSections = errors.New("no configuration sections given")
+
+// pushableFields lists, by configuration section, the only options which
+// can be pushed. They are plain values like intervals and concurrency, which
+// the running components can apply (see Reconfigurable). Options which could
+// make peers run commands or contact other hosts (such as the tracker hooks)
+// are never pushable.
+var pushableFields = map[string][]string{
+	"disk":      {"metric_ttl"},
+	"numpin":    {"metric_ttl"},
+	"stateless": {"concurrent_pins"},
+}
+
+// ConfigSaver saves component configurations to the configuration file
+// without applying them. It is implemented by config.Manager.
+type ConfigSaver interface {
+	SaveComponentJSON(name string, raw []byte) error
+}
+
+// SetSharedConfigs sets the component configurations which can be updated
+// with PushConfig, and the saver used to store the pushed configurations.
+// They are identified by their ConfigKey, and only those with pushable
+// options are accepted. Pushing configurations is disabled until this is
+// set.
+func (c *Cluster) SetSharedConfigs(saver ConfigSaver, cfgs ...config.ComponentConfig) {
+	c.sharedConfigMux.Lock()
+	defer c.sharedConfigMux.Unlock()
+	c.configSaver = saver
+	c.sharedConfigs = make(map[string]config.ComponentConfig, len(cfgs))
+	c.pushedConfigs = make(map[string]map[string]json.RawMessage)
+	for _, cfg := range cfgs {
+		if _, ok := pushableFields[cfg.ConfigKey()]; !ok {
+			logger.Warningf("the %s configuration cannot be pushed", cfg.ConfigKey())
+			continue
+		}
+		c.sharedConfigs[cfg.ConfigKey()] = cfg
+	}
+}
+
+// PushConfig sends the given configuration sections to all the peers in the
+// cluster, which apply them and save them to their configuration files.
+// Only the options in pushableFields can be pushed, and options which are
+// not given keep their current values. Sections without a configuration
+// take the current pushable options of this peer. It returns an error
+// listing the peers where it failed.
+//
+// Peers only accept configurations pushed by trusted peers.
+func (c *Cluster) PushConfig(ctx context.Context, sections []*api.ConfigSection) error {
+	_, span := trace.StartSpan(ctx, "cluster/PushConfig")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if len(sections) == 0 {
+		return errNoConfigSections
+	}
+
+	pushed := make([]*api.ConfigSection, 0, len(sections))
+	for _, s := range sections {
+		if _, err := c.sharedConfig(s.Name); err != nil {
+			return err
+		}
+		raw := s.Config
+		if len(raw) == 0 {
+			var err error
+			raw, err = c.currentPushableJSON(s.Name)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := pushableOptions(s.Name, raw); err != nil {
+			return err
+		}
+		pushed = append(pushed, &api.ConfigSection{Name: s.Name, Config: raw})
+	}
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := c.multiCall(
+		ctx,
+		peers,
+		"Cluster",
+		"PushConfigLocal",
+		pushed,
+		rpcutil.RPCDiscardReplies(len(peers)),
+	)
+
+	for i, err := range errs {
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %s", peer.IDB58Encode(peers[i]), err)
+		}
+	}
+	return rpcutil.CheckErrs(errs)
+}
+
+// PushConfigLocal saves the given configuration sections to the
+// configuration file of this peer and applies them to the running
+// components. Sections with options which cannot be pushed are rejected,
+// and sections are checked before being saved, so that none is saved when
+// one is invalid.
+func (c *Cluster) PushConfigLocal(ctx context.Context, sections []*api.ConfigSection) error {
+	_, span := trace.StartSpan(ctx, "cluster/PushConfigLocal")
+	defer span.End()
+
+	if len(sections) == 0 {
+		return errNoConfigSections
+	}
+
+	options := make([]map[string]json.RawMessage, len(sections))
+	for i, s := range sections {
+		if _, err := c.sharedConfig(s.Name); err != nil {
+			return err
+		}
+		opts, err := pushableOptions(s.Name, s.Config)
+		if err != nil {
+			return err
+		}
+		options[i] = opts
+	}
+
+	c.sharedConfigMux.Lock()
+	defer c.sharedConfigMux.Unlock()
+
+	// Merge the pushed options with the previously pushed ones, or
+	// the current ones, and check the results before saving any.
+	merged := make(map[string]map[string]json.RawMessage, len(sections))
+	for i, s := range sections {
+		current, ok := merged[s.Name]
+		if !ok {
+			var err error
+			current, err = c.pushedConfig(s.Name)
+			if err != nil {
+				return err
+			}
+		}
+		for k, v := range options[i] {
+			current[k] = v
+		}
+		merged[s.Name] = current
+	}
+
+	raws := make(map[string][]byte, len(merged))
+	checked := make(map[string]config.ComponentConfig, len(merged))
+	for name, opts := range merged {
+		raw, err := json.Marshal(opts)
+		if err != nil {
+			return err
+		}
+		cfg, err := config.CheckJSON(c.sharedConfigs[name], raw)
+		if err != nil {
+			return fmt.Errorf("%s configuration: %s", name, err)
+		}
+		raws[name] = raw
+		checked[name] = cfg
+	}
+
+	for name, raw := range raws {
+		if err := c.configSaver.SaveComponentJSON(name, raw); err != nil {
+			return fmt.Errorf("saving the %s configuration: %s", name, err)
+		}
+		c.pushedConfigs[name] = merged[name]
+		if err := c.reconfigure(checked[name]); err != nil {
+			return fmt.Errorf("applying the %s configuration: %s", name, err)
+		}
+		logger.Infof("applied and saved pushed %s configuration", name)
+	}
+	return nil
+}
+
+// reconfigure applies a pushed configuration to the running components
+// which use it.
+func (c *Cluster) reconfigure(cfg config.ComponentConfig) error {
+	components := make([]interface{}, 0, len(c.informers)+1)
+	for _, inf := range c.informers {
+		components = append(components, inf)
+	}
+	components = append(components, c.tracker)
+
+	for _, comp := range components {
+		r, ok := comp.(Reconfigurable)
+		if !ok || r.ConfigKey() != cfg.ConfigKey() {
+			continue
+		}
+		if err := r.Reconfigure(cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// currentPushableJSON returns the pushable options of the given section
+// which this peer currently uses.
+func (c *Cluster) currentPushableJSON(name string) ([]byte, error) {
+	c.sharedConfigMux.Lock()
+	opts, err := c.pushedConfig(name)
+	c.sharedConfigMux.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	return pushableJSON(name, raw)
+}
+
+// pushedConfig returns the options of the given section as last saved by
+// PushConfigLocal, or as currently used. The sharedConfigMux must be held.
+func (c *Cluster) pushedConfig(name string) (map[string]json.RawMessage, error) {
+	opts := make(map[string]json.RawMessage)
+	if pushed, ok := c.pushedConfigs[name]; ok {
+		for k, v := range pushed {
+			opts[k] = v
+		}
+		return opts, nil
+	}
+	raw, err := c.sharedConfigs[name].ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// pushableOptions parses a pushed configuration section and returns its
+// options. It fails when any of them cannot be pushed.
+func pushableOptions(name string, raw []byte) (map[string]json.RawMessage, error) {
+	var opts map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return nil, fmt.Errorf("%s configuration: %s", name, err)
+	}
+	for k := range opts {
+		if !pushable(name, k) {
+			return nil, fmt.Errorf("%s.%s cannot be pushed", name, k)
+		}
+	}
+	return opts, nil
+}
+
+// pushableJSON keeps only the pushable options of a configuration section.
+func pushableJSON(name string, raw []byte) ([]byte, error) {
+	var opts map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return nil, err
+	}
+	for k := range opts {
+		if !pushable(name, k) {
+			delete(opts, k)
+		}
+	}
+	return json.Marshal(opts)
+}
+
+func pushable(name, option string) bool {
+	for _, f := range pushableFields[name] {
+		if f == option {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedConfig returns the configuration which can be pushed with the given
+// key.
+func (c *Cluster) sharedConfig(name string) (config.ComponentConfig, error) {
+	c.sharedConfigMux.Lock()
+	defer c.sharedConfigMux.Unlock()
+	if c.sharedConfigs == nil {
+		return nil, errors.New("pushing configurations is not enabled in this peer")
+	}
+	cfg, ok := c.sharedConfigs[name]
+	if !ok {
+		return nil, fmt.Errorf("the %s configuration cannot be pushed", name)
+	}
+	return cfg, nil
+}
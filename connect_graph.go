@@ -35,11 +35,8 @@ func (c *Cluster) ConnectGraph() (api.ConnectGraph, error) {
 
 	peers := make([][]*api.ID, len(members), len(members))
 
-	ctxs, cancels := rpcutil.CtxsWithCancel(ctx, len(members))
-	defer rpcutil.MultiCancel(cancels)
-
-	errs := c.rpcClient.MultiCall(
-		ctxs,
+	errs := c.multiCall(
+		ctx,
 		members,
 		"Cluster",
 		"Peers",
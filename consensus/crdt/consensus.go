@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/faults"
 	"github.com/ipfs/ipfs-cluster/pstoremgr"
 	"github.com/ipfs/ipfs-cluster/state"
 	"github.com/ipfs/ipfs-cluster/state/dsstate"
@@ -39,7 +40,7 @@ var (
 
 // Common variables for the module.
 var (
-	ErrNoLeader = errors.New("crdt consensus component does not provide a leader")
+	ErrNoLeader = api.NewCodedError(api.ErrCodeNotLeader, "crdt consensus component does not provide a leader")
 	ErrRmPeer   = errors.New("crdt consensus component cannot remove peers")
 )
 
@@ -363,6 +364,9 @@ func (css *Consensus) LogPin(ctx context.Context, pin *api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "consensus/LogPin")
 	defer span.End()
 
+	if err := faults.DelayCommit(ctx); err != nil {
+		return err
+	}
 	return css.state.Add(ctx, pin)
 }
 
@@ -371,6 +375,9 @@ func (css *Consensus) LogUnpin(ctx context.Context, pin *api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "consensus/LogUnpin")
 	defer span.End()
 
+	if err := faults.DelayCommit(ctx); err != nil {
+		return err
+	}
 	return css.state.Rm(ctx, pin.Cid)
 }
 
@@ -0,0 +1,170 @@
+package raft
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "github.com/boltdb/bolt"
+)
+
+// logStoreFile is the name of the BoltDB file, in the Raft data folder,
+// which holds the Raft log.
+const logStoreFile = "raft.db"
+
+// dbOpenTimeout limits how long we wait for the lock of the log store,
+// which is held while the peer runs.
+const dbOpenTimeout = time.Second
+
+var errLogStoreInUse = errors.New("the raft log store is in use by a running peer")
+
+// DataUsage reports the disk space, in bytes, used by the Raft data of a
+// peer.
+type DataUsage struct {
+	DataFolder    string
+	LogStore      int64
+	Snapshots     int
+	SnapshotsSize int64
+	Backups       int
+	BackupsSize   int64
+}
+
+// Usage returns the disk space used by the Raft log store, snapshots and
+// the backups of the data folder made by CleanupRaft.
+func Usage(cfg *Config) (*DataUsage, error) {
+	dataFolder := cfg.GetDataFolder()
+	usage := &DataUsage{DataFolder: dataFolder}
+
+	st, err := os.Stat(filepath.Join(dataFolder, logStoreFile))
+	switch {
+	case err == nil:
+		usage.LogStore = st.Size()
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	snaps, _ := filepath.Glob(filepath.Join(dataFolder, "snapshots", "*"))
+	for _, s := range snaps {
+		size, err := folderSize(s)
+		if err != nil {
+			return nil, err
+		}
+		usage.Snapshots++
+		usage.SnapshotsSize += size
+	}
+
+	dbh := newDataBackupHelper(dataFolder, cfg.BackupsRotate)
+	for _, b := range dbh.listBackups() {
+		size, err := folderSize(b)
+		if err != nil {
+			return nil, err
+		}
+		usage.Backups++
+		usage.BackupsSize += size
+	}
+	return usage, nil
+}
+
+func folderSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// CompactLog rewrites the Raft log store so that it does not use the space
+// freed when the log is truncated after taking snapshots, which BoltDB
+// never gives back. It returns the size of the log store before and after,
+// which are 0 when there is no log store. Raft must not be running.
+func CompactLog(cfg *Config) (before, after int64, err error) {
+	path := filepath.Join(cfg.GetDataFolder(), logStoreFile)
+	st, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	before = st.Size()
+
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+	if err := copyBolt(path, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, err
+	}
+
+	st, err = os.Stat(tmpPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	after = st.Size()
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, 0, err
+	}
+	return before, after, nil
+}
+
+// copyBolt copies all the buckets of the BoltDB file at src into a new one
+// at dst.
+func copyBolt(src, dst string) error {
+	srcDB, err := bolt.Open(src, 0600, &bolt.Options{ReadOnly: true, Timeout: dbOpenTimeout})
+	if err == bolt.ErrTimeout {
+		return errLogStoreInUse
+	}
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+
+	dstDB, err := bolt.Open(dst, 0600, &bolt.Options{Timeout: dbOpenTimeout})
+	if err != nil {
+		return err
+	}
+	defer dstDB.Close()
+
+	err = srcDB.View(func(stx *bolt.Tx) error {
+		return dstDB.Update(func(dtx *bolt.Tx) error {
+			return stx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				nb, err := dtx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(nb, b)
+			})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return dstDB.Close()
+}
+
+func copyBucket(dst, src *bolt.Bucket) error {
+	// Keys are written in order, so pages can be filled completely.
+	dst.FillPercent = 1.0
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		sub := src.Bucket(k)
+		if sub == nil {
+			return errors.New("unexpected nil value in bucket")
+		}
+		nb, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(nb, sub)
+	})
+}
@@ -3,6 +3,7 @@ package raft
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"time"
@@ -65,6 +66,14 @@ type Config struct {
 	// Namespace to use when writing keys to the datastore
 	DatastoreNamespace string
 
+	// SnapshotTrustedPeers lists the peers which may download state
+	// snapshots (see FetchSnapshot) besides the members of the Raft
+	// peerset, i.e. peers about to join the cluster.
+	SnapshotTrustedPeers []peer.ID
+	// SnapshotTrustAll lets any peer download state snapshots. It is set
+	// with a "*" in snapshot_trusted_peers.
+	SnapshotTrustAll bool
+
 	// A Hashicorp Raft's configuration object.
 	RaftConfig *hraft.Config
 
@@ -106,6 +115,10 @@ type jsonConfig struct {
 
 	DatastoreNamespace string `json:"datastore_namespace,omitempty"`
 
+	// SnapshotTrustedPeers lists the peers, other than the Raft peers,
+	// which may download state snapshots. "*" trusts any peer.
+	SnapshotTrustedPeers []string `json:"snapshot_trusted_peers,omitempty"`
+
 	// HeartbeatTimeout specifies the time in follower state without
 	// a leader before we attempt an election.
 	HeartbeatTimeout string `json:"heartbeat_timeout,omitempty"`
@@ -236,6 +249,21 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	config.SetIfNotDefault(leaderLeaseTimeout, &cfg.RaftConfig.LeaderLeaseTimeout)
 
 	cfg.InitPeerset = api.StringsToPeers(jcfg.InitPeerset)
+
+	cfg.SnapshotTrustAll = false
+	cfg.SnapshotTrustedPeers = []peer.ID{}
+	for _, p := range jcfg.SnapshotTrustedPeers {
+		if p == "*" {
+			cfg.SnapshotTrustAll = true
+			cfg.SnapshotTrustedPeers = []peer.ID{}
+			break
+		}
+		pid, err := peer.IDB58Decode(p)
+		if err != nil {
+			return fmt.Errorf("error parsing snapshot_trusted_peers: %s", err)
+		}
+		cfg.SnapshotTrustedPeers = append(cfg.SnapshotTrustedPeers, pid)
+	}
 	return cfg.Validate()
 }
 
@@ -268,6 +296,11 @@ func (cfg *Config) toJSONConfig() *jsonConfig {
 		jcfg.DatastoreNamespace = cfg.DatastoreNamespace
 		// otherwise leave empty so it gets ommitted.
 	}
+	if cfg.SnapshotTrustAll {
+		jcfg.SnapshotTrustedPeers = []string{"*"}
+	} else {
+		jcfg.SnapshotTrustedPeers = api.PeersToStrings(cfg.SnapshotTrustedPeers)
+	}
 	return jcfg
 }
 
@@ -281,6 +314,8 @@ func (cfg *Config) Default() error {
 	cfg.CommitRetryDelay = DefaultCommitRetryDelay
 	cfg.BackupsRotate = DefaultBackupsRotate
 	cfg.DatastoreNamespace = DefaultDatastoreNamespace
+	cfg.SnapshotTrustedPeers = []peer.ID{}
+	cfg.SnapshotTrustAll = false
 	cfg.RaftConfig = hraft.DefaultConfig()
 
 	// These options are imposed over any Default Raft Config.
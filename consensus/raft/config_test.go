@@ -108,6 +108,38 @@ func TestDefault(t *testing.T) {
 	}
 }
 
+func TestSnapshotTrustedPeers(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON([]byte(`{"snapshot_trusted_peers": ["QmXZrtE5jQwXNqCJMfHUTQkvhQ4ZAnqMnmzFMJfLewuabc"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SnapshotTrustAll || len(cfg.SnapshotTrustedPeers) != 1 {
+		t.Error("expected one trusted peer")
+	}
+
+	err = cfg.LoadJSON([]byte(`{"snapshot_trusted_peers": ["*"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.SnapshotTrustAll {
+		t.Error("expected all peers to be trusted")
+	}
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	if err := cfg.LoadJSON(newjson); err != nil || !cfg.SnapshotTrustAll {
+		t.Error("trusting all peers should survive a round trip")
+	}
+
+	err = cfg.LoadJSON([]byte(`{"snapshot_trusted_peers": ["abc"]}`))
+	if err == nil {
+		t.Error("expected an error parsing the trusted peers")
+	}
+}
+
 func TestApplyEnvVars(t *testing.T) {
 	os.Setenv("CLUSTER_RAFT_COMMITRETRIES", "300")
 	cfg := &Config{}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/faults"
 	"github.com/ipfs/ipfs-cluster/state"
 	"github.com/ipfs/ipfs-cluster/state/dsstate"
 
@@ -109,6 +110,8 @@ func NewConsensus(
 
 	baseOp.consensus = cc
 
+	host.SetStreamHandler(SnapshotProtocol, cc.serveSnapshot)
+
 	go cc.finishBootstrap()
 	return cc, nil
 }
@@ -198,6 +201,8 @@ func (cc *Consensus) Shutdown(ctx context.Context) error {
 
 	logger.Info("stopping Consensus component")
 
+	cc.host.RemoveStreamHandler(SnapshotProtocol)
+
 	// Raft Shutdown
 	err := cc.raft.Shutdown(ctx)
 	if err != nil {
@@ -274,7 +279,7 @@ func (cc *Consensus) redirectToLeader(method string, arg interface{}) (bool, err
 			// means we timed out waiting for a leader
 			// we don't retry in this case
 			if err != nil {
-				return false, fmt.Errorf("timed out waiting for leader: %s", err)
+				return false, api.NewCodedError(api.ErrCodeNotLeader, "timed out waiting for leader: %s", err)
 			}
 			leader, err = peer.IDB58Decode(pidstr)
 			if err != nil {
@@ -322,6 +327,10 @@ func (cc *Consensus) commit(ctx context.Context, op *LogOp, rpcOp string, redire
 		}
 	}
 
+	if err := faults.DelayCommit(ctx); err != nil {
+		return err
+	}
+
 	var finalErr error
 	for i := 0; i <= cc.config.CommitRetries; i++ {
 		logger.Debugf("attempt #%d: committing %+v", i, op)
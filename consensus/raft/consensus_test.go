@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -12,9 +13,11 @@ import (
 	"github.com/ipfs/ipfs-cluster/state/dsstate"
 	"github.com/ipfs/ipfs-cluster/test"
 
+	raftboltdb "github.com/hashicorp/raft-boltdb"
 	cid "github.com/ipfs/go-cid"
 	libp2p "github.com/libp2p/go-libp2p"
 	host "github.com/libp2p/go-libp2p-core/host"
+	peer "github.com/libp2p/go-libp2p-core/peer"
 	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
 )
 
@@ -326,4 +329,157 @@ func TestRaftLatestSnapshot(t *testing.T) {
 	if len(pins) != 3 {
 		t.Fatal("Latest snapshot not read")
 	}
+
+	meta, err := latestSnapshotMeta(cc.config.GetDataFolder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullMeta, rc, err := latestSnapshot(cc.config.GetDataFolder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc.Close()
+	if meta == nil || meta.ID != fullMeta.ID || meta.Index != fullMeta.Index {
+		t.Error("expected the metadata of the latest snapshot")
+	}
+}
+
+func TestFetchSnapshot(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer cleanRaft(1)
+	defer cc.Shutdown(ctx)
+
+	h := makeTestingHost(t)
+	defer h.Close()
+	h.Peerstore().AddAddrs(cc.host.ID(), cc.host.Addrs(), peerstore.PermanentAddrTTL)
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.DataFolder = "raftFolderFromTests-2"
+	cleanRaft(2)
+	defer cleanRaft(2)
+
+	cc.config.SnapshotTrustedPeers = []peer.ID{h.ID()}
+	err := FetchSnapshot(ctx, h, cfg, cc.host.ID())
+	if err == nil {
+		t.Error("expected an error when the peer has no snapshots")
+	}
+
+	err = cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	err = cc.raft.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Peers which are neither Raft peers nor trusted get nothing.
+	cc.config.SnapshotTrustedPeers = []peer.ID{}
+	err = FetchSnapshot(ctx, h, cfg, cc.host.ID())
+	if err == nil || err.Error() != errSnapshotNotAllowed.Error() {
+		t.Fatal("expected an error when the peer is not trusted:", err)
+	}
+	if meta, _ := latestSnapshotMeta(cfg.GetDataFolder()); meta != nil {
+		t.Fatal("no snapshot should have been stored")
+	}
+
+	cc.config.SnapshotTrustedPeers = []peer.ID{h.ID()}
+	err = FetchSnapshot(ctx, h, cfg, cc.host.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := latestSnapshotMeta(cfg.GetDataFolder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteMeta, err := latestSnapshotMeta(cc.config.GetDataFolder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta == nil || meta.Index != remoteMeta.Index || meta.Term != remoteMeta.Term {
+		t.Fatal("expected the snapshot of the remote peer")
+	}
+
+	st, err := OfflineState(cfg, inmem.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pins, err := st.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || !pins[0].Cid.Equals(test.Cid1) {
+		t.Error("the fetched snapshot should contain the pin")
+	}
+
+	err = FetchSnapshot(ctx, h, cfg, cc.host.ID())
+	if err == nil {
+		t.Error("expected an error when a snapshot exists already")
+	}
+}
+
+func TestCompactLog(t *testing.T) {
+	ctx := context.Background()
+	cc := testingConsensus(t, 1)
+	defer cleanRaft(1)
+	defer cc.Shutdown(ctx)
+
+	err := cc.LogPin(ctx, testPin(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	err = cc.raft.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = CompactLog(cc.config)
+	if err != errLogStoreInUse {
+		t.Error("expected an error compacting the log of a running peer:", err)
+	}
+
+	err = cc.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := Usage(cc.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.LogStore == 0 || usage.Snapshots == 0 || usage.SnapshotsSize == 0 {
+		t.Errorf("unexpected usage: %+v", usage)
+	}
+
+	logPath := filepath.Join(cc.config.GetDataFolder(), logStoreFile)
+	lastIndex := func() uint64 {
+		store, err := raftboltdb.NewBoltStore(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		idx, err := store.LastIndex()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return idx
+	}
+	idx := lastIndex()
+
+	before, after, err := CompactLog(cc.config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != usage.LogStore || after > before {
+		t.Errorf("unexpected sizes: %d -> %d", before, after)
+	}
+
+	if lastIndex() != idx {
+		t.Error("the log should be kept after compacting it")
+	}
 }
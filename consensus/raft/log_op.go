@@ -2,7 +2,6 @@ package raft
 
 import (
 	"context"
-	"errors"
 
 	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
@@ -106,5 +105,5 @@ ROLLBACK:
 	// cluster to the previous state. This operation can only be performed
 	// by the cluster leader.
 	logger.Error("Rollbacks are not implemented")
-	return nil, errors.New("a rollback may be necessary. Reason: " + err.Error())
+	return nil, api.NewCodedError(api.ErrCodeOutdatedState, "a rollback may be necessary. Reason: %s", err)
 }
@@ -136,7 +136,7 @@ func (rw *raftWrapper) makeTransport() (err error) {
 func (rw *raftWrapper) makeStores() error {
 	logger.Debug("creating BoltDB store")
 	df := rw.config.GetDataFolder()
-	store, err := raftboltdb.NewBoltStore(filepath.Join(df, "raft.db"))
+	store, err := raftboltdb.NewBoltStore(filepath.Join(df, logStoreFile))
 	if err != nil {
 		return err
 	}
@@ -557,6 +557,26 @@ func latestSnapshot(raftDataFolder string) (*hraft.SnapshotMeta, io.ReadCloser,
 	return meta, r, nil
 }
 
+// latestSnapshotMeta returns the metadata of the most recent raft snapshot
+// stored at the given folder, or nil when there are none. Only the metadata
+// file kept alongside every snapshot is read. Opening the snapshot with
+// latestSnapshot() instead reads the whole state to verify its checksum,
+// which takes long with large states.
+func latestSnapshotMeta(raftDataFolder string) (*hraft.SnapshotMeta, error) {
+	store, err := hraft.NewFileSnapshotStore(raftDataFolder, RaftMaxSnapshots, nil)
+	if err != nil {
+		return nil, err
+	}
+	snapMetas, err := store.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapMetas) == 0 { // no error if snapshot isn't found
+		return nil, nil
+	}
+	return snapMetas[0], nil
+}
+
 // LastStateRaw returns the bytes of the last snapshot stored, its metadata,
 // and a flag indicating whether any snapshot was found.
 func LastStateRaw(cfg *Config) (io.Reader, bool, error) {
@@ -588,7 +608,7 @@ func SnapshotSave(cfg *Config, newState state.State, pids []peer.ID) error {
 	if err != nil {
 		return err
 	}
-	meta, _, err := latestSnapshot(dataFolder)
+	meta, err := latestSnapshotMeta(dataFolder)
 	if err != nil {
 		return err
 	}
@@ -641,7 +661,7 @@ func CleanupRaft(cfg *Config) error {
 	dataFolder := cfg.GetDataFolder()
 	keep := cfg.BackupsRotate
 
-	meta, _, err := latestSnapshot(dataFolder)
+	meta, err := latestSnapshotMeta(dataFolder)
 	if meta == nil && err == nil {
 		// no snapshots at all. Avoid creating backups
 		// from empty state folders.
@@ -0,0 +1,203 @@
+package raft
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	host "github.com/libp2p/go-libp2p-core/host"
+	network "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	protocol "github.com/libp2p/go-libp2p-core/protocol"
+
+	hraft "github.com/hashicorp/raft"
+)
+
+// SnapshotProtocol is the libp2p protocol used by peers joining a Raft
+// cluster to download the latest state snapshot of an existing peer (see
+// FetchSnapshot).
+const SnapshotProtocol = protocol.ID("/ipfs-cluster/raft-snapshot/1.0.0")
+
+// snapshotHeader is sent, as a JSON line, before the snapshot bytes. These
+// are followed by their SHA-256 checksum.
+type snapshotHeader struct {
+	Error string              `json:"error,omitempty"`
+	Meta  *hraft.SnapshotMeta `json:"meta,omitempty"`
+}
+
+// errSnapshotNotAllowed is sent to peers which are neither Raft peers nor
+// trusted to download snapshots.
+var errSnapshotNotAllowed = errors.New("not allowed to download snapshots from this peer")
+
+// serveSnapshot sends the latest snapshot of this peer over the given
+// stream, as long as the remote peer may download it (see
+// canFetchSnapshot).
+func (cc *Consensus) serveSnapshot(s network.Stream) {
+	defer s.Close()
+
+	remotePeer := s.Conn().RemotePeer()
+	remote := peer.IDB58Encode(remotePeer)
+	hdr := snapshotHeader{}
+	var meta *hraft.SnapshotMeta
+	var r io.ReadCloser
+	err := errSnapshotNotAllowed
+	if cc.canFetchSnapshot(remotePeer) {
+		meta, r, err = latestSnapshot(cc.config.GetDataFolder())
+	} else {
+		logger.Warningf("refusing to send a snapshot to %s: not a raft peer nor in snapshot_trusted_peers", remote)
+	}
+	switch {
+	case err != nil:
+		hdr.Error = err.Error()
+	case meta == nil:
+		hdr.Error = "no snapshot available"
+	default:
+		defer r.Close()
+		hdr.Meta = meta
+	}
+
+	b, err := json.Marshal(hdr)
+	if err != nil {
+		logger.Error(err)
+		s.Reset()
+		return
+	}
+	if _, err := s.Write(append(b, '\n')); err != nil || hdr.Meta == nil {
+		return
+	}
+
+	logger.Infof("sending snapshot %s to %s", meta.ID, remote)
+	sum := sha256.New()
+	_, err = io.CopyN(io.MultiWriter(s, sum), r, meta.Size)
+	if err == nil {
+		_, err = s.Write(sum.Sum(nil))
+	}
+	if err != nil {
+		logger.Errorf("error sending snapshot to %s: %s", remote, err)
+		s.Reset()
+	}
+}
+
+// canFetchSnapshot returns whether the given peer may download snapshots:
+// snapshots contain the whole shared state, so they are only sent to the
+// members of the Raft peerset and to the peers in SnapshotTrustedPeers.
+func (cc *Consensus) canFetchSnapshot(p peer.ID) bool {
+	if cc.config.SnapshotTrustAll {
+		return true
+	}
+	for _, tp := range cc.config.SnapshotTrustedPeers {
+		if tp == p {
+			return true
+		}
+	}
+
+	peers, err := cc.Peers(cc.ctx)
+	if err != nil {
+		logger.Error(err)
+		return false
+	}
+	for _, rp := range peers {
+		if rp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchSnapshot downloads the latest state snapshot of the given peer,
+// which must be reachable by the host, and stores it in the Raft data
+// folder. A peer joining a cluster with it starts from the fetched state and
+// only needs to receive the log entries committed since then, instead of the
+// whole log. The data folder must not contain any snapshots. The given peer
+// only sends its snapshot if the host is one of its Raft peers or in its
+// SnapshotTrustedPeers.
+func FetchSnapshot(ctx context.Context, h host.Host, cfg *Config, p peer.ID) error {
+	dataFolder := cfg.GetDataFolder()
+	if err := makeDataFolder(dataFolder); err != nil {
+		return err
+	}
+	existing, err := latestSnapshotMeta(dataFolder)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return errors.New("the raft data folder already contains a snapshot")
+	}
+
+	s, err := h.NewStream(ctx, p, SnapshotProtocol)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Reset()
+		case <-done:
+		}
+	}()
+
+	br := bufio.NewReader(s)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("reading snapshot header: %s", err)
+	}
+	var hdr snapshotHeader
+	if err := json.Unmarshal(line, &hdr); err != nil {
+		return fmt.Errorf("decoding snapshot header: %s", err)
+	}
+	if hdr.Error != "" {
+		return errors.New(hdr.Error)
+	}
+	meta := hdr.Meta
+	if meta == nil {
+		return errors.New("no snapshot metadata received")
+	}
+
+	store, err := hraft.NewFileSnapshotStoreWithLogger(dataFolder, RaftMaxSnapshots, nil)
+	if err != nil {
+		return err
+	}
+	_, dummyTransport := hraft.NewInmemTransport("")
+	sink, err := store.Create(
+		meta.Version,
+		meta.Index,
+		meta.Term,
+		meta.Configuration,
+		meta.ConfigurationIndex,
+		dummyTransport,
+	)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.New()
+	_, err = io.CopyN(io.MultiWriter(sink, sum), br, meta.Size)
+	if err != nil {
+		sink.Cancel()
+		return fmt.Errorf("reading snapshot: %s", err)
+	}
+	remoteSum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(br, remoteSum); err != nil {
+		sink.Cancel()
+		return fmt.Errorf("reading snapshot checksum: %s", err)
+	}
+	if !bytes.Equal(remoteSum, sum.Sum(nil)) {
+		sink.Cancel()
+		return errors.New("snapshot checksum mismatch")
+	}
+
+	if err := sink.Close(); err != nil {
+		return err
+	}
+	logger.Infof("fetched snapshot %s (index %d) from %s", meta.ID, meta.Index, peer.IDB58Encode(p))
+	return nil
+}
@@ -4,7 +4,10 @@ package badger
 
 import (
 	"os"
+	"path/filepath"
+	"runtime"
 
+	badger "github.com/dgraph-io/badger"
 	ds "github.com/ipfs/go-datastore"
 	badgerds "github.com/ipfs/go-ds-badger"
 	"github.com/pkg/errors"
@@ -31,3 +34,59 @@ func Cleanup(cfg *Config) error {
 	return os.RemoveAll(cfg.GetFolder())
 
 }
+
+// compactDiscardRatio is the fraction of discardable data above which value
+// log files are rewritten when compacting.
+const compactDiscardRatio = 0.5
+
+// Compact reclaims the space used by deleted and overwritten entries in the
+// badger datastore: it merges all the levels of its LSM tree and then
+// rewrites the value log files with enough discardable data. The datastore
+// must not be in use. It returns the size of the datastore folder before and
+// after.
+func Compact(cfg *Config) (before, after int64, err error) {
+	before, err = DiskUsage(cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	d, err := New(cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	bds := d.(*badgerds.Datastore)
+	err = bds.DB.Flatten(runtime.NumCPU())
+	for err == nil {
+		err = bds.DB.RunValueLogGC(compactDiscardRatio)
+	}
+	if err == badger.ErrNoRewrite {
+		err = nil
+	}
+	if cerr := bds.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "compacting badger datastore")
+	}
+
+	after, err = DiskUsage(cfg)
+	return before, after, err
+}
+
+// DiskUsage returns the size of the files in the badger datastore folder.
+func DiskUsage(cfg *Config) (int64, error) {
+	var size int64
+	err := filepath.Walk(cfg.GetFolder(), func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
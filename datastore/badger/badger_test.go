@@ -0,0 +1,61 @@
+package badger
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+)
+
+func TestCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badger-compact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Folder = dir
+
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if err := d.Put(ds.NewKey(fmt.Sprintf("k%d", i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Delete(ds.NewKey("k0")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = Compact(cfg)
+	if err == nil {
+		t.Error("expected an error compacting a datastore in use")
+	}
+	d.Close()
+
+	before, after, err := Compact(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before == 0 || after == 0 {
+		t.Errorf("unexpected sizes: %d -> %d", before, after)
+	}
+
+	d, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if ok, _ := d.Has(ds.NewKey("k0")); ok {
+		t.Error("k0 should have been deleted")
+	}
+	if v, err := d.Get(ds.NewKey("k99")); err != nil || string(v) != "value" {
+		t.Error("k99 should be kept:", err)
+	}
+}
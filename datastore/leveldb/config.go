@@ -0,0 +1,172 @@
+package leveldb
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+
+	"github.com/ipfs/ipfs-cluster/config"
+)
+
+const configKey = "leveldb"
+const envConfigKey = "cluster_leveldb"
+
+// Default values for leveldb Config
+const (
+	DefaultSubFolder = "leveldb"
+)
+
+var (
+	// DefaultLevelDBOptions carries the default options used by the
+	// leveldb datastore.
+	DefaultLevelDBOptions = opt.Options{
+		BlockCacheCapacity:     opt.DefaultBlockCacheCapacity,
+		OpenFilesCacheCapacity: opt.DefaultOpenFilesCacheCapacity,
+		WriteBuffer:            opt.DefaultWriteBuffer,
+		Compression:            opt.DefaultCompression,
+	}
+)
+
+// Config is used to initialize a LevelDB datastore. It implements the
+// ComponentConfig interface.
+type Config struct {
+	config.Saver
+
+	// The folder for this datastore. Non-absolute paths are relative to
+	// the base configuration folder.
+	Folder string
+
+	LevelDBOptions opt.Options
+}
+
+// levelDBOptions is the subset of opt.Options which can be set from the
+// configuration.
+type levelDBOptions struct {
+	BlockCacheCapacity     int  `json:"block_cache_capacity"`
+	OpenFilesCacheCapacity int  `json:"open_files_cache_capacity"`
+	WriteBuffer            int  `json:"write_buffer"`
+	Compression            uint `json:"compression"`
+	NoSync                 bool `json:"no_sync"`
+}
+
+func (lo *levelDBOptions) Unmarshal() *opt.Options {
+	return &opt.Options{
+		BlockCacheCapacity:     lo.BlockCacheCapacity,
+		OpenFilesCacheCapacity: lo.OpenFilesCacheCapacity,
+		WriteBuffer:            lo.WriteBuffer,
+		Compression:            opt.Compression(lo.Compression),
+		NoSync:                 lo.NoSync,
+	}
+}
+
+func (lo *levelDBOptions) Marshal(ldbOpts *opt.Options) {
+	lo.BlockCacheCapacity = ldbOpts.BlockCacheCapacity
+	lo.OpenFilesCacheCapacity = ldbOpts.OpenFilesCacheCapacity
+	lo.WriteBuffer = ldbOpts.WriteBuffer
+	lo.Compression = uint(ldbOpts.Compression)
+	lo.NoSync = ldbOpts.NoSync
+}
+
+type jsonConfig struct {
+	Folder         string         `json:"folder,omitempty"`
+	LevelDBOptions levelDBOptions `json:"leveldb_options,omitempty"`
+}
+
+// ConfigKey returns a human-friendly identifier for this type of Datastore.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default initializes this Config with sensible values.
+func (cfg *Config) Default() error {
+	cfg.Folder = DefaultSubFolder
+	cfg.LevelDBOptions = DefaultLevelDBOptions
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+
+	err := envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Validate checks that the fields of this Config have working values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	if cfg.Folder == "" {
+		return errors.New("folder is unset")
+	}
+
+	if cfg.LevelDBOptions.Compression > opt.SnappyCompression {
+		return errors.New("leveldb_options.compression is invalid")
+	}
+
+	return nil
+}
+
+// LoadJSON reads the fields of this Config from a JSON byteslice as
+// generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		return err
+	}
+	cfg.Default()
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	config.SetIfNotDefault(jcfg.Folder, &cfg.Folder)
+
+	ldbOpts := jcfg.LevelDBOptions.Unmarshal()
+	config.SetIfNotDefault(ldbOpts.BlockCacheCapacity, &cfg.LevelDBOptions.BlockCacheCapacity)
+	config.SetIfNotDefault(ldbOpts.OpenFilesCacheCapacity, &cfg.LevelDBOptions.OpenFilesCacheCapacity)
+	config.SetIfNotDefault(ldbOpts.WriteBuffer, &cfg.LevelDBOptions.WriteBuffer)
+	cfg.LevelDBOptions.Compression = ldbOpts.Compression
+	cfg.LevelDBOptions.NoSync = ldbOpts.NoSync
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a JSON-formatted human-friendly representation of this
+// Config.
+func (cfg *Config) ToJSON() (raw []byte, err error) {
+	jcfg := cfg.toJSONConfig()
+
+	raw, err = config.DefaultJSONMarshal(jcfg)
+	return
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	jCfg := &jsonConfig{}
+
+	if cfg.Folder != DefaultSubFolder {
+		jCfg.Folder = cfg.Folder
+	}
+
+	lo := &levelDBOptions{}
+	lo.Marshal(&cfg.LevelDBOptions)
+	jCfg.LevelDBOptions = *lo
+
+	return jCfg
+}
+
+// GetFolder returns the LevelDB folder.
+func (cfg *Config) GetFolder() string {
+	if filepath.IsAbs(cfg.Folder) {
+		return cfg.Folder
+	}
+
+	return filepath.Join(cfg.BaseDir, cfg.Folder)
+}
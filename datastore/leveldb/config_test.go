@@ -0,0 +1,69 @@
+package leveldb
+
+import (
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+var cfgJSON = []byte(`
+{
+    "folder": "test",
+    "leveldb_options": {
+        "write_buffer": 1048576,
+        "compression": 1
+    }
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+
+	if cfg.LevelDBOptions.WriteBuffer != 1048576 {
+		t.Fatalf("WriteBuffer should be 1048576, got: %d", cfg.LevelDBOptions.WriteBuffer)
+	}
+
+	if cfg.LevelDBOptions.Compression != opt.NoCompression {
+		t.Fatalf("got: %d, want: %d", cfg.LevelDBOptions.Compression, opt.NoCompression)
+	}
+
+	if cfg.LevelDBOptions.BlockCacheCapacity != opt.DefaultBlockCacheCapacity {
+		t.Fatalf(
+			"got: %d, want: %d",
+			cfg.LevelDBOptions.BlockCacheCapacity,
+			opt.DefaultBlockCacheCapacity,
+		)
+	}
+
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Folder != "test" || cfg.LevelDBOptions.WriteBuffer != 1048576 {
+		t.Error("options were not kept")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.LevelDBOptions.Compression = 5
+	if cfg.Validate() == nil {
+		t.Error("expected error validating compression")
+	}
+}
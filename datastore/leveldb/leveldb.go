@@ -0,0 +1,188 @@
+// Package leveldb provides a configurable LevelDB go-datastore for use with
+// IPFS Cluster.
+package leveldb
+
+import (
+	"os"
+	"path/filepath"
+
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Datastore is a go-datastore backed by a LevelDB database.
+type Datastore struct {
+	DB *leveldb.DB
+}
+
+var _ ds.Batching = (*Datastore)(nil)
+
+// New returns a LevelDB datastore configured with the given
+// configuration.
+func New(cfg *Config) (ds.Datastore, error) {
+	folder := cfg.GetFolder()
+	err := os.MkdirAll(folder, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating leveldb folder")
+	}
+	opts := cfg.LevelDBOptions
+	db, err := leveldb.OpenFile(folder, &opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening leveldb datastore")
+	}
+	return &Datastore{DB: db}, nil
+}
+
+// Cleanup deletes the leveldb datastore.
+func Cleanup(cfg *Config) error {
+	folder := cfg.GetFolder()
+	if _, err := os.Stat(folder); os.IsNotExist(err) {
+		return nil
+	}
+	return os.RemoveAll(cfg.GetFolder())
+}
+
+// Put stores a value.
+func (d *Datastore) Put(key ds.Key, value []byte) error {
+	return d.DB.Put(key.Bytes(), value, nil)
+}
+
+// Get retrieves a value.
+func (d *Datastore) Get(key ds.Key) ([]byte, error) {
+	val, err := d.DB.Get(key.Bytes(), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ds.ErrNotFound
+	}
+	return val, err
+}
+
+// Has returns whether a key is stored.
+func (d *Datastore) Has(key ds.Key) (bool, error) {
+	return d.DB.Has(key.Bytes(), nil)
+}
+
+// GetSize returns the size of the value stored under a key.
+func (d *Datastore) GetSize(key ds.Key) (int, error) {
+	return ds.GetBackedSize(d, key)
+}
+
+// Delete removes a key.
+func (d *Datastore) Delete(key ds.Key) error {
+	return d.DB.Delete(key.Bytes(), nil)
+}
+
+// Query runs a query. Only the prefix is handled by LevelDB, the rest of
+// the query is applied on the results.
+func (d *Datastore) Query(q query.Query) (query.Results, error) {
+	var rng *util.Range
+	prefix := ds.NewKey(q.Prefix).String()
+	if prefix != "/" {
+		rng = util.BytesPrefix([]byte(prefix + "/"))
+	}
+	iter := d.DB.NewIterator(rng, nil)
+	done := false
+	qr := query.ResultsFromIterator(q, query.Iterator{
+		Next: func() (query.Result, bool) {
+			if done {
+				return query.Result{}, false
+			}
+			if !iter.Next() {
+				done = true
+				if err := iter.Error(); err != nil {
+					return query.Result{Error: err}, true
+				}
+				return query.Result{}, false
+			}
+			e := query.Entry{Key: string(iter.Key())}
+			if !q.KeysOnly {
+				e.Value = append([]byte(nil), iter.Value()...)
+			}
+			return query.Result{Entry: e}, true
+		},
+		Close: func() error {
+			iter.Release()
+			return nil
+		},
+	})
+	// The prefix has been applied already.
+	q.Prefix = ""
+	return query.NaiveQueryApply(q, qr), nil
+}
+
+// Batch returns a batch of operations which are written atomically on
+// Commit.
+func (d *Datastore) Batch() (ds.Batch, error) {
+	return &batch{db: d.DB, b: new(leveldb.Batch)}, nil
+}
+
+// Close closes the database.
+func (d *Datastore) Close() error {
+	return d.DB.Close()
+}
+
+type batch struct {
+	db *leveldb.DB
+	b  *leveldb.Batch
+}
+
+func (b *batch) Put(key ds.Key, value []byte) error {
+	b.b.Put(key.Bytes(), value)
+	return nil
+}
+
+func (b *batch) Delete(key ds.Key) error {
+	b.b.Delete(key.Bytes())
+	return nil
+}
+
+func (b *batch) Commit() error {
+	return b.db.Write(b.b, nil)
+}
+
+// Compact reclaims the space used by deleted and overwritten entries in the
+// leveldb datastore by compacting its whole key range. The datastore must
+// not be in use. It returns the size of the datastore folder before and
+// after.
+func Compact(cfg *Config) (before, after int64, err error) {
+	before, err = DiskUsage(cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	d, err := New(cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	lds := d.(*Datastore)
+	err = lds.DB.CompactRange(util.Range{})
+	if cerr := lds.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "compacting leveldb datastore")
+	}
+
+	after, err = DiskUsage(cfg)
+	return before, after, err
+}
+
+// DiskUsage returns the size of the files in the leveldb datastore folder.
+func DiskUsage(cfg *Config) (int64, error) {
+	var size int64
+	err := filepath.Walk(cfg.GetFolder(), func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
@@ -0,0 +1,96 @@
+package leveldb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+)
+
+func TestDatastore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leveldb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Folder = dir
+
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := d.(ds.Batching).Batch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := b.Put(ds.NewKey(fmt.Sprintf("/a/k%d", i)), []byte("value")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Put(ds.NewKey("/ab"), []byte("other")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Delete(ds.NewKey("/a/k0")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(ds.NewKey("/a/k0")); err != ds.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got: %v", err)
+	}
+
+	res, err := d.Query(query.Query{Prefix: "/a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 9 {
+		t.Errorf("expected 9 entries under /a, got: %d", len(entries))
+	}
+	d.Close()
+
+	d, err = New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if size, err := d.GetSize(ds.NewKey("/a/k9")); err != nil || size != 5 {
+		t.Errorf("k9 should have survived a restart: %d %v", size, err)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leveldb-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.BaseDir = dir
+
+	d, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Close()
+	if err := Cleanup(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cfg.GetFolder()); !os.IsNotExist(err) {
+		t.Error("leveldb folder should have been removed")
+	}
+}
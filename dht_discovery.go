@@ -0,0 +1,99 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/pstoremgr"
+
+	net "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	discovery "github.com/libp2p/go-libp2p-discovery"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+)
+
+// rendezvousNamespace returns the key under which peers advertise
+// themselves on the DHT for the given cluster name.
+func rendezvousNamespace(name string) string {
+	return "/ipfs-cluster/rendezvous/" + name
+}
+
+// dhtDiscovery advertises this peer on the DHT under the configured
+// rendezvous and regularly looks for other peers advertising it,
+// connecting to them.
+func (c *Cluster) dhtDiscovery() {
+	if c.dht == nil {
+		return
+	}
+
+	// Without a cluster secret our DHT is the public one, but we need
+	// to connect to it first.
+	if len(c.config.Secret) == 0 {
+		c.connectPublicDHT()
+	}
+
+	ns := rendezvousNamespace(c.config.DHTRendezvous)
+	rd := discovery.NewRoutingDiscovery(c.dht)
+	discovery.Advertise(c.ctx, rd, ns)
+
+	ticker := time.NewTicker(dhtDiscoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		c.findRendezvousPeers(rd, ns)
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Cluster) findRendezvousPeers(rd *discovery.RoutingDiscovery, ns string) {
+	ctx, cancel := context.WithTimeout(c.ctx, dhtDiscoveryInterval)
+	defer cancel()
+
+	pinfos, err := discovery.FindPeers(ctx, rd, ns)
+	if err != nil {
+		logger.Debugf("error finding peers on the DHT: %s", err)
+		return
+	}
+
+	for _, pinfo := range pinfos {
+		if pinfo.ID == c.id || len(pinfo.Addrs) == 0 {
+			continue
+		}
+		if c.host.Network().Connectedness(pinfo.ID) == net.Connected {
+			continue
+		}
+		logger.Infof("found %s on the DHT", pinfo.ID.Pretty())
+		c.peerManager.HandlePeerFound(pinfo)
+	}
+}
+
+// connectPublicDHT connects to the default IPFS bootstrap peers and
+// bootstraps the DHT with them.
+func (c *Cluster) connectPublicDHT() {
+	connected := 0
+	for _, addr := range dht.DefaultBootstrapPeers {
+		pinfo, err := peer.AddrInfoFromP2pAddr(addr)
+		if err != nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(c.ctx, pstoremgr.ConnectTimeout)
+		err = c.host.Connect(ctx, *pinfo)
+		cancel()
+		if err != nil {
+			logger.Debugf("error connecting to bootstrap peer %s: %s", pinfo.ID.Pretty(), err)
+			continue
+		}
+		connected++
+	}
+
+	if connected == 0 {
+		logger.Warning("could not connect to any public DHT bootstrap peer")
+		return
+	}
+	c.dht.BootstrapOnce(c.ctx, dht.DefaultBootstrapConfig)
+}
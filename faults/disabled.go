@@ -0,0 +1,7 @@
+//go:build !faults
+// +build !faults
+
+package faults
+
+// Enabled is true when the peer is built with the "faults" build tag.
+const Enabled = false
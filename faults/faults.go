@@ -0,0 +1,163 @@
+// Package faults provides a fault injection layer for resilience testing of
+// IPFS Cluster peers. It can drop RPC calls from other peers, delay the
+// commits to the consensus and fail requests to the IPFS daemon.
+//
+// Faults are only injected in binaries built with the "faults" build tag
+// (go build -tags faults). Otherwise Enabled is false, Set fails and the
+// hooks do nothing. The settings apply to all the peers running in the same
+// process.
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is returned by the operations which fail because of an
+// injected fault.
+var ErrInjected = errors.New("injected fault")
+
+// ErrDisabled is returned by Set when the binary was not built with the
+// "faults" build tag.
+var ErrDisabled = errors.New("fault injection is not enabled in this build")
+
+// Settings control the faults injected in the peer. The zero value injects
+// no faults.
+type Settings struct {
+	// RPCDropRate is the fraction (0 to 1) of the RPC calls received from
+	// other peers which are dropped. The callers see them fail as if they
+	// were not authorized.
+	RPCDropRate float64
+	// CommitDelay is added before every pin and unpin is committed to the
+	// consensus.
+	CommitDelay time.Duration
+	// ConnectorFailRate is the fraction (0 to 1) of the requests to the
+	// IPFS daemon which fail without being sent.
+	ConnectorFailRate float64
+}
+
+type jsonSettings struct {
+	RPCDropRate       float64 `json:"rpc_drop_rate"`
+	CommitDelay       string  `json:"commit_delay"`
+	ConnectorFailRate float64 `json:"connector_fail_rate"`
+}
+
+// MarshalJSON encodes the settings with a human-readable commit delay.
+func (s Settings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSettings{
+		RPCDropRate:       s.RPCDropRate,
+		CommitDelay:       s.CommitDelay.String(),
+		ConnectorFailRate: s.ConnectorFailRate,
+	})
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON.
+func (s *Settings) UnmarshalJSON(b []byte) error {
+	var js jsonSettings
+	err := json.Unmarshal(b, &js)
+	if err != nil {
+		return err
+	}
+	var delay time.Duration
+	if js.CommitDelay != "" {
+		delay, err = time.ParseDuration(js.CommitDelay)
+		if err != nil {
+			return errors.New("commit_delay is invalid")
+		}
+	}
+	*s = Settings{
+		RPCDropRate:       js.RPCDropRate,
+		CommitDelay:       delay,
+		ConnectorFailRate: js.ConnectorFailRate,
+	}
+	return nil
+}
+
+// Validate checks that the settings have sensible values.
+func (s Settings) Validate() error {
+	if s.RPCDropRate < 0 || s.RPCDropRate > 1 {
+		return errors.New("rpc_drop_rate must be between 0 and 1")
+	}
+	if s.CommitDelay < 0 {
+		return errors.New("commit_delay cannot be negative")
+	}
+	if s.ConnectorFailRate < 0 || s.ConnectorFailRate > 1 {
+		return errors.New("connector_fail_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+var (
+	mu      sync.RWMutex
+	current Settings
+)
+
+// Set replaces the current settings.
+func Set(s Settings) error {
+	if !Enabled {
+		return ErrDisabled
+	}
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	mu.Lock()
+	current = s
+	mu.Unlock()
+	return nil
+}
+
+// Get returns the current settings.
+func Get() Settings {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// DropRPC returns true when an incoming RPC call should be dropped.
+func DropRPC() bool {
+	if !Enabled {
+		return false
+	}
+	return happens(Get().RPCDropRate)
+}
+
+// DelayCommit waits for the configured commit delay or until the context is
+// done, in which case it returns the context error.
+func DelayCommit(ctx context.Context) error {
+	if !Enabled {
+		return nil
+	}
+	delay := Get().CommitDelay
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ConnectorCall returns ErrInjected when a request to the IPFS daemon should
+// fail.
+func ConnectorCall() error {
+	if !Enabled {
+		return nil
+	}
+	if happens(Get().ConnectorFailRate) {
+		return ErrInjected
+	}
+	return nil
+}
+
+// happens returns true with the given probability.
+func happens(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
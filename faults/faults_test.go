@@ -0,0 +1,94 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSettingsJSON(t *testing.T) {
+	s := Settings{
+		RPCDropRate:       0.5,
+		CommitDelay:       2 * time.Second,
+		ConnectorFailRate: 0.1,
+	}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s2 Settings
+	err = json.Unmarshal(b, &s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2 != s {
+		t.Errorf("expected %+v, got %+v", s, s2)
+	}
+
+	err = json.Unmarshal([]byte(`{"commit_delay": "abc"}`), &s2)
+	if err == nil {
+		t.Error("expected an error with an invalid commit delay")
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	for _, s := range []Settings{
+		{RPCDropRate: -0.1},
+		{RPCDropRate: 1.1},
+		{CommitDelay: -time.Second},
+		{ConnectorFailRate: 2},
+	} {
+		if s.Validate() == nil {
+			t.Errorf("expected %+v to be invalid", s)
+		}
+	}
+}
+
+func TestFaults(t *testing.T) {
+	defer Set(Settings{})
+
+	err := Set(Settings{
+		RPCDropRate:       1,
+		CommitDelay:       100 * time.Millisecond,
+		ConnectorFailRate: 1,
+	})
+	if !Enabled {
+		if err != ErrDisabled {
+			t.Fatal("expected ErrDisabled without the faults build tag")
+		}
+		if DropRPC() || ConnectorCall() != nil || DelayCommit(context.Background()) != nil {
+			t.Error("no faults should be injected without the faults build tag")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !DropRPC() {
+		t.Error("expected the RPC to be dropped")
+	}
+	if ConnectorCall() != ErrInjected {
+		t.Error("expected the connector call to fail")
+	}
+
+	start := time.Now()
+	if err := DelayCommit(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("expected the commit to be delayed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if DelayCommit(ctx) != context.Canceled {
+		t.Error("expected the delay to be interrupted by the context")
+	}
+
+	Set(Settings{})
+	if DropRPC() || ConnectorCall() != nil {
+		t.Error("no faults should be injected with the zero settings")
+	}
+}
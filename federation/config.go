@@ -0,0 +1,256 @@
+package federation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/ipfs/ipfs-cluster/config"
+)
+
+const configKey = "federation"
+const envConfigKey = "cluster_federation"
+
+// Default values for this Config.
+const (
+	DefaultEnableFederation   = false
+	DefaultName               = "remote"
+	DefaultSyncInterval       = 5 * time.Minute
+	DefaultTimeout            = 2 * time.Minute
+	DefaultKeepRemoved        = true
+	DefaultUnpinConfirmations = 3
+)
+
+// Config allows to configure the Federation component, which mirrors the
+// pinset of a remote cluster. It implements the config.ComponentConfig
+// interface.
+type Config struct {
+	config.Saver
+
+	// Enables the mirroring of the remote cluster pinset.
+	EnableFederation bool
+
+	// Name identifies the remote cluster. It is recorded in the metadata
+	// of the mirrored pins.
+	Name string
+
+	// The REST API endpoint of the remote cluster.
+	RemoteAPIAddr ma.Multiaddr
+
+	// Basic authentication credentials for the remote REST API.
+	Username string
+	Password string
+
+	// Use HTTPS to talk to the remote REST API, optionally without
+	// verifying its certificate.
+	SSL          bool
+	NoVerifyCert bool
+
+	// How often the remote pinset is fetched and mirrored.
+	SyncInterval time.Duration
+
+	// Timeout for requests to the remote cluster.
+	Timeout time.Duration
+
+	// Only the pins in these remote namespaces are mirrored. All pins are
+	// mirrored when empty.
+	Namespaces []string
+
+	// Local namespace for the mirrored pins.
+	Namespace string
+
+	// Placement policy for the mirrored pins.
+	Policy string
+
+	// Replication factors for the mirrored pins. The ones from the
+	// placement policy or the cluster defaults are used when 0.
+	ReplicationFactorMin int
+	ReplicationFactorMax int
+
+	// Do not unpin the mirrored pins which are removed from the remote
+	// cluster.
+	KeepRemoved bool
+
+	// Without KeepRemoved, number of consecutive syncs in which a
+	// mirrored pin must be missing from the remote pinset before it is
+	// unpinned, so that a transient problem in the remote cluster does
+	// not unpin everything.
+	UnpinConfirmations int
+}
+
+type jsonConfig struct {
+	EnableFederation      bool     `json:"enable_federation"`
+	Name                  string   `json:"name"`
+	RemoteAPIMultiaddress string   `json:"remote_api_multiaddress"`
+	Username              string   `json:"username,omitempty"`
+	Password              string   `json:"password,omitempty"`
+	SSL                   bool     `json:"ssl,omitempty"`
+	NoVerifyCert          bool     `json:"no_verify_cert,omitempty"`
+	SyncInterval          string   `json:"sync_interval"`
+	Timeout               string   `json:"timeout"`
+	Namespaces            []string `json:"namespaces"`
+	Namespace             string   `json:"namespace,omitempty"`
+	Policy                string   `json:"policy,omitempty"`
+	ReplicationFactorMin  int      `json:"replication_factor_min"`
+	ReplicationFactorMax  int      `json:"replication_factor_max"`
+	KeepRemoved           bool     `json:"keep_removed"`
+	UnpinConfirmations    int      `json:"unpin_confirmations"`
+}
+
+// ConfigKey provides a human-friendly identifier for this type of Config.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default sets the fields of this Config to sensible values.
+func (cfg *Config) Default() error {
+	cfg.EnableFederation = DefaultEnableFederation
+	cfg.Name = DefaultName
+	cfg.RemoteAPIAddr = nil
+	cfg.Username = ""
+	cfg.Password = ""
+	cfg.SSL = false
+	cfg.NoVerifyCert = false
+	cfg.SyncInterval = DefaultSyncInterval
+	cfg.Timeout = DefaultTimeout
+	cfg.Namespaces = nil
+	cfg.Namespace = ""
+	cfg.Policy = ""
+	cfg.ReplicationFactorMin = 0
+	cfg.ReplicationFactorMax = 0
+	cfg.KeepRemoved = DefaultKeepRemoved
+	cfg.UnpinConfirmations = DefaultUnpinConfirmations
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found
+// as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+
+	err := envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Validate checks that the fields of this Config have working values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	if !cfg.EnableFederation {
+		return nil
+	}
+
+	switch {
+	case cfg.Name == "":
+		return errors.New("federation.name is empty")
+	case cfg.RemoteAPIAddr == nil:
+		return errors.New("federation.remote_api_multiaddress is undefined")
+	case cfg.SyncInterval <= 0:
+		return errors.New("federation.sync_interval is invalid")
+	case cfg.Timeout <= 0:
+		return errors.New("federation.timeout is invalid")
+	case cfg.ReplicationFactorMin < -1 || cfg.ReplicationFactorMax < -1:
+		return errors.New("federation replication factors must be -1, 0 or positive")
+	case cfg.ReplicationFactorMax > 0 && cfg.ReplicationFactorMin > cfg.ReplicationFactorMax:
+		return errors.New("federation.replication_factor_min is larger than replication_factor_max")
+	case cfg.UnpinConfirmations <= 0:
+		return errors.New("federation.unpin_confirmations must be positive")
+	}
+	for _, ns := range cfg.Namespaces {
+		if ns == "" {
+			return errors.New("federation.namespaces cannot contain empty namespaces")
+		}
+	}
+	return nil
+}
+
+// LoadJSON sets the fields of this Config to the values defined by the JSON
+// representation of it, as generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling federation config")
+		return err
+	}
+
+	cfg.Default()
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	cfg.EnableFederation = jcfg.EnableFederation
+	config.SetIfNotDefault(jcfg.Name, &cfg.Name)
+	if jcfg.RemoteAPIMultiaddress != "" {
+		remoteAddr, err := ma.NewMultiaddr(jcfg.RemoteAPIMultiaddress)
+		if err != nil {
+			return fmt.Errorf("error parsing federation.remote_api_multiaddress: %s", err)
+		}
+		cfg.RemoteAPIAddr = remoteAddr
+	}
+	cfg.Username = jcfg.Username
+	cfg.Password = jcfg.Password
+	cfg.SSL = jcfg.SSL
+	cfg.NoVerifyCert = jcfg.NoVerifyCert
+	cfg.Namespaces = jcfg.Namespaces
+	cfg.Namespace = jcfg.Namespace
+	cfg.Policy = jcfg.Policy
+	cfg.ReplicationFactorMin = jcfg.ReplicationFactorMin
+	cfg.ReplicationFactorMax = jcfg.ReplicationFactorMax
+	cfg.KeepRemoved = jcfg.KeepRemoved
+	config.SetIfNotDefault(jcfg.UnpinConfirmations, &cfg.UnpinConfirmations)
+
+	err := config.ParseDurations(
+		configKey,
+		&config.DurationOpt{Duration: jcfg.SyncInterval, Dst: &cfg.SyncInterval, Name: "sync_interval"},
+		&config.DurationOpt{Duration: jcfg.Timeout, Dst: &cfg.Timeout, Name: "timeout"},
+	)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := cfg.toJSONConfig()
+
+	return config.DefaultJSONMarshal(jcfg)
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	jcfg := &jsonConfig{
+		EnableFederation:     cfg.EnableFederation,
+		Name:                 cfg.Name,
+		Username:             cfg.Username,
+		Password:             cfg.Password,
+		SSL:                  cfg.SSL,
+		NoVerifyCert:         cfg.NoVerifyCert,
+		SyncInterval:         cfg.SyncInterval.String(),
+		Timeout:              cfg.Timeout.String(),
+		Namespaces:           cfg.Namespaces,
+		Namespace:            cfg.Namespace,
+		Policy:               cfg.Policy,
+		ReplicationFactorMin: cfg.ReplicationFactorMin,
+		ReplicationFactorMax: cfg.ReplicationFactorMax,
+		KeepRemoved:          cfg.KeepRemoved,
+		UnpinConfirmations:   cfg.UnpinConfirmations,
+	}
+	if cfg.RemoteAPIAddr != nil {
+		jcfg.RemoteAPIMultiaddress = cfg.RemoteAPIAddr.String()
+	}
+	if jcfg.Namespaces == nil {
+		jcfg.Namespaces = []string{}
+	}
+	return jcfg
+}
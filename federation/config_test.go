@@ -0,0 +1,154 @@
+package federation
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+var cfgJSON = []byte(`
+{
+	"enable_federation": true,
+	"name": "origin",
+	"remote_api_multiaddress": "/dns4/cluster.example.org/tcp/9094",
+	"username": "backup",
+	"password": "secret",
+	"sync_interval": "1m",
+	"timeout": "30s",
+	"namespaces": ["team-a"],
+	"namespace": "origin",
+	"replication_factor_min": 2,
+	"replication_factor_max": 3,
+	"keep_removed": true,
+	"unpin_confirmations": 5
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.EnableFederation ||
+		cfg.Name != "origin" ||
+		cfg.RemoteAPIAddr.String() != "/dns4/cluster.example.org/tcp/9094" ||
+		cfg.Username != "backup" ||
+		cfg.SyncInterval != time.Minute ||
+		cfg.Timeout != 30*time.Second ||
+		len(cfg.Namespaces) != 1 ||
+		cfg.Namespace != "origin" ||
+		cfg.ReplicationFactorMin != 2 ||
+		cfg.ReplicationFactorMax != 3 ||
+		!cfg.KeepRemoved ||
+		cfg.UnpinConfirmations != 5 {
+		t.Error("config not loaded correctly")
+	}
+
+	j := &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.RemoteAPIMultiaddress = ""
+	tst, _ := json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with an undefined remote_api_multiaddress")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.EnableFederation = false
+	j.RemoteAPIMultiaddress = ""
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err != nil {
+		t.Error("remote_api_multiaddress is not needed when disabled")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.SyncInterval = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a negative sync_interval")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.ReplicationFactorMax = 1
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with replication_factor_min > replication_factor_max")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.Namespaces = []string{""}
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with an empty namespace")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.UnpinConfirmations = -1
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a negative unpin_confirmations")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RemoteAPIAddr.String() != "/dns4/cluster.example.org/tcp/9094" {
+		t.Error("remote_api_multiaddress not preserved")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+	if !cfg.KeepRemoved {
+		t.Error("removed pins should be kept by default")
+	}
+
+	_, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.EnableFederation = true
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating without remote_api_multiaddress")
+	}
+}
+
+func TestApplyEnvVars(t *testing.T) {
+	os.Setenv("CLUSTER_FEDERATION_SYNCINTERVAL", "22s")
+	defer os.Unsetenv("CLUSTER_FEDERATION_SYNCINTERVAL")
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ApplyEnvVars()
+
+	if cfg.SyncInterval != 22*time.Second {
+		t.Fatal("failed to override sync_interval with env var")
+	}
+}
@@ -0,0 +1,253 @@
+// Package federation implements a Cluster component which follows the
+// pinset of a remote cluster through its REST API and replicates the
+// selected pins locally, so that a cluster can work as a backup of another
+// one, even when they are run by different organizations.
+package federation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/api/rest/client"
+
+	logging "github.com/ipfs/go-log"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	"go.opencensus.io/trace"
+)
+
+var logger = logging.Logger("federation")
+
+// MetadataKey is the metadata key which marks mirrored pins. Its value is
+// the name of the remote cluster they come from.
+const MetadataKey = "federation"
+
+// Federation is a Cluster API component which mirrors the pinset of a
+// remote cluster. The mirroring is a leader task (see
+// ipfscluster.LeaderTasker), so only one peer of the cluster runs it at a
+// time.
+type Federation struct {
+	config *Config
+	remote client.Client
+
+	rpcClient *rpc.Client
+
+	// missing counts, for every mirrored pin not found in the remote
+	// pinset, the consecutive syncs in which it was missing. It is only
+	// used by sync, which is never run concurrently.
+	missing map[string]int
+
+	shutdownLock sync.Mutex
+	shutdown     bool
+}
+
+// New returns a Federation component with the given configuration.
+func New(cfg *Config) (*Federation, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	remote, err := client.NewDefaultClient(&client.Config{
+		APIAddr:      cfg.RemoteAPIAddr,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		SSL:          cfg.SSL,
+		NoVerifyCert: cfg.NoVerifyCert,
+		Timeout:      cfg.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("mirroring the pinset of %s (%s)", cfg.Name, cfg.RemoteAPIAddr)
+	return &Federation{
+		config:  cfg,
+		remote:  remote,
+		missing: make(map[string]int),
+	}, nil
+}
+
+// SetClient makes the component ready to perform RPC
+// requests.
+func (fed *Federation) SetClient(c *rpc.Client) {
+	fed.rpcClient = c
+}
+
+// Shutdown stops the mirroring of the remote pinset.
+func (fed *Federation) Shutdown(ctx context.Context) error {
+	fed.shutdownLock.Lock()
+	defer fed.shutdownLock.Unlock()
+
+	if fed.shutdown {
+		logger.Debug("already shutdown")
+		return nil
+	}
+
+	logger.Info("stopping Federation component")
+	fed.shutdown = true
+	return nil
+}
+
+// LeaderTask returns the task mirroring the remote pinset every
+// SyncInterval.
+func (fed *Federation) LeaderTask() (string, time.Duration, func(context.Context) error) {
+	return "federation/" + fed.config.Name, fed.config.SyncInterval, fed.sync
+}
+
+// sync pins the selected remote pins which are not pinned locally and,
+// unless KeepRemoved is set, unpins the mirrored pins which have been
+// missing from the remote pinset for UnpinConfirmations syncs in a row.
+func (fed *Federation) sync(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "federation/sync")
+	defer span.End()
+
+	if fed.rpcClient == nil {
+		return errors.New("federation: RPC client not set")
+	}
+
+	remoteCtx, cancel := context.WithTimeout(ctx, fed.config.Timeout)
+	defer cancel()
+	remotePins, err := fed.remote.Allocations(remoteCtx, api.DataType)
+	if err != nil {
+		return err
+	}
+
+	var localPins []*api.Pin
+	err = fed.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"Pins",
+		struct{}{},
+		&localPins,
+	)
+	if err != nil {
+		return err
+	}
+
+	local := make(map[string]*api.Pin, len(localPins))
+	for _, pin := range localPins {
+		local[pin.Cid.String()] = pin
+	}
+
+	selected := make(map[string]struct{}, len(remotePins))
+	var pinned, failed int
+	for _, rpin := range remotePins {
+		if !fed.isSelected(rpin) {
+			continue
+		}
+		selected[rpin.Cid.String()] = struct{}{}
+		if _, ok := local[rpin.Cid.String()]; ok {
+			continue
+		}
+
+		var pin api.Pin
+		err := fed.rpcClient.CallContext(
+			ctx,
+			"",
+			"Cluster",
+			"Pin",
+			fed.mirrorPin(rpin),
+			&pin,
+		)
+		if err != nil {
+			logger.Warningf("error mirroring %s: %s", rpin.Cid, err)
+			failed++
+			continue
+		}
+		pinned++
+	}
+
+	var unpinned int
+	if !fed.config.KeepRemoved {
+		for c := range fed.missing {
+			if _, ok := local[c]; !ok {
+				delete(fed.missing, c)
+			}
+		}
+		for c, pin := range local {
+			if _, ok := selected[c]; ok || !fed.isMirrored(pin) {
+				delete(fed.missing, c)
+				continue
+			}
+			fed.missing[c]++
+			if fed.missing[c] < fed.config.UnpinConfirmations {
+				logger.Debugf("%s missing from %s (%d/%d)", pin.Cid, fed.config.Name, fed.missing[c], fed.config.UnpinConfirmations)
+				continue
+			}
+			var unpin api.Pin
+			err := fed.rpcClient.CallContext(
+				ctx,
+				"",
+				"Cluster",
+				"Unpin",
+				pin,
+				&unpin,
+			)
+			if err != nil {
+				logger.Warningf("error unpinning %s, removed from %s: %s", pin.Cid, fed.config.Name, err)
+				failed++
+				continue
+			}
+			delete(fed.missing, c)
+			unpinned++
+		}
+	}
+
+	if pinned+unpinned+failed > 0 {
+		logger.Infof(
+			"pinset of %s mirrored: %d pinned, %d unpinned, %d failed",
+			fed.config.Name,
+			pinned,
+			unpinned,
+			failed,
+		)
+	}
+	return nil
+}
+
+// isSelected returns true when the given remote pin should be mirrored.
+func (fed *Federation) isSelected(pin *api.Pin) bool {
+	if len(fed.config.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range fed.config.Namespaces {
+		if pin.Namespace == ns {
+			return true
+		}
+	}
+	return false
+}
+
+// isMirrored returns true when the given local pin was mirrored from the
+// remote cluster.
+func (fed *Federation) isMirrored(pin *api.Pin) bool {
+	return pin.Metadata[MetadataKey] == fed.config.Name
+}
+
+// mirrorPin returns the local pin for the given remote pin. It keeps the
+// name, metadata and depth of the remote pin, while the replication options
+// are taken from the configuration. The expiration and the size of the
+// remote pin are not kept: the remote cluster cannot make the mirrored pins
+// expire and sizes are measured locally for quotas.
+func (fed *Federation) mirrorPin(rpin *api.Pin) *api.Pin {
+	meta := make(map[string]string, len(rpin.Metadata)+1)
+	for k, v := range rpin.Metadata {
+		meta[k] = v
+	}
+	meta[MetadataKey] = fed.config.Name
+
+	pin := api.PinWithOpts(rpin.Cid, api.PinOptions{
+		ReplicationFactorMin: fed.config.ReplicationFactorMin,
+		ReplicationFactorMax: fed.config.ReplicationFactorMax,
+		Name:                 rpin.Name,
+		Metadata:             meta,
+		Policy:               fed.config.Policy,
+		Namespace:            fed.config.Namespace,
+	})
+	pin.MaxDepth = rpin.MaxDepth
+	return pin
+}
@@ -0,0 +1,209 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	manet "github.com/multiformats/go-multiaddr-net"
+)
+
+// mockCluster records the pins and unpins made by the Federation
+// component.
+type mockCluster struct {
+	mu       sync.Mutex
+	pins     []*api.Pin
+	unpinned []*api.Pin
+}
+
+func (mock *mockCluster) Pins(ctx context.Context, in struct{}, out *[]*api.Pin) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	*out = mock.pins
+	return nil
+}
+
+func (mock *mockCluster) Pin(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.pins = append(mock.pins, in)
+	*out = *in
+	return nil
+}
+
+func (mock *mockCluster) Unpin(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.unpinned = append(mock.unpinned, in)
+	*out = *in
+	return nil
+}
+
+func testRemote(pins []*api.Pin) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/allocations" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(pins)
+	}))
+}
+
+func testFederation(t *testing.T, remote *httptest.Server, mock *mockCluster) *Federation {
+	cfg := &Config{}
+	cfg.Default()
+	cfg.EnableFederation = true
+	addr, err := manet.FromNetAddr(remote.Listener.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.RemoteAPIAddr = addr
+	cfg.Namespaces = []string{test.Namespace1}
+	cfg.ReplicationFactorMin = 1
+	cfg.ReplicationFactorMax = 1
+
+	fed, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := rpc.NewServer(nil, "mock")
+	err = s.RegisterName("Cluster", mock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fed.rpcClient = rpc.NewClientWithServer(nil, "mock", s)
+	return fed
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+
+	selected := api.PinWithOpts(test.Cid1, api.PinOptions{
+		Name:      "selected",
+		Namespace: test.Namespace1,
+		Metadata:  map[string]string{"a": "b"},
+	})
+	selected.ExpireAt = time.Now().Add(time.Hour)
+	selected.Size = 1
+	ignored := api.PinWithOpts(test.Cid2, api.PinOptions{Name: "ignored"})
+	remote := testRemote([]*api.Pin{selected, ignored})
+	defer remote.Close()
+
+	removed := api.PinWithOpts(test.Cid3, api.PinOptions{
+		Metadata: map[string]string{MetadataKey: DefaultName},
+	})
+	localOnly := api.PinCid(test.Cid4)
+	mock := &mockCluster{pins: []*api.Pin{removed, localOnly}}
+
+	fed := testFederation(t, remote, mock)
+	defer fed.Shutdown(ctx)
+	fed.config.KeepRemoved = false
+	fed.config.UnpinConfirmations = 2
+
+	err := fed.sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mock.pins) != 3 {
+		t.Fatalf("expected 1 mirrored pin, got %d", len(mock.pins)-2)
+	}
+	pin := mock.pins[2]
+	if !pin.Cid.Equals(test.Cid1) {
+		t.Error("the selected pin should have been mirrored")
+	}
+	if pin.Name != "selected" || pin.Metadata["a"] != "b" {
+		t.Error("the name and metadata of the remote pin should be kept")
+	}
+	if pin.Metadata[MetadataKey] != DefaultName {
+		t.Error("the mirrored pin should be marked")
+	}
+	if pin.ReplicationFactorMin != 1 || pin.ReplicationFactorMax != 1 || pin.Namespace != "" {
+		t.Error("the replication options should come from the configuration")
+	}
+	if !pin.ExpireAt.IsZero() || pin.Size != 0 {
+		t.Error("the expiration and size of the remote pin should not be kept")
+	}
+
+	if len(mock.unpinned) != 0 {
+		t.Error("removed pins should only be unpinned after the confirmations")
+	}
+	err = fed.sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.unpinned) != 1 || !mock.unpinned[0].Cid.Equals(test.Cid3) {
+		t.Error("only the mirrored pin removed from the remote cluster should be unpinned")
+	}
+
+	// Nothing changes on another sync.
+	mock.unpinned = nil
+	mock.pins = mock.pins[1:]
+	err = fed.sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.pins) != 2 || len(mock.unpinned) != 0 {
+		t.Error("the pinset was already mirrored")
+	}
+
+	// Removed pins are kept when asked to.
+	mock.pins = append(mock.pins, removed)
+	fed.config.KeepRemoved = true
+	for i := 0; i < 3; i++ {
+		err = fed.sync(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(mock.unpinned) != 0 {
+		t.Error("removed pins should have been kept")
+	}
+}
+
+func TestLeaderTask(t *testing.T) {
+	ctx := context.Background()
+	remote := testRemote(nil)
+	defer remote.Close()
+
+	fed := testFederation(t, remote, &mockCluster{})
+	defer fed.Shutdown(ctx)
+
+	name, interval, run := fed.LeaderTask()
+	if name != "federation/"+DefaultName || interval != DefaultSyncInterval {
+		t.Errorf("unexpected task: %s every %s", name, interval)
+	}
+	if err := run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	fed.rpcClient = nil
+	if err := run(ctx); err == nil {
+		t.Error("expected an error without RPC client")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	ctx := context.Background()
+	remote := testRemote(nil)
+	defer remote.Close()
+
+	fed := testFederation(t, remote, &mockCluster{})
+	err := fed.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fed.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,13 +4,19 @@ package disk
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/config"
+	"github.com/ipfs/ipfs-cluster/observations"
 
 	logging "github.com/ipfs/go-log"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 
+	"go.opencensus.io/stats"
 	"go.opencensus.io/trace"
 )
 
@@ -31,6 +37,10 @@ var logger = logging.Logger("diskinfo")
 type Informer struct {
 	config    *Config
 	rpcClient *rpc.Client
+
+	// metricTTL is the configured one until updated by Reconfigure.
+	ttlMux    sync.RWMutex
+	metricTTL time.Duration
 }
 
 // NewInformer returns an initialized informer using the given InformerConfig.
@@ -41,7 +51,8 @@ func NewInformer(cfg *Config) (*Informer, error) {
 	}
 
 	return &Informer{
-		config: cfg,
+		config:    cfg,
+		metricTTL: cfg.MetricTTL,
 	}, nil
 }
 
@@ -50,6 +61,25 @@ func (disk *Informer) Name() string {
 	return disk.config.MetricType.String()
 }
 
+// ConfigKey returns the key of the disk informer configuration.
+func (disk *Informer) ConfigKey() string {
+	return configKey
+}
+
+// Reconfigure applies the metric_ttl of the given disk informer
+// configuration. The metric type cannot change while running, as it is the
+// name of the metric.
+func (disk *Informer) Reconfigure(cfg config.ComponentConfig) error {
+	dcfg, ok := cfg.(*Config)
+	if !ok {
+		return errors.New("not a disk informer configuration")
+	}
+	disk.ttlMux.Lock()
+	disk.metricTTL = dcfg.MetricTTL
+	disk.ttlMux.Unlock()
+	return nil
+}
+
 // SetClient provides us with an rpc.Client which allows
 // contacting other components in the cluster.
 func (disk *Informer) SetClient(c *rpc.Client) {
@@ -96,6 +126,12 @@ func (disk *Informer) GetMetric(ctx context.Context) *api.Metric {
 		logger.Error(err)
 		valid = false
 	} else {
+		var free uint64
+		if repoStat.StorageMax > repoStat.RepoSize {
+			free = repoStat.StorageMax - repoStat.RepoSize
+		}
+		stats.Record(ctx, observations.IPFSFreeSpace.M(int64(free)))
+
 		switch disk.config.MetricType {
 		case MetricFreeSpace:
 			metric = repoStat.StorageMax - repoStat.RepoSize
@@ -110,6 +146,8 @@ func (disk *Informer) GetMetric(ctx context.Context) *api.Metric {
 		Valid: valid,
 	}
 
-	m.SetTTL(disk.config.MetricTTL)
+	disk.ttlMux.RLock()
+	m.SetTTL(disk.metricTTL)
+	disk.ttlMux.RUnlock()
 	return m
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/test"
@@ -115,3 +116,30 @@ func TestWithErrors(t *testing.T) {
 		t.Errorf("metric should be invalid")
 	}
 }
+
+func TestReconfigure(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	inf, err := NewInformer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inf.Shutdown(ctx)
+	inf.SetClient(test.NewMockRPCClient(t))
+
+	newCfg := &Config{}
+	newCfg.Default()
+	newCfg.MetricTTL = time.Minute
+	newCfg.MetricType = MetricRepoSize
+	if err := inf.Reconfigure(newCfg); err != nil {
+		t.Fatal(err)
+	}
+	m := inf.GetMetric(ctx)
+	if ttl := m.GetTTL(); ttl < 50*time.Second {
+		t.Error("expected the new metric TTL, got", ttl)
+	}
+	if m.Name != cfg.MetricType.String() {
+		t.Error("the metric type should not change while running")
+	}
+}
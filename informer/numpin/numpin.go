@@ -4,9 +4,13 @@ package numpin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/config"
 
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 
@@ -21,6 +25,10 @@ var MetricName = "numpin"
 type Informer struct {
 	config    *Config
 	rpcClient *rpc.Client
+
+	// metricTTL is the configured one until updated by Reconfigure.
+	ttlMux    sync.RWMutex
+	metricTTL time.Duration
 }
 
 // NewInformer returns an initialized Informer.
@@ -31,10 +39,29 @@ func NewInformer(cfg *Config) (*Informer, error) {
 	}
 
 	return &Informer{
-		config: cfg,
+		config:    cfg,
+		metricTTL: cfg.MetricTTL,
 	}, nil
 }
 
+// ConfigKey returns the key of the numpin informer configuration.
+func (npi *Informer) ConfigKey() string {
+	return configKey
+}
+
+// Reconfigure applies the metric_ttl of the given numpin informer
+// configuration.
+func (npi *Informer) Reconfigure(cfg config.ComponentConfig) error {
+	ncfg, ok := cfg.(*Config)
+	if !ok {
+		return errors.New("not a numpin informer configuration")
+	}
+	npi.ttlMux.Lock()
+	npi.metricTTL = ncfg.MetricTTL
+	npi.ttlMux.Unlock()
+	return nil
+}
+
 // SetClient provides us with an rpc.Client which allows
 // contacting other components in the cluster.
 func (npi *Informer) SetClient(c *rpc.Client) {
@@ -90,6 +117,8 @@ func (npi *Informer) GetMetric(ctx context.Context) *api.Metric {
 		Valid: valid,
 	}
 
-	m.SetTTL(npi.config.MetricTTL)
+	npi.ttlMux.RLock()
+	m.SetTTL(npi.metricTTL)
+	npi.ttlMux.RUnlock()
 	return m
 }
@@ -3,6 +3,7 @@ package numpin
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 
@@ -50,3 +51,27 @@ func Test(t *testing.T) {
 		t.Error("bad metric value")
 	}
 }
+
+func TestReconfigure(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{}
+	cfg.Default()
+	inf, err := NewInformer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inf.SetClient(mockRPCClient(t))
+
+	newCfg := &Config{}
+	newCfg.Default()
+	newCfg.MetricTTL = time.Minute
+	if err := inf.Reconfigure(newCfg); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := inf.GetMetric(ctx).GetTTL(); ttl < 50*time.Second {
+		t.Error("expected the new metric TTL, got", ttl)
+	}
+	if cfg.MetricTTL == time.Minute {
+		t.Error("the original configuration should not be modified")
+	}
+}
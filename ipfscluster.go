@@ -8,11 +8,14 @@ package ipfscluster
 
 import (
 	"context"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/config"
 	"github.com/ipfs/ipfs-cluster/state"
 
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 )
@@ -70,6 +73,34 @@ type API interface {
 	Component
 }
 
+// LeaderTasker is implemented by components with a background task which
+// only needs to run in one peer of the cluster at a time. Cluster schedules
+// it along with its own leader tasks (see runLeaderTasks), instead of the
+// component running it on every peer.
+type LeaderTasker interface {
+	// LeaderTask returns the name of the task, how often it runs and
+	// the function running it.
+	LeaderTask() (string, time.Duration, func(context.Context) error)
+}
+
+// Reconfigurable is implemented by components which can apply the options
+// pushed with PushConfig while running (see pushableFields).
+type Reconfigurable interface {
+	// ConfigKey returns the key of the configuration used by the
+	// component.
+	ConfigKey() string
+	// Reconfigure applies the pushable options of the given
+	// configuration, which has the same type as the component's one.
+	Reconfigure(config.ComponentConfig) error
+}
+
+// LocalPersister is implemented by components which persist data that
+// only concerns this peer. Cluster hands them their own part of the peer
+// datastore (see localDatastore) before setting their RPC client.
+type LocalPersister interface {
+	SetLocalDatastore(ds.Datastore)
+}
+
 // IPFSConnector is a component which allows cluster to interact with
 // an IPFS daemon. This is a base component.
 type IPFSConnector interface {
@@ -90,6 +121,8 @@ type IPFSConnector interface {
 	// RepoStat returns the current repository size and max limit as
 	// provided by "repo stat".
 	RepoStat(context.Context) (*api.IPFSRepoStat, error)
+	// DagSize returns the cumulative size of the DAG under a cid.
+	DagSize(context.Context, cid.Cid) (uint64, error)
 	// RepoGC performs garbage collection sweep on the IPFS repo.
 	RepoGC(context.Context) (*api.RepoGC, error)
 	// Resolve returns a cid given a path.
@@ -98,6 +131,15 @@ type IPFSConnector interface {
 	BlockPut(context.Context, *api.NodeWithMeta) error
 	// BlockGet retrieves the raw data of an IPFS block.
 	BlockGet(context.Context, cid.Cid) ([]byte, error)
+	// Prefetch fetches the DAG under a cid into the IPFS repo without
+	// pinning it.
+	Prefetch(context.Context, cid.Cid) error
+	// NamePublish publishes a cid under the IPNS name of the given key,
+	// which is created when it does not exist, and returns the name.
+	NamePublish(context.Context, *api.IPNSPublish) (string, error)
+	// SetReprovider changes the reprovider settings of the IPFS
+	// daemon. They may only apply once the daemon is restarted.
+	SetReprovider(context.Context, *api.IPFSReprovider) error
 }
 
 // Peered represents a component which needs to be aware of the peers
@@ -121,12 +163,24 @@ type PinTracker interface {
 	Untrack(context.Context, cid.Cid) error
 	// StatusAll returns the list of pins with their local status.
 	StatusAll(context.Context) []*api.PinInfo
+	// StatusAllChan sends the local status of every pin on the given
+	// channel, as the shared state is read, and closes it when done.
+	StatusAllChan(context.Context, chan<- *api.PinInfo) error
 	// Status returns the local status of a given Cid.
 	Status(context.Context, cid.Cid) *api.PinInfo
 	// RecoverAll calls Recover() for all pins tracked.
 	RecoverAll(context.Context) ([]*api.PinInfo, error)
 	// Recover retriggers a Pin/Unpin operation in a Cids with error status.
 	Recover(context.Context, cid.Cid) (*api.PinInfo, error)
+	// PendingOperations returns the number of Pin/Unpin operations which
+	// are queued or in progress.
+	PendingOperations(context.Context) int
+	// Operations returns a description of the operations that the
+	// tracker is currently keeping track of.
+	Operations(context.Context) []*api.TrackerOperation
+	// StatusChanges returns the latest status of the Cids whose status
+	// changed after the given time.
+	StatusChanges(context.Context, time.Time) ([]*api.PinInfo, error)
 }
 
 // Informer provides Metric information from a peer. The metrics produced by
@@ -37,6 +37,7 @@ import (
 	libp2p "github.com/libp2p/go-libp2p"
 	crypto "github.com/libp2p/go-libp2p-core/crypto"
 	host "github.com/libp2p/go-libp2p-core/host"
+	net "github.com/libp2p/go-libp2p-core/network"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
@@ -605,9 +606,19 @@ func TestClustersPeersRetainOrder(t *testing.T) {
 
 	delay()
 
+	// The RTT and the last metric time depend on the peer answering.
+	peers := func(c *Cluster) []*api.ID {
+		ids := c.Peers(ctx)
+		for _, id := range ids {
+			id.LastMetric = 0
+			id.RTT = 0
+		}
+		return ids
+	}
+
 	for i := 0; i < 5; i++ {
 		j := rand.Intn(nClusters) // choose a random cluster peer
-		peers1, err := json.Marshal(clusters[j].Peers(ctx))
+		peers1, err := json.Marshal(peers(clusters[j]))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -615,7 +626,7 @@ func TestClustersPeersRetainOrder(t *testing.T) {
 		waitForLeaderAndMetrics(t, clusters)
 
 		k := rand.Intn(nClusters)
-		peers2, err := json.Marshal(clusters[k].Peers(ctx))
+		peers2, err := json.Marshal(peers(clusters[k]))
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -800,11 +811,17 @@ func TestClustersStatusAll(t *testing.T) {
 		if info[pid].Status != api.TrackerStatusPinned {
 			t.Error("the hash should have been pinned")
 		}
+		if statuses[0].Status != api.GlobalPinStatusSatisfied {
+			t.Error("the pin should be satisfied")
+		}
 
 		status, err := c.Status(ctx, h)
 		if err != nil {
 			t.Error(err)
 		}
+		if status.Status != api.GlobalPinStatusSatisfied {
+			t.Error("the pin should be satisfied")
+		}
 
 		pinfo, ok := status.PeerMap[pid]
 		if !ok {
@@ -1061,6 +1078,86 @@ func TestClustersShutdown(t *testing.T) {
 	runF(t, clusters, f)
 }
 
+func TestClustersKeepAlive(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	waitForLeader(t, clusters)
+
+	c0, c1 := clusters[0], clusters[1]
+	if reconnected := c0.keepAlivePeers(ctx); len(reconnected) != 0 {
+		t.Errorf("no peers should have been reconnected: %v", reconnected)
+	}
+
+	err := c0.host.Network().ClosePeer(c1.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reconnected := c0.keepAlivePeers(ctx)
+	found := false
+	for _, p := range reconnected {
+		found = found || p == c1.id
+	}
+	if !found {
+		t.Errorf("expected %s to be reconnected: %v", c1.id, reconnected)
+	}
+	if c0.host.Network().Connectedness(c1.id) != net.Connected {
+		t.Error("expected the peers to be connected again")
+	}
+}
+
+func TestClustersLeaderTasks(t *testing.T) {
+	ctx := context.Background()
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	waitForLeader(t, clusters)
+	ttlDelay()
+
+	var mu sync.Mutex
+	runs := make(map[peer.ID]int)
+	task := leaderTask{
+		name:     "test",
+		interval: time.Minute,
+	}
+
+	for _, c := range clusters {
+		c := c
+		task.run = func(ctx context.Context) error {
+			mu.Lock()
+			runs[c.id]++
+			mu.Unlock()
+			return nil
+		}
+		c.runLeaderTaskOnce(ctx, task)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected the task to run in one peer, ran in %d", len(runs))
+	}
+
+	var runner *Cluster
+	for _, c := range clusters {
+		if runs[c.id] > 0 {
+			runner = c
+		}
+	}
+	if leader, err := runner.consensus.Leader(ctx); err == nil && leader != runner.id {
+		t.Error("expected the task to run in the consensus leader")
+	}
+
+	runner.config.FollowerMode = true
+	defer func() { runner.config.FollowerMode = false }()
+	if runner.isTaskRunner(ctx, task.name) {
+		t.Error("follower peers should not run leader tasks")
+	}
+
+	task.enabled = func() bool { return false }
+	for _, c := range clusters {
+		if c.runLeaderTaskOnce(ctx, task) {
+			t.Error("disabled tasks should not run")
+		}
+	}
+}
+
 func TestClustersReplicationOverall(t *testing.T) {
 	ctx := context.Background()
 	clusters, mock := createClusters(t)
@@ -1325,6 +1422,76 @@ func TestClustersReplicationFactorInBetween(t *testing.T) {
 	runF(t, clusters, f)
 }
 
+// This test checks that degraded pins are allocated to more peers
+// when they are available.
+func TestClustersTopUpReplicas(t *testing.T) {
+	ctx := context.Background()
+	if nClusters < 5 {
+		t.Skip("Need at least 5 peers")
+	}
+
+	clusters, mock := createClusters(t)
+	defer shutdownClusters(t, clusters, mock)
+	for _, c := range clusters {
+		c.config.ReplicationFactorMin = 1
+		c.config.ReplicationFactorMax = nClusters
+	}
+
+	ttlDelay()
+
+	h := test.Cid1
+	pin, err := clusters[0].Pin(ctx, h, api.PinOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinDelay()
+
+	// Leave the pin allocated to 2 peers only.
+	pin, err = clusters[0].PinGet(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin.Allocations = pin.Allocations[0:2]
+	err = clusters[0].consensus.LogPin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinDelay()
+
+	pin, err = clusters[0].PinGet(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pin.Degraded() {
+		t.Fatal("pin should be degraded")
+	}
+
+	for _, c := range clusters {
+		err := c.topUpDegradedPins(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pinDelay()
+
+	f := func(t *testing.T, c *Cluster) {
+		p, err := c.PinGet(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Allocations) != nClusters {
+			t.Errorf("pin should have been topped up to %d allocations", nClusters)
+		}
+		if p.Degraded() {
+			t.Error("pin should not be degraded")
+		}
+	}
+	runF(t, clusters, f)
+}
+
 // This test checks that we do not pin something for which
 // we cannot reach ReplicationFactorMin
 func TestClustersReplicationFactorMin(t *testing.T) {
@@ -1918,6 +2085,26 @@ func TestClustersDisabledRepinning(t *testing.T) {
 	if numPinned != nClusters-2 {
 		t.Errorf("expected %d replicas for pin, got %d", nClusters-2, numPinned)
 	}
+
+	// Alerts are still handled after the first one
+	k := rand.Intn(nClusters)
+	for k == j || k == killedClusterIndex {
+		k = rand.Intn(nClusters)
+	}
+	t.Logf("Shutting down %s", clusters[k].ID(ctx).ID)
+	clusters[k].Shutdown(ctx)
+
+	waitForLeaderAndMetrics(t, clusters)
+
+	found := false
+	for _, alrt := range clusters[j].Alerts(ctx) {
+		if alrt.Peer == clusters[k].id && alrt.MetricName == pingMetricName {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected an alert for the second peer shut down")
+	}
 }
 
 func TestRepoGC(t *testing.T) {
@@ -2045,18 +2232,19 @@ func TestClusterPinsWithExpiration(t *testing.T) {
 	// wait till expiry time
 	time.Sleep(expireIn)
 
-	// manually call state sync on all peers, so we don't have to wait till
-	// state sync interval
+	// manually run the leader task on all peers, so we don't have to wait
+	// till state sync interval. Only one of them unpins.
 	for _, c := range clusters {
-		err = c.StateSync(ctx)
-		if err != nil {
-			t.Error(err)
+		for _, task := range c.leaderTasks() {
+			if task.name == "unpin_expired" {
+				c.runLeaderTaskOnce(ctx, task)
+			}
 		}
 	}
 
 	pinDelay()
 
-	// state sync should have unpinned expired pin
+	// the leader task should have unpinned expired pin
 	pins, err = cl.Pins(ctx)
 	if err != nil {
 		t.Fatal(err)
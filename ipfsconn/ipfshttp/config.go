@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -25,6 +26,8 @@ const (
 	DefaultUnpinTimeout       = 3 * time.Hour
 	DefaultRepoGCTimeout      = 24 * time.Hour
 	DefaultUnpinDisable       = false
+	DefaultFetchRateLimit     = 0
+	DefaultWatchdogInterval   = 30 * time.Second
 )
 
 // Config is used to initialize a Connector and allows to customize
@@ -54,6 +57,25 @@ type Config struct {
 	// Disables the unpin operation and returns an error.
 	UnpinDisable bool
 
+	// FetchRateLimit is the maximum number of blocks per second that
+	// the IPFS daemon is allowed to fetch for pins which do not set their
+	// own fetch rate limit, except for priority pins. 0 means unlimited.
+	FetchRateLimit uint64
+
+	// WatchdogInterval specifies how often to check that the IPFS daemon
+	// is reachable and has not been restarted. When a restart is
+	// detected, swarm connections are re-established and pins are
+	// re-verified. 0 disables the watchdog.
+	WatchdogInterval time.Duration
+
+	// MFSMirrorRoot is an MFS directory of the IPFS daemon where pinned
+	// items are mirrored, as <root>/<namespace>/<name>, so that they can
+	// be browsed with human names. Pins without a namespace are placed in
+	// the "default" directory and pins without a name are named after
+	// their CID. Mirrored items are removed when unpinned. Empty disables
+	// mirroring.
+	MFSMirrorRoot string
+
 	// Tracing flag used to skip tracing specific paths when not enabled.
 	Tracing bool
 }
@@ -66,6 +88,9 @@ type jsonConfig struct {
 	UnpinTimeout       string `json:"unpin_timeout"`
 	RepoGCTimeout      string `json:"repogc_timeout"`
 	UnpinDisable       bool   `json:"unpin_disable,omitempty"`
+	FetchRateLimit     uint64 `json:"fetch_rate_limit,omitempty"`
+	WatchdogInterval   string `json:"watchdog_interval"`
+	MFSMirrorRoot      string `json:"mfs_mirror_root,omitempty"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -83,6 +108,8 @@ func (cfg *Config) Default() error {
 	cfg.UnpinTimeout = DefaultUnpinTimeout
 	cfg.RepoGCTimeout = DefaultRepoGCTimeout
 	cfg.UnpinDisable = DefaultUnpinDisable
+	cfg.FetchRateLimit = DefaultFetchRateLimit
+	cfg.WatchdogInterval = DefaultWatchdogInterval
 
 	return nil
 }
@@ -131,6 +158,14 @@ func (cfg *Config) Validate() error {
 		err = errors.New("ipfshttp.repogc_timeout invalid")
 	}
 
+	if cfg.WatchdogInterval < 0 {
+		err = errors.New("ipfshttp.watchdog_interval invalid")
+	}
+
+	if root := cfg.MFSMirrorRoot; root != "" && (!path.IsAbs(root) || path.Clean(root) == "/") {
+		err = errors.New("ipfshttp.mfs_mirror_root must be an absolute path other than /")
+	}
+
 	return err
 
 }
@@ -157,6 +192,8 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 
 	cfg.NodeAddr = nodeAddr
 	cfg.UnpinDisable = jcfg.UnpinDisable
+	cfg.FetchRateLimit = jcfg.FetchRateLimit
+	cfg.MFSMirrorRoot = jcfg.MFSMirrorRoot
 
 	err = config.ParseDurations(
 		"ipfshttp",
@@ -165,6 +202,7 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 		&config.DurationOpt{Duration: jcfg.PinTimeout, Dst: &cfg.PinTimeout, Name: "pin_timeout"},
 		&config.DurationOpt{Duration: jcfg.UnpinTimeout, Dst: &cfg.UnpinTimeout, Name: "unpin_timeout"},
 		&config.DurationOpt{Duration: jcfg.RepoGCTimeout, Dst: &cfg.RepoGCTimeout, Name: "repogc_timeout"},
+		&config.DurationOpt{Duration: jcfg.WatchdogInterval, Dst: &cfg.WatchdogInterval, Name: "watchdog_interval"},
 	)
 	if err != nil {
 		return err
@@ -201,7 +239,10 @@ func (cfg *Config) toJSONConfig() (jcfg *jsonConfig, err error) {
 	jcfg.PinTimeout = cfg.PinTimeout.String()
 	jcfg.UnpinTimeout = cfg.UnpinTimeout.String()
 	jcfg.RepoGCTimeout = cfg.RepoGCTimeout.String()
+	jcfg.WatchdogInterval = cfg.WatchdogInterval.String()
 	jcfg.UnpinDisable = cfg.UnpinDisable
+	jcfg.FetchRateLimit = cfg.FetchRateLimit
+	jcfg.MFSMirrorRoot = cfg.MFSMirrorRoot
 
 	return
 }
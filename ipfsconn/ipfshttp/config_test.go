@@ -14,7 +14,8 @@ var cfgJSON = []byte(`
 	"ipfs_request_timeout": "5m0s",
 	"pin_timeout": "24h",
 	"unpin_timeout": "3h",
-	"repogc_timeout": "24h"
+	"repogc_timeout": "24h",
+	"watchdog_interval": "30s"
 }
 `)
 
@@ -60,6 +61,18 @@ func TestDefault(t *testing.T) {
 	if cfg.Validate() == nil {
 		t.Fatal("expected error validating")
 	}
+
+	cfg.Default()
+	cfg.MFSMirrorRoot = "/cluster"
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+	for _, root := range []string{"cluster", "/", "//"} {
+		cfg.MFSMirrorRoot = root
+		if cfg.Validate() == nil {
+			t.Errorf("expected error validating mfs_mirror_root %q", root)
+		}
+	}
 }
 
 func TestApplyEnvVar(t *testing.T) {
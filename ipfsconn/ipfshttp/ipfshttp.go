@@ -11,12 +11,14 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/faults"
 	"github.com/ipfs/ipfs-cluster/observations"
 
 	cid "github.com/ipfs/go-cid"
@@ -73,6 +75,13 @@ type Connector struct {
 	wg           sync.WaitGroup
 }
 
+// watchdogState keeps what the watchdog knows about the IPFS daemon
+// between checks.
+type watchdogState struct {
+	id   peer.ID // last seen ID of the daemon
+	down bool    // whether the daemon was unreachable in the last check
+}
+
 type ipfsError struct {
 	Message string
 }
@@ -86,8 +95,9 @@ type ipfsPinLsResp struct {
 }
 
 type ipfsIDResp struct {
-	ID        string
-	Addresses []string
+	ID           string
+	Addresses    []string
+	AgentVersion string
 }
 
 type ipfsResolveResp struct {
@@ -99,6 +109,14 @@ type ipfsRepoGCResp struct {
 	Error string
 }
 
+type ipfsObjectStatResp struct {
+	CumulativeSize uint64
+}
+
+type ipfsBlockStatResp struct {
+	Size uint64
+}
+
 type ipfsRefsResp struct {
 	Ref string
 	Err string
@@ -118,10 +136,38 @@ type ipfsBlockPutResp struct {
 	Size int
 }
 
+type ipfsFilesLsResp struct {
+	Entries []ipfsFilesEntry
+}
+
+type ipfsFilesEntry struct {
+	Name string
+	Type int
+	Hash string
+}
+
+type ipfsFilesStatResp struct {
+	Hash string
+}
+
 type ipfsPeer struct {
 	Peer string
 }
 
+type ipfsKeyListResp struct {
+	Keys []ipfsKey
+}
+
+type ipfsKey struct {
+	Name string
+	ID   string `json:"Id"`
+}
+
+type ipfsNamePublishResp struct {
+	Name  string
+	Value string
+}
+
 type ipfsStream struct {
 	Protocol string
 }
@@ -177,7 +223,7 @@ func NewConnector(cfg *Config) (*Connector, error) {
 	return ipfs, nil
 }
 
-// connects all ipfs daemons when
+// connects all ipfs daemons and starts the watchdog when
 // we receive the rpcReady signal.
 func (ipfs *Connector) run() {
 	<-ipfs.rpcReady
@@ -187,6 +233,18 @@ func (ipfs *Connector) run() {
 	ipfs.shutdownLock.Lock()
 	defer ipfs.shutdownLock.Unlock()
 
+	if ipfs.shutdown {
+		return
+	}
+
+	if ipfs.config.WatchdogInterval > 0 {
+		ipfs.wg.Add(1)
+		go func() {
+			defer ipfs.wg.Done()
+			ipfs.watchdog()
+		}()
+	}
+
 	if ipfs.config.ConnectSwarmsDelay == 0 {
 		return
 	}
@@ -212,6 +270,84 @@ func (ipfs *Connector) run() {
 	}()
 }
 
+// watchdog regularly checks the IPFS daemon until shutdown.
+func (ipfs *Connector) watchdog() {
+	ticker := time.NewTicker(ipfs.config.WatchdogInterval)
+	defer ticker.Stop()
+
+	st := &watchdogState{}
+	for {
+		select {
+		case <-ticker.C:
+			if ipfs.watchdogCheck(ipfs.ctx, st) {
+				ipfs.ipfsRestarted(ipfs.ctx)
+			}
+		case <-ipfs.ctx.Done():
+			return
+		}
+	}
+}
+
+// watchdogCheck requests the ID of the IPFS daemon and returns true when
+// the daemon has come back after being unreachable or its ID has changed
+// since the last check, that is, when it has been restarted.
+func (ipfs *Connector) watchdogCheck(ctx context.Context, st *watchdogState) bool {
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.WatchdogInterval)
+	defer cancel()
+
+	id, err := ipfs.ID(ctx)
+	if err != nil {
+		if ctx.Err() != nil && ipfs.ctx.Err() != nil {
+			return false // shutting down
+		}
+		if !st.down {
+			logger.Errorf("the IPFS daemon is unreachable: %s", err)
+			stats.Record(ctx, observations.IPFSDown.M(1))
+		}
+		st.down = true
+		return false
+	}
+
+	restarted := st.down
+	if st.down {
+		logger.Warning("the IPFS daemon is reachable again")
+		stats.Record(ctx, observations.IPFSDown.M(0))
+	}
+	if st.id != "" && st.id != id.ID {
+		logger.Warningf("the IPFS daemon ID changed from %s to %s", st.id, id.ID)
+		restarted = true
+	}
+	st.id = id.ID
+	st.down = false
+	return restarted
+}
+
+// ipfsRestarted re-connects the IPFS daemon to those of the other cluster
+// peers and re-verifies the local pins, since the daemon may have lost
+// them while it was down.
+func (ipfs *Connector) ipfsRestarted(ctx context.Context) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/ipfsRestarted")
+	defer span.End()
+
+	logger.Warning("IPFS daemon restart detected: re-connecting swarms and re-verifying pins")
+	stats.Record(ctx, observations.IPFSRestarts.M(1))
+
+	ipfs.ConnectSwarms(ctx)
+
+	var pinfos []*api.PinInfo
+	err := ipfs.rpcClient.CallContext(
+		ctx,
+		"",
+		"Cluster",
+		"RecoverAllLocal",
+		struct{}{},
+		&pinfos,
+	)
+	if err != nil {
+		logger.Errorf("error re-verifying pins after IPFS restart: %s", err)
+	}
+}
+
 // SetClient makes the component ready to perform RPC
 // requests.
 func (ipfs *Connector) SetClient(c *rpc.Client) {
@@ -272,7 +408,8 @@ func (ipfs *Connector) ID(ctx context.Context) (*api.IPFSID, error) {
 	}
 
 	id := &api.IPFSID{
-		ID: pID,
+		ID:           pID,
+		AgentVersion: res.AgentVersion,
 	}
 
 	mAddrs := make([]api.Multiaddr, len(res.Addresses), len(res.Addresses))
@@ -308,6 +445,15 @@ func (ipfs *Connector) Pin(ctx context.Context, pin *api.Pin) error {
 	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/Pin")
 	defer span.End()
 
+	err := ipfs.pin(ctx, pin)
+	if err != nil {
+		return err
+	}
+	ipfs.mirrorPin(ctx, pin)
+	return nil
+}
+
+func (ipfs *Connector) pin(ctx context.Context, pin *api.Pin) error {
 	hash := pin.Cid
 	maxDepth := pin.MaxDepth
 
@@ -339,6 +485,18 @@ func (ipfs *Connector) Pin(ctx context.Context, pin *api.Pin) error {
 		}
 	}
 
+	ipfs.connectOrigins(ctx, pin.Origins)
+
+	// Fetch the content at a limited rate first, so that the pin
+	// request below finds it locally.
+	if limit := ipfs.fetchRateLimit(pin); limit > 0 && maxDepth != 0 {
+		logger.Debugf("fetching %s at most at %d blocks per second", hash, limit)
+		err := ipfs.fetchRefs(ctx, hash, maxDepth, limit)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Pin request and timeout if there is no progress
 	outPins := make(chan int)
 	go func() {
@@ -380,6 +538,42 @@ func (ipfs *Connector) Pin(ctx context.Context, pin *api.Pin) error {
 	return nil
 }
 
+// connectOrigins asks the IPFS daemon to connect to the given origins of a
+// pin, so that it fetches the content from them directly. This is a best
+// effort attempt: pinning proceeds even when the connections fail.
+func (ipfs *Connector) connectOrigins(ctx context.Context, origins []api.Multiaddr) {
+	if len(origins) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+	for _, addr := range origins {
+		err := ipfs.postDiscardBodyCtx(
+			ctx,
+			fmt.Sprintf("swarm/connect?arg=%s", url.QueryEscape(addr.String())),
+		)
+		if err != nil {
+			logger.Warningf("error connecting to pin origin %s: %s", addr, err)
+			continue
+		}
+		logger.Debugf("ipfs successfully connected to pin origin %s", addr)
+	}
+}
+
+// fetchRateLimit returns the fetch rate limit which applies to a pin: its
+// own, or the configured one for pins without one, unless they are priority
+// pins.
+func (ipfs *Connector) fetchRateLimit(pin *api.Pin) uint64 {
+	if pin.FetchRateLimit > 0 {
+		return pin.FetchRateLimit
+	}
+	if pin.Priority {
+		return 0
+	}
+	return ipfs.config.FetchRateLimit
+}
+
 // pinProgress pins an item and sends fetched node's progress on a
 // channel. Blocks until done or error. pinProgress will always close the out
 // channel.  pinProgress will not block on sending to the channel if it is full.
@@ -470,10 +664,170 @@ func (ipfs *Connector) Unpin(ctx context.Context, hash cid.Cid) error {
 		stats.Record(ctx, observations.Pins.M(-1))
 	}
 
+	ipfs.unmirrorPin(ctx, hash)
 	logger.Debug("IPFS object is already unpinned: ", hash)
 	return nil
 }
 
+// mfsMirrorDefaultDir is the directory of the MFS mirror where pins without
+// a namespace are placed.
+const mfsMirrorDefaultDir = "default"
+
+// mfsPathComponent makes s usable as a single component of an MFS path.
+func mfsPathComponent(s string) string {
+	s = strings.ReplaceAll(s, "/", "_")
+	if s == "." || s == ".." {
+		s = strings.Repeat("_", len(s))
+	}
+	return s
+}
+
+// mfsMirrorPath returns the MFS directory where a pin is mirrored and the
+// path of the mirrored item in it.
+func (ipfs *Connector) mfsMirrorPath(pin *api.Pin) (string, string) {
+	ns := pin.Namespace
+	if ns == "" {
+		ns = mfsMirrorDefaultDir
+	}
+	name := pin.Name
+	if name == "" {
+		name = pin.Cid.String()
+	}
+	dir := path.Join(ipfs.config.MFSMirrorRoot, mfsPathComponent(ns))
+	return dir, path.Join(dir, mfsPathComponent(name))
+}
+
+// mirrorPin copies a pinned item into the MFS mirror (see
+// Config.MFSMirrorRoot). Mirroring is a best effort: errors are logged and
+// do not affect the pin.
+func (ipfs *Connector) mirrorPin(ctx context.Context, pin *api.Pin) {
+	if ipfs.config.MFSMirrorRoot == "" || pin.Type != api.DataType {
+		return
+	}
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/mirrorPin")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	dir, p := ipfs.mfsMirrorPath(pin)
+	err := ipfs.postDiscardBodyCtx(
+		ctx,
+		fmt.Sprintf("files/mkdir?arg=%s&parents=true", url.QueryEscape(dir)),
+	)
+	if err != nil {
+		logger.Warningf("error creating MFS mirror directory %s: %s", dir, err)
+		return
+	}
+
+	// Pins are re-sent to IPFS (i.e. on recover), in which case the item
+	// is already mirrored.
+	if ci, err := ipfs.mfsStat(ctx, p); err == nil {
+		if !ci.Equals(pin.Cid) {
+			logger.Warningf("cannot mirror %s: MFS path %s is used by %s", pin.Cid, p, ci)
+		}
+		return
+	}
+
+	err = ipfs.postDiscardBodyCtx(
+		ctx,
+		fmt.Sprintf(
+			"files/cp?arg=%s&arg=%s",
+			url.QueryEscape("/ipfs/"+pin.Cid.String()),
+			url.QueryEscape(p),
+		),
+	)
+	if err != nil {
+		logger.Warningf("error mirroring %s to MFS path %s: %s", pin.Cid, p, err)
+		return
+	}
+	logger.Debugf("mirrored %s to MFS path %s", pin.Cid, p)
+}
+
+// unmirrorPin removes the items of the MFS mirror which point to the given
+// CID. Since the name and namespace of unpinned items are not known, it
+// looks through all the mirrored items.
+func (ipfs *Connector) unmirrorPin(ctx context.Context, hash cid.Cid) {
+	if ipfs.config.MFSMirrorRoot == "" {
+		return
+	}
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/unmirrorPin")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	root := ipfs.config.MFSMirrorRoot
+	dirs, err := ipfs.mfsLs(ctx, root)
+	if err != nil {
+		logger.Debugf("error listing MFS mirror %s: %s", root, err)
+		return
+	}
+
+	for _, dir := range dirs {
+		if dir.Type != 1 { // not a directory
+			continue
+		}
+		dirPath := path.Join(root, dir.Name)
+		entries, err := ipfs.mfsLs(ctx, dirPath)
+		if err != nil {
+			logger.Warningf("error listing MFS mirror directory %s: %s", dirPath, err)
+			continue
+		}
+		for _, e := range entries {
+			ci, err := cid.Decode(e.Hash)
+			if err != nil || !ci.Equals(hash) {
+				continue
+			}
+			p := path.Join(dirPath, e.Name)
+			err = ipfs.postDiscardBodyCtx(
+				ctx,
+				fmt.Sprintf("files/rm?arg=%s&recursive=true", url.QueryEscape(p)),
+			)
+			if err != nil {
+				logger.Warningf("error removing MFS path %s: %s", p, err)
+				continue
+			}
+			logger.Debugf("removed %s from MFS path %s", hash, p)
+		}
+	}
+}
+
+// mfsLs lists the entries of an MFS directory.
+func (ipfs *Connector) mfsLs(ctx context.Context, dir string) ([]ipfsFilesEntry, error) {
+	body, err := ipfs.postCtx(
+		ctx,
+		fmt.Sprintf("files/ls?arg=%s&long=true", url.QueryEscape(dir)),
+		"",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var resp ipfsFilesLsResp
+	err = json.Unmarshal(body, &resp)
+	return resp.Entries, err
+}
+
+// mfsStat returns the CID of the item at the given MFS path.
+func (ipfs *Connector) mfsStat(ctx context.Context, p string) (cid.Cid, error) {
+	body, err := ipfs.postCtx(
+		ctx,
+		fmt.Sprintf("files/stat?arg=%s", url.QueryEscape(p)),
+		"",
+		nil,
+	)
+	if err != nil {
+		return cid.Undef, err
+	}
+	var resp ipfsFilesStatResp
+	err = json.Unmarshal(body, &resp)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.Decode(resp.Hash)
+}
+
 // PinLs performs a "pin ls --type typeFilter" request against the configured
 // IPFS daemon and returns a map of cid strings and their status.
 func (ipfs *Connector) PinLs(ctx context.Context, typeFilter string) (map[string]api.IPFSPinStatus, error) {
@@ -560,6 +914,9 @@ func (ipfs *Connector) PinLsCid(ctx context.Context, hash cid.Cid) (api.IPFSPinS
 
 func (ipfs *Connector) doPostCtx(ctx context.Context, client *http.Client, apiURL, path string, contentType string, postBody io.Reader) (*http.Response, error) {
 	logger.Debugf("posting %s", path)
+	if err := faults.ConnectorCall(); err != nil {
+		return nil, fmt.Errorf("IPFS request unsuccessful (%s): %s", path, err)
+	}
 	urlstr := fmt.Sprintf("%s/%s", apiURL, path)
 
 	req, err := http.NewRequest("POST", urlstr, postBody)
@@ -768,6 +1125,45 @@ func (ipfs *Connector) RepoStat(ctx context.Context) (*api.IPFSRepoStat, error)
 	return &stats, nil
 }
 
+// DagSize returns the cumulative size of the DAG under the given cid, as
+// reported by "object stat". Blocks which are not protobuf nodes (i.e. raw
+// leaves) are not supported by it, so "block stat" is used for them.
+func (ipfs *Connector) DagSize(ctx context.Context, c cid.Cid) (uint64, error) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/DagSize")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	if c.Type() != cid.DagProtobuf {
+		res, err := ipfs.postCtx(ctx, "block/stat?arg="+c.String(), "", nil)
+		if err != nil {
+			logger.Error(err)
+			return 0, err
+		}
+		var stat ipfsBlockStatResp
+		err = json.Unmarshal(res, &stat)
+		if err != nil {
+			logger.Error(err)
+			return 0, err
+		}
+		return stat.Size, nil
+	}
+
+	res, err := ipfs.postCtx(ctx, "object/stat?arg="+c.String(), "", nil)
+	if err != nil {
+		logger.Error(err)
+		return 0, err
+	}
+	var stat ipfsObjectStatResp
+	err = json.Unmarshal(res, &stat)
+	if err != nil {
+		logger.Error(err)
+		return 0, err
+	}
+	return stat.CumulativeSize, nil
+}
+
 // RepoGC performs a garbage collection sweep on the cluster peer's IPFS repo.
 func (ipfs *Connector) RepoGC(ctx context.Context) (*api.RepoGC, error) {
 	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/RepoGC")
@@ -947,26 +1343,188 @@ func (ipfs *Connector) BlockGet(ctx context.Context, c cid.Cid) ([]byte, error)
 	return ipfs.postCtx(ctx, url, "", nil)
 }
 
-// // FetchRefs asks IPFS to download blocks recursively to the given depth.
-// // It discards the response, but waits until it completes.
-// func (ipfs *Connector) FetchRefs(ctx context.Context, c cid.Cid, maxDepth int) error {
-// 	ctx, cancel := context.WithTimeout(ipfs.ctx, ipfs.config.PinTimeout)
-// 	defer cancel()
-
-// 	q := url.Values{}
-// 	q.Set("recursive", "true")
-// 	q.Set("unique", "false") // same memory on IPFS side
-// 	q.Set("max-depth", fmt.Sprintf("%d", maxDepth))
-// 	q.Set("arg", c.String())
-
-// 	url := fmt.Sprintf("refs?%s", q.Encode())
-// 	err := ipfs.postDiscardBodyCtx(ctx, url)
-// 	if err != nil {
-// 		return err
-// 	}
-// 	logger.Debugf("refs for %s successfully fetched", c)
-// 	return nil
-// }
+// Prefetch asks IPFS to fetch all the blocks of the DAG under the given
+// Cid into its blockstore, without pinning it. It waits until all the
+// blocks have been fetched.
+func (ipfs *Connector) Prefetch(ctx context.Context, c cid.Cid) error {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/Prefetch")
+	defer span.End()
+
+	err := ipfs.fetchRefs(ctx, c, -1, 0)
+	if err != nil {
+		return err
+	}
+	logger.Debugf("refs for %s successfully fetched", c)
+	return nil
+}
+
+// NamePublish publishes the given Cid under the IPNS name of the given key
+// and returns the name. Keys other than "self" are generated in the IPFS
+// daemon when they do not exist yet.
+func (ipfs *Connector) NamePublish(ctx context.Context, pub *api.IPNSPublish) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/NamePublish")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	key := pub.Key
+	if key == "" {
+		key = "self"
+	}
+	if key != "self" {
+		if err := ipfs.ensureKey(ctx, key); err != nil {
+			return "", err
+		}
+	}
+
+	q := make(url.Values, 4)
+	q.Set("arg", "/ipfs/"+pub.Cid.String())
+	q.Set("key", key)
+	q.Set("allow-offline", "true")
+	if pub.Lifetime > 0 {
+		q.Set("lifetime", pub.Lifetime.String())
+	}
+	res, err := ipfs.postCtx(ctx, "name/publish?"+q.Encode(), "", nil)
+	if err != nil {
+		logger.Error(err)
+		return "", err
+	}
+
+	var resp ipfsNamePublishResp
+	err = json.Unmarshal(res, &resp)
+	if err != nil {
+		logger.Error(err)
+		return "", err
+	}
+	logger.Debugf("published %s under /ipns/%s", pub.Cid, resp.Name)
+	return resp.Name, nil
+}
+
+// SetReprovider sets the Reprovider.Strategy and Reprovider.Interval
+// options in the configuration of the IPFS daemon. The daemon only uses
+// them after being restarted.
+func (ipfs *Connector) SetReprovider(ctx context.Context, rp *api.IPFSReprovider) error {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/SetReprovider")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, ipfs.config.IPFSRequestTimeout)
+	defer cancel()
+
+	interval := "0"
+	if rp.Interval > 0 {
+		interval = rp.Interval.String()
+	}
+	for _, kv := range [][2]string{
+		{"Reprovider.Strategy", rp.Strategy},
+		{"Reprovider.Interval", interval},
+	} {
+		q := make(url.Values, 2)
+		q.Add("arg", kv[0])
+		q.Add("arg", kv[1])
+		_, err := ipfs.postCtx(ctx, "config?"+q.Encode(), "", nil)
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+	}
+	logger.Infof("IPFS reprovider set to strategy %s and interval %s", rp.Strategy, interval)
+	return nil
+}
+
+// ensureKey generates a key with the given name in the IPFS daemon unless
+// it exists already.
+func (ipfs *Connector) ensureKey(ctx context.Context, name string) error {
+	res, err := ipfs.postCtx(ctx, "key/list", "", nil)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	var keys ipfsKeyListResp
+	err = json.Unmarshal(res, &keys)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	for _, k := range keys.Keys {
+		if k.Name == name {
+			return nil
+		}
+	}
+
+	logger.Infof("generating IPNS key %s in the IPFS daemon", name)
+	_, err = ipfs.postCtx(ctx, "key/gen?arg="+url.QueryEscape(name)+"&type=ed25519", "", nil)
+	if err != nil {
+		logger.Error(err)
+	}
+	return err
+}
+
+// fetchRefs asks IPFS to fetch the blocks of a DAG to the given depth by
+// listing its refs. When rateLimit is larger than 0, the refs are read at
+// most at that many per second. As IPFS does not walk the DAG further while
+// the response is not read, this limits the rate at which it fetches the
+// blocks. The request is cancelled when no ref has been received for
+// PinTimeout.
+func (ipfs *Connector) fetchRefs(ctx context.Context, c cid.Cid, maxDepth int, rateLimit uint64) error {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/ipfshttp/fetchRefs")
+	defer span.End()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stalled := time.AfterFunc(ipfs.config.PinTimeout, cancel)
+	defer stalled.Stop()
+
+	var throttle <-chan time.Time
+	if rateLimit > 0 && rateLimit < uint64(time.Second) {
+		ticker := time.NewTicker(time.Second / time.Duration(rateLimit))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	q := url.Values{}
+	q.Set("recursive", "true")
+	q.Set("unique", "false") // same memory on IPFS side
+	q.Set("max-depth", strconv.Itoa(maxDepth))
+	q.Set("arg", c.String())
+
+	path := fmt.Sprintf("refs?%s", q.Encode())
+	res, err := ipfs.doPostCtx(ctx, ipfs.client, ipfs.apiURL(), path, "", nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	_, err = checkResponse(path, res)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(res.Body)
+	for {
+		var ref ipfsRefsResp
+		err := dec.Decode(&ref)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if ref.Err != "" {
+			return errors.New(ref.Err)
+		}
+		stalled.Reset(ipfs.config.PinTimeout)
+
+		if throttle != nil {
+			select {
+			case <-throttle:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return ctx.Err()
+}
 
 // Returns true every updateMetricsMod-th time that we
 // call this function.
@@ -59,6 +59,9 @@ func TestIPFSID(t *testing.T) {
 	if len(id.Addresses) != 2 {
 		t.Error("expected 2 address")
 	}
+	if id.AgentVersion != test.IpfsAgentVersion {
+		t.Error("expected the agent version of the daemon")
+	}
 	if id.Error != "" {
 		t.Error("expected no error")
 	}
@@ -69,6 +72,106 @@ func TestIPFSID(t *testing.T) {
 	}
 }
 
+func TestWatchdogCheck(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	st := &watchdogState{}
+	if ipfs.watchdogCheck(ctx, st) {
+		t.Error("first check should not detect a restart")
+	}
+	if st.id != test.PeerID1 {
+		t.Error("should have recorded the IPFS daemon ID")
+	}
+
+	st.id = test.PeerID2
+	if !ipfs.watchdogCheck(ctx, st) {
+		t.Error("should have detected a restart after an ID change")
+	}
+
+	nodeAddr := ipfs.nodeAddr
+	ipfs.nodeAddr = "127.0.0.1:1" // nothing listens here
+	if ipfs.watchdogCheck(ctx, st) {
+		t.Error("should not detect a restart while IPFS is down")
+	}
+	if !st.down {
+		t.Error("should have marked IPFS as down")
+	}
+
+	ipfs.nodeAddr = nodeAddr
+	if !ipfs.watchdogCheck(ctx, st) {
+		t.Error("should have detected a restart when IPFS came back")
+	}
+	if st.down {
+		t.Error("should have marked IPFS as up")
+	}
+
+	// does not fail with the mock rpc client
+	ipfs.ipfsRestarted(ctx)
+}
+
+func TestPinFetchRateLimit(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	pin := api.PinCid(test.Cid1)
+	if ipfs.fetchRateLimit(pin) != 0 {
+		t.Error("expected no fetch rate limit by default")
+	}
+
+	ipfs.config.FetchRateLimit = 10
+	if ipfs.fetchRateLimit(pin) != 10 {
+		t.Error("expected the configured fetch rate limit")
+	}
+	pin.Priority = true
+	if ipfs.fetchRateLimit(pin) != 0 {
+		t.Error("the configured limit should not apply to priority pins")
+	}
+	pin.FetchRateLimit = 2
+	if ipfs.fetchRateLimit(pin) != 2 {
+		t.Error("expected the pin fetch rate limit")
+	}
+
+	start := time.Now()
+	err := ipfs.Pin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 400*time.Millisecond {
+		t.Error("the fetch should have been throttled")
+	}
+	pinSt, err := ipfs.PinLsCid(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pinSt.IsPinned(-1) {
+		t.Error("cid should have been pinned")
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	err := ipfs.Prefetch(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	err = ipfs.Prefetch(tctx, test.SlowCid1)
+	if err == nil {
+		t.Error("expected a timeout error")
+	}
+}
+
 func TestPin(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
@@ -102,6 +205,31 @@ func TestPin(t *testing.T) {
 	}
 }
 
+func TestPinOrigins(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	origin, err := api.NewMultiaddr("/ip4/1.2.3.4/tcp/4001/p2p/" + test.PeerID1.Pretty())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin := api.PinCid(test.Cid1)
+	pin.Origins = []api.Multiaddr{origin}
+	err = ipfs.Pin(ctx, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mock.GetCount("swarm/connect") != 1 {
+		t.Error("swarm/connect should have been called once")
+	}
+	if mock.GetCount("pin/add") != 1 {
+		t.Error("pin/add should have been called once")
+	}
+}
+
 func TestPinUpdate(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
@@ -153,6 +281,44 @@ func TestIPFSUnpin(t *testing.T) {
 	}
 }
 
+func TestIPFSMFSMirror(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+	ipfs.config.MFSMirrorRoot = "/cluster"
+
+	named := api.PinWithOpts(test.Cid1, api.PinOptions{
+		Name:      "dataset",
+		Namespace: "team/a",
+	})
+	unnamed := api.PinCid(test.Cid2)
+	for _, pin := range []*api.Pin{named, unnamed, named} {
+		if err := ipfs.Pin(ctx, pin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	namedPath := "/cluster/team_a/dataset"
+	unnamedPath := "/cluster/default/" + test.Cid2.String()
+	if c, ok := mock.MFSEntry(namedPath); !ok || c != test.Cid1.String() {
+		t.Errorf("expected %s in %s", test.Cid1, namedPath)
+	}
+	if c, ok := mock.MFSEntry(unnamedPath); !ok || c != test.Cid2.String() {
+		t.Errorf("expected %s in %s", test.Cid2, unnamedPath)
+	}
+
+	if err := ipfs.Unpin(ctx, test.Cid1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mock.MFSEntry(namedPath); ok {
+		t.Error("unpinned items should be removed from the mirror")
+	}
+	if _, ok := mock.MFSEntry(unnamedPath); !ok {
+		t.Error("pinned items should stay in the mirror")
+	}
+}
+
 func TestIPFSUnpinDisabled(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
@@ -358,6 +524,93 @@ func TestRepoStat(t *testing.T) {
 	}
 }
 
+func TestDagSize(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	// See the ipfs mock implementation
+	size, err := ipfs.DagSize(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 1000 {
+		t.Error("expected 1000 bytes of size")
+	}
+
+	// Raw blocks
+	err = ipfs.BlockPut(ctx, &api.NodeWithMeta{
+		Data: []byte(test.Cid4Data),
+		Cid:  test.Cid4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err = ipfs.DagSize(ctx, test.Cid4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != uint64(len(test.Cid4Data)) {
+		t.Errorf("expected %d bytes of size", len(test.Cid4Data))
+	}
+}
+
+func TestNamePublish(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	name, err := ipfs.NamePublish(ctx, &api.IPNSPublish{Cid: test.Cid1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != test.PeerID1.Pretty() {
+		t.Error("expected publishing under the self key by default")
+	}
+
+	// The key is generated the first time.
+	name, err = ipfs.NamePublish(ctx, &api.IPNSPublish{Cid: test.Cid2, Key: "pinset"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name2, err := ipfs.NamePublish(ctx, &api.IPNSPublish{Cid: test.Cid3, Key: "pinset"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != name2 || name == test.PeerID1.Pretty() {
+		t.Error("expected the same name for the same key")
+	}
+	if mock.IPNSValue(name) != "/ipfs/"+test.Cid3.String() {
+		t.Error("the last cid was not published")
+	}
+}
+
+func TestSetReprovider(t *testing.T) {
+	ctx := context.Background()
+	ipfs, mock := testIPFSConnector(t)
+	defer mock.Close()
+	defer ipfs.Shutdown(ctx)
+
+	err := ipfs.SetReprovider(ctx, &api.IPFSReprovider{Strategy: "pinned", Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mock.ConfigValue("Reprovider.Strategy") != "pinned" ||
+		mock.ConfigValue("Reprovider.Interval") != "1h0m0s" {
+		t.Error("the reprovider options were not set")
+	}
+
+	err = ipfs.SetReprovider(ctx, &api.IPFSReprovider{Strategy: "pinned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mock.ConfigValue("Reprovider.Interval") != "0" {
+		t.Error("the reprovider should be disabled")
+	}
+}
+
 func TestResolve(t *testing.T) {
 	ctx := context.Background()
 	ipfs, mock := testIPFSConnector(t)
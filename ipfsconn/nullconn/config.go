@@ -0,0 +1,170 @@
+package nullconn
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+
+	"github.com/ipfs/ipfs-cluster/config"
+)
+
+const configKey = "nullconn"
+const envConfigKey = "cluster_nullconn"
+
+// Default values for Config.
+const (
+	DefaultEnable           = false
+	DefaultPinLatency       = 0
+	DefaultUnpinLatency     = 0
+	DefaultLatencyJitter    = 0
+	DefaultPinFailureRate   = 0
+	DefaultUnpinFailureRate = 0
+	DefaultPinSize          = 1024 * 1024               // 1 MiB
+	DefaultStorageMax       = 1024 * 1024 * 1024 * 1024 // 1 TiB
+)
+
+// Config allows to configure the null IPFS connector, which simulates an
+// IPFS daemon in memory. It implements the config.ComponentConfig
+// interface.
+type Config struct {
+	config.Saver
+
+	// Use the null connector instead of the IPFS HTTP connector. Meant
+	// for staging and load testing only: nothing is ever pinned.
+	Enable bool
+
+	// Time taken by every pin and unpin operation.
+	PinLatency   time.Duration
+	UnpinLatency time.Duration
+
+	// A random delay up to this value is added to every operation.
+	LatencyJitter time.Duration
+
+	// Fraction (0 to 1) of the pin and unpin operations which fail.
+	PinFailureRate   float64
+	UnpinFailureRate float64
+
+	// Size of every pinned item, as reported by DagSize and used to
+	// compute the repository size.
+	PinSize uint64
+
+	// Maximum repository size, as reported by RepoStat.
+	StorageMax uint64
+}
+
+type jsonConfig struct {
+	Enable           bool    `json:"enable"`
+	PinLatency       string  `json:"pin_latency"`
+	UnpinLatency     string  `json:"unpin_latency"`
+	LatencyJitter    string  `json:"latency_jitter"`
+	PinFailureRate   float64 `json:"pin_failure_rate"`
+	UnpinFailureRate float64 `json:"unpin_failure_rate"`
+	PinSize          uint64  `json:"pin_size"`
+	StorageMax       uint64  `json:"storage_max"`
+}
+
+// ConfigKey provides a human-friendly identifier for this type of Config.
+func (cfg *Config) ConfigKey() string {
+	return configKey
+}
+
+// Default sets the fields of this Config to sensible values.
+func (cfg *Config) Default() error {
+	cfg.Enable = DefaultEnable
+	cfg.PinLatency = DefaultPinLatency
+	cfg.UnpinLatency = DefaultUnpinLatency
+	cfg.LatencyJitter = DefaultLatencyJitter
+	cfg.PinFailureRate = DefaultPinFailureRate
+	cfg.UnpinFailureRate = DefaultUnpinFailureRate
+	cfg.PinSize = DefaultPinSize
+	cfg.StorageMax = DefaultStorageMax
+	return nil
+}
+
+// ApplyEnvVars fills in any Config fields found
+// as environment variables.
+func (cfg *Config) ApplyEnvVars() error {
+	jcfg := cfg.toJSONConfig()
+
+	err := envconfig.Process(envConfigKey, jcfg)
+	if err != nil {
+		return err
+	}
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+// Validate checks that the fields of this Config have working values,
+// at least in appearance.
+func (cfg *Config) Validate() error {
+	switch {
+	case cfg.PinLatency < 0:
+		return errors.New("nullconn.pin_latency is invalid")
+	case cfg.UnpinLatency < 0:
+		return errors.New("nullconn.unpin_latency is invalid")
+	case cfg.LatencyJitter < 0:
+		return errors.New("nullconn.latency_jitter is invalid")
+	case cfg.PinFailureRate < 0 || cfg.PinFailureRate > 1:
+		return errors.New("nullconn.pin_failure_rate must be between 0 and 1")
+	case cfg.UnpinFailureRate < 0 || cfg.UnpinFailureRate > 1:
+		return errors.New("nullconn.unpin_failure_rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// LoadJSON sets the fields of this Config to the values defined by the JSON
+// representation of it, as generated by ToJSON.
+func (cfg *Config) LoadJSON(raw []byte) error {
+	jcfg := &jsonConfig{}
+	err := json.Unmarshal(raw, jcfg)
+	if err != nil {
+		logger.Error("Error unmarshaling nullconn config")
+		return err
+	}
+
+	cfg.Default()
+
+	return cfg.applyJSONConfig(jcfg)
+}
+
+func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
+	cfg.Enable = jcfg.Enable
+	cfg.PinFailureRate = jcfg.PinFailureRate
+	cfg.UnpinFailureRate = jcfg.UnpinFailureRate
+	config.SetIfNotDefault(jcfg.PinSize, &cfg.PinSize)
+	config.SetIfNotDefault(jcfg.StorageMax, &cfg.StorageMax)
+
+	err := config.ParseDurations(
+		configKey,
+		&config.DurationOpt{Duration: jcfg.PinLatency, Dst: &cfg.PinLatency, Name: "pin_latency"},
+		&config.DurationOpt{Duration: jcfg.UnpinLatency, Dst: &cfg.UnpinLatency, Name: "unpin_latency"},
+		&config.DurationOpt{Duration: jcfg.LatencyJitter, Dst: &cfg.LatencyJitter, Name: "latency_jitter"},
+	)
+	if err != nil {
+		return err
+	}
+
+	return cfg.Validate()
+}
+
+// ToJSON generates a human-friendly JSON representation of this Config.
+func (cfg *Config) ToJSON() ([]byte, error) {
+	jcfg := cfg.toJSONConfig()
+
+	return config.DefaultJSONMarshal(jcfg)
+}
+
+func (cfg *Config) toJSONConfig() *jsonConfig {
+	return &jsonConfig{
+		Enable:           cfg.Enable,
+		PinLatency:       cfg.PinLatency.String(),
+		UnpinLatency:     cfg.UnpinLatency.String(),
+		LatencyJitter:    cfg.LatencyJitter.String(),
+		PinFailureRate:   cfg.PinFailureRate,
+		UnpinFailureRate: cfg.UnpinFailureRate,
+		PinSize:          cfg.PinSize,
+		StorageMax:       cfg.StorageMax,
+	}
+}
@@ -0,0 +1,103 @@
+package nullconn
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+var cfgJSON = []byte(`
+{
+	"enable": true,
+	"pin_latency": "2s",
+	"unpin_latency": "1s",
+	"latency_jitter": "500ms",
+	"pin_failure_rate": 0.1,
+	"unpin_failure_rate": 0.05,
+	"pin_size": 2048,
+	"storage_max": 1000000
+}
+`)
+
+func TestLoadJSON(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON(cfgJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.Enable ||
+		cfg.PinLatency != 2*time.Second ||
+		cfg.UnpinLatency != time.Second ||
+		cfg.LatencyJitter != 500*time.Millisecond ||
+		cfg.PinFailureRate != 0.1 ||
+		cfg.UnpinFailureRate != 0.05 ||
+		cfg.PinSize != 2048 ||
+		cfg.StorageMax != 1000000 {
+		t.Error("config not loaded correctly")
+	}
+
+	j := &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.PinFailureRate = 1.5
+	tst, _ := json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a pin_failure_rate over 1")
+	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.UnpinLatency = "-1s"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error with a negative unpin_latency")
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	cfg := &Config{}
+	cfg.LoadJSON(cfgJSON)
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LatencyJitter != 500*time.Millisecond {
+		t.Error("latency_jitter not preserved")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	cfg := &Config{}
+	cfg.Default()
+	if cfg.Validate() != nil {
+		t.Fatal("error validating")
+	}
+	if cfg.Enable {
+		t.Error("the null connector should be disabled by default")
+	}
+
+	cfg.UnpinFailureRate = -0.5
+	if cfg.Validate() == nil {
+		t.Fatal("expected error validating a negative unpin_failure_rate")
+	}
+}
+
+func TestApplyEnvVars(t *testing.T) {
+	os.Setenv("CLUSTER_NULLCONN_PINLATENCY", "22s")
+	defer os.Unsetenv("CLUSTER_NULLCONN_PINLATENCY")
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ApplyEnvVars()
+
+	if cfg.PinLatency != 22*time.Second {
+		t.Fatal("failed to override pin_latency with env var")
+	}
+}
@@ -0,0 +1,309 @@
+// Package nullconn implements an IPFS Cluster IPFSConnector component which
+// does not talk to any IPFS daemon. Pins are only recorded in memory, after
+// a configurable latency and with a configurable failure rate, so that the
+// behaviour of large clusters (consensus, allocations, pin tracking) can be
+// tested without running real IPFS daemons.
+package nullconn
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/faults"
+
+	cid "github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	crypto "github.com/libp2p/go-libp2p-core/crypto"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	"go.opencensus.io/trace"
+)
+
+var logger = logging.Logger("nullconn")
+
+// ErrSimulated is returned by the operations which fail because of the
+// configured failure rates.
+var ErrSimulated = errors.New("simulated failure")
+
+// ErrNotSupported is returned by the operations which cannot be simulated.
+var ErrNotSupported = errors.New("not supported by the null connector")
+
+// Connector implements the IPFSConnector interface without an IPFS daemon.
+type Connector struct {
+	ctx    context.Context
+	cancel func()
+
+	config *Config
+	id     peer.ID
+
+	randMux sync.Mutex
+	rand    *rand.Rand
+
+	pinsMux sync.RWMutex
+	pins    map[cid.Cid]api.IPFSPinStatus
+
+	blocksMux sync.RWMutex
+	blocks    map[cid.Cid][]byte
+}
+
+// NewConnector creates the component and leaves it ready to be used. It
+// makes up a random peer ID for the simulated IPFS daemon.
+func NewConnector(cfg *Config) (*Connector, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	priv, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	if err != nil {
+		return nil, err
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logger.Warning("using the null IPFS connector: nothing will be pinned in IPFS")
+	return &Connector{
+		ctx:    ctx,
+		cancel: cancel,
+		config: cfg,
+		id:     id,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+		pins:   make(map[cid.Cid]api.IPFSPinStatus),
+		blocks: make(map[cid.Cid][]byte),
+	}, nil
+}
+
+// SetClient does nothing, as this component does not use RPC.
+func (nc *Connector) SetClient(c *rpc.Client) {}
+
+// Shutdown stops the component. Operations waiting on their latency are
+// cancelled.
+func (nc *Connector) Shutdown(ctx context.Context) error {
+	nc.cancel()
+	return nil
+}
+
+// wait simulates the latency of an operation, adding the configured jitter.
+func (nc *Connector) wait(ctx context.Context, latency time.Duration) error {
+	if jitter := nc.config.LatencyJitter; jitter > 0 {
+		nc.randMux.Lock()
+		latency += time.Duration(nc.rand.Int63n(int64(jitter)))
+		nc.randMux.Unlock()
+	}
+	if latency <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-nc.ctx.Done():
+		return errors.New("connector is shutting down")
+	}
+}
+
+// fails returns true with the given probability.
+func (nc *Connector) fails(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	nc.randMux.Lock()
+	defer nc.randMux.Unlock()
+	return nc.rand.Float64() < rate
+}
+
+// operation simulates an operation with the given latency and failure rate.
+func (nc *Connector) operation(ctx context.Context, latency time.Duration, failureRate float64) error {
+	if err := faults.ConnectorCall(); err != nil {
+		return err
+	}
+	if err := nc.wait(ctx, latency); err != nil {
+		return err
+	}
+	if nc.fails(failureRate) {
+		return ErrSimulated
+	}
+	return nil
+}
+
+// ID returns the made-up ID of the simulated IPFS daemon.
+func (nc *Connector) ID(ctx context.Context) (*api.IPFSID, error) {
+	return &api.IPFSID{
+		ID:           nc.id,
+		Addresses:    []api.Multiaddr{},
+		AgentVersion: "ipfs-cluster/nullconn",
+	}, nil
+}
+
+// Pin records the item as pinned after PinLatency, unless the operation
+// fails.
+func (nc *Connector) Pin(ctx context.Context, pin *api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/nullconn/Pin")
+	defer span.End()
+
+	err := nc.operation(ctx, nc.config.PinLatency, nc.config.PinFailureRate)
+	if err != nil {
+		logger.Debugf("error pinning %s: %s", pin.Cid, err)
+		return err
+	}
+
+	status := api.IPFSPinStatusRecursive
+	if pin.MaxDepth == 0 {
+		status = api.IPFSPinStatusDirect
+	}
+	nc.pinsMux.Lock()
+	nc.pins[pin.Cid] = status
+	nc.pinsMux.Unlock()
+	logger.Debugf("pinned %s", pin.Cid)
+	return nil
+}
+
+// Unpin forgets the item after UnpinLatency, unless the operation fails.
+func (nc *Connector) Unpin(ctx context.Context, hash cid.Cid) error {
+	ctx, span := trace.StartSpan(ctx, "ipfsconn/nullconn/Unpin")
+	defer span.End()
+
+	err := nc.operation(ctx, nc.config.UnpinLatency, nc.config.UnpinFailureRate)
+	if err != nil {
+		logger.Debugf("error unpinning %s: %s", hash, err)
+		return err
+	}
+
+	nc.pinsMux.Lock()
+	delete(nc.pins, hash)
+	nc.pinsMux.Unlock()
+	logger.Debugf("unpinned %s", hash)
+	return nil
+}
+
+// PinLsCid returns the status of the given item.
+func (nc *Connector) PinLsCid(ctx context.Context, hash cid.Cid) (api.IPFSPinStatus, error) {
+	nc.pinsMux.RLock()
+	defer nc.pinsMux.RUnlock()
+	status, ok := nc.pins[hash]
+	if !ok {
+		return api.IPFSPinStatusUnpinned, nil
+	}
+	return status, nil
+}
+
+// PinLs returns the pinned items of the given type ("recursive", "direct"
+// or "all").
+func (nc *Connector) PinLs(ctx context.Context, typeFilter string) (map[string]api.IPFSPinStatus, error) {
+	var want api.IPFSPinStatus
+	switch typeFilter {
+	case "", "all":
+	case "recursive":
+		want = api.IPFSPinStatusRecursive
+	case "direct":
+		want = api.IPFSPinStatusDirect
+	case "indirect":
+		// There are no indirect pins without DAGs.
+		return map[string]api.IPFSPinStatus{}, nil
+	default:
+		return nil, errors.New("invalid pin type: " + typeFilter)
+	}
+
+	nc.pinsMux.RLock()
+	defer nc.pinsMux.RUnlock()
+	statusMap := make(map[string]api.IPFSPinStatus, len(nc.pins))
+	for c, status := range nc.pins {
+		if want == 0 || status == want {
+			statusMap[c.String()] = status
+		}
+	}
+	return statusMap, nil
+}
+
+// ConnectSwarms does nothing.
+func (nc *Connector) ConnectSwarms(ctx context.Context) error {
+	return nil
+}
+
+// SwarmPeers returns no peers.
+func (nc *Connector) SwarmPeers(ctx context.Context) ([]peer.ID, error) {
+	return []peer.ID{}, nil
+}
+
+// ConfigKey fails, as there is no IPFS configuration.
+func (nc *Connector) ConfigKey(keypath string) (interface{}, error) {
+	return nil, ErrNotSupported
+}
+
+// RepoStat reports PinSize for every pinned item and the configured
+// StorageMax.
+func (nc *Connector) RepoStat(ctx context.Context) (*api.IPFSRepoStat, error) {
+	nc.pinsMux.RLock()
+	n := uint64(len(nc.pins))
+	nc.pinsMux.RUnlock()
+	return &api.IPFSRepoStat{
+		RepoSize:   n * nc.config.PinSize,
+		StorageMax: nc.config.StorageMax,
+	}, nil
+}
+
+// DagSize returns PinSize.
+func (nc *Connector) DagSize(ctx context.Context, hash cid.Cid) (uint64, error) {
+	return nc.config.PinSize, nil
+}
+
+// RepoGC does nothing.
+func (nc *Connector) RepoGC(ctx context.Context) (*api.RepoGC, error) {
+	return &api.RepoGC{Keys: []api.IPFSRepoGC{}}, nil
+}
+
+// Resolve returns the cid of /ipfs/<cid> paths. Other paths cannot be
+// resolved.
+func (nc *Connector) Resolve(ctx context.Context, path string) (cid.Cid, error) {
+	path = strings.TrimPrefix(path, "/ipfs/")
+	if strings.Contains(path, "/") {
+		return cid.Undef, ErrNotSupported
+	}
+	return cid.Decode(path)
+}
+
+// BlockPut keeps the block in memory.
+func (nc *Connector) BlockPut(ctx context.Context, b *api.NodeWithMeta) error {
+	nc.blocksMux.Lock()
+	defer nc.blocksMux.Unlock()
+	nc.blocks[b.Cid] = b.Data
+	return nil
+}
+
+// BlockGet returns a block added with BlockPut.
+func (nc *Connector) BlockGet(ctx context.Context, hash cid.Cid) ([]byte, error) {
+	nc.blocksMux.RLock()
+	defer nc.blocksMux.RUnlock()
+	data, ok := nc.blocks[hash]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+	return data, nil
+}
+
+// Prefetch waits for PinLatency.
+func (nc *Connector) Prefetch(ctx context.Context, hash cid.Cid) error {
+	return nc.wait(ctx, nc.config.PinLatency)
+}
+
+// NamePublish fails, as there is no IPNS.
+func (nc *Connector) NamePublish(ctx context.Context, pub *api.IPNSPublish) (string, error) {
+	return "", ErrNotSupported
+}
+
+// SetReprovider does nothing.
+func (nc *Connector) SetReprovider(ctx context.Context, rp *api.IPFSReprovider) error {
+	return nil
+}
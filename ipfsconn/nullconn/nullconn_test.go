@@ -0,0 +1,134 @@
+package nullconn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/test"
+)
+
+func testNullConnector(t *testing.T, modify func(cfg *Config)) *Connector {
+	cfg := &Config{}
+	cfg.Default()
+	if modify != nil {
+		modify(cfg)
+	}
+	nc, err := NewConnector(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return nc
+}
+
+func TestPinUnpin(t *testing.T) {
+	ctx := context.Background()
+	nc := testNullConnector(t, func(cfg *Config) {
+		cfg.PinLatency = 50 * time.Millisecond
+	})
+	defer nc.Shutdown(ctx)
+
+	start := time.Now()
+	err := nc.Pin(ctx, api.PinCid(test.Cid1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Error("pinning should take pin_latency")
+	}
+
+	direct := api.PinCid(test.Cid2)
+	direct.MaxDepth = 0
+	err = nc.Pin(ctx, direct)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := nc.PinLsCid(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st != api.IPFSPinStatusRecursive {
+		t.Error("expected a recursive pin")
+	}
+
+	pins, err := nc.PinLs(ctx, "direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 || pins[test.Cid2.String()] != api.IPFSPinStatusDirect {
+		t.Errorf("unexpected direct pins: %v", pins)
+	}
+
+	stat, err := nc.RepoStat(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.RepoSize != 2*DefaultPinSize || stat.StorageMax != DefaultStorageMax {
+		t.Errorf("unexpected repo stat: %+v", stat)
+	}
+
+	err = nc.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, _ = nc.PinLsCid(ctx, test.Cid1)
+	if st != api.IPFSPinStatusUnpinned {
+		t.Error("expected the item to be unpinned")
+	}
+}
+
+func TestFailureRate(t *testing.T) {
+	ctx := context.Background()
+	nc := testNullConnector(t, func(cfg *Config) {
+		cfg.PinFailureRate = 1
+	})
+	defer nc.Shutdown(ctx)
+
+	err := nc.Pin(ctx, api.PinCid(test.Cid1))
+	if err != ErrSimulated {
+		t.Errorf("expected a simulated failure, got %v", err)
+	}
+	st, _ := nc.PinLsCid(ctx, test.Cid1)
+	if st != api.IPFSPinStatusUnpinned {
+		t.Error("failed pins should not be recorded")
+	}
+
+	err = nc.Unpin(ctx, test.Cid1)
+	if err != nil {
+		t.Error("unpinning should not fail with unpin_failure_rate 0")
+	}
+}
+
+func TestLatencyCancel(t *testing.T) {
+	nc := testNullConnector(t, func(cfg *Config) {
+		cfg.PinLatency = time.Hour
+	})
+	defer nc.Shutdown(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := nc.Pin(ctx, api.PinCid(test.Cid1))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the pin to be cancelled, got %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	nc := testNullConnector(t, nil)
+	defer nc.Shutdown(ctx)
+
+	c, err := nc.Resolve(ctx, "/ipfs/"+test.Cid1.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Equals(test.Cid1) {
+		t.Error("unexpected resolved cid")
+	}
+	_, err = nc.Resolve(ctx, "/ipfs/"+test.Cid1.String()+"/a")
+	if err == nil {
+		t.Error("expected an error resolving a subpath")
+	}
+}
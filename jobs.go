@@ -0,0 +1,484 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+var (
+	errJobNotFound = errors.New("job not found")
+	errJobFinished = errors.New("job already finished")
+)
+
+// jobsDatastore is the name of the local datastore where jobs are
+// persisted.
+const jobsDatastore = "jobs"
+
+// jobPinsDatastore is the name of the local datastore where the pins of
+// import jobs are persisted, one per key, until the job finishes.
+const jobPinsDatastore = "job_pins"
+
+// lastJobIDKey keeps the ID of the last job submitted, so that the IDs of
+// jobs which have been removed are not reused.
+var lastJobIDKey = ds.NewKey("last_id")
+
+// jobSaveInterval is the number of items processed by import jobs between
+// saves of their progress.
+const jobSaveInterval = 100
+
+// job is a job queued in this peer.
+type job struct {
+	info   *api.Job
+	cancel context.CancelFunc
+}
+
+// SubmitJob queues a long-running operation in this peer. Jobs run one
+// after another, in submission order, and are persisted in the local
+// datastore so that they are resumed when the peer restarts. Finished jobs
+// are kept for JobRetention.
+func (c *Cluster) SubmitJob(ctx context.Context, j *api.Job) (*api.Job, error) {
+	_, span := trace.StartSpan(ctx, "cluster/SubmitJob")
+	defer span.End()
+
+	if err := j.Validate(); err != nil {
+		return nil, err
+	}
+
+	c.jobsMux.Lock()
+	c.nextJobID++
+	c.saveLastJobID()
+	info := &api.Job{
+		ID:      fmt.Sprintf("%d", c.nextJobID),
+		Kind:    j.Kind,
+		Status:  api.JobQueued,
+		Total:   len(j.Pins),
+		Created: time.Now(),
+	}
+	if err := c.saveJobPins(info.ID, j.Pins); err != nil {
+		c.jobsMux.Unlock()
+		return nil, err
+	}
+	c.jobs[info.ID] = &job{info: info}
+	c.saveJob(info)
+	infoCopy := jobCopy(info)
+	c.jobsMux.Unlock()
+
+	logger.Infof("%s job %s queued", info.Kind, info.ID)
+	c.notifyJobs()
+	return infoCopy, nil
+}
+
+// Jobs returns the jobs of this peer, including finished ones, sorted by
+// creation time. The pins of import jobs are not included.
+func (c *Cluster) Jobs(ctx context.Context) []*api.Job {
+	_, span := trace.StartSpan(ctx, "cluster/Jobs")
+	defer span.End()
+
+	c.jobsMux.Lock()
+	defer c.jobsMux.Unlock()
+
+	c.pruneJobs()
+	result := make([]*api.Job, 0, len(c.jobs))
+	for _, j := range c.jobs {
+		result = append(result, jobCopy(j.info))
+	}
+	sortJobs(result)
+	return result
+}
+
+// Job returns the job with the given ID. The pins of import jobs are not
+// included.
+func (c *Cluster) Job(ctx context.Context, id string) (*api.Job, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Job")
+	defer span.End()
+
+	c.jobsMux.Lock()
+	defer c.jobsMux.Unlock()
+
+	j, ok := c.jobs[id]
+	if !ok {
+		return nil, errJobNotFound
+	}
+	return jobCopy(j.info), nil
+}
+
+// CancelJob cancels the job with the given ID. Queued jobs will not run and
+// running jobs are stopped. Cancelled jobs are kept in the job list.
+func (c *Cluster) CancelJob(ctx context.Context, id string) error {
+	_, span := trace.StartSpan(ctx, "cluster/CancelJob")
+	defer span.End()
+
+	c.jobsMux.Lock()
+	defer c.jobsMux.Unlock()
+
+	j, ok := c.jobs[id]
+	if !ok {
+		return errJobNotFound
+	}
+
+	switch j.info.Status {
+	case api.JobQueued:
+		c.finishJob(j.info, api.JobCancelled, nil)
+	case api.JobRunning:
+		// runJob marks it as cancelled.
+		j.cancel()
+	default:
+		return errJobFinished
+	}
+	logger.Infof("job %s cancelled", id)
+	return nil
+}
+
+// runJobs runs the queued jobs, one at a time, whenever notified.
+func (c *Cluster) runJobs() {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-c.jobsCh:
+		}
+
+		for {
+			ctx, j := c.nextJob()
+			if j == nil {
+				break
+			}
+			c.runJob(ctx, j)
+			if c.ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Cluster) notifyJobs() {
+	select {
+	case c.jobsCh <- struct{}{}:
+	default:
+	}
+}
+
+// nextJob marks the oldest queued job as running and returns it, along with
+// the context to run it, or nil when no jobs are queued.
+func (c *Cluster) nextJob() (context.Context, *api.Job) {
+	c.jobsMux.Lock()
+	defer c.jobsMux.Unlock()
+
+	var next *job
+	for _, j := range c.jobs {
+		if j.info.Status != api.JobQueued {
+			continue
+		}
+		if next == nil || jobLess(j.info, next.info) {
+			next = j
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	next.cancel = cancel
+	next.info.Status = api.JobRunning
+	next.info.Started = time.Now()
+	c.saveJob(next.info)
+	return ctx, next.info
+}
+
+func (c *Cluster) runJob(ctx context.Context, j *api.Job) {
+	ctx, span := trace.StartSpan(ctx, "cluster/runJob")
+	defer span.End()
+
+	logger.Infof("running %s job %s", j.Kind, j.ID)
+	err := c.performJob(ctx, j)
+
+	c.jobsMux.Lock()
+	defer c.jobsMux.Unlock()
+	switch {
+	case c.ctx.Err() != nil:
+		// The peer is shutting down: the job stays running and
+		// is resumed on restart.
+		return
+	case ctx.Err() != nil:
+		c.finishJob(j, api.JobCancelled, nil)
+	case err != nil:
+		logger.Errorf("%s job %s failed: %s", j.Kind, j.ID, err)
+		c.finishJob(j, api.JobFailed, err)
+	default:
+		logger.Infof("%s job %s done", j.Kind, j.ID)
+		c.finishJob(j, api.JobDone, nil)
+	}
+}
+
+// performJob runs the operation of a job and updates its progress.
+func (c *Cluster) performJob(ctx context.Context, j *api.Job) error {
+	switch j.Kind {
+	case api.JobRecover:
+		gpInfos, err := c.RecoverAll(ctx)
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, gpInfo := range gpInfos {
+			for _, pInfo := range gpInfo.PeerMap {
+				if pInfo.Status.Match(api.TrackerStatusError) {
+					failed++
+					break
+				}
+			}
+		}
+		c.setJobProgress(j, len(gpInfos), len(gpInfos), failed)
+		return nil
+	case api.JobRepoGC:
+		repoGC, err := c.RepoGC(ctx)
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, peerGC := range repoGC.PeerMap {
+			if peerGC.Error != "" {
+				failed++
+			}
+		}
+		c.setJobProgress(j, len(repoGC.PeerMap), len(repoGC.PeerMap), failed)
+		return nil
+	case api.JobShardsGC:
+		unpinned, err := c.ShardsGC(ctx)
+		if err != nil {
+			return err
+		}
+		c.setJobProgress(j, len(unpinned), len(unpinned), 0)
+		return nil
+	case api.JobImport:
+		return c.importPins(ctx, j)
+	default:
+		return fmt.Errorf("unknown job kind: %q", j.Kind)
+	}
+}
+
+// importPins pins the pins of an import job, starting after the last
+// processed one.
+func (c *Cluster) importPins(ctx context.Context, j *api.Job) error {
+	c.jobsMux.Lock()
+	total := j.Total
+	start := j.Progress
+	failed := j.Failed
+	c.jobsMux.Unlock()
+
+	store := c.localDatastore(jobPinsDatastore)
+	for i := start; i < total; i++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		b, err := store.Get(jobPinKey(j.ID, i))
+		if err != nil {
+			return fmt.Errorf("loading pin %d: %s", i, err)
+		}
+		var stored api.Pin
+		if err := json.Unmarshal(b, &stored); err != nil {
+			return fmt.Errorf("decoding pin %d: %s", i, err)
+		}
+
+		pin := api.PinWithOpts(stored.Cid, stored.PinOptions)
+		_, _, err = c.pin(ctx, pin, []peer.ID{}, false)
+		if err != nil {
+			logger.Warningf("import job %s: error pinning %s: %s", j.ID, pin.Cid, err)
+			failed++
+		}
+
+		c.jobsMux.Lock()
+		j.Progress = i + 1
+		j.Failed = failed
+		if j.Progress%jobSaveInterval == 0 {
+			c.saveJob(j)
+		}
+		c.jobsMux.Unlock()
+	}
+	return nil
+}
+
+func (c *Cluster) setJobProgress(j *api.Job, progress, total, failed int) {
+	c.jobsMux.Lock()
+	defer c.jobsMux.Unlock()
+	j.Progress = progress
+	j.Total = total
+	j.Failed = failed
+}
+
+// finishJob sets the final status of a job and persists it. The pins of
+// import jobs are removed, along with the jobs which have been finished
+// for longer than JobRetention. It must be called with jobsMux held.
+func (c *Cluster) finishJob(j *api.Job, status api.JobStatus, err error) {
+	j.Status = status
+	j.Finished = time.Now()
+	if err != nil {
+		j.Error = err.Error()
+	}
+	c.saveJob(j)
+	c.removeJobPins(j)
+	c.pruneJobs()
+}
+
+// pruneJobs removes the jobs which have been finished for longer than
+// JobRetention. It must be called with jobsMux held.
+func (c *Cluster) pruneJobs() {
+	retention := c.config.JobRetention
+	if retention <= 0 {
+		return
+	}
+	for id, j := range c.jobs {
+		if j.info.Finished.IsZero() || time.Since(j.info.Finished) < retention {
+			continue
+		}
+		delete(c.jobs, id)
+		if err := c.localDatastore(jobsDatastore).Delete(ds.NewKey(id)); err != nil {
+			logger.Errorf("error removing job %s: %s", id, err)
+		}
+	}
+}
+
+// saveJob persists a job, without its pins (see saveJobPins). It must be
+// called with jobsMux held.
+func (c *Cluster) saveJob(j *api.Job) {
+	b, err := json.Marshal(jobCopy(j))
+	if err == nil {
+		err = c.localDatastore(jobsDatastore).Put(ds.NewKey(j.ID), b)
+	}
+	if err != nil {
+		logger.Errorf("error saving job %s: %s", j.ID, err)
+	}
+}
+
+// saveLastJobID persists nextJobID. It must be called with jobsMux held.
+func (c *Cluster) saveLastJobID() {
+	id := []byte(strconv.FormatUint(c.nextJobID, 10))
+	if err := c.localDatastore(jobsDatastore).Put(lastJobIDKey, id); err != nil {
+		logger.Errorf("error saving the last job ID: %s", err)
+	}
+}
+
+// jobPinKey returns the key of the i-th pin of a job. Indexes are padded so
+// that the keys of a job sort in order.
+func jobPinKey(jobID string, i int) ds.Key {
+	return ds.NewKey(jobID).ChildString(fmt.Sprintf("%010d", i))
+}
+
+// saveJobPins persists the pins of an import job, one per key, so that
+// they are written once and the job itself stays small.
+func (c *Cluster) saveJobPins(jobID string, pins []*api.Pin) error {
+	store := c.localDatastore(jobPinsDatastore)
+	for i, pin := range pins {
+		b, err := json.Marshal(pin)
+		if err != nil {
+			return err
+		}
+		if err := store.Put(jobPinKey(jobID, i), b); err != nil {
+			return fmt.Errorf("error saving the pins of job %s: %s", jobID, err)
+		}
+	}
+	return nil
+}
+
+// removeJobPins removes the pins of a finished import job.
+func (c *Cluster) removeJobPins(j *api.Job) {
+	if j.Kind != api.JobImport {
+		return
+	}
+	store := c.localDatastore(jobPinsDatastore)
+	for i := 0; i < j.Total; i++ {
+		if err := store.Delete(jobPinKey(j.ID, i)); err != nil && err != ds.ErrNotFound {
+			logger.Errorf("error removing the pins of job %s: %s", j.ID, err)
+			return
+		}
+	}
+}
+
+// loadJobs loads the jobs persisted by a previous run of the peer. Jobs
+// which were running are queued again and resumed.
+func (c *Cluster) loadJobs() error {
+	results, err := c.localDatastore(jobsDatastore).Query(query.Query{})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+
+	c.jobsMux.Lock()
+	resumed := 0
+	for _, entry := range entries {
+		if ds.NewKey(entry.Key).Equal(lastJobIDKey) {
+			if id, err := strconv.ParseUint(string(entry.Value), 10, 64); err == nil && id > c.nextJobID {
+				c.nextJobID = id
+			}
+			continue
+		}
+		info := &api.Job{}
+		if err := json.Unmarshal(entry.Value, info); err != nil {
+			logger.Errorf("error loading job %s: %s", entry.Key, err)
+			continue
+		}
+		if id, err := strconv.ParseUint(info.ID, 10, 64); err == nil && id > c.nextJobID {
+			c.nextJobID = id
+		}
+		// Jobs persisted by older versions carry their pins.
+		if len(info.Pins) > 0 {
+			if err := c.saveJobPins(info.ID, info.Pins); err != nil {
+				logger.Error(err)
+				continue
+			}
+			c.saveJob(info)
+			info.Pins = nil
+		}
+		if info.Status == api.JobRunning {
+			info.Status = api.JobQueued
+			c.saveJob(info)
+		}
+		if info.Status == api.JobQueued {
+			resumed++
+		}
+		c.jobs[info.ID] = &job{info: info}
+	}
+	c.pruneJobs()
+	c.jobsMux.Unlock()
+
+	if resumed > 0 {
+		logger.Infof("resuming %d queued jobs", resumed)
+		c.notifyJobs()
+	}
+	return nil
+}
+
+// jobCopy returns a copy of a job without its pins.
+func jobCopy(j *api.Job) *api.Job {
+	jCopy := *j
+	jCopy.Pins = nil
+	return &jCopy
+}
+
+func jobLess(a, b *api.Job) bool {
+	if !a.Created.Equal(b.Created) {
+		return a.Created.Before(b.Created)
+	}
+	return a.ID < b.ID
+}
+
+func sortJobs(jobs []*api.Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobLess(jobs[i], jobs[j])
+	})
+}
@@ -0,0 +1,90 @@
+package ipfscluster
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	net "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	ping "github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	"go.opencensus.io/trace"
+)
+
+// keepAlive pings every cluster peer every KeepAliveInterval and
+// re-connects to those which are not connected or do not answer.
+func (c *Cluster) keepAlive() {
+	ticker := time.NewTicker(c.config.KeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.keepAlivePeers(c.ctx)
+		}
+	}
+}
+
+// keepAlivePeers pings the cluster peers and re-connects to those which
+// are not connected or do not answer within peersPingTimeout. Connections
+// which do not answer are closed first, as they are likely stale. It
+// returns the peers it re-connected to.
+func (c *Cluster) keepAlivePeers(ctx context.Context) []peer.ID {
+	ctx, span := trace.StartSpan(ctx, "cluster/keepAlivePeers")
+	defer span.End()
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		logger.Debugf("keep-alive: cannot obtain the peerset: %s", err)
+		return nil
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var reconnected []peer.ID
+	for _, p := range peers {
+		if p == c.id {
+			continue
+		}
+		wg.Add(1)
+		go func(p peer.ID) {
+			defer wg.Done()
+			if c.keepAlivePeer(ctx, p) {
+				mu.Lock()
+				reconnected = append(reconnected, p)
+				mu.Unlock()
+			}
+		}(p)
+	}
+	wg.Wait()
+	return reconnected
+}
+
+// keepAlivePeer pings the given peer, re-connecting to it when needed. It
+// returns true when it re-connected successfully.
+func (c *Cluster) keepAlivePeer(ctx context.Context, p peer.ID) bool {
+	if c.host.Network().Connectedness(p) == net.Connected {
+		pctx, pcancel := context.WithTimeout(ctx, peersPingTimeout)
+		res, ok := <-ping.Ping(pctx, c.host, p)
+		pcancel()
+		if ok && res.Error == nil {
+			return false
+		}
+		logger.Infof("keep-alive: %s did not answer, closing its connections: %v", p, res.Error)
+		if err := c.host.Network().ClosePeer(p); err != nil {
+			logger.Debugf("keep-alive: error closing connections to %s: %s", p, err)
+		}
+	}
+
+	cctx, ccancel := context.WithTimeout(ctx, peersPingTimeout)
+	defer ccancel()
+	err := c.host.Connect(cctx, peer.AddrInfo{ID: p})
+	if err != nil {
+		logger.Debugf("keep-alive: cannot connect to %s: %s", p, err)
+		return false
+	}
+	logger.Infof("keep-alive: reconnected to %s", p)
+	return true
+}
@@ -0,0 +1,36 @@
+package ipfscluster
+
+import (
+	ds "github.com/ipfs/go-datastore"
+	namespace "github.com/ipfs/go-datastore/namespace"
+)
+
+// localDatastoreNamespace is the part of the peer datastore used for data
+// which only concerns this peer, as opposed to the shared state.
+var localDatastoreNamespace = ds.NewKey("/local")
+
+// localDatastore returns the part of the peer datastore where the data of
+// the given kind is persisted. The datastore is opened by the daemon with
+// the configured backend and outlives restarts of the peer.
+func (c *Cluster) localDatastore(name string) ds.Datastore {
+	return namespace.Wrap(c.datastore, localDatastoreNamespace.ChildString(name))
+}
+
+// Names of the local datastores handed to the components (see
+// LocalPersister).
+const (
+	trackerDatastore = "tracker"
+	monitorDatastore = "monitor"
+)
+
+// setupLocalPersisters hands their local datastore to the components which
+// persist data locally: the queued operations of the pin tracker and the
+// latest metrics of the peer monitor.
+func (c *Cluster) setupLocalPersisters() {
+	if lp, ok := c.tracker.(LocalPersister); ok {
+		lp.SetLocalDatastore(c.localDatastore(trackerDatastore))
+	}
+	if lp, ok := c.monitor.(LocalPersister); ok {
+		lp.SetLocalDatastore(c.localDatastore(monitorDatastore))
+	}
+}
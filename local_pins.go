@@ -0,0 +1,132 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/state"
+	"github.com/ipfs/ipfs-cluster/state/dsstate"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// localPinsDatastore is the name of the local datastore where local pins
+// are persisted.
+const localPinsDatastore = "local_pins"
+
+var errPinnedLocally = errors.New("cid is pinned locally in this peer: unpin it before adding it to the shared pinset")
+
+// localPins returns the state holding the local pins of this peer. Local
+// pins are pinned and tracked by this peer only and are never written to
+// the shared state.
+func (c *Cluster) localPins() (*dsstate.State, error) {
+	return dsstate.New(c.localDatastore(localPinsDatastore), "", dsstate.DefaultHandle())
+}
+
+// pinLocal persists a local pin and tells the tracker to pin it. Local pins
+// are allocated to this peer only and cannot be part of the shared pinset.
+func (c *Cluster) pinLocal(ctx context.Context, pin *api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/pinLocal")
+	defer span.End()
+
+	if pin.Type != api.DataType {
+		return errors.New("only data pins can be local pins")
+	}
+	if pin.PinUpdate != cid.Undef {
+		return errors.New("local pins cannot be pin updates")
+	}
+	if !pin.ExpireAt.IsZero() {
+		return errors.New("local pins cannot expire")
+	}
+
+	_, err := c.PinGet(ctx, pin.Cid)
+	if err == nil {
+		return errors.New("cid is part of the shared pinset and cannot be pinned locally")
+	}
+	if err != state.ErrNotFound {
+		return err
+	}
+
+	st, err := c.localPins()
+	if err != nil {
+		return err
+	}
+
+	pin.ReplicationFactorMin = 1
+	pin.ReplicationFactorMax = 1
+	pin.Allocations = []peer.ID{c.id}
+	if err := st.Add(ctx, pin); err != nil {
+		return err
+	}
+
+	logger.Infof("pinning %s locally", pin.Cid)
+	return c.tracker.Track(ctx, pin)
+}
+
+// unpinLocal removes a local pin and tells the tracker to unpin it. It
+// returns false when the given cid is not a local pin. Protected local pins
+// are only removed when force is set.
+func (c *Cluster) unpinLocal(ctx context.Context, h cid.Cid, force bool) (*api.Pin, bool, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/unpinLocal")
+	defer span.End()
+
+	st, err := c.localPins()
+	if err != nil {
+		return nil, false, err
+	}
+
+	pin, err := st.Get(ctx, h)
+	if err == state.ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if pin.Protected && !force {
+		return pin, true, errPinProtected(h)
+	}
+
+	if err := st.Rm(ctx, h); err != nil {
+		return pin, true, err
+	}
+
+	logger.Infof("unpinning %s locally", h)
+	return pin, true, c.tracker.Untrack(ctx, h)
+}
+
+// isLocalPin returns true when the given cid is a local pin of this peer.
+func (c *Cluster) isLocalPin(ctx context.Context, h cid.Cid) (bool, error) {
+	st, err := c.localPins()
+	if err != nil {
+		return false, err
+	}
+	return st.Has(ctx, h)
+}
+
+// trackLocalPins hands all the persisted local pins to the tracker, which
+// does not know about them after a restart.
+func (c *Cluster) trackLocalPins(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/trackLocalPins")
+	defer span.End()
+
+	st, err := c.localPins()
+	if err != nil {
+		return err
+	}
+
+	pins, err := st.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, pin := range pins {
+		if err := c.tracker.Track(ctx, pin); err != nil {
+			logger.Errorf("error tracking local pin %s: %s", pin.Cid, err)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package ipfscluster
 
 import (
+	"fmt"
+	"strings"
+
 	logging "github.com/ipfs/go-log"
 )
 
@@ -56,6 +59,14 @@ var LoggingFacilitiesExtra = map[string]string{
 	"raftlib":     "ERROR",
 }
 
+// LoggingFacilityAliases groups several logging identifiers under a
+// single name, so that they can be adjusted together.
+var LoggingFacilityAliases = map[string][]string{
+	"consensus": {"raft", "crdt", "libp2p-raft", "raftlib"},
+	"api":       {"restapi", "restapilog", "ipfsproxy", "ipfsproxylog"},
+	"tracker":   {"pintracker", "optracker"},
+}
+
 // SetFacilityLogLevel sets the log level for a given module
 func SetFacilityLogLevel(f, l string) {
 	/*
@@ -68,3 +79,22 @@ func SetFacilityLogLevel(f, l string) {
 	*/
 	logging.SetLogLevel(f, l)
 }
+
+// setLogLevel sets the log level of a facility, an alias from
+// LoggingFacilityAliases or all facilities ("*"). Unlike
+// SetFacilityLogLevel, it returns an error for unknown facilities and
+// levels.
+func setLogLevel(f, l string) error {
+	facilities, ok := LoggingFacilityAliases[f]
+	if !ok {
+		facilities = []string{f}
+	}
+
+	for _, fac := range facilities {
+		err := logging.SetLogLevel(fac, strings.ToUpper(l))
+		if err != nil {
+			return fmt.Errorf("setting log level for %s: %s", fac, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	ds "github.com/ipfs/go-datastore"
+	"go.opencensus.io/trace"
+)
+
+// maintenanceDatastore is the name of the local datastore where the
+// maintenance mode of the peer is persisted.
+const maintenanceDatastore = "maintenance"
+
+var maintenanceKey = ds.NewKey("enabled")
+
+// PeerMaintenance enables or disables the maintenance mode of the given
+// peer (see PeerMaintenanceLocal). When no peer is given, it applies to this
+// peer.
+func (c *Cluster) PeerMaintenance(ctx context.Context, m *api.PeerMaintenance) error {
+	_, span := trace.StartSpan(ctx, "cluster/PeerMaintenance")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if m.Peer == "" || m.Peer == c.id {
+		return c.PeerMaintenanceLocal(ctx, m)
+	}
+
+	return c.rpcClient.CallContext(
+		ctx,
+		m.Peer,
+		"Cluster",
+		"PeerMaintenanceLocal",
+		m,
+		&struct{}{},
+	)
+}
+
+// PeerMaintenanceLocal enables or disables the maintenance mode of this
+// peer. A peer in maintenance flags the metrics it publishes so that it
+// receives no new allocations, while it keeps the pins allocated to it.
+// When m.Repin is set, those pins are re-allocated to other peers. The mode
+// is persisted and survives restarts.
+func (c *Cluster) PeerMaintenanceLocal(ctx context.Context, m *api.PeerMaintenance) error {
+	_, span := trace.StartSpan(ctx, "cluster/PeerMaintenanceLocal")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if m.Repin && !m.Enabled {
+		return errors.New("pins can only be re-allocated when enabling maintenance")
+	}
+	if m.Repin && c.config.DisableRepinning {
+		return errors.New("repinning is disabled in this peer")
+	}
+
+	store := c.localDatastore(maintenanceDatastore)
+	var err error
+	if m.Enabled {
+		err = store.Put(maintenanceKey, []byte("true"))
+	} else {
+		err = store.Delete(maintenanceKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.maintenanceMux.Lock()
+	c.maintenance = m.Enabled
+	c.maintenanceMux.Unlock()
+
+	if m.Enabled {
+		logger.Warning("maintenance mode enabled: this peer will not receive new allocations")
+	} else {
+		logger.Info("maintenance mode disabled")
+	}
+
+	// Let other peers know right away instead of waiting for the next
+	// metrics.
+	if _, err := c.sendPingMetric(ctx); err != nil {
+		logger.Errorf("error publishing metrics: %s", err)
+	}
+	if _, err := c.sendInformersMetrics(ctx); err != nil {
+		logger.Errorf("error publishing metrics: %s", err)
+	}
+
+	if m.Repin {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.vacatePeer(c.ctx, c.id, repinReasonMaintenance)
+		}()
+	}
+	return nil
+}
+
+// inMaintenance returns whether this peer is in maintenance mode.
+func (c *Cluster) inMaintenance() bool {
+	c.maintenanceMux.RLock()
+	defer c.maintenanceMux.RUnlock()
+	return c.maintenance
+}
+
+// loadMaintenance restores the maintenance mode set before the peer was
+// restarted.
+func (c *Cluster) loadMaintenance() error {
+	ok, err := c.localDatastore(maintenanceDatastore).Has(maintenanceKey)
+	if err != nil {
+		return err
+	}
+	if ok {
+		logger.Warning("this peer is in maintenance mode and will not receive new allocations")
+	}
+	c.maintenanceMux.Lock()
+	c.maintenance = ok
+	c.maintenanceMux.Unlock()
+	return nil
+}
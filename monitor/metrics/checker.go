@@ -67,7 +67,7 @@ func (mc *Checker) CheckPeers(peers []peer.ID) error {
 		for _, peer := range peers {
 			for _, metric := range mc.metrics.PeerMetricAll(name, peer) {
 				if mc.FailedMetric(metric.Name, peer) {
-					err := mc.alert(peer, metric.Name)
+					err := mc.alert(metric)
 					if err != nil {
 						return err
 					}
@@ -83,7 +83,7 @@ func (mc *Checker) CheckPeers(peers []peer.ID) error {
 func (mc *Checker) CheckAll() error {
 	for _, metric := range mc.metrics.AllMetrics() {
 		if mc.FailedMetric(metric.Name, metric.Peer) {
-			err := mc.alert(metric.Peer, metric.Name)
+			err := mc.alert(metric)
 			if err != nil {
 				return err
 			}
@@ -98,7 +98,7 @@ func (mc *Checker) alertIfExpired(metric *api.Metric) error {
 		return nil
 	}
 
-	err := mc.alert(metric.Peer, metric.Name)
+	err := mc.alert(metric)
 	if err != nil {
 		return err
 	}
@@ -107,7 +107,10 @@ func (mc *Checker) alertIfExpired(metric *api.Metric) error {
 	return nil
 }
 
-func (mc *Checker) alert(pid peer.ID, metricName string) error {
+func (mc *Checker) alert(metric *api.Metric) error {
+	pid := metric.Peer
+	metricName := metric.Name
+
 	mc.failedPeersMu.Lock()
 	defer mc.failedPeersMu.Unlock()
 
@@ -130,8 +133,11 @@ func (mc *Checker) alert(pid peer.ID, metricName string) error {
 	failedMetrics[metricName]++
 
 	alrt := &api.Alert{
-		Peer:       pid,
-		MetricName: metricName,
+		Peer:        pid,
+		Peername:    metric.Peername,
+		MetricName:  metricName,
+		TriggeredAt: time.Now(),
+		Tags:        metric.Tags,
 	}
 	select {
 	case mc.alertCh <- alrt:
@@ -14,8 +14,9 @@ const envConfigKey = "cluster_pubsubmon"
 
 // Default values for this Config.
 const (
-	DefaultCheckInterval    = 15 * time.Second
-	DefaultFailureThreshold = 3.0
+	DefaultCheckInterval      = 15 * time.Second
+	DefaultFailureThreshold   = 3.0
+	DefaultClockSkewThreshold = 5 * time.Second
 )
 
 // Config allows to initialize a Monitor and customize some parameters.
@@ -27,11 +28,18 @@ type Config struct {
 	// The greater the threshold value the more leniency is granted.
 	// A value between 2.0 and 4.0 is suggested for the threshold.
 	FailureThreshold float64
+	// ClockSkewThreshold is the clock difference with another peer (as
+	// measured from the timestamps in the metrics it sends) above which
+	// a warning is logged. Metrics expire based on the sender's clock, so
+	// skewed clocks make peers look down or metrics live too long. 0
+	// disables the check.
+	ClockSkewThreshold time.Duration
 }
 
 type jsonConfig struct {
-	CheckInterval    string   `json:"check_interval"`
-	FailureThreshold *float64 `json:"failure_threshold"`
+	CheckInterval      string   `json:"check_interval"`
+	FailureThreshold   *float64 `json:"failure_threshold"`
+	ClockSkewThreshold string   `json:"clock_skew_threshold,omitempty"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -43,6 +51,7 @@ func (cfg *Config) ConfigKey() string {
 func (cfg *Config) Default() error {
 	cfg.CheckInterval = DefaultCheckInterval
 	cfg.FailureThreshold = DefaultFailureThreshold
+	cfg.ClockSkewThreshold = DefaultClockSkewThreshold
 	return nil
 }
 
@@ -70,6 +79,10 @@ func (cfg *Config) Validate() error {
 		return errors.New("pubsubmon.failure_threshold too low")
 	}
 
+	if cfg.ClockSkewThreshold < 0 {
+		return errors.New("pubsubmon.clock_skew_threshold is invalid")
+	}
+
 	return nil
 }
 
@@ -94,6 +107,13 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	if jcfg.FailureThreshold != nil {
 		cfg.FailureThreshold = *jcfg.FailureThreshold
 	}
+	if jcfg.ClockSkewThreshold != "" {
+		skew, err := time.ParseDuration(jcfg.ClockSkewThreshold)
+		if err != nil {
+			return err
+		}
+		cfg.ClockSkewThreshold = skew
+	}
 
 	return cfg.Validate()
 }
@@ -107,7 +127,8 @@ func (cfg *Config) ToJSON() ([]byte, error) {
 
 func (cfg *Config) toJSONConfig() *jsonConfig {
 	return &jsonConfig{
-		CheckInterval:    cfg.CheckInterval.String(),
-		FailureThreshold: &cfg.FailureThreshold,
+		CheckInterval:      cfg.CheckInterval.String(),
+		FailureThreshold:   &cfg.FailureThreshold,
+		ClockSkewThreshold: cfg.ClockSkewThreshold.String(),
 	}
 }
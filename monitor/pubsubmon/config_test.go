@@ -10,7 +10,8 @@ import (
 var cfgJSON = []byte(`
 {
       "check_interval": "15s",
-      "failure_threshold": 3.0
+      "failure_threshold": 3.0,
+      "clock_skew_threshold": "5s"
 }
 `)
 
@@ -30,6 +31,15 @@ func TestLoadJSON(t *testing.T) {
 	if err == nil {
 		t.Error("expected error decoding check_interval")
 	}
+
+	j = &jsonConfig{}
+	json.Unmarshal(cfgJSON, j)
+	j.ClockSkewThreshold = "abc"
+	tst, _ = json.Marshal(j)
+	err = cfg.LoadJSON(tst)
+	if err == nil {
+		t.Error("expected error decoding clock_skew_threshold")
+	}
 }
 
 func TestToJSON(t *testing.T) {
@@ -0,0 +1,82 @@
+package pubsubmon
+
+import (
+	"bytes"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	msgpack "github.com/multiformats/go-multicodec/msgpack"
+)
+
+// SetLocalDatastore sets the datastore where the monitor persists the
+// latest metric of every type and peer. The metrics persisted before a
+// restart are loaded from it unless they have expired, so that the peer
+// does not wait for new ones to make decisions.
+func (mon *Monitor) SetLocalDatastore(store ds.Datastore) {
+	mon.storeMux.Lock()
+	mon.store = store
+	mon.storeMux.Unlock()
+	mon.loadMetrics()
+}
+
+func metricKey(m *api.Metric) ds.Key {
+	return ds.NewKey(m.Name).ChildString(peer.IDB58Encode(m.Peer))
+}
+
+// persistMetric saves the given metric as the latest one of its type for
+// its peer.
+func (mon *Monitor) persistMetric(m *api.Metric) {
+	mon.storeMux.Lock()
+	defer mon.storeMux.Unlock()
+	if mon.store == nil {
+		return
+	}
+
+	var b bytes.Buffer
+	enc := msgpack.Multicodec(msgpackHandle).Encoder(&b)
+	err := enc.Encode(m)
+	if err == nil {
+		err = mon.store.Put(metricKey(m), b.Bytes())
+	}
+	if err != nil {
+		logger.Errorf("error persisting the '%s' metric from '%s': %s", m.Name, m.Peer, err)
+	}
+}
+
+// loadMetrics adds the persisted metrics which have not expired to the
+// metrics store and removes the others.
+func (mon *Monitor) loadMetrics() {
+	mon.storeMux.Lock()
+	defer mon.storeMux.Unlock()
+	if mon.store == nil {
+		return
+	}
+
+	results, err := mon.store.Query(query.Query{})
+	if err != nil {
+		logger.Errorf("error reading the persisted metrics: %s", err)
+		return
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		logger.Errorf("error reading the persisted metrics: %s", err)
+		return
+	}
+
+	n := 0
+	for _, e := range entries {
+		var m api.Metric
+		dec := msgpack.Multicodec(msgpackHandle).Decoder(bytes.NewReader(e.Value))
+		err := dec.Decode(&m)
+		if err != nil || m.Discard() {
+			mon.store.Delete(ds.NewKey(e.Key))
+			continue
+		}
+		mon.metrics.Add(&m)
+		n++
+	}
+	logger.Debugf("loaded %d persisted metrics", n)
+}
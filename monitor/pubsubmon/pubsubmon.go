@@ -5,12 +5,14 @@ package pubsubmon
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"sync"
 
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/monitor/metrics"
 
+	ds "github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
@@ -44,6 +46,13 @@ type Monitor struct {
 
 	config *Config
 
+	skewMux sync.Mutex
+	skewed  map[peer.ID]struct{}
+
+	// store persists the latest metrics (see SetLocalDatastore).
+	storeMux sync.Mutex
+	store    ds.Datastore
+
 	shutdownLock sync.Mutex
 	shutdown     bool
 	wg           sync.WaitGroup
@@ -90,6 +99,7 @@ func New(
 		metrics: mtrs,
 		checker: checker,
 		config:  cfg,
+		skewed:  make(map[peer.ID]struct{}),
 	}
 
 	go mon.run()
@@ -180,10 +190,46 @@ func (mon *Monitor) LogMetric(ctx context.Context, m *api.Metric) error {
 	defer span.End()
 
 	mon.metrics.Add(m)
+	if !m.Discard() {
+		mon.persistMetric(m)
+	}
 	logger.Debugf("pubsub mon logged '%s' metric from '%s'. Expires on %d", m.Name, m.Peer, m.Expire)
+	mon.checkClockSkew(m)
 	return nil
 }
 
+// checkClockSkew warns when the clock of the peer that sent the given metric
+// differs from ours by more than the configured threshold. The warning is
+// logged once, and again only after the peer's clock has recovered.
+func (mon *Monitor) checkClockSkew(m *api.Metric) {
+	threshold := mon.config.ClockSkewThreshold
+	if threshold == 0 || m.SentAt == 0 {
+		return
+	}
+
+	skew := m.ClockSkew()
+	mon.skewMux.Lock()
+	defer mon.skewMux.Unlock()
+
+	_, skewed := mon.skewed[m.Peer]
+	if skew > threshold || skew < -threshold {
+		if !skewed {
+			logger.Warningf(
+				"clock of peer %s differs by %s (threshold %s). Metrics may expire too early or too late. Please sync the clocks (i.e. with NTP)",
+				m.Peer.Pretty(),
+				skew,
+				threshold,
+			)
+			mon.skewed[m.Peer] = struct{}{}
+		}
+		return
+	}
+	if skewed {
+		logger.Infof("clock of peer %s is in sync again (differs by %s)", m.Peer.Pretty(), skew)
+		delete(mon.skewed, m.Peer)
+	}
+}
+
 // PublishMetric broadcasts a metric to all current cluster peers.
 func (mon *Monitor) PublishMetric(ctx context.Context, m *api.Metric) error {
 	ctx, span := trace.StartSpan(ctx, "monitor/pubsub/PublishMetric")
@@ -194,6 +240,8 @@ func (mon *Monitor) PublishMetric(ctx context.Context, m *api.Metric) error {
 		return nil
 	}
 
+	m.SentAt = time.Now().UnixNano()
+
 	var b bytes.Buffer
 
 	enc := msgpack.Multicodec(msgpackHandle).Encoder(&b)
@@ -10,8 +10,10 @@ import (
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/datastore/inmem"
 	"github.com/ipfs/ipfs-cluster/test"
 
+	query "github.com/ipfs/go-datastore/query"
 	libp2p "github.com/libp2p/go-libp2p"
 	host "github.com/libp2p/go-libp2p-core/host"
 	peer "github.com/libp2p/go-libp2p-core/peer"
@@ -229,6 +231,43 @@ func TestPeerMonitorLogMetric(t *testing.T) {
 	}
 }
 
+func TestPeerMonitorClockSkew(t *testing.T) {
+	ctx := context.Background()
+	pm, _, shutdown := testPeerMonitor(t)
+	defer shutdown()
+	mf := newMetricFactory()
+
+	isSkewed := func(p peer.ID) bool {
+		pm.skewMux.Lock()
+		defer pm.skewMux.Unlock()
+		_, ok := pm.skewed[p]
+		return ok
+	}
+
+	m := mf.newMetric("test", test.PeerID1)
+	m.SentAt = time.Now().Add(-time.Minute).UnixNano()
+	pm.LogMetric(ctx, m)
+	if !isSkewed(test.PeerID1) {
+		t.Error("peer should have been flagged as skewed")
+	}
+	if m.ClockSkew() < time.Minute {
+		t.Error("clock skew should be at least one minute")
+	}
+
+	m = mf.newMetric("test", test.PeerID1)
+	m.SentAt = time.Now().UnixNano()
+	pm.LogMetric(ctx, m)
+	if isSkewed(test.PeerID1) {
+		t.Error("peer should not be flagged as skewed anymore")
+	}
+
+	// Metrics without timestamp are ignored
+	pm.LogMetric(ctx, mf.newMetric("test", test.PeerID2))
+	if isSkewed(test.PeerID2) {
+		t.Error("peer should not be flagged as skewed")
+	}
+}
+
 func TestPeerMonitorPublishMetric(t *testing.T) {
 	ctx := context.Background()
 	pm, host, shutdown := testPeerMonitor(t)
@@ -335,3 +374,42 @@ func TestMetricsGetsDeleted(t *testing.T) {
 		t.Error("expected no metrics")
 	}
 }
+
+func TestPeerMonitorPersistMetrics(t *testing.T) {
+	ctx := context.Background()
+	store := inmem.New()
+	mf := newMetricFactory()
+
+	pm, _, shutdown := testPeerMonitor(t)
+	pm.SetLocalDatastore(store)
+	pm.LogMetric(ctx, mf.newMetric("test", test.PeerID1))
+	pm.LogMetric(ctx, mf.newMetric("test", test.PeerID2))
+	expired := mf.newMetric("test", test.PeerID3)
+	expired.SetTTL(time.Millisecond)
+	pm.LogMetric(ctx, expired)
+	shutdown()
+
+	time.Sleep(10 * time.Millisecond)
+
+	pm, _, shutdown = testPeerMonitor(t)
+	defer shutdown()
+	pm.SetLocalDatastore(store)
+
+	latestMetrics := pm.LatestMetrics(ctx, "test")
+	if len(latestMetrics) != 2 {
+		t.Fatalf("expected the 2 metrics which have not expired, got %d", len(latestMetrics))
+	}
+	for _, m := range latestMetrics {
+		if m.Peer == test.PeerID3 {
+			t.Error("the expired metric should not have been loaded")
+		}
+	}
+	res, err := store.Query(query.Query{KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := res.Rest()
+	if len(entries) != 2 {
+		t.Errorf("the expired metric should have been removed: %d entries", len(entries))
+	}
+}
@@ -0,0 +1,122 @@
+package observations
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+)
+
+// HTTPHandler wraps the handler of an HTTP API component (i.e. the REST API
+// or the IPFS proxy) so that its requests are recorded in the HTTP metrics:
+// requests served by method and status code, requests in flight, body sizes
+// and latency. Metrics are tagged with the given component name and with
+// the tags already present in ctx (i.e. the host).
+func HTTPHandler(ctx context.Context, component string, h http.Handler) http.Handler {
+	ctx, err := tag.New(ctx, tag.Upsert(ComponentKey, component))
+	if err != nil {
+		logger.Error(err)
+	}
+	return &httpHandler{
+		ctx:     ctx,
+		handler: h,
+	}
+}
+
+type httpHandler struct {
+	ctx      context.Context
+	handler  http.Handler
+	inFlight int64
+}
+
+func (hh *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	stats.Record(hh.ctx, HTTPInFlight.M(atomic.AddInt64(&hh.inFlight, 1)))
+
+	body := &countingReader{ReadCloser: r.Body}
+	if r.Body != nil {
+		r.Body = body
+	}
+	cw := &countingWriter{ResponseWriter: w, status: http.StatusOK}
+
+	defer func() {
+		stats.Record(hh.ctx, HTTPInFlight.M(atomic.AddInt64(&hh.inFlight, -1)))
+		ctx, err := tag.New(
+			hh.ctx,
+			tag.Upsert(HTTPMethodKey, r.Method),
+			tag.Upsert(HTTPStatusKey, strconv.Itoa(cw.status)),
+		)
+		if err != nil {
+			logger.Error(err)
+			ctx = hh.ctx
+		}
+		stats.Record(
+			ctx,
+			HTTPRequests.M(1),
+			HTTPRequestBytes.M(body.n),
+			HTTPResponseBytes.M(cw.n),
+			HTTPLatency.M(float64(time.Since(start))/float64(time.Millisecond)),
+		)
+	}()
+
+	hh.handler.ServeHTTP(cw, r)
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// countingWriter records the status code and counts the bytes of a
+// response. It supports flushing and hijacking, which the wrapped handlers
+// use for streaming responses and for proxying.
+type countingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	n           int64
+}
+
+func (cw *countingWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.status = status
+		cw.wroteHeader = true
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+	n, err := cw.ResponseWriter.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func (cw *countingWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+	return hj.Hijack()
+}
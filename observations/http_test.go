@@ -0,0 +1,66 @@
+package observations
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	if err := view.Register(HTTPRequestsView, HTTPRequestBytesView, HTTPResponseBytesView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(HTTPRequestsView, HTTPRequestBytesView, HTTPResponseBytesView)
+
+	h := HTTPHandler(context.Background(), "test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("1234"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted || w.Body.String() != "hello" {
+		t.Fatal("the response should not be modified")
+	}
+
+	rows, err := view.RetrieveData(HTTPRequestsView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatal("expected a row of requests")
+	}
+	tags := make(map[string]string)
+	for _, tg := range rows[0].Tags {
+		tags[tg.Key.Name()] = tg.Value
+	}
+	if tags["component"] != "test" || tags["method"] != "POST" || tags["status"] != "202" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	if rows[0].Data.(*view.CountData).Value != 1 {
+		t.Error("expected one request")
+	}
+
+	rows, err = view.RetrieveData(HTTPRequestBytesView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.DistributionData).Mean != 4 {
+		t.Error("expected a request body of 4 bytes")
+	}
+
+	rows, err = view.RetrieveData(HTTPResponseBytesView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Data.(*view.DistributionData).Mean != 5 {
+		t.Error("expected a response body of 5 bytes")
+	}
+}
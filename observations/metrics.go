@@ -26,6 +26,9 @@ var (
 var (
 	HostKey       = makeKey("host")
 	RemotePeerKey = makeKey("remote_peer")
+	ComponentKey  = makeKey("component")
+	HTTPMethodKey = makeKey("method")
+	HTTPStatusKey = makeKey("status")
 )
 
 // metrics
@@ -38,6 +41,22 @@ var (
 	Peers = stats.Int64("cluster/peers", "Number of cluster peers", stats.UnitDimensionless)
 	// Alerts is the number of alerts that have been sent due to peers not sending "ping" heartbeats in time.
 	Alerts = stats.Int64("cluster/alerts", "Number of alerts triggered", stats.UnitDimensionless)
+	// IPFSDown is 1 while the IPFS daemon is unreachable and 0 otherwise.
+	IPFSDown = stats.Int64("ipfs/down", "Whether the IPFS daemon is unreachable", stats.UnitDimensionless)
+	// IPFSRestarts counts the times that the IPFS daemon has been found restarted.
+	IPFSRestarts = stats.Int64("ipfs/restarts", "Number of IPFS daemon restarts detected", stats.UnitDimensionless)
+	// IPFSFreeSpace is the free space in the IPFS repository, as seen by the disk informer.
+	IPFSFreeSpace = stats.Int64("ipfs/free_space", "Free space in the IPFS repository", stats.UnitBytes)
+	// HTTPRequests counts the requests served by the HTTP API components.
+	HTTPRequests = stats.Int64("api/requests", "Number of HTTP requests served", stats.UnitDimensionless)
+	// HTTPInFlight is the number of requests being served by the HTTP API components.
+	HTTPInFlight = stats.Int64("api/requests_in_flight", "Number of HTTP requests in flight", stats.UnitDimensionless)
+	// HTTPRequestBytes is the size of the bodies of the requests received by the HTTP API components.
+	HTTPRequestBytes = stats.Int64("api/request_bytes", "Size of HTTP request bodies", stats.UnitBytes)
+	// HTTPResponseBytes is the size of the bodies of the responses sent by the HTTP API components.
+	HTTPResponseBytes = stats.Int64("api/response_bytes", "Size of HTTP response bodies", stats.UnitBytes)
+	// HTTPLatency is the time taken to serve requests by the HTTP API components.
+	HTTPLatency = stats.Float64("api/latency", "Time to serve HTTP requests", stats.UnitMilliseconds)
 )
 
 // views, which is just the aggregation of the metrics
@@ -66,11 +85,67 @@ var (
 		Aggregation: messageCountDistribution,
 	}
 
+	IPFSDownView = &view.View{
+		Measure:     IPFSDown,
+		TagKeys:     []tag.Key{HostKey},
+		Aggregation: view.LastValue(),
+	}
+
+	IPFSRestartsView = &view.View{
+		Measure:     IPFSRestarts,
+		TagKeys:     []tag.Key{HostKey},
+		Aggregation: view.Count(),
+	}
+
+	IPFSFreeSpaceView = &view.View{
+		Measure:     IPFSFreeSpace,
+		TagKeys:     []tag.Key{HostKey},
+		Aggregation: view.LastValue(),
+	}
+
+	HTTPRequestsView = &view.View{
+		Measure:     HTTPRequests,
+		TagKeys:     []tag.Key{HostKey, ComponentKey, HTTPMethodKey, HTTPStatusKey},
+		Aggregation: view.Count(),
+	}
+
+	HTTPInFlightView = &view.View{
+		Measure:     HTTPInFlight,
+		TagKeys:     []tag.Key{HostKey, ComponentKey},
+		Aggregation: view.LastValue(),
+	}
+
+	HTTPRequestBytesView = &view.View{
+		Measure:     HTTPRequestBytes,
+		TagKeys:     []tag.Key{HostKey, ComponentKey},
+		Aggregation: bytesDistribution,
+	}
+
+	HTTPResponseBytesView = &view.View{
+		Measure:     HTTPResponseBytes,
+		TagKeys:     []tag.Key{HostKey, ComponentKey},
+		Aggregation: bytesDistribution,
+	}
+
+	HTTPLatencyView = &view.View{
+		Measure:     HTTPLatency,
+		TagKeys:     []tag.Key{HostKey, ComponentKey},
+		Aggregation: latencyDistribution,
+	}
+
 	DefaultViews = []*view.View{
 		PinsView,
 		TrackerPinsView,
 		PeersView,
 		AlertsView,
+		IPFSDownView,
+		IPFSRestartsView,
+		IPFSFreeSpaceView,
+		HTTPRequestsView,
+		HTTPInFlightView,
+		HTTPRequestBytesView,
+		HTTPResponseBytesView,
+		HTTPLatencyView,
 	}
 )
 
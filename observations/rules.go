@@ -0,0 +1,109 @@
+package observations
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// RuleThresholds carries the configuration values which the generated
+// Prometheus alerting rules are based on.
+type RuleThresholds struct {
+	// PingTTL is how long a peer "ping" metric is valid. Alerts are
+	// triggered for peers whose ping expires.
+	PingTTL time.Duration
+	// PeerWatchInterval is how often peers check the peerset.
+	PeerWatchInterval time.Duration
+	// FreeSpaceTTL is how long the free space metric is valid.
+	FreeSpaceTTL time.Duration
+	// DiskHeadroom is the free space, in bytes, under which an alert is
+	// raised. No disk alert is generated when 0.
+	DiskHeadroom uint64
+}
+
+// PrometheusRules returns a Prometheus rules file (YAML) with alerting rules
+// for the metrics exported by the peers, using the given thresholds.
+func PrometheusRules(th RuleThresholds) ([]byte, error) {
+	if th.PingTTL <= 0 || th.PeerWatchInterval <= 0 || th.FreeSpaceTTL <= 0 {
+		return nil, fmt.Errorf("rule thresholds must be positive")
+	}
+
+	// Missed pings are accounted when the alert triggers, so we look
+	// back at least a couple of ping TTLs.
+	alertsWindow := 2 * th.PingTTL
+	if alertsWindow < time.Minute {
+		alertsWindow = time.Minute
+	}
+
+	tmpl, err := template.New("rules").Delims("[[", "]]").Parse(rulesTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, map[string]interface{}{
+		"AlertsRange":  "[" + promDuration(alertsWindow) + "]",
+		"PingTTL":      promDuration(th.PingTTL),
+		"PeersFor":     promDuration(2 * th.PeerWatchInterval),
+		"FreeSpaceTTL": promDuration(th.FreeSpaceTTL),
+		"DiskHeadroom": th.DiskHeadroom,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// promDuration formats a duration as Prometheus does, rounded up to seconds.
+func promDuration(d time.Duration) string {
+	secs := (d + time.Second - 1) / time.Second
+	return fmt.Sprintf("%ds", secs)
+}
+
+const rulesTemplate = `# Prometheus alerting rules for IPFS Cluster peers, generated with
+# "ipfs-cluster-service metrics rules".
+groups:
+- name: ipfs-cluster
+  rules:
+  - alert: IPFSClusterPeerMetricsMissing
+    expr: increase(ipfscluster_cluster_alerts_count[[ .AlertsRange ]]) > 0
+    labels:
+      severity: warning
+    annotations:
+      summary: "Peer {{ $labels.remote_peer }} is not sending metrics"
+      description: "{{ $labels.host }} has not received a ping from {{ $labels.remote_peer }} within [[ .PingTTL ]]."
+  - alert: IPFSClusterIPFSDown
+    expr: ipfscluster_ipfs_down == 1
+    for: [[ .PingTTL ]]
+    labels:
+      severity: critical
+    annotations:
+      summary: "IPFS is down on {{ $labels.host }}"
+      description: "The IPFS daemon of {{ $labels.host }} has been unreachable for more than [[ .PingTTL ]]."
+  - alert: IPFSClusterIPFSRestarted
+    expr: increase(ipfscluster_ipfs_restarts[1h]) > 0
+    labels:
+      severity: info
+    annotations:
+      summary: "IPFS restarted on {{ $labels.host }}"
+      description: "The IPFS daemon of {{ $labels.host }} restarted in the last hour."
+  - alert: IPFSClusterPeersDecreased
+    expr: ipfscluster_cluster_peers < max_over_time(ipfscluster_cluster_peers[1h])
+    for: [[ .PeersFor ]]
+    labels:
+      severity: warning
+    annotations:
+      summary: "{{ $labels.host }} sees fewer cluster peers"
+      description: "{{ $labels.host }} sees {{ $value }} peers, fewer than in the last hour."
+[[- if .DiskHeadroom ]]
+  - alert: IPFSClusterLowDiskSpace
+    expr: ipfscluster_ipfs_free_space < [[ .DiskHeadroom ]]
+    for: [[ .FreeSpaceTTL ]]
+    labels:
+      severity: warning
+    annotations:
+      summary: "Low disk space on {{ $labels.host }}"
+      description: "The IPFS repository of {{ $labels.host }} has {{ $value | humanize1024 }}B free, under the headroom of [[ .DiskHeadroom ]] bytes."
+[[- end ]]
+`
@@ -0,0 +1,53 @@
+package observations
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusRules(t *testing.T) {
+	th := RuleThresholds{
+		PingTTL:           30 * time.Second,
+		PeerWatchInterval: 5 * time.Second,
+		FreeSpaceTTL:      30 * time.Second,
+	}
+
+	rules, err := PrometheusRules(th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(rules)
+	for _, s := range []string{
+		"ipfscluster_cluster_alerts_count[60s]",
+		"for: 30s",
+		"for: 10s",
+		"{{ $labels.host }}",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("rules should contain %q:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "IPFSClusterLowDiskSpace") {
+		t.Error("no disk rule expected without headroom")
+	}
+
+	th.PingTTL = 90 * time.Second
+	th.DiskHeadroom = 1024
+	rules, err = PrometheusRules(th)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = string(rules)
+	if !strings.Contains(out, "ipfscluster_cluster_alerts_count[180s]") {
+		t.Error("alerts range should be twice the ping TTL")
+	}
+	if !strings.Contains(out, "ipfscluster_ipfs_free_space < 1024") {
+		t.Errorf("expected a disk rule:\n%s", out)
+	}
+
+	th.PingTTL = 0
+	if _, err := PrometheusRules(th); err == nil {
+		t.Error("expected an error with a zero ping TTL")
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"expvar"
 	"net/http"
 	"net/http/pprof"
+	"regexp"
+	"strings"
+	"unicode"
 
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 	manet "github.com/multiformats/go-multiaddr-net"
@@ -20,11 +23,12 @@ import (
 )
 
 // SetupMetrics configures and starts stats tooling,
-// if enabled.
-func SetupMetrics(cfg *MetricsConfig) error {
+// if enabled. The given labels (the tags of the peer) are added to all the
+// exported metrics.
+func SetupMetrics(cfg *MetricsConfig, labels map[string]string) error {
 	if cfg.EnableStats {
 		logger.Infof("stats collection enabled on %s", cfg.PrometheusEndpoint)
-		return setupMetrics(cfg)
+		return setupMetrics(cfg, labels)
 	}
 	return nil
 }
@@ -60,15 +64,32 @@ func SetupTracing(cfg *TracingConfig) (*JaegerTracer, error) {
 	return &JaegerTracer{je}, nil
 }
 
-func setupMetrics(cfg *MetricsConfig) error {
+func setupMetrics(cfg *MetricsConfig, labels map[string]string) error {
+	// the metrics views of interest
+	views := append([]*view.View{}, DefaultViews...)
+	views = append(views,
+		ochttp.ClientCompletedCount,
+		ochttp.ClientRoundtripLatencyDistribution,
+		ochttp.ClientReceivedBytesDistribution,
+		ochttp.ClientSentBytesDistribution,
+		ochttp.ServerRequestCountView,
+		ochttp.ServerRequestBytesView,
+		ochttp.ServerResponseBytesView,
+		ochttp.ServerLatencyView,
+		ochttp.ServerRequestCountByMethod,
+		ochttp.ServerResponseCountByStatusCode,
+	)
+	views = append(views, ocgorpc.DefaultServerViews...)
+
 	// setup Prometheus
 	registry := prom.NewRegistry()
 	goCollector := prom.NewGoCollector()
 	procCollector := prom.NewProcessCollector(prom.ProcessCollectorOpts{})
 	registry.MustRegister(goCollector, procCollector)
 	pe, err := prometheus.NewExporter(prometheus.Options{
-		Namespace: "ipfscluster",
-		Registry:  registry,
+		Namespace:   "ipfscluster",
+		Registry:    registry,
+		ConstLabels: promLabels(labels, views),
 	})
 	if err != nil {
 		return err
@@ -78,29 +99,7 @@ func setupMetrics(cfg *MetricsConfig) error {
 	view.RegisterExporter(pe)
 	view.SetReportingPeriod(cfg.ReportingInterval)
 
-	// register the metrics views of interest
-	if err := view.Register(DefaultViews...); err != nil {
-		return err
-	}
-	if err := view.Register(
-		ochttp.ClientCompletedCount,
-		ochttp.ClientRoundtripLatencyDistribution,
-		ochttp.ClientReceivedBytesDistribution,
-		ochttp.ClientSentBytesDistribution,
-	); err != nil {
-		return err
-	}
-	if err := view.Register(
-		ochttp.ServerRequestCountView,
-		ochttp.ServerRequestBytesView,
-		ochttp.ServerResponseBytesView,
-		ochttp.ServerLatencyView,
-		ochttp.ServerRequestCountByMethod,
-		ochttp.ServerResponseCountByStatusCode,
-	); err != nil {
-		return err
-	}
-	if err := view.Register(ocgorpc.DefaultServerViews...); err != nil {
+	if err := view.Register(views...); err != nil {
 		return err
 	}
 
@@ -156,3 +155,31 @@ func setupTracing(cfg *TracingConfig) (*jaeger.Exporter, error) {
 	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(cfg.SamplingProb)})
 	return je, nil
 }
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// promLabels converts peer tags to Prometheus labels. Invalid characters in
+// the names are replaced by "_" and names which clash with the labels of
+// the given views, or which Prometheus reserves, are prefixed with "tag_".
+func promLabels(tags map[string]string, views []*view.View) prom.Labels {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	reserved := map[string]bool{"le": true} // histogram buckets
+	for _, v := range views {
+		for _, k := range v.TagKeys {
+			reserved[k.Name()] = true
+		}
+	}
+
+	labels := make(prom.Labels, len(tags))
+	for k, v := range tags {
+		name := invalidLabelChars.ReplaceAllString(k, "_")
+		if name == "" || reserved[name] || strings.HasPrefix(name, "__") || unicode.IsDigit(rune(name[0])) {
+			name = "tag_" + name
+		}
+		labels[name] = v
+	}
+	return labels
+}
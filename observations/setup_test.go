@@ -0,0 +1,37 @@
+package observations
+
+import (
+	"testing"
+)
+
+func TestPromLabels(t *testing.T) {
+	if promLabels(nil, DefaultViews) != nil {
+		t.Error("expected no labels")
+	}
+
+	labels := promLabels(map[string]string{
+		"region":    "eu",
+		"cloud.env": "prod",
+		"host":      "a",
+		"le":        "b",
+		"1st":       "c",
+		"__name":    "d",
+	}, DefaultViews)
+
+	expected := map[string]string{
+		"region":     "eu",
+		"cloud_env":  "prod",
+		"tag_host":   "a",
+		"tag_le":     "b",
+		"tag_1st":    "c",
+		"tag___name": "d",
+	}
+	if len(labels) != len(expected) {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+	for k, v := range expected {
+		if labels[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, labels[k])
+		}
+	}
+}
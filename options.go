@@ -0,0 +1,326 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/allocator/descendalloc"
+	"github.com/ipfs/ipfs-cluster/allocator/hrwalloc"
+	"github.com/ipfs/ipfs-cluster/allocator/util"
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/datastore/inmem"
+	"github.com/ipfs/ipfs-cluster/pstoremgr"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	ds "github.com/ipfs/go-datastore"
+	host "github.com/libp2p/go-libp2p-core/host"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	discovery "github.com/libp2p/go-libp2p/p2p/discovery"
+)
+
+// Option sets one of the components of a Cluster peer created with New.
+type Option func(*options)
+
+type options struct {
+	dht       *dht.IpfsDHT
+	datastore ds.Datastore
+	consensus Consensus
+	apis      []API
+	ipfs      IPFSConnector
+	tracker   PinTracker
+	monitor   PeerMonitor
+	allocator PinAllocator
+	informers []Informer
+	tracer    Tracer
+}
+
+// WithDHT sets the DHT used for peer discovery and routing. The peer runs
+// without a DHT when not set.
+func WithDHT(d *dht.IpfsDHT) Option {
+	return func(o *options) { o.dht = d }
+}
+
+// WithDatastore sets the datastore used by the peer to persist data locally.
+// An in-memory datastore is used when not set. The datastore is closed on
+// shutdown.
+func WithDatastore(d ds.Datastore) Option {
+	return func(o *options) { o.datastore = d }
+}
+
+// WithConsensus sets the Consensus component. It is required.
+func WithConsensus(c Consensus) Option {
+	return func(o *options) { o.consensus = c }
+}
+
+// WithAPIs adds API components. The peer runs without APIs when not set.
+func WithAPIs(apis ...API) Option {
+	return func(o *options) { o.apis = append(o.apis, apis...) }
+}
+
+// WithIPFSConnector sets the IPFSConnector component. It is required.
+func WithIPFSConnector(ipfs IPFSConnector) Option {
+	return func(o *options) { o.ipfs = ipfs }
+}
+
+// WithPinTracker sets the PinTracker component. It is required.
+func WithPinTracker(t PinTracker) Option {
+	return func(o *options) { o.tracker = t }
+}
+
+// WithPeerMonitor sets the PeerMonitor component. It is required.
+func WithPeerMonitor(m PeerMonitor) Option {
+	return func(o *options) { o.monitor = m }
+}
+
+// WithPinAllocator sets the PinAllocator component. When not set, the
+// allocator selected by Config.Allocator is used.
+func WithPinAllocator(a PinAllocator) Option {
+	return func(o *options) { o.allocator = a }
+}
+
+// WithInformers adds Informer components. At least one is required.
+func WithInformers(informers ...Informer) Option {
+	return func(o *options) { o.informers = append(o.informers, informers...) }
+}
+
+// WithTracer sets the Tracer component. Nothing is traced when not set.
+func WithTracer(t Tracer) Option {
+	return func(o *options) { o.tracer = t }
+}
+
+// New creates an IPFS Cluster peer with the given libp2p host,
+// configuration and components, without starting it. This allows
+// embedding a cluster peer in other programs:
+//
+//	c, err := ipfscluster.New(ctx, host, cfg,
+//	        ipfscluster.WithConsensus(consensus),
+//	        ipfscluster.WithIPFSConnector(connector),
+//	        ipfscluster.WithPinTracker(tracker),
+//	        ipfscluster.WithPeerMonitor(monitor),
+//	        ipfscluster.WithInformers(informer),
+//	)
+//	...
+//	err = c.Start(ctx)
+//	...
+//	err = c.WaitReady(ctx)
+//	...
+//	defer c.Shutdown(ctx)
+//
+// The peer lives until Shutdown is called or the given context is
+// cancelled. Components must be created beforehand and are shut down along
+// with the peer.
+func New(ctx context.Context, host host.Host, cfg *Config, opts ...Option) (*Cluster, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	if host == nil {
+		return nil, errors.New("cluster host is nil")
+	}
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	switch {
+	case o.consensus == nil:
+		return nil, errors.New("no consensus component is passed")
+	case o.ipfs == nil:
+		return nil, errors.New("no IPFS connector component is passed")
+	case o.tracker == nil:
+		return nil, errors.New("no pin tracker component is passed")
+	case o.monitor == nil:
+		return nil, errors.New("no peer monitor component is passed")
+	case len(o.informers) == 0:
+		return nil, errors.New("no informers are passed")
+	}
+	if o.datastore == nil {
+		o.datastore = inmem.New()
+	}
+	if o.tracer == nil {
+		o.tracer = noopTracer{}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	c := &Cluster{
+		ctx:         ctx,
+		cancel:      cancel,
+		id:          host.ID(),
+		config:      cfg,
+		host:        host,
+		dht:         o.dht,
+		datastore:   o.datastore,
+		consensus:   o.consensus,
+		apis:        o.apis,
+		ipfs:        o.ipfs,
+		tracker:     o.tracker,
+		monitor:     o.monitor,
+		allocator:   o.allocator,
+		informers:   o.informers,
+		tracer:      o.tracer,
+		peerManager: pstoremgr.New(ctx, host, cfg.GetPeerstorePath()),
+		alerts:      make(map[string]*api.Alert),
+		unreachable: make(map[peer.ID]struct{}),
+		repins:      make(map[peer.ID]*api.RepinProgress),
+		recovers:    make(map[string]*recoverSchedule),
+		jobs:        make(map[string]*job),
+		jobsCh:      make(chan struct{}, 1),
+		downSince:   make(map[peer.ID]time.Time),
+		shutdownB:   false,
+		removed:     false,
+		doneCh:      make(chan struct{}),
+		readyCh:     make(chan struct{}),
+		readyB:      false,
+		drainCh:     make(chan struct{}),
+		pinIndex:    newPinIndex(),
+		statusPager: newPinInfoPager(),
+	}
+	if c.allocator == nil {
+		c.allocator = c.newAllocator()
+	}
+	return c, nil
+}
+
+// Start starts a peer created with New: it connects to the known cluster
+// peers, starts the RPC server, which the components use from then on,
+// and lets the peer join the cluster in the background. Use Ready() or
+// WaitReady() to know when the peer is fully up. The peer is shut down
+// when Start fails.
+func (c *Cluster) Start(ctx context.Context) error {
+	c.shutdownLock.Lock()
+	if c.shutdownB {
+		c.shutdownLock.Unlock()
+		return errors.New("the cluster peer has been shut down")
+	}
+	if c.startedB {
+		c.shutdownLock.Unlock()
+		return errors.New("the cluster peer has already been started")
+	}
+	c.startedB = true
+	c.shutdownLock.Unlock()
+
+	// Before any metric is logged below and before the components
+	// get their RPC client.
+	c.setupLocalPersisters()
+
+	listenAddrs := ""
+	for _, addr := range c.host.Addrs() {
+		listenAddrs += fmt.Sprintf("        %s/p2p/%s\n", addr, c.host.ID().Pretty())
+	}
+
+	logger.Infof("IPFS Cluster v%s listening on:\n%s\n", version.Version, listenAddrs)
+
+	if c.config.MDNSInterval > 0 {
+		mdns, err := discovery.NewMdnsService(c.ctx, c.host, c.config.MDNSInterval, mdnsServiceTag)
+		if err != nil {
+			c.Shutdown(ctx)
+			return err
+		}
+		mdns.RegisterNotifee(c.peerManager)
+		c.discovery = mdns
+	}
+
+	// Import known cluster peers from peerstore file and config. Set
+	// a non permanent TTL.
+	c.peerManager.ImportPeersFromPeerstore(false, peerstore.AddressTTL)
+	c.peerManager.ImportPeers(c.config.PeerAddresses, false, peerstore.AddressTTL)
+	// Attempt to connect to some peers (up to bootstrapCount)
+	connectedPeers := c.peerManager.Bootstrap(bootstrapCount)
+	// We cannot warn when count is low as this as this is normal if going
+	// to Join() later.
+	logger.Debugf("bootstrap count %d", len(connectedPeers))
+	// Log a ping metric for every connected peer. This will make them
+	// visible as peers without having to wait for them to send one.
+	for _, p := range connectedPeers {
+		if err := c.logPingMetric(ctx, p); err != nil {
+			logger.Warning(err)
+		}
+	}
+
+	// Bootstrap the DHT now that we possibly have some connections
+	if c.dht != nil {
+		c.dht.Bootstrap(c.ctx)
+	}
+
+	if err := c.loadMaintenance(); err != nil {
+		logger.Errorf("error loading maintenance mode: %s", err)
+	}
+	if err := c.loadStandby(); err != nil {
+		logger.Errorf("error loading standby mode: %s", err)
+	}
+	if err := c.loadAllocationExclusions(); err != nil {
+		logger.Errorf("error loading allocation exclusions: %s", err)
+	}
+	if err := c.loadProvideStrategy(); err != nil {
+		logger.Errorf("error loading the provide strategy: %s", err)
+	}
+
+	// Jobs are loaded before the APIs can submit new ones. Queued jobs
+	// run once the peer is ready.
+	if err := c.loadJobs(); err != nil {
+		logger.Errorf("error loading jobs: %s", err)
+	}
+
+	// After setupRPC components can do their tasks with a fully operative
+	// routed libp2p host with some connections and a working DHT (hopefully).
+	err := c.setupRPC()
+	if err != nil {
+		c.Shutdown(ctx)
+		return err
+	}
+	c.setupRPCClients()
+
+	// Note: It is very important to first call Add() once in a non-racy
+	// place
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.ready(ReadyTimeout)
+		c.run()
+	}()
+
+	return nil
+}
+
+// WaitReady blocks until the peer is ready, it is shut down or the given
+// context is cancelled.
+func (c *Cluster) WaitReady(ctx context.Context) error {
+	select {
+	case <-c.Ready():
+		return nil
+	case <-c.Done():
+		return errors.New("the cluster peer was shut down before becoming ready")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// noopTracer is the Tracer used when none is given.
+type noopTracer struct{}
+
+func (noopTracer) SetClient(*rpc.Client) {}
+
+func (noopTracer) Shutdown(context.Context) error { return nil }
+
+// allocatorDatastore is the name of the local datastore where the
+// allocator keeps its state.
+const allocatorDatastore = "allocator"
+
+// newAllocator returns the PinAllocator selected by Config.Allocator,
+// breaking ties as set in Config.AllocatorTieBreak.
+func (c *Cluster) newAllocator() PinAllocator {
+	switch c.config.Allocator {
+	case AllocatorHRW:
+		return hrwalloc.NewAllocator()
+	default:
+		tb := util.NewTieBreaker(c.config.AllocatorTieBreak, c.localDatastore(allocatorDatastore))
+		return descendalloc.NewAllocatorWithTieBreaker(tb)
+	}
+}
@@ -0,0 +1,219 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	net "github.com/libp2p/go-libp2p-core/network"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	peerstore "github.com/libp2p/go-libp2p-core/peerstore"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.opencensus.io/trace"
+)
+
+// exchangeAddrs regularly sends the addresses we know for cluster peers
+// to the rest of the cluster, so that peers which cannot reach each other
+// directly (i.e. because they are behind NAT) learn about the addresses
+// observed by others and about relay addresses.
+func (c *Cluster) exchangeAddrs() {
+	ticker := time.NewTicker(addrExchangeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.shareAddrs(c.ctx)
+			if err != nil {
+				logger.Debugf("error sharing peer addresses: %s", err)
+			}
+		}
+	}
+}
+
+func (c *Cluster) shareAddrs(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/shareAddrs")
+	defer span.End()
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+
+	addrs := c.knownAddrs(peers)
+
+	var others []peer.ID
+	for _, p := range peers {
+		if p != c.id {
+			others = append(others, p)
+		}
+	}
+
+	errs := c.multiCall(
+		ctx,
+		others,
+		"Cluster",
+		"ImportPeerAddrs",
+		addrs,
+		rpcutil.RPCDiscardReplies(len(others)),
+	)
+	return rpcutil.CheckErrs(errs)
+}
+
+// knownAddrs returns the addresses of this peer (including relay
+// addresses) and, for the given peers we are connected to, their announced
+// addresses and the addresses we observe their connections coming from.
+func (c *Cluster) knownAddrs(peers []peer.ID) []api.Multiaddr {
+	seen := make(map[string]struct{})
+	var addrs []api.Multiaddr
+	add := func(p peer.ID, addr ma.Multiaddr) {
+		p2pAddrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: p, Addrs: []ma.Multiaddr{addr}})
+		if err != nil || len(p2pAddrs) == 0 {
+			return
+		}
+		key := p2pAddrs[0].String()
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		addrs = append(addrs, api.NewMultiaddrWithValue(p2pAddrs[0]))
+	}
+
+	for _, a := range c.host.Addrs() {
+		add(c.id, a)
+	}
+
+	for _, p := range peers {
+		if p == c.id || c.host.Network().Connectedness(p) != net.Connected {
+			continue
+		}
+		for _, a := range c.host.Peerstore().Addrs(p) {
+			add(p, a)
+		}
+		for _, conn := range c.host.Network().ConnsToPeer(p) {
+			add(p, conn.RemoteMultiaddr())
+		}
+	}
+	return addrs
+}
+
+// ImportPeerAddrs adds the given addresses of cluster peers, as sent by
+// other peers, to the peerstore. Addresses of peers which are not part of
+// the cluster are ignored. We attempt to connect to the cluster peers we
+// are not connected to.
+func (c *Cluster) ImportPeerAddrs(ctx context.Context, addrs []api.Multiaddr) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/ImportPeerAddrs")
+	defer span.End()
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+	peerset := make(map[peer.ID]struct{}, len(peers))
+	for _, p := range peers {
+		peerset[p] = struct{}{}
+	}
+
+	for _, a := range addrs {
+		pinfo, err := peer.AddrInfoFromP2pAddr(a.Value())
+		if err != nil {
+			logger.Debugf("ignoring address %s: %s", a.Value(), err)
+			continue
+		}
+		if _, ok := peerset[pinfo.ID]; !ok || pinfo.ID == c.id {
+			continue
+		}
+		connect := c.host.Network().Connectedness(pinfo.ID) != net.Connected
+		_, err = c.peerManager.ImportPeer(a.Value(), connect, peerstore.AddressTTL)
+		if err != nil {
+			logger.Debugf("error importing address %s: %s", a.Value(), err)
+		}
+	}
+	return nil
+}
+
+// KnownPeers returns the cluster peers in the peerstore (including this
+// one), with their names, addresses and connection manager tags, in order
+// of priority. Peers without known addresses are omitted.
+func (c *Cluster) KnownPeers(ctx context.Context) ([]*api.KnownPeer, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/KnownPeers")
+	defer span.End()
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[peer.ID]string)
+	for _, id := range c.Peers(ctx) {
+		if id.Error == "" {
+			names[id.ID] = id.Peername
+		}
+	}
+
+	self := &api.KnownPeer{
+		ID:       c.id,
+		Peername: c.config.Peername,
+	}
+	for _, a := range c.host.Addrs() {
+		self.Addresses = append(self.Addresses, api.NewMultiaddrWithValue(a))
+	}
+	known := []*api.KnownPeer{self}
+
+	for _, pinfo := range c.peerManager.PeerInfos(peers) {
+		kp := &api.KnownPeer{
+			ID:       pinfo.ID,
+			Peername: names[pinfo.ID],
+		}
+		for _, a := range pinfo.Addrs {
+			kp.Addresses = append(kp.Addresses, api.NewMultiaddrWithValue(a))
+		}
+		if tagInfo := c.host.ConnManager().GetTagInfo(pinfo.ID); tagInfo != nil && len(tagInfo.Tags) > 0 {
+			kp.Tags = tagInfo.Tags
+		}
+		known = append(known, kp)
+	}
+	return known, nil
+}
+
+// ImportKnownPeers adds the addresses of the given peers to the peerstore,
+// applies their connection manager tags and saves the peerstore file.
+// Peers are given priority in the order they come. We attempt to connect
+// to the peers we are not connected to.
+func (c *Cluster) ImportKnownPeers(ctx context.Context, known []*api.KnownPeer) error {
+	_, span := trace.StartSpan(ctx, "cluster/ImportKnownPeers")
+	defer span.End()
+
+	connman := c.host.ConnManager()
+	for i, kp := range known {
+		if kp.ID == c.id {
+			continue
+		}
+		pinfo := &peer.AddrInfo{ID: kp.ID}
+		for _, a := range kp.Addresses {
+			pinfo.Addrs = append(pinfo.Addrs, a.Value())
+		}
+		addrs, err := peer.AddrInfoToP2pAddrs(pinfo)
+		if err != nil {
+			return err
+		}
+
+		connect := c.host.Network().Connectedness(kp.ID) != net.Connected
+		for _, a := range addrs {
+			_, err := c.peerManager.ImportPeer(a, connect, peerstore.PermanentAddrTTL)
+			if err != nil {
+				return err
+			}
+		}
+		c.peerManager.SetPriority(kp.ID, i)
+
+		for tag, v := range kp.Tags {
+			connman.TagPeer(kp.ID, tag, v)
+		}
+	}
+	return c.peerManager.SavePeerstoreForPeers(c.host.Peerstore().Peers())
+}
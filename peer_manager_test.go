@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -508,6 +509,165 @@ func TestClustersPeerRemoveReallocsPins(t *testing.T) {
 	}
 }
 
+func TestClustersPeerRemoveDryRun(t *testing.T) {
+	ctx := context.Background()
+	clusters, mocks := createClusters(t)
+	defer shutdownClusters(t, clusters, mocks)
+
+	if len(clusters) < 3 {
+		t.Skip("test needs at least 3 clusters")
+	}
+
+	for _, c := range clusters {
+		c.config.ReplicationFactorMin = nClusters - 1
+		c.config.ReplicationFactorMax = nClusters - 1
+	}
+
+	waitForLeaderAndMetrics(t, clusters)
+
+	prefix := test.Cid1.Prefix()
+	for i := 0; i < nClusters; i++ {
+		h, err := prefix.Sum(randomBytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = clusters[0].Pin(ctx, h, api.PinOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ttlDelay()
+	}
+	pinDelay()
+
+	chosenID := clusters[1].host.ID()
+	report, err := clusters[0].PeerRemoveDryRun(ctx, chosenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.AllocatedPins != nClusters-1 {
+		t.Errorf("expected %d allocated pins, got %d", nClusters-1, report.AllocatedPins)
+	}
+	if report.ReallocatedPins != report.AllocatedPins {
+		t.Errorf("expected all pins to be re-allocated: %+v", report)
+	}
+	total := 0
+	for p, n := range report.Receivers {
+		if p == peer.IDB58Encode(chosenID) {
+			t.Error("removed peer should not receive pins")
+		}
+		total += n
+	}
+	if total != report.ReallocatedPins {
+		t.Errorf("expected %d received pins, got %d", report.ReallocatedPins, total)
+	}
+
+	// Nothing should have been removed
+	if len(clusters[0].Peers(ctx)) != nClusters {
+		t.Error("dry run should not remove the peer")
+	}
+}
+
+func TestClustersRotatePeer(t *testing.T) {
+	ctx := context.Background()
+	clusters, mocks := createClusters(t)
+	defer shutdownClusters(t, clusters, mocks)
+
+	if len(clusters) < 2 {
+		t.Skip("test needs at least 2 clusters")
+	}
+
+	for _, c := range clusters {
+		c.config.ReplicationFactorMin = 1
+		c.config.ReplicationFactorMax = 1
+	}
+
+	h := test.Cid1
+	_, err := clusters[0].Pin(ctx, h, api.PinOptions{
+		UserAllocations: []peer.ID{clusters[1].id},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	newIdent, err := config.NewIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rot := &api.PeerRotation{
+		Old: clusters[1].id,
+		New: newIdent.ID,
+	}
+
+	// Signed with the wrong key
+	err = rot.Sign(newIdent.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clusters[0].RotatePeer(ctx, rot)
+	if err == nil {
+		t.Fatal("expected an error with a bad signature")
+	}
+
+	err = rot.Sign(clusters[1].host.Peerstore().PrivKey(clusters[1].id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clusters[0].RotatePeer(ctx, rot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinDelay()
+
+	pin, err := clusters[0].PinGet(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsPeer(pin.Allocations, rot.Old) || !containsPeer(pin.Allocations, rot.New) {
+		t.Errorf("allocations were not rotated: %s", pin.Allocations)
+	}
+}
+
+func TestClustersImportPeerAddrs(t *testing.T) {
+	ctx := context.Background()
+	clusters, mocks := createClusters(t)
+	defer shutdownClusters(t, clusters, mocks)
+
+	if len(clusters) < 2 {
+		t.Skip("test needs at least 2 clusters")
+	}
+
+	relayAddr, _ := ma.NewMultiaddr("/ip4/1.2.3.4/tcp/4001/p2p/" + test.PeerID1.Pretty() + "/p2p-circuit/p2p/" + clusters[1].id.Pretty())
+	unknownAddr, _ := ma.NewMultiaddr("/ip4/1.2.3.5/tcp/4001/p2p/" + test.PeerID2.Pretty())
+	err := clusters[0].ImportPeerAddrs(ctx, []api.Multiaddr{
+		api.NewMultiaddrWithValue(relayAddr),
+		api.NewMultiaddrWithValue(unknownAddr),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, a := range clusters[0].host.Peerstore().Addrs(clusters[1].id) {
+		if strings.Contains(a.String(), "p2p-circuit") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected the relay address to be imported")
+	}
+
+	if len(clusters[0].host.Peerstore().Addrs(test.PeerID2)) != 0 {
+		t.Error("addresses of peers outside the cluster should be ignored")
+	}
+
+	known := clusters[0].knownAddrs([]peer.ID{clusters[0].id, clusters[1].id})
+	if len(known) == 0 {
+		t.Error("expected some known addresses")
+	}
+}
+
 func TestClustersPeerJoin(t *testing.T) {
 	ctx := context.Background()
 	clusters, mocks, boot := peerManagerClusters(t)
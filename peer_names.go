@@ -0,0 +1,38 @@
+package ipfscluster
+
+import (
+	"context"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// PeerNames returns the peernames of the cluster peers known to this peer,
+// indexed by their peer ID. Peernames travel with the ping metrics, so a
+// peer appears here once its first ping metric has been received. Peers
+// without a configured peername are not included.
+func (c *Cluster) PeerNames(ctx context.Context) map[string]string {
+	_, span := trace.StartSpan(ctx, "cluster/PeerNames")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	names := make(map[string]string)
+	for _, m := range c.monitor.LatestMetrics(ctx, pingMetricName) {
+		if m.Peername != "" {
+			names[peer.IDB58Encode(m.Peer)] = m.Peername
+		}
+	}
+	if c.config.Peername != "" {
+		names[peer.IDB58Encode(c.id)] = c.config.Peername
+	}
+	return names
+}
+
+// peerName returns the peername of the given peer from the given
+// map, or the peer ID when it is not known.
+func peerName(names map[string]string, p peer.ID) string {
+	if name, ok := names[peer.IDB58Encode(p)]; ok {
+		return name
+	}
+	return peer.IDB58Encode(p)
+}
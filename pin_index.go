@@ -0,0 +1,357 @@
+package ipfscluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// cidSet is a set of CIDs.
+type cidSet map[cid.Cid]struct{}
+
+// cidIndex maps index keys to the CIDs of the pins with that key.
+type cidIndex map[string]cidSet
+
+func (ci cidIndex) add(key string, c cid.Cid) {
+	if ci[key] == nil {
+		ci[key] = make(cidSet)
+	}
+	ci[key][c] = struct{}{}
+}
+
+func (ci cidIndex) del(key string, c cid.Cid) {
+	delete(ci[key], c)
+	if len(ci[key]) == 0 {
+		delete(ci, key)
+	}
+}
+
+// pinIndex keeps an in-memory copy of the shared pinset with secondary
+// indexes by metadata key, by allocation, by type and by collection, so that filtered pin
+// listings do not need to go through the whole state. It also keeps the
+// quota usage of every namespace and user.
+//
+// The index is updated incrementally with every pin and unpin applied to the
+// shared state (as they reach the PinTracker) and rebuilt from the state on
+// every StateSync to correct any drift. It is not used until the first
+// rebuild.
+type pinIndex struct {
+	mu    sync.RWMutex
+	ready bool
+
+	pins    map[cid.Cid]*api.Pin
+	byMeta  cidIndex
+	byAlloc cidIndex
+	byType  cidIndex
+	byColl  cidIndex
+
+	// pins without allocations, which all peers pin.
+	everywhere cidSet
+
+	// quota usage by namespace and by user (see countQuotaUsage).
+	nsUsage   map[string]*api.QuotaUsage
+	userUsage map[string]*api.QuotaUsage
+
+	// changes received while the pinset is being listed for a rebuild.
+	// They are re-applied on top of the listing.
+	rebuilding bool
+	pending    []pinIndexChange
+}
+
+type pinIndexChange struct {
+	pin *api.Pin
+	rm  bool
+}
+
+func newPinIndex() *pinIndex {
+	idx := &pinIndex{}
+	idx.clear()
+	return idx
+}
+
+func (idx *pinIndex) clear() {
+	idx.pins = make(map[cid.Cid]*api.Pin)
+	idx.byMeta = make(cidIndex)
+	idx.byAlloc = make(cidIndex)
+	idx.byType = make(cidIndex)
+	idx.byColl = make(cidIndex)
+	idx.everywhere = make(cidSet)
+	idx.nsUsage = make(map[string]*api.QuotaUsage)
+	idx.userUsage = make(map[string]*api.QuotaUsage)
+}
+
+// add indexes a pin, replacing any previous version of it.
+func (idx *pinIndex) add(pin *api.Pin) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.rebuilding {
+		idx.pending = append(idx.pending, pinIndexChange{pin: pin})
+	}
+	idx.put(pin)
+}
+
+// rm removes a pin from the index.
+func (idx *pinIndex) rm(c cid.Cid) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.rebuilding {
+		idx.pending = append(idx.pending, pinIndexChange{pin: &api.Pin{Cid: c}, rm: true})
+	}
+	idx.del(c)
+}
+
+// startRebuild must be called before listing the pinset given to
+// finishRebuild, so that concurrent changes are not lost.
+func (idx *pinIndex) startRebuild() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.rebuilding = true
+	idx.pending = nil
+}
+
+// finishRebuild replaces the contents of the index with the given pinset
+// and the changes received since startRebuild, and marks it as ready. When
+// the pinset could not be listed (nil), the rebuild is abandoned.
+func (idx *pinIndex) finishRebuild(pins []*api.Pin) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	pending := idx.pending
+	idx.rebuilding = false
+	idx.pending = nil
+	if pins == nil {
+		return
+	}
+
+	idx.clear()
+	for _, pin := range pins {
+		idx.put(pin)
+	}
+	for _, ch := range pending {
+		if ch.rm {
+			idx.del(ch.pin.Cid)
+			continue
+		}
+		idx.put(ch.pin)
+	}
+	idx.ready = true
+}
+
+// query returns the pins matching the given query, sorted by CID, and
+// whether the index was ready to answer it.
+func (idx *pinIndex) query(q *api.PinQuery) ([]*api.Pin, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if !idx.ready {
+		return nil, false
+	}
+
+	// Walk the smallest candidate set among the indexes that apply.
+	var candidates cidSet
+	picked := false
+	pick := func(set cidSet) {
+		if !picked || len(set) < len(candidates) {
+			candidates = set
+			picked = true
+		}
+	}
+	for k := range q.Metadata {
+		pick(idx.byMeta[k])
+	}
+	if q.Allocation != "" {
+		pick(idx.byAlloc[string(q.Allocation)])
+	}
+	var types []api.PinType
+	for _, t := range []api.PinType{api.DataType, api.MetaType, api.ClusterDAGType, api.ShardType} {
+		if q.Type&t > 0 {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 1 {
+		pick(idx.byType[types[0].String()])
+	}
+	if q.Collection != "" {
+		pick(idx.byColl[q.Collection])
+	}
+
+	out := []*api.Pin{}
+	if !picked {
+		for _, pin := range idx.pins {
+			if q.Matches(pin) {
+				out = append(out, pin)
+			}
+		}
+	} else {
+		for c := range candidates {
+			if pin := idx.pins[c]; q.Matches(pin) {
+				out = append(out, pin)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Cid.KeyString() < out[j].Cid.KeyString()
+	})
+	return out, true
+}
+
+// countAllocated returns the number of pins that the given peer should be
+// pinning: those allocated to it and those pinned everywhere. It returns
+// false when the index is not ready.
+func (idx *pinIndex) countAllocated(pid peer.ID) (int, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if !idx.ready {
+		return 0, false
+	}
+	return len(idx.byAlloc[string(pid)]) + len(idx.everywhere), true
+}
+
+// quotaUsage returns a copy of the quota usage of every namespace and user
+// with pins, by name. It returns false when the index is not ready.
+func (idx *pinIndex) quotaUsage() (namespaces, users map[string]*api.QuotaUsage, ok bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if !idx.ready {
+		return nil, nil, false
+	}
+	copyUsage := func(usage map[string]*api.QuotaUsage) map[string]*api.QuotaUsage {
+		cp := make(map[string]*api.QuotaUsage, len(usage))
+		for k, u := range usage {
+			uCopy := *u
+			cp[k] = &uCopy
+		}
+		return cp
+	}
+	return copyUsage(idx.nsUsage), copyUsage(idx.userUsage), true
+}
+
+func (idx *pinIndex) put(pin *api.Pin) {
+	idx.del(pin.Cid)
+	idx.pins[pin.Cid] = pin
+	for k := range pin.Metadata {
+		idx.byMeta.add(k, pin.Cid)
+	}
+	for _, p := range pin.Allocations {
+		idx.byAlloc.add(string(p), pin.Cid)
+	}
+	if len(pin.Allocations) == 0 && pin.Type != api.MetaType {
+		idx.everywhere[pin.Cid] = struct{}{}
+	}
+	idx.byType.add(pin.Type.String(), pin.Cid)
+	if pin.Collection != "" {
+		idx.byColl.add(pin.Collection, pin.Cid)
+	}
+	countQuotaUsage(idx.nsUsage, idx.userUsage, pin, 1)
+}
+
+func (idx *pinIndex) del(c cid.Cid) {
+	pin, ok := idx.pins[c]
+	if !ok {
+		return
+	}
+	delete(idx.pins, c)
+	for k := range pin.Metadata {
+		idx.byMeta.del(k, c)
+	}
+	for _, p := range pin.Allocations {
+		idx.byAlloc.del(string(p), c)
+	}
+	delete(idx.everywhere, c)
+	idx.byType.del(pin.Type.String(), c)
+	if pin.Collection != "" {
+		idx.byColl.del(pin.Collection, c)
+	}
+	countQuotaUsage(idx.nsUsage, idx.userUsage, pin, -1)
+}
+
+// rebuildPinIndex rebuilds the pinset index from the shared state.
+func (c *Cluster) rebuildPinIndex(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/rebuildPinIndex")
+	defer span.End()
+
+	c.pinIndex.startRebuild()
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		c.pinIndex.finishRebuild(nil)
+		return err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		c.pinIndex.finishRebuild(nil)
+		return err
+	}
+	if pins == nil {
+		pins = []*api.Pin{}
+	}
+	c.pinIndex.finishRebuild(pins)
+	return nil
+}
+
+// PinsQuery returns the pins in the shared state which match the given
+// query, sorted by CID. It uses the in-memory pinset index when available
+// and falls back to going through the state otherwise.
+func (c *Cluster) PinsQuery(ctx context.Context, q *api.PinQuery) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsQuery")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if pins, ok := c.pinIndex.query(q); ok {
+		return pins, nil
+	}
+
+	pins, err := c.Pins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := []*api.Pin{}
+	for _, pin := range pins {
+		if q.Matches(pin) {
+			out = append(out, pin)
+		}
+	}
+	return out, nil
+}
+
+// PinsView returns a page of the pins in the shared state which match the
+// given query, sorted by CID, along with their status in the cluster peers.
+// All the matching pins are returned when the limit is 0.
+func (c *Cluster) PinsView(ctx context.Context, q *api.PinViewQuery) (*api.PinViewPage, error) {
+	_, span := trace.StartSpan(ctx, "cluster/PinsView")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	pins, err := c.PinsQuery(ctx, &q.PinQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &api.PinViewPage{
+		Items:  []*api.PinView{},
+		Offset: q.Offset,
+		Total:  len(pins),
+	}
+	if q.Offset >= len(pins) {
+		return page, nil
+	}
+	pins = pins[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(pins) {
+		pins = pins[:q.Limit]
+	}
+
+	for _, pin := range pins {
+		gpi, err := c.Status(ctx, pin.Cid)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, &api.PinView{
+			Pin:    pin,
+			Status: gpi,
+		})
+	}
+	return page, nil
+}
@@ -0,0 +1,110 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// pinsDAGLeafSize is the maximum number of entries listed in every leaf
+// node of a pins DAG, so that blocks stay well below the block size limit.
+var pinsDAGLeafSize = 1000
+
+// makePinsDAG builds a DAG of dag-cbor nodes listing the given entries. The
+// root holds the given fields, the number of entries ("count") and links
+// to the leaves ("leaves"), which hold up to pinsDAGLeafSize entries each
+// ("pins"). The root is the first node returned.
+func makePinsDAG(fields map[string]interface{}, entries []interface{}) ([]ipld.Node, error) {
+	var leaves []ipld.Node
+	for start := 0; start < len(entries); start += pinsDAGLeafSize {
+		end := start + pinsDAGLeafSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		leaf, err := cbor.WrapObject(
+			map[string]interface{}{"pins": entries[start:end]},
+			mh.SHA2_256, -1,
+		)
+		if err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	links := make([]interface{}, 0, len(leaves))
+	for _, leaf := range leaves {
+		links = append(links, leaf.Cid())
+	}
+	rootObj := make(map[string]interface{}, len(fields)+2)
+	for k, v := range fields {
+		rootObj[k] = v
+	}
+	rootObj["count"] = len(entries)
+	rootObj["leaves"] = links
+	root, err := cbor.WrapObject(rootObj, mh.SHA2_256, -1)
+	if err != nil {
+		return nil, err
+	}
+	return append([]ipld.Node{root}, leaves...), nil
+}
+
+// readPinsDAG returns the entries listed in the pins DAG under the given
+// root, fetching its blocks with getBlock.
+func readPinsDAG(ctx context.Context, root cid.Cid, getBlock func(context.Context, cid.Cid) ([]byte, error)) ([]interface{}, error) {
+	rootNode, err := decodePinsDAGNode(ctx, root, getBlock)
+	if err != nil {
+		return nil, err
+	}
+	leaves, _, err := rootNode.Resolve([]string{"leaves"})
+	if err != nil {
+		return nil, err
+	}
+	links, ok := leaves.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: leaves is not a list", root)
+	}
+
+	var entries []interface{}
+	for _, l := range links {
+		leafCid, ok := l.(cid.Cid)
+		if !ok {
+			return nil, fmt.Errorf("%s: leaves should be links", root)
+		}
+		leafNode, err := decodePinsDAGNode(ctx, leafCid, getBlock)
+		if err != nil {
+			return nil, err
+		}
+		pins, _, err := leafNode.Resolve([]string{"pins"})
+		if err != nil {
+			return nil, err
+		}
+		list, ok := pins.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: pins is not a list", leafCid)
+		}
+		entries = append(entries, list...)
+	}
+	return entries, nil
+}
+
+func decodePinsDAGNode(ctx context.Context, c cid.Cid, getBlock func(context.Context, cid.Cid) ([]byte, error)) (*cbor.Node, error) {
+	if c.Type() != cid.DagCBOR {
+		return nil, fmt.Errorf("%s is not a dag-cbor node", c)
+	}
+	data, err := getBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	n, err := cbor.Decode(data, c.Prefix().MhType, c.Prefix().MhLength)
+	if err != nil {
+		return nil, err
+	}
+	if !n.Cid().Equals(c) {
+		return nil, fmt.Errorf("the block for %s does not match its cid", c)
+	}
+	return n, nil
+}
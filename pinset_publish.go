@@ -0,0 +1,149 @@
+package ipfscluster
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	ipld "github.com/ipfs/go-ipld-format"
+	"go.opencensus.io/trace"
+)
+
+// pinsetPublishDatastore is the name of the local datastore where the root
+// of the last published pinset DAG is kept.
+const pinsetPublishDatastore = "pinset_publish"
+
+var pinsetRootKey = ds.NewKey("root")
+
+// pinsetDAGVersion is the version of the format of the published pinset
+// DAG.
+const pinsetDAGVersion = 1
+
+// publishPinsetLoop publishes the pinset every IPNSPublishInterval.
+//
+// This is not a leader task: the IPNS key lives in the IPFS daemon of every
+// peer which sets IPNSPublishKey, and each of them keeps its own name up to
+// date.
+func (c *Cluster) publishPinsetLoop() {
+	timer := time.NewTimer(peerStagger(c.id, "ipns_publish", c.config.IPNSPublishInterval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-timer.C:
+			if _, _, err := c.publishPinset(c.ctx); err != nil {
+				logger.Errorf("error publishing the pinset to IPNS: %s", err)
+			}
+			timer.Reset(c.config.IPNSPublishInterval)
+		}
+	}
+}
+
+// publishPinset writes the current pinset as a DAG to IPFS, pins its root
+// and publishes it under the configured IPNS key. The root of the previous
+// publication is unpinned. Nothing is written when the pinset has not
+// changed since then. It returns the root of the DAG and the IPNS name.
+//
+// The DAG is made of dag-cbor nodes. The root lists the number of pins and
+// links to leaves which list the pins, sorted by Cid. Pinned Cids are
+// stored as strings, not links, so that pinning the root does not fetch
+// the content of the cluster.
+func (c *Cluster) publishPinset(ctx context.Context) (cid.Cid, string, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/publishPinset")
+	defer span.End()
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return cid.Undef, "", err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return cid.Undef, "", err
+	}
+
+	nodes, err := makePinsetDAG(pins)
+	if err != nil {
+		return cid.Undef, "", err
+	}
+	root := nodes[0].Cid()
+
+	store := c.localDatastore(pinsetPublishDatastore)
+	previous := cid.Undef
+	b, err := store.Get(pinsetRootKey)
+	if err == nil {
+		previous, _ = cid.Cast(b)
+	}
+
+	if !previous.Equals(root) {
+		for _, n := range nodes {
+			err := c.ipfs.BlockPut(ctx, &api.NodeWithMeta{
+				Cid:  n.Cid(),
+				Data: n.RawData(),
+			})
+			if err != nil {
+				return cid.Undef, "", err
+			}
+		}
+		if err := c.ipfs.Pin(ctx, api.PinCid(root)); err != nil {
+			return cid.Undef, "", err
+		}
+	}
+
+	name, err := c.ipfs.NamePublish(ctx, &api.IPNSPublish{
+		Cid:      root,
+		Key:      c.config.IPNSPublishKey,
+		Lifetime: c.config.IPNSRecordLifetime,
+	})
+	if err != nil {
+		return cid.Undef, "", err
+	}
+
+	if !previous.Equals(root) {
+		if err := store.Put(pinsetRootKey, root.Bytes()); err != nil {
+			logger.Errorf("error saving the root of the published pinset: %s", err)
+		}
+		if previous.Defined() {
+			if err := c.ipfs.Unpin(ctx, previous); err != nil {
+				logger.Warningf("error unpinning the previous pinset root %s: %s", previous, err)
+			}
+		}
+		logger.Infof("published the pinset (%d pins) as %s under /ipns/%s", len(pins), root, name)
+	}
+	return root, name, nil
+}
+
+// makePinsetDAG builds the nodes of the pinset DAG for the given pins. The
+// root is the first node.
+func makePinsetDAG(pins []*api.Pin) ([]ipld.Node, error) {
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].Cid.String() < pins[j].Cid.String()
+	})
+	entries := make([]interface{}, 0, len(pins))
+	for _, pin := range pins {
+		entries = append(entries, pinsetEntry(pin))
+	}
+	return makePinsDAG(map[string]interface{}{"version": pinsetDAGVersion}, entries)
+}
+
+// pinsetEntry returns the representation of a pin in the pinset DAG.
+func pinsetEntry(pin *api.Pin) map[string]interface{} {
+	entry := map[string]interface{}{
+		"cid":                    pin.Cid.String(),
+		"type":                   pin.Type.String(),
+		"replication_factor_min": pin.ReplicationFactorMin,
+		"replication_factor_max": pin.ReplicationFactorMax,
+	}
+	if pin.Name != "" {
+		entry["name"] = pin.Name
+	}
+	if pin.Reference != nil {
+		entry["reference"] = pin.Reference.String()
+	}
+	return entry
+}
@@ -57,8 +57,11 @@ type Operation struct {
 	cancel func()
 
 	// RO fields
-	opType OperationType
-	pin    *api.Pin
+	opType   OperationType
+	pin      *api.Pin
+	created  time.Time
+	attempts int
+	onChange func(*Operation) // called after phase changes, if set
 
 	// RW fields
 	mu    sync.RWMutex
@@ -73,15 +76,18 @@ func NewOperation(ctx context.Context, pin *api.Pin, typ OperationType, ph Phase
 	defer span.End()
 
 	ctx, cancel := context.WithCancel(ctx)
+	now := time.Now()
 	return &Operation{
 		ctx:    ctx,
 		cancel: cancel,
 
-		pin:    pin,
-		opType: typ,
-		phase:  ph,
-		ts:     time.Now(),
-		error:  "",
+		pin:      pin,
+		opType:   typ,
+		created:  now,
+		attempts: 1,
+		phase:    ph,
+		ts:       now,
+		error:    "",
 	}
 }
 
@@ -98,7 +104,9 @@ func (op *Operation) String() string {
 	}
 	fmt.Fprintf(&b, "phase: %s\n", op.Phase().String())
 	fmt.Fprintf(&b, "error: %s\n", op.Error())
+	fmt.Fprintf(&b, "created: %s\n", op.Created().String())
 	fmt.Fprintf(&b, "timestamp: %s\n", op.Timestamp().String())
+	fmt.Fprintf(&b, "attempts: %d\n", op.Attempts())
 
 	return b.String()
 }
@@ -144,6 +152,9 @@ func (op *Operation) SetPhase(ph Phase) {
 		op.ts = time.Now()
 	}
 	op.mu.Unlock()
+	if op.onChange != nil {
+		op.onChange(op)
+	}
 	span.End()
 }
 
@@ -167,6 +178,9 @@ func (op *Operation) SetError(err error) {
 		op.ts = time.Now()
 	}
 	op.mu.Unlock()
+	if op.onChange != nil {
+		op.onChange(op)
+	}
 	span.End()
 }
 
@@ -190,6 +204,17 @@ func (op *Operation) Timestamp() time.Time {
 	return ts
 }
 
+// Created returns the time when this operation was created.
+func (op *Operation) Created() time.Time {
+	return op.created
+}
+
+// Attempts returns how many times this operation has been tried. It is
+// larger than 1 when the operation replaced a failed one of the same type.
+func (op *Operation) Attempts() int {
+	return op.attempts
+}
+
 // Cancelled returns whether the context for this
 // operation has been cancelled.
 func (op *Operation) Cancelled() bool {
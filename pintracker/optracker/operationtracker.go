@@ -7,7 +7,9 @@ package optracker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +25,15 @@ import (
 
 var logger = logging.Logger("optracker")
 
+// MaxStatusChanges is the number of status changes kept by the
+// OperationTracker to answer StatusChanges requests.
+var MaxStatusChanges = 100000
+
+// ErrChangesTruncated is returned by StatusChanges when some of the changes
+// since the given time are no longer kept (or happened before the tracker
+// was started). The full status needs to be requested instead.
+var ErrChangesTruncated = errors.New("status changes since the given time are not available anymore")
+
 // OperationTracker tracks and manages all inflight Operations.
 type OperationTracker struct {
 	ctx      context.Context // parent context for all ops
@@ -31,6 +42,10 @@ type OperationTracker struct {
 
 	mu         sync.RWMutex
 	operations map[string]*Operation
+
+	changesMu   sync.Mutex
+	changes     []*api.PinInfo
+	truncatedAt time.Time // changes before this time are not known
 }
 
 func (opt *OperationTracker) String() string {
@@ -54,10 +69,11 @@ func (opt *OperationTracker) String() string {
 // NewOperationTracker creates a new OperationTracker.
 func NewOperationTracker(ctx context.Context, pid peer.ID, peerName string) *OperationTracker {
 	return &OperationTracker{
-		ctx:        ctx,
-		pid:        pid,
-		peerName:   peerName,
-		operations: make(map[string]*Operation),
+		ctx:         ctx,
+		pid:         pid,
+		peerName:    peerName,
+		operations:  make(map[string]*Operation),
+		truncatedAt: time.Now(),
 	}
 }
 
@@ -76,20 +92,74 @@ func (opt *OperationTracker) TrackNewOperation(ctx context.Context, pin *api.Pin
 	opt.mu.Lock()
 	defer opt.mu.Unlock()
 
+	attempts := 1
 	op, ok := opt.operations[cidStr]
 	if ok { // operation exists
 		if op.Type() == typ && op.Phase() != PhaseError && op.Phase() != PhaseDone {
 			return nil // an ongoing operation of the same sign exists
 		}
+		if op.Type() == typ && op.Phase() == PhaseError {
+			// we are retrying a failed operation
+			attempts = op.Attempts() + 1
+		}
 		op.Cancel() // cancel ongoing operation and replace it
 	}
 
 	op2 := NewOperation(ctx, pin, typ, ph)
+	op2.attempts = attempts
+	op2.onChange = opt.recordChange
+	opt.recordChange(op2)
 	logger.Debugf("'%s' on cid '%s' has been created with phase '%s'", typ, cidStr, ph)
 	opt.operations[cidStr] = op2
 	return op2
 }
 
+// recordChange adds the current status of an operation to the list of
+// status changes, dropping the oldest ones when full.
+func (opt *OperationTracker) recordChange(op *Operation) {
+	pinfo := opt.unsafePinInfo(opt.ctx, op)
+
+	opt.changesMu.Lock()
+	defer opt.changesMu.Unlock()
+	opt.changes = append(opt.changes, &pinfo)
+	if n := len(opt.changes) - MaxStatusChanges; n > 0 {
+		opt.truncatedAt = opt.changes[n-1].TS
+		opt.changes = opt.changes[n:]
+	}
+}
+
+// StatusChanges returns the latest status of every Cid whose status has
+// changed after the given time because of an operation. It returns
+// ErrChangesTruncated when those changes are not fully known.
+func (opt *OperationTracker) StatusChanges(ctx context.Context, since time.Time) ([]*api.PinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "optracker/StatusChanges")
+	defer span.End()
+
+	opt.changesMu.Lock()
+	defer opt.changesMu.Unlock()
+
+	if since.Before(opt.truncatedAt) {
+		return nil, ErrChangesTruncated
+	}
+
+	var pinfos []*api.PinInfo
+	seen := make(map[cid.Cid]struct{})
+	// latest changes first. Changes may be slightly out of order
+	// so we look at all of them.
+	for i := len(opt.changes) - 1; i >= 0; i-- {
+		pinfo := opt.changes[i]
+		if !pinfo.TS.After(since) {
+			continue
+		}
+		if _, ok := seen[pinfo.Cid]; ok {
+			continue
+		}
+		seen[pinfo.Cid] = struct{}{}
+		pinfos = append(pinfos, pinfo)
+	}
+	return pinfos, nil
+}
+
 // Clean deletes an operation from the tracker if it is the one we are tracking
 // (compares pointers).
 func (opt *OperationTracker) Clean(ctx context.Context, op *Operation) {
@@ -157,6 +227,7 @@ func (opt *OperationTracker) unsafePinInfo(ctx context.Context, op *Operation) a
 		Status:   op.ToTrackerStatus(),
 		TS:       op.Timestamp(),
 		Error:    op.Error(),
+		Local:    op.Pin().LocalPin,
 	}
 }
 
@@ -206,6 +277,40 @@ func (opt *OperationTracker) GetAll(ctx context.Context) []*api.PinInfo {
 	return pinfos
 }
 
+// Operations returns a description of all the operations known to the
+// tracker, oldest first.
+func (opt *OperationTracker) Operations(ctx context.Context) []*api.TrackerOperation {
+	ctx, span := trace.StartSpan(ctx, "optracker/Operations")
+	defer span.End()
+
+	opt.mu.RLock()
+	ops := make([]*api.TrackerOperation, 0, len(opt.operations))
+	for _, op := range opt.operations {
+		ops = append(ops, opt.unsafeTrackerOperation(op))
+	}
+	opt.mu.RUnlock()
+
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].Created.Before(ops[j].Created)
+	})
+	return ops
+}
+
+func (opt *OperationTracker) unsafeTrackerOperation(op *Operation) *api.TrackerOperation {
+	return &api.TrackerOperation{
+		Cid:      op.Cid(),
+		Peer:     opt.pid,
+		PeerName: opt.peerName,
+		Type:     strings.ToLower(strings.TrimPrefix(op.Type().String(), "Operation")),
+		Phase:    strings.ToLower(strings.TrimPrefix(op.Phase().String(), "Phase")),
+		Priority: op.Pin().Priority,
+		Created:  op.Created(),
+		TS:       op.Timestamp(),
+		Attempts: op.Attempts(),
+		Error:    op.Error(),
+	}
+}
+
 // CleanAllDone deletes any operation from the tracker that is in PhaseDone.
 func (opt *OperationTracker) CleanAllDone(ctx context.Context) {
 	opt.mu.Lock()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
 	"github.com/ipfs/ipfs-cluster/test"
@@ -141,6 +142,83 @@ func TestOperationTracker_SetError(t *testing.T) {
 	}
 }
 
+func TestOperationTracker_Operations(t *testing.T) {
+	ctx := context.Background()
+	opt := testOperationTracker(t)
+	opt.TrackNewOperation(ctx, api.PinCid(test.Cid1), OperationPin, PhaseDone)
+	opt.TrackNewOperation(ctx, api.PinCid(test.Cid2), OperationUnpin, PhaseQueued)
+	opt.SetError(ctx, test.Cid1, errors.New("fake error"))
+
+	// retry the failed pin
+	op := opt.TrackNewOperation(ctx, api.PinCid(test.Cid1), OperationPin, PhaseInProgress)
+	if op.Attempts() != 2 {
+		t.Errorf("expected 2 attempts, got %d", op.Attempts())
+	}
+
+	ops := opt.Operations(ctx)
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 operations, got %d", len(ops))
+	}
+
+	first, second := ops[0], ops[1]
+	if !first.Cid.Equals(test.Cid2) || !second.Cid.Equals(test.Cid1) {
+		t.Fatal("operations should be sorted by creation time")
+	}
+	if first.Type != "unpin" || first.Phase != "queued" || first.Attempts != 1 {
+		t.Errorf("unexpected operation: %+v", first)
+	}
+	if second.Type != "pin" || second.Phase != "inprogress" || second.Attempts != 2 {
+		t.Errorf("unexpected operation: %+v", second)
+	}
+	if second.Peer != test.PeerID1 || second.PeerName != test.PeerName1 {
+		t.Error("unexpected peer")
+	}
+}
+
+func TestOperationTracker_StatusChanges(t *testing.T) {
+	ctx := context.Background()
+	opt := testOperationTracker(t)
+
+	_, err := opt.StatusChanges(ctx, time.Now().Add(-time.Minute))
+	if err != ErrChangesTruncated {
+		t.Error("changes before the tracker started should not be known")
+	}
+
+	since := time.Now()
+	op := opt.TrackNewOperation(ctx, api.PinCid(test.Cid1), OperationPin, PhaseQueued)
+	opt.TrackNewOperation(ctx, api.PinCid(test.Cid2), OperationPin, PhaseQueued)
+	op.SetPhase(PhaseInProgress)
+	middle := time.Now()
+	op.SetPhase(PhaseDone)
+
+	changes, err := opt.StatusChanges(ctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %d", len(changes))
+	}
+	if !changes[0].Cid.Equals(test.Cid1) || changes[0].Status != api.TrackerStatusPinned {
+		t.Error("expected the latest status of the latest change first")
+	}
+
+	changes, err = opt.StatusChanges(ctx, middle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !changes[0].Cid.Equals(test.Cid1) {
+		t.Error("expected only the change after middle")
+	}
+
+	defer func(max int) { MaxStatusChanges = max }(MaxStatusChanges)
+	MaxStatusChanges = 2
+	opt.TrackNewOperation(ctx, api.PinCid(test.Cid3), OperationPin, PhaseQueued)
+	_, err = opt.StatusChanges(ctx, since)
+	if err != ErrChangesTruncated {
+		t.Error("expected truncated changes")
+	}
+}
+
 func TestOperationTracker_Get(t *testing.T) {
 	ctx := context.Background()
 	opt := testOperationTracker(t)
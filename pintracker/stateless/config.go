@@ -3,6 +3,9 @@ package stateless
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 
@@ -16,6 +19,13 @@ const envConfigKey = "cluster_stateless"
 const (
 	DefaultMaxPinQueueSize = 1000000
 	DefaultConcurrentPins  = 10
+	DefaultHookTimeout     = time.Minute
+)
+
+// Events which trigger hooks.
+const (
+	HookEventPinned   = "pinned"
+	HookEventUnpinned = "unpinned"
 )
 
 // Config allows to initialize a Monitor and customize some parameters.
@@ -28,11 +38,39 @@ type Config struct {
 	// daemon in parallel. If the pinning method is "refs", it might increase
 	// speed. Unpin requests are always processed one by one.
 	ConcurrentPins int
+	// Hooks are run when this peer finishes pinning or unpinning an
+	// item.
+	Hooks []*Hook
+}
+
+// Hook is a command or a webhook which is run when this peer finishes
+// pinning or unpinning an item. Commands receive the details of the item in
+// environment variables, while webhooks receive them as a JSON body. The
+// name and metadata of unpinned items are only given when known, as they
+// are usually gone from the pinset already.
+type Hook struct {
+	// Events triggering the hook ("pinned" and/or "unpinned"). All
+	// events trigger it when empty.
+	Events []string
+	// Exec is the command to run, followed by its arguments.
+	Exec []string
+	// Webhook is a URL to POST to.
+	Webhook string
+	// Timeout for the command or the request.
+	Timeout time.Duration
 }
 
 type jsonConfig struct {
-	MaxPinQueueSize int `json:"max_pin_queue_size,omitempty"`
-	ConcurrentPins  int `json:"concurrent_pins"`
+	MaxPinQueueSize int         `json:"max_pin_queue_size,omitempty"`
+	ConcurrentPins  int         `json:"concurrent_pins"`
+	Hooks           []*jsonHook `json:"hooks,omitempty"`
+}
+
+type jsonHook struct {
+	Events  []string `json:"events,omitempty"`
+	Exec    []string `json:"exec,omitempty"`
+	Webhook string   `json:"webhook,omitempty"`
+	Timeout string   `json:"timeout,omitempty"`
 }
 
 // ConfigKey provides a human-friendly identifier for this type of Config.
@@ -44,6 +82,7 @@ func (cfg *Config) ConfigKey() string {
 func (cfg *Config) Default() error {
 	cfg.MaxPinQueueSize = DefaultMaxPinQueueSize
 	cfg.ConcurrentPins = DefaultConcurrentPins
+	cfg.Hooks = nil
 	return nil
 }
 
@@ -70,9 +109,53 @@ func (cfg *Config) Validate() error {
 	if cfg.ConcurrentPins <= 0 {
 		return errors.New("statelesstracker.concurrent_pins is too low")
 	}
+
+	for i, h := range cfg.Hooks {
+		err := h.validate()
+		if err != nil {
+			return fmt.Errorf("statelesstracker.hooks[%d]: %s", i, err)
+		}
+	}
+	return nil
+}
+
+func (h *Hook) validate() error {
+	if (len(h.Exec) == 0) == (h.Webhook == "") {
+		return errors.New("either exec or webhook must be set")
+	}
+	if h.Webhook != "" {
+		u, err := url.Parse(h.Webhook)
+		if err != nil {
+			return fmt.Errorf("invalid webhook: %s", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return errors.New("webhook must be an http or https URL")
+		}
+	}
+	for _, ev := range h.Events {
+		if ev != HookEventPinned && ev != HookEventUnpinned {
+			return fmt.Errorf("unknown event: %s", ev)
+		}
+	}
+	if h.Timeout <= 0 {
+		return errors.New("timeout is invalid")
+	}
 	return nil
 }
 
+// handles returns true if the hook is triggered by the given event.
+func (h *Hook) handles(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, ev := range h.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
 // LoadJSON sets the fields of this Config to the values defined by the JSON
 // representation of it, as generated by ToJSON.
 func (cfg *Config) LoadJSON(raw []byte) error {
@@ -92,6 +175,26 @@ func (cfg *Config) applyJSONConfig(jcfg *jsonConfig) error {
 	config.SetIfNotDefault(jcfg.MaxPinQueueSize, &cfg.MaxPinQueueSize)
 	config.SetIfNotDefault(jcfg.ConcurrentPins, &cfg.ConcurrentPins)
 
+	if jcfg.Hooks != nil {
+		cfg.Hooks = make([]*Hook, 0, len(jcfg.Hooks))
+	}
+	for i, jh := range jcfg.Hooks {
+		h := &Hook{
+			Events:  jh.Events,
+			Exec:    jh.Exec,
+			Webhook: jh.Webhook,
+			Timeout: DefaultHookTimeout,
+		}
+		err := config.ParseDurations(
+			configKey,
+			&config.DurationOpt{Duration: jh.Timeout, Dst: &h.Timeout, Name: fmt.Sprintf("hooks[%d].timeout", i)},
+		)
+		if err != nil {
+			return err
+		}
+		cfg.Hooks = append(cfg.Hooks, h)
+	}
+
 	return cfg.Validate()
 }
 
@@ -109,6 +212,17 @@ func (cfg *Config) toJSONConfig() *jsonConfig {
 	if cfg.MaxPinQueueSize != DefaultMaxPinQueueSize {
 		jCfg.MaxPinQueueSize = cfg.MaxPinQueueSize
 	}
+	for _, h := range cfg.Hooks {
+		jh := &jsonHook{
+			Events:  h.Events,
+			Exec:    h.Exec,
+			Webhook: h.Webhook,
+		}
+		if h.Timeout != DefaultHookTimeout {
+			jh.Timeout = h.Timeout.String()
+		}
+		jCfg.Hooks = append(jCfg.Hooks, jh)
+	}
 
 	return jCfg
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"testing"
+	"time"
 )
 
 var cfgJSON = []byte(`
@@ -34,6 +35,64 @@ func TestLoadJSON(t *testing.T) {
 	}
 }
 
+func TestLoadJSONHooks(t *testing.T) {
+	cfg := &Config{}
+	err := cfg.LoadJSON([]byte(`
+{
+	"concurrent_pins": 2,
+	"hooks": [
+		{
+			"events": ["pinned"],
+			"exec": ["/usr/local/bin/index", "--add"],
+			"timeout": "10s"
+		},
+		{
+			"webhook": "https://example.org/hook"
+		}
+	]
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Hooks) != 2 {
+		t.Fatal("expected 2 hooks")
+	}
+	if h := cfg.Hooks[0]; len(h.Exec) != 2 || h.Timeout != 10*time.Second || !h.handles(HookEventPinned) || h.handles(HookEventUnpinned) {
+		t.Error("first hook not loaded correctly")
+	}
+	if h := cfg.Hooks[1]; h.Webhook != "https://example.org/hook" || h.Timeout != DefaultHookTimeout || !h.handles(HookEventUnpinned) {
+		t.Error("second hook not loaded correctly")
+	}
+
+	newjson, err := cfg.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = &Config{}
+	err = cfg.LoadJSON(newjson)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Hooks) != 2 {
+		t.Error("hooks not preserved")
+	}
+
+	badHooks := []string{
+		`{}`,
+		`{"exec": ["/bin/true"], "webhook": "http://example.org"}`,
+		`{"webhook": "ftp://example.org"}`,
+		`{"exec": ["/bin/true"], "events": ["added"]}`,
+		`{"exec": ["/bin/true"], "timeout": "-1s"}`,
+	}
+	for _, h := range badHooks {
+		err := cfg.LoadJSON([]byte(`{"hooks": [` + h + `]}`))
+		if err == nil {
+			t.Errorf("expected an error with hook %s", h)
+		}
+	}
+}
+
 func TestToJSON(t *testing.T) {
 	cfg := &Config{}
 	cfg.LoadJSON(cfgJSON)
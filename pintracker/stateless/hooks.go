@@ -0,0 +1,139 @@
+package stateless
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ipfs/ipfs-cluster/pintracker/optracker"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// hookEvent carries the details of a finished operation. It is the body of
+// webhook requests.
+type hookEvent struct {
+	Event    string            `json:"event"`
+	Cid      string            `json:"cid"`
+	Name     string            `json:"name"`
+	Peer     string            `json:"peer"`
+	PeerName string            `json:"peername"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// env returns the environment variables given to hook commands:
+// CLUSTER_HOOK_EVENT, CLUSTER_HOOK_CID, CLUSTER_HOOK_NAME, CLUSTER_HOOK_PEER,
+// CLUSTER_HOOK_PEERNAME and CLUSTER_HOOK_METADATA, which holds the metadata
+// as JSON.
+func (ev *hookEvent) env() ([]string, error) {
+	meta, err := json.Marshal(ev.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return []string{
+		"CLUSTER_HOOK_EVENT=" + ev.Event,
+		"CLUSTER_HOOK_CID=" + ev.Cid,
+		"CLUSTER_HOOK_NAME=" + ev.Name,
+		"CLUSTER_HOOK_PEER=" + ev.Peer,
+		"CLUSTER_HOOK_PEERNAME=" + ev.PeerName,
+		"CLUSTER_HOOK_METADATA=" + string(meta),
+	}, nil
+}
+
+// runHooks launches the hooks triggered by the given finished pin or unpin
+// operation. Hooks run in the background and their errors are logged.
+func (spt *Tracker) runHooks(op *optracker.Operation) {
+	if len(spt.config.Hooks) == 0 {
+		return
+	}
+
+	var event string
+	switch op.Type() {
+	case optracker.OperationPin:
+		event = HookEventPinned
+	case optracker.OperationUnpin:
+		event = HookEventUnpinned
+	default:
+		return
+	}
+
+	pin := op.Pin()
+	ev := &hookEvent{
+		Event:    event,
+		Cid:      pin.Cid.String(),
+		Name:     pin.Name,
+		Peer:     peer.IDB58Encode(spt.peerID),
+		PeerName: spt.peerName,
+		Metadata: pin.Metadata,
+	}
+	if ev.Metadata == nil {
+		ev.Metadata = make(map[string]string)
+	}
+
+	// Do not launch hooks while shutting down -- prevents race
+	// conditions with spt.wg.
+	spt.shutdownMu.Lock()
+	defer spt.shutdownMu.Unlock()
+	if spt.ctx.Err() != nil {
+		return
+	}
+
+	for _, h := range spt.config.Hooks {
+		if !h.handles(event) {
+			continue
+		}
+		spt.wg.Add(1)
+		go func(h *Hook) {
+			defer spt.wg.Done()
+			err := spt.runHook(h, ev)
+			if err != nil {
+				logger.Errorf("error running %s hook for %s: %s", ev.Event, ev.Cid, err)
+			}
+		}(h)
+	}
+}
+
+// runHook runs a hook command or posts to a webhook.
+func (spt *Tracker) runHook(h *Hook, ev *hookEvent) error {
+	ctx, cancel := context.WithTimeout(spt.ctx, h.Timeout)
+	defer cancel()
+
+	if len(h.Exec) > 0 {
+		env, err := ev.env()
+		if err != nil {
+			return err
+		}
+		cmd := exec.CommandContext(ctx, h.Exec[0], h.Exec[1:]...)
+		cmd.Env = append(os.Environ(), env...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+		}
+		return nil
+	}
+
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", h.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package stateless
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/pintracker/optracker"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+)
+
+// queuedOp is a queued operation as persisted in the local datastore.
+type queuedOp struct {
+	Type    optracker.OperationType `json:"type"`
+	Created time.Time               `json:"created"`
+	Pin     *api.Pin                `json:"pin"`
+}
+
+// SetLocalDatastore sets the datastore where the tracker persists its
+// queued pin and unpin operations, keyed by cid. The operations that were
+// not finished when the peer stopped are queued again when the tracker
+// gets its RPC client.
+func (spt *Tracker) SetLocalDatastore(store ds.Datastore) {
+	spt.storeMu.Lock()
+	defer spt.storeMu.Unlock()
+	spt.store = store
+}
+
+// persist saves a queued operation, replacing any other for the same cid.
+func (spt *Tracker) persist(op *optracker.Operation) {
+	spt.storeMu.Lock()
+	defer spt.storeMu.Unlock()
+	if spt.store == nil {
+		return
+	}
+
+	b, err := json.Marshal(queuedOp{
+		Type:    op.Type(),
+		Created: op.Created(),
+		Pin:     op.Pin(),
+	})
+	if err == nil {
+		err = spt.store.Put(ds.NewKey(op.Cid().String()), b)
+	}
+	if err != nil {
+		logger.Errorf("error persisting the queued operation for %s: %s", op.Cid(), err)
+	}
+}
+
+// forget removes the persisted operation for the given cid. When created is
+// not zero, it is only removed if it is the operation created then, as a
+// newer one may have replaced it.
+func (spt *Tracker) forget(c cid.Cid, created time.Time) {
+	spt.storeMu.Lock()
+	defer spt.storeMu.Unlock()
+	if spt.store == nil {
+		return
+	}
+
+	key := ds.NewKey(c.String())
+	if !created.IsZero() {
+		b, err := spt.store.Get(key)
+		if err == ds.ErrNotFound {
+			return
+		}
+		var qop queuedOp
+		if err == nil {
+			err = json.Unmarshal(b, &qop)
+		}
+		if err == nil && !qop.Created.Equal(created) {
+			return
+		}
+	}
+	if err := spt.store.Delete(key); err != nil {
+		logger.Errorf("error removing the queued operation for %s: %s", c, err)
+	}
+}
+
+// requeue queues again the operations persisted before the peer stopped.
+func (spt *Tracker) requeue() {
+	spt.storeMu.Lock()
+	store := spt.store
+	spt.storeMu.Unlock()
+	if store == nil {
+		return
+	}
+
+	results, err := store.Query(query.Query{})
+	if err != nil {
+		logger.Errorf("error reading the queued operations: %s", err)
+		return
+	}
+	// Read them all first, as queueing them writes to the datastore.
+	entries, err := results.Rest()
+	if err != nil {
+		logger.Errorf("error reading the queued operations: %s", err)
+		return
+	}
+
+	n := 0
+	for _, e := range entries {
+		var qop queuedOp
+		err := json.Unmarshal(e.Value, &qop)
+		if err == nil && qop.Pin == nil {
+			err = errMissingPin
+		}
+		if err != nil {
+			logger.Errorf("discarding queued operation %s: %s", e.Key, err)
+			store.Delete(ds.NewKey(e.Key))
+			continue
+		}
+		if err := spt.enqueue(spt.ctx, qop.Pin, qop.Type); err != nil {
+			logger.Errorf("error queueing again the operation for %s: %s", qop.Pin.Cid, err)
+			continue
+		}
+		n++
+	}
+	if n > 0 {
+		logger.Infof("queued again %d operations not finished before the last shutdown", n)
+	}
+}
@@ -10,10 +10,12 @@ import (
 	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/config"
 	"github.com/ipfs/ipfs-cluster/pintracker/optracker"
 	"github.com/ipfs/ipfs-cluster/state"
 
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log"
 	peer "github.com/libp2p/go-libp2p-core/peer"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
@@ -25,10 +27,12 @@ var logger = logging.Logger("pintracker")
 
 var (
 	// ErrFullQueue is the error used when pin or unpin operation channel is full.
-	ErrFullQueue = errors.New("pin/unpin operation queue is full. Try increasing max_pin_queue_size")
+	ErrFullQueue = api.NewCodedError(api.ErrCodePinQueueFull, "pin/unpin operation queue is full. Try increasing max_pin_queue_size")
 
 	// items with this error should be recovered
 	errUnexpectedlyUnpinned = errors.New("the item should be pinned but it is not")
+
+	errMissingPin = errors.New("no pin")
 )
 
 // Tracker uses the optracker.OperationTracker to manage
@@ -46,11 +50,27 @@ type Tracker struct {
 
 	getState func(ctx context.Context) (state.ReadOnly, error)
 
+	// local pins are not part of the shared state, so the tracker
+	// keeps them itself.
+	localPinsMu sync.RWMutex
+	localPins   map[cid.Cid]*api.Pin
+
 	rpcClient *rpc.Client
 	rpcReady  chan struct{}
 
 	pinCh   chan *optracker.Operation
 	unpinCh chan *optracker.Operation
+	// priority pins are taken by the pin workers before any in pinCh.
+	priorityPinCh chan *optracker.Operation
+
+	// pinWorkers holds a channel to stop each of the running pin
+	// workers. Their number changes with Reconfigure.
+	pinWorkersMu sync.Mutex
+	pinWorkers   []chan struct{}
+
+	// store persists the queued operations (see SetLocalDatastore).
+	storeMu sync.Mutex
+	store   ds.Datastore
 
 	shutdownMu sync.Mutex
 	shutdown   bool
@@ -68,33 +88,86 @@ func New(cfg *Config, pid peer.ID, peerName string, getState func(ctx context.Co
 		ctx:       ctx,
 		cancel:    cancel,
 		getState:  getState,
+		localPins: make(map[cid.Cid]*api.Pin),
 		optracker: optracker.NewOperationTracker(ctx, pid, peerName),
 		rpcReady:  make(chan struct{}, 1),
 		pinCh:     make(chan *optracker.Operation, cfg.MaxPinQueueSize),
 		unpinCh:   make(chan *optracker.Operation, cfg.MaxPinQueueSize),
-	}
 
-	for i := 0; i < spt.config.ConcurrentPins; i++ {
-		go spt.opWorker(spt.pin, spt.pinCh)
+		priorityPinCh: make(chan *optracker.Operation, cfg.MaxPinQueueSize),
 	}
-	go spt.opWorker(spt.unpin, spt.unpinCh)
+
+	spt.setPinWorkers(spt.config.ConcurrentPins)
+	go spt.opWorker(spt.unpin, nil, spt.unpinCh, nil)
 	return spt
 }
 
-// receives a pin Function (pin or unpin) and a channel.
-// Used for both pinning and unpinning
-func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, opChan chan *optracker.Operation) {
+// setPinWorkers starts or stops pin workers so that n of them run. Stopped
+// workers finish their current operation first.
+func (spt *Tracker) setPinWorkers(n int) {
+	spt.pinWorkersMu.Lock()
+	defer spt.pinWorkersMu.Unlock()
+
+	for len(spt.pinWorkers) < n {
+		stop := make(chan struct{})
+		spt.pinWorkers = append(spt.pinWorkers, stop)
+		go spt.opWorker(spt.pin, spt.priorityPinCh, spt.pinCh, stop)
+	}
+	for len(spt.pinWorkers) > n {
+		last := len(spt.pinWorkers) - 1
+		close(spt.pinWorkers[last])
+		spt.pinWorkers = spt.pinWorkers[:last]
+	}
+}
+
+// ConfigKey returns the key of the stateless tracker configuration.
+func (spt *Tracker) ConfigKey() string {
+	return configKey
+}
+
+// Reconfigure applies the concurrent_pins of the given stateless tracker
+// configuration. The queues cannot be resized while running.
+func (spt *Tracker) Reconfigure(cfg config.ComponentConfig) error {
+	scfg, ok := cfg.(*Config)
+	if !ok {
+		return errors.New("not a stateless tracker configuration")
+	}
+	spt.setPinWorkers(scfg.ConcurrentPins)
+	return nil
+}
+
+// receives a pin Function (pin or unpin) and the channels to take
+// operations from. Operations in prioCh are always processed before those
+// in opChan. Used for both pinning and unpinning. The worker returns when
+// the stop channel is closed.
+func (spt *Tracker) opWorker(pinF func(*optracker.Operation) error, prioCh, opChan chan *optracker.Operation, stop <-chan struct{}) {
 	for {
+		var op *optracker.Operation
 		select {
-		case op := <-opChan:
-			if cont := applyPinF(pinF, op); cont {
-				continue
+		case op = <-prioCh:
+		default:
+			select {
+			case op = <-prioCh:
+			case op = <-opChan:
+			case <-stop:
+				return
+			case <-spt.ctx.Done():
+				return
 			}
+		}
 
-			spt.optracker.Clean(op.Context(), op)
-		case <-spt.ctx.Done():
-			return
+		if cont := applyPinF(pinF, op); cont {
+			// Operations cancelled by a newer one or by the
+			// shutdown stay persisted.
+			if op.Phase() == optracker.PhaseError {
+				spt.forget(op.Cid(), op.Created())
+			}
+			continue
 		}
+
+		spt.forget(op.Cid(), op.Created())
+		spt.runHooks(op)
+		spt.optracker.Clean(op.Context(), op)
 	}
 }
 
@@ -176,10 +249,16 @@ func (spt *Tracker) enqueue(ctx context.Context, c *api.Pin, typ optracker.Opera
 	switch typ {
 	case optracker.OperationPin:
 		ch = spt.pinCh
+		if c.Priority {
+			ch = spt.priorityPinCh
+		}
 	case optracker.OperationUnpin:
 		ch = spt.unpinCh
 	}
 
+	// Persisted first so that workers do not finish the operation
+	// before.
+	spt.persist(op)
 	select {
 	case ch <- op:
 	default:
@@ -187,6 +266,7 @@ func (spt *Tracker) enqueue(ctx context.Context, c *api.Pin, typ optracker.Opera
 		op.SetError(err)
 		op.Cancel()
 		logger.Error(err.Error())
+		spt.forget(c.Cid, time.Time{})
 		return err
 	}
 	return nil
@@ -197,6 +277,7 @@ func (spt *Tracker) enqueue(ctx context.Context, c *api.Pin, typ optracker.Opera
 func (spt *Tracker) SetClient(c *rpc.Client) {
 	spt.rpcClient = c
 	spt.rpcReady <- struct{}{}
+	spt.requeue()
 }
 
 // Shutdown finishes the services provided by the StatelessPinTracker
@@ -237,6 +318,13 @@ func (spt *Tracker) Track(ctx context.Context, c *api.Pin) error {
 		return nil
 	}
 
+	if c.LocalPin {
+		spt.localPinsMu.Lock()
+		spt.localPins[c.Cid] = c
+		spt.localPinsMu.Unlock()
+		return spt.enqueue(ctx, c, optracker.OperationPin)
+	}
+
 	// Trigger unpin whenever something remote is tracked
 	// Note, IPFSConn checks with pin/ls before triggering
 	// pin/rm.
@@ -267,6 +355,9 @@ func (spt *Tracker) Untrack(ctx context.Context, c cid.Cid) error {
 	defer span.End()
 
 	logger.Debugf("untracking %s", c)
+	spt.localPinsMu.Lock()
+	delete(spt.localPins, c)
+	spt.localPinsMu.Unlock()
 	return spt.enqueue(ctx, api.PinCid(c), optracker.OperationUnpin)
 }
 
@@ -293,6 +384,84 @@ func (spt *Tracker) StatusAll(ctx context.Context) []*api.PinInfo {
 	return pis
 }
 
+// StatusAllChan works like StatusAll, but it sends the information on the
+// given channel as the shared state is read, instead of building the whole
+// listing in memory. The channel is closed when done.
+func (spt *Tracker) StatusAllChan(ctx context.Context, out chan<- *api.PinInfo) error {
+	ctx, span := trace.StartSpan(ctx, "tracker/stateless/StatusAllChan")
+	defer span.End()
+	defer close(out)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	st, err := spt.getState(ctx)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	localpis, err := spt.ipfsStatusAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Inflight operations replace the status of their items.
+	ops := make(map[string]*api.PinInfo)
+	for _, infop := range spt.optracker.GetAll(ctx) {
+		ops[infop.Cid.String()] = infop
+	}
+
+	send := func(pinInfo *api.PinInfo) error {
+		if op, ok := ops[pinInfo.Cid.String()]; ok {
+			pinInfo = op
+			delete(ops, pinInfo.Cid.String())
+		}
+		select {
+		case out <- pinInfo:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	statePins := make(chan *api.Pin, 1024)
+	listErr := make(chan error, 1)
+	go func() {
+		listErr <- st.ListChan(ctx, statePins)
+	}()
+
+	for p := range statePins {
+		pinInfo := spt.statePinInfo(p, localpis, true)
+		if err := send(pinInfo); err != nil {
+			cancel()
+			for range statePins {
+			}
+			return err
+		}
+	}
+	if err := <-listErr; err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	for _, pinInfo := range spt.localPinInfos(localpis) {
+		if err := send(pinInfo); err != nil {
+			return err
+		}
+	}
+
+	// Operations on items which are not in the state (i.e. unpinning).
+	for _, op := range ops {
+		select {
+		case out <- op:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
 // Status returns information for a Cid pinned to the local IPFS node.
 func (spt *Tracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
 	ctx, span := trace.StartSpan(ctx, "tracker/stateless/Status")
@@ -311,27 +480,32 @@ func (spt *Tracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
 		TS:       time.Now(),
 	}
 
-	// check global state to see if cluster should even be caring about
-	// the provided cid
-	gpin := &api.Pin{}
-	st, err := spt.getState(ctx)
-	if err != nil {
-		logger.Error(err)
-		addError(pinInfo, err)
-		return pinInfo
-	}
+	// local pins are not in the shared state
+	gpin, ok := spt.localPin(c)
+	if ok {
+		pinInfo.Local = true
+	} else {
+		// check global state to see if cluster should even be caring
+		// about the provided cid
+		st, err := spt.getState(ctx)
+		if err != nil {
+			logger.Error(err)
+			addError(pinInfo, err)
+			return pinInfo
+		}
 
-	gpin, err = st.Get(ctx, c)
-	if err == state.ErrNotFound {
-		pinInfo.Status = api.TrackerStatusUnpinned
-		return pinInfo
-	}
-	if err != nil {
-		logger.Error(err)
-		addError(pinInfo, err)
-		return pinInfo
+		gpin, err = st.Get(ctx, c)
+		if err == state.ErrNotFound {
+			pinInfo.Status = api.TrackerStatusUnpinned
+			return pinInfo
+		}
+		if err != nil {
+			logger.Error(err)
+			addError(pinInfo, err)
+			return pinInfo
+		}
+		// The pin IS in the state.
 	}
-	// The pin IS in the state.
 
 	// check if pin is a meta pin
 	if gpin.Type == api.MetaType {
@@ -340,14 +514,14 @@ func (spt *Tracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
 	}
 
 	// check if pin is a remote pin
-	if gpin.IsRemotePin(spt.peerID) {
+	if !pinInfo.Local && gpin.IsRemotePin(spt.peerID) {
 		pinInfo.Status = api.TrackerStatusRemote
 		return pinInfo
 	}
 
 	// else attempt to get status from ipfs node
 	var ips api.IPFSPinStatus
-	err = spt.rpcClient.CallContext(
+	err := spt.rpcClient.CallContext(
 		ctx,
 		"",
 		"IPFSConnector",
@@ -406,12 +580,43 @@ func (spt *Tracker) Recover(ctx context.Context, c cid.Cid) (*api.PinInfo, error
 	return spt.recoverWithPinInfo(ctx, spt.Status(ctx, c))
 }
 
+// PendingOperations returns the number of pin and unpin operations which are
+// queued or in progress.
+func (spt *Tracker) PendingOperations(ctx context.Context) int {
+	queued := spt.optracker.Filter(ctx, optracker.PhaseQueued)
+	inProgress := spt.optracker.Filter(ctx, optracker.PhaseInProgress)
+	return len(queued) + len(inProgress)
+}
+
+// Operations returns a description of all the operations tracked
+// by the operation tracker.
+func (spt *Tracker) Operations(ctx context.Context) []*api.TrackerOperation {
+	ctx, span := trace.StartSpan(ctx, "tracker/stateless/Operations")
+	defer span.End()
+
+	return spt.optracker.Operations(ctx)
+}
+
+// StatusChanges returns the latest status of the Cids whose status changed
+// after the given time because of pin and unpin operations. It returns an
+// error when those changes are no longer known.
+func (spt *Tracker) StatusChanges(ctx context.Context, since time.Time) ([]*api.PinInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "tracker/stateless/StatusChanges")
+	defer span.End()
+
+	return spt.optracker.StatusChanges(ctx, since)
+}
+
 func (spt *Tracker) recoverWithPinInfo(ctx context.Context, pi *api.PinInfo) (*api.PinInfo, error) {
 	var err error
 	switch pi.Status {
 	case api.TrackerStatusPinError:
 		logger.Infof("Restarting pin operation for %s", pi.Cid)
-		err = spt.enqueue(ctx, api.PinCid(pi.Cid), optracker.OperationPin)
+		pin, ok := spt.localPin(pi.Cid)
+		if !ok {
+			pin = api.PinCid(pi.Cid)
+		}
+		err = spt.enqueue(ctx, pin, optracker.OperationPin)
 	case api.TrackerStatusUnpinError:
 		logger.Infof("Restarting unpin operation for %s", pi.Cid)
 		err = spt.enqueue(ctx, api.PinCid(pi.Cid), optracker.OperationUnpin)
@@ -459,9 +664,9 @@ func (spt *Tracker) ipfsStatusAll(ctx context.Context) (map[string]*api.PinInfo,
 	return pins, nil
 }
 
-// localStatus returns a joint set of consensusState and ipfsStatus
-// marking pins which should be meta or remote and leaving any ipfs pins that
-// aren't in the consensusState out. If incExtra is true, Remote and Sharded
+// localStatus returns a joint set of consensusState, local pins and
+// ipfsStatus marking pins which should be meta or remote and leaving any ipfs
+// pins that aren't in the consensusState or tracked as local pins out. If incExtra is true, Remote and Sharded
 // pins will be added to the status slice.
 func (spt *Tracker) localStatus(ctx context.Context, incExtra bool) (map[string]*api.PinInfo, error) {
 	ctx, span := trace.StartSpan(ctx, "tracker/stateless/localStatus")
@@ -490,43 +695,89 @@ func (spt *Tracker) localStatus(ctx context.Context, incExtra bool) (map[string]
 
 	pininfos := make(map[string]*api.PinInfo, len(statePins))
 	for _, p := range statePins {
-		pCid := p.Cid.String()
-		ipfsInfo, pinnedInIpfs := localpis[pCid]
-		// base pinInfo object - status to be filled.
-		pinInfo := &api.PinInfo{
-			Cid:      p.Cid,
-			Peer:     spt.peerID,
-			PeerName: spt.peerName,
-			TS:       time.Now(),
+		if pinInfo := spt.statePinInfo(p, localpis, incExtra); pinInfo != nil {
+			pininfos[p.Cid.String()] = pinInfo
 		}
+	}
 
-		switch {
-		case p.Type == api.MetaType:
-			pinInfo.Status = api.TrackerStatusSharded
-			if incExtra {
-				pininfos[pCid] = pinInfo
-			}
-		case p.IsRemotePin(spt.peerID):
-			pinInfo.Status = api.TrackerStatusRemote
-			if incExtra {
-				pininfos[pCid] = pinInfo
-			}
-		case pinnedInIpfs:
-			pininfos[pCid] = ipfsInfo
-		default:
-			// report as PIN_ERROR for this peer.  this will be
-			// overwritten if the operation tracker has more info
-			// for this (an ongoing pinning operation). Otherwise,
-			// it means something should be pinned and it is not
-			// known by IPFS. Should be handled to "recover".
-			pinInfo.Status = api.TrackerStatusPinError
-			pinInfo.Error = errUnexpectedlyUnpinned.Error()
-			pininfos[pCid] = pinInfo
-		}
+	for _, pinInfo := range spt.localPinInfos(localpis) {
+		pininfos[pinInfo.Cid.String()] = pinInfo
 	}
 	return pininfos, nil
 }
 
+// statePinInfo returns the local status of a pin in the shared state, given
+// the status of the items pinned in IPFS. It returns nil for Remote and
+// Sharded pins unless incExtra is set.
+func (spt *Tracker) statePinInfo(p *api.Pin, localpis map[string]*api.PinInfo, incExtra bool) *api.PinInfo {
+	ipfsInfo, pinnedInIpfs := localpis[p.Cid.String()]
+	// base pinInfo object - status to be filled.
+	pinInfo := &api.PinInfo{
+		Cid:      p.Cid,
+		Peer:     spt.peerID,
+		PeerName: spt.peerName,
+		TS:       time.Now(),
+	}
+
+	switch {
+	case p.Type == api.MetaType:
+		if !incExtra {
+			return nil
+		}
+		pinInfo.Status = api.TrackerStatusSharded
+	case p.IsRemotePin(spt.peerID):
+		if !incExtra {
+			return nil
+		}
+		pinInfo.Status = api.TrackerStatusRemote
+	case pinnedInIpfs:
+		return ipfsInfo
+	default:
+		// report as PIN_ERROR for this peer.  this will be
+		// overwritten if the operation tracker has more info
+		// for this (an ongoing pinning operation). Otherwise,
+		// it means something should be pinned and it is not
+		// known by IPFS. Should be handled to "recover".
+		pinInfo.Status = api.TrackerStatusPinError
+		pinInfo.Error = errUnexpectedlyUnpinned.Error()
+	}
+	return pinInfo
+}
+
+// localPinInfos returns the status of the local pins, given the status of
+// the items pinned in IPFS.
+func (spt *Tracker) localPinInfos(localpis map[string]*api.PinInfo) []*api.PinInfo {
+	spt.localPinsMu.RLock()
+	defer spt.localPinsMu.RUnlock()
+
+	pininfos := make([]*api.PinInfo, 0, len(spt.localPins))
+	for c := range spt.localPins {
+		if ipfsInfo, pinnedInIpfs := localpis[c.String()]; pinnedInIpfs {
+			ipfsInfo.Local = true
+			pininfos = append(pininfos, ipfsInfo)
+			continue
+		}
+		pininfos = append(pininfos, &api.PinInfo{
+			Cid:      c,
+			Peer:     spt.peerID,
+			PeerName: spt.peerName,
+			Status:   api.TrackerStatusPinError,
+			TS:       time.Now(),
+			Error:    errUnexpectedlyUnpinned.Error(),
+			Local:    true,
+		})
+	}
+	return pininfos
+}
+
+// localPin returns the local pin for the given Cid, if any.
+func (spt *Tracker) localPin(c cid.Cid) (*api.Pin, bool) {
+	spt.localPinsMu.RLock()
+	defer spt.localPinsMu.RUnlock()
+	pin, ok := spt.localPins[c]
+	return pin, ok
+}
+
 func (spt *Tracker) getErrorsAll(ctx context.Context) []*api.PinInfo {
 	return spt.optracker.Filter(ctx, optracker.PhaseError)
 }
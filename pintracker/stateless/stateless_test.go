@@ -2,8 +2,16 @@ package stateless
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +22,7 @@ import (
 	"github.com/ipfs/ipfs-cluster/test"
 
 	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
 	rpc "github.com/libp2p/go-libp2p-gorpc"
 )
 
@@ -39,7 +48,17 @@ var (
 type mockIPFS struct {
 }
 
+// pinOrder records the order in which pin calls are received.
+var (
+	pinOrderMux sync.Mutex
+	pinOrder    []cid.Cid
+)
+
 func (mock *mockIPFS) Pin(ctx context.Context, in *api.Pin, out *struct{}) error {
+	pinOrderMux.Lock()
+	pinOrder = append(pinOrder, in.Cid)
+	pinOrderMux.Unlock()
+
 	switch in.Cid {
 	case pinCancelCid:
 		return errPinCancelCid
@@ -167,6 +186,45 @@ func TestUntrackTrack(t *testing.T) {
 	}
 }
 
+func TestTrackPriority(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	pinOrderMux.Lock()
+	pinOrder = nil
+	pinOrderMux.Unlock()
+
+	// Keep the only pin worker busy while the other pins are queued.
+	err := spt.Track(ctx, api.PinWithOpts(test.SlowCid1, pinOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	err = spt.Track(ctx, api.PinWithOpts(test.Cid4, pinOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prioOpts := pinOpts
+	prioOpts.Priority = true
+	err = spt.Track(ctx, api.PinWithOpts(test.Cid1, prioOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	pinOrderMux.Lock()
+	defer pinOrderMux.Unlock()
+	if len(pinOrder) != 3 {
+		t.Fatal("expected 3 pin calls, got", len(pinOrder))
+	}
+	if !pinOrder[1].Equals(test.Cid1) || !pinOrder[2].Equals(test.Cid4) {
+		t.Error("the priority pin should have been pinned first")
+	}
+}
+
 func TestTrackUntrackWithCancel(t *testing.T) {
 	ctx := context.Background()
 	spt := testStatelessPinTracker(t)
@@ -421,6 +479,65 @@ func TestStatusAll(t *testing.T) {
 	}
 }
 
+// TestStatusAllChan checks that StatusAllChan streams the same items as
+// StatusAll.
+func TestStatusAllChan(t *testing.T) {
+	ctx := context.Background()
+
+	normalPin := api.PinWithOpts(test.Cid1, pinOpts)
+	normalPin2 := api.PinWithOpts(test.Cid4, pinOpts)
+
+	spt := testStatelessPinTracker(t, normalPin, normalPin2)
+	defer spt.Shutdown(ctx)
+
+	slowPin := api.PinWithOpts(test.SlowCid1, pinOpts)
+	err := spt.Track(ctx, slowPin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second / 2)
+
+	out := make(chan *api.PinInfo)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- spt.StatusAllChan(ctx, out)
+	}()
+
+	statuses := make(map[cid.Cid]api.TrackerStatus)
+	for pi := range out {
+		if _, ok := statuses[pi.Cid]; ok {
+			t.Error("duplicated item:", pi.Cid)
+		}
+		statuses[pi.Cid] = pi.Status
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[cid.Cid]api.TrackerStatus{
+		test.Cid1:     api.TrackerStatusPinned,
+		test.Cid4:     api.TrackerStatusPinError,
+		test.SlowCid1: api.TrackerStatusPinning,
+	}
+	if len(statuses) != len(expected) {
+		t.Errorf("wrong status length. Expected %d, got: %d", len(expected), len(statuses))
+	}
+	for c, st := range expected {
+		if statuses[c] != st {
+			t.Errorf("%s: expected %s, got %s", c, st, statuses[c])
+		}
+	}
+
+	// Cancelling stops the listing.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = spt.StatusAllChan(cctx, make(chan *api.PinInfo))
+	if err == nil {
+		t.Error("expected an error with a cancelled context")
+	}
+}
+
 // TestStatus checks that the Status calls correctly reports tracked
 // items and mismatches between what's on IPFS and on the state.
 func TestStatus(t *testing.T) {
@@ -465,6 +582,60 @@ func TestStatus(t *testing.T) {
 	}
 }
 
+// TestLocalPins checks that local pins, which are not in the shared state,
+// are tracked and reported as local.
+func TestLocalPins(t *testing.T) {
+	ctx := context.Background()
+
+	spt := testStatelessPinTracker(t, api.PinWithOpts(test.Cid4, pinOpts))
+	defer spt.Shutdown(ctx)
+
+	localPin := api.PinWithOpts(test.Cid1, api.PinOptions{LocalPin: true})
+	err := spt.Track(ctx, localPin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	stAll := spt.StatusAll(ctx)
+	if len(stAll) != 2 {
+		t.Fatalf("wrong status length. Expected 2, got: %d", len(stAll))
+	}
+	for _, pi := range stAll {
+		switch pi.Cid {
+		case test.Cid1:
+			if pi.Status != api.TrackerStatusPinned || !pi.Local {
+				t.Error("cid1 should be pinned locally")
+			}
+		case test.Cid4:
+			if pi.Local {
+				t.Error("cid4 is not a local pin")
+			}
+		default:
+			t.Error("Unexpected pin:", pi.Cid)
+		}
+	}
+
+	st := spt.Status(ctx, test.Cid1)
+	if st.Status != api.TrackerStatusPinned || !st.Local {
+		t.Error("cid1 should be pinned locally")
+	}
+
+	err = spt.Untrack(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	for _, pi := range spt.StatusAll(ctx) {
+		if pi.Cid.Equals(test.Cid1) && pi.Local {
+			t.Error("cid1 should no longer be a local pin")
+		}
+	}
+}
+
 var sortPinInfoByCid = func(p []*api.PinInfo) {
 	sort.Slice(p, func(i, j int) bool {
 		return p[i].Cid.String() < p[j].Cid.String()
@@ -479,3 +650,173 @@ func BenchmarkTracker_localStatus(b *testing.B) {
 		tracker.localStatus(ctx, true)
 	}
 }
+
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+
+	events := make(chan *hookEvent, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hookEvent
+		err := json.NewDecoder(r.Body).Decode(&ev)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events <- &ev
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.Hooks = []*Hook{
+		{
+			Webhook: srv.URL,
+			Timeout: DefaultHookTimeout,
+		},
+		{
+			Events:  []string{HookEventUnpinned},
+			Exec:    []string{"sh", "-c", `echo "$CLUSTER_HOOK_EVENT $CLUSTER_HOOK_CID $CLUSTER_HOOK_METADATA" > ` + out},
+			Timeout: DefaultHookTimeout,
+		},
+	}
+	spt := New(cfg, test.PeerID1, test.PeerName1, getStateFunc(t))
+	spt.SetClient(mockRPCClient(t))
+	defer spt.Shutdown(ctx)
+
+	opts := pinOpts
+	opts.Name = "hooked"
+	opts.Metadata = map[string]string{"a": "b"}
+	err = spt.Track(ctx, api.PinWithOpts(test.Cid1, opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitEvent := func(event string) *hookEvent {
+		select {
+		case ev := <-events:
+			if ev.Event != event || ev.Cid != test.Cid1.String() || ev.PeerName != test.PeerName1 {
+				t.Errorf("unexpected webhook event: %+v", ev)
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatalf("webhook not called for %s", event)
+		}
+		return nil
+	}
+	ev := waitEvent(HookEventPinned)
+	if ev.Name != "hooked" || ev.Metadata["a"] != "b" {
+		t.Errorf("the pinned event should carry the name and metadata: %+v", ev)
+	}
+
+	if _, err := os.Stat(out); err == nil {
+		t.Error("the command should only run for unpinned events")
+	}
+
+	err = spt.Untrack(ctx, test.Cid1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(HookEventUnpinned)
+
+	var b []byte
+	for i := 0; i < 50; i++ {
+		b, err = ioutil.ReadFile(out)
+		if err == nil && len(b) > 0 {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	expected := fmt.Sprintf("%s %s {}\n", HookEventUnpinned, test.Cid1)
+	if string(b) != expected {
+		t.Errorf("unexpected command output: %q", b)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	ctx := context.Background()
+	spt := testStatelessPinTracker(t)
+	defer spt.Shutdown(ctx)
+
+	pinWorkers := func() int {
+		spt.pinWorkersMu.Lock()
+		defer spt.pinWorkersMu.Unlock()
+		return len(spt.pinWorkers)
+	}
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ConcurrentPins = 3
+	if err := spt.Reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := pinWorkers(); n != 3 {
+		t.Fatalf("expected 3 pin workers, got %d", n)
+	}
+
+	cfg.ConcurrentPins = 1
+	if err := spt.Reconfigure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := pinWorkers(); n != 1 {
+		t.Fatalf("expected 1 pin worker, got %d", n)
+	}
+
+	// The remaining worker keeps pinning.
+	err := spt.Track(ctx, api.PinWithOpts(test.Cid3, pinOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if st := spt.Status(ctx, test.Cid3).Status; st == api.TrackerStatusPinQueued {
+		t.Error("the pin should have been processed")
+	}
+}
+
+func TestQueuePersistence(t *testing.T) {
+	ctx := context.Background()
+	store := inmem.New()
+
+	cfg := &Config{}
+	cfg.Default()
+	cfg.ConcurrentPins = 1
+	spt := New(cfg, test.PeerID1, test.PeerName1, getStateFunc(t))
+	spt.SetLocalDatastore(store)
+	spt.SetClient(mockRPCClient(t))
+
+	// Keep the only pin worker busy so that the second pin stays queued
+	// until the shutdown.
+	err := spt.Track(ctx, api.PinWithOpts(test.SlowCid1, pinOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	err = spt.Track(ctx, api.PinWithOpts(test.Cid4, pinOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spt.Shutdown(ctx)
+
+	if ok, _ := store.Has(ds.NewKey(test.Cid4.String())); !ok {
+		t.Fatal("the queued operation should have been persisted")
+	}
+
+	spt = New(cfg, test.PeerID1, test.PeerName1, getStateFunc(t))
+	defer spt.Shutdown(ctx)
+	spt.SetLocalDatastore(store)
+	spt.SetClient(mockRPCClient(t))
+
+	time.Sleep(1500 * time.Millisecond)
+	if st := spt.Status(ctx, test.Cid4).Status; st == api.TrackerStatusPinQueued {
+		t.Error("the operation should have been queued again and processed")
+	}
+	if ok, _ := store.Has(ds.NewKey(test.Cid4.String())); ok {
+		t.Error("the finished operation should have been removed")
+	}
+}
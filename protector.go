@@ -0,0 +1,187 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	salsa20 "github.com/davidlazar/go-crypto/salsa20"
+	corepnet "github.com/libp2p/go-libp2p-core/pnet"
+)
+
+// multistreamHeader is the first message sent by both sides of a libp2p
+// connection once the private network has been set up. It is used to find
+// out which secret the other side is using.
+var multistreamHeader = []byte("\x13/multistream/1.0.0\n")
+
+var errShortNonce = errors.New("could not read full nonce")
+
+// secretProtector is a libp2p private network protector (compatible with
+// the "v1" PSK protector), whose secrets can be changed at runtime.
+// Connections are always written using the current secret, but the
+// remote side may use either the current or the transition secret. This
+// allows to rotate the cluster secret without splitting the cluster.
+type secretProtector struct {
+	mu         sync.RWMutex
+	secret     *[32]byte
+	transition *[32]byte
+}
+
+var _ corepnet.Protector = (*secretProtector)(nil)
+
+func newSecretProtector(secret, transition []byte) *secretProtector {
+	p := &secretProtector{}
+	p.setSecrets(secret, transition)
+	return p
+}
+
+func toKey(secret []byte) *[32]byte {
+	if len(secret) == 0 {
+		return nil
+	}
+	var key [32]byte
+	copy(key[:], secret)
+	return &key
+}
+
+// setSecrets sets the secret used to write and the additional secret
+// accepted when reading. The transition secret may be empty.
+func (p *secretProtector) setSecrets(secret, transition []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secret = toKey(secret)
+	p.transition = toKey(transition)
+}
+
+// dropTransition stops accepting the given transition secret, unless it
+// has been replaced in the meantime.
+func (p *secretProtector) dropTransition(secret []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.transition != nil && bytes.Equal(p.transition[:], secret) {
+		p.transition = nil
+	}
+}
+
+// Protect wraps the connection so that it is encrypted with the cluster
+// secret.
+func (p *secretProtector) Protect(conn net.Conn) (net.Conn, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	keys := []*[32]byte{p.secret}
+	if p.transition != nil {
+		keys = append(keys, p.transition)
+	}
+	return &secretConn{
+		Conn:     conn,
+		write:    p.secret,
+		readKeys: keys,
+	}, nil
+}
+
+// Fingerprint returns a hash of the current secret.
+func (p *secretProtector) Fingerprint() []byte {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	sum := sha256.Sum256(p.secret[:])
+	return sum[:]
+}
+
+// secretConn is a connection encrypted with XSalsa20 using one of the
+// cluster secrets.
+type secretConn struct {
+	net.Conn
+	write    *[32]byte
+	readKeys []*[32]byte
+
+	writeS20 cipher.Stream
+	readS20  cipher.Stream
+	// decrypted data read while finding out the remote secret
+	pending []byte
+}
+
+func (c *secretConn) Read(out []byte) (int, error) {
+	if c.readS20 == nil {
+		err := c.setupRead()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(c.pending) > 0 {
+		n := copy(out, c.pending)
+		c.pending = c.pending[n:]
+		return n, nil
+	}
+
+	n, err := c.Conn.Read(out)
+	if n > 0 {
+		c.readS20.XORKeyStream(out[:n], out[:n])
+	}
+	return n, err
+}
+
+// setupRead reads the nonce sent by the remote side and, when several
+// secrets are accepted, finds out which one is used by decrypting the
+// first message.
+func (c *secretConn) setupRead() error {
+	nonce := make([]byte, 24)
+	_, err := io.ReadFull(c.Conn, nonce)
+	if err != nil {
+		return errShortNonce
+	}
+
+	if len(c.readKeys) == 1 {
+		c.readS20 = salsa20.New(c.readKeys[0], nonce)
+		return nil
+	}
+
+	head := make([]byte, len(multistreamHeader))
+	_, err = io.ReadFull(c.Conn, head)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range c.readKeys {
+		s20 := salsa20.New(key, nonce)
+		plain := make([]byte, len(head))
+		s20.XORKeyStream(plain, head)
+		if bytes.Equal(plain, multistreamHeader) {
+			c.readS20 = s20
+			c.pending = plain
+			return nil
+		}
+	}
+
+	// No secret matches. Use the current one and let the connection
+	// fail further up.
+	c.readS20 = salsa20.New(c.readKeys[0], nonce)
+	c.pending = make([]byte, len(head))
+	c.readS20.XORKeyStream(c.pending, head)
+	return nil
+}
+
+func (c *secretConn) Write(in []byte) (int, error) {
+	if c.writeS20 == nil {
+		nonce := make([]byte, 24)
+		_, err := rand.Read(nonce)
+		if err != nil {
+			return 0, err
+		}
+		_, err = c.Conn.Write(nonce)
+		if err != nil {
+			return 0, err
+		}
+		c.writeS20 = salsa20.New(c.write, nonce)
+	}
+
+	out := make([]byte, len(in))
+	c.writeS20.XORKeyStream(out, in)
+	return c.Conn.Write(out)
+}
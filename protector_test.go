@@ -0,0 +1,94 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+)
+
+func testSecret(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+// exchange writes the multistream header (as libp2p does) followed by a
+// message through a pipe between two protected connections, and returns
+// what the other side reads.
+func exchange(t *testing.T, pw, pr *secretProtector) []byte {
+	t.Helper()
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	w, _ := pw.Protect(c1)
+	r, _ := pr.Protect(c2)
+
+	msg := append(append([]byte{}, multistreamHeader...), []byte("hello")...)
+	go func() {
+		w.Write(msg[:len(multistreamHeader)])
+		w.Write(msg[len(multistreamHeader):])
+	}()
+
+	buf := make([]byte, len(msg))
+	_, err := io.ReadFull(r, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestSecretProtector(t *testing.T) {
+	s1 := testSecret(1)
+	s2 := testSecret(2)
+	expected := append(append([]byte{}, multistreamHeader...), []byte("hello")...)
+
+	t.Run("same secret", func(t *testing.T) {
+		got := exchange(t, newSecretProtector(s1, nil), newSecretProtector(s1, nil))
+		if !bytes.Equal(got, expected) {
+			t.Error("message not decrypted")
+		}
+	})
+
+	t.Run("different secret", func(t *testing.T) {
+		got := exchange(t, newSecretProtector(s1, nil), newSecretProtector(s2, nil))
+		if bytes.Equal(got, expected) {
+			t.Error("message should not be decrypted")
+		}
+	})
+
+	t.Run("transition secret", func(t *testing.T) {
+		reader := newSecretProtector(s1, s2)
+		got := exchange(t, newSecretProtector(s2, nil), reader)
+		if !bytes.Equal(got, expected) {
+			t.Error("message with transition secret not decrypted")
+		}
+		got = exchange(t, newSecretProtector(s1, nil), reader)
+		if !bytes.Equal(got, expected) {
+			t.Error("message with current secret not decrypted")
+		}
+	})
+
+	t.Run("drop transition", func(t *testing.T) {
+		reader := newSecretProtector(s2, s1)
+		reader.dropTransition(s2) // not the transition secret
+		got := exchange(t, newSecretProtector(s1, nil), reader)
+		if !bytes.Equal(got, expected) {
+			t.Error("transition secret should still be accepted")
+		}
+
+		reader.dropTransition(s1)
+		got = exchange(t, newSecretProtector(s1, nil), reader)
+		if bytes.Equal(got, expected) {
+			t.Error("dropped transition secret should not be accepted")
+		}
+	})
+
+	t.Run("fingerprint", func(t *testing.T) {
+		p := newSecretProtector(s1, nil)
+		fp := p.Fingerprint()
+		p.setSecrets(s2, s1)
+		if bytes.Equal(fp, p.Fingerprint()) {
+			t.Error("fingerprint should change with the secret")
+		}
+	})
+}
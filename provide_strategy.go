@@ -0,0 +1,160 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	ds "github.com/ipfs/go-datastore"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// provideStrategyDatastore is the name of the local datastore where the
+// provide strategy set at runtime is persisted.
+const provideStrategyDatastore = "provide_strategy"
+
+var provideStrategyKey = ds.NewKey("strategy")
+
+// reprovideInterval is the reprovider interval set in the IPFS daemons
+// which announce content. It is the default of IPFS.
+var reprovideInterval = 12 * time.Hour
+
+// SetProvideStrategy sends the given provide strategy to all the peers in
+// the cluster (see SetProvideStrategyLocal), which set the reprovider of
+// their IPFS daemons accordingly. It replaces the strategy in the
+// configuration of each peer. A strategy without a Strategy clears the
+// one previously set at runtime. It returns an error listing the peers
+// where it failed.
+func (c *Cluster) SetProvideStrategy(ctx context.Context, ps *api.ProvideStrategy) error {
+	_, span := trace.StartSpan(ctx, "cluster/SetProvideStrategy")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if ps == nil {
+		ps = &api.ProvideStrategy{}
+	}
+	if ps.Strategy != "" {
+		if err := ps.Validate(); err != nil {
+			return err
+		}
+	}
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := c.multiCall(
+		ctx,
+		peers,
+		"Cluster",
+		"SetProvideStrategyLocal",
+		ps,
+		rpcutil.RPCDiscardReplies(len(peers)),
+	)
+
+	for i, err := range errs {
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %s", peer.IDB58Encode(peers[i]), err)
+		}
+	}
+	return rpcutil.CheckErrs(errs)
+}
+
+// SetProvideStrategyLocal replaces the provide strategy set at runtime in
+// this peer and sets the reprovider of the IPFS daemon accordingly. The
+// strategy is persisted and survives restarts.
+func (c *Cluster) SetProvideStrategyLocal(ctx context.Context, ps *api.ProvideStrategy) error {
+	_, span := trace.StartSpan(ctx, "cluster/SetProvideStrategyLocal")
+	defer span.End()
+
+	c.provideMux.Lock()
+	store := c.localDatastore(provideStrategyDatastore)
+	if ps.Strategy == "" {
+		if err := store.Delete(provideStrategyKey); err != nil && err != ds.ErrNotFound {
+			c.provideMux.Unlock()
+			return err
+		}
+		c.provideStrategy = nil
+		logger.Info("provide strategy set at runtime cleared")
+	} else {
+		if err := ps.Validate(); err != nil {
+			c.provideMux.Unlock()
+			return err
+		}
+		b, err := json.Marshal(ps)
+		if err != nil {
+			c.provideMux.Unlock()
+			return err
+		}
+		if err := store.Put(provideStrategyKey, b); err != nil {
+			c.provideMux.Unlock()
+			return err
+		}
+		c.provideStrategy = ps
+	}
+	c.provideMux.Unlock()
+
+	effective := c.ProvideStrategy(ctx)
+	if effective.Strategy == "" {
+		return nil
+	}
+	return c.applyProvideStrategy(ctx, effective)
+}
+
+// ProvideStrategy returns the provide strategy followed by this peer: the
+// one set at runtime or, if none, the one in the configuration. The
+// Strategy is empty when the IPFS daemon is left as it is.
+func (c *Cluster) ProvideStrategy(ctx context.Context) *api.ProvideStrategy {
+	_, span := trace.StartSpan(ctx, "cluster/ProvideStrategy")
+	defer span.End()
+
+	c.provideMux.RLock()
+	defer c.provideMux.RUnlock()
+	ps := c.provideStrategy
+	if ps == nil {
+		ps = c.config.ProvideStrategy
+	}
+	if ps == nil {
+		return &api.ProvideStrategy{}
+	}
+	psCopy := *ps
+	return &psCopy
+}
+
+// applyProvideStrategy sets the reprovider of the IPFS daemon: the given
+// strategy when this peer announces content, or a 0 interval otherwise.
+func (c *Cluster) applyProvideStrategy(ctx context.Context, ps *api.ProvideStrategy) error {
+	rp := &api.IPFSReprovider{
+		Strategy: ps.Strategy,
+	}
+	if ps.Provides(c.id) {
+		rp.Interval = reprovideInterval
+	}
+	return c.ipfs.SetReprovider(ctx, rp)
+}
+
+// loadProvideStrategy loads the provide strategy set at runtime before the
+// peer was restarted.
+func (c *Cluster) loadProvideStrategy() error {
+	b, err := c.localDatastore(provideStrategyDatastore).Get(provideStrategyKey)
+	if err == ds.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ps := &api.ProvideStrategy{}
+	if err := json.Unmarshal(b, ps); err != nil {
+		return err
+	}
+	c.provideMux.Lock()
+	c.provideStrategy = ps
+	c.provideMux.Unlock()
+	return nil
+}
@@ -3,14 +3,29 @@
 // the libp2p Host. This includes resolving DNS addresses, decapsulating
 // and encapsulating the /p2p/ (/ipfs/) protocol as needed, listing, saving
 // and loading addresses.
+//
+// The Manager records when each address was last seen working. Addresses
+// which have not worked for AddressExpiry are not saved nor loaded again,
+// and the peerstore file holds at most MaxPeerstoreAddrs addresses. DNS
+// multiaddresses are remembered so that they can be resolved again with
+// ResolveDNSAddrs.
+//
+// When the peerstore file changes, the previous version is kept as a
+// timestamped backup next to it, up to PeerstoreBackups of them, so that it
+// can be restored with RestorePeerstore.
 package pstoremgr
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -36,6 +51,26 @@ var (
 	ConnectTimeout = 5 * time.Second
 )
 
+// AddressExpiry is the time after which an address that has not been seen
+// working is removed. Addresses of connected peers do not expire.
+var AddressExpiry = 30 * 24 * time.Hour
+
+// MaxPeerstoreAddrs is the maximum number of addresses saved to the
+// peerstore file. The least recently seen addresses are dropped first.
+var MaxPeerstoreAddrs = 1000
+
+// PeerstoreBackups is the number of previous versions of the peerstore file
+// that are kept. The oldest backups are removed first. Zero disables
+// backups.
+var PeerstoreBackups = 5
+
+// backupSuffix separates the peerstore path from the timestamp in the names
+// of backups.
+const backupSuffix = ".backup-"
+
+// backupTimeFormat sorts lexicographically in chronological order.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
 // Manager provides utilities for handling cluster peer addresses
 // and storing them in a libp2p Host peerstore.
 type Manager struct {
@@ -43,17 +78,77 @@ type Manager struct {
 	host          host.Host
 	peerstoreLock sync.Mutex
 	peerstorePath string
+
+	// last time each address (including the /p2p/ part) was seen
+	// working.
+	lastSeenLock sync.Mutex
+	lastSeen     map[string]time.Time
+
+	dnsLock  sync.Mutex
+	dnsAddrs map[string]*dnsAddr
+}
+
+// dnsAddr is a DNS multiaddress imported with ImportPeer, along with the
+// addresses it resolved to the last time.
+type dnsAddr struct {
+	addr     ma.Multiaddr
+	ttl      time.Duration
+	resolved map[string]ma.Multiaddr
 }
 
 // New creates a Manager with the given libp2p Host and peerstorePath.
 // The path indicates the place to persist and read peer addresses from.
 // If empty, these operations (LoadPeerstore, SavePeerstore) will no-op.
 func New(ctx context.Context, h host.Host, peerstorePath string) *Manager {
-	return &Manager{
+	pm := &Manager{
 		ctx:           ctx,
 		host:          h,
 		peerstorePath: peerstorePath,
+		lastSeen:      make(map[string]time.Time),
+		dnsAddrs:      make(map[string]*dnsAddr),
+	}
+	if h != nil {
+		h.Network().Notify(&net.NotifyBundle{
+			ConnectedF: pm.connected,
+		})
+	}
+	return pm
+}
+
+func addrKey(pid peer.ID, addr ma.Multiaddr) string {
+	return addr.String() + "/p2p/" + peer.IDB58Encode(pid)
+}
+
+// connected marks the address used by an outgoing connection as seen.
+func (pm *Manager) connected(n net.Network, c net.Conn) {
+	if c.Stat().Direction != net.DirOutbound {
+		return
+	}
+	pm.seen(addrKey(c.RemotePeer(), c.RemoteMultiaddr()), time.Now(), true)
+}
+
+// seen records the last time an address was seen working. Unless force is
+// set, it only does so for addresses we do not know about yet.
+func (pm *Manager) seen(key string, t time.Time, force bool) {
+	pm.lastSeenLock.Lock()
+	defer pm.lastSeenLock.Unlock()
+	if _, ok := pm.lastSeen[key]; ok && !force {
+		return
 	}
+	pm.lastSeen[key] = t
+}
+
+// LastSeen returns the last time the given address of a peer was seen
+// working. Addresses are considered seen when they are first added.
+func (pm *Manager) LastSeen(pid peer.ID, addr ma.Multiaddr) (time.Time, bool) {
+	pm.lastSeenLock.Lock()
+	defer pm.lastSeenLock.Unlock()
+	t, ok := pm.lastSeen[addrKey(pid, addr)]
+	return t, ok
+}
+
+func expired(t time.Time) bool {
+	return AddressExpiry > 0 && time.Since(t) > AddressExpiry
 }
 
 // ImportPeer adds a new peer address to the host's peerstore, optionally
@@ -86,6 +181,7 @@ func (pm *Manager) ImportPeer(addr ma.Multiaddr, connect bool, ttl time.Duration
 				return "", err
 			}
 		}
+		pm.trackDNSAddr(addr, ttl, resolvedAddrs)
 		return pid, nil // returns the last peer ID
 	}
 
@@ -101,6 +197,13 @@ func (pm *Manager) ImportPeer(addr ma.Multiaddr, connect bool, ttl time.Duration
 
 	logger.Debugf("adding peer address %s", addr)
 	pm.host.Peerstore().AddAddrs(pinfo.ID, pinfo.Addrs, ttl)
+	now := time.Now()
+	for _, a := range pinfo.Addrs {
+		pm.seen(addrKey(pinfo.ID, a), now, false)
+	}
+	if madns.Matches(addr) {
+		pm.trackDNSAddr(addr, ttl, nil)
+	}
 
 	if connect {
 		go func() {
@@ -120,6 +223,114 @@ func (pm *Manager) RmPeer(pid peer.ID) error {
 
 	logger.Debugf("forgetting peer %s", pid.Pretty())
 	pm.host.Peerstore().ClearAddrs(pid)
+
+	suffix := "/p2p/" + peer.IDB58Encode(pid)
+	pm.dnsLock.Lock()
+	for k := range pm.dnsAddrs {
+		if strings.HasSuffix(k, suffix) {
+			delete(pm.dnsAddrs, k)
+		}
+	}
+	pm.dnsLock.Unlock()
+
+	pm.lastSeenLock.Lock()
+	defer pm.lastSeenLock.Unlock()
+	for k := range pm.lastSeen {
+		if strings.HasSuffix(k, suffix) {
+			delete(pm.lastSeen, k)
+		}
+	}
+	return nil
+}
+
+// trackDNSAddr remembers a DNS multiaddress so that ResolveDNSAddrs
+// resolves it again.
+func (pm *Manager) trackDNSAddr(addr ma.Multiaddr, ttl time.Duration, resolved []ma.Multiaddr) {
+	pm.dnsLock.Lock()
+	defer pm.dnsLock.Unlock()
+
+	entry, ok := pm.dnsAddrs[addr.String()]
+	if !ok {
+		entry = &dnsAddr{
+			addr:     addr,
+			resolved: make(map[string]ma.Multiaddr),
+		}
+		pm.dnsAddrs[addr.String()] = entry
+	}
+	entry.ttl = ttl
+	for _, a := range resolved {
+		entry.resolved[a.String()] = a
+	}
+}
+
+// ResolveDNSAddrs resolves again all the DNS multiaddresses (dnsaddr, dns4
+// and dns6) imported with ImportPeer and updates the host's peerstore with
+// the results: new addresses are added and the addresses which are no
+// longer returned are removed. This allows to find peers whose IPs have
+// changed behind the same hostname.
+func (pm *Manager) ResolveDNSAddrs() {
+	if pm.host == nil {
+		return
+	}
+
+	pm.dnsLock.Lock()
+	entries := make([]*dnsAddr, 0, len(pm.dnsAddrs))
+	for _, e := range pm.dnsAddrs {
+		entries = append(entries, e)
+	}
+	pm.dnsLock.Unlock()
+
+	for _, e := range entries {
+		err := pm.resolveDNSAddr(e)
+		if err != nil {
+			logger.Warningf("error resolving %s: %s", e.addr, err)
+		}
+	}
+}
+
+func (pm *Manager) resolveDNSAddr(e *dnsAddr) error {
+	logger.Debugf("resolving %s", e.addr)
+	ctx, cancel := context.WithTimeout(pm.ctx, DNSTimeout)
+	defer cancel()
+
+	resolvedAddrs, err := madns.Resolve(ctx, e.addr)
+	if err != nil {
+		return err
+	}
+	if len(resolvedAddrs) == 0 {
+		return errors.New("no resolved addresses")
+	}
+
+	resolved := make(map[string]ma.Multiaddr)
+	for _, a := range resolvedAddrs {
+		pinfo, err := peer.AddrInfoFromP2pAddr(a)
+		if err != nil {
+			logger.Debugf("ignoring %s: %s", a, err)
+			continue
+		}
+		resolved[a.String()] = a
+		if pinfo.ID == pm.host.ID() {
+			continue
+		}
+		pm.host.Peerstore().AddAddrs(pinfo.ID, pinfo.Addrs, e.ttl)
+	}
+
+	pm.dnsLock.Lock()
+	defer pm.dnsLock.Unlock()
+	for k, a := range e.resolved {
+		if _, ok := resolved[k]; ok {
+			continue
+		}
+		pinfo, err := peer.AddrInfoFromP2pAddr(a)
+		if err != nil {
+			continue
+		}
+		logger.Infof("%s no longer resolves to %s", e.addr, a)
+		for _, old := range pinfo.Addrs {
+			pm.host.Peerstore().SetAddr(pinfo.ID, old, 0)
+		}
+	}
+	e.resolved = resolved
 	return nil
 }
 
@@ -204,7 +415,9 @@ func (pm *Manager) ImportPeersFromPeerstore(connect bool, ttl time.Duration) err
 }
 
 // LoadPeerstore parses the peerstore file and returns the list
-// of addresses read from it.
+// of addresses read from it. Each line holds an address, optionally
+// followed by the time it was last seen working. Expired addresses are
+// skipped.
 func (pm *Manager) LoadPeerstore() (addrs []ma.Multiaddr) {
 	if pm.peerstorePath == "" {
 		return
@@ -219,21 +432,40 @@ func (pm *Manager) LoadPeerstore() (addrs []ma.Multiaddr) {
 
 	defer f.Close()
 
+	now := time.Now()
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		addrStr := scanner.Text()
-		if len(addrStr) == 0 || addrStr[0] != '/' {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0][0] != '/' {
 			// skip anything that is not going to be a multiaddress
 			continue
 		}
-		addr, err := ma.NewMultiaddr(addrStr)
+		addr, err := ma.NewMultiaddr(fields[0])
 		if err != nil {
 			logger.Errorf(
 				"error parsing multiaddress from %s: %s",
 				pm.peerstorePath,
 				err,
 			)
+			continue
+		}
+
+		// Addresses without a valid last-seen time are considered
+		// as just seen.
+		lastSeen := now
+		if len(fields) > 1 {
+			t, err := time.Parse(time.RFC3339, fields[1])
+			if err != nil {
+				logger.Warningf("%s: bad last-seen time for %s: %s", pm.peerstorePath, addr, err)
+			} else {
+				lastSeen = t
+			}
 		}
+		if expired(lastSeen) {
+			logger.Debugf("skipping expired address %s", addr)
+			continue
+		}
+		pm.seen(addr.String(), lastSeen, true)
 		addrs = append(addrs, addr)
 	}
 	if err := scanner.Err(); err != nil {
@@ -243,45 +475,184 @@ func (pm *Manager) LoadPeerstore() (addrs []ma.Multiaddr) {
 }
 
 // SavePeerstore stores a slice of multiaddresses in the peerstore file, one
-// per line.
+// per line, along with the time they were last seen working. Expired
+// addresses are skipped (and removed from the host's peerstore), and only
+// the MaxPeerstoreAddrs most recently seen addresses are saved.
 func (pm *Manager) SavePeerstore(pinfos []peer.AddrInfo) error {
 	if pm.peerstorePath == "" {
 		return nil
 	}
 
+	entries := pm.peerstoreEntries(pinfos)
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "%s %s\n", e.addr, e.lastSeen.UTC().Format(time.RFC3339))
+	}
+
 	pm.peerstoreLock.Lock()
 	defer pm.peerstoreLock.Unlock()
 
-	f, err := os.Create(pm.peerstorePath)
+	err := pm.writePeerstore(buf.Bytes())
 	if err != nil {
 		logger.Errorf(
 			"could not save peer addresses to %s: %s",
 			pm.peerstorePath,
 			err,
 		)
+	}
+	return err
+}
+
+// writePeerstore replaces the contents of the peerstore file, backing up
+// the previous ones when they differ. The peerstoreLock must be held.
+func (pm *Manager) writePeerstore(content []byte) error {
+	old, err := ioutil.ReadFile(pm.peerstorePath)
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	defer f.Close()
+	if len(old) > 0 && !bytes.Equal(old, content) {
+		if err := pm.backupPeerstore(old); err != nil {
+			// A failed backup should not prevent saving
+			// the current addresses.
+			logger.Warningf("could not back up %s: %s", pm.peerstorePath, err)
+		}
+	}
+	return ioutil.WriteFile(pm.peerstorePath, content, 0666)
+}
+
+// backupPeerstore writes the given contents of the peerstore file to a new
+// backup and removes the backups beyond PeerstoreBackups.
+func (pm *Manager) backupPeerstore(content []byte) error {
+	if PeerstoreBackups <= 0 {
+		return nil
+	}
+	name := pm.peerstorePath + backupSuffix + time.Now().UTC().Format(backupTimeFormat)
+	if err := ioutil.WriteFile(name, content, 0600); err != nil {
+		return err
+	}
+
+	backups, err := pm.backups()
+	if err != nil {
+		return err
+	}
+	for i := PeerstoreBackups; i < len(backups); i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			logger.Warningf("could not remove old peerstore backup: %s", err)
+		}
+	}
+	return nil
+}
+
+// backups returns the paths of the peerstore backups, newest first.
+func (pm *Manager) backups() ([]string, error) {
+	matches, err := filepath.Glob(pm.peerstorePath + backupSuffix + "*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+	return matches, nil
+}
+
+// Backups returns the paths of the backups of the peerstore file,
+// newest first.
+func (pm *Manager) Backups() ([]string, error) {
+	if pm.peerstorePath == "" {
+		return nil, nil
+	}
+	pm.peerstoreLock.Lock()
+	defer pm.peerstoreLock.Unlock()
+	return pm.backups()
+}
+
+// RestorePeerstore replaces the peerstore file with the given backup, as
+// returned by Backups. The current file is backed up first, so
+// restoring can be undone. Backups without any valid address are refused.
+func (pm *Manager) RestorePeerstore(backup string) error {
+	if pm.peerstorePath == "" {
+		return errors.New("no peerstore path set")
+	}
+
+	content, err := ioutil.ReadFile(backup)
+	if err != nil {
+		return err
+	}
+	valid := false
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if _, err := ma.NewMultiaddr(fields[0]); err == nil {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("%s does not contain any peer addresses", backup)
+	}
 
+	pm.peerstoreLock.Lock()
+	defer pm.peerstoreLock.Unlock()
+	return pm.writePeerstore(content)
+}
+
+type peerstoreEntry struct {
+	addr     string
+	lastSeen time.Time
+}
+
+// peerstoreEntries returns the non-expired addresses to be saved, in the
+// given order, and capped to MaxPeerstoreAddrs.
+func (pm *Manager) peerstoreEntries(pinfos []peer.AddrInfo) []peerstoreEntry {
+	now := time.Now()
+	var entries []peerstoreEntry
 	for _, pinfo := range pinfos {
 		if len(pinfo.Addrs) == 0 {
 			logger.Warning("address info does not have any multiaddresses")
 			continue
 		}
 
-		addrs, err := peer.AddrInfoToP2pAddrs(&pinfo)
-		if err != nil {
-			logger.Warning(err)
-			continue
-		}
-		for _, a := range addrs {
-			_, err = f.Write([]byte(fmt.Sprintf("%s\n", a.String())))
-			if err != nil {
-				return err
+		// We cannot tell which address works for peers that
+		// connected to us, but they are alive.
+		connected := pm.host != nil && pm.host.Network().Connectedness(pinfo.ID) == net.Connected
+
+		for _, a := range pinfo.Addrs {
+			key := addrKey(pinfo.ID, a)
+			pm.seen(key, now, connected)
+			lastSeen, _ := pm.LastSeen(pinfo.ID, a)
+			if expired(lastSeen) {
+				logger.Infof("expiring address %s, not seen since %s", key, lastSeen)
+				if pm.host != nil {
+					pm.host.Peerstore().SetAddr(pinfo.ID, a, 0)
+				}
+				continue
 			}
+			entries = append(entries, peerstoreEntry{key, lastSeen})
 		}
 	}
-	return nil
+
+	if MaxPeerstoreAddrs <= 0 || len(entries) <= MaxPeerstoreAddrs {
+		return entries
+	}
+
+	// Find the oldest last-seen time that makes the cut and keep the
+	// original order otherwise.
+	times := make([]time.Time, len(entries))
+	for i, e := range entries {
+		times[i] = e.lastSeen
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].After(times[j]) })
+	cutoff := times[MaxPeerstoreAddrs-1]
+
+	kept := entries[:0]
+	for _, e := range entries {
+		if len(kept) < MaxPeerstoreAddrs && !e.lastSeen.Before(cutoff) {
+			kept = append(kept, e)
+		}
+	}
+	logger.Infof("peerstore file capped to %d addresses", MaxPeerstoreAddrs)
+	return kept
 }
 
 // SavePeerstoreForPeers calls PeerInfos and then saves the peerstore
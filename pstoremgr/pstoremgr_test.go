@@ -2,7 +2,10 @@ package pstoremgr
 
 import (
 	"context"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -24,6 +27,10 @@ func makeMgr(t *testing.T) *Manager {
 func clean(pm *Manager) {
 	if path := pm.peerstorePath; path != "" {
 		os.RemoveAll(path)
+		backups, _ := filepath.Glob(path + backupSuffix + "*")
+		for _, b := range backups {
+			os.RemoveAll(b)
+		}
 	}
 }
 
@@ -199,3 +206,200 @@ func TestPriority(t *testing.T) {
 		t.Error("wrong order of peerinfos")
 	}
 }
+
+func TestPeerstoreExpiry(t *testing.T) {
+	pm := makeMgr(t)
+	defer clean(pm)
+
+	loc := "/ip4/127.0.0.1/tcp/1234"
+	recent := testAddr(loc, test.PeerID1)
+	old := testAddr(loc, test.PeerID2)
+	noTime := testAddr(loc, test.PeerID3)
+
+	f, err := os.Create(pm.peerstorePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(f, "%s %s\n", recent, time.Now().Add(-time.Hour).Format(time.RFC3339))
+	fmt.Fprintf(f, "%s %s\n", old, time.Now().Add(-AddressExpiry-time.Hour).Format(time.RFC3339))
+	fmt.Fprintf(f, "%s\n", noTime)
+	f.Close()
+
+	addrs := pm.LoadPeerstore()
+	if len(addrs) != 2 {
+		t.Fatal("expected the expired address to be skipped")
+	}
+	if addrs[0].String() != recent.String() || addrs[1].String() != noTime.String() {
+		t.Error("unexpected addresses loaded")
+	}
+
+	lastSeen, ok := pm.LastSeen(test.PeerID1, ma.StringCast(loc))
+	if !ok || time.Since(lastSeen) < time.Hour-time.Minute {
+		t.Error("last-seen time not loaded")
+	}
+
+	err = pm.ImportPeers(addrs, false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make the first address expire
+	pm.seen(recent.String(), time.Now().Add(-AddressExpiry-time.Hour), true)
+
+	err = pm.SavePeerstoreForPeers([]peer.ID{test.PeerID1, test.PeerID3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrs = pm.LoadPeerstore()
+	if len(addrs) != 1 || addrs[0].String() != noTime.String() {
+		t.Error("expected only the non-expired address to be saved")
+	}
+
+	if len(pm.host.Peerstore().Addrs(test.PeerID1)) != 0 {
+		t.Error("expired address should have been removed from the peerstore")
+	}
+}
+
+func TestPeerstoreCap(t *testing.T) {
+	pm := makeMgr(t)
+	defer clean(pm)
+
+	max := MaxPeerstoreAddrs
+	MaxPeerstoreAddrs = 2
+	defer func() { MaxPeerstoreAddrs = max }()
+
+	loc := "/ip4/127.0.0.1/tcp/1234"
+	peers := []peer.ID{test.PeerID1, test.PeerID2, test.PeerID3}
+	var addrs []ma.Multiaddr
+	for _, p := range peers {
+		addrs = append(addrs, testAddr(loc, p))
+	}
+	err := pm.ImportPeers(addrs, false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// PeerID2 is the least recently seen.
+	pm.seen(addrs[1].String(), time.Now().Add(-time.Hour), true)
+
+	err = pm.SavePeerstoreForPeers(peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := pm.LoadPeerstore()
+	if len(loaded) != 2 {
+		t.Fatal("expected 2 addresses")
+	}
+	if loaded[0].String() != addrs[0].String() || loaded[1].String() != addrs[2].String() {
+		t.Error("expected the least recently seen address to be dropped")
+	}
+}
+
+func TestPeerstoreBackups(t *testing.T) {
+	pm := makeMgr(t)
+	defer clean(pm)
+
+	backups := PeerstoreBackups
+	PeerstoreBackups = 2
+	defer func() { PeerstoreBackups = backups }()
+
+	loc := "/ip4/127.0.0.1/tcp/1234"
+	peers := []peer.ID{test.PeerID1, test.PeerID2, test.PeerID3}
+	for i := range peers {
+		_, err := pm.ImportPeer(testAddr(loc, peers[i]), false, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = pm.SavePeerstoreForPeers(peers[:i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Saving the same addresses again does not create a backup.
+	err := pm.SavePeerstoreForPeers(peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := pm.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(list))
+	}
+
+	// An accidental truncation can be undone.
+	err = pm.SavePeerstore(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pm.LoadPeerstore()) != 0 {
+		t.Fatal("expected an empty peerstore")
+	}
+	list, err = pm.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pm.RestorePeerstore(list[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pm.LoadPeerstore()) != 3 {
+		t.Error("expected the addresses of 3 peers after restoring")
+	}
+
+	empty := pm.peerstorePath + ".empty"
+	defer os.Remove(empty)
+	err = ioutil.WriteFile(empty, []byte("\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.RestorePeerstore(empty); err == nil {
+		t.Error("expected an error restoring a backup without addresses")
+	}
+}
+
+func TestResolveDNSAddrs(t *testing.T) {
+	pm := makeMgr(t)
+	defer clean(pm)
+
+	dnsAddr := testAddr("/dns4/localhost/tcp/1235", test.PeerID1)
+	_, err := pm.ImportPeer(dnsAddr, false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend it used to resolve to a different IP
+	stale := testAddr("/ip4/127.0.0.2/tcp/1235", test.PeerID1)
+	pm.host.Peerstore().AddAddr(test.PeerID1, ma.StringCast("/ip4/127.0.0.2/tcp/1235"), time.Minute)
+	pm.dnsAddrs[dnsAddr.String()].resolved[stale.String()] = stale
+
+	pm.ResolveDNSAddrs()
+
+	var hasDNS, hasResolved bool
+	for _, a := range pm.host.Peerstore().Addrs(test.PeerID1) {
+		switch a.String() {
+		case "/dns4/localhost/tcp/1235":
+			hasDNS = true
+		case "/ip4/127.0.0.1/tcp/1235":
+			hasResolved = true
+		case "/ip4/127.0.0.2/tcp/1235":
+			t.Error("stale address should have been removed")
+		}
+	}
+	if !hasDNS {
+		t.Error("expected the dns address to be kept")
+	}
+	if !hasResolved {
+		t.Error("expected the resolved address to be added")
+	}
+
+	pm.RmPeer(test.PeerID1)
+	if len(pm.dnsAddrs) != 0 {
+		t.Error("expected the dns address to be forgotten with the peer")
+	}
+}
@@ -0,0 +1,227 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	"go.opencensus.io/trace"
+)
+
+var errPinNamespace = errors.New("this cid cannot be pinned in this namespace")
+
+// checkQuotas makes sure that a pin can be added: pins cannot be moved
+// between namespaces by re-pinning them, and new pins must fit in the
+// quotas of their namespace and of their owner, if any. Pins keep the owner
+// which first made them. The size of new pins subject to a quota is set.
+func (c *Cluster) checkQuotas(ctx context.Context, pin *api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/checkQuotas")
+	defer span.End()
+
+	existing, err := c.PinGet(ctx, pin.Cid)
+	if err == nil {
+		if existing.Namespace != pin.Namespace {
+			// Pins in other namespaces are not disclosed to
+			// namespaced users.
+			if pin.Namespace != "" {
+				return errPinNamespace
+			}
+			return fmt.Errorf("%s is already pinned in namespace %s", pin.Cid, existing.Namespace)
+		}
+		pin.Owner = existing.Owner
+		if pin.Size == 0 {
+			pin.Size = existing.Size
+		}
+		return nil // already accounted for
+	}
+
+	if !countsForQuota(pin) {
+		return nil
+	}
+	nsQuota := c.config.NamespaceQuotas[pin.Namespace]
+	userQuota := c.config.UserQuotas[pin.Owner]
+	if pin.Namespace == "" {
+		nsQuota = nil
+	}
+	if pin.Owner == "" {
+		userQuota = nil
+	}
+	if nsQuota == nil && userQuota == nil {
+		return nil
+	}
+
+	// Pins are sized even when only their number is limited, so that
+	// their bytes are known if a bytes quota is set later.
+	if pin.Size == 0 {
+		limitsBytes := nsQuota != nil && nsQuota.MaxBytes > 0 ||
+			userQuota != nil && userQuota.MaxBytes > 0
+		size, err := c.ipfs.DagSize(ctx, pin.Cid)
+		switch {
+		case err != nil && limitsBytes:
+			return fmt.Errorf("cannot obtain the size of %s to check its quotas: %s", pin.Cid, err)
+		case err != nil:
+			logger.Warningf("cannot obtain the size of %s: %s", pin.Cid, err)
+		default:
+			pin.Size = size
+		}
+	}
+
+	namespaces, users, err := c.quotaUsage(ctx)
+	if err != nil {
+		return err
+	}
+	if nsQuota != nil {
+		err := checkQuota("namespace "+pin.Namespace, namespaces[pin.Namespace], pin)
+		if err != nil {
+			return err
+		}
+	}
+	if userQuota != nil {
+		err := checkQuota("user "+pin.Owner, users[pin.Owner], pin)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkQuota returns an error when adding the given pin would put the
+// given usage over its quota.
+func checkQuota(what string, usage *api.QuotaUsage, pin *api.Pin) error {
+	if usage.MaxPins > 0 && usage.Pins+1 > usage.MaxPins {
+		return api.NewCodedError(api.ErrCodeQuotaExceeded, "%s is over quota: it cannot have more than %d pins", what, usage.MaxPins)
+	}
+	if usage.MaxBytes > 0 && usage.Bytes+pin.Size > usage.MaxBytes {
+		var available uint64
+		if usage.Bytes < usage.MaxBytes {
+			available = usage.MaxBytes - usage.Bytes
+		}
+		return api.NewCodedError(
+			api.ErrCodeQuotaExceeded,
+			"%s is over quota: %s needs %d bytes but only %d of %d are available",
+			what,
+			pin.Cid,
+			pin.Size,
+			available,
+			usage.MaxBytes,
+		)
+	}
+	return nil
+}
+
+// quotaUsage returns the usage of every namespace and user with pins or
+// with a quota, by name. It uses the pinset index when available and goes
+// through the state otherwise.
+func (c *Cluster) quotaUsage(ctx context.Context) (namespaces, users map[string]*api.QuotaUsage, err error) {
+	namespaces, users, ok := c.pinIndex.quotaUsage()
+	if !ok {
+		namespaces = make(map[string]*api.QuotaUsage)
+		users = make(map[string]*api.QuotaUsage)
+		cState, err := c.consensus.State(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		list, err := cState.List(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, pin := range list {
+			countQuotaUsage(namespaces, users, pin, 1)
+		}
+	}
+
+	for ns, q := range c.config.NamespaceQuotas {
+		u, ok := namespaces[ns]
+		if !ok {
+			u = &api.QuotaUsage{Namespace: ns}
+			namespaces[ns] = u
+		}
+		u.MaxPins = q.MaxPins
+		u.MaxBytes = q.MaxBytes
+	}
+	for user, q := range c.config.UserQuotas {
+		u, ok := users[user]
+		if !ok {
+			u = &api.QuotaUsage{User: user}
+			users[user] = u
+		}
+		u.MaxPins = q.MaxPins
+		u.MaxBytes = q.MaxBytes
+	}
+	return namespaces, users, nil
+}
+
+// countQuotaUsage adds (delta 1) or removes (delta -1) a pin to or from the
+// usage of its namespace and of its owner. Usages left without pins are
+// removed.
+func countQuotaUsage(namespaces, users map[string]*api.QuotaUsage, pin *api.Pin, delta int) {
+	if !countsForQuota(pin) {
+		return
+	}
+	count := func(usage map[string]*api.QuotaUsage, key string, newUsage func() *api.QuotaUsage) {
+		u, ok := usage[key]
+		if !ok {
+			if delta < 0 {
+				return
+			}
+			u = newUsage()
+			usage[key] = u
+		}
+		u.Pins += delta
+		if delta > 0 {
+			u.Bytes += pin.Size
+		} else if u.Bytes >= pin.Size {
+			u.Bytes -= pin.Size
+		} else {
+			u.Bytes = 0
+		}
+		if u.Pins <= 0 {
+			delete(usage, key)
+		}
+	}
+	if ns := pin.Namespace; ns != "" {
+		count(namespaces, ns, func() *api.QuotaUsage { return &api.QuotaUsage{Namespace: ns} })
+	}
+	if user := pin.Owner; user != "" {
+		count(users, user, func() *api.QuotaUsage { return &api.QuotaUsage{User: user} })
+	}
+}
+
+// QuotaUsage returns the number and the size of the pins in every
+// namespace and made by every user, along with their quotas. Namespaces
+// come first, sorted by name, followed by the users.
+func (c *Cluster) QuotaUsage(ctx context.Context) ([]*api.QuotaUsage, error) {
+	_, span := trace.StartSpan(ctx, "cluster/QuotaUsage")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	namespaces, users, err := c.quotaUsage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]*api.QuotaUsage, 0, len(namespaces)+len(users))
+	for _, u := range namespaces {
+		usage = append(usage, u)
+	}
+	for _, u := range users {
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].User != usage[j].User {
+			return usage[i].User < usage[j].User
+		}
+		return usage[i].Namespace < usage[j].Namespace
+	})
+	return usage, nil
+}
+
+// countsForQuota returns true for the pins requested by users. The pins
+// for shards and ClusterDAGs created when adding sharded content are
+// accounted for in the MetaPin.
+func countsForQuota(pin *api.Pin) bool {
+	return pin.Type == api.DataType || pin.Type == api.MetaType
+}
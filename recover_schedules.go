@@ -0,0 +1,267 @@
+package ipfscluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	ds "github.com/ipfs/go-datastore"
+	query "github.com/ipfs/go-datastore/query"
+	"go.opencensus.io/trace"
+)
+
+var errRecoverScheduleNotFound = errors.New("recover schedule not found")
+
+// recoverSchedulesDatastore is the name of the local datastore where
+// recover schedules are persisted.
+const recoverSchedulesDatastore = "recover_schedules"
+
+// recoverSchedule is a recover schedule run by this peer.
+type recoverSchedule struct {
+	info   *api.RecoverSchedule
+	cancel context.CancelFunc
+}
+
+// ScheduleRecover registers a recover schedule in this peer. Unlike Recover
+// and RecoverAll, which perform a single attempt, the items in error state
+// are recovered every rs.Interval until none remain in error or
+// rs.MaxAttempts attempts have been made. The first attempt is made right
+// away.
+//
+// Schedules are persisted in the local datastore and resumed when the peer
+// restarts. They are not leader tasks: a schedule only runs in the peer
+// where it was registered, so it is not duplicated across the cluster.
+func (c *Cluster) ScheduleRecover(ctx context.Context, rs *api.RecoverSchedule) (*api.RecoverSchedule, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ScheduleRecover")
+	defer span.End()
+
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+
+	c.recoversMux.Lock()
+	defer c.recoversMux.Unlock()
+
+	c.nextRecoverID++
+	now := time.Now()
+	info := &api.RecoverSchedule{
+		ID:          fmt.Sprintf("%d", c.nextRecoverID),
+		Cid:         rs.Cid,
+		Local:       rs.Local,
+		Interval:    rs.Interval,
+		MaxAttempts: rs.MaxAttempts,
+		Created:     now,
+		NextAttempt: now,
+	}
+	schedCtx, cancel := context.WithCancel(c.ctx)
+	c.recovers[info.ID] = &recoverSchedule{
+		info:   info,
+		cancel: cancel,
+	}
+	c.saveRecoverSchedule(info)
+
+	c.wg.Add(1)
+	go c.runRecoverSchedule(schedCtx, info)
+
+	infoCopy := *info
+	return &infoCopy, nil
+}
+
+// RecoverSchedules returns the recover schedules registered in this peer,
+// including finished ones, sorted by creation time.
+func (c *Cluster) RecoverSchedules(ctx context.Context) []*api.RecoverSchedule {
+	_, span := trace.StartSpan(ctx, "cluster/RecoverSchedules")
+	defer span.End()
+
+	c.recoversMux.Lock()
+	defer c.recoversMux.Unlock()
+
+	result := make([]*api.RecoverSchedule, 0, len(c.recovers))
+	for _, sched := range c.recovers {
+		infoCopy := *sched.info
+		result = append(result, &infoCopy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Created.Before(result[j].Created)
+	})
+	return result
+}
+
+// CancelRecoverSchedule stops the recover schedule with the given ID, if it
+// is still running, and removes it.
+func (c *Cluster) CancelRecoverSchedule(ctx context.Context, id string) error {
+	_, span := trace.StartSpan(ctx, "cluster/CancelRecoverSchedule")
+	defer span.End()
+
+	c.recoversMux.Lock()
+	defer c.recoversMux.Unlock()
+
+	sched, ok := c.recovers[id]
+	if !ok {
+		return errRecoverScheduleNotFound
+	}
+	sched.cancel()
+	delete(c.recovers, id)
+	if err := c.localDatastore(recoverSchedulesDatastore).Delete(ds.NewKey(id)); err != nil {
+		logger.Errorf("error removing recover schedule %s: %s", id, err)
+	}
+	logger.Infof("recover schedule %s cancelled", id)
+	return nil
+}
+
+func (c *Cluster) runRecoverSchedule(ctx context.Context, rs *api.RecoverSchedule) {
+	defer c.wg.Done()
+
+	timer := time.NewTimer(time.Until(rs.NextAttempt))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		errored, err := c.recoverAttempt(ctx, rs)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warningf("recover schedule %s: %s", rs.ID, err)
+		}
+
+		c.recoversMux.Lock()
+		now := time.Now()
+		rs.Attempts++
+		rs.LastAttempt = now
+		if err == nil {
+			rs.Errored = errored
+		}
+		done := (err == nil && errored == 0) || rs.Attempts >= rs.MaxAttempts
+		if done {
+			rs.Done = true
+			rs.NextAttempt = time.Time{}
+		} else {
+			rs.NextAttempt = now.Add(rs.Interval)
+		}
+		attempts := rs.Attempts
+		remaining := rs.Errored
+		c.saveRecoverSchedule(rs)
+		c.recoversMux.Unlock()
+
+		if done {
+			logger.Infof(
+				"recover schedule %s finished after %d attempts: %d items in error",
+				rs.ID,
+				attempts,
+				remaining,
+			)
+			return
+		}
+		timer.Reset(rs.Interval)
+	}
+}
+
+// saveRecoverSchedule persists a recover schedule. It must be called with
+// recoversMux held.
+func (c *Cluster) saveRecoverSchedule(rs *api.RecoverSchedule) {
+	b, err := json.Marshal(rs)
+	if err == nil {
+		err = c.localDatastore(recoverSchedulesDatastore).Put(ds.NewKey(rs.ID), b)
+	}
+	if err != nil {
+		logger.Errorf("error saving recover schedule %s: %s", rs.ID, err)
+	}
+}
+
+// loadRecoverSchedules loads the recover schedules persisted by a previous
+// run of the peer and resumes the unfinished ones.
+func (c *Cluster) loadRecoverSchedules() error {
+	results, err := c.localDatastore(recoverSchedulesDatastore).Query(query.Query{})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+
+	c.recoversMux.Lock()
+	defer c.recoversMux.Unlock()
+	for _, entry := range entries {
+		info := &api.RecoverSchedule{}
+		if err := json.Unmarshal(entry.Value, info); err != nil {
+			logger.Errorf("error loading recover schedule %s: %s", entry.Key, err)
+			continue
+		}
+		if id, err := strconv.ParseUint(info.ID, 10, 64); err == nil && id > c.nextRecoverID {
+			c.nextRecoverID = id
+		}
+
+		schedCtx, cancel := context.WithCancel(c.ctx)
+		c.recovers[info.ID] = &recoverSchedule{
+			info:   info,
+			cancel: cancel,
+		}
+		if !info.Done {
+			c.wg.Add(1)
+			go c.runRecoverSchedule(schedCtx, info)
+		}
+	}
+	return nil
+}
+
+// recoverAttempt performs a recover operation as described by the given
+// schedule and returns the number of items which remain in error. Items in
+// error in several peers are counted once per peer.
+func (c *Cluster) recoverAttempt(ctx context.Context, rs *api.RecoverSchedule) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/recoverAttempt")
+	defer span.End()
+
+	var pInfos []*api.PinInfo
+	switch {
+	case rs.Cid.Defined() && rs.Local:
+		pInfo, err := c.RecoverLocal(ctx, rs.Cid)
+		if err != nil {
+			return 0, err
+		}
+		pInfos = append(pInfos, pInfo)
+	case rs.Cid.Defined():
+		gpInfo, err := c.Recover(ctx, rs.Cid)
+		if err != nil {
+			return 0, err
+		}
+		for _, pInfo := range gpInfo.PeerMap {
+			pInfos = append(pInfos, pInfo)
+		}
+	case rs.Local:
+		var err error
+		pInfos, err = c.RecoverAllLocal(ctx)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		gpInfos, err := c.RecoverAll(ctx)
+		if err != nil {
+			return 0, err
+		}
+		for _, gpInfo := range gpInfos {
+			for _, pInfo := range gpInfo.PeerMap {
+				pInfos = append(pInfos, pInfo)
+			}
+		}
+	}
+
+	errored := 0
+	for _, pInfo := range pInfos {
+		if pInfo != nil && pInfo.Status.Match(api.TrackerStatusError) {
+			errored++
+		}
+	}
+	return errored, nil
+}
@@ -0,0 +1,340 @@
+package ipfscluster
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// topUpDegradedPins looks for degraded pins (those allocated to fewer peers
+// than their ReplicationFactorMax) and tries to allocate them to more peers
+// when there is capacity for them. It runs as a leader task, so a single
+// peer tops up every pin.
+func (c *Cluster) topUpDegradedPins(ctx context.Context) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/topUpDegradedPins")
+	defer span.End()
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := cState.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, pin := range list {
+		if !pin.Degraded() {
+			continue
+		}
+		c.topUpPin(ctx, pin)
+	}
+	return nil
+}
+
+// topUpPin allocates a degraded pin to additional peers, keeping the
+// current allocations. Nothing is done when there are no candidates for it
+// or when some of the current allocations would be dropped, as
+// re-allocating pins away from peers is left to repinning.
+func (c *Cluster) topUpPin(ctx context.Context, pin *api.Pin) {
+	ctx, span := trace.StartSpan(ctx, "cluster/topUpPin")
+	defer span.End()
+
+	topUp := *pin
+	topUp.ReplicationFactorMin = len(pin.Allocations) + 1
+	allocs, err := c.allocate(ctx, &topUp, nil)
+	if err != nil {
+		logger.Debugf("cannot top up %s: %s", pin.Cid, err)
+		return
+	}
+	if len(allocs) <= len(pin.Allocations) {
+		return
+	}
+	for _, p := range pin.Allocations {
+		if !containsPeer(allocs, p) {
+			return
+		}
+	}
+
+	if err := c.startWrite(); err != nil {
+		return
+	}
+	defer c.writesWg.Done()
+
+	pin.Allocations = allocs
+	err = c.consensus.LogPin(ctx, pin)
+	if err != nil {
+		logger.Warningf("error topping up %s: %s", pin.Cid, err)
+		return
+	}
+	logger.Infof("topped up %s: now allocated to %s", pin.Cid, pin.Allocations)
+}
+
+// Reasons for re-allocating the pins of a peer.
+const (
+	repinReasonDown        = "down"
+	repinReasonRemoved     = "removed"
+	repinReasonMaintenance = "maintenance"
+)
+
+// markPeerDown records that the given peer is down since the given time,
+// unless it was already known to be down. It returns the time since which
+// the peer is down.
+func (c *Cluster) markPeerDown(p peer.ID, t time.Time) time.Time {
+	c.repinsMux.Lock()
+	defer c.repinsMux.Unlock()
+	since, ok := c.downSince[p]
+	if !ok {
+		since = t
+		c.downSince[p] = since
+	}
+	return since
+}
+
+// repinDownPeers regularly re-allocates the pins of the peers which have
+// been down for longer than RepinDelay, and forgets about the peers which
+// have recovered.
+//
+// This is not a leader task: the pins are already split among the peers
+// (see shouldPeerRepinCid), so no work is duplicated, and a down peer may
+// well be the elected runner of a task under crdt, which would leave its
+// pins without anyone to re-allocate them.
+func (c *Cluster) repinDownPeers() {
+	ticker := time.NewTicker(repinCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkDownPeers(c.ctx)
+		}
+	}
+}
+
+func (c *Cluster) checkDownPeers(ctx context.Context) {
+	down := make(map[peer.ID]struct{})
+	for _, alrt := range c.Alerts(ctx) {
+		if alrt.MetricName == pingMetricName {
+			down[alrt.Peer] = struct{}{}
+		}
+	}
+
+	expired := make(map[peer.ID]time.Time)
+	c.repinsMux.Lock()
+	for p, since := range c.downSince {
+		if _, ok := down[p]; !ok {
+			delete(c.downSince, p) // peer has recovered
+			continue
+		}
+		if time.Since(since) >= c.config.RepinDelay {
+			expired[p] = since
+		}
+	}
+	c.repinsMux.Unlock()
+
+	if c.config.FollowerMode || c.config.DisableRepinning {
+		return
+	}
+	for p, since := range expired {
+		c.repinDownPeer(ctx, p, since)
+	}
+}
+
+// repinDownPeer re-allocates the pins of a peer which is down since the
+// given time, unless it has been done already. Every peer only re-allocates
+// the pins for which it is responsible (see shouldPeerRepinCid).
+func (c *Cluster) repinDownPeer(ctx context.Context, p peer.ID, since time.Time) {
+	ctx, span := trace.StartSpan(ctx, "cluster/repinDownPeer")
+	defer span.End()
+
+	progress, ok := c.startRepin(p, repinReasonDown, since)
+	if !ok {
+		return
+	}
+
+	var pins []*api.Pin
+	cState, err := c.consensus.State(ctx)
+	if err == nil {
+		var list []*api.Pin
+		list, err = cState.List(ctx)
+		for _, pin := range list {
+			if len(pin.Allocations) == 1 && containsPeer(pin.Allocations, p) {
+				logger.Warning("a pin with only one allocation cannot be repinned")
+				logger.Warning("to make repinning possible, pin with a replication factor of 2+")
+				continue
+			}
+			if c.shouldPeerRepinCid(p, pin) {
+				pins = append(pins, pin)
+			}
+		}
+	}
+	if err != nil {
+		logger.Warning(err)
+	}
+	c.repinPins(ctx, progress, pins)
+}
+
+// startRepin registers a new re-allocation of the pins of the given peer.
+// It returns false, and does nothing, when a re-allocation started after
+// the given time already.
+func (c *Cluster) startRepin(p peer.ID, reason string, since time.Time) (*api.RepinProgress, bool) {
+	c.repinsMux.Lock()
+	defer c.repinsMux.Unlock()
+
+	if pr, ok := c.repins[p]; ok && !pr.Started.Before(since) {
+		return nil, false
+	}
+	progress := &api.RepinProgress{
+		Peer:    p,
+		Reason:  reason,
+		Started: time.Now(),
+	}
+	c.repins[p] = progress
+	return progress, true
+}
+
+// repinPins re-allocates the given pins away from the peer of the given
+// progress report, observing RepinRateLimit, and updates the report.
+func (c *Cluster) repinPins(ctx context.Context, progress *api.RepinProgress, pins []*api.Pin) {
+	c.repinsMux.Lock()
+	progress.Total = len(pins)
+	c.repinsMux.Unlock()
+
+	for _, pin := range pins {
+		if err := c.waitRepin(ctx); err != nil {
+			return
+		}
+		err := c.repinFromPeer(ctx, progress.Peer, pin)
+		c.repinsMux.Lock()
+		if err != nil {
+			progress.Failed++
+		} else {
+			progress.Repinned++
+		}
+		c.repinsMux.Unlock()
+	}
+
+	c.repinsMux.Lock()
+	progress.Done = true
+	c.repinsMux.Unlock()
+	logger.Infof(
+		"re-allocation of pins from %s finished: %d repinned, %d failed",
+		progress.Peer.Pretty(),
+		progress.Repinned,
+		progress.Failed,
+	)
+}
+
+// waitRepin blocks until the next re-allocation is allowed by
+// RepinRateLimit.
+func (c *Cluster) waitRepin(ctx context.Context) error {
+	limit := c.config.RepinRateLimit
+	if limit <= 0 {
+		return nil
+	}
+
+	c.repinsMux.Lock()
+	now := time.Now()
+	next := c.nextRepin
+	if next.Before(now) {
+		next = now
+	}
+	c.nextRepin = next.Add(time.Minute / time.Duration(limit))
+	c.repinsMux.Unlock()
+
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RepinProgress returns the progress of the re-allocations of pins of down
+// or removed peers across the cluster. Every peer re-allocates a part of
+// the pins of down peers, so the reports from all peers are combined.
+func (c *Cluster) RepinProgress(ctx context.Context) ([]*api.RepinProgress, error) {
+	_, span := trace.StartSpan(ctx, "cluster/RepinProgress")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make([][]*api.RepinProgress, len(peers))
+	errs := c.multiCall(
+		ctx,
+		peers,
+		"Cluster",
+		"RepinProgressLocal",
+		struct{}{},
+		rpcutil.CopyRepinProgressSliceToIfaces(replies),
+	)
+
+	combined := make(map[peer.ID]*api.RepinProgress)
+	var result []*api.RepinProgress
+	for i, reply := range replies {
+		if errs[i] != nil {
+			logger.Warningf("error getting re-allocation progress from %s: %s", peers[i].Pretty(), errs[i])
+			continue
+		}
+		for _, pr := range reply {
+			cpr, ok := combined[pr.Peer]
+			if !ok || cpr.Reason != pr.Reason {
+				if ok && cpr.Started.After(pr.Started) {
+					continue // older re-allocation
+				}
+				prCopy := *pr
+				combined[pr.Peer] = &prCopy
+				continue
+			}
+			if pr.Started.Before(cpr.Started) {
+				cpr.Started = pr.Started
+			}
+			cpr.Total += pr.Total
+			cpr.Repinned += pr.Repinned
+			cpr.Failed += pr.Failed
+			cpr.Done = cpr.Done && pr.Done
+		}
+	}
+
+	for _, pr := range combined {
+		result = append(result, pr)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result, nil
+}
+
+// RepinProgressLocal returns the progress of the re-allocations of pins of
+// down or removed peers performed by this peer.
+func (c *Cluster) RepinProgressLocal(ctx context.Context) []*api.RepinProgress {
+	_, span := trace.StartSpan(ctx, "cluster/RepinProgressLocal")
+	defer span.End()
+
+	c.repinsMux.Lock()
+	defer c.repinsMux.Unlock()
+
+	result := make([]*api.RepinProgress, 0, len(c.repins))
+	for _, pr := range c.repins {
+		prCopy := *pr
+		result = append(result, &prCopy)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result
+}
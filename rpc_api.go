@@ -2,8 +2,11 @@ package ipfscluster
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/faults"
 	"github.com/ipfs/ipfs-cluster/version"
 
 	cid "github.com/ipfs/go-cid"
@@ -31,6 +34,30 @@ const (
 // RPCEndpointType controls how access is granted to an RPC endpoint
 type RPCEndpointType int
 
+// String returns the name used for the endpoint type in the configuration
+// ("closed", "trusted" or "open").
+func (t RPCEndpointType) String() string {
+	switch t {
+	case RPCClosed:
+		return "closed"
+	case RPCTrusted:
+		return "trusted"
+	case RPCOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+func parseRPCEndpointType(s string) (RPCEndpointType, error) {
+	for _, t := range []RPCEndpointType{RPCClosed, RPCTrusted, RPCOpen} {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	return RPCClosed, fmt.Errorf("unknown RPC endpoint type %q (use closed, trusted or open)", s)
+}
+
 // A trick to find where something is used (i.e. Cluster.Pin):
 // grep -R -B 3 '"Pin"' | grep -C 1 '"Cluster"'.
 // This does not cover globalPinInfo*(...) broadcasts nor redirects to leader
@@ -41,38 +68,45 @@ func newRPCServer(c *Cluster) (*rpc.Server, error) {
 	var s *rpc.Server
 
 	authF := func(pid peer.ID, svc, method string) bool {
+		if faults.DropRPC() {
+			logger.Debugf("rpc: dropping %s.%s from %s (fault injection)", svc, method, pid.Pretty())
+			return false
+		}
 		endpointType, ok := c.config.RPCPolicy[svc+"."+method]
 		if !ok {
 			return false
 		}
 
+		var authorized bool
 		switch endpointType {
 		case RPCTrusted:
-			return c.consensus.IsTrustedPeer(c.ctx, pid)
+			authorized = c.consensus.IsTrustedPeer(c.ctx, pid)
 		case RPCOpen:
-			return true
-		default:
-			return false
+			authorized = true
+		}
+		if !authorized {
+			logger.Debugf("rpc: %s is not authorized to call %s.%s (%s)", pid.Pretty(), svc, method, endpointType)
 		}
+		return authorized
 	}
 
+	timeoutsH := &rpcTimeoutHandler{timeouts: c.config.RPCTimeouts}
 	if c.config.Tracing {
-		s = rpc.NewServer(
-			c.host,
-			version.RPCProtocol,
-			rpc.WithServerStatsHandler(&ocgorpc.ServerHandler{}),
-			rpc.WithAuthorizeFunc(authF),
-		)
-	} else {
-		s = rpc.NewServer(c.host, version.RPCProtocol, rpc.WithAuthorizeFunc(authF))
+		timeoutsH.next = &ocgorpc.ServerHandler{}
 	}
+	s = rpc.NewServer(
+		c.host,
+		version.RPCProtocol,
+		rpc.WithServerStatsHandler(timeoutsH),
+		rpc.WithAuthorizeFunc(authF),
+	)
 
 	cl := &ClusterRPCAPI{c}
 	err := s.RegisterName(RPCServiceID(cl), cl)
 	if err != nil {
 		return nil, err
 	}
-	pt := &PinTrackerRPCAPI{c.tracker}
+	pt := &PinTrackerRPCAPI{c.tracker, c.pinIndex, c.statusPager}
 	err = s.RegisterName(RPCServiceID(pt), pt)
 	if err != nil {
 		return nil, err
@@ -123,6 +157,8 @@ type ClusterRPCAPI struct {
 // peer API for the PinTracker component.
 type PinTrackerRPCAPI struct {
 	tracker PinTracker
+	index   *pinIndex
+	pager   *pinInfoPager
 }
 
 // IPFSConnectorRPCAPI is a go-libp2p-gorpc service which provides the
@@ -159,7 +195,18 @@ func (rpcapi *ClusterRPCAPI) Pin(ctx context.Context, in *api.Pin, out *api.Pin)
 	// we do not call the Pin method directly since that method does not
 	// allow to pin other than regular DataType pins. The adder will
 	// however send Meta, Shard and ClusterDAG pins.
-	pin, _, err := rpcapi.c.pin(ctx, in, []peer.ID{})
+	pin, _, err := rpcapi.c.pin(ctx, in, []peer.ID{}, false)
+	if err != nil {
+		return err
+	}
+	*out = *pin
+	return nil
+}
+
+// ForcePin runs Cluster.pin() allowing to lift the protection of protected
+// pins.
+func (rpcapi *ClusterRPCAPI) ForcePin(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	pin, _, err := rpcapi.c.pin(ctx, in, []peer.ID{}, true)
 	if err != nil {
 		return err
 	}
@@ -177,6 +224,16 @@ func (rpcapi *ClusterRPCAPI) Unpin(ctx context.Context, in *api.Pin, out *api.Pi
 	return nil
 }
 
+// ForceUnpin runs Cluster.ForceUnpin().
+func (rpcapi *ClusterRPCAPI) ForceUnpin(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	pin, err := rpcapi.c.ForceUnpin(ctx, in.Cid)
+	if err != nil {
+		return err
+	}
+	*out = *pin
+	return nil
+}
+
 // PinPath resolves path into a cid and runs Cluster.Pin().
 func (rpcapi *ClusterRPCAPI) PinPath(ctx context.Context, in *api.PinPath, out *api.Pin) error {
 	pin, err := rpcapi.c.PinPath(ctx, in.Path, in.PinOptions)
@@ -207,6 +264,26 @@ func (rpcapi *ClusterRPCAPI) Pins(ctx context.Context, in struct{}, out *[]*api.
 	return nil
 }
 
+// PinsQuery runs Cluster.PinsQuery().
+func (rpcapi *ClusterRPCAPI) PinsQuery(ctx context.Context, in *api.PinQuery, out *[]*api.Pin) error {
+	pins, err := rpcapi.c.PinsQuery(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pins
+	return nil
+}
+
+// PinsView runs Cluster.PinsView().
+func (rpcapi *ClusterRPCAPI) PinsView(ctx context.Context, in *api.PinViewQuery, out *api.PinViewPage) error {
+	page, err := rpcapi.c.PinsView(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *page
+	return nil
+}
+
 // PinGet runs Cluster.PinGet().
 func (rpcapi *ClusterRPCAPI) PinGet(ctx context.Context, in cid.Cid, out *api.Pin) error {
 	pin, err := rpcapi.c.PinGet(ctx, in)
@@ -225,12 +302,40 @@ func (rpcapi *ClusterRPCAPI) Version(ctx context.Context, in struct{}, out *api.
 	return nil
 }
 
+// SetLogLevel runs Cluster.SetLogLevel().
+func (rpcapi *ClusterRPCAPI) SetLogLevel(ctx context.Context, in *api.LogLevel, out *struct{}) error {
+	return rpcapi.c.SetLogLevel(ctx, in.Facility, in.Level)
+}
+
+// Upgrade runs Cluster.Upgrade().
+func (rpcapi *ClusterRPCAPI) Upgrade(ctx context.Context, in *api.UpgradeOptions, out *struct{}) error {
+	return rpcapi.c.Upgrade(ctx, in)
+}
+
+// RollingUpgrade runs Cluster.RollingUpgrade().
+func (rpcapi *ClusterRPCAPI) RollingUpgrade(ctx context.Context, in *api.UpgradeOptions, out *struct{}) error {
+	return rpcapi.c.RollingUpgrade(ctx, in)
+}
+
+// Time returns the current time at this peer. It is used to detect clock
+// skew between peers.
+func (rpcapi *ClusterRPCAPI) Time(ctx context.Context, in struct{}, out *time.Time) error {
+	*out = time.Now()
+	return nil
+}
+
 // Peers runs Cluster.Peers().
 func (rpcapi *ClusterRPCAPI) Peers(ctx context.Context, in struct{}, out *[]*api.ID) error {
 	*out = rpcapi.c.Peers(ctx)
 	return nil
 }
 
+// PeerNames runs Cluster.PeerNames().
+func (rpcapi *ClusterRPCAPI) PeerNames(ctx context.Context, in struct{}, out *map[string]string) error {
+	*out = rpcapi.c.PeerNames(ctx)
+	return nil
+}
+
 // PeerAdd runs Cluster.PeerAdd().
 func (rpcapi *ClusterRPCAPI) PeerAdd(ctx context.Context, in peer.ID, out *api.ID) error {
 	id, err := rpcapi.c.PeerAdd(ctx, in)
@@ -256,6 +361,200 @@ func (rpcapi *ClusterRPCAPI) PeerRemove(ctx context.Context, in peer.ID, out *st
 	return rpcapi.c.PeerRemove(ctx, in)
 }
 
+// PeerRemoveDryRun runs Cluster.PeerRemoveDryRun().
+func (rpcapi *ClusterRPCAPI) PeerRemoveDryRun(ctx context.Context, in peer.ID, out *api.PeerRemoveReport) error {
+	report, err := rpcapi.c.PeerRemoveDryRun(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *report
+	return nil
+}
+
+// PeerMaintenance runs Cluster.PeerMaintenance().
+func (rpcapi *ClusterRPCAPI) PeerMaintenance(ctx context.Context, in *api.PeerMaintenance, out *struct{}) error {
+	return rpcapi.c.PeerMaintenance(ctx, in)
+}
+
+// PeerMaintenanceLocal runs Cluster.PeerMaintenanceLocal().
+func (rpcapi *ClusterRPCAPI) PeerMaintenanceLocal(ctx context.Context, in *api.PeerMaintenance, out *struct{}) error {
+	return rpcapi.c.PeerMaintenanceLocal(ctx, in)
+}
+
+// PeerActivate runs Cluster.PeerActivate().
+func (rpcapi *ClusterRPCAPI) PeerActivate(ctx context.Context, in peer.ID, out *struct{}) error {
+	return rpcapi.c.PeerActivate(ctx, in)
+}
+
+// PeerActivateLocal runs Cluster.PeerActivateLocal().
+func (rpcapi *ClusterRPCAPI) PeerActivateLocal(ctx context.Context, in struct{}, out *struct{}) error {
+	return rpcapi.c.PeerActivateLocal(ctx)
+}
+
+// AllocationExclusions runs Cluster.AllocationExclusions().
+func (rpcapi *ClusterRPCAPI) AllocationExclusions(ctx context.Context, in struct{}, out *api.AllocationExclusions) error {
+	*out = *rpcapi.c.AllocationExclusions(ctx)
+	return nil
+}
+
+// SetAllocationExclusions runs Cluster.SetAllocationExclusions().
+func (rpcapi *ClusterRPCAPI) SetAllocationExclusions(ctx context.Context, in *api.AllocationExclusions, out *struct{}) error {
+	return rpcapi.c.SetAllocationExclusions(ctx, in)
+}
+
+// SetAllocationExclusionsLocal runs Cluster.SetAllocationExclusionsLocal().
+func (rpcapi *ClusterRPCAPI) SetAllocationExclusionsLocal(ctx context.Context, in *api.AllocationExclusions, out *struct{}) error {
+	return rpcapi.c.SetAllocationExclusionsLocal(ctx, in)
+}
+
+// ProvideStrategy runs Cluster.ProvideStrategy().
+func (rpcapi *ClusterRPCAPI) ProvideStrategy(ctx context.Context, in struct{}, out *api.ProvideStrategy) error {
+	*out = *rpcapi.c.ProvideStrategy(ctx)
+	return nil
+}
+
+// SetProvideStrategy runs Cluster.SetProvideStrategy().
+func (rpcapi *ClusterRPCAPI) SetProvideStrategy(ctx context.Context, in *api.ProvideStrategy, out *struct{}) error {
+	return rpcapi.c.SetProvideStrategy(ctx, in)
+}
+
+// SetProvideStrategyLocal runs Cluster.SetProvideStrategyLocal().
+func (rpcapi *ClusterRPCAPI) SetProvideStrategyLocal(ctx context.Context, in *api.ProvideStrategy, out *struct{}) error {
+	return rpcapi.c.SetProvideStrategyLocal(ctx, in)
+}
+
+// RotatePeer runs Cluster.RotatePeer().
+func (rpcapi *ClusterRPCAPI) RotatePeer(ctx context.Context, in *api.PeerRotation, out *struct{}) error {
+	return rpcapi.c.RotatePeer(ctx, in)
+}
+
+// RotatePeerLocal runs Cluster.RotatePeerLocal().
+func (rpcapi *ClusterRPCAPI) RotatePeerLocal(ctx context.Context, in *api.PeerRotation, out *struct{}) error {
+	return rpcapi.c.RotatePeerLocal(ctx, in)
+}
+
+// BeginSecretRotation runs Cluster.BeginSecretRotation().
+func (rpcapi *ClusterRPCAPI) BeginSecretRotation(ctx context.Context, in []byte, out *struct{}) error {
+	return rpcapi.c.BeginSecretRotation(ctx, in)
+}
+
+// BeginSecretRotationLocal runs Cluster.BeginSecretRotationLocal().
+func (rpcapi *ClusterRPCAPI) BeginSecretRotationLocal(ctx context.Context, in []byte, out *struct{}) error {
+	return rpcapi.c.BeginSecretRotationLocal(ctx, in)
+}
+
+// FinalizeSecretRotation runs Cluster.FinalizeSecretRotation().
+func (rpcapi *ClusterRPCAPI) FinalizeSecretRotation(ctx context.Context, in struct{}, out *struct{}) error {
+	return rpcapi.c.FinalizeSecretRotation(ctx)
+}
+
+// FinalizeSecretRotationLocal runs Cluster.FinalizeSecretRotationLocal().
+func (rpcapi *ClusterRPCAPI) FinalizeSecretRotationLocal(ctx context.Context, in struct{}, out *struct{}) error {
+	return rpcapi.c.FinalizeSecretRotationLocal(ctx)
+}
+
+// ImportPeerAddrs runs Cluster.ImportPeerAddrs().
+func (rpcapi *ClusterRPCAPI) ImportPeerAddrs(ctx context.Context, in []api.Multiaddr, out *struct{}) error {
+	return rpcapi.c.ImportPeerAddrs(ctx, in)
+}
+
+// KnownPeers runs Cluster.KnownPeers().
+func (rpcapi *ClusterRPCAPI) KnownPeers(ctx context.Context, in struct{}, out *[]*api.KnownPeer) error {
+	known, err := rpcapi.c.KnownPeers(ctx)
+	if err != nil {
+		return err
+	}
+	*out = known
+	return nil
+}
+
+// ImportKnownPeers runs Cluster.ImportKnownPeers().
+func (rpcapi *ClusterRPCAPI) ImportKnownPeers(ctx context.Context, in []*api.KnownPeer, out *struct{}) error {
+	return rpcapi.c.ImportKnownPeers(ctx, in)
+}
+
+// RepinProgress runs Cluster.RepinProgress().
+func (rpcapi *ClusterRPCAPI) RepinProgress(ctx context.Context, in struct{}, out *[]*api.RepinProgress) error {
+	progress, err := rpcapi.c.RepinProgress(ctx)
+	if err != nil {
+		return err
+	}
+	*out = progress
+	return nil
+}
+
+// RepinProgressLocal runs Cluster.RepinProgressLocal().
+func (rpcapi *ClusterRPCAPI) RepinProgressLocal(ctx context.Context, in struct{}, out *[]*api.RepinProgress) error {
+	*out = rpcapi.c.RepinProgressLocal(ctx)
+	return nil
+}
+
+// QuotaUsage runs Cluster.QuotaUsage().
+func (rpcapi *ClusterRPCAPI) QuotaUsage(ctx context.Context, in struct{}, out *[]*api.QuotaUsage) error {
+	usage, err := rpcapi.c.QuotaUsage(ctx)
+	if err != nil {
+		return err
+	}
+	*out = usage
+	return nil
+}
+
+// Stats runs Cluster.Stats().
+func (rpcapi *ClusterRPCAPI) Stats(ctx context.Context, in struct{}, out *api.ClusterStats) error {
+	stats, err := rpcapi.c.Stats(ctx)
+	if err != nil {
+		return err
+	}
+	*out = *stats
+	return nil
+}
+
+// StatsLocal runs Cluster.StatsLocal().
+func (rpcapi *ClusterRPCAPI) StatsLocal(ctx context.Context, in struct{}, out *api.PeerStats) error {
+	*out = *rpcapi.c.StatsLocal(ctx)
+	return nil
+}
+
+// ShardsGC runs Cluster.ShardsGC().
+func (rpcapi *ClusterRPCAPI) ShardsGC(ctx context.Context, in struct{}, out *[]*api.Pin) error {
+	unpinned, err := rpcapi.c.ShardsGC(ctx)
+	if err != nil {
+		return err
+	}
+	*out = unpinned
+	return nil
+}
+
+// StateCheckpoint runs Cluster.StateCheckpoint().
+func (rpcapi *ClusterRPCAPI) StateCheckpoint(ctx context.Context, in struct{}, out *api.Pin) error {
+	pin, err := rpcapi.c.StateCheckpoint(ctx)
+	if err != nil {
+		return err
+	}
+	*out = *pin
+	return nil
+}
+
+// StateCheckpoints runs Cluster.StateCheckpoints().
+func (rpcapi *ClusterRPCAPI) StateCheckpoints(ctx context.Context, in struct{}, out *[]*api.Pin) error {
+	checkpoints, err := rpcapi.c.StateCheckpoints(ctx)
+	if err != nil {
+		return err
+	}
+	*out = checkpoints
+	return nil
+}
+
+// StateCheckpointPins runs Cluster.StateCheckpointPins().
+func (rpcapi *ClusterRPCAPI) StateCheckpointPins(ctx context.Context, in cid.Cid, out *[]*api.Pin) error {
+	pins, err := rpcapi.c.StateCheckpointPins(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pins
+	return nil
+}
+
 // Join runs Cluster.Join().
 func (rpcapi *ClusterRPCAPI) Join(ctx context.Context, in api.Multiaddr, out *struct{}) error {
 	return rpcapi.c.Join(ctx, in.Value())
@@ -278,6 +577,16 @@ func (rpcapi *ClusterRPCAPI) StatusAllLocal(ctx context.Context, in struct{}, ou
 	return nil
 }
 
+// StatusChanges runs Cluster.StatusChanges().
+func (rpcapi *ClusterRPCAPI) StatusChanges(ctx context.Context, in time.Time, out *api.StatusChanges) error {
+	changes, err := rpcapi.c.StatusChanges(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *changes
+	return nil
+}
+
 // Status runs Cluster.Status().
 func (rpcapi *ClusterRPCAPI) Status(ctx context.Context, in cid.Cid, out *api.GlobalPinInfo) error {
 	pinfo, err := rpcapi.c.Status(ctx, in)
@@ -335,6 +644,118 @@ func (rpcapi *ClusterRPCAPI) RecoverLocal(ctx context.Context, in cid.Cid, out *
 	return nil
 }
 
+// ScheduleRecover runs Cluster.ScheduleRecover().
+func (rpcapi *ClusterRPCAPI) ScheduleRecover(ctx context.Context, in *api.RecoverSchedule, out *api.RecoverSchedule) error {
+	rs, err := rpcapi.c.ScheduleRecover(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *rs
+	return nil
+}
+
+// RecoverSchedules runs Cluster.RecoverSchedules().
+func (rpcapi *ClusterRPCAPI) RecoverSchedules(ctx context.Context, in struct{}, out *[]*api.RecoverSchedule) error {
+	*out = rpcapi.c.RecoverSchedules(ctx)
+	return nil
+}
+
+// CancelRecoverSchedule runs Cluster.CancelRecoverSchedule().
+func (rpcapi *ClusterRPCAPI) CancelRecoverSchedule(ctx context.Context, in string, out *struct{}) error {
+	return rpcapi.c.CancelRecoverSchedule(ctx, in)
+}
+
+// SubmitJob runs Cluster.SubmitJob().
+func (rpcapi *ClusterRPCAPI) SubmitJob(ctx context.Context, in *api.Job, out *api.Job) error {
+	j, err := rpcapi.c.SubmitJob(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *j
+	return nil
+}
+
+// Jobs runs Cluster.Jobs().
+func (rpcapi *ClusterRPCAPI) Jobs(ctx context.Context, in struct{}, out *[]*api.Job) error {
+	*out = rpcapi.c.Jobs(ctx)
+	return nil
+}
+
+// Job runs Cluster.Job().
+func (rpcapi *ClusterRPCAPI) Job(ctx context.Context, in string, out *api.Job) error {
+	j, err := rpcapi.c.Job(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *j
+	return nil
+}
+
+// CancelJob runs Cluster.CancelJob().
+func (rpcapi *ClusterRPCAPI) CancelJob(ctx context.Context, in string, out *struct{}) error {
+	return rpcapi.c.CancelJob(ctx, in)
+}
+
+// PinCollection runs Cluster.PinCollection().
+func (rpcapi *ClusterRPCAPI) PinCollection(ctx context.Context, in *api.Collection, out *api.Collection) error {
+	col, err := rpcapi.c.PinCollection(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *col
+	return nil
+}
+
+// UnpinCollection runs Cluster.UnpinCollection().
+func (rpcapi *ClusterRPCAPI) UnpinCollection(ctx context.Context, in string, out *api.Collection) error {
+	col, err := rpcapi.c.UnpinCollection(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *col
+	return nil
+}
+
+// Collection runs Cluster.Collection().
+func (rpcapi *ClusterRPCAPI) Collection(ctx context.Context, in string, out *api.Collection) error {
+	col, err := rpcapi.c.Collection(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = *col
+	return nil
+}
+
+// Collections runs Cluster.Collections().
+func (rpcapi *ClusterRPCAPI) Collections(ctx context.Context, in struct{}, out *[]*api.Collection) error {
+	cols, err := rpcapi.c.Collections(ctx)
+	if err != nil {
+		return err
+	}
+	*out = cols
+	return nil
+}
+
+// CollectionStatus runs Cluster.CollectionStatus().
+func (rpcapi *ClusterRPCAPI) CollectionStatus(ctx context.Context, in string, out *[]*api.GlobalPinInfo) error {
+	gpis, err := rpcapi.c.CollectionStatus(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = gpis
+	return nil
+}
+
+// PushConfig runs Cluster.PushConfig().
+func (rpcapi *ClusterRPCAPI) PushConfig(ctx context.Context, in []*api.ConfigSection, out *struct{}) error {
+	return rpcapi.c.PushConfig(ctx, in)
+}
+
+// PushConfigLocal runs Cluster.PushConfigLocal().
+func (rpcapi *ClusterRPCAPI) PushConfigLocal(ctx context.Context, in []*api.ConfigSection, out *struct{}) error {
+	return rpcapi.c.PushConfigLocal(ctx, in)
+}
+
 // BlockAllocate returns allocations for blocks. This is used in the adders.
 // It's different from pin allocations when ReplicationFactor < 0.
 func (rpcapi *ClusterRPCAPI) BlockAllocate(ctx context.Context, in *api.Pin, out *[]peer.ID) error {
@@ -342,7 +763,7 @@ func (rpcapi *ClusterRPCAPI) BlockAllocate(ctx context.Context, in *api.Pin, out
 		return errFollowerMode
 	}
 
-	err := rpcapi.c.setupPin(ctx, in)
+	err := rpcapi.c.setupPin(ctx, in, false)
 	if err != nil {
 		return err
 	}
@@ -361,14 +782,7 @@ func (rpcapi *ClusterRPCAPI) BlockAllocate(ctx context.Context, in *api.Pin, out
 		return nil
 	}
 
-	allocs, err := rpcapi.c.allocate(
-		ctx,
-		in.Cid,
-		in.ReplicationFactorMin,
-		in.ReplicationFactorMax,
-		[]peer.ID{},        // blacklist
-		in.UserAllocations, // prio list
-	)
+	allocs, err := rpcapi.c.allocate(ctx, in, []peer.ID{})
 
 	if err != nil {
 		return err
@@ -398,6 +812,22 @@ func (rpcapi *ClusterRPCAPI) RepoGCLocal(ctx context.Context, in struct{}, out *
 	return nil
 }
 
+// Prefetch runs Cluster.Prefetch().
+func (rpcapi *ClusterRPCAPI) Prefetch(ctx context.Context, in *api.Prefetch, out *[]*api.PrefetchResult) error {
+	res, err := rpcapi.c.Prefetch(ctx, in.Cid, in.Peers)
+	if err != nil {
+		return err
+	}
+	*out = res
+	return nil
+}
+
+// Alerts runs Cluster.Alerts().
+func (rpcapi *ClusterRPCAPI) Alerts(ctx context.Context, in struct{}, out *[]*api.Alert) error {
+	*out = rpcapi.c.Alerts(ctx)
+	return nil
+}
+
 // SendInformerMetric runs Cluster.sendInformerMetric().
 func (rpcapi *ClusterRPCAPI) SendInformerMetric(ctx context.Context, in struct{}, out *api.Metric) error {
 	m, err := rpcapi.c.sendInformerMetric(ctx, rpcapi.c.informers[0])
@@ -427,6 +857,7 @@ func (rpcapi *ClusterRPCAPI) SendInformersMetrics(ctx context.Context, in struct
 func (rpcapi *PinTrackerRPCAPI) Track(ctx context.Context, in *api.Pin, out *struct{}) error {
 	ctx, span := trace.StartSpan(ctx, "rpc/tracker/Track")
 	defer span.End()
+	rpcapi.index.add(in)
 	return rpcapi.tracker.Track(ctx, in)
 }
 
@@ -434,6 +865,7 @@ func (rpcapi *PinTrackerRPCAPI) Track(ctx context.Context, in *api.Pin, out *str
 func (rpcapi *PinTrackerRPCAPI) Untrack(ctx context.Context, in *api.Pin, out *struct{}) error {
 	ctx, span := trace.StartSpan(ctx, "rpc/tracker/Untrack")
 	defer span.End()
+	rpcapi.index.rm(in.Cid)
 	return rpcapi.tracker.Untrack(ctx, in.Cid)
 }
 
@@ -445,6 +877,18 @@ func (rpcapi *PinTrackerRPCAPI) StatusAll(ctx context.Context, in struct{}, out
 	return nil
 }
 
+// StatusAllPage returns a page of PinTracker.StatusAll().
+func (rpcapi *PinTrackerRPCAPI) StatusAllPage(ctx context.Context, in *api.PageRequest, out *api.PinInfoPage) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/tracker/StatusAllPage")
+	defer span.End()
+	page, err := rpcapi.pager.page(in, rpcapi.tracker.StatusAllChan)
+	if err != nil {
+		return err
+	}
+	*out = *page
+	return nil
+}
+
 // Status runs PinTracker.Status().
 func (rpcapi *PinTrackerRPCAPI) Status(ctx context.Context, in cid.Cid, out *api.PinInfo) error {
 	ctx, span := trace.StartSpan(ctx, "rpc/tracker/Status")
@@ -475,6 +919,34 @@ func (rpcapi *PinTrackerRPCAPI) Recover(ctx context.Context, in cid.Cid, out *ap
 	return err
 }
 
+// PendingOperations runs PinTracker.PendingOperations().
+func (rpcapi *PinTrackerRPCAPI) PendingOperations(ctx context.Context, in struct{}, out *int) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/tracker/PendingOperations")
+	defer span.End()
+	*out = rpcapi.tracker.PendingOperations(ctx)
+	return nil
+}
+
+// StatusChanges runs PinTracker.StatusChanges().
+func (rpcapi *PinTrackerRPCAPI) StatusChanges(ctx context.Context, in time.Time, out *[]*api.PinInfo) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/tracker/StatusChanges")
+	defer span.End()
+	pinfos, err := rpcapi.tracker.StatusChanges(ctx, in)
+	if err != nil {
+		return err
+	}
+	*out = pinfos
+	return nil
+}
+
+// Operations runs PinTracker.Operations().
+func (rpcapi *PinTrackerRPCAPI) Operations(ctx context.Context, in struct{}, out *[]*api.TrackerOperation) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/tracker/Operations")
+	defer span.End()
+	*out = rpcapi.tracker.Operations(ctx)
+	return nil
+}
+
 /*
    IPFS Connector component methods
 */
@@ -556,6 +1028,13 @@ func (rpcapi *IPFSConnectorRPCAPI) BlockGet(ctx context.Context, in cid.Cid, out
 	return nil
 }
 
+// Prefetch runs IPFSConnector.Prefetch().
+func (rpcapi *IPFSConnectorRPCAPI) Prefetch(ctx context.Context, in cid.Cid, out *struct{}) error {
+	ctx, span := trace.StartSpan(ctx, "rpc/ipfsconn/Prefetch")
+	defer span.End()
+	return rpcapi.ipfs.Prefetch(ctx, in)
+}
+
 // Resolve runs IPFSConnector.Resolve().
 func (rpcapi *IPFSConnectorRPCAPI) Resolve(ctx context.Context, in string, out *cid.Cid) error {
 	c, err := rpcapi.ipfs.Resolve(ctx, in)
@@ -0,0 +1,253 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// pageSessionTTL is how long a peer keeps a listing open after a page of
+// it was last requested.
+var pageSessionTTL = time.Minute
+
+// maxPageSessions is the maximum number of listings which a peer serves in
+// pages at the same time.
+var maxPageSessions = 16
+
+var (
+	errPageSessionNotFound = errors.New("page session not found or expired")
+	errTooManyPageSessions = errors.New("too many paged listings in progress. Try again later")
+)
+
+// pinInfoPager serves PinInfo listings in pages. The listing is streamed
+// when the first page is requested and read as pages are requested, so
+// that it is never fully held in memory. Sessions are dropped after their
+// last page is served or when they expire.
+type pinInfoPager struct {
+	mu       sync.Mutex
+	next     uint64
+	sessions map[string]*pinInfoSession
+}
+
+type pinInfoSession struct {
+	mu     sync.Mutex
+	items  chan *api.PinInfo
+	errc   chan error
+	cancel context.CancelFunc
+	timer  *time.Timer
+	// offset of the next item to be read.
+	offset int
+	// peeked holds the first item of the next page, if any.
+	peeked *api.PinInfo
+}
+
+func newPinInfoPager() *pinInfoPager {
+	return &pinInfoPager{
+		sessions: make(map[string]*pinInfoSession),
+	}
+}
+
+// page returns the requested page. stream is called to start the listing
+// when a new session starts: it must send the items on the given channel
+// and close it when done.
+func (pg *pinInfoPager) page(req *api.PageRequest, stream func(context.Context, chan<- *api.PinInfo) error) (*api.PinInfoPage, error) {
+	if req.Limit <= 0 {
+		return nil, errors.New("the page limit must be positive")
+	}
+
+	id := req.Session
+	s, err := pg.session(id, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Offset != s.offset {
+		return nil, errors.New("page offset out of range")
+	}
+
+	items, more, err := s.read(req.Limit)
+	if err != nil || !more {
+		if id != "" {
+			pg.drop(id)
+		} else {
+			s.cancel()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if id == "" { // single page
+			return &api.PinInfoPage{Items: items}, nil
+		}
+		return &api.PinInfoPage{Session: id, Items: items}, nil
+	}
+
+	if id == "" {
+		id, err = pg.add(s)
+		if err != nil {
+			s.cancel()
+			return nil, err
+		}
+	} else {
+		s.timer.Reset(pageSessionTTL)
+	}
+	return &api.PinInfoPage{
+		Session: id,
+		Items:   items,
+		More:    true,
+	}, nil
+}
+
+// session returns the session with the given id or, when empty, starts a
+// new one which is not registered yet.
+func (pg *pinInfoPager) session(id string, stream func(context.Context, chan<- *api.PinInfo) error) (*pinInfoSession, error) {
+	if id != "" {
+		pg.mu.Lock()
+		defer pg.mu.Unlock()
+		s, ok := pg.sessions[id]
+		if !ok {
+			return nil, errPageSessionNotFound
+		}
+		return s, nil
+	}
+
+	pg.mu.Lock()
+	full := len(pg.sessions) >= maxPageSessions
+	pg.mu.Unlock()
+	if full {
+		return nil, errTooManyPageSessions
+	}
+
+	// The listing outlives the request which starts it.
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &pinInfoSession{
+		items:  make(chan *api.PinInfo, 64),
+		errc:   make(chan error, 1),
+		cancel: cancel,
+	}
+	go func() {
+		s.errc <- stream(ctx, s.items)
+	}()
+	return s, nil
+}
+
+// add registers a session which has more pages.
+func (pg *pinInfoPager) add(s *pinInfoSession) (string, error) {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	if len(pg.sessions) >= maxPageSessions {
+		return "", errTooManyPageSessions
+	}
+	pg.next++
+	id := fmt.Sprintf("%d", pg.next)
+	pg.sessions[id] = s
+	s.timer = time.AfterFunc(pageSessionTTL, func() {
+		pg.drop(id)
+	})
+	return id, nil
+}
+
+// drop removes a session and stops its listing.
+func (pg *pinInfoPager) drop(id string) {
+	pg.mu.Lock()
+	s, ok := pg.sessions[id]
+	delete(pg.sessions, id)
+	pg.mu.Unlock()
+	if ok {
+		s.timer.Stop()
+		s.cancel()
+	}
+}
+
+// read returns up to limit items and whether more items follow.
+func (s *pinInfoSession) read(limit int) ([]*api.PinInfo, bool, error) {
+	items := make([]*api.PinInfo, 0, limit)
+	if s.peeked != nil {
+		items = append(items, s.peeked)
+		s.peeked = nil
+	}
+	for len(items) < limit {
+		item, ok := <-s.items
+		if !ok {
+			s.offset += len(items)
+			return items, false, <-s.errc
+		}
+		items = append(items, item)
+	}
+	s.offset += len(items)
+
+	// Find out whether this is the last page.
+	item, ok := <-s.items
+	if !ok {
+		return items, false, <-s.errc
+	}
+	s.peeked = item
+	return items, true, nil
+}
+
+// statusAllPaged obtains the tracker status of all pins from the given peer
+// in pages of RPCPageSize items, passing every page to merge as it arrives.
+// Peers which do not serve pages yet are asked for the whole listing at
+// once.
+func (c *Cluster) statusAllPaged(ctx context.Context, dest peer.ID, merge func([]*api.PinInfo)) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/statusAllPaged")
+	defer span.End()
+
+	req := &api.PageRequest{Limit: c.config.RPCPageSize}
+	for {
+		var page api.PinInfoPage
+		pageCtx, cancel := c.rpcContext(ctx, "PinTracker", "StatusAllPage")
+		err := c.rpcClient.CallContext(
+			pageCtx,
+			dest,
+			"PinTracker",
+			"StatusAllPage",
+			req,
+			&page,
+		)
+		cancel()
+		if err != nil && req.Session == "" && strings.Contains(err.Error(), "can't find method") {
+			return c.statusAllUnpaged(ctx, dest, merge)
+		}
+		if err != nil {
+			return err
+		}
+
+		merge(page.Items)
+		if !page.More {
+			return nil
+		}
+		req.Session = page.Session
+		req.Offset += len(page.Items)
+	}
+}
+
+func (c *Cluster) statusAllUnpaged(ctx context.Context, dest peer.ID, merge func([]*api.PinInfo)) error {
+	ctx, cancel := c.rpcContext(ctx, "PinTracker", "StatusAll")
+	defer cancel()
+
+	var pinInfos []*api.PinInfo
+	err := c.rpcClient.CallContext(
+		ctx,
+		dest,
+		"PinTracker",
+		"StatusAll",
+		struct{}{},
+		&pinInfos,
+	)
+	if err != nil {
+		return err
+	}
+	merge(pinInfos)
+	return nil
+}
@@ -7,40 +7,99 @@ package ipfscluster
 // without missing any endpoint.
 var DefaultRPCPolicy = map[string]RPCEndpointType{
 	// Cluster methods
-	"Cluster.BlockAllocate":        RPCClosed,
-	"Cluster.ConnectGraph":         RPCClosed,
-	"Cluster.ID":                   RPCOpen,
-	"Cluster.Join":                 RPCClosed,
-	"Cluster.PeerAdd":              RPCOpen, // Used by Join()
-	"Cluster.PeerRemove":           RPCTrusted,
-	"Cluster.Peers":                RPCTrusted, // Used by ConnectGraph()
-	"Cluster.Pin":                  RPCClosed,
-	"Cluster.PinGet":               RPCClosed,
-	"Cluster.PinPath":              RPCClosed,
-	"Cluster.Pins":                 RPCClosed, // Used in stateless tracker, ipfsproxy, restapi
-	"Cluster.Recover":              RPCClosed,
-	"Cluster.RecoverAll":           RPCClosed,
-	"Cluster.RecoverAllLocal":      RPCTrusted,
-	"Cluster.RecoverLocal":         RPCTrusted,
-	"Cluster.RepoGC":               RPCClosed,
-	"Cluster.RepoGCLocal":          RPCTrusted,
-	"Cluster.SendInformerMetric":   RPCClosed,
-	"Cluster.SendInformersMetrics": RPCClosed,
-	"Cluster.Status":               RPCClosed,
-	"Cluster.StatusAll":            RPCClosed,
-	"Cluster.StatusAllLocal":       RPCClosed,
-	"Cluster.StatusLocal":          RPCClosed,
-	"Cluster.Unpin":                RPCClosed,
-	"Cluster.UnpinPath":            RPCClosed,
-	"Cluster.Version":              RPCOpen,
+	"Cluster.Alerts":                       RPCClosed,
+	"Cluster.AllocationExclusions":         RPCClosed,
+	"Cluster.BeginSecretRotation":          RPCClosed,
+	"Cluster.BeginSecretRotationLocal":     RPCTrusted, // Called by BeginSecretRotation()
+	"Cluster.BlockAllocate":                RPCClosed,
+	"Cluster.CancelJob":                    RPCClosed,
+	"Cluster.CancelRecoverSchedule":        RPCClosed,
+	"Cluster.Collection":                   RPCClosed,
+	"Cluster.CollectionStatus":             RPCClosed,
+	"Cluster.Collections":                  RPCClosed,
+	"Cluster.ConnectGraph":                 RPCClosed,
+	"Cluster.FinalizeSecretRotation":       RPCClosed,
+	"Cluster.FinalizeSecretRotationLocal":  RPCTrusted, // Called by FinalizeSecretRotation()
+	"Cluster.ForcePin":                     RPCClosed,
+	"Cluster.ForceUnpin":                   RPCClosed,
+	"Cluster.ID":                           RPCOpen,
+	"Cluster.ImportKnownPeers":             RPCClosed,
+	"Cluster.ImportPeerAddrs":              RPCTrusted, // Called by shareAddrs()
+	"Cluster.Job":                          RPCClosed,
+	"Cluster.Jobs":                         RPCClosed,
+	"Cluster.Join":                         RPCClosed,
+	"Cluster.KnownPeers":                   RPCClosed,
+	"Cluster.PeerActivate":                 RPCClosed,
+	"Cluster.PeerActivateLocal":            RPCTrusted, // Called by PeerActivate()
+	"Cluster.PeerAdd":                      RPCOpen,    // Used by Join()
+	"Cluster.PeerMaintenance":              RPCClosed,
+	"Cluster.PeerMaintenanceLocal":         RPCTrusted, // Called by PeerMaintenance()
+	"Cluster.PeerNames":                    RPCClosed,
+	"Cluster.PeerRemove":                   RPCTrusted,
+	"Cluster.PeerRemoveDryRun":             RPCClosed,
+	"Cluster.Peers":                        RPCTrusted, // Used by ConnectGraph()
+	"Cluster.Pin":                          RPCClosed,
+	"Cluster.PinCollection":                RPCClosed,
+	"Cluster.PinGet":                       RPCClosed,
+	"Cluster.PinPath":                      RPCClosed,
+	"Cluster.Pins":                         RPCClosed, // Used in stateless tracker, ipfsproxy, restapi
+	"Cluster.PinsQuery":                    RPCClosed,
+	"Cluster.PinsView":                     RPCClosed,
+	"Cluster.Prefetch":                     RPCClosed,
+	"Cluster.ProvideStrategy":              RPCClosed,
+	"Cluster.PushConfig":                   RPCClosed,
+	"Cluster.PushConfigLocal":              RPCTrusted, // Called by PushConfig()
+	"Cluster.QuotaUsage":                   RPCClosed,
+	"Cluster.Recover":                      RPCClosed,
+	"Cluster.RecoverAll":                   RPCClosed,
+	"Cluster.RecoverAllLocal":              RPCTrusted,
+	"Cluster.RecoverLocal":                 RPCTrusted,
+	"Cluster.RecoverSchedules":             RPCClosed,
+	"Cluster.RepinProgress":                RPCClosed,
+	"Cluster.RepinProgressLocal":           RPCTrusted, // Called by RepinProgress()
+	"Cluster.RepoGC":                       RPCClosed,
+	"Cluster.RepoGCLocal":                  RPCTrusted,
+	"Cluster.RollingUpgrade":               RPCClosed,
+	"Cluster.RotatePeer":                   RPCTrusted, // Used by "id rotate"
+	"Cluster.RotatePeerLocal":              RPCTrusted, // Called by RotatePeer()
+	"Cluster.ScheduleRecover":              RPCClosed,
+	"Cluster.SendInformerMetric":           RPCClosed,
+	"Cluster.SendInformersMetrics":         RPCClosed,
+	"Cluster.SetAllocationExclusions":      RPCClosed,
+	"Cluster.SetAllocationExclusionsLocal": RPCTrusted, // Called by SetAllocationExclusions()
+	"Cluster.SetLogLevel":                  RPCClosed,
+	"Cluster.SetProvideStrategy":           RPCClosed,
+	"Cluster.SetProvideStrategyLocal":      RPCTrusted, // Called by SetProvideStrategy()
+	"Cluster.ShardsGC":                     RPCClosed,
+	"Cluster.StateCheckpoint":              RPCClosed,
+	"Cluster.StateCheckpointPins":          RPCClosed,
+	"Cluster.StateCheckpoints":             RPCClosed,
+	"Cluster.Stats":                        RPCClosed,
+	"Cluster.StatsLocal":                   RPCTrusted, // Called by Stats()
+	"Cluster.Status":                       RPCClosed,
+	"Cluster.StatusAll":                    RPCClosed,
+	"Cluster.StatusAllLocal":               RPCClosed,
+	"Cluster.StatusChanges":                RPCClosed,
+	"Cluster.StatusLocal":                  RPCClosed,
+	"Cluster.SubmitJob":                    RPCClosed,
+	"Cluster.Time":                         RPCOpen, // Used by diagnostics
+	"Cluster.Unpin":                        RPCClosed,
+	"Cluster.UnpinCollection":              RPCClosed,
+	"Cluster.UnpinPath":                    RPCClosed,
+	"Cluster.Upgrade":                      RPCClosed, // Called by RollingUpgrade(). Opt-in per peer.
+	"Cluster.Version":                      RPCOpen,
 
 	// PinTracker methods
-	"PinTracker.Recover":    RPCTrusted, // Called in broadcast from Recover()
-	"PinTracker.RecoverAll": RPCClosed,  // Broadcast in RecoverAll unimplemented
-	"PinTracker.Status":     RPCTrusted,
-	"PinTracker.StatusAll":  RPCTrusted,
-	"PinTracker.Track":      RPCClosed,
-	"PinTracker.Untrack":    RPCClosed,
+	"PinTracker.Operations":        RPCClosed,
+	"PinTracker.PendingOperations": RPCClosed,
+	"PinTracker.Recover":           RPCTrusted, // Called in broadcast from Recover()
+	"PinTracker.RecoverAll":        RPCClosed,  // Broadcast in RecoverAll unimplemented
+	"PinTracker.Status":            RPCTrusted,
+	"PinTracker.StatusAll":         RPCTrusted,
+	"PinTracker.StatusAllPage":     RPCTrusted,
+	"PinTracker.StatusChanges":     RPCTrusted, // Called in broadcast from StatusChanges()
+	"PinTracker.Track":             RPCClosed,
+	"PinTracker.Untrack":           RPCClosed,
 
 	// IPFSConnector methods
 	"IPFSConnector.BlockGet":   RPCClosed,
@@ -49,6 +108,7 @@ var DefaultRPCPolicy = map[string]RPCEndpointType{
 	"IPFSConnector.Pin":        RPCClosed,
 	"IPFSConnector.PinLs":      RPCClosed,
 	"IPFSConnector.PinLsCid":   RPCClosed,
+	"IPFSConnector.Prefetch":   RPCTrusted, // Called in broadcast from Prefetch()
 	"IPFSConnector.RepoStat":   RPCTrusted, // Called in broadcast from proxy/repo/stat
 	"IPFSConnector.Resolve":    RPCClosed,
 	"IPFSConnector.SwarmPeers": RPCTrusted, // Called in ConnectGraph
@@ -0,0 +1,37 @@
+package ipfscluster
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestRPCPolicy checks that every RPC endpoint has a policy and that the
+// policy does not name endpoints which do not exist. Run rpcutil/policygen
+// to regenerate the policy when it fails.
+func TestRPCPolicy(t *testing.T) {
+	rpcComponents := []interface{}{
+		&ClusterRPCAPI{},
+		&PinTrackerRPCAPI{},
+		&IPFSConnectorRPCAPI{},
+		&ConsensusRPCAPI{},
+		&PeerMonitorRPCAPI{},
+	}
+
+	endpoints := make(map[string]struct{})
+	for _, c := range rpcComponents {
+		typ := reflect.TypeOf(c)
+		for i := 0; i < typ.NumMethod(); i++ {
+			name := RPCServiceID(c) + "." + typ.Method(i).Name
+			endpoints[name] = struct{}{}
+			if _, ok := DefaultRPCPolicy[name]; !ok {
+				t.Errorf("%s is not in DefaultRPCPolicy", name)
+			}
+		}
+	}
+
+	for name := range DefaultRPCPolicy {
+		if _, ok := endpoints[name]; !ok {
+			t.Errorf("DefaultRPCPolicy has an unknown endpoint: %s", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ipfscluster
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-gorpc/stats"
+)
+
+// DefaultRPCTimeouts associates RPC endpoints to the maximum time that a call
+// to them can take. Endpoints not listed here have no timeout other than the
+// one of the context of the caller.
+//
+// Timeouts apply on both sides: the cluster peer making the call stops
+// waiting for the response and the peer serving it cancels the context of
+// the operation, which is also the case for calls made locally by the
+// cluster components.
+var DefaultRPCTimeouts = map[string]time.Duration{
+	// Peer and status queries
+	"Cluster.ID":                 30 * time.Second,
+	"Cluster.ImportPeerAddrs":    30 * time.Second,
+	"Cluster.Peers":              time.Minute,
+	"Cluster.Version":            30 * time.Second,
+	"Cluster.StatusLocal":        2 * time.Minute,
+	"Cluster.StatusAllLocal":     10 * time.Minute,
+	"Cluster.RecoverLocal":       2 * time.Minute,
+	"Cluster.RecoverAllLocal":    10 * time.Minute,
+	"Cluster.RepinProgressLocal": time.Minute,
+	"Cluster.StatsLocal":         2 * time.Minute,
+	"PinTracker.Status":          2 * time.Minute,
+	"PinTracker.StatusAll":       10 * time.Minute,
+	"PinTracker.StatusAllPage":   10 * time.Minute,
+
+	// Pin triggers and peerset changes
+	"PinTracker.Track":   time.Minute,
+	"PinTracker.Untrack": time.Minute,
+	"Consensus.LogPin":   2 * time.Minute,
+	"Consensus.LogUnpin": 2 * time.Minute,
+	"Consensus.AddPeer":  2 * time.Minute,
+	"Consensus.RmPeer":   2 * time.Minute,
+
+	// Metric pushes
+	"Cluster.SendInformerMetric":   time.Minute,
+	"Cluster.SendInformersMetrics": time.Minute,
+	"PeerMonitor.LatestMetrics":    30 * time.Second,
+}
+
+// rpcContext returns the context for a call to the given endpoint, derived
+// from ctx and using the timeout configured for it, if any.
+func (c *Cluster) rpcContext(ctx context.Context, svc, method string) (context.Context, context.CancelFunc) {
+	if t := c.config.RPCTimeouts[svc+"."+method]; t > 0 {
+		return context.WithTimeout(ctx, t)
+	}
+	return context.WithCancel(ctx)
+}
+
+type rpcCancelKey struct{}
+
+// rpcTimeoutHandler is a go-libp2p-gorpc stats handler for the RPC server
+// which sets the configured timeout on the context of every call it serves.
+// It wraps an optional handler, i.e. the tracing one.
+type rpcTimeoutHandler struct {
+	timeouts map[string]time.Duration
+	next     stats.Handler
+}
+
+// TagRPC sets the deadline for the call.
+func (h *rpcTimeoutHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if h.next != nil {
+		ctx = h.next.TagRPC(ctx, info)
+	}
+
+	// FullMethodName is /Service/Method.
+	endpoint := strings.Replace(strings.TrimPrefix(info.FullMethodName, "/"), "/", ".", 1)
+	t := h.timeouts[endpoint]
+	if t <= 0 {
+		return ctx
+	}
+	ctx, cancel := context.WithTimeout(ctx, t)
+	return context.WithValue(ctx, rpcCancelKey{}, cancel)
+}
+
+// HandleRPC releases the deadline of the call once it has ended.
+func (h *rpcTimeoutHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if h.next != nil {
+		h.next.HandleRPC(ctx, s)
+	}
+	if _, ok := s.(*stats.End); !ok {
+		return
+	}
+	if cancel, ok := ctx.Value(rpcCancelKey{}).(context.CancelFunc); ok {
+		cancel()
+	}
+}
@@ -0,0 +1,154 @@
+package rpcutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+// Caller performs single RPC calls. It is implemented by the
+// go-libp2p-gorpc client.
+type Caller interface {
+	CallContext(ctx context.Context, dest peer.ID, svcName, svcMethod string, args, reply interface{}) error
+}
+
+// UnreachableError is returned by Broadcast for the destinations which did
+// not answer before their call timed out.
+type UnreachableError struct {
+	Peer peer.ID
+	Err  error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("peer %s unreachable: %s", e.Peer.Pretty(), e.Err)
+}
+
+// IsUnreachable returns whether the given error is an *UnreachableError.
+func IsUnreachable(err error) bool {
+	_, ok := err.(*UnreachableError)
+	return ok
+}
+
+// BroadcastOptions control how Broadcast fans out the calls.
+type BroadcastOptions struct {
+	// Concurrency limits the number of calls running at the same time.
+	// 0 means no limit.
+	Concurrency int
+	// Timeout limits the duration of every call. 0 means no timeout
+	// other than the one of the parent context.
+	Timeout time.Duration
+	// First lists the destinations to call before any other, when
+	// present.
+	First []peer.ID
+	// Last lists the destinations to call after all the others, when
+	// present, i.e. those which were unreachable recently, so that they
+	// do not hold back the rest when the concurrency is limited.
+	Last []peer.ID
+}
+
+// Broadcast runs call for every destination, with at most
+// opts.Concurrency calls running at once, and returns the error of every
+// call in the order of dests. Every call receives the index of its
+// destination and a context derived from ctx with opts.Timeout. Calls
+// which time out while ctx is not done fail with an *UnreachableError, so
+// that callers can aggregate the results of the peers which answered and
+// mark the rest. Destinations not called because ctx is done get its error.
+func Broadcast(
+	ctx context.Context,
+	dests []peer.ID,
+	opts BroadcastOptions,
+	call func(ctx context.Context, i int) error,
+) []error {
+	errs := make([]error, len(dests))
+	if len(dests) == 0 {
+		return errs
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 || concurrency > len(dests) {
+		concurrency = len(dests)
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+	for _, i := range callOrder(dests, opts.First, opts.Last) {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var callCtx context.Context
+			var cancel context.CancelFunc
+			if opts.Timeout > 0 {
+				callCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			} else {
+				callCtx, cancel = context.WithCancel(ctx)
+			}
+			defer cancel()
+
+			err := call(callCtx, i)
+			if err != nil && ctx.Err() == nil && callCtx.Err() == context.DeadlineExceeded {
+				err = &UnreachableError{Peer: dests[i], Err: err}
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// MultiCall calls the given endpoint on every destination using Broadcast
+// and stores the responses in replies, which must have the length of dests
+// (see the Copy* functions). It is like the MultiCall of the
+// go-libp2p-gorpc client, which runs all the calls at once.
+func MultiCall(
+	ctx context.Context,
+	client Caller,
+	dests []peer.ID,
+	svcName, svcMethod string,
+	args interface{},
+	replies []interface{},
+	opts BroadcastOptions,
+) []error {
+	return Broadcast(ctx, dests, opts, func(ctx context.Context, i int) error {
+		return client.CallContext(ctx, dests[i], svcName, svcMethod, args, replies[i])
+	})
+}
+
+// callOrder returns the indexes of dests with those in first at the start
+// and those in last at the end, keeping the order of dests otherwise.
+func callOrder(dests []peer.ID, first, last []peer.ID) []int {
+	rank := func(p peer.ID) int {
+		for _, f := range first {
+			if f == p {
+				return 0
+			}
+		}
+		for _, l := range last {
+			if l == p {
+				return 2
+			}
+		}
+		return 1
+	}
+
+	order := make([]int, 0, len(dests))
+	for r := 0; r < 3; r++ {
+		for i, p := range dests {
+			if rank(p) == r {
+				order = append(order, i)
+			}
+		}
+	}
+	return order
+}
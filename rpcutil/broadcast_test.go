@@ -0,0 +1,108 @@
+package rpcutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+)
+
+var testPeers = []peer.ID{
+	peer.ID("peer0"),
+	peer.ID("peer1"),
+	peer.ID("peer2"),
+	peer.ID("peer3"),
+}
+
+func TestBroadcastConcurrency(t *testing.T) {
+	var mux sync.Mutex
+	running, maxRunning := 0, 0
+	errs := Broadcast(context.Background(), testPeers, BroadcastOptions{Concurrency: 2}, func(ctx context.Context, i int) error {
+		mux.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mux.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mux.Lock()
+		running--
+		mux.Unlock()
+		return nil
+	})
+	if CheckErrs(errs) != nil {
+		t.Fatal(errs)
+	}
+	if maxRunning != 2 {
+		t.Errorf("expected 2 concurrent calls, got %d", maxRunning)
+	}
+}
+
+func TestBroadcastOrder(t *testing.T) {
+	var mux sync.Mutex
+	var order []peer.ID
+	opts := BroadcastOptions{
+		Concurrency: 1,
+		First:       []peer.ID{testPeers[2]},
+		Last:        []peer.ID{testPeers[0]},
+	}
+	Broadcast(context.Background(), testPeers, opts, func(ctx context.Context, i int) error {
+		mux.Lock()
+		order = append(order, testPeers[i])
+		mux.Unlock()
+		return nil
+	})
+
+	expected := []peer.ID{testPeers[2], testPeers[1], testPeers[3], testPeers[0]}
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("unexpected call order: %v", order)
+		}
+	}
+}
+
+func TestBroadcastUnreachable(t *testing.T) {
+	failure := errors.New("failure")
+	opts := BroadcastOptions{Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	errs := Broadcast(context.Background(), testPeers, opts, func(ctx context.Context, i int) error {
+		switch i {
+		case 1:
+			<-ctx.Done() // dead peer
+			return ctx.Err()
+		case 2:
+			return failure
+		}
+		return nil
+	})
+	if time.Since(start) > time.Second {
+		t.Error("the dead peer should only hold the broadcast for the timeout")
+	}
+
+	if errs[0] != nil || errs[3] != nil {
+		t.Error("the results of the peers which answered should be kept")
+	}
+	if !IsUnreachable(errs[1]) {
+		t.Errorf("expected an unreachable error: %v", errs[1])
+	}
+	if errs[2] != failure {
+		t.Errorf("expected the error of the call: %v", errs[2])
+	}
+}
+
+func TestBroadcastCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := Broadcast(ctx, testPeers, BroadcastOptions{Concurrency: 1}, func(ctx context.Context, i int) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	for i, err := range errs {
+		if err == nil || IsUnreachable(err) {
+			t.Errorf("%d: expected the context error: %v", i, err)
+		}
+	}
+}
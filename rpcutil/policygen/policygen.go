@@ -24,20 +24,36 @@ func rpcTypeStr(t cluster.RPCEndpointType) string {
 }
 
 var comments = map[string]string{
-	"Cluster.PeerAdd":          "Used by Join()",
-	"Cluster.Peers":            "Used by ConnectGraph()",
-	"Cluster.Pins":             "Used in stateless tracker, ipfsproxy, restapi",
-	"PinTracker.Recover":       "Called in broadcast from Recover()",
-	"PinTracker.RecoverAll":    "Broadcast in RecoverAll unimplemented",
-	"Pintracker.Status":        "Called in broadcast from Status()",
-	"Pintracker.StatusAll":     "Called in broadcast from StatusAll()",
-	"IPFSConnector.BlockPut":   "Called from Add()",
-	"IPFSConnector.RepoStat":   "Called in broadcast from proxy/repo/stat",
-	"IPFSConnector.SwarmPeers": "Called in ConnectGraph",
-	"Consensus.AddPeer":        "Called by Raft/redirect to leader",
-	"Consensus.LogPin":         "Called by Raft/redirect to leader",
-	"Consensus.LogUnpin":       "Called by Raft/redirect to leader",
-	"Consensus.RmPeer":         "Called by Raft/redirect to leader",
+	"Cluster.BeginSecretRotationLocal":     "Called by BeginSecretRotation()",
+	"Cluster.FinalizeSecretRotationLocal":  "Called by FinalizeSecretRotation()",
+	"Cluster.ImportPeerAddrs":              "Called by shareAddrs()",
+	"Cluster.PeerActivateLocal":            "Called by PeerActivate()",
+	"Cluster.PeerAdd":                      "Used by Join()",
+	"Cluster.PeerMaintenanceLocal":         "Called by PeerMaintenance()",
+	"Cluster.Peers":                        "Used by ConnectGraph()",
+	"Cluster.Pins":                         "Used in stateless tracker, ipfsproxy, restapi",
+	"Cluster.PushConfigLocal":              "Called by PushConfig()",
+	"Cluster.RepinProgressLocal":           "Called by RepinProgress()",
+	"Cluster.RotatePeer":                   "Used by \"id rotate\"",
+	"Cluster.RotatePeerLocal":              "Called by RotatePeer()",
+	"Cluster.SetAllocationExclusionsLocal": "Called by SetAllocationExclusions()",
+	"Cluster.SetProvideStrategyLocal":      "Called by SetProvideStrategy()",
+	"Cluster.StatsLocal":                   "Called by Stats()",
+	"Cluster.Time":                         "Used by diagnostics",
+	"Cluster.Upgrade":                      "Called by RollingUpgrade(). Opt-in per peer.",
+	"PinTracker.Recover":                   "Called in broadcast from Recover()",
+	"PinTracker.RecoverAll":                "Broadcast in RecoverAll unimplemented",
+	"PinTracker.StatusChanges":             "Called in broadcast from StatusChanges()",
+	"Pintracker.Status":                    "Called in broadcast from Status()",
+	"Pintracker.StatusAll":                 "Called in broadcast from StatusAll()",
+	"IPFSConnector.BlockPut":               "Called from Add()",
+	"IPFSConnector.Prefetch":               "Called in broadcast from Prefetch()",
+	"IPFSConnector.RepoStat":               "Called in broadcast from proxy/repo/stat",
+	"IPFSConnector.SwarmPeers":             "Called in ConnectGraph",
+	"Consensus.AddPeer":                    "Called by Raft/redirect to leader",
+	"Consensus.LogPin":                     "Called by Raft/redirect to leader",
+	"Consensus.LogUnpin":                   "Called by Raft/redirect to leader",
+	"Consensus.RmPeer":                     "Called by Raft/redirect to leader",
 }
 
 func main() {
@@ -114,6 +114,29 @@ func CopyPinInfoSliceToIfaces(in [][]*api.PinInfo) []interface{} {
 	return ifaces
 }
 
+// CopyRepinProgressSliceToIfaces converts an api.RepinProgress slice of
+// slices to an empty interface slice using pointers to each elements of the
+// original slice. Useful to handle gorpc.MultiCall() replies.
+func CopyRepinProgressSliceToIfaces(in [][]*api.RepinProgress) []interface{} {
+	ifaces := make([]interface{}, len(in), len(in))
+	for i := range in {
+		ifaces[i] = &in[i]
+	}
+	return ifaces
+}
+
+// CopyPeerStatsToIfaces converts an api.PeerStats slice to an empty
+// interface slice using pointers to each elements of the original slice.
+// Useful to handle gorpc.MultiCall() replies.
+func CopyPeerStatsToIfaces(in []*api.PeerStats) []interface{} {
+	ifaces := make([]interface{}, len(in), len(in))
+	for i := range in {
+		in[i] = &api.PeerStats{}
+		ifaces[i] = in[i]
+	}
+	return ifaces
+}
+
 // CopyRepoGCSliceToIfaces converts an api.RepoGC slice to
 // an empty interface slice using pointers to each elements of
 // the original slice. Useful to handle gorpc.MultiCall() replies.
@@ -0,0 +1,126 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// leaderTask is a background task which only needs to run in one peer of
+// the cluster at a time, such as a sweep over the whole shared state.
+type leaderTask struct {
+	name     string
+	interval time.Duration
+	// run is only called while this peer is the runner of the task
+	// (see isTaskRunner).
+	run func(ctx context.Context) error
+	// enabled, when set, is checked before every run.
+	enabled func() bool
+}
+
+// leaderTasks returns the background tasks scheduled by runLeaderTasks,
+// including those of the APIs which are LeaderTaskers.
+func (c *Cluster) leaderTasks() []leaderTask {
+	tasks := []leaderTask{
+		{
+			name:     "top_up_replicas",
+			interval: topUpInterval,
+			run:      c.topUpDegradedPins,
+			enabled:  func() bool { return !c.config.DisableRepinning },
+		},
+		{
+			name:     "unpin_expired",
+			interval: c.config.StateSyncInterval,
+			run:      c.unpinExpired,
+		},
+	}
+	for _, api := range c.apis {
+		if lt, ok := api.(LeaderTasker); ok {
+			name, interval, run := lt.LeaderTask()
+			tasks = append(tasks, leaderTask{
+				name:     name,
+				interval: interval,
+				run:      run,
+			})
+		}
+	}
+	return tasks
+}
+
+// runLeaderTasks runs every leader task on its interval until the cluster
+// is shut down. The first runs are staggered so that tasks do not run
+// all at once.
+func (c *Cluster) runLeaderTasks() {
+	for _, t := range c.leaderTasks() {
+		c.wg.Add(1)
+		go func(t leaderTask) {
+			defer c.wg.Done()
+			c.runLeaderTask(t)
+		}(t)
+	}
+}
+
+func (c *Cluster) runLeaderTask(t leaderTask) {
+	timer := time.NewTimer(peerStagger(c.id, t.name, t.interval))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-timer.C:
+			c.runLeaderTaskOnce(c.ctx, t)
+			timer.Reset(t.interval)
+		}
+	}
+}
+
+// runLeaderTaskOnce runs the task if this peer is its runner. It returns
+// whether the task ran.
+func (c *Cluster) runLeaderTaskOnce(ctx context.Context, t leaderTask) bool {
+	ctx, span := trace.StartSpan(ctx, "cluster/leaderTask/"+t.name)
+	defer span.End()
+
+	if t.enabled != nil && !t.enabled() {
+		return false
+	}
+	if !c.isTaskRunner(ctx, t.name) {
+		logger.Debugf("%s: not the runner of this task", t.name)
+		return false
+	}
+	logger.Debugf("%s: running leader task", t.name)
+	if err := t.run(ctx); err != nil {
+		logger.Errorf("%s: %s", t.name, err)
+	}
+	return true
+}
+
+// isTaskRunner returns whether this peer should run the leader task with
+// the given name. With a consensus which has a leader (raft), the leader
+// runs every task. Otherwise (crdt), the trusted peer closest to the task
+// name runs it, so that different tasks may run in different peers.
+// Follower peers never run tasks, as they cannot modify the pinset.
+func (c *Cluster) isTaskRunner(ctx context.Context, name string) bool {
+	if c.config.FollowerMode {
+		return false
+	}
+
+	if leader, err := c.consensus.Leader(ctx); err == nil {
+		return leader == c.id
+	}
+
+	// We cannot know if our peer ID is trusted by the other peers. This
+	// assumes yes. Setting FollowerMode is a way to assume the opposite.
+	trustedPeers, err := c.getTrustedPeers(ctx)
+	if err != nil {
+		return false
+	}
+	checker := distanceChecker{
+		local:      c.id,
+		otherPeers: trustedPeers,
+		cache:      make(map[peer.ID]distance, len(trustedPeers)+1),
+	}
+	return checker.isClosestKey("leader-task/" + name)
+}
@@ -0,0 +1,132 @@
+package ipfscluster
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// SecretGracePeriod specifies for how long peers keep accepting connections
+// using the previous cluster secret once a secret rotation has been
+// finalized. It covers peers which have not finalized the rotation yet.
+var SecretGracePeriod = 5 * time.Minute
+
+var (
+	errNoSecret         = errors.New("this peer does not use a cluster secret")
+	errNoSecretRotation = errors.New("no secret rotation is in progress")
+)
+
+// BeginSecretRotation starts the rotation of the cluster secret to the given
+// one. All peers start accepting connections which use the new secret,
+// while they keep using the current secret to connect to others. Once all
+// peers have begun the rotation, it can be completed with
+// FinalizeSecretRotation.
+func (c *Cluster) BeginSecretRotation(ctx context.Context, secret []byte) error {
+	_, span := trace.StartSpan(ctx, "cluster/BeginSecretRotation")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if len(secret) != 32 {
+		return fmt.Errorf("the cluster secret should be 32 bytes, not %d", len(secret))
+	}
+	return c.broadcastSecretRotation(ctx, "BeginSecretRotationLocal", secret)
+}
+
+// BeginSecretRotationLocal makes this peer accept connections using the
+// given secret, in addition to the current one. The secret is saved to the
+// configuration as transition_secret.
+func (c *Cluster) BeginSecretRotationLocal(ctx context.Context, secret []byte) error {
+	_, span := trace.StartSpan(ctx, "cluster/BeginSecretRotationLocal")
+	defer span.End()
+
+	p := c.config.protector
+	if p == nil {
+		return errNoSecret
+	}
+	if len(secret) != 32 {
+		return fmt.Errorf("the cluster secret should be 32 bytes, not %d", len(secret))
+	}
+
+	c.config.lock.Lock()
+	defer c.config.lock.Unlock()
+	if bytes.Equal(secret, c.config.Secret) {
+		return errors.New("the new secret is the current one")
+	}
+	c.config.TransitionSecret = secret
+	p.setSecrets(c.config.Secret, secret)
+	c.config.NotifySave()
+	logger.Info("secret rotation started: accepting connections using the new secret")
+	return nil
+}
+
+// FinalizeSecretRotation completes a rotation started with
+// BeginSecretRotation. All peers start using the new secret to connect to
+// others. The previous secret is accepted during SecretGracePeriod.
+func (c *Cluster) FinalizeSecretRotation(ctx context.Context) error {
+	_, span := trace.StartSpan(ctx, "cluster/FinalizeSecretRotation")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	return c.broadcastSecretRotation(ctx, "FinalizeSecretRotationLocal", struct{}{})
+}
+
+// FinalizeSecretRotationLocal makes the transition secret the cluster
+// secret of this peer and saves it to the configuration.
+func (c *Cluster) FinalizeSecretRotationLocal(ctx context.Context) error {
+	_, span := trace.StartSpan(ctx, "cluster/FinalizeSecretRotationLocal")
+	defer span.End()
+
+	p := c.config.protector
+	if p == nil {
+		return errNoSecret
+	}
+
+	c.config.lock.Lock()
+	defer c.config.lock.Unlock()
+	if len(c.config.TransitionSecret) == 0 {
+		return errNoSecretRotation
+	}
+	previous := c.config.Secret
+	c.config.Secret = c.config.TransitionSecret
+	c.config.TransitionSecret = nil
+	p.setSecrets(c.config.Secret, previous)
+	c.config.NotifySave()
+
+	time.AfterFunc(SecretGracePeriod, func() {
+		p.dropTransition(previous)
+	})
+	logger.Infof("secret rotation finalized. The previous secret is accepted for %s", SecretGracePeriod)
+	return nil
+}
+
+// broadcastSecretRotation calls the given method on all peers, and returns
+// an error listing the peers where it failed.
+func (c *Cluster) broadcastSecretRotation(ctx context.Context, method string, in interface{}) error {
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+
+	errs := c.multiCall(
+		ctx,
+		peers,
+		"Cluster",
+		method,
+		in,
+		rpcutil.RPCDiscardReplies(len(peers)),
+	)
+
+	for i, err := range errs {
+		if err != nil {
+			errs[i] = fmt.Errorf("%s: %s", peer.IDB58Encode(peers[i]), err)
+		}
+	}
+	return rpcutil.CheckErrs(errs)
+}
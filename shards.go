@@ -0,0 +1,100 @@
+package ipfscluster
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	"go.opencensus.io/trace"
+)
+
+// shardReferences counts, for every ClusterDAG and shard, the number of
+// MetaPins in the pinset which reference it, either directly or through
+// their ClusterDAG. The MetaPin with the given CID is not counted.
+//
+// References are derived from the pinset and the ClusterDAGs on every call
+// rather than stored along the pins, so they are always in sync with the
+// MetaPins, even when these are modified concurrently from several peers.
+func (c *Cluster) shardReferences(ctx context.Context, except cid.Cid) (map[cid.Cid]int, error) {
+	ctx, span := trace.StartSpan(ctx, "cluster/shardReferences")
+	defer span.End()
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[cid.Cid]int)
+	for _, pin := range pins {
+		if pin.Type != api.MetaType || pin.Cid.Equals(except) {
+			continue
+		}
+		cids, err := c.cidsFromMetaPin(ctx, pin.Cid)
+		if err != nil {
+			return nil, fmt.Errorf("cannot obtain the shards of %s: %s", pin.Cid, err)
+		}
+		for _, ci := range cids {
+			if !ci.Equals(pin.Cid) {
+				refs[ci]++
+			}
+		}
+	}
+	return refs, nil
+}
+
+// ShardsGC unpins the ClusterDAGs and shards which are not referenced by
+// any MetaPin in the pinset, as it may happen when unpinning sharded content
+// fails half-way, and returns them. It should not be run while sharded
+// content is being added, as the shards of unfinished additions are not
+// referenced yet.
+func (c *Cluster) ShardsGC(ctx context.Context) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/ShardsGC")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if c.config.FollowerMode {
+		return nil, errFollowerMode
+	}
+
+	if err := c.startWrite(); err != nil {
+		return nil, err
+	}
+	defer c.writesWg.Done()
+
+	refs, err := c.shardReferences(ctx, cid.Undef)
+	if err != nil {
+		return nil, err
+	}
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	unpinned := []*api.Pin{}
+	for _, pin := range pins {
+		if pin.Type != api.ShardType && pin.Type != api.ClusterDAGType {
+			continue
+		}
+		if refs[pin.Cid] > 0 {
+			continue
+		}
+		err := c.consensus.LogUnpin(ctx, pin)
+		if err != nil {
+			return unpinned, err
+		}
+		logger.Infof("unpinned unreferenced %s %s", pin.Type, pin.Cid)
+		unpinned = append(unpinned, pin)
+	}
+	return unpinned, nil
+}
@@ -18,6 +18,15 @@ test_expect_success "cluster-service init without --peers succeeds and creates e
     [ ! -s "test-config/peerstore" ]
 '
 
+test_expect_success "cluster-service peerstore restore recovers the previous peerstore" '
+    PEER1=/ip4/192.168.0.129/tcp/9196/p2p/12D3KooWRN8KRjpyg9rsW2w7StbBRGper65psTZm68cjud9KAkaW
+    PEER2=/ip4/192.168.0.129/tcp/9196/p2p/12D3KooWPwrYNj7VficHw5qYidepMGA85756kYgMdNmRM9A1ZHjN
+    ipfs-cluster-service --config "test-config" peerstore restore --list | grep -q "peerstore.backup-" &&
+    ipfs-cluster-service --config "test-config" peerstore restore &&
+    grep -q $PEER1 test-config/peerstore &&
+    grep -q $PEER2 test-config/peerstore
+'
+
 test_expect_success "cluster-service init with raft generates only raft config" '
     ipfs-cluster-service --config "test-config" init -f --consensus raft &&
     [ "$(jq -M -r .consensus.raft test-config/service.json)" != "null" ] && 
@@ -17,6 +17,13 @@ test_expect_success IPFS,CLUSTER,JQ "state export saves the correct state to exp
     jq -r ".cid | .[\"/\"]" export.json | grep -q "$cid"
 '
 
+test_expect_success IPFS,CLUSTER,JQ "state export --anonymize strips names and allocations" '
+    ipfs-cluster-service --debug --config "test-config" state export --anonymize -f anon.json &&
+    [ -f anon.json ] &&
+    jq -r ".cid | .[\"/\"]" anon.json | grep -q "$cid" &&
+    jq -e ".name == \"\" and (.allocations | length) == 0" anon.json
+'
+
 cluster_kill
 sleep 5
 test_cluster_init "" raft
@@ -0,0 +1,74 @@
+package ipfscluster
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// precheckSize resolves the size of a new pin before it is allocated, when
+// SizePrecheckTimeout is set, and rejects it with an ErrCodeNoSpace error
+// when no peer reports enough free space to store it. Pins whose size
+// cannot be resolved in time are allocated without knowing it.
+func (c *Cluster) precheckSize(ctx context.Context, pin *api.Pin) error {
+	ctx, span := trace.StartSpan(ctx, "cluster/precheckSize")
+	defer span.End()
+
+	if c.config.SizePrecheckTimeout <= 0 || pin.Type != api.DataType {
+		return nil
+	}
+	if _, err := c.PinGet(ctx, pin.Cid); err == nil {
+		return nil
+	}
+
+	if pin.Size == 0 {
+		sizeCtx, cancel := context.WithTimeout(ctx, c.config.SizePrecheckTimeout)
+		size, err := c.ipfs.DagSize(sizeCtx, pin.Cid)
+		cancel()
+		if err != nil {
+			logger.Warningf("cannot obtain the size of %s before allocating it: %s", pin.Cid, err)
+			return nil
+		}
+		pin.Size = size
+	}
+
+	free := c.peersFreeSpace(ctx)
+	if len(free) == 0 {
+		// the disk informer does not report free space.
+		return nil
+	}
+	var largest uint64
+	for _, f := range free {
+		if f > largest {
+			largest = f
+		}
+	}
+	if pin.Size > largest {
+		return api.NewCodedError(
+			api.ErrCodeNoSpace,
+			"%s needs %d bytes but no peer has that much free space (largest: %d bytes)",
+			pin.Cid,
+			pin.Size,
+			largest,
+		)
+	}
+	return nil
+}
+
+// peersFreeSpace returns the free space of the peers, as reported with
+// their last "freespace" metrics.
+func (c *Cluster) peersFreeSpace(ctx context.Context) map[peer.ID]uint64 {
+	free := make(map[peer.ID]uint64)
+	for _, m := range c.monitor.LatestMetrics(ctx, freeSpaceMetricName) {
+		v, err := strconv.ParseUint(m.Value, 10, 64)
+		if err != nil {
+			continue
+		}
+		free[m.Peer] = v
+	}
+	return free
+}
@@ -0,0 +1,98 @@
+package ipfscluster
+
+import (
+	"context"
+
+	ds "github.com/ipfs/go-datastore"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// standbyDatastore is the name of the local datastore where the activation
+// of a peer configured in standby mode is persisted.
+const standbyDatastore = "standby"
+
+var activatedKey = ds.NewKey("activated")
+
+// PeerActivate takes the given peer out of standby mode (see
+// PeerActivateLocal). When no peer is given, it applies to this peer.
+func (c *Cluster) PeerActivate(ctx context.Context, pid peer.ID) error {
+	_, span := trace.StartSpan(ctx, "cluster/PeerActivate")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if pid == "" || pid == c.id {
+		return c.PeerActivateLocal(ctx)
+	}
+
+	return c.rpcClient.CallContext(
+		ctx,
+		pid,
+		"Cluster",
+		"PeerActivateLocal",
+		struct{}{},
+		&struct{}{},
+	)
+}
+
+// PeerActivateLocal takes this peer out of standby mode, so that it starts
+// receiving allocations right away. The activation is persisted: the peer
+// does not go back to standby when restarted. It does nothing if the peer
+// is not in standby.
+func (c *Cluster) PeerActivateLocal(ctx context.Context) error {
+	_, span := trace.StartSpan(ctx, "cluster/PeerActivateLocal")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	if !c.inStandby() {
+		logger.Info("peer activation requested, but this peer is not in standby")
+		return nil
+	}
+
+	err := c.localDatastore(standbyDatastore).Put(activatedKey, []byte("true"))
+	if err != nil {
+		return err
+	}
+
+	c.standbyMux.Lock()
+	c.standby = false
+	c.standbyMux.Unlock()
+
+	logger.Info("standby mode disabled: this peer will receive allocations")
+
+	// Let other peers know right away instead of waiting for the next
+	// metrics.
+	if _, err := c.sendPingMetric(ctx); err != nil {
+		logger.Errorf("error publishing metrics: %s", err)
+	}
+	if _, err := c.sendInformersMetrics(ctx); err != nil {
+		logger.Errorf("error publishing metrics: %s", err)
+	}
+	return nil
+}
+
+// inStandby returns whether this peer is in standby mode.
+func (c *Cluster) inStandby() bool {
+	c.standbyMux.RLock()
+	defer c.standbyMux.RUnlock()
+	return c.standby
+}
+
+// loadStandby sets the standby mode when configured, unless the peer was
+// activated before being restarted.
+func (c *Cluster) loadStandby() error {
+	if !c.config.Standby {
+		return nil
+	}
+	activated, err := c.localDatastore(standbyDatastore).Has(activatedKey)
+	if err != nil {
+		return err
+	}
+	if !activated {
+		logger.Warning("this peer is in standby mode and will not receive allocations until activated")
+	}
+	c.standbyMux.Lock()
+	c.standby = !activated
+	c.standbyMux.Unlock()
+	return nil
+}
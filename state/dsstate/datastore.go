@@ -163,6 +163,51 @@ func (st *State) List(ctx context.Context) ([]*api.Pin, error) {
 	return pins, nil
 }
 
+// ListChan sends all the Pins in the datastore on the given channel as they
+// are read from the datastore query, and closes it when done or when the
+// context is cancelled.
+func (st *State) ListChan(ctx context.Context, out chan<- *api.Pin) error {
+	_, span := trace.StartSpan(ctx, "state/dsstate/ListChan")
+	defer span.End()
+	defer close(out)
+
+	q := query.Query{
+		Prefix: st.namespace.String(),
+	}
+
+	results, err := st.dsRead.Query(q)
+	if err != nil {
+		return err
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			logger.Errorf("error in query result: %s", r.Error)
+			return r.Error
+		}
+		k := ds.NewKey(r.Key)
+		ci, err := st.unkey(k)
+		if err != nil {
+			logger.Warning("bad key (ignoring). key: ", k, "error: ", err)
+			continue
+		}
+
+		p, err := st.deserializePin(ci, r.Value)
+		if err != nil {
+			logger.Errorf("error deserializing pin (%s): %s", r.Key, err)
+			continue
+		}
+
+		select {
+		case out <- p:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
 // Migrate migrates an older state version to the current one.
 // This is a no-op for now.
 func (st *State) Migrate(ctx context.Context, r io.Reader) error {
@@ -108,6 +108,36 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestListChan(t *testing.T) {
+	ctx := context.Background()
+	st := newState(t)
+	st.Add(ctx, c)
+
+	out := make(chan *api.Pin)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- st.ListChan(ctx, out)
+	}()
+
+	var list []*api.Pin
+	for p := range out {
+		list = append(list, p)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || !list[0].Cid.Equals(c.Cid) {
+		t.Error("returned something different")
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	err := st.ListChan(cctx, make(chan *api.Pin))
+	if err == nil {
+		t.Error("expected an error with a cancelled context")
+	}
+}
+
 func TestMarshalUnmarshal(t *testing.T) {
 	ctx := context.Background()
 	st := newState(t)
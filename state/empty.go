@@ -14,6 +14,11 @@ func (e *empty) List(ctx context.Context) ([]*api.Pin, error) {
 	return []*api.Pin{}, nil
 }
 
+func (e *empty) ListChan(ctx context.Context, out chan<- *api.Pin) error {
+	close(out)
+	return nil
+}
+
 func (e *empty) Has(ctx context.Context, c cid.Cid) (bool, error) {
 	return false, nil
 }
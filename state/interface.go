@@ -5,7 +5,6 @@ package state
 // State represents the shared state of the cluster
 import (
 	"context"
-	"errors"
 	"io"
 
 	"github.com/ipfs/ipfs-cluster/api"
@@ -14,7 +13,7 @@ import (
 )
 
 // ErrNotFound should be returned when a pin is not part of the state.
-var ErrNotFound = errors.New("pin is not part of the pinset")
+var ErrNotFound = api.NewCodedError(api.ErrCodeNotFound, "pin is not part of the pinset")
 
 // State is a wrapper to the Cluster shared state so that Pin objects can
 // be easily read, written and queried. The state can be marshaled and
@@ -35,6 +34,10 @@ type State interface {
 type ReadOnly interface {
 	// List lists all the pins in the state.
 	List(context.Context) ([]*api.Pin, error)
+	// ListChan sends all the pins in the state on the given channel, as
+	// they are read, and closes it when done. Unlike List, it does not
+	// keep the whole pinset in memory.
+	ListChan(context.Context, chan<- *api.Pin) error
 	// Has returns true if the state is holding information for a Cid.
 	Has(context.Context, cid.Cid) (bool, error)
 	// Get returns the information attacthed to this pin, if any. If the
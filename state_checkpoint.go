@@ -0,0 +1,157 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// StateCheckpointMetaKey is the metadata key which marks the pins of state
+// checkpoints. Its value is the number of pins in the checkpoint.
+const StateCheckpointMetaKey = "state-checkpoint"
+
+// stateCheckpointVersion is the version of the format of the state
+// checkpoint DAG.
+const stateCheckpointVersion = 1
+
+var errNotCheckpoint = errors.New("the cid is not a state checkpoint")
+
+// StateCheckpoint writes the shared state as a DAG to IPFS and pins its
+// root in the cluster with replication factor -1, so that every peer keeps
+// a copy. The pin, which records the checkpoint, is returned. It is marked
+// with the StateCheckpointMetaKey metadata key.
+//
+// The DAG is made of dag-cbor nodes. The root lists the number of pins and
+// the creation time and links to leaves which list the pins, serialized as
+// in the state. Checkpoints can be read back with StateCheckpointPins.
+func (c *Cluster) StateCheckpoint(ctx context.Context) (*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/StateCheckpoint")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]interface{}, 0, len(pins))
+	for _, pin := range pins {
+		b, err := pin.ProtoMarshal()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, b)
+	}
+	now := time.Now().UTC()
+	nodes, err := makePinsDAG(
+		map[string]interface{}{
+			"version": stateCheckpointVersion,
+			"created": now.Format(time.RFC3339),
+		},
+		entries,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		err := c.ipfs.BlockPut(ctx, &api.NodeWithMeta{
+			Cid:  n.Cid(),
+			Data: n.RawData(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	root := nodes[0].Cid()
+	pin := api.PinWithOpts(root, api.PinOptions{
+		ReplicationFactorMin: -1,
+		ReplicationFactorMax: -1,
+		Name:                 "state-checkpoint-" + now.Format("20060102T150405Z"),
+		Metadata: map[string]string{
+			StateCheckpointMetaKey: strconv.Itoa(len(pins)),
+		},
+	})
+	result, _, err := c.pin(ctx, pin, []peer.ID{}, false)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("state checkpoint with %d pins created: %s", len(pins), root)
+	return result, nil
+}
+
+// StateCheckpoints returns the pins of the state checkpoints, sorted by
+// name, which includes the creation time.
+func (c *Cluster) StateCheckpoints(ctx context.Context) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/StateCheckpoints")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	cState, err := c.consensus.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := cState.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints := []*api.Pin{}
+	for _, pin := range pins {
+		if _, ok := pin.Metadata[StateCheckpointMetaKey]; ok {
+			checkpoints = append(checkpoints, pin)
+		}
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Name < checkpoints[j].Name
+	})
+	return checkpoints, nil
+}
+
+// StateCheckpointPins returns the pins in the state checkpoint with the
+// given root. The blocks of the checkpoint are fetched from IPFS.
+func (c *Cluster) StateCheckpointPins(ctx context.Context, root cid.Cid) ([]*api.Pin, error) {
+	_, span := trace.StartSpan(ctx, "cluster/StateCheckpointPins")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	rootNode, err := decodePinsDAGNode(ctx, root, c.ipfs.BlockGet)
+	if err != nil {
+		return nil, err
+	}
+	version, _, err := rootNode.Resolve([]string{"version"})
+	if err != nil || fmt.Sprint(version) != strconv.Itoa(stateCheckpointVersion) {
+		return nil, errNotCheckpoint
+	}
+
+	entries, err := readPinsDAG(ctx, root, c.ipfs.BlockGet)
+	if err != nil {
+		return nil, err
+	}
+	pins := make([]*api.Pin, 0, len(entries))
+	for _, e := range entries {
+		b, ok := e.([]byte)
+		if !ok {
+			return nil, errNotCheckpoint
+		}
+		pin := &api.Pin{}
+		if err := pin.ProtoUnmarshal(b); err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
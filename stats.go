@@ -0,0 +1,106 @@
+package ipfscluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/rpcutil"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// Stats returns a compact summary of the health of the cluster: the size of
+// the shared state, the consensus peerset and leader, and the stats of
+// every peer (see StatsLocal) along with their free space. Peers which
+// cannot be contacted are included with an Error.
+func (c *Cluster) Stats(ctx context.Context) (*api.ClusterStats, error) {
+	_, span := trace.StartSpan(ctx, "cluster/Stats")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	stats := &api.ClusterStats{
+		Peer: c.id,
+		Time: time.Now(),
+	}
+	stats.Leader, _ = c.consensus.Leader(ctx)
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		stats.ConsensusError = err.Error()
+		peers = []peer.ID{c.id}
+	} else {
+		stats.ConsensusPeers = len(peers)
+	}
+
+	if stats.ConsensusError == "" {
+		cState, err := c.consensus.State(ctx)
+		if err == nil {
+			var pins []*api.Pin
+			pins, err = cState.List(ctx)
+			stats.Pins = len(pins)
+		}
+		if err != nil {
+			stats.ConsensusError = err.Error()
+		}
+	}
+
+	replies := make([]*api.PeerStats, len(peers))
+	errs := c.multiCall(
+		ctx,
+		peers,
+		"Cluster",
+		"StatsLocal",
+		struct{}{},
+		rpcutil.CopyPeerStatsToIfaces(replies),
+	)
+
+	free := c.peersFreeSpace(ctx)
+	names := c.PeerNames(ctx)
+	for i, p := range peers {
+		ps := replies[i]
+		if errs[i] != nil {
+			ps = &api.PeerStats{
+				Peer:  p,
+				Error: errs[i].Error(),
+			}
+		}
+		ps.Peer = p
+		ps.FreeSpace = free[p]
+		if ps.Peername == "" {
+			ps.Peername = names[peer.IDB58Encode(p)]
+		}
+		stats.Peers = append(stats.Peers, ps)
+	}
+	return stats, nil
+}
+
+// StatsLocal returns the stats of the pins tracked by this peer, the
+// length of its operation queue and the consensus leader it sees.
+func (c *Cluster) StatsLocal(ctx context.Context) *api.PeerStats {
+	_, span := trace.StartSpan(ctx, "cluster/StatsLocal")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	ps := &api.PeerStats{
+		Peer:       c.id,
+		Peername:   c.config.Peername,
+		QueueDepth: c.tracker.PendingOperations(ctx),
+	}
+	ps.Leader, _ = c.consensus.Leader(ctx)
+
+	for _, pinfo := range c.tracker.StatusAll(ctx) {
+		switch {
+		case pinfo.Status == api.TrackerStatusPinned:
+			ps.Pinned++
+		case pinfo.Status == api.TrackerStatusPinning:
+			ps.Pinning++
+		case pinfo.Status.Match(api.TrackerStatusQueued):
+			ps.Queued++
+		case pinfo.Status.Match(api.TrackerStatusError):
+			ps.Errors++
+		}
+	}
+	return ps
+}
@@ -37,6 +37,9 @@ var (
 	PeerName5 = "TestPeer5"
 	PeerName6 = "TestPeer6"
 
+	// Namespace1 is the namespace of the Cid3 pin in the mock RPC API.
+	Namespace1 = "team-a"
+
 	PathIPFS1 = "/ipfs/QmaNJ5acV31sx8jq626qTpAWW4DXKw34aGhx53dECLvXbY"
 	PathIPFS2 = "/ipfs/QmbUNM297ZwxB8CfFAznK7H9YMesDoY6Tt5bPgt5MSCB2u/im.gif"
 	PathIPFS3 = "/ipfs/QmbUNM297ZwxB8CfFAznK7H9YMesDoY6Tt5bPgt5MSCB2u/im.gif/"
@@ -0,0 +1,216 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	gopath "github.com/ipfs/go-path"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// MockConnector is an in-memory implementation of the IPFSConnector
+// component. Pins and blocks are kept in maps and nothing is sent to an
+// IPFS daemon. Operations on ErrorCid fail with ErrBadCid.
+type MockConnector struct {
+	id peer.ID
+
+	mu     sync.RWMutex
+	pins   map[cid.Cid]api.IPFSPinStatus
+	blocks map[cid.Cid][]byte
+}
+
+// NewMockConnector returns an empty MockConnector which reports the
+// given peer as the IPFS daemon ID.
+func NewMockConnector(id peer.ID) *MockConnector {
+	return &MockConnector{
+		id:     id,
+		pins:   make(map[cid.Cid]api.IPFSPinStatus),
+		blocks: make(map[cid.Cid][]byte),
+	}
+}
+
+// SetClient does nothing.
+func (ipfs *MockConnector) SetClient(c *rpc.Client) {}
+
+// Shutdown does nothing.
+func (ipfs *MockConnector) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// ID returns the configured IPFS peer ID.
+func (ipfs *MockConnector) ID(ctx context.Context) (*api.IPFSID, error) {
+	return &api.IPFSID{
+		ID: ipfs.id,
+	}, nil
+}
+
+// Pin records the pin as direct or recursive according to its MaxDepth.
+func (ipfs *MockConnector) Pin(ctx context.Context, pin *api.Pin) error {
+	if pin.Cid.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+
+	st := api.IPFSPinStatusRecursive
+	if pin.MaxDepth == 0 {
+		st = api.IPFSPinStatusDirect
+	}
+
+	ipfs.mu.Lock()
+	defer ipfs.mu.Unlock()
+	ipfs.pins[pin.Cid] = st
+	return nil
+}
+
+// Unpin forgets a pin.
+func (ipfs *MockConnector) Unpin(ctx context.Context, c cid.Cid) error {
+	if c.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+
+	ipfs.mu.Lock()
+	defer ipfs.mu.Unlock()
+	delete(ipfs.pins, c)
+	return nil
+}
+
+// PinLsCid returns the pin status of a Cid.
+func (ipfs *MockConnector) PinLsCid(ctx context.Context, c cid.Cid) (api.IPFSPinStatus, error) {
+	if c.Equals(ErrorCid) {
+		return api.IPFSPinStatusError, ErrBadCid
+	}
+
+	ipfs.mu.RLock()
+	defer ipfs.mu.RUnlock()
+	st, ok := ipfs.pins[c]
+	if !ok {
+		return api.IPFSPinStatusUnpinned, nil
+	}
+	return st, nil
+}
+
+// PinLs returns all pins matching the given type filter.
+func (ipfs *MockConnector) PinLs(ctx context.Context, typeFilter string) (map[string]api.IPFSPinStatus, error) {
+	filter := api.IPFSPinStatusFromString(typeFilter)
+
+	ipfs.mu.RLock()
+	defer ipfs.mu.RUnlock()
+	m := make(map[string]api.IPFSPinStatus)
+	for c, st := range ipfs.pins {
+		if typeFilter == "" || typeFilter == "all" || st == filter {
+			m[c.String()] = st
+		}
+	}
+	return m, nil
+}
+
+// ConnectSwarms does nothing.
+func (ipfs *MockConnector) ConnectSwarms(ctx context.Context) error {
+	return nil
+}
+
+// SwarmPeers returns no peers.
+func (ipfs *MockConnector) SwarmPeers(ctx context.Context) ([]peer.ID, error) {
+	return []peer.ID{}, nil
+}
+
+// ConfigKey always returns nil.
+func (ipfs *MockConnector) ConfigKey(keypath string) (interface{}, error) {
+	return nil, nil
+}
+
+// RepoStat returns the total size of the stored blocks.
+func (ipfs *MockConnector) RepoStat(ctx context.Context) (*api.IPFSRepoStat, error) {
+	ipfs.mu.RLock()
+	defer ipfs.mu.RUnlock()
+	var size uint64
+	for _, b := range ipfs.blocks {
+		size += uint64(len(b))
+	}
+	return &api.IPFSRepoStat{RepoSize: size, StorageMax: 1 << 30}, nil
+}
+
+// DagSize returns the size of the block for the given Cid, if stored.
+func (ipfs *MockConnector) DagSize(ctx context.Context, c cid.Cid) (uint64, error) {
+	ipfs.mu.RLock()
+	defer ipfs.mu.RUnlock()
+	return uint64(len(ipfs.blocks[c])), nil
+}
+
+// RepoGC removes all blocks which are not pinned and returns their keys.
+func (ipfs *MockConnector) RepoGC(ctx context.Context) (*api.RepoGC, error) {
+	ipfs.mu.Lock()
+	defer ipfs.mu.Unlock()
+	gc := &api.RepoGC{Peer: ipfs.id}
+	for c := range ipfs.blocks {
+		if _, ok := ipfs.pins[c]; ok {
+			continue
+		}
+		delete(ipfs.blocks, c)
+		gc.Keys = append(gc.Keys, api.IPFSRepoGC{Key: c})
+	}
+	return gc, nil
+}
+
+// Prefetch does nothing, as there is nowhere to fetch blocks from. It
+// returns ErrBadCid for ErrorCid.
+func (ipfs *MockConnector) Prefetch(ctx context.Context, c cid.Cid) error {
+	if c.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	return nil
+}
+
+// NamePublish returns the key name as the IPNS name. It returns ErrBadCid
+// for ErrorCid.
+func (ipfs *MockConnector) NamePublish(ctx context.Context, pub *api.IPNSPublish) (string, error) {
+	if pub.Cid.Equals(ErrorCid) {
+		return "", ErrBadCid
+	}
+	return pub.Key, nil
+}
+
+// SetReprovider does nothing.
+func (ipfs *MockConnector) SetReprovider(ctx context.Context, rp *api.IPFSReprovider) error {
+	return nil
+}
+
+// Resolve parses an IPFS path and returns its root Cid. Paths with
+// further segments resolve to CidResolved.
+func (ipfs *MockConnector) Resolve(ctx context.Context, path string) (cid.Cid, error) {
+	p, err := gopath.ParsePath(path)
+	if err != nil {
+		return cid.Undef, err
+	}
+	c, rest, err := gopath.SplitAbsPath(p)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if len(rest) > 0 {
+		return CidResolved, nil
+	}
+	return c, nil
+}
+
+// BlockPut stores a block.
+func (ipfs *MockConnector) BlockPut(ctx context.Context, nwm *api.NodeWithMeta) error {
+	ipfs.mu.Lock()
+	defer ipfs.mu.Unlock()
+	ipfs.blocks[nwm.Cid] = nwm.Data
+	return nil
+}
+
+// BlockGet returns a stored block.
+func (ipfs *MockConnector) BlockGet(ctx context.Context, c cid.Cid) ([]byte, error) {
+	ipfs.mu.RLock()
+	defer ipfs.mu.RUnlock()
+	b, ok := ipfs.blocks[c]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+	return b, nil
+}
@@ -0,0 +1,192 @@
+package test
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/datastore/inmem"
+	"github.com/ipfs/ipfs-cluster/state"
+	"github.com/ipfs/ipfs-cluster/state/dsstate"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// MockConsensus is an in-memory, single-peer implementation of the
+// Consensus component. Pins are applied to a state backed by an in-memory
+// datastore and, like the real consensus components, handed to the
+// PinTracker over RPC once applied.
+type MockConsensus struct {
+	self      peer.ID
+	rpcClient *rpc.Client
+
+	mu      sync.RWMutex
+	state   state.State
+	peers   []peer.ID
+	trusted map[peer.ID]struct{}
+	ready   chan struct{}
+}
+
+// NewMockConsensus returns a MockConsensus for the given peer, which is
+// always the leader and the only member of the peerset until other
+// peers are added.
+func NewMockConsensus(self peer.ID) *MockConsensus {
+	ready := make(chan struct{})
+	close(ready)
+	return &MockConsensus{
+		self:    self,
+		state:   newInmemState(),
+		peers:   []peer.ID{self},
+		trusted: make(map[peer.ID]struct{}),
+		ready:   ready,
+	}
+}
+
+func newInmemState() state.State {
+	st, err := dsstate.New(inmem.New(), "", dsstate.DefaultHandle())
+	if err != nil {
+		panic(err)
+	}
+	return st
+}
+
+// SetClient sets the RPC client used to notify the PinTracker.
+func (cons *MockConsensus) SetClient(c *rpc.Client) {
+	cons.mu.Lock()
+	defer cons.mu.Unlock()
+	cons.rpcClient = c
+}
+
+// Shutdown does nothing.
+func (cons *MockConsensus) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Ready returns a closed channel: the mock consensus is ready right away.
+func (cons *MockConsensus) Ready(ctx context.Context) <-chan struct{} {
+	return cons.ready
+}
+
+// LogPin adds the pin to the state and asks the PinTracker to track it.
+func (cons *MockConsensus) LogPin(ctx context.Context, pin *api.Pin) error {
+	cons.mu.Lock()
+	err := cons.state.Add(ctx, pin)
+	client := cons.rpcClient
+	cons.mu.Unlock()
+	if err != nil || client == nil {
+		return err
+	}
+	return client.CallContext(ctx, "", "PinTracker", "Track", pin, &struct{}{})
+}
+
+// LogUnpin removes the pin from the state and asks the PinTracker to
+// untrack it.
+func (cons *MockConsensus) LogUnpin(ctx context.Context, pin *api.Pin) error {
+	cons.mu.Lock()
+	err := cons.state.Rm(ctx, pin.Cid)
+	client := cons.rpcClient
+	cons.mu.Unlock()
+	if err != nil || client == nil {
+		return err
+	}
+	return client.CallContext(ctx, "", "PinTracker", "Untrack", pin, &struct{}{})
+}
+
+// AddPeer adds a peer to the peerset.
+func (cons *MockConsensus) AddPeer(ctx context.Context, p peer.ID) error {
+	cons.mu.Lock()
+	defer cons.mu.Unlock()
+	for _, pid := range cons.peers {
+		if pid == p {
+			return nil
+		}
+	}
+	cons.peers = append(cons.peers, p)
+	return nil
+}
+
+// RmPeer removes a peer from the peerset.
+func (cons *MockConsensus) RmPeer(ctx context.Context, p peer.ID) error {
+	cons.mu.Lock()
+	defer cons.mu.Unlock()
+	for i, pid := range cons.peers {
+		if pid == p {
+			cons.peers = append(cons.peers[:i], cons.peers[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// State returns the current state.
+func (cons *MockConsensus) State(ctx context.Context) (state.ReadOnly, error) {
+	cons.mu.RLock()
+	defer cons.mu.RUnlock()
+	return cons.state, nil
+}
+
+// Leader returns the peer given to NewMockConsensus.
+func (cons *MockConsensus) Leader(ctx context.Context) (peer.ID, error) {
+	return cons.self, nil
+}
+
+// WaitForSync returns immediately, as updates are applied synchronously.
+func (cons *MockConsensus) WaitForSync(ctx context.Context) error {
+	return nil
+}
+
+// Clean replaces the state with an empty one.
+func (cons *MockConsensus) Clean(ctx context.Context) error {
+	cons.mu.Lock()
+	defer cons.mu.Unlock()
+	cons.state = newInmemState()
+	return nil
+}
+
+// Peers returns the current peerset.
+func (cons *MockConsensus) Peers(ctx context.Context) ([]peer.ID, error) {
+	cons.mu.RLock()
+	defer cons.mu.RUnlock()
+	peers := make([]peer.ID, len(cons.peers))
+	copy(peers, cons.peers)
+	return peers, nil
+}
+
+// IsTrustedPeer returns true for the local peer and for any peer marked
+// with Trust.
+func (cons *MockConsensus) IsTrustedPeer(ctx context.Context, p peer.ID) bool {
+	if p == cons.self {
+		return true
+	}
+	cons.mu.RLock()
+	defer cons.mu.RUnlock()
+	_, ok := cons.trusted[p]
+	return ok
+}
+
+// Trust marks a peer as trusted.
+func (cons *MockConsensus) Trust(ctx context.Context, p peer.ID) error {
+	cons.mu.Lock()
+	defer cons.mu.Unlock()
+	cons.trusted[p] = struct{}{}
+	return nil
+}
+
+// Distrust removes a peer from the trusted set.
+func (cons *MockConsensus) Distrust(ctx context.Context, p peer.ID) error {
+	cons.mu.Lock()
+	defer cons.mu.Unlock()
+	delete(cons.trusted, p)
+	return nil
+}
+
+// Has is a shortcut to check whether the state contains a pin for the
+// given Cid.
+func (cons *MockConsensus) Has(ctx context.Context, c cid.Cid) bool {
+	cons.mu.RLock()
+	defer cons.mu.RUnlock()
+	ok, _ := cons.state.Has(ctx, c)
+	return ok
+}
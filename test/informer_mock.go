@@ -0,0 +1,64 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// MockInformer is an Informer which produces metrics with a fixed name
+// and a value which can be changed with SetValue.
+type MockInformer struct {
+	name string
+	ttl  time.Duration
+
+	mu    sync.RWMutex
+	value string
+}
+
+// NewMockInformer returns a MockInformer producing metrics with the given
+// name, value and TTL.
+func NewMockInformer(name, value string, ttl time.Duration) *MockInformer {
+	return &MockInformer{
+		name:  name,
+		value: value,
+		ttl:   ttl,
+	}
+}
+
+// SetClient does nothing.
+func (inf *MockInformer) SetClient(c *rpc.Client) {}
+
+// Shutdown does nothing.
+func (inf *MockInformer) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Name returns the metric name.
+func (inf *MockInformer) Name() string {
+	return inf.name
+}
+
+// SetValue changes the value of the metrics returned from now on.
+func (inf *MockInformer) SetValue(value string) {
+	inf.mu.Lock()
+	defer inf.mu.Unlock()
+	inf.value = value
+}
+
+// GetMetric returns a valid metric with the current value.
+func (inf *MockInformer) GetMetric(ctx context.Context) *api.Metric {
+	inf.mu.RLock()
+	defer inf.mu.RUnlock()
+	m := &api.Metric{
+		Name:  inf.name,
+		Value: inf.value,
+		Valid: true,
+	}
+	m.SetTTL(inf.ttl)
+	return m
+}
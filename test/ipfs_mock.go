@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,6 +32,7 @@ const (
 	IpfsCustomHeaderValue = "42"
 	IpfsACAOrigin         = "myorigin"
 	IpfsErrFromNotPinned  = "'from' cid was not recursively pinned already"
+	IpfsAgentVersion      = "go-ipfs/0.4.23/mock"
 )
 
 // IpfsMock is an ipfs daemon mock which should sustain the functionality used by ipfscluster.
@@ -43,6 +45,21 @@ type IpfsMock struct {
 	reqCounts  map[string]int
 	reqCounter chan string
 
+	// mfs maps MFS paths to the CIDs copied to them. Directories map to
+	// an empty string.
+	mfsMux sync.Mutex
+	mfs    map[string]string
+
+	// keys maps IPNS key names to their IDs and ipns maps those IDs
+	// to the last published path.
+	ipnsMux sync.Mutex
+	keys    map[string]string
+	ipns    map[string]string
+
+	// config holds the configuration options set with "config".
+	configMux sync.Mutex
+	config    map[string]string
+
 	closeMux sync.Mutex
 	closed   bool
 }
@@ -66,8 +83,9 @@ type ipfsErr struct {
 }
 
 type mockIDResp struct {
-	ID        string
-	Addresses []string
+	ID           string
+	Addresses    []string
+	AgentVersion string
 }
 
 type mockRepoStatResp struct {
@@ -105,6 +123,39 @@ type mockBlockPutResp struct {
 	Key string
 }
 
+type mockObjectStatResp struct {
+	CumulativeSize uint64
+}
+
+type mockBlockStatResp struct {
+	Key  string
+	Size int
+}
+
+type mockFilesEntry struct {
+	Name string
+	Type int
+	Hash string
+}
+
+type mockFilesLsResp struct {
+	Entries []mockFilesEntry
+}
+
+type mockKey struct {
+	Name string
+	ID   string `json:"Id"`
+}
+
+type mockKeyListResp struct {
+	Keys []mockKey
+}
+
+type mockNamePublishResp struct {
+	Name  string
+	Value string
+}
+
 type mockRepoGCResp struct {
 	Key   cid.Cid `json:",omitempty"`
 	Error string  `json:",omitempty"`
@@ -123,6 +174,10 @@ func NewIpfsMock(t *testing.T) *IpfsMock {
 		BlockStore: make(map[string][]byte),
 		reqCounts:  make(map[string]int),
 		reqCounter: make(chan string, 100),
+		mfs:        map[string]string{"/": ""},
+		keys:       map[string]string{"self": PeerID1.Pretty()},
+		ipns:       make(map[string]string),
+		config:     make(map[string]string),
 	}
 
 	go m.countRequests()
@@ -163,6 +218,86 @@ func (m *IpfsMock) GetCount(path string) int {
 	return m.reqCounts[path]
 }
 
+// MFSEntry returns the CID copied to the given MFS path, if any.
+func (m *IpfsMock) MFSEntry(p string) (string, bool) {
+	m.mfsMux.Lock()
+	defer m.mfsMux.Unlock()
+	c, ok := m.mfs[p]
+	return c, ok && c != ""
+}
+
+// mfsHandler handles the files/* endpoints. It returns false when the
+// request fails.
+func (m *IpfsMock) mfsHandler(w http.ResponseWriter, r *http.Request, endp string) bool {
+	m.mfsMux.Lock()
+	defer m.mfsMux.Unlock()
+
+	args := r.URL.Query()["arg"]
+	if len(args) == 0 {
+		return false
+	}
+	notExist := func() bool {
+		w.WriteHeader(http.StatusInternalServerError)
+		j, _ := json.Marshal(ipfsErr{0, "file does not exist"})
+		w.Write(j)
+		return true
+	}
+
+	switch endp {
+	case "files/mkdir":
+		for p := path.Clean(args[0]); p != "/"; p = path.Dir(p) {
+			m.mfs[p] = ""
+		}
+	case "files/cp":
+		if len(args) != 2 {
+			return false
+		}
+		if _, ok := m.mfs[path.Dir(args[1])]; !ok {
+			return notExist()
+		}
+		if _, ok := m.mfs[args[1]]; ok {
+			return false
+		}
+		m.mfs[args[1]] = strings.TrimPrefix(args[0], "/ipfs/")
+	case "files/stat":
+		c, ok := m.mfs[args[0]]
+		if !ok {
+			return notExist()
+		}
+		j, _ := json.Marshal(struct{ Hash string }{c})
+		w.Write(j)
+	case "files/ls":
+		if _, ok := m.mfs[args[0]]; !ok {
+			return notExist()
+		}
+		resp := mockFilesLsResp{Entries: []mockFilesEntry{}}
+		for p, c := range m.mfs {
+			if p == "/" || path.Dir(p) != args[0] {
+				continue
+			}
+			e := mockFilesEntry{Name: path.Base(p), Hash: c}
+			if c == "" {
+				e.Type = 1
+			}
+			resp.Entries = append(resp.Entries, e)
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "files/rm":
+		if _, ok := m.mfs[args[0]]; !ok {
+			return notExist()
+		}
+		for p := range m.mfs {
+			if p == args[0] || strings.HasPrefix(p, args[0]+"/") {
+				delete(m.mfs, p)
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+	return true
+}
+
 // FIXME: what if IPFS API changes?
 func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
@@ -182,6 +317,7 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 				"/ip4/0.0.0.0/tcp/1234",
 				"/ip6/::/tcp/1234",
 			},
+			AgentVersion: IpfsAgentVersion,
 		}
 		j, _ := json.Marshal(resp)
 		w.Write(j)
@@ -390,6 +526,28 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 			goto ERROR
 		}
 		w.Write(data)
+	case "block/stat":
+		arg := r.URL.Query().Get("arg")
+		data, ok := m.BlockStore[arg]
+		if !ok {
+			goto ERROR
+		}
+		resp := mockBlockStatResp{
+			Key:  arg,
+			Size: len(data),
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "object/stat":
+		arg := r.URL.Query().Get("arg")
+		if arg == "" {
+			goto ERROR
+		}
+		resp := mockObjectStatResp{
+			CumulativeSize: 1000,
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
 	case "repo/gc":
 		// It assumes `/repo/gc` with parameter `stream-errors=true`
 		enc := json.NewEncoder(w)
@@ -437,6 +595,16 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 		w.Write(j)
 	case "resolve":
 		w.Write([]byte("{\"Path\":\"" + "/ipfs/" + CidResolved.String() + "\"}"))
+	case "config":
+		args := r.URL.Query()["arg"]
+		if len(args) != 2 {
+			goto ERROR
+		}
+		m.configMux.Lock()
+		m.config[args[0]] = args[1]
+		m.configMux.Unlock()
+		j, _ := json.Marshal(map[string]string{"Key": args[0], "Value": args[1]})
+		w.Write(j)
 	case "config/show":
 		resp := mockConfigResp{
 			Datastore: struct {
@@ -466,6 +634,14 @@ func (m *IpfsMock) handler(w http.ResponseWriter, r *http.Request) {
 		}
 	case "version":
 		w.Write([]byte("{\"Version\":\"m.o.c.k\"}"))
+	case "files/mkdir", "files/cp", "files/stat", "files/ls", "files/rm":
+		if !m.mfsHandler(w, r, endp) {
+			goto ERROR
+		}
+	case "key/list", "key/gen", "name/publish":
+		if !m.ipnsHandler(w, r, endp) {
+			goto ERROR
+		}
 	default:
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -474,6 +650,57 @@ ERROR:
 	w.WriteHeader(http.StatusInternalServerError)
 }
 
+// ipnsHandler serves the key and name endpoints used to publish IPNS
+// records. It returns false when the request should fail.
+func (m *IpfsMock) ipnsHandler(w http.ResponseWriter, r *http.Request, endp string) bool {
+	m.ipnsMux.Lock()
+	defer m.ipnsMux.Unlock()
+
+	q := r.URL.Query()
+	switch endp {
+	case "key/list":
+		resp := mockKeyListResp{}
+		for name, id := range m.keys {
+			resp.Keys = append(resp.Keys, mockKey{Name: name, ID: id})
+		}
+		j, _ := json.Marshal(resp)
+		w.Write(j)
+	case "key/gen":
+		name := q.Get("arg")
+		if _, ok := m.keys[name]; ok || name == "" {
+			return false
+		}
+		h, _ := multihash.Sum([]byte(name), multihash.SHA2_256, -1)
+		m.keys[name] = h.B58String()
+		j, _ := json.Marshal(mockKey{Name: name, ID: m.keys[name]})
+		w.Write(j)
+	case "name/publish":
+		id, ok := m.keys[q.Get("key")]
+		if !ok || !strings.HasPrefix(q.Get("arg"), "/ipfs/") {
+			return false
+		}
+		m.ipns[id] = q.Get("arg")
+		j, _ := json.Marshal(mockNamePublishResp{Name: id, Value: q.Get("arg")})
+		w.Write(j)
+	}
+	return true
+}
+
+// IPNSValue returns the path last published under the given IPNS name.
+func (m *IpfsMock) IPNSValue(name string) string {
+	m.ipnsMux.Lock()
+	defer m.ipnsMux.Unlock()
+	return m.ipns[name]
+}
+
+// ConfigValue returns the value set for the given configuration option
+// with "config".
+func (m *IpfsMock) ConfigValue(key string) string {
+	m.configMux.Lock()
+	defer m.configMux.Unlock()
+	return m.config[key]
+}
+
 // Close closes the mock server. It's important to call after each test or
 // the listeners are left hanging around.
 func (m *IpfsMock) Close() {
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// MockPeerMonitor is an in-memory implementation of the PeerMonitor
+// component. Published metrics are stored locally, as if the local peer
+// was the only one in the cluster. Alerts are only produced when injected
+// with SendAlert.
+type MockPeerMonitor struct {
+	alerts chan *api.Alert
+
+	mu      sync.RWMutex
+	metrics map[string]map[peer.ID]*api.Metric
+}
+
+// NewMockPeerMonitor returns an empty MockPeerMonitor.
+func NewMockPeerMonitor() *MockPeerMonitor {
+	return &MockPeerMonitor{
+		alerts:  make(chan *api.Alert, 256),
+		metrics: make(map[string]map[peer.ID]*api.Metric),
+	}
+}
+
+// SetClient does nothing.
+func (mon *MockPeerMonitor) SetClient(c *rpc.Client) {}
+
+// Shutdown does nothing.
+func (mon *MockPeerMonitor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// LogMetric stores a metric, replacing the previous one with the same
+// name for the same peer.
+func (mon *MockPeerMonitor) LogMetric(ctx context.Context, m *api.Metric) error {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+	byPeer, ok := mon.metrics[m.Name]
+	if !ok {
+		byPeer = make(map[peer.ID]*api.Metric)
+		mon.metrics[m.Name] = byPeer
+	}
+	byPeer[m.Peer] = m
+	return nil
+}
+
+// PublishMetric stores the metric locally.
+func (mon *MockPeerMonitor) PublishMetric(ctx context.Context, m *api.Metric) error {
+	return mon.LogMetric(ctx, m)
+}
+
+// LatestMetrics returns the latest valid, non-expired metrics with the
+// given name for every peer.
+func (mon *MockPeerMonitor) LatestMetrics(ctx context.Context, name string) []*api.Metric {
+	mon.mu.RLock()
+	defer mon.mu.RUnlock()
+	var metrics []*api.Metric
+	for _, m := range mon.metrics[name] {
+		if m.Valid && !m.Expired() {
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics
+}
+
+// MetricNames returns the sorted names of all logged metrics.
+func (mon *MockPeerMonitor) MetricNames(ctx context.Context) []string {
+	mon.mu.RLock()
+	defer mon.mu.RUnlock()
+	names := make([]string, 0, len(mon.metrics))
+	for name := range mon.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Alerts returns the channel on which alerts injected with SendAlert
+// are delivered.
+func (mon *MockPeerMonitor) Alerts() <-chan *api.Alert {
+	return mon.alerts
+}
+
+// SendAlert delivers an alert on the Alerts channel, as if the given
+// peer had stopped sending the given metric.
+func (mon *MockPeerMonitor) SendAlert(a *api.Alert) {
+	mon.alerts <- a
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -23,6 +24,9 @@ var (
 	ErrBadCid = errors.New("this is an expected error when using ErrorCid")
 	// ErrLinkNotFound is error returned when no link is found
 	ErrLinkNotFound = errors.New("no link by that name")
+	// ErrChangesTruncated is returned when asking for the status changes
+	// since the zero time.
+	ErrChangesTruncated = errors.New("status changes since the given time are not available anymore")
 )
 
 // NewMockRPCClient creates a mock ipfs-cluster RPC server and returns
@@ -85,6 +89,14 @@ func (mock *mockCluster) Unpin(ctx context.Context, in *api.Pin, out *api.Pin) e
 	return nil
 }
 
+func (mock *mockCluster) ForcePin(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	return mock.Pin(ctx, in, out)
+}
+
+func (mock *mockCluster) ForceUnpin(ctx context.Context, in *api.Pin, out *api.Pin) error {
+	return mock.Unpin(ctx, in, out)
+}
+
 func (mock *mockCluster) PinPath(ctx context.Context, in *api.PinPath, out *api.Pin) error {
 	p, err := gopath.ParsePath(in.Path)
 	if err != nil {
@@ -124,10 +136,56 @@ func (mock *mockCluster) Pins(ctx context.Context, in struct{}, out *[]*api.Pin)
 		ReplicationFactorMax: -1,
 	}
 
+	nsOpts := opts
+	nsOpts.Namespace = Namespace1
+
 	*out = []*api.Pin{
 		api.PinWithOpts(Cid1, opts),
 		api.PinCid(Cid2),
-		api.PinWithOpts(Cid3, opts),
+		api.PinWithOpts(Cid3, nsOpts),
+	}
+	return nil
+}
+
+func (mock *mockCluster) PinsQuery(ctx context.Context, in *api.PinQuery, out *[]*api.Pin) error {
+	var pins []*api.Pin
+	err := mock.Pins(ctx, struct{}{}, &pins)
+	if err != nil {
+		return err
+	}
+	*out = []*api.Pin{}
+	for _, pin := range pins {
+		if in.Matches(pin) {
+			*out = append(*out, pin)
+		}
+	}
+	return nil
+}
+
+func (mock *mockCluster) PinsView(ctx context.Context, in *api.PinViewQuery, out *api.PinViewPage) error {
+	var pins []*api.Pin
+	err := mock.PinsQuery(ctx, &in.PinQuery, &pins)
+	if err != nil {
+		return err
+	}
+	*out = api.PinViewPage{
+		Items:  []*api.PinView{},
+		Offset: in.Offset,
+		Total:  len(pins),
+	}
+	for i := in.Offset; i < len(pins); i++ {
+		if in.Limit > 0 && len(out.Items) == in.Limit {
+			break
+		}
+		var gpi api.GlobalPinInfo
+		err := mock.Status(ctx, pins[i].Cid, &gpi)
+		if err != nil {
+			return err
+		}
+		out.Items = append(out.Items, &api.PinView{
+			Pin:    pins[i],
+			Status: &gpi,
+		})
 	}
 	return nil
 }
@@ -140,6 +198,9 @@ func (mock *mockCluster) PinGet(ctx context.Context, in cid.Cid, out *api.Pin) e
 		p := api.PinCid(in)
 		p.ReplicationFactorMin = -1
 		p.ReplicationFactorMax = -1
+		if in.Equals(Cid3) {
+			p.Namespace = Namespace1
+		}
 		*out = *p
 		return nil
 	case Cid2.String(): // This is a remote pin
@@ -178,6 +239,26 @@ func (mock *mockCluster) Version(ctx context.Context, in struct{}, out *api.Vers
 	return nil
 }
 
+func (mock *mockCluster) SetLogLevel(ctx context.Context, in *api.LogLevel, out *struct{}) error {
+	if in.Facility == "" || in.Level == "" {
+		return errors.New("bad log level")
+	}
+	return nil
+}
+
+func (mock *mockCluster) Upgrade(ctx context.Context, in *api.UpgradeOptions, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) RollingUpgrade(ctx context.Context, in *api.UpgradeOptions, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) Time(ctx context.Context, in struct{}, out *time.Time) error {
+	*out = time.Now()
+	return nil
+}
+
 func (mock *mockCluster) Peers(ctx context.Context, in struct{}, out *[]*api.ID) error {
 	id := &api.ID{}
 	mock.ID(ctx, in, id)
@@ -186,6 +267,15 @@ func (mock *mockCluster) Peers(ctx context.Context, in struct{}, out *[]*api.ID)
 	return nil
 }
 
+func (mock *mockCluster) PeerNames(ctx context.Context, in struct{}, out *map[string]string) error {
+	*out = map[string]string{
+		peer.IDB58Encode(PeerID1): PeerName1,
+		peer.IDB58Encode(PeerID2): PeerName2,
+		peer.IDB58Encode(PeerID3): PeerName3,
+	}
+	return nil
+}
+
 func (mock *mockCluster) PeerAdd(ctx context.Context, in peer.ID, out *api.ID) error {
 	id := api.ID{}
 	mock.ID(ctx, struct{}{}, &id)
@@ -197,6 +287,211 @@ func (mock *mockCluster) PeerRemove(ctx context.Context, in peer.ID, out *struct
 	return nil
 }
 
+func (mock *mockCluster) PeerRemoveDryRun(ctx context.Context, in peer.ID, out *api.PeerRemoveReport) error {
+	*out = api.PeerRemoveReport{
+		Peer:            in,
+		AllocatedPins:   2,
+		ReallocatedPins: 2,
+		RepoSize:        1024,
+		Receivers: map[string]int{
+			peer.IDB58Encode(PeerID2): 2,
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) PeerMaintenance(ctx context.Context, in *api.PeerMaintenance, out *struct{}) error {
+	if in.Repin && !in.Enabled {
+		return errors.New("pins can only be re-allocated when enabling maintenance")
+	}
+	return nil
+}
+
+func (mock *mockCluster) PeerMaintenanceLocal(ctx context.Context, in *api.PeerMaintenance, out *struct{}) error {
+	return mock.PeerMaintenance(ctx, in, out)
+}
+
+func (mock *mockCluster) PeerActivate(ctx context.Context, in peer.ID, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) PeerActivateLocal(ctx context.Context, in struct{}, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) AllocationExclusions(ctx context.Context, in struct{}, out *api.AllocationExclusions) error {
+	*out = api.AllocationExclusions{
+		Peers: []peer.ID{PeerID3},
+		Tags:  map[string][]string{"rack": {"old"}},
+	}
+	return nil
+}
+
+func (mock *mockCluster) SetAllocationExclusions(ctx context.Context, in *api.AllocationExclusions, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) SetAllocationExclusionsLocal(ctx context.Context, in *api.AllocationExclusions, out *struct{}) error {
+	return mock.SetAllocationExclusions(ctx, in, out)
+}
+
+func (mock *mockCluster) ProvideStrategy(ctx context.Context, in struct{}, out *api.ProvideStrategy) error {
+	*out = api.ProvideStrategy{
+		Strategy: api.ProvidePinned,
+		Peers:    []peer.ID{PeerID1},
+	}
+	return nil
+}
+
+func (mock *mockCluster) SetProvideStrategy(ctx context.Context, in *api.ProvideStrategy, out *struct{}) error {
+	if in.Strategy == "" {
+		return nil
+	}
+	return in.Validate()
+}
+
+func (mock *mockCluster) SetProvideStrategyLocal(ctx context.Context, in *api.ProvideStrategy, out *struct{}) error {
+	return mock.SetProvideStrategy(ctx, in, out)
+}
+
+func (mock *mockCluster) RotatePeer(ctx context.Context, in *api.PeerRotation, out *struct{}) error {
+	return in.Verify()
+}
+
+func (mock *mockCluster) RotatePeerLocal(ctx context.Context, in *api.PeerRotation, out *struct{}) error {
+	return in.Verify()
+}
+
+func (mock *mockCluster) BeginSecretRotation(ctx context.Context, in []byte, out *struct{}) error {
+	if len(in) != 32 {
+		return errors.New("bad secret length")
+	}
+	return nil
+}
+
+func (mock *mockCluster) BeginSecretRotationLocal(ctx context.Context, in []byte, out *struct{}) error {
+	return mock.BeginSecretRotation(ctx, in, out)
+}
+
+func (mock *mockCluster) FinalizeSecretRotation(ctx context.Context, in struct{}, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) FinalizeSecretRotationLocal(ctx context.Context, in struct{}, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) ImportPeerAddrs(ctx context.Context, in []api.Multiaddr, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) KnownPeers(ctx context.Context, in struct{}, out *[]*api.KnownPeer) error {
+	addr, _ := api.NewMultiaddr("/ip4/1.2.3.4/tcp/9096")
+	*out = []*api.KnownPeer{
+		{
+			ID:        PeerID1,
+			Peername:  PeerName1,
+			Addresses: []api.Multiaddr{addr},
+			Tags:      map[string]int{"ipfs-cluster": 1},
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) ImportKnownPeers(ctx context.Context, in []*api.KnownPeer, out *struct{}) error {
+	return nil
+}
+
+func (mock *mockCluster) RepinProgress(ctx context.Context, in struct{}, out *[]*api.RepinProgress) error {
+	*out = []*api.RepinProgress{
+		{
+			Peer:     PeerID2,
+			Reason:   "down",
+			Started:  time.Now(),
+			Total:    2,
+			Repinned: 1,
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) RepinProgressLocal(ctx context.Context, in struct{}, out *[]*api.RepinProgress) error {
+	return mock.RepinProgress(ctx, in, out)
+}
+
+func (mock *mockCluster) Stats(ctx context.Context, in struct{}, out *api.ClusterStats) error {
+	*out = api.ClusterStats{
+		Peer:           PeerID1,
+		Time:           time.Now(),
+		Pins:           3,
+		ConsensusPeers: 1,
+		Leader:         PeerID1,
+	}
+	var ps api.PeerStats
+	mock.StatsLocal(ctx, in, &ps)
+	out.Peers = []*api.PeerStats{&ps}
+	return nil
+}
+
+func (mock *mockCluster) StatsLocal(ctx context.Context, in struct{}, out *api.PeerStats) error {
+	*out = api.PeerStats{
+		Peer:       PeerID1,
+		Peername:   PeerName1,
+		FreeSpace:  1000,
+		Pinned:     2,
+		Queued:     1,
+		QueueDepth: 1,
+		Leader:     PeerID1,
+	}
+	return nil
+}
+
+func (mock *mockCluster) QuotaUsage(ctx context.Context, in struct{}, out *[]*api.QuotaUsage) error {
+	*out = []*api.QuotaUsage{
+		{
+			Namespace: Namespace1,
+			Pins:      1,
+			Bytes:     1000,
+			MaxPins:   10,
+		},
+		{
+			User:  "alice",
+			Pins:  2,
+			Bytes: 2000,
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) ShardsGC(ctx context.Context, in struct{}, out *[]*api.Pin) error {
+	shard := api.PinCid(Cid1)
+	shard.Type = api.ShardType
+	*out = []*api.Pin{shard}
+	return nil
+}
+
+func (mock *mockCluster) StateCheckpoint(ctx context.Context, in struct{}, out *api.Pin) error {
+	pin := api.PinCid(Cid1)
+	pin.Metadata = map[string]string{"state-checkpoint": "1"}
+	*out = *pin
+	return nil
+}
+
+func (mock *mockCluster) StateCheckpoints(ctx context.Context, in struct{}, out *[]*api.Pin) error {
+	pin := &api.Pin{}
+	mock.StateCheckpoint(ctx, in, pin)
+	*out = []*api.Pin{pin}
+	return nil
+}
+
+func (mock *mockCluster) StateCheckpointPins(ctx context.Context, in cid.Cid, out *[]*api.Pin) error {
+	if in.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	*out = []*api.Pin{api.PinCid(Cid2), api.PinCid(Cid3)}
+	return nil
+}
+
 func (mock *mockCluster) ConnectGraph(ctx context.Context, in struct{}, out *api.ConnectGraph) error {
 	*out = api.ConnectGraph{
 		ClusterID: PeerID1,
@@ -219,6 +514,28 @@ func (mock *mockCluster) ConnectGraph(ctx context.Context, in struct{}, out *api
 	return nil
 }
 
+func (mock *mockCluster) StatusChanges(ctx context.Context, in time.Time, out *api.StatusChanges) error {
+	var pinInfos []*api.PinInfo
+	err := (&mockPinTracker{}).StatusChanges(ctx, in, &pinInfos)
+	if err != nil {
+		return err
+	}
+	changes := &api.StatusChanges{
+		Since: in,
+		Until: time.Now(),
+	}
+	for _, pInfo := range pinInfos {
+		changes.Changes = append(changes.Changes, &api.GlobalPinInfo{
+			Cid: pInfo.Cid,
+			PeerMap: map[string]*api.PinInfo{
+				peer.IDB58Encode(pInfo.Peer): pInfo,
+			},
+		})
+	}
+	*out = *changes
+	return nil
+}
+
 func (mock *mockCluster) StatusAll(ctx context.Context, in struct{}, out *[]*api.GlobalPinInfo) error {
 	pid := peer.IDB58Encode(PeerID1)
 	*out = []*api.GlobalPinInfo{
@@ -277,6 +594,7 @@ func (mock *mockCluster) Status(ctx context.Context, in cid.Cid, out *api.Global
 				TS:     time.Now(),
 			},
 		},
+		Status: api.GlobalPinStatusSatisfied,
 	}
 	return nil
 }
@@ -301,6 +619,152 @@ func (mock *mockCluster) RecoverLocal(ctx context.Context, in cid.Cid, out *api.
 	return (&mockPinTracker{}).Recover(ctx, in, out)
 }
 
+func (mock *mockCluster) ScheduleRecover(ctx context.Context, in *api.RecoverSchedule, out *api.RecoverSchedule) error {
+	if err := in.Validate(); err != nil {
+		return err
+	}
+	*out = api.RecoverSchedule{
+		ID:          "1",
+		Cid:         in.Cid,
+		Local:       in.Local,
+		Interval:    in.Interval,
+		MaxAttempts: in.MaxAttempts,
+		Created:     time.Now(),
+		NextAttempt: time.Now(),
+	}
+	return nil
+}
+
+func (mock *mockCluster) RecoverSchedules(ctx context.Context, in struct{}, out *[]*api.RecoverSchedule) error {
+	*out = []*api.RecoverSchedule{
+		{
+			ID:          "1",
+			Interval:    10 * time.Minute,
+			MaxAttempts: 5,
+			Attempts:    1,
+			Created:     time.Now(),
+			LastAttempt: time.Now(),
+			NextAttempt: time.Now().Add(10 * time.Minute),
+			Errored:     1,
+		},
+	}
+	return nil
+}
+
+func (mock *mockCluster) SubmitJob(ctx context.Context, in *api.Job, out *api.Job) error {
+	if err := in.Validate(); err != nil {
+		return err
+	}
+	*out = api.Job{
+		ID:      "1",
+		Kind:    in.Kind,
+		Status:  api.JobQueued,
+		Total:   len(in.Pins),
+		Created: time.Now(),
+	}
+	return nil
+}
+
+func (mock *mockCluster) Jobs(ctx context.Context, in struct{}, out *[]*api.Job) error {
+	var j api.Job
+	mock.Job(ctx, "1", &j)
+	*out = []*api.Job{&j}
+	return nil
+}
+
+func (mock *mockCluster) Job(ctx context.Context, in string, out *api.Job) error {
+	if in != "1" {
+		return errors.New("job not found")
+	}
+	*out = api.Job{
+		ID:       "1",
+		Kind:     api.JobImport,
+		Status:   api.JobRunning,
+		Progress: 10,
+		Total:    20,
+		Created:  time.Now(),
+		Started:  time.Now(),
+	}
+	return nil
+}
+
+func (mock *mockCluster) CancelJob(ctx context.Context, in string, out *struct{}) error {
+	if in != "1" {
+		return errors.New("job not found")
+	}
+	return nil
+}
+
+func (mock *mockCluster) PinCollection(ctx context.Context, in *api.Collection, out *api.Collection) error {
+	for _, c := range in.Cids {
+		if c.Equals(ErrorCid) {
+			return ErrBadCid
+		}
+	}
+	*out = *in
+	return nil
+}
+
+func (mock *mockCluster) UnpinCollection(ctx context.Context, in string, out *api.Collection) error {
+	return mock.Collection(ctx, in, out)
+}
+
+func (mock *mockCluster) Collection(ctx context.Context, in string, out *api.Collection) error {
+	if in != "dataset" {
+		return errors.New("collection not found")
+	}
+	*out = api.Collection{
+		Name: "dataset",
+		Cids: []cid.Cid{Cid1, Cid2},
+	}
+	return nil
+}
+
+func (mock *mockCluster) Collections(ctx context.Context, in struct{}, out *[]*api.Collection) error {
+	var col api.Collection
+	mock.Collection(ctx, "dataset", &col)
+	*out = []*api.Collection{&col}
+	return nil
+}
+
+func (mock *mockCluster) CollectionStatus(ctx context.Context, in string, out *[]*api.GlobalPinInfo) error {
+	var col api.Collection
+	if err := mock.Collection(ctx, in, &col); err != nil {
+		return err
+	}
+	gpis := make([]*api.GlobalPinInfo, 0, len(col.Cids))
+	for _, c := range col.Cids {
+		var gpi api.GlobalPinInfo
+		mock.Status(ctx, c, &gpi)
+		gpis = append(gpis, &gpi)
+	}
+	*out = gpis
+	return nil
+}
+
+func (mock *mockCluster) PushConfig(ctx context.Context, in []*api.ConfigSection, out *struct{}) error {
+	if len(in) == 0 {
+		return errors.New("no configuration sections given")
+	}
+	for _, s := range in {
+		if s.Name != "disk" {
+			return fmt.Errorf("the %s configuration cannot be pushed", s.Name)
+		}
+	}
+	return nil
+}
+
+func (mock *mockCluster) PushConfigLocal(ctx context.Context, in []*api.ConfigSection, out *struct{}) error {
+	return mock.PushConfig(ctx, in, out)
+}
+
+func (mock *mockCluster) CancelRecoverSchedule(ctx context.Context, in string, out *struct{}) error {
+	if in != "1" {
+		return errors.New("recover schedule not found")
+	}
+	return nil
+}
+
 func (mock *mockCluster) BlockAllocate(ctx context.Context, in *api.Pin, out *[]peer.ID) error {
 	if in.ReplicationFactorMin > 1 {
 		return errors.New("replMin too high: can only mock-allocate to 1")
@@ -309,6 +773,25 @@ func (mock *mockCluster) BlockAllocate(ctx context.Context, in *api.Pin, out *[]
 	return nil
 }
 
+func (mock *mockCluster) Prefetch(ctx context.Context, in *api.Prefetch, out *[]*api.PrefetchResult) error {
+	if in.Cid.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	peers := in.Peers
+	if len(peers) == 0 {
+		peers = []peer.ID{PeerID1}
+	}
+	res := make([]*api.PrefetchResult, 0, len(peers))
+	for _, p := range peers {
+		res = append(res, &api.PrefetchResult{
+			Cid:  in.Cid,
+			Peer: p,
+		})
+	}
+	*out = res
+	return nil
+}
+
 func (mock *mockCluster) RepoGC(ctx context.Context, in struct{}, out *api.GlobalRepoGC) error {
 	localrepoGC := &api.RepoGC{}
 	_ = mock.RepoGCLocal(ctx, struct{}{}, localrepoGC)
@@ -345,6 +828,17 @@ func (mock *mockCluster) RepoGCLocal(ctx context.Context, in struct{}, out *api.
 	return nil
 }
 
+func (mock *mockCluster) Alerts(ctx context.Context, in struct{}, out *[]*api.Alert) error {
+	*out = []*api.Alert{
+		{
+			Peer:        PeerID2,
+			MetricName:  "ping",
+			TriggeredAt: time.Now(),
+		},
+	}
+	return nil
+}
+
 func (mock *mockCluster) SendInformerMetric(ctx context.Context, in struct{}, out *api.Metric) error {
 	return nil
 }
@@ -377,6 +871,31 @@ func (mock *mockPinTracker) StatusAll(ctx context.Context, in struct{}, out *[]*
 	return nil
 }
 
+func (mock *mockPinTracker) StatusChanges(ctx context.Context, in time.Time, out *[]*api.PinInfo) error {
+	if in.IsZero() {
+		return ErrChangesTruncated
+	}
+	*out = []*api.PinInfo{
+		{
+			Cid:    Cid3,
+			Peer:   PeerID1,
+			Status: api.TrackerStatusPinError,
+			TS:     time.Now(),
+		},
+	}
+	return nil
+}
+
+func (mock *mockPinTracker) StatusAllPage(ctx context.Context, in *api.PageRequest, out *api.PinInfoPage) error {
+	var pinInfos []*api.PinInfo
+	err := mock.StatusAll(ctx, struct{}{}, &pinInfos)
+	if err != nil {
+		return err
+	}
+	*out = api.PinInfoPage{Items: pinInfos}
+	return nil
+}
+
 func (mock *mockPinTracker) Status(ctx context.Context, in cid.Cid, out *api.PinInfo) error {
 	if in.Equals(ErrorCid) {
 		return ErrBadCid
@@ -406,6 +925,27 @@ func (mock *mockPinTracker) Recover(ctx context.Context, in cid.Cid, out *api.Pi
 	return nil
 }
 
+func (mock *mockPinTracker) PendingOperations(ctx context.Context, in struct{}, out *int) error {
+	*out = 0
+	return nil
+}
+
+func (mock *mockPinTracker) Operations(ctx context.Context, in struct{}, out *[]*api.TrackerOperation) error {
+	*out = []*api.TrackerOperation{
+		{
+			Cid:      Cid1,
+			Peer:     PeerID1,
+			PeerName: PeerName1,
+			Type:     "pin",
+			Phase:    "inprogress",
+			Created:  time.Now(),
+			TS:       time.Now(),
+			Attempts: 1,
+		},
+	}
+	return nil
+}
+
 /* PeerMonitor methods */
 
 // LatestMetrics runs PeerMonitor.LatestMetrics().
@@ -494,6 +1034,13 @@ func (mock *mockIPFSConnector) BlockPut(ctx context.Context, in *api.NodeWithMet
 	return nil
 }
 
+func (mock *mockIPFSConnector) Prefetch(ctx context.Context, in cid.Cid, out *struct{}) error {
+	if in.Equals(ErrorCid) {
+		return ErrBadCid
+	}
+	return nil
+}
+
 func (mock *mockIPFSConnector) Resolve(ctx context.Context, in string, out *cid.Cid) error {
 	switch in {
 	case ErrorCid.String(), "/ipfs/" + ErrorCid.String():
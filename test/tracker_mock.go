@@ -0,0 +1,224 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+
+	cid "github.com/ipfs/go-cid"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	rpc "github.com/libp2p/go-libp2p-gorpc"
+)
+
+// MockPinTracker is an in-memory implementation of the PinTracker
+// component. Operations are performed synchronously: when an RPC client
+// is set, Track and Untrack call IPFSConnector.Pin and IPFSConnector.Unpin
+// and record the outcome. Without a client, pins are marked as pinned
+// right away.
+type MockPinTracker struct {
+	peerID   peer.ID
+	peerName string
+
+	mu        sync.RWMutex
+	rpcClient *rpc.Client
+	pins      map[cid.Cid]*api.Pin
+	status    map[cid.Cid]*api.PinInfo
+	changes   []*api.PinInfo
+}
+
+// NewMockPinTracker returns an empty MockPinTracker for the given peer.
+func NewMockPinTracker(peerID peer.ID, peerName string) *MockPinTracker {
+	return &MockPinTracker{
+		peerID:   peerID,
+		peerName: peerName,
+		pins:     make(map[cid.Cid]*api.Pin),
+		status:   make(map[cid.Cid]*api.PinInfo),
+	}
+}
+
+// SetClient sets the RPC client used to contact the IPFSConnector.
+func (mpt *MockPinTracker) SetClient(c *rpc.Client) {
+	mpt.mu.Lock()
+	defer mpt.mu.Unlock()
+	mpt.rpcClient = c
+}
+
+// Shutdown does nothing.
+func (mpt *MockPinTracker) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (mpt *MockPinTracker) pinInfo(c cid.Cid, st api.TrackerStatus, err error) *api.PinInfo {
+	pInfo := &api.PinInfo{
+		Cid:      c,
+		Peer:     mpt.peerID,
+		PeerName: mpt.peerName,
+		Status:   st,
+		TS:       time.Now(),
+	}
+	if err != nil {
+		pInfo.Error = err.Error()
+	}
+	return pInfo
+}
+
+func (mpt *MockPinTracker) ipfsCall(ctx context.Context, method string, pin *api.Pin) error {
+	mpt.mu.RLock()
+	client := mpt.rpcClient
+	mpt.mu.RUnlock()
+	if client == nil {
+		return nil
+	}
+	return client.CallContext(ctx, "", "IPFSConnector", method, pin, &struct{}{})
+}
+
+// Track pins the given item and records its status.
+func (mpt *MockPinTracker) Track(ctx context.Context, pin *api.Pin) error {
+	mpt.mu.Lock()
+	mpt.pins[pin.Cid] = pin
+	mpt.mu.Unlock()
+
+	if pin.Type == api.MetaType {
+		// Like the real trackers, meta pins are not sent to IPFS.
+		mpt.setStatus(mpt.pinInfo(pin.Cid, api.TrackerStatusSharded, nil))
+		return nil
+	}
+
+	err := mpt.ipfsCall(ctx, "Pin", pin)
+	if err != nil {
+		mpt.setStatus(mpt.pinInfo(pin.Cid, api.TrackerStatusPinError, err))
+		return nil
+	}
+	mpt.setStatus(mpt.pinInfo(pin.Cid, api.TrackerStatusPinned, nil))
+	return nil
+}
+
+// Untrack unpins the given Cid and forgets about it.
+func (mpt *MockPinTracker) Untrack(ctx context.Context, c cid.Cid) error {
+	mpt.mu.Lock()
+	pin, ok := mpt.pins[c]
+	delete(mpt.pins, c)
+	mpt.mu.Unlock()
+	if !ok {
+		pin = api.PinCid(c)
+	}
+
+	err := mpt.ipfsCall(ctx, "Unpin", pin)
+	if err != nil {
+		mpt.setStatus(mpt.pinInfo(c, api.TrackerStatusUnpinError, err))
+		return nil
+	}
+
+	mpt.mu.Lock()
+	delete(mpt.status, c)
+	mpt.changes = append(mpt.changes, mpt.pinInfo(c, api.TrackerStatusUnpinned, nil))
+	mpt.mu.Unlock()
+	return nil
+}
+
+func (mpt *MockPinTracker) setStatus(pInfo *api.PinInfo) {
+	mpt.mu.Lock()
+	defer mpt.mu.Unlock()
+	mpt.status[pInfo.Cid] = pInfo
+	mpt.changes = append(mpt.changes, pInfo)
+}
+
+// StatusAll returns the status of all tracked items.
+func (mpt *MockPinTracker) StatusAll(ctx context.Context) []*api.PinInfo {
+	mpt.mu.RLock()
+	defer mpt.mu.RUnlock()
+	pinInfos := make([]*api.PinInfo, 0, len(mpt.status))
+	for _, pInfo := range mpt.status {
+		pinInfos = append(pinInfos, pInfo)
+	}
+	return pinInfos
+}
+
+// StatusAllChan sends the status of all tracked items on the given channel.
+func (mpt *MockPinTracker) StatusAllChan(ctx context.Context, out chan<- *api.PinInfo) error {
+	defer close(out)
+	for _, pInfo := range mpt.StatusAll(ctx) {
+		select {
+		case out <- pInfo:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Status returns the status of the given Cid, which is unpinned
+// when it is not tracked.
+func (mpt *MockPinTracker) Status(ctx context.Context, c cid.Cid) *api.PinInfo {
+	mpt.mu.RLock()
+	defer mpt.mu.RUnlock()
+	pInfo, ok := mpt.status[c]
+	if !ok {
+		return mpt.pinInfo(c, api.TrackerStatusUnpinned, nil)
+	}
+	return pInfo
+}
+
+// RecoverAll calls Recover for every item in error status.
+func (mpt *MockPinTracker) RecoverAll(ctx context.Context) ([]*api.PinInfo, error) {
+	var pinInfos []*api.PinInfo
+	for _, pInfo := range mpt.StatusAll(ctx) {
+		if pInfo.Status.Match(api.TrackerStatusError) {
+			recovered, err := mpt.Recover(ctx, pInfo.Cid)
+			if err != nil {
+				return pinInfos, err
+			}
+			pinInfos = append(pinInfos, recovered)
+		}
+	}
+	return pinInfos, nil
+}
+
+// Recover retries the pin or unpin operation for an item in error
+// status and returns its new status.
+func (mpt *MockPinTracker) Recover(ctx context.Context, c cid.Cid) (*api.PinInfo, error) {
+	pInfo := mpt.Status(ctx, c)
+	switch pInfo.Status {
+	case api.TrackerStatusPinError:
+		mpt.mu.RLock()
+		pin := mpt.pins[c]
+		mpt.mu.RUnlock()
+		if pin == nil {
+			pin = api.PinCid(c)
+		}
+		mpt.Track(ctx, pin)
+	case api.TrackerStatusUnpinError:
+		mpt.Untrack(ctx, c)
+	}
+	return mpt.Status(ctx, c), nil
+}
+
+// PendingOperations always returns 0, since operations are synchronous.
+func (mpt *MockPinTracker) PendingOperations(ctx context.Context) int {
+	return 0
+}
+
+// StatusChanges returns the latest status of the items whose status was set
+// after the given time.
+func (mpt *MockPinTracker) StatusChanges(ctx context.Context, since time.Time) ([]*api.PinInfo, error) {
+	mpt.mu.RLock()
+	defer mpt.mu.RUnlock()
+	var pinInfos []*api.PinInfo
+	seen := make(map[cid.Cid]struct{})
+	for i := len(mpt.changes) - 1; i >= 0; i-- {
+		pInfo := mpt.changes[i]
+		if _, ok := seen[pInfo.Cid]; ok || !pInfo.TS.After(since) {
+			continue
+		}
+		seen[pInfo.Cid] = struct{}{}
+		pinInfos = append(pinInfos, pInfo)
+	}
+	return pinInfos, nil
+}
+
+// Operations always returns an empty list, since operations are synchronous.
+func (mpt *MockPinTracker) Operations(ctx context.Context) []*api.TrackerOperation {
+	return []*api.TrackerOperation{}
+}
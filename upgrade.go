@@ -0,0 +1,184 @@
+package ipfscluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/ipfs-cluster/api"
+	"github.com/ipfs/ipfs-cluster/version"
+
+	semver "github.com/blang/semver"
+	peer "github.com/libp2p/go-libp2p-core/peer"
+	"go.opencensus.io/trace"
+)
+
+// UpgradeFunc replaces the running peer with the given release. It is
+// expected to return once the new version is in place and to restart the
+// peer asynchronously, so that the caller can receive the answer.
+type UpgradeFunc func(ctx context.Context, opts *api.UpgradeOptions) error
+
+var errUpgradesDisabled = errors.New("upgrades are not enabled in this peer")
+
+// UpgradeTimeout specifies how long RollingUpgrade waits for a peer to come
+// back with the new version before giving up.
+var UpgradeTimeout = 5 * time.Minute
+
+// upgradeCheckInterval specifies how often RollingUpgrade checks the version
+// of a peer which is being upgraded.
+var upgradeCheckInterval = 2 * time.Second
+
+// SetUpgradeFunc sets the function used to upgrade this peer when Upgrade
+// is called. Upgrades are disabled until this is set.
+func (c *Cluster) SetUpgradeFunc(f UpgradeFunc) {
+	c.upgradeMux.Lock()
+	defer c.upgradeMux.Unlock()
+	c.upgradeF = f
+}
+
+// checkUpgrade parses the version to upgrade to and refuses anything but
+// newer versions, unless forced.
+func checkUpgrade(opts *api.UpgradeOptions) (semver.Version, error) {
+	target, err := semver.ParseTolerant(opts.Version)
+	if err != nil {
+		return target, fmt.Errorf("bad version %q: %s", opts.Version, err)
+	}
+	if !opts.Force && !target.GT(version.Version) {
+		return target, fmt.Errorf("refusing to downgrade from %s to %s without force", version.Version, target)
+	}
+	return target, nil
+}
+
+// Upgrade replaces this peer with the given version using the function set
+// with SetUpgradeFunc. The peer restarts once the upgrade is in place.
+// Versions older than (or equal to) the running one are refused unless
+// forced.
+//
+// Upgrade is closed to other peers in the default RPC policy. Peers which
+// should follow RollingUpgrade() must set "Cluster.Upgrade" to "trusted" in
+// their "rpc_policy".
+func (c *Cluster) Upgrade(ctx context.Context, opts *api.UpgradeOptions) error {
+	_, span := trace.StartSpan(ctx, "cluster/Upgrade")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	c.upgradeMux.Lock()
+	f := c.upgradeF
+	c.upgradeMux.Unlock()
+
+	if f == nil {
+		return errUpgradesDisabled
+	}
+
+	target, err := checkUpgrade(opts)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("upgrading to version %s", target)
+	return f(ctx, opts)
+}
+
+// RollingUpgrade upgrades every peer in the cluster to the given version,
+// one at a time. Each peer must report the new version before the next one
+// is upgraded. This peer is upgraded last. The process stops on the first
+// peer that fails to upgrade. The options, including the download location,
+// are passed on to every peer.
+func (c *Cluster) RollingUpgrade(ctx context.Context, opts *api.UpgradeOptions) error {
+	_, span := trace.StartSpan(ctx, "cluster/RollingUpgrade")
+	defer span.End()
+	ctx = trace.NewContext(c.ctx, span)
+
+	target, err := semver.ParseTolerant(opts.Version)
+	if err != nil {
+		return fmt.Errorf("bad version %q: %s", opts.Version, err)
+	}
+	// Do not start downgrading other peers when this one is going to
+	// refuse it.
+	if !version.Version.Equals(target) {
+		if _, err := checkUpgrade(opts); err != nil {
+			return err
+		}
+	}
+
+	peers, err := c.consensus.Peers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range peers {
+		if p == c.id {
+			continue
+		}
+
+		current, err := c.peerVersion(ctx, p)
+		if err == nil && current.Equals(target) {
+			logger.Infof("%s already running version %s", peer.IDB58Encode(p), target)
+			continue
+		}
+
+		logger.Infof("upgrading %s to version %s", peer.IDB58Encode(p), target)
+		err = c.rpcClient.CallContext(
+			ctx,
+			p,
+			"Cluster",
+			"Upgrade",
+			opts,
+			&struct{}{},
+		)
+		if err != nil {
+			return fmt.Errorf("upgrading %s: %s", peer.IDB58Encode(p), err)
+		}
+
+		err = c.waitForVersion(ctx, p, target)
+		if err != nil {
+			return fmt.Errorf("upgrading %s: %s", peer.IDB58Encode(p), err)
+		}
+	}
+
+	if version.Version.Equals(target) {
+		return nil
+	}
+	return c.Upgrade(ctx, opts)
+}
+
+func (c *Cluster) peerVersion(ctx context.Context, p peer.ID) (semver.Version, error) {
+	ctx, cancel := c.rpcContext(ctx, "Cluster", "Version")
+	defer cancel()
+
+	var out api.Version
+	err := c.rpcClient.CallContext(
+		ctx,
+		p,
+		"Cluster",
+		"Version",
+		struct{}{},
+		&out,
+	)
+	if err != nil {
+		return semver.Version{}, err
+	}
+	return semver.ParseTolerant(out.Version)
+}
+
+// waitForVersion waits until the given peer reports the given version.
+func (c *Cluster) waitForVersion(ctx context.Context, p peer.ID, target semver.Version) error {
+	ctx, cancel := context.WithTimeout(ctx, UpgradeTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(upgradeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("peer did not come back with version %s", target)
+		case <-ticker.C:
+			v, err := c.peerVersion(ctx, p)
+			if err == nil && v.Equals(target) {
+				return nil
+			}
+		}
+	}
+}
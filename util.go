@@ -105,14 +105,16 @@ type distanceChecker struct {
 	cache      map[peer.ID]distance
 }
 
-func (dc distanceChecker) isClosest(ci cid.Cid) bool {
-	ciHash := convertKey(ci.KeyString())
+// isClosestKey returns whether the local peer is closer to the given key
+// than all the other peers.
+func (dc distanceChecker) isClosestKey(key string) bool {
+	keyHash := convertKey(key)
 	localPeerHash := dc.convertPeerID(dc.local)
-	myDistance := xor(ciHash, localPeerHash)
+	myDistance := xor(keyHash, localPeerHash)
 
 	for _, p := range dc.otherPeers {
 		peerHash := dc.convertPeerID(p)
-		distance := xor(peerHash, ciHash)
+		distance := xor(peerHash, keyHash)
 
 		// if myDistance is larger than for other peers...
 		if bytes.Compare(myDistance[:], distance[:]) > 0 {